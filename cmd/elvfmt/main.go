@@ -0,0 +1,72 @@
+// Command elvfmt reformats Elvish sources.
+//
+// With no arguments, it formats the code from stdin and writes the result to
+// stdout. Otherwise it formats the files in the arguments.
+//
+// For the changes made by the formatter, see [src.elv.sh/pkg/elvfmt].
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/diff"
+	"src.elv.sh/pkg/elvfmt"
+	"src.elv.sh/pkg/parse"
+)
+
+var (
+	overwrite = flag.Bool("w", false, "write result to source file")
+	showDiff  = flag.Bool("d", false, "show diff")
+)
+
+func main() {
+	flag.Parse()
+
+	files := flag.Args()
+	if len(files) == 0 {
+		text, err := io.ReadAll(os.Stdin)
+		handleReadError("stdin", err)
+		fmt.Print(format("[stdin]", string(text)))
+		return
+	}
+	for _, file := range files {
+		textBytes, err := os.ReadFile(file)
+		handleReadError(file, err)
+		text := string(textBytes)
+		result := format(file, text)
+		if *overwrite {
+			if result != text {
+				err := os.WriteFile(file, []byte(result), 0644)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "write %s: %v\n", file, err)
+					os.Exit(2)
+				}
+			}
+		} else if !*showDiff {
+			fmt.Print(result)
+		}
+		if *showDiff {
+			os.Stdout.Write(diff.Diff(file+".orig", text, file, result))
+		}
+	}
+}
+
+func format(name, original string) string {
+	formatted, err := elvfmt.Format(parse.Source{Name: name, Code: original, IsFile: true})
+	if err != nil {
+		diag.ShowError(os.Stderr, err)
+		os.Exit(2)
+	}
+	return formatted
+}
+
+func handleReadError(name string, err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "read %s: %v\n", name, err)
+		os.Exit(2)
+	}
+}
@@ -9,7 +9,10 @@ import (
 
 	"src.elv.sh/pkg/buildinfo"
 	"src.elv.sh/pkg/daemon"
+	"src.elv.sh/pkg/elvdoc"
+	"src.elv.sh/pkg/lint"
 	"src.elv.sh/pkg/lsp"
+	"src.elv.sh/pkg/parse/parsedump"
 	"src.elv.sh/pkg/prog"
 	"src.elv.sh/pkg/shell"
 )
@@ -18,6 +21,7 @@ func main() {
 	os.Exit(prog.Run(
 		[3]*os.File{os.Stdin, os.Stdout, os.Stderr}, os.Args,
 		prog.Composite(
-			&buildinfo.Program{}, &daemon.Program{}, &lsp.Program{},
+			&buildinfo.Program{}, &daemon.Program{}, &lsp.Program{}, &elvdoc.Program{},
+			&lint.Program{}, &parsedump.Program{},
 			&shell.Program{ActivateDaemon: daemon.Activate})))
 }
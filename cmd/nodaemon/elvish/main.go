@@ -6,7 +6,10 @@ import (
 	"os"
 
 	"src.elv.sh/pkg/buildinfo"
+	"src.elv.sh/pkg/elvdoc"
+	"src.elv.sh/pkg/lint"
 	"src.elv.sh/pkg/lsp"
+	"src.elv.sh/pkg/parse/parsedump"
 	"src.elv.sh/pkg/prog"
 	"src.elv.sh/pkg/shell"
 )
@@ -14,5 +17,7 @@ import (
 func main() {
 	os.Exit(prog.Run(
 		[3]*os.File{os.Stdin, os.Stdout, os.Stderr}, os.Args,
-		prog.Composite(&buildinfo.Program{}, &lsp.Program{}, &shell.Program{})))
+		prog.Composite(
+			&buildinfo.Program{}, &lsp.Program{}, &elvdoc.Program{}, &lint.Program{}, &parsedump.Program{},
+			&shell.Program{})))
 }
@@ -7,7 +7,10 @@ import (
 
 	"src.elv.sh/pkg/buildinfo"
 	"src.elv.sh/pkg/daemon"
+	"src.elv.sh/pkg/elvdoc"
+	"src.elv.sh/pkg/lint"
 	"src.elv.sh/pkg/lsp"
+	"src.elv.sh/pkg/parse/parsedump"
 	"src.elv.sh/pkg/pprof"
 	"src.elv.sh/pkg/prog"
 	"src.elv.sh/pkg/shell"
@@ -18,5 +21,6 @@ func main() {
 		[3]*os.File{os.Stdin, os.Stdout, os.Stderr}, os.Args,
 		prog.Composite(
 			&pprof.Program{}, &buildinfo.Program{}, &daemon.Program{}, &lsp.Program{},
+			&elvdoc.Program{}, &lint.Program{}, &parsedump.Program{},
 			&shell.Program{ActivateDaemon: daemon.Activate})))
 }
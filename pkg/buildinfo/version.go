@@ -0,0 +1,56 @@
+package buildinfo
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed version of Elvish.
+type Version struct {
+	// The X, Y and Z in X.Y.Z.
+	Nums [3]int
+	// Whether the version has a prerelease suffix, like "-dev.unknown" or
+	// "-rc.1".
+	Prerelease bool
+}
+
+// ParseVersion parses a version of the form X[.Y[.Z]][-prerelease][+variant],
+// where X, Y and Z are non-negative integers without leading zeros. Missing
+// components are treated as 0, and the variant is ignored.
+func ParseVersion(s string) (Version, bool) {
+	var v Version
+	s, _, _ = strings.Cut(s, "+")
+	s, _, v.Prerelease = strings.Cut(s, "-")
+	fields := strings.Split(s, ".")
+	if len(fields) > len(v.Nums) {
+		return Version{}, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 || strconv.Itoa(n) != field {
+			return Version{}, false
+		}
+		v.Nums[i] = n
+	}
+	return v, true
+}
+
+// CompareVersions returns a negative number, 0 or a positive number if a is
+// older than, the same as or newer than b respectively. A prerelease is older
+// than the release with the same X.Y.Z; prereleases of the same X.Y.Z are not
+// compared with each other.
+func CompareVersions(a, b Version) int {
+	for i := range a.Nums {
+		if a.Nums[i] != b.Nums[i] {
+			return a.Nums[i] - b.Nums[i]
+		}
+	}
+	switch {
+	case a.Prerelease && !b.Prerelease:
+		return -1
+	case !a.Prerelease && b.Prerelease:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,72 @@
+package buildinfo
+
+import "testing"
+
+var parseVersionTests = []struct {
+	s    string
+	want Version
+	ok   bool
+}{
+	{"0.22.1", Version{Nums: [3]int{0, 22, 1}}, true},
+	{"0.22", Version{Nums: [3]int{0, 22, 0}}, true},
+	{"1", Version{Nums: [3]int{1, 0, 0}}, true},
+	{"0.22.0-dev.unknown", Version{Nums: [3]int{0, 22, 0}, Prerelease: true}, true},
+	{"0.22.0+deb1", Version{Nums: [3]int{0, 22, 0}}, true},
+	{"0.22.0-rc.1+deb1", Version{Nums: [3]int{0, 22, 0}, Prerelease: true}, true},
+	{"bad", Version{}, false},
+	{"0.x", Version{}, false},
+	{"0.22.1.0", Version{}, false},
+	{"0.022", Version{}, false},
+	{"0.-1", Version{}, false},
+	{"", Version{}, false},
+}
+
+func TestParseVersion(t *testing.T) {
+	for _, test := range parseVersionTests {
+		got, ok := ParseVersion(test.s)
+		if got != test.want || ok != test.ok {
+			t.Errorf("ParseVersion(%q) -> (%v, %v), want (%v, %v)",
+				test.s, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+var compareVersionsTests = []struct {
+	a, b string
+	want int
+}{
+	{"0.22.0", "0.21.0", 1},
+	{"0.21.0", "0.22.0", -1},
+	{"0.22.0", "0.22.0", 0},
+	{"1.0.0", "0.99.99", 1},
+	{"0.10.0", "0.9.0", 1},
+	{"0.22", "0.22.0", 0},
+	{"0.22.1", "0.22.0+ubuntu", 1},
+	{"0.22.0", "0.22.0+ubuntu", 0},
+	{"0.22.0", "0.22.0-dev.0.20240101000000-abcdef", 1},
+	{"0.22.0-rc.1", "0.22.0", -1},
+	{"0.22.0-rc.2", "0.22.0-rc.1", 0},
+	{"0.23.0-rc.1", "0.22.0", 1},
+	{"0.23.0", "0.22.0-dev.unknown", 1},
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, test := range compareVersionsTests {
+		a, _ := ParseVersion(test.a)
+		b, _ := ParseVersion(test.b)
+		if got := sign(CompareVersions(a, b)); got != test.want {
+			t.Errorf("CompareVersions(%q, %q) -> %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func sign(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	default:
+		return 0
+	}
+}
@@ -69,6 +69,7 @@ type app struct {
 	RPromptPersistent func() bool
 	BeforeReadline    []func()
 	AfterReadline     []func(string)
+	OnBufferChange    []func(tk.CodeBuffer)
 	Highlighter       Highlighter
 	Prompt            Prompt
 	RPrompt           Prompt
@@ -99,6 +100,7 @@ func NewApp(spec AppSpec) App {
 		RPromptPersistent: spec.RPromptPersistent,
 		BeforeReadline:    spec.BeforeReadline,
 		AfterReadline:     spec.AfterReadline,
+		OnBufferChange:    spec.OnBufferChange,
 		Highlighter:       spec.Highlighter,
 		Prompt:            spec.Prompt,
 		RPrompt:           spec.RPrompt,
@@ -211,6 +213,16 @@ func (a *app) resetAllStates() {
 }
 
 func (a *app) handle(e event) {
+	if len(a.OnBufferChange) > 0 {
+		oldBuffer := a.codeArea.CopyState().Buffer
+		defer func() {
+			if buffer := a.codeArea.CopyState().Buffer; buffer != oldBuffer {
+				for _, f := range a.OnBufferChange {
+					f(buffer)
+				}
+			}
+		}()
+	}
 	switch e := e.(type) {
 	case os.Signal:
 		switch e {
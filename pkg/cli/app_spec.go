@@ -12,6 +12,8 @@ type AppSpec struct {
 	RPromptPersistent func() bool
 	BeforeReadline    []func()
 	AfterReadline     []func(string)
+	// Called after the code buffer has been changed while reading code.
+	OnBufferChange []func(tk.CodeBuffer)
 
 	Highlighter Highlighter
 	Prompt      Prompt
@@ -106,6 +106,28 @@ func TestReadCode_CallsAfterReadline(t *testing.T) {
 	}
 }
 
+func TestReadCode_CallsOnBufferChange(t *testing.T) {
+	callCh := make(chan tk.CodeBuffer, 10)
+	f := Setup(WithSpec(func(spec *AppSpec) {
+		spec.OnBufferChange = []func(tk.CodeBuffer){
+			func(b tk.CodeBuffer) { callCh <- b }}
+	}))
+
+	feedInput(f.TTY, "ab\n")
+	f.Wait()
+
+	for _, want := range []tk.CodeBuffer{{Content: "a", Dot: 1}, {Content: "ab", Dot: 2}} {
+		select {
+		case b := <-callCh:
+			if b != want {
+				t.Errorf("OnBufferChange hook called with %v, want %v", b, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("OnBufferChange not called for %v", want)
+		}
+	}
+}
+
 func TestReadCode_FinalRedraw(t *testing.T) {
 	f := Setup(WithSpec(func(spec *AppSpec) {
 		spec.CodeAreaState.Buffer.Content = "code"
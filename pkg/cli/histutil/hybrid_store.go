@@ -21,6 +21,17 @@ type hybridStore struct {
 
 func (s hybridStore) AddCmd(cmd storedefs.Cmd) (int, error) {
 	seq, err := s.shared.AddCmd(cmd)
+	// The database may return the sequence number of the last command instead
+	// of adding a duplicate. It returns 0 for commands matching its ignore
+	// patterns, which are not added to it but are still kept in the session
+	// history.
+	if err == nil && seq > 0 {
+		c := s.session.Cursor("")
+		c.Prev()
+		if last, err := c.Get(); err == nil && last.Seq == seq {
+			return seq, nil
+		}
+	}
 	s.session.AddCmd(storedefs.Cmd{Text: cmd.Text, Seq: seq})
 	return seq, err
 }
@@ -3,6 +3,7 @@ package histutil
 import (
 	"errors"
 	"reflect"
+	"strings"
 	"testing"
 
 	"src.elv.sh/pkg/store/storedefs"
@@ -76,6 +77,68 @@ func TestHybridStore_AddCmd_AddsToSessionEvenIfDBErrors(t *testing.T) {
 	}
 }
 
+func TestHybridStore_AddCmd_SkipsSessionIfDBDedups(t *testing.T) {
+	// Sequence numbers of the real database start from 1.
+	db := dedupDB{NewFaultyInMemoryDB("shared 1")}
+	f := mustNewHybridStore(db)
+
+	f.AddCmd(storedefs.Cmd{Text: "echo"})
+	f.AddCmd(storedefs.Cmd{Text: "echo"})
+
+	allCmds, err := f.AllCmds()
+	if err != nil {
+		panic(err)
+	}
+	wantAllCmds := []storedefs.Cmd{{Text: "shared 1", Seq: 0}, {Text: "echo", Seq: 1}}
+	if !reflect.DeepEqual(allCmds, wantAllCmds) {
+		t.Errorf("AllCmd -> %v, want %v", allCmds, wantAllCmds)
+	}
+}
+
+// A DB that doesn't add consecutive duplicates.
+type dedupDB struct{ FaultyInMemoryDB }
+
+func (db dedupDB) AddCmd(cmd string) (int, error) {
+	next, _ := db.NextCmdSeq()
+	if last, err := db.PrevCmd(next, ""); err == nil && last.Text == cmd {
+		return last.Seq, nil
+	}
+	return db.FaultyInMemoryDB.AddCmd(cmd)
+}
+
+func TestHybridStore_AddCmd_KeepsCommandsIgnoredByDBInSession(t *testing.T) {
+	db := ignoreDB{NewFaultyInMemoryDB("shared 1")}
+	f := mustNewHybridStore(db)
+
+	f.AddCmd(storedefs.Cmd{Text: "secret 1"})
+	f.AddCmd(storedefs.Cmd{Text: "secret 2"})
+	f.AddCmd(storedefs.Cmd{Text: "echo"})
+
+	allCmds, err := f.AllCmds()
+	if err != nil {
+		panic(err)
+	}
+	wantAllCmds := []storedefs.Cmd{
+		{Text: "shared 1", Seq: 0},
+		{Text: "secret 1", Seq: 0},
+		{Text: "secret 2", Seq: 0},
+		{Text: "echo", Seq: 1}}
+	if !reflect.DeepEqual(allCmds, wantAllCmds) {
+		t.Errorf("AllCmd -> %v, want %v", allCmds, wantAllCmds)
+	}
+}
+
+// A DB that ignores commands starting with "secret", like the real database
+// does with its ignore patterns.
+type ignoreDB struct{ FaultyInMemoryDB }
+
+func (db ignoreDB) AddCmd(cmd string) (int, error) {
+	if strings.HasPrefix(cmd, "secret") {
+		return 0, nil
+	}
+	return db.FaultyInMemoryDB.AddCmd(cmd)
+}
+
 func TestHybridStore_AllCmds_IncludesFrozenSharedAndNewlyAdded(t *testing.T) {
 	db := NewFaultyInMemoryDB("shared 1")
 	f := mustNewHybridStore(db)
@@ -0,0 +1,128 @@
+package modes
+
+import (
+	"errors"
+	"strings"
+
+	"src.elv.sh/pkg/cli"
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/ui"
+)
+
+// Calc is a mode for evaluating arithmetic expressions. The expression is
+// typed into the mode's own code area, and the result is shown below it as
+// the expression changes.
+type Calc interface {
+	tk.Widget
+	// Accept inserts the result at the dot of the code area the mode was
+	// started from, and closes the mode. It returns an error if the current
+	// expression can't be evaluated.
+	Accept() error
+}
+
+// CalcSpec specifies the configuration for the calc mode.
+type CalcSpec struct {
+	// Key bindings.
+	Bindings tk.Bindings
+	// The function to evaluate an expression. Required.
+	Evaluate func(expr string) (string, error)
+}
+
+type calc struct {
+	CalcSpec
+	app        cli.App
+	attachedTo tk.CodeArea
+	codeArea   tk.CodeArea
+	lastExpr   string
+	result     string
+	err        error
+}
+
+var (
+	errEvaluateIsRequired = errors.New("evaluate is required")
+	errEmptyExpression    = errors.New("empty expression")
+)
+
+// NewCalc creates a new calc mode.
+func NewCalc(app cli.App, spec CalcSpec) (Calc, error) {
+	codeArea, err := FocusedCodeArea(app)
+	if err != nil {
+		return nil, err
+	}
+	if spec.Evaluate == nil {
+		return nil, errEvaluateIsRequired
+	}
+	if spec.Bindings == nil {
+		spec.Bindings = tk.DummyBindings{}
+	}
+	w := &calc{
+		CalcSpec: spec, app: app, attachedTo: codeArea,
+		codeArea: tk.NewCodeArea(tk.CodeAreaSpec{
+			Prompt: modePrompt(" CALC ", true),
+		}),
+	}
+	w.update(true)
+	return w, nil
+}
+
+func (w *calc) Render(width, height int) *term.Buffer {
+	buf := w.render(width)
+	buf.TrimToLines(0, height)
+	return buf
+}
+
+func (w *calc) MaxHeight(width, height int) int {
+	return len(w.render(width).Lines)
+}
+
+func (w *calc) render(width int) *term.Buffer {
+	buf := w.codeArea.Render(width, 1)
+	var line ui.Text
+	if w.err != nil {
+		if w.err != errEmptyExpression {
+			line = ui.T(w.err.Error(), ui.FgRed)
+		}
+	} else {
+		line = ui.T("= " + w.result)
+	}
+	if line != nil {
+		buf.ExtendDown(term.NewBufferBuilder(width).WriteStyled(line).Buffer(), false)
+	}
+	return buf
+}
+
+func (w *calc) Handle(event term.Event) bool {
+	handled := w.Bindings.Handle(w, event)
+	if !handled {
+		handled = w.codeArea.Handle(event)
+	}
+	w.update(false)
+	return handled
+}
+
+func (w *calc) Focus() bool { return true }
+
+func (w *calc) Accept() error {
+	if w.err != nil {
+		return w.err
+	}
+	w.attachedTo.MutateState(func(s *tk.CodeAreaState) {
+		s.Buffer.InsertAtDot(w.result)
+	})
+	w.app.PopAddon()
+	return nil
+}
+
+func (w *calc) update(force bool) {
+	expr := w.codeArea.CopyState().Buffer.Content
+	if expr == w.lastExpr && !force {
+		return
+	}
+	w.lastExpr = expr
+	if strings.TrimSpace(expr) == "" {
+		w.result, w.err = "", errEmptyExpression
+		return
+	}
+	w.result, w.err = w.Evaluate(expr)
+}
@@ -0,0 +1,89 @@
+package modes
+
+import (
+	"errors"
+	"testing"
+
+	"src.elv.sh/pkg/cli"
+	. "src.elv.sh/pkg/cli/clitest"
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/ui"
+)
+
+var errBadExpr = errors.New("bad expression")
+
+func testEvaluate(expr string) (string, error) {
+	if expr == "1+1" {
+		return "2", nil
+	}
+	return "", errBadExpr
+}
+
+func TestCalc(t *testing.T) {
+	f := Setup(WithSpec(func(spec *cli.AppSpec) {
+		spec.CodeAreaState.Buffer = tk.CodeBuffer{Content: "ab", Dot: 1}
+	}))
+	defer f.Stop()
+
+	w := startCalc(f.App, CalcSpec{
+		Evaluate: testEvaluate,
+		Bindings: tk.MapBindings{
+			term.K('\n'): func(w tk.Widget) { w.(Calc).Accept() },
+		},
+	})
+	f.TestTTY(t,
+		"ab", "\n",
+		" CALC  ", Styles,
+		"****** ", term.DotHere,
+	)
+
+	f.TTY.Inject(term.K('1'), term.K('+'))
+	f.TestTTY(t,
+		"ab", "\n",
+		" CALC  1+", Styles,
+		"******   ", term.DotHere, "\n",
+		"bad expression", Styles,
+		"!!!!!!!!!!!!!!",
+	)
+	if err := w.Accept(); err != errBadExpr {
+		t.Errorf("Accept() -> %v, want %v", err, errBadExpr)
+	}
+
+	f.TTY.Inject(term.K('1'))
+	f.TestTTY(t,
+		"ab", "\n",
+		" CALC  1+1", Styles,
+		"******    ", term.DotHere, "\n",
+		"= 2",
+	)
+
+	f.TTY.Inject(term.K('\n'))
+	f.TestTTY(t, "a2", term.DotHere, "b")
+}
+
+func TestCalc_FocusedWidgetNotCodeArea(t *testing.T) {
+	testFocusedWidgetNotCodeArea(t, func(app cli.App) error {
+		_, err := NewCalc(app, CalcSpec{Evaluate: testEvaluate})
+		return err
+	})
+}
+
+func TestCalc_NoEvaluate(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	startCalc(f.App, CalcSpec{})
+	f.TTY.TestMsg(t, ui.Concat(ui.T("error:", ui.FgRed), ui.T(" evaluate is required")))
+}
+
+func startCalc(app cli.App, spec CalcSpec) Calc {
+	w, err := NewCalc(app, spec)
+	if err != nil {
+		app.Notify(ErrorText(err))
+		return nil
+	}
+	app.PushAddon(w)
+	app.Redraw()
+	return w
+}
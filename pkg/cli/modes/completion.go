@@ -3,6 +3,7 @@ package modes
 import (
 	"errors"
 	"strings"
+	"sync"
 
 	"src.elv.sh/pkg/cli"
 	"src.elv.sh/pkg/cli/tk"
@@ -14,6 +15,10 @@ import (
 // candidates. It is based on the ComboBox widget.
 type Completion interface {
 	tk.ComboBox
+	// ToggleMark toggles whether the selected candidate is marked, and moves
+	// the selection to the next candidate. When there are marked candidates,
+	// all of them are inserted instead of the selected one.
+	ToggleMark()
 }
 
 // CompletionSpec specifies the configuration for the completion mode.
@@ -36,6 +41,21 @@ type CompletionItem struct {
 type completion struct {
 	tk.ComboBox
 	attached tk.CodeArea
+	replace  diag.Ranging
+	items    []CompletionItem
+	marks    *completionMarks
+}
+
+// Set of marked candidates, identified by their ToInsert field.
+type completionMarks struct {
+	mutex  sync.Mutex
+	marked map[string]bool
+}
+
+func (m *completionMarks) has(s string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.marked[s]
 }
 
 var errNoCandidates = errors.New("no candidates")
@@ -49,7 +69,10 @@ func NewCompletion(app cli.App, cfg CompletionSpec) (Completion, error) {
 	if len(cfg.Items) == 0 {
 		return nil, errNoCandidates
 	}
-	w := tk.NewComboBox(tk.ComboBoxSpec{
+	marks := &completionMarks{marked: make(map[string]bool)}
+	w := completion{
+		attached: codeArea, replace: cfg.Replace, items: cfg.Items, marks: marks}
+	w.ComboBox = tk.NewComboBox(tk.ComboBoxSpec{
 		CodeArea: tk.CodeAreaSpec{
 			Prompt:      modePrompt(" COMPLETING "+cfg.Name+" ", true),
 			Highlighter: cfg.Filter.Highlighter,
@@ -58,11 +81,7 @@ func NewCompletion(app cli.App, cfg CompletionSpec) (Completion, error) {
 			Horizontal: true,
 			Bindings:   cfg.Bindings,
 			OnSelect: func(it tk.Items, i int) {
-				text := it.(completionItems)[i].ToInsert
-				codeArea.MutateState(func(s *tk.CodeAreaState) {
-					s.Pending = tk.PendingCode{
-						From: cfg.Replace.From, To: cfg.Replace.To, Content: text}
-				})
+				w.updatePending(it.(completionItems).items[i].ToInsert)
 			},
 			OnAccept: func(it tk.Items, i int) {
 				codeArea.MutateState((*tk.CodeAreaState).ApplyPending)
@@ -71,30 +90,88 @@ func NewCompletion(app cli.App, cfg CompletionSpec) (Completion, error) {
 			ExtendStyle: true,
 		},
 		OnFilter: func(w tk.ComboBox, p string) {
-			w.ListBox().Reset(filterCompletionItems(cfg.Items, cfg.Filter.makePredicate(p)), 0)
+			w.ListBox().Reset(filterCompletionItems(cfg.Items, cfg.Filter.makePredicate(p), marks), 0)
 		},
 	})
-	return completion{w, codeArea}, nil
+	return w, nil
 }
 
 func (w completion) Dismiss() {
 	w.attached.MutateState(func(s *tk.CodeAreaState) { s.Pending = tk.PendingCode{} })
 }
 
-type completionItems []CompletionItem
+func (w completion) ToggleMark() {
+	s := w.ListBox().CopyState()
+	if s.Selected < 0 || s.Selected >= s.Items.Len() {
+		return
+	}
+	selected := s.Items.(completionItems).items[s.Selected].ToInsert
+	w.marks.mutex.Lock()
+	if w.marks.marked[selected] {
+		delete(w.marks.marked, selected)
+	} else {
+		w.marks.marked[selected] = true
+	}
+	w.marks.mutex.Unlock()
+	w.ListBox().Select(tk.Next)
+	// Selecting the next candidate doesn't update the pending code when
+	// the selection doesn't change.
+	s = w.ListBox().CopyState()
+	w.updatePending(s.Items.(completionItems).items[s.Selected].ToInsert)
+}
+
+// Updates the pending code to all the marked candidates, or the selected
+// candidate if there are no marked ones.
+func (w completion) updatePending(selected string) {
+	var marked []string
+	for _, item := range w.items {
+		if w.marks.has(item.ToInsert) {
+			marked = append(marked, item.ToInsert)
+		}
+	}
+	text := selected
+	if len(marked) > 0 {
+		var sb strings.Builder
+		for i, s := range marked {
+			if i > 0 && !strings.HasSuffix(marked[i-1], " ") {
+				sb.WriteString(" ")
+			}
+			sb.WriteString(s)
+		}
+		text = sb.String()
+	}
+	w.attached.MutateState(func(s *tk.CodeAreaState) {
+		s.Pending = tk.PendingCode{
+			From: w.replace.From, To: w.replace.To, Content: text}
+	})
+}
+
+type completionItems struct {
+	items []CompletionItem
+	marks *completionMarks
+}
 
-func filterCompletionItems(all []CompletionItem, p func(string) bool) completionItems {
+func filterCompletionItems(all []CompletionItem, p func(string) bool, marks *completionMarks) completionItems {
 	var filtered []CompletionItem
 	for _, candidate := range all {
 		if p(unstyle(candidate.ToShow)) {
 			filtered = append(filtered, candidate)
 		}
 	}
-	return filtered
+	return completionItems{filtered, marks}
 }
 
-func (it completionItems) Show(i int) ui.Text { return it[i].ToShow }
-func (it completionItems) Len() int           { return len(it) }
+func (it completionItems) Show(i int) ui.Text {
+	if it.marks.has(it.items[i].ToInsert) {
+		return ui.StyleText(it.items[i].ToShow, markedStyling)
+	}
+	return it.items[i].ToShow
+}
+
+func (it completionItems) Len() int { return len(it.items) }
+
+// Styling applied to marked items in the completion and navigation modes.
+var markedStyling = ui.Underlined
 
 func unstyle(t ui.Text) string {
 	var sb strings.Builder
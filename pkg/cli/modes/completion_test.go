@@ -33,6 +33,49 @@ func TestCompletion_Accept(t *testing.T) {
 	f.TestTTY(t, "foo", term.DotHere)
 }
 
+func TestCompletion_ToggleMark(t *testing.T) {
+	f := setupStartedCompletion(t)
+	defer f.Stop()
+	w := f.App.ActiveWidget().(Completion)
+	styles := ui.RuneStylesheet{
+		'_': ui.Underlined,
+		'*': ui.Stylings(ui.Bold, ui.FgWhite, ui.BgMagenta),
+		'#': ui.Stylings(ui.Inverse, ui.FgBlue),
+		'U': ui.Stylings(ui.Inverse, ui.FgBlue, ui.Underlined),
+	}
+
+	// Marking a candidate moves the selection, but only the marked candidate
+	// is inserted.
+	w.ToggleMark()
+	f.App.Redraw()
+	f.TestTTY(t,
+		"foo\n", styles,
+		"___",
+		" COMPLETING WORD  ", styles,
+		"***************** ", term.DotHere, "\n",
+		"foo  foo bar", styles,
+		"___  #######",
+	)
+
+	// All marked candidates are inserted, separated by spaces.
+	w.ToggleMark()
+	f.App.Redraw()
+	f.TestTTY(t,
+		"foo 'foo bar'\n", styles,
+		"_____________",
+		" COMPLETING WORD  ", styles,
+		"***************** ", term.DotHere, "\n",
+		"foo  foo bar", styles,
+		"___  UUUUUUU",
+	)
+
+	// Toggling again unmarks.
+	w.ToggleMark()
+	f.App.Redraw()
+	f.TTY.Inject(term.K(ui.Enter))
+	f.TestTTY(t, "foo", term.DotHere)
+}
+
 func TestCompletion_Dismiss(t *testing.T) {
 	f := setupStartedCompletion(t)
 	defer f.Stop()
@@ -2,9 +2,13 @@ package modes
 
 import (
 	"fmt"
+	"strconv"
+	"sync"
+	"time"
 
 	"src.elv.sh/pkg/cli"
 	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/fsutil"
 	"src.elv.sh/pkg/store/storedefs"
 	"src.elv.sh/pkg/ui"
 )
@@ -24,6 +28,23 @@ type HistlistSpec struct {
 	// Dedup is called to determine whether deduplication should be done.
 	// Defaults to true if unset.
 	Dedup func() bool
+	// CmdRecords is called to retrieve the time, working directory and result
+	// of the commands, the first time they are shown. If nil, they are never
+	// shown.
+	CmdRecords func() ([]storedefs.CmdRecord, error)
+	// Details is called to determine whether the time, working directory and
+	// result of the commands should be shown. Defaults to false if unset.
+	Details func() bool
+	// Workspace is called to retrieve the root of the workspace containing
+	// the working directory, or "" if it is not in any workspace.
+	Workspace func() string
+	// WorkspaceCmds is called to retrieve the commands run in a workspace, the
+	// first time only those commands are shown. If nil, commands are never
+	// filtered by workspace.
+	WorkspaceCmds func(root string) ([]storedefs.Cmd, error)
+	// InWorkspace is called to determine whether only the commands run in the
+	// current workspace should be shown. Defaults to false if unset.
+	InWorkspace func() bool
 	// Configuration for the filter.
 	Filter FilterSpec
 	// RPrompt of the code area (first row of the widget).
@@ -42,16 +63,32 @@ func NewHistlist(app cli.App, spec HistlistSpec) (Histlist, error) {
 	if spec.Dedup == nil {
 		spec.Dedup = func() bool { return true }
 	}
+	if spec.Details == nil {
+		spec.Details = func() bool { return false }
+	}
+	if spec.InWorkspace == nil {
+		spec.InWorkspace = func() bool { return false }
+	}
 
 	cmds, err := spec.AllCmds()
 	if err != nil {
 		return nil, fmt.Errorf("db error: %v", err.Error())
 	}
-	last := map[string]int{}
-	for i, cmd := range cmds {
-		last[cmd.Text] = i
+	var details *histlistDetails
+	if spec.CmdRecords != nil {
+		details = &histlistDetails{load: spec.CmdRecords}
 	}
-	cmdItems := histlistItems{cmds, last}
+	var workspace *histlistWorkspace
+	if spec.Workspace != nil && spec.WorkspaceCmds != nil {
+		if root := spec.Workspace(); root != "" {
+			workspace = &histlistWorkspace{
+				load: func() ([]storedefs.Cmd, error) { return spec.WorkspaceCmds(root) },
+				all:  cmds}
+		}
+	}
+	cmdItems := histlistItems{entries: cmds, last: lastIndices(cmds),
+		details: details, workspace: workspace}
+	inWorkspace := func() bool { return workspace != nil && spec.InWorkspace() }
 
 	w := tk.NewComboBox(tk.ComboBoxSpec{
 		CodeArea: tk.CodeAreaSpec{
@@ -60,6 +97,9 @@ func NewHistlist(app cli.App, spec HistlistSpec) (Histlist, error) {
 				if spec.Dedup() {
 					content += "(dedup on) "
 				}
+				if inWorkspace() {
+					content += "(workspace on) "
+				}
 				return modeLine(content, true)
 			},
 			RPrompt:     spec.CodeAreaRPrompt,
@@ -81,7 +121,11 @@ func NewHistlist(app cli.App, spec HistlistSpec) (Histlist, error) {
 			},
 		},
 		OnFilter: func(w tk.ComboBox, p string) {
-			it := cmdItems.filter(spec.Filter.makePredicate(p), spec.Dedup())
+			items := cmdItems
+			if inWorkspace() {
+				items.entries, items.last = workspace.get()
+			}
+			it := items.filter(spec.Filter.makePredicate(p), spec.Dedup(), spec.Details())
 			w.ListBox().Reset(it, it.Len()-1)
 		},
 	})
@@ -91,9 +135,74 @@ func NewHistlist(app cli.App, spec HistlistSpec) (Histlist, error) {
 type histlistItems struct {
 	entries []storedefs.Cmd
 	last    map[string]int
+	// Nil if details are not available.
+	details     *histlistDetails
+	showDetails bool
+	// Nil if the working directory is not in a workspace, or commands can't
+	// be filtered by workspace.
+	workspace *histlistWorkspace
+}
+
+// Maps the text of each command to the index of its last occurrence.
+func lastIndices(cmds []storedefs.Cmd) map[string]int {
+	last := map[string]int{}
+	for i, cmd := range cmds {
+		last[cmd.Text] = i
+	}
+	return last
+}
+
+// Details of the commands, loaded when they are first shown.
+type histlistDetails struct {
+	load    func() ([]storedefs.CmdRecord, error)
+	once    sync.Once
+	records map[int]storedefs.CmdRecord
+}
+
+func (d *histlistDetails) get(seq int) (storedefs.CmdRecord, bool) {
+	d.once.Do(func() {
+		// Commands without details are shown with empty details, which is
+		// also what happens when the details can't be loaded.
+		records, _ := d.load()
+		d.records = make(map[int]storedefs.CmdRecord, len(records))
+		for _, r := range records {
+			d.records[r.Seq] = r
+		}
+	})
+	r, ok := d.records[seq]
+	return r, ok
+}
+
+// Commands run in the current workspace, loaded when they are first shown.
+type histlistWorkspace struct {
+	load    func() ([]storedefs.Cmd, error)
+	all     []storedefs.Cmd
+	once    sync.Once
+	entries []storedefs.Cmd
+	last    map[string]int
+}
+
+// Returns the commands among all the commands that were run in the workspace,
+// and their last indices, which are used for deduplication.
+func (w *histlistWorkspace) get() ([]storedefs.Cmd, map[string]int) {
+	w.once.Do(func() {
+		// No commands are shown if they can't be loaded.
+		cmds, _ := w.load()
+		seqs := make(map[int]bool, len(cmds))
+		for _, cmd := range cmds {
+			seqs[cmd.Seq] = true
+		}
+		for _, cmd := range w.all {
+			if seqs[cmd.Seq] {
+				w.entries = append(w.entries, cmd)
+			}
+		}
+		w.last = lastIndices(w.entries)
+	})
+	return w.entries, w.last
 }
 
-func (it histlistItems) filter(p func(string) bool, dedup bool) histlistItems {
+func (it histlistItems) filter(p func(string) bool, dedup, details bool) histlistItems {
 	var filtered []storedefs.Cmd
 	for i, entry := range it.entries {
 		text := entry.Text
@@ -104,13 +213,48 @@ func (it histlistItems) filter(p func(string) bool, dedup bool) histlistItems {
 			filtered = append(filtered, entry)
 		}
 	}
-	return histlistItems{filtered, nil}
+	return histlistItems{entries: filtered, details: it.details, showDetails: details}
 }
 
 func (it histlistItems) Show(i int) ui.Text {
 	entry := it.entries[i]
+	if it.details != nil && it.showDetails {
+		r, _ := it.details.get(entry.Seq)
+		s := fmt.Sprintf("%4d %s %s", entry.Seq, formatDetails(r), entry.Text)
+		if r.Dir != "" {
+			s += "  (in " + fsutil.TildeAbbr(r.Dir) + ")"
+		}
+		return ui.T(s)
+	}
 	// TODO: The alignment of the index works up to 10000 entries.
 	return ui.T(fmt.Sprintf("%4d %s", entry.Seq, entry.Text))
 }
 
+// Formats the time, exit status and duration of a command in columns of fixed
+// width, leaving the columns empty if they are unknown.
+func formatDetails(r storedefs.CmdRecord) string {
+	t, exit, duration := "", "", ""
+	if !r.Time.IsZero() {
+		t = r.Time.Format("2006-01-02 15:04")
+	}
+	if r.Result != nil {
+		exit = strconv.Itoa(r.Result.Exit)
+		duration = formatDuration(r.Result.Duration)
+	}
+	return fmt.Sprintf("%-16s %3s %6s", t, exit, duration)
+}
+
+// Formats a duration with a precision that depends on its magnitude, like
+// 12ms, 1.5s or 2m30s.
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	case d < time.Minute:
+		return d.Round(100 * time.Millisecond).String()
+	default:
+		return d.Round(time.Second).String()
+	}
+}
+
 func (it histlistItems) Len() int { return len(it.entries) }
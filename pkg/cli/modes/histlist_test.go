@@ -3,12 +3,14 @@ package modes
 import (
 	"regexp"
 	"testing"
+	"time"
 
 	"src.elv.sh/pkg/cli"
 	. "src.elv.sh/pkg/cli/clitest"
 	"src.elv.sh/pkg/cli/histutil"
 	"src.elv.sh/pkg/cli/term"
 	"src.elv.sh/pkg/store/storedefs"
+	"src.elv.sh/pkg/tt"
 	"src.elv.sh/pkg/ui"
 )
 
@@ -120,6 +122,113 @@ func TestHistlist_Dedup(t *testing.T) {
 		"++++++++++++++++++++++++++++++++++++++++++++++++++")
 }
 
+func TestHistlist_Details(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	st := histutil.NewMemStore(
+		// 0    1
+		"foo", "bar")
+	records := []storedefs.CmdRecord{{
+		Text: "foo", Seq: 0, Time: time.Date(2023, 11, 14, 22, 13, 0, 0, time.UTC),
+		Dir: "/tmp", Result: &storedefs.CmdResult{Duration: 1500 * time.Millisecond, Exit: 1}}}
+	details := true
+	startHistlist(f.App, HistlistSpec{
+		AllCmds:    st.AllCmds,
+		CmdRecords: func() ([]storedefs.CmdRecord, error) { return records, nil },
+		Details:    func() bool { return details },
+	})
+	f.TestTTY(t,
+		"\n",
+		" HISTORY (dedup on)  ", Styles,
+		"******************** ", term.DotHere, "\n",
+		"   0 2023-11-14 22:13   1   1.5s foo  (in /tmp)\n",
+		"   1                             bar              ", Styles,
+		"++++++++++++++++++++++++++++++++++++++++++++++++++")
+
+	details = false
+	f.TTY.Inject(term.K('b'), term.K(ui.Backspace))
+	f.TestTTY(t,
+		"\n",
+		" HISTORY (dedup on)  ", Styles,
+		"******************** ", term.DotHere, "\n",
+		"   0 foo\n",
+		"   1 bar                                          ", Styles,
+		"++++++++++++++++++++++++++++++++++++++++++++++++++")
+}
+
+func TestHistlist_Workspace(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	st := histutil.NewMemStore(
+		// 0   1       2     3
+		"ls", "echo", "ls", "make")
+	inWorkspace := true
+	startHistlist(f.App, HistlistSpec{
+		AllCmds:   st.AllCmds,
+		Workspace: func() string { return "/ws" },
+		WorkspaceCmds: func(root string) ([]storedefs.Cmd, error) {
+			if root != "/ws" {
+				return nil, errMock
+			}
+			return []storedefs.Cmd{{Text: "ls", Seq: 0}, {Text: "echo", Seq: 1}, {Text: "make", Seq: 3}}, nil
+		},
+		InWorkspace: func() bool { return inWorkspace },
+	})
+	// Deduplication only considers commands in the workspace.
+	f.TestTTY(t,
+		"\n",
+		" HISTORY (dedup on) (workspace on)  ", Styles,
+		"*********************************** ", term.DotHere, "\n",
+		"   0 ls\n",
+		"   1 echo\n",
+		"   3 make                                         ", Styles,
+		"++++++++++++++++++++++++++++++++++++++++++++++++++")
+
+	inWorkspace = false
+	f.TTY.Inject(term.K('e'), term.K(ui.Backspace))
+	f.TestTTY(t,
+		"\n",
+		" HISTORY (dedup on)  ", Styles,
+		"******************** ", term.DotHere, "\n",
+		"   1 echo\n",
+		"   2 ls\n",
+		"   3 make                                         ", Styles,
+		"++++++++++++++++++++++++++++++++++++++++++++++++++")
+}
+
+func TestHistlist_NotInWorkspace(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	st := histutil.NewMemStore("ls", "echo")
+	startHistlist(f.App, HistlistSpec{
+		AllCmds:   st.AllCmds,
+		Workspace: func() string { return "" },
+		WorkspaceCmds: func(root string) ([]storedefs.Cmd, error) {
+			return nil, errMock
+		},
+		InWorkspace: func() bool { return true },
+	})
+	// All commands are shown when not in a workspace.
+	f.TestTTY(t,
+		"\n",
+		" HISTORY (dedup on)  ", Styles,
+		"******************** ", term.DotHere, "\n",
+		"   0 ls\n",
+		"   1 echo                                         ", Styles,
+		"++++++++++++++++++++++++++++++++++++++++++++++++++")
+}
+
+func TestFormatDuration(t *testing.T) {
+	tt.Test(t, formatDuration,
+		Args(12345*time.Microsecond).Rets("12ms"),
+		Args(1520*time.Millisecond).Rets("1.5s"),
+		Args(150500*time.Millisecond).Rets("2m31s"),
+	)
+}
+
 func TestHistlist_CustomFilter(t *testing.T) {
 	f := Setup()
 	defer f.Stop()
@@ -0,0 +1,206 @@
+package modes
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"src.elv.sh/pkg/cli"
+	"src.elv.sh/pkg/cli/histutil"
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/store/storedefs"
+	"src.elv.sh/pkg/ui"
+)
+
+// Histsearch is a mode for searching history incrementally. The query is typed
+// into the mode's own code area, and the newest command matching the query is
+// inserted as pending code at the dot of the code area the mode was started
+// from.
+type Histsearch interface {
+	tk.Widget
+	// Older moves to the next older command matching the query.
+	Older() error
+	// Newer moves to the next newer command matching the query.
+	Newer() error
+	// Accept inserts the current match into the code area and closes the
+	// mode.
+	Accept()
+}
+
+// HistsearchSpec specifies the configuration for the histsearch mode.
+type HistsearchSpec struct {
+	// Key bindings.
+	Bindings tk.Bindings
+	// History store to search.
+	Store histutil.Store
+}
+
+type histsearch struct {
+	app        cli.App
+	attachedTo tk.CodeArea
+	query      tk.CodeArea
+	bindings   tk.Bindings
+	// All commands, oldest first.
+	cmds []storedefs.Cmd
+	// The query last used to compute matches.
+	lastQuery string
+	// Indices into cmds of deduplicated commands matching the query, newest
+	// first.
+	matches []int
+	// Index into matches of the current match.
+	current int
+}
+
+var errNoMatchingCommand = errors.New("no matching command")
+
+// NewHistsearch creates a new Histsearch mode.
+func NewHistsearch(app cli.App, spec HistsearchSpec) (Histsearch, error) {
+	codeArea, err := FocusedCodeArea(app)
+	if err != nil {
+		return nil, err
+	}
+	if spec.Store == nil {
+		return nil, errNoHistoryStore
+	}
+	if spec.Bindings == nil {
+		spec.Bindings = tk.DummyBindings{}
+	}
+	cmds, err := spec.Store.AllCmds()
+	if err != nil {
+		return nil, fmt.Errorf("db error: %v", err.Error())
+	}
+	w := &histsearch{
+		app: app, attachedTo: codeArea, bindings: spec.Bindings, cmds: cmds,
+		query: tk.NewCodeArea(tk.CodeAreaSpec{
+			Prompt: modePrompt(" HISTORY SEARCH ", true),
+		}),
+	}
+	w.update(true)
+	return w, nil
+}
+
+func (w *histsearch) Render(width, height int) *term.Buffer {
+	buf := w.render(width)
+	buf.TrimToLines(0, height)
+	return buf
+}
+
+func (w *histsearch) MaxHeight(width, height int) int {
+	return len(w.render(width).Lines)
+}
+
+func (w *histsearch) render(width int) *term.Buffer {
+	buf := w.query.Render(width, 1)
+	var line ui.Text
+	if cmd, ok := w.currentCmd(); ok {
+		line = highlightMatch(cmd.Text, w.lastQuery)
+	} else if w.lastQuery != "" {
+		line = ui.T(errNoMatchingCommand.Error(), ui.FgRed)
+	}
+	if line != nil {
+		buf.ExtendDown(term.NewBufferBuilder(width).WriteStyled(line).Buffer(), false)
+	}
+	return buf
+}
+
+func (w *histsearch) Handle(event term.Event) bool {
+	handled := w.bindings.Handle(w, event)
+	if !handled {
+		handled = w.query.Handle(event)
+	}
+	w.update(false)
+	return handled
+}
+
+func (w *histsearch) Focus() bool { return true }
+
+func (w *histsearch) Older() error {
+	if w.current+1 >= len(w.matches) {
+		return errNoMatchingCommand
+	}
+	w.current++
+	w.updatePending()
+	return nil
+}
+
+func (w *histsearch) Newer() error {
+	if w.current == 0 {
+		return errNoMatchingCommand
+	}
+	w.current--
+	w.updatePending()
+	return nil
+}
+
+func (w *histsearch) Accept() {
+	w.attachedTo.MutateState((*tk.CodeAreaState).ApplyPending)
+	w.app.PopAddon()
+}
+
+func (w *histsearch) Dismiss() {
+	w.attachedTo.MutateState(func(s *tk.CodeAreaState) { s.Pending = tk.PendingCode{} })
+}
+
+func (w *histsearch) update(force bool) {
+	query := w.query.CopyState().Buffer.Content
+	if query == w.lastQuery && !force {
+		return
+	}
+	w.lastQuery = query
+	w.matches = w.matches[:0]
+	w.current = 0
+	seen := make(map[string]bool)
+	for i := len(w.cmds) - 1; i >= 0; i-- {
+		text := w.cmds[i].Text
+		if seen[text] {
+			continue
+		}
+		seen[text] = true
+		if query != "" && matchIndex(text, query) != -1 {
+			w.matches = append(w.matches, i)
+		}
+	}
+	w.updatePending()
+}
+
+func (w *histsearch) currentCmd() (storedefs.Cmd, bool) {
+	if w.current < len(w.matches) {
+		return w.cmds[w.matches[w.current]], true
+	}
+	return storedefs.Cmd{}, false
+}
+
+func (w *histsearch) updatePending() {
+	cmd, _ := w.currentCmd()
+	w.attachedTo.MutateState(func(s *tk.CodeAreaState) {
+		dot := s.Buffer.Dot
+		s.Pending = tk.PendingCode{From: dot, To: dot, Content: cmd.Text}
+	})
+}
+
+// Returns the byte index of the first occurrence of query in text, or -1 if
+// there is none. Like the default filter of listing modes, the match is
+// case-insensitive unless the query contains uppercase letters.
+func matchIndex(text, query string) int {
+	if strings.IndexFunc(query, unicode.IsUpper) == -1 {
+		text = strings.ToLower(text)
+		query = strings.ToLower(query)
+	}
+	return strings.Index(text, query)
+}
+
+// Returns text with the first occurrence of query highlighted.
+func highlightMatch(text, query string) ui.Text {
+	i := matchIndex(text, query)
+	if i == -1 || len(text) != len(strings.ToLower(text)) {
+		// The lowercase version of text may have a different length, in which
+		// case the index can't be used on the original text.
+		return ui.T(text)
+	}
+	return ui.Concat(
+		ui.T(text[:i]),
+		ui.T(text[i:i+len(query)], ui.Inverse),
+		ui.T(text[i+len(query):]))
+}
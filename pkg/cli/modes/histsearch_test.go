@@ -0,0 +1,130 @@
+package modes
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/cli"
+	. "src.elv.sh/pkg/cli/clitest"
+	"src.elv.sh/pkg/cli/histutil"
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/ui"
+)
+
+func TestHistsearch(t *testing.T) {
+	f := Setup(WithSpec(func(spec *cli.AppSpec) {
+		spec.CodeAreaState.Buffer = tk.CodeBuffer{Content: "a; b", Dot: 3}
+	}))
+	defer f.Stop()
+
+	store := histutil.NewMemStore(
+		// 0          1           2          3
+		"echo foo", "ls -l", "echo FOObar", "echo foo")
+	w := startHistsearch(f.App, HistsearchSpec{
+		Store: store,
+		Bindings: tk.MapBindings{
+			term.K('R', ui.Ctrl): func(w tk.Widget) { w.(Histsearch).Older() },
+			term.K('S', ui.Ctrl): func(w tk.Widget) { w.(Histsearch).Newer() },
+			term.K('\n'):         func(w tk.Widget) { w.(Histsearch).Accept() },
+		},
+	})
+	f.TestTTY(t,
+		"a; b", "\n",
+		" HISTORY SEARCH  ", Styles,
+		"**************** ", term.DotHere,
+	)
+
+	// Typing narrows the search; the newest match is inserted as pending
+	// code at the dot.
+	f.TTY.Inject(term.K('f'), term.K('o'))
+	bufFoo := f.MakeBuffer(
+		"a; echo foob", Styles,
+		"   ________ ", "\n",
+		" HISTORY SEARCH  fo", Styles,
+		"****************   ", term.DotHere, "\n",
+		"echo foo", Styles,
+		"     ++ ",
+	)
+	f.TTY.TestBuffer(t, bufFoo)
+
+	// Cycle to older matches, skipping duplicates. The match is
+	// case-insensitive since the query has no uppercase letters.
+	f.TTY.Inject(term.K('R', ui.Ctrl))
+	f.TestTTY(t,
+		"a; echo FOObarb", Styles,
+		"   ___________ ", "\n",
+		" HISTORY SEARCH  fo", Styles,
+		"****************   ", term.DotHere, "\n",
+		"echo FOObar", Styles,
+		"     ++    ",
+	)
+	if err := w.Older(); err != errNoMatchingCommand {
+		t.Errorf("Older() at oldest match -> %v, want %v", err, errNoMatchingCommand)
+	}
+	f.TTY.Inject(term.K('S', ui.Ctrl))
+	f.TTY.TestBuffer(t, bufFoo)
+	if err := w.Newer(); err != errNoMatchingCommand {
+		t.Errorf("Newer() at newest match -> %v, want %v", err, errNoMatchingCommand)
+	}
+
+	// Query with no match.
+	f.TTY.Inject(term.K('x'))
+	f.TestTTY(t,
+		"a; b", "\n",
+		" HISTORY SEARCH  fox", Styles,
+		"****************    ", term.DotHere, "\n",
+		"no matching command", Styles,
+		"!!!!!!!!!!!!!!!!!!!",
+	)
+
+	// Accept.
+	f.TTY.Inject(term.K(ui.Backspace), term.K('\n'))
+	f.TestTTY(t, "a; echo foo", term.DotHere, "b")
+}
+
+func TestHistsearch_Dismiss(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	store := histutil.NewMemStore("echo foo")
+	startHistsearch(f.App, HistsearchSpec{Store: store})
+	f.TTY.Inject(term.K('f'))
+	f.TestTTY(t,
+		"echo foo", Styles,
+		"________", "\n",
+		" HISTORY SEARCH  f", Styles,
+		"****************  ", term.DotHere, "\n",
+		"echo foo", Styles,
+		"     +  ",
+	)
+
+	f.App.PopAddon()
+	f.App.Redraw()
+	f.TestTTY(t /* nothing */, term.DotHere)
+}
+
+func TestHistsearch_FocusedWidgetNotCodeArea(t *testing.T) {
+	testFocusedWidgetNotCodeArea(t, func(app cli.App) error {
+		_, err := NewHistsearch(app, HistsearchSpec{Store: histutil.NewMemStore()})
+		return err
+	})
+}
+
+func TestHistsearch_NoStore(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	startHistsearch(f.App, HistsearchSpec{})
+	f.TTY.TestMsg(t, ui.Concat(ui.T("error:", ui.FgRed), ui.T(" no history store")))
+}
+
+func startHistsearch(app cli.App, spec HistsearchSpec) Histsearch {
+	w, err := NewHistsearch(app, spec)
+	if err != nil {
+		app.Notify(ErrorText(err))
+		return nil
+	}
+	app.PushAddon(w)
+	app.Redraw()
+	return w
+}
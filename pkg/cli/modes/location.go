@@ -4,9 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"unicode"
 
 	"src.elv.sh/pkg/cli"
 	"src.elv.sh/pkg/cli/tk"
@@ -19,6 +21,14 @@ import (
 // directory. It is based on the ComboBox widget.
 type Location interface {
 	tk.ComboBox
+	// Remove removes the selected directory from the directory history.
+	Remove() error
+	// Descend replaces the list with the selected directory and its
+	// subdirectories, and clears the filter.
+	Descend() error
+	// TogglePin pins the selected directory if it is not pinned, and unpins it
+	// otherwise. The list is then reloaded.
+	TogglePin() error
 }
 
 // LocationSpec is the configuration to start the location history feature.
@@ -30,36 +40,111 @@ type LocationSpec struct {
 	// IteratePinned specifies pinned directories by calling the given function
 	// with all pinned directories.
 	IteratePinned func(func(string))
+	// SetPinned is called by TogglePin to pin or unpin a directory, which
+	// should be reflected by IteratePinned afterwards. If nil, TogglePin
+	// returns an error.
+	SetPinned func(dir string, pinned bool) error
 	// IterateHidden specifies hidden directories by calling the given function
 	// with all hidden directories.
 	IterateHidden func(func(string))
 	// IterateWorksapce specifies workspace configuration.
 	IterateWorkspaces LocationWSIterator
-	// Configuration for the filter.
+	// Configuration for the filter. If the Maker is nil, the filter is matched
+	// fuzzily against the path components; see NewLocation.
 	Filter FilterSpec
 }
 
 // LocationStore defines the interface for interacting with the directory history.
 type LocationStore interface {
 	Dirs(blacklist map[string]struct{}) ([]storedefs.Dir, error)
+	DelDir(dir string) error
 	Chdir(dir string) error
 	Getwd() (string, error)
 }
 
-// A special score for pinned directories.
-var pinnedScore = math.Inf(1)
+// Special scores for pinned directories and subdirectories shown after
+// descending into a directory.
+var (
+	pinnedScore = math.Inf(1)
+	subdirScore = math.Inf(-1)
+)
 
-var errNoDirectoryHistoryStore = errors.New("no directory history store")
+var (
+	errNoDirectoryHistoryStore = errors.New("no directory history store")
+	errNoSelectedDirectory     = errors.New("no selected directory")
+	errCannotRemovePinned      = errors.New("can't remove pinned directory")
+	errCannotPin               = errors.New("can't pin directories")
+)
+
+type location struct {
+	tk.ComboBox
+	app   cli.App
+	cfg   LocationSpec
+	store LocationStore
+	l     *locationList
+	// Resolves a path in the list, which may be relative to a workspace, to a
+	// path that can be used to change directory.
+	resolve func(string) string
+}
 
 // NewLocation creates a new location mode.
+//
+// Unless the filter has a custom Maker, the filter is split into segments at
+// whitespaces and path separators, and a directory matches if each segment
+// fuzzily matches (as a subsequence) a different path component, in order.
+// The match is case-insensitive unless the filter contains uppercase letters.
+// Path components that are not matched, except the first and last ones, are
+// shortened when shown; matched characters are highlighted.
 func NewLocation(app cli.App, cfg LocationSpec) (Location, error) {
 	if cfg.Store == nil {
 		return nil, errNoDirectoryHistoryStore
 	}
+	dirs, wsKind, wsRoot, err := loadLocationDirs(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &locationList{dirs: dirs}
+	w := &location{
+		app: app, cfg: cfg, store: cfg.Store, l: l,
+		resolve: func(path string) string {
+			if wsKind != "" && hasPathPrefix(path, wsKind) {
+				return wsRoot + path[len(wsKind):]
+			}
+			return path
+		},
+	}
+	w.ComboBox = tk.NewComboBox(tk.ComboBoxSpec{
+		CodeArea: tk.CodeAreaSpec{
+			Prompt:      modePrompt(" LOCATION ", true),
+			Highlighter: cfg.Filter.Highlighter,
+		},
+		ListBox: tk.ListBoxSpec{
+			Bindings: cfg.Bindings,
+			OnAccept: func(it tk.Items, i int) {
+				err := cfg.Store.Chdir(w.resolve(it.(locationList).dirs[i].Path))
+				if err != nil {
+					app.Notify(ErrorText(err))
+				}
+				app.PopAddon()
+			},
+		},
+		OnFilter: func(w tk.ComboBox, p string) {
+			if cfg.Filter.Maker == nil {
+				w.ListBox().Reset(l.fuzzyFilter(p), 0)
+			} else {
+				w.ListBox().Reset(l.filter(cfg.Filter.makePredicate(p)), 0)
+			}
+		},
+	})
+	return w, nil
+}
 
-	dirs := []storedefs.Dir{}
+// Returns the pinned directories followed by those in the directory history,
+// along with the kind and root of the workspace the working directory is in.
+func loadLocationDirs(cfg LocationSpec) (dirs []storedefs.Dir, wsKind, wsRoot string, err error) {
+	dirs = []storedefs.Dir{}
 	blacklist := map[string]struct{}{}
-	wsKind, wsRoot := "", ""
 
 	if cfg.IteratePinned != nil {
 		cfg.IteratePinned(func(s string) {
@@ -79,7 +164,7 @@ func NewLocation(app cli.App, cfg LocationSpec) (Location, error) {
 	}
 	storedDirs, err := cfg.Store.Dirs(blacklist)
 	if err != nil {
-		return nil, fmt.Errorf("db error: %v", err)
+		return nil, "", "", fmt.Errorf("db error: %v", err)
 	}
 	for _, dir := range storedDirs {
 		if filepath.IsAbs(dir.Path) {
@@ -88,33 +173,95 @@ func NewLocation(app cli.App, cfg LocationSpec) (Location, error) {
 			dirs = append(dirs, dir)
 		}
 	}
+	return dirs, wsKind, wsRoot, nil
+}
 
-	l := locationList{dirs}
+func (w *location) selected() (storedefs.Dir, bool) {
+	s := w.ListBox().CopyState()
+	if s.Items == nil || s.Selected < 0 || s.Selected >= s.Items.Len() {
+		return storedefs.Dir{}, false
+	}
+	return s.Items.(locationList).dirs[s.Selected], true
+}
 
-	w := tk.NewComboBox(tk.ComboBoxSpec{
-		CodeArea: tk.CodeAreaSpec{
-			Prompt:      modePrompt(" LOCATION ", true),
-			Highlighter: cfg.Filter.Highlighter,
-		},
-		ListBox: tk.ListBoxSpec{
-			Bindings: cfg.Bindings,
-			OnAccept: func(it tk.Items, i int) {
-				path := it.(locationList).dirs[i].Path
-				if strings.HasPrefix(path, wsKind) {
-					path = wsRoot + path[len(wsKind):]
-				}
-				err := cfg.Store.Chdir(path)
-				if err != nil {
-					app.Notify(ErrorText(err))
-				}
-				app.PopAddon()
-			},
-		},
-		OnFilter: func(w tk.ComboBox, p string) {
-			w.ListBox().Reset(l.filter(cfg.Filter.makePredicate(p)), 0)
-		},
+func (w *location) Remove() error {
+	dir, ok := w.selected()
+	if !ok {
+		return errNoSelectedDirectory
+	}
+	if dir.Score == pinnedScore {
+		return errCannotRemovePinned
+	}
+	if err := w.store.DelDir(dir.Path); err != nil {
+		return fmt.Errorf("db error: %v", err)
+	}
+	for i, d := range w.l.dirs {
+		if d.Path == dir.Path {
+			w.l.dirs = append(w.l.dirs[:i:i], w.l.dirs[i+1:]...)
+			break
+		}
+	}
+	selected := w.ListBox().CopyState().Selected
+	w.Refilter()
+	w.ListBox().Select(func(s tk.ListBoxState) int {
+		return min(selected, s.Items.Len()-1)
 	})
-	return w, nil
+	return nil
+}
+
+func (w *location) Descend() error {
+	dir, ok := w.selected()
+	if !ok {
+		return errNoSelectedDirectory
+	}
+	path := w.resolve(dir.Path)
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	dirs := []storedefs.Dir{{Path: path, Score: dir.Score}}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(path, name)); err == nil && info.IsDir() {
+			dirs = append(dirs, storedefs.Dir{Path: filepath.Join(path, name), Score: subdirScore})
+		}
+	}
+	w.l.dirs = dirs
+	w.CodeArea().MutateState(func(s *tk.CodeAreaState) { *s = tk.CodeAreaState{} })
+	w.Refilter()
+	return nil
+}
+
+func (w *location) TogglePin() error {
+	if w.cfg.SetPinned == nil {
+		return errCannotPin
+	}
+	dir, ok := w.selected()
+	if !ok {
+		return errNoSelectedDirectory
+	}
+	path := w.resolve(dir.Path)
+	if err := w.cfg.SetPinned(path, dir.Score != pinnedScore); err != nil {
+		return fmt.Errorf("db error: %v", err)
+	}
+	dirs, _, _, err := loadLocationDirs(w.cfg)
+	if err != nil {
+		return err
+	}
+	w.l.dirs = dirs
+	w.Refilter()
+	w.ListBox().Select(func(s tk.ListBoxState) int {
+		for i, d := range s.Items.(locationList).dirs {
+			if d.Path == dir.Path || d.Path == path {
+				return i
+			}
+		}
+		return 0
+	})
+	return nil
 }
 
 func hasPathPrefix(path, prefix string) bool {
@@ -152,6 +299,8 @@ func (ws LocationWSIterator) Parse(path string) (kind, root string) {
 
 type locationList struct {
 	dirs []storedefs.Dir
+	// Segments of the fuzzy filter, used for highlighting.
+	segments []string
 }
 
 func (l locationList) filter(p func(string) bool) locationList {
@@ -161,19 +310,130 @@ func (l locationList) filter(p func(string) bool) locationList {
 			filteredDirs = append(filteredDirs, dir)
 		}
 	}
-	return locationList{filteredDirs}
+	return locationList{dirs: filteredDirs}
+}
+
+func (l locationList) fuzzyFilter(p string) locationList {
+	segments := strings.FieldsFunc(p, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '/' || r == filepath.Separator
+	})
+	if strings.IndexFunc(p, unicode.IsUpper) == -1 {
+		for i, segment := range segments {
+			segments[i] = strings.ToLower(segment)
+		}
+	}
+	var filteredDirs []storedefs.Dir
+	for _, dir := range l.dirs {
+		if matchPath(fsutil.TildeAbbr(dir.Path), segments) != nil {
+			filteredDirs = append(filteredDirs, dir)
+		}
+	}
+	return locationList{filteredDirs, segments}
 }
 
 func (l locationList) Show(i int) ui.Text {
-	return ui.T(fmt.Sprintf("%s %s",
-		showScore(l.dirs[i].Score), fsutil.TildeAbbr(l.dirs[i].Path)))
+	path := fsutil.TildeAbbr(l.dirs[i].Path)
+	return ui.Concat(
+		ui.T(showScore(l.dirs[i].Score)+" "),
+		showPath(path, matchPath(path, l.segments)))
 }
 
 func (l locationList) Len() int { return len(l.dirs) }
 
 func showScore(f float64) string {
-	if f == pinnedScore {
+	switch f {
+	case pinnedScore:
 		return "  *"
+	case subdirScore:
+		return "   "
 	}
 	return fmt.Sprintf("%3.0f", f)
 }
+
+// Matches the segments against the components of path, and returns the
+// indices of the matched runes in each component, or nil if there is no
+// match. Each segment must fuzzily match a different component, in order.
+// Segments should already be lowercase if the match is to be
+// case-insensitive.
+func matchPath(path string, segments []string) [][]int {
+	components := strings.Split(path, string(filepath.Separator))
+	matched := make([][]int, len(components))
+	caseSensitive := false
+	for _, segment := range segments {
+		if strings.IndexFunc(segment, unicode.IsUpper) != -1 {
+			caseSensitive = true
+		}
+	}
+	j := 0
+	for _, segment := range segments {
+		for ; j < len(components); j++ {
+			component := components[j]
+			if !caseSensitive {
+				component = strings.ToLower(component)
+			}
+			if indices := matchSubsequence(component, segment); indices != nil {
+				matched[j] = indices
+				break
+			}
+		}
+		if j == len(components) {
+			return nil
+		}
+		j++
+	}
+	return matched
+}
+
+// Returns the rune indices of s that match the runes of sub in order, or nil
+// if sub is not a subsequence of s.
+func matchSubsequence(s, sub string) []int {
+	indices := []int{}
+	subRunes := []rune(sub)
+	for i, r := range []rune(s) {
+		if len(indices) == len(subRunes) {
+			break
+		}
+		if r == subRunes[len(indices)] {
+			indices = append(indices, i)
+		}
+	}
+	if len(indices) < len(subRunes) {
+		return nil
+	}
+	return indices
+}
+
+// Shows path, highlighting the matched runes. Components without matches,
+// except the first and last ones, are shortened to their first rune (or the
+// first two if it starts with a dot).
+func showPath(path string, matched [][]int) ui.Text {
+	components := strings.Split(path, string(filepath.Separator))
+	var tb ui.TextBuilder
+	for i, component := range components {
+		if i > 0 {
+			tb.WriteText(ui.T(string(filepath.Separator)))
+		}
+		runes := []rune(component)
+		if i < len(matched) && len(matched[i]) > 0 {
+			k := 0
+			for j, r := range runes {
+				if k < len(matched[i]) && matched[i][k] == j {
+					tb.WriteText(ui.T(string(r), ui.Underlined))
+					k++
+				} else {
+					tb.WriteText(ui.T(string(r)))
+				}
+			}
+			continue
+		}
+		if i > 0 && i < len(components)-1 {
+			n := 1
+			if len(runes) > 1 && runes[0] == '.' {
+				n = 2
+			}
+			runes = runes[:min(n, len(runes))]
+		}
+		tb.WriteText(ui.T(string(runes)))
+	}
+	return tb.Text()
+}
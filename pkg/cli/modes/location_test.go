@@ -4,7 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -12,6 +14,8 @@ import (
 	"src.elv.sh/pkg/cli"
 	. "src.elv.sh/pkg/cli/clitest"
 	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/fsutil"
 	"src.elv.sh/pkg/store/storedefs"
 	"src.elv.sh/pkg/testutil"
 	"src.elv.sh/pkg/ui"
@@ -20,6 +24,7 @@ import (
 type locationStore struct {
 	storedDirs []storedefs.Dir
 	dirsError  error
+	delDir     func(dir string) error
 	chdir      func(dir string) error
 	wd         string
 }
@@ -35,6 +40,13 @@ func (ts locationStore) Dirs(blacklist map[string]struct{}) ([]storedefs.Dir, er
 	return dirs, ts.dirsError
 }
 
+func (ts locationStore) DelDir(dir string) error {
+	if ts.delDir == nil {
+		return nil
+	}
+	return ts.delDir(dir)
+}
+
 func (ts locationStore) Chdir(dir string) error {
 	if ts.chdir == nil {
 		return nil
@@ -89,15 +101,17 @@ func TestLocation_FullWorkflow(t *testing.T) {
 		"",
 		"200 "+filepath.Join("~", "go"),
 		"100 ~",
-		" 50 "+fixPath("/tmp/foo/bar/lorem/ipsum"))
+		" 50 "+fixPath("/t/f/b/l/ipsum"))
 	f.TTY.TestBuffer(t, wantBuf)
 
 	// Test filtering.
 	f.TTY.Inject(term.K('f'), term.K('o'))
 
-	wantBuf = locationBuf(
+	wantBuf = locationBufStyled(
 		"fo",
-		" 50 "+fixPath("/tmp/foo/bar/lorem/ipsum"))
+		ui.Concat(
+			ui.T(" 50 "+fixPath("/t/")), ui.T("fo", ui.Underlined),
+			ui.T(fixPath("o/b/l/ipsum"))))
 	f.TTY.TestBuffer(t, wantBuf)
 
 	// Test accepting.
@@ -135,11 +149,157 @@ func TestLocation_Hidden(t *testing.T) {
 	// Test UI.
 	wantBuf := locationBuf(
 		"",
-		"200 "+fixPath("/usr/bin"),
+		"200 "+fixPath("/u/bin"),
 		" 50 "+fixPath("/tmp"))
 	f.TTY.TestBuffer(t, wantBuf)
 }
 
+func TestLocation_FuzzyFilter(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	dirs := []storedefs.Dir{
+		{Path: fixPath("/home/elf/src/elvish"), Score: 200},
+		{Path: fixPath("/home/elf/src/Go"), Score: 100},
+		{Path: fixPath("/usr/local/share/elvish"), Score: 50},
+	}
+	startLocation(f.App, LocationSpec{Store: locationStore{storedDirs: dirs}})
+
+	// Segments must match different components in order.
+	f.TTY.Inject(term.K('s'), term.K('r'), term.K('c'), term.K(' '), term.K('e'), term.K('v'))
+	f.TTY.TestBuffer(t, locationBufStyled(
+		"src ev",
+		ui.Concat(
+			ui.T("200 "+fixPath("/h/e/")), ui.T("src", ui.Underlined),
+			ui.T(string(filepath.Separator)), ui.T("e", ui.Underlined),
+			ui.T("l"), ui.T("v", ui.Underlined), ui.T("ish"))))
+
+	// Path separators also separate segments; uppercase letters make the match
+	// case-sensitive.
+	f.TTY.Inject(term.K(ui.Backspace), term.K(ui.Backspace), term.K(ui.Backspace),
+		term.K('/'), term.K('G'))
+	f.TTY.TestBuffer(t, locationBufStyled(
+		"src/G",
+		ui.Concat(
+			ui.T("100 "+fixPath("/h/e/")), ui.T("src", ui.Underlined),
+			ui.T(string(filepath.Separator)), ui.T("G", ui.Underlined),
+			ui.T("o"))))
+	// Lowercase segments still match uppercase letters.
+	f.TTY.Inject(term.K(ui.Backspace), term.K('g'))
+	f.TTY.TestBuffer(t, locationBufStyled(
+		"src/g",
+		ui.Concat(
+			ui.T("100 "+fixPath("/h/e/")), ui.T("src", ui.Underlined),
+			ui.T(string(filepath.Separator)), ui.T("G", ui.Underlined),
+			ui.T("o"))))
+}
+
+func TestLocation_Remove(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	var deleted []string
+	dirs := []storedefs.Dir{
+		{Path: fixPath("/usr/bin"), Score: 200},
+		{Path: fixPath("/tmp"), Score: 50},
+	}
+	w, _ := NewLocation(f.App, LocationSpec{
+		Store: locationStore{
+			storedDirs: dirs,
+			delDir: func(dir string) error {
+				deleted = append(deleted, dir)
+				return nil
+			},
+		},
+		IteratePinned: func(f func(string)) { f(fixPath("/opt")) },
+	})
+	f.App.PushAddon(w)
+
+	// Pinned directories can't be removed.
+	if err := w.Remove(); err != errCannotRemovePinned {
+		t.Errorf("Remove() on pinned directory -> %v, want %v", err, errCannotRemovePinned)
+	}
+	// Removing the last item selects the new last item.
+	w.ListBox().Select(func(tk.ListBoxState) int { return 2 })
+	if err := w.Remove(); err != nil {
+		t.Errorf("Remove() -> %v, want nil", err)
+	}
+	f.App.Redraw()
+	f.TTY.TestBuffer(t, term.NewBufferBuilder(50).
+		Newline().
+		WriteStyled(modeLine(" LOCATION ", true)).SetDotHere().
+		Newline().Write("  * "+fixPath("/opt")).
+		Newline().WriteStyled(ui.T(fmt.Sprintf("%-50s", "200 "+fixPath("/u/bin")), ui.Inverse)).
+		Buffer())
+	if want := []string{fixPath("/tmp")}; !reflect.DeepEqual(deleted, want) {
+		t.Errorf("DelDir called with %v, want %v", deleted, want)
+	}
+}
+
+func TestLocation_Remove_StoreError(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	w, _ := NewLocation(f.App, LocationSpec{Store: locationStore{
+		storedDirs: []storedefs.Dir{{Path: fixPath("/tmp"), Score: 50}},
+		delDir:     func(string) error { return errors.New("ERROR") },
+	}})
+	if err := w.Remove(); err == nil || err.Error() != "db error: ERROR" {
+		t.Errorf("Remove() -> %v, want db error", err)
+	}
+}
+
+func TestLocation_Descend(t *testing.T) {
+	dir := testutil.TempDir(t)
+	testutil.ApplyDirIn(testutil.Dir{
+		"a":      testutil.Dir{},
+		"b":      testutil.Dir{},
+		".git":   testutil.Dir{},
+		"file":   "",
+		"common": testutil.Dir{},
+	}, dir)
+	f := Setup()
+	defer f.Stop()
+
+	chdirCh := make(chan string, 100)
+	w, _ := NewLocation(f.App, LocationSpec{Store: locationStore{
+		storedDirs: []storedefs.Dir{{Path: dir, Score: 50}},
+		chdir:      func(dir string) error { chdirCh <- dir; return nil },
+	}})
+	f.App.PushAddon(w)
+	short := func(path string) string { return plainText(showPath(fsutil.TildeAbbr(path), nil)) }
+	f.TTY.Inject(term.K('x'))
+	f.TTY.TestBuffer(t, locationBuf("x"))
+	if err := w.Descend(); err != errNoSelectedDirectory {
+		t.Errorf("Descend() with no selection -> %v, want %v", err, errNoSelectedDirectory)
+	}
+
+	f.TTY.Inject(term.K(ui.Backspace))
+	f.TTY.TestBuffer(t, locationBuf("", " 50 "+short(dir)))
+	if err := w.Descend(); err != nil {
+		t.Errorf("Descend() -> %v, want nil", err)
+	}
+	f.App.Redraw()
+	// The filter is cleared, and the list contains the directory itself
+	// followed by its subdirectories, excluding hidden ones.
+	f.TTY.TestBuffer(t, locationBuf(
+		"",
+		" 50 "+short(dir),
+		"    "+short(filepath.Join(dir, "a")),
+		"    "+short(filepath.Join(dir, "b")),
+		"    "+short(filepath.Join(dir, "common"))))
+
+	f.TTY.Inject(term.K('c'), term.K('o'), term.K(ui.Enter))
+	select {
+	case got := <-chdirCh:
+		if want := filepath.Join(dir, "common"); got != want {
+			t.Errorf("Chdir called with %s, want %s", got, want)
+		}
+	case <-time.After(testutil.Scaled(time.Second)):
+		t.Errorf("Chdir not called")
+	}
+}
+
 func TestLocation_Pinned(t *testing.T) {
 	f := Setup()
 	defer f.Stop()
@@ -158,11 +318,69 @@ func TestLocation_Pinned(t *testing.T) {
 		"",
 		"  * "+fixPath("/home"),
 		"  * "+fixPath("/usr"),
-		"200 "+fixPath("/usr/bin"),
+		"200 "+fixPath("/u/bin"),
 		" 50 "+fixPath("/tmp"))
 	f.TTY.TestBuffer(t, wantBuf)
 }
 
+func TestLocation_TogglePin(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	dirs := []storedefs.Dir{
+		{Path: fixPath("/usr/bin"), Score: 200},
+		{Path: fixPath("/tmp"), Score: 50},
+	}
+	var pinned []string
+	w, _ := NewLocation(f.App, LocationSpec{
+		Store: locationStore{storedDirs: dirs},
+		IteratePinned: func(f func(string)) {
+			for _, dir := range pinned {
+				f(dir)
+			}
+		},
+		SetPinned: func(dir string, pin bool) error {
+			if pin {
+				pinned = append(pinned, dir)
+			} else {
+				pinned = slices.DeleteFunc(pinned, func(d string) bool { return d == dir })
+			}
+			return nil
+		},
+	})
+	f.App.PushAddon(w)
+
+	// Pinning moves the directory to the top, and keeps it selected.
+	w.ListBox().Select(func(tk.ListBoxState) int { return 1 })
+	if err := w.TogglePin(); err != nil {
+		t.Errorf("TogglePin() -> %v, want nil", err)
+	}
+	f.App.Redraw()
+	f.TTY.TestBuffer(t, locationBuf("", "  * "+fixPath("/tmp"), "200 "+fixPath("/u/bin")))
+
+	// Unpinning restores the score.
+	if err := w.TogglePin(); err != nil {
+		t.Errorf("TogglePin() -> %v, want nil", err)
+	}
+	if len(pinned) != 0 {
+		t.Errorf("got pinned directories %v after unpinning, want none", pinned)
+	}
+	if dir, _ := w.(*location).selected(); dir != dirs[1] {
+		t.Errorf("got selected directory %v, want %v", dir, dirs[1])
+	}
+}
+
+func TestLocation_TogglePin_NoSetPinned(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	w, _ := NewLocation(f.App, LocationSpec{Store: locationStore{
+		storedDirs: []storedefs.Dir{{Path: fixPath("/tmp"), Score: 50}}}})
+	if err := w.TogglePin(); err != errCannotPin {
+		t.Errorf("TogglePin() -> %v, want %v", err, errCannotPin)
+	}
+}
+
 func TestLocation_HideWd(t *testing.T) {
 	f := Setup()
 	defer f.Stop()
@@ -229,6 +447,14 @@ func TestLocation_Workspace(t *testing.T) {
 }
 
 func locationBuf(filter string, lines ...string) *term.Buffer {
+	texts := make([]ui.Text, len(lines))
+	for i, line := range lines {
+		texts[i] = ui.T(line)
+	}
+	return locationBufStyled(filter, texts...)
+}
+
+func locationBufStyled(filter string, lines ...ui.Text) *term.Buffer {
 	b := term.NewBufferBuilder(50).
 		Newline(). // empty code area
 		WriteStyled(modeLine(" LOCATION ", true)).
@@ -236,14 +462,23 @@ func locationBuf(filter string, lines ...string) *term.Buffer {
 	for i, line := range lines {
 		b.Newline()
 		if i == 0 {
-			b.WriteStyled(ui.T(fmt.Sprintf("%-50s", line), ui.Inverse))
+			padding := ui.T(strings.Repeat(" ", 50-len(plainText(line))))
+			b.WriteStyled(ui.StyleText(ui.Concat(line, padding), ui.Inverse))
 		} else {
-			b.Write(line)
+			b.WriteStyled(line)
 		}
 	}
 	return b.Buffer()
 }
 
+func plainText(t ui.Text) string {
+	var sb strings.Builder
+	for _, seg := range t {
+		sb.WriteString(seg.Text)
+	}
+	return sb.String()
+}
+
 func fixPath(path string) string {
 	if runtime.GOOS != "windows" {
 		return path
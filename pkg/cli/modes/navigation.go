@@ -32,6 +32,17 @@ type Navigation interface {
 	// MutateShowHidden changes whether hidden files - files whose names start
 	// with ".", should be shown.
 	MutateShowHidden(f func(bool) bool)
+	// ToggleMark toggles whether the currently selected file is marked, and
+	// moves the selection down. Marks are cleared when changing directory.
+	ToggleMark()
+	// MarkedNames returns the names of all marked files, sorted.
+	MarkedNames() []string
+	// ClearMarks unmarks all files.
+	ClearMarks()
+	// Refresh reads the current directory again, which is useful after the
+	// directory has been modified. It selects the named file if it exists, or
+	// keeps the current selection if name is empty.
+	Refresh(name string)
 }
 
 // NavigationSpec specifieis the configuration for the navigation mode.
@@ -52,6 +63,8 @@ type NavigationSpec struct {
 type navigationState struct {
 	Filtering  bool
 	ShowHidden bool
+	// Names of marked files in the current directory.
+	Marked map[string]bool
 }
 
 type navigation struct {
@@ -74,7 +87,18 @@ func (w *navigation) MutateState(f func(*navigationState)) {
 func (w *navigation) CopyState() navigationState {
 	w.stateMutex.RLock()
 	defer w.stateMutex.RUnlock()
-	return w.state
+	s := w.state
+	s.Marked = make(map[string]bool, len(w.state.Marked))
+	for name := range w.state.Marked {
+		s.Marked[name] = true
+	}
+	return s
+}
+
+func (w *navigation) isMarked(name string) bool {
+	w.stateMutex.RLock()
+	defer w.stateMutex.RUnlock()
+	return w.state.Marked[name]
 }
 
 func (w *navigation) Handle(event term.Event) bool {
@@ -126,6 +150,7 @@ func (w *navigation) ascend() {
 			s.Buffer = tk.CodeBuffer{}
 		})
 		w.lastFilter = ""
+		w.ClearMarks()
 		updateState(w, currentName)
 	}
 }
@@ -151,6 +176,7 @@ func (w *navigation) descend() {
 			s.Buffer = tk.CodeBuffer{}
 		})
 		w.lastFilter = ""
+		w.ClearMarks()
 		updateState(w, "")
 	}
 }
@@ -238,6 +264,7 @@ func updateState(w *navigation, selectName string) {
 			current,
 			w.Filter.makePredicate(filter),
 			showHidden,
+			w.isMarked,
 			func(it tk.Items, i int) {
 				previewCol := makeCol(it.(fileItems)[i], showHidden)
 				colView.MutateState(func(s *tk.ColViewState) {
@@ -291,10 +318,10 @@ func tryToSelectName(w tk.Widget, name string) {
 }
 
 func makeCol(f NavigationFile, showHidden bool) tk.Widget {
-	return makeColInner(f, func(string) bool { return true }, showHidden, nil)
+	return makeColInner(f, func(string) bool { return true }, showHidden, nil, nil)
 }
 
-func makeColInner(f NavigationFile, filter func(string) bool, showHidden bool, onSelect func(tk.Items, int)) tk.Widget {
+func makeColInner(f NavigationFile, filter func(string) bool, showHidden bool, isMarked func(string) bool, onSelect func(tk.Items, int)) tk.Widget {
 	files, content, err := f.Read()
 	if err != nil {
 		return makeErrCol(err)
@@ -306,6 +333,9 @@ func makeColInner(f NavigationFile, filter func(string) bool, showHidden bool, o
 			name := file.Name()
 			hidden := len(name) > 0 && name[0] == '.'
 			if filter(name) && (showHidden || !hidden) {
+				if isMarked != nil {
+					file = markableFile{file, isMarked}
+				}
 				filtered = append(filtered, file)
 			}
 		}
@@ -330,6 +360,19 @@ func makeErrCol(err error) tk.Widget {
 	return tk.Label{Content: ui.T(err.Error(), ui.FgRed)}
 }
 
+// Wraps a NavigationFile to show it with markedStyling when it is marked.
+type markableFile struct {
+	NavigationFile
+	isMarked func(string) bool
+}
+
+func (f markableFile) ShowName() ui.Text {
+	if f.isMarked(f.Name()) {
+		return ui.StyleText(f.NavigationFile.ShowName(), markedStyling)
+	}
+	return f.NavigationFile.ShowName()
+}
+
 type fileItems []NavigationFile
 
 func (it fileItems) Show(i int) ui.Text {
@@ -379,3 +422,42 @@ func (w *navigation) MutateShowHidden(f func(bool) bool) {
 	w.MutateState(func(s *navigationState) { s.ShowHidden = f(s.ShowHidden) })
 	updateState(w, w.SelectedName())
 }
+
+func (w *navigation) ToggleMark() {
+	name := w.SelectedName()
+	if name == "" {
+		return
+	}
+	w.MutateState(func(s *navigationState) {
+		if s.Marked[name] {
+			delete(s.Marked, name)
+		} else {
+			if s.Marked == nil {
+				s.Marked = make(map[string]bool)
+			}
+			s.Marked[name] = true
+		}
+	})
+	w.Select(tk.Next)
+}
+
+func (w *navigation) MarkedNames() []string {
+	marked := w.CopyState().Marked
+	names := make([]string, 0, len(marked))
+	for name := range marked {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (w *navigation) ClearMarks() {
+	w.MutateState(func(s *navigationState) { s.Marked = nil })
+}
+
+func (w *navigation) Refresh(name string) {
+	if name == "" {
+		name = w.SelectedName()
+	}
+	updateState(w, name)
+}
@@ -2,6 +2,7 @@ package modes
 
 import (
 	"errors"
+	"reflect"
 	"testing"
 
 	"src.elv.sh/pkg/cli"
@@ -165,6 +166,66 @@ func TestNavigation_SelectedName_EmptyDirectory(t *testing.T) {
 	}
 }
 
+func TestNavigation_ToggleMark(t *testing.T) {
+	f := setupNav(t)
+	defer f.Stop()
+
+	w := startNavigation(f.App, NavigationSpec{Cursor: getTestCursor()})
+	w.ToggleMark()
+	w.ToggleMark()
+	f.App.Redraw()
+	styles := ui.RuneStylesheet{
+		'*': ui.Stylings(ui.Bold, ui.FgWhite, ui.BgMagenta),
+		'_': ui.Underlined,
+		'#': ui.Stylings(ui.Inverse, ui.FgBlue),
+		'U': ui.Stylings(ui.Underlined, ui.FgBlue),
+	}
+	f.TestTTY(t,
+		"", term.DotHere, "\n",
+		" NAVIGATING  \n", styles,
+		"************ ",
+		" a    d1            \n", styles,
+		"     ______________ ",
+		" d    d2           \n", styles,
+		"#### UUUUUUUUUUUUUU",
+		" f    d3           ", styles,
+		"     ##############",
+	)
+	if names := w.MarkedNames(); !reflect.DeepEqual(names, []string{"d1", "d2"}) {
+		t.Errorf("got marked names %v, want [d1 d2]", names)
+	}
+
+	// Marks are cleared when changing directory.
+	w.Ascend()
+	if names := w.MarkedNames(); len(names) != 0 {
+		t.Errorf("got marked names %v after ascending, want none", names)
+	}
+}
+
+func TestNavigation_Refresh(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	c := &testCursor{
+		root: testutil.Dir{"d": testutil.Dir{"a": "", "c": ""}},
+		pwd:  []string{"d"}}
+	w := startNavigation(f.App, NavigationSpec{Cursor: c})
+	w.Select(tk.Next)
+
+	// The selection is kept.
+	c.root["d"].(testutil.Dir)["b"] = ""
+	w.Refresh("")
+	if name := w.SelectedName(); name != "c" {
+		t.Errorf("got selected name %q after refreshing, want %q", name, "c")
+	}
+
+	// The named file is selected.
+	w.Refresh("b")
+	if name := w.SelectedName(); name != "b" {
+		t.Errorf("got selected name %q after refreshing, want %q", name, "b")
+	}
+}
+
 func TestNavigation_FakeFS(t *testing.T) {
 	cursor := getTestCursor()
 	testNavigation(t, cursor)
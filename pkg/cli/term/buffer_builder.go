@@ -2,6 +2,7 @@ package term
 
 import (
 	"strings"
+	"unicode/utf8"
 
 	"src.elv.sh/pkg/ui"
 	"src.elv.sh/pkg/wcwidth"
@@ -97,7 +98,11 @@ func (bb *BufferBuilder) WriteRuneSGR(r rune, style string) *BufferBuilder {
 		}
 		c = Cell{"^" + string(r^0x40), style}
 	}
+	return bb.writeCell(c)
+}
 
+// Writes a cell, wrapping the line when needed.
+func (bb *BufferBuilder) writeCell(c Cell) *BufferBuilder {
 	if bb.Col+wcwidth.Of(c.Text) > bb.Width {
 		bb.Newline()
 		bb.appendCell(c)
@@ -135,10 +140,20 @@ func (bb *BufferBuilder) MarkLines(args ...any) *BufferBuilder {
 	return bb.WriteStyled(ui.MarkLines(args...))
 }
 
-// WriteStringSGR writes a string to a buffer with a SGR style.
+// WriteStringSGR writes a string to a buffer with a SGR style. Each extended
+// grapheme cluster is written into one cell, except that control characters
+// are written with WriteRuneSGR.
 func (bb *BufferBuilder) WriteStringSGR(text, style string) *BufferBuilder {
-	for _, r := range text {
-		bb.WriteRuneSGR(r, style)
+	for text != "" {
+		n := wcwidth.NextCluster(text)
+		if r, size := utf8.DecodeRuneInString(text); size == n || r < 0x20 || r == 0x7f {
+			for _, r := range text[:n] {
+				bb.WriteRuneSGR(r, style)
+			}
+		} else {
+			bb.writeCell(Cell{text[:n], style})
+		}
+		text = text[n:]
 	}
 	return bb
 }
@@ -27,6 +27,13 @@ var bufferBuilderWritesTests = []struct {
 			{"a", "1"},
 			{"^[", "1;7"},
 			{"b", "1"}}}}},
+	// Writing extended grapheme clusters.
+	{NewBufferBuilder(10), "e\u0301👍🏽", "1",
+		&Buffer{Width: 10, Lines: [][]Cell{{{"e\u0301", "1"}, {"👍🏽", "1"}}}}},
+	// Writing wide extended grapheme clusters that trigger wrapping.
+	{NewBufferBuilder(3), "a👨\u200d👩b", "",
+		&Buffer{Width: 3, Lines: [][]Cell{
+			{{"a", ""}, {"👨\u200d👩", ""}}, {{"b", ""}}}}},
 	// Writing text containing a newline.
 	{NewBufferBuilder(10), "a\nb", "1",
 		&Buffer{Width: 10, Lines: [][]Cell{
@@ -11,6 +11,7 @@ import (
 	"src.elv.sh/pkg/cli/term"
 	"src.elv.sh/pkg/parse"
 	"src.elv.sh/pkg/ui"
+	"src.elv.sh/pkg/wcwidth"
 )
 
 // CodeArea is a Widget for displaying and editing code.
@@ -359,8 +360,8 @@ func (w *codeArea) handleKeyEvent(key ui.Key) bool {
 		w.resetInserts()
 		w.MutateState(func(s *CodeAreaState) {
 			c := &s.Buffer
-			// Remove the last rune.
-			_, chop := utf8.DecodeLastRuneInString(c.Content[:c.Dot])
+			// Remove the last extended grapheme cluster.
+			chop := wcwidth.LastCluster(c.Content[:c.Dot])
 			*c = CodeBuffer{
 				Content: c.Content[:c.Dot-chop] + c.Content[c.Dot:],
 				Dot:     c.Dot - chop,
@@ -274,6 +274,13 @@ var codeAreaHandleTests = []handleTest{
 			term.K('你'), term.K('好'), term.K(ui.Backspace)},
 		WantNewState: CodeAreaState{Buffer: CodeBuffer{Content: "你", Dot: 3}},
 	},
+	{
+		Name: "backspace deleting extended grapheme cluster",
+		Given: NewCodeArea(CodeAreaSpec{State: CodeAreaState{
+			Buffer: CodeBuffer{Content: "a👍🏽", Dot: len("a👍🏽")}}}),
+		Events:       []term.Event{term.K(ui.Backspace)},
+		WantNewState: CodeAreaState{Buffer: CodeBuffer{Content: "a", Dot: 1}},
+	},
 	// Regression test for https://b.elv.sh/1178
 	{
 		Name:  "Ctrl-H being equivalent to backspace",
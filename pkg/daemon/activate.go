@@ -4,21 +4,24 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"src.elv.sh/pkg/daemon/daemondefs"
 	"src.elv.sh/pkg/daemon/internal/api"
+	"src.elv.sh/pkg/env"
 	"src.elv.sh/pkg/fsutil"
+	"src.elv.sh/pkg/rpc"
 )
 
+// Default time to wait for a spawned daemon to come up, and time to wait for a
+// killed daemon to remove its socket. Can be overridden in tests.
 var (
-	daemonSpawnTimeout     = time.Second
-	daemonSpawnWaitPerLoop = 10 * time.Millisecond
-
-	daemonKillTimeout     = time.Second
-	daemonKillWaitPerLoop = 10 * time.Millisecond
+	daemonSpawnTimeout = 2 * time.Second
+	daemonKillTimeout  = time.Second
 )
 
 type daemonStatus int
@@ -30,17 +33,32 @@ const (
 	connectionRefused
 	connectionOtherError
 	daemonOutdated
+	// The daemon runs a newer version of Elvish with an incompatible API. It
+	// is not restarted, since newer Elvish processes may still be using it.
+	daemonNewer
 )
 
 const connectionRefusedFmt = "Socket file %s exists but refuses requests. This is likely because the daemon was terminated abnormally. Going to remove socket file and re-spawn the daemon.\n"
 
 // Activate returns a daemon client, either by connecting to an existing daemon,
-// or spawning a new one. It always returns a non-nil client, even if there was an error.
+// or spawning a new one. It always returns a non-nil client, even if there was an error,
+// unless spawnCfg.Remote is an invalid configuration.
+//
+// If spawnCfg.Activated is not nil, it returns immediately and activates the
+// client in the background instead; see [daemondefs.SpawnConfig].
 func Activate(stderr io.Writer, spawnCfg *daemondefs.SpawnConfig) (daemondefs.Client, error) {
+	if spawnCfg.Activated != nil {
+		return activateAsync(stderr, spawnCfg), nil
+	}
+	if spawnCfg.Remote != nil {
+		return activateRemote(*spawnCfg.Remote)
+	}
 	sockpath := spawnCfg.SockPath
-	cl := NewClient(sockpath)
+	cl := newSockClient(sockpath)
 	status, err := detectDaemon(sockpath, cl)
 	shouldSpawn := false
+	// The listening socket handed off by an outdated daemon.
+	var handoff *os.File
 
 	switch status {
 	case daemonOK:
@@ -57,11 +75,26 @@ func Activate(stderr io.Writer, spawnCfg *daemondefs.SpawnConfig) (daemondefs.Cl
 		shouldSpawn = true
 	case connectionOtherError:
 		return cl, fmt.Errorf("unexpected RPC error on socket %s: %w", sockpath, err)
+	case daemonNewer:
+		return cl, err
 	case daemonOutdated:
-		fmt.Fprintln(stderr, "Daemon is outdated; going to kill old daemon and re-spawn")
-		err := killDaemon(sockpath, cl)
+		if managed, _ := cl.managed(); managed {
+			// Spawning a daemon would conflict with the service manager,
+			// which starts the new version on the next connection instead.
+			fmt.Fprintln(stderr, "Daemon is outdated; going to stop old daemon and wait for the service manager to restart it")
+			err := shutdownDaemon(cl, func() error { return stopDaemon(cl) })
+			if err != nil {
+				return cl, fmt.Errorf("failed to stop old daemon: %w", err)
+			}
+			return cl, waitForManagedDaemon(stderr, spawnCfg, cl)
+		}
+		fmt.Fprintln(stderr, "Daemon is outdated; going to stop old daemon and re-spawn")
+		handoff, err = upgradeDaemon(spawnCfg, cl)
 		if err != nil {
-			return cl, fmt.Errorf("failed to kill old daemon: %w", err)
+			return cl, fmt.Errorf("failed to stop old daemon: %w", err)
+		}
+		if handoff != nil {
+			defer handoff.Close()
 		}
 		shouldSpawn = true
 	default:
@@ -72,14 +105,15 @@ func Activate(stderr io.Writer, spawnCfg *daemondefs.SpawnConfig) (daemondefs.Cl
 		return cl, nil
 	}
 
-	err = spawn(spawnCfg)
+	err = spawn(spawnCfg, handoff)
 	if err != nil {
 		return cl, fmt.Errorf("failed to spawn daemon: %w", err)
 	}
 
 	// Wait for daemon to come online
-	start := time.Now()
-	for time.Since(start) < daemonSpawnTimeout {
+	timeout := spawnTimeout(spawnCfg)
+	b := newBackoff(timeout)
+	for {
 		cl.ResetConn()
 		status, err := detectDaemon(sockpath, cl)
 
@@ -96,12 +130,78 @@ func Activate(stderr io.Writer, spawnCfg *daemondefs.SpawnConfig) (daemondefs.Cl
 			return cl, fmt.Errorf("unexpected RPC error on socket %s: %w", sockpath, err)
 		case daemonOutdated:
 			return cl, fmt.Errorf("code bug: newly spawned daemon is outdated")
+		case daemonNewer:
+			return cl, err
 		default:
 			return cl, fmt.Errorf("code bug: unknown daemon status %d", status)
 		}
-		time.Sleep(daemonSpawnWaitPerLoop)
+		if !waitToRetry(stderr, spawnCfg, b, err) {
+			return cl, &daemondefs.SpawnTimeoutError{Timeout: timeout, Attempts: b.attempts, Err: err}
+		}
+	}
+}
+
+// Returns a client whose requests wait for Activate to finish in the
+// background, and then use the connection it has made.
+func activateAsync(stderr io.Writer, spawnCfg *daemondefs.SpawnConfig) daemondefs.Client {
+	syncCfg := *spawnCfg
+	syncCfg.Activated = nil
+	sockPath := spawnCfg.SockPath
+	if spawnCfg.Remote != nil {
+		sockPath = spawnCfg.Remote.Addr
+	}
+	cl := &client{sockPath: sockPath, ready: make(chan struct{})}
+	go func() {
+		activated, err := Activate(stderr, &syncCfg)
+		if activated, ok := activated.(*client); ok {
+			cl.dial, cl.rpcClient = activated.dial, activated.rpcClient
+		} else {
+			// Only happens with an invalid remote configuration.
+			cl.dial = func() (net.Conn, error) { return nil, err }
+		}
+		close(cl.ready)
+		spawnCfg.Activated(err)
+	}()
+	return cl
+}
+
+func spawnTimeout(cfg *daemondefs.SpawnConfig) time.Duration {
+	if cfg.SpawnTimeout > 0 {
+		return cfg.SpawnTimeout
 	}
-	return cl, fmt.Errorf("daemon did not come up within %v", daemonSpawnTimeout)
+	return daemonSpawnTimeout
+}
+
+// Waits before retrying to connect to the daemon after a failed attempt with
+// the given error, or returns false if the backoff has reached the deadline.
+func waitToRetry(stderr io.Writer, cfg *daemondefs.SpawnConfig, b *backoff, err error) bool {
+	wait, ok := b.next()
+	if !ok {
+		return false
+	}
+	logger.Printf("daemon not ready after %d attempts (%v), retrying in %v", b.attempts, err, wait)
+	if cfg.Verbose {
+		fmt.Fprintf(stderr, "Daemon is not ready yet (%v); retrying in %v\n", err, wait)
+	}
+	time.Sleep(wait)
+	return true
+}
+
+// Connects to a remote daemon. It never spawns a daemon, since the remote
+// daemon is managed separately.
+func activateRemote(cfg daemondefs.RemoteConfig) (daemondefs.Client, error) {
+	cl, err := NewRemoteClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("remote daemon %s: %w", cfg.Addr, err)
+	}
+	version, err := cl.Version()
+	if err != nil {
+		return cl, fmt.Errorf("remote daemon %s: %w", cfg.Addr, err)
+	}
+	if version != api.Version {
+		return cl, fmt.Errorf("remote daemon %s is incompatible (API version %d, want %d)", cfg.Addr, version, api.Version)
+	}
+	return cl, nil
 }
 
 func detectDaemon(sockpath string, cl daemondefs.Client) (daemonStatus, error) {
@@ -122,37 +222,113 @@ func detectDaemon(sockpath string, cl daemondefs.Client) (daemonStatus, error) {
 	}
 	if version < api.Version {
 		return daemonOutdated, nil
+	} else if version > api.Version {
+		return daemonNewer, fmt.Errorf(
+			"daemon uses a newer API version %d than this Elvish (%d); restart Elvish to upgrade it",
+			version, api.Version)
 	}
 	return daemonOK, nil
 }
 
-func killDaemon(sockpath string, cl daemondefs.Client) error {
+// Waits for a daemon started by a service manager to come online after the old
+// daemon has been stopped. Connections may still reach the old daemon while it
+// is exiting.
+func waitForManagedDaemon(stderr io.Writer, cfg *daemondefs.SpawnConfig, cl daemondefs.Client) error {
+	timeout := spawnTimeout(cfg)
+	b := newBackoff(timeout)
+	for {
+		cl.ResetConn()
+		status, err := detectDaemon(cfg.SockPath, cl)
+		switch status {
+		case daemonOK:
+			return nil
+		case daemonOutdated:
+			err = errors.New("daemon is still outdated")
+		case connectionRefused:
+			// Continue waiting
+		default:
+			return fmt.Errorf("wait for daemon: %w", err)
+		}
+		if !waitToRetry(stderr, cfg, b, err) {
+			return &daemondefs.SpawnTimeoutError{Timeout: timeout, Attempts: b.attempts, Err: err}
+		}
+	}
+}
+
+// Asks the outdated daemon to exit with the Shutdown RPC, and returns the
+// listening socket it hands off, which is nil if the daemon couldn't hand it
+// off. Falls back to killDaemon if the daemon doesn't support the Shutdown RPC.
+func upgradeDaemon(cfg *daemondefs.SpawnConfig, cl *client) (*os.File, error) {
+	receiver, err := newHandoffReceiver(cfg.RunDir)
+	if err != nil {
+		logger.Println("cannot receive socket from old daemon:", err)
+		return nil, shutdownDaemon(cl, func() error { return killDaemon(cfg.SockPath, cl) })
+	}
+	defer receiver.close()
+	handedOff, err := cl.shutdown(receiver.path)
+	if isMissingShutdown(err) {
+		return nil, killDaemon(cfg.SockPath, cl)
+	} else if err != nil {
+		return nil, err
+	}
+	if !handedOff {
+		return nil, nil
+	}
+	return receiver.receive()
+}
+
+// Asks the daemon to exit with the Shutdown RPC, without handing off its
+// listening socket. When this returns, the daemon has closed the database and
+// stopped listening. Calls fallback instead if the daemon doesn't support the
+// Shutdown RPC.
+func shutdownDaemon(cl *client, fallback func() error) error {
+	_, err := cl.shutdown("")
+	if isMissingShutdown(err) {
+		return fallback()
+	}
+	return err
+}
+
+// Returns whether the error is from an old daemon that doesn't support the
+// Shutdown RPC.
+func isMissingShutdown(err error) bool {
+	var serverErr rpc.ServerError
+	return errors.As(err, &serverErr) && strings.Contains(string(serverErr), "can't find method")
+}
+
+// Asks the daemon to exit by sending it a signal.
+func stopDaemon(cl daemondefs.Client) error {
 	pid, err := cl.Pid()
 	if err != nil {
-		return fmt.Errorf("kill daemon: %w", err)
+		return err
 	}
 	process, err := os.FindProcess(pid)
 	if err != nil {
-		return fmt.Errorf("kill daemon: %w", err)
+		return err
 	}
-	err = process.Signal(os.Interrupt)
-	if err != nil {
+	return process.Signal(os.Interrupt)
+}
+
+func killDaemon(sockpath string, cl daemondefs.Client) error {
+	if err := stopDaemon(cl); err != nil {
 		return fmt.Errorf("kill daemon: %w", err)
 	}
 	// Wait until the old daemon has removed the socket file, so that it doesn't
 	// inadvertently remove the socket file of the new daemon we will start.
-	start := time.Now()
-	for time.Since(start) < daemonKillTimeout {
+	b := newBackoff(daemonKillTimeout)
+	for {
 		_, err := os.Lstat(sockpath)
-		if err == nil {
-			time.Sleep(daemonKillWaitPerLoop)
-		} else if os.IsNotExist(err) {
+		if os.IsNotExist(err) {
 			return nil
-		} else {
+		} else if err != nil {
 			return fmt.Errorf("kill daemon: %w", err)
 		}
+		wait, ok := b.next()
+		if !ok {
+			return fmt.Errorf("kill daemon: daemon did not remove socket within %v", daemonKillTimeout)
+		}
+		time.Sleep(wait)
 	}
-	return fmt.Errorf("kill daemon: daemon did not remove socket within %v", daemonKillTimeout)
 }
 
 // Can be overridden in tests to avoid actual forking.
@@ -170,7 +346,7 @@ var startProcess = func(name string, argv []string, attr *os.ProcAttr) error {
 // daemon is detached from the current terminal, so that it is not affected by
 // I/O or signals in the current terminal and keeps running after the current
 // process quits.
-func spawn(cfg *daemondefs.SpawnConfig) error {
+func spawn(cfg *daemondefs.SpawnConfig, handoff *os.File) error {
 	binPath, err := os.Executable()
 	if err != nil {
 		return errors.New("cannot find elvish: " + err.Error())
@@ -190,6 +366,12 @@ func spawn(cfg *daemondefs.SpawnConfig) error {
 		"-db", dbPath,
 		"-sock", sockPath,
 	}
+	if cfg.IdleTimeout > 0 {
+		args = append(args, "-daemon-idle-timeout", cfg.IdleTimeout.String())
+	}
+	if cfg.Passphrase != "" {
+		args = append(args, "-db-encrypt")
+	}
 
 	// The daemon does not read any input; open DevNull and use it for stdin. We
 	// could also just close the stdin, but on Unix that would make the first
@@ -206,7 +388,17 @@ func spawn(cfg *daemondefs.SpawnConfig) error {
 	}
 	defer out.Close()
 
-	procattrs := procAttrForSpawn([]*os.File{in, out, out})
+	files := []*os.File{in, out, out}
+	if handoff != nil {
+		files = append(files, handoff)
+	}
+	procattrs := procAttrForSpawn(files)
+	if handoff != nil {
+		procattrs.Env = append(procattrs.Env, handoffEnv())
+	}
+	if cfg.Passphrase != "" {
+		procattrs.Env = append(procattrs.Env, env.ELVISH_DB_PASSPHRASE+"="+cfg.Passphrase)
+	}
 
 	err = startProcess(binPath, args, procattrs)
 	return err
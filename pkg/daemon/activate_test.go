@@ -1,14 +1,19 @@
 package daemon
 
 import (
+	"errors"
 	"io"
 	"net"
 	"os"
 	"runtime"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
 	"src.elv.sh/pkg/daemon/daemondefs"
+	"src.elv.sh/pkg/daemon/internal/api"
+	"src.elv.sh/pkg/env"
 	"src.elv.sh/pkg/must"
 	"src.elv.sh/pkg/testutil"
 )
@@ -23,6 +28,27 @@ func TestActivate_ConnectsToExistingServer(t *testing.T) {
 	}
 }
 
+func TestActivate_DoesNotStopNewerServer(t *testing.T) {
+	setupForActivate(t, func(name string, argv []string, attr *os.ProcAttr) error {
+		t.Errorf("spawned a new server")
+		return nil
+	})
+	version := api.Version + 1
+	sigCh := make(chan os.Signal)
+	startServerOpts(t, cli("sock", "db"), ServeOpts{Version: &version, Signals: sigCh})
+	t.Cleanup(func() { close(sigCh) })
+
+	_, err := Activate(io.Discard,
+		&daemondefs.SpawnConfig{DbPath: "db", SockPath: "sock", RunDir: "."})
+	if err == nil || !strings.Contains(err.Error(), "newer API version") {
+		t.Errorf("got error %v, want error about newer API version", err)
+	}
+	// The server is still running.
+	if v, err := startClient(t, "sock").Version(); v != version || err != nil {
+		t.Errorf("Version() -> (%v, %v), want (%v, nil)", v, err, version)
+	}
+}
+
 func TestActivate_SpawnsNewServer(t *testing.T) {
 	activated := 0
 	setupForActivate(t, func(name string, argv []string, attr *os.ProcAttr) error {
@@ -41,6 +67,84 @@ func TestActivate_SpawnsNewServer(t *testing.T) {
 	}
 }
 
+func TestActivate_Async(t *testing.T) {
+	spawning := make(chan struct{})
+	setupForActivate(t, func(name string, argv []string, attr *os.ProcAttr) error {
+		<-spawning
+		startServer(t, argv)
+		return nil
+	})
+
+	activated := make(chan error, 1)
+	cl, err := Activate(io.Discard, &daemondefs.SpawnConfig{
+		DbPath: "db", SockPath: "sock", RunDir: ".",
+		Activated: func(err error) { activated <- err }})
+	if err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+	defer cl.Close()
+	if cl.SockPath() != "sock" {
+		t.Errorf("got SockPath() %q, want %q", cl.SockPath(), "sock")
+	}
+	select {
+	case <-activated:
+		t.Fatalf("activated before the daemon has been spawned")
+	default:
+	}
+
+	// Requests wait for the daemon.
+	seqCh := make(chan int)
+	go func() {
+		seq, _ := cl.AddCmd("echo foo")
+		seqCh <- seq
+	}()
+	close(spawning)
+	if seq := <-seqCh; seq != 1 {
+		t.Errorf("AddCmd -> %v, want 1", seq)
+	}
+	if err := <-activated; err != nil {
+		t.Errorf("Activated called with %v, want nil", err)
+	}
+}
+
+func TestActivate_AsyncReportsError(t *testing.T) {
+	setup(t)
+	must.CreateEmpty("sock")
+	activated := make(chan error, 1)
+	cl, _ := Activate(io.Discard, &daemondefs.SpawnConfig{
+		DbPath: "db", SockPath: "sock", RunDir: ".",
+		Activated: func(err error) { activated <- err }})
+	defer cl.Close()
+
+	if err := <-activated; err == nil {
+		t.Errorf("Activated called with nil, want error")
+	}
+	if _, err := cl.NextCmdSeq(); err == nil {
+		t.Errorf("NextCmdSeq -> no error")
+	}
+}
+
+func TestActivate_PassesPassphrase(t *testing.T) {
+	var gotArgs, gotEnv []string
+	setupForActivate(t, func(name string, argv []string, attr *os.ProcAttr) error {
+		gotArgs, gotEnv = argv, attr.Env
+		startServer(t, cli("sock", "db"))
+		return nil
+	})
+
+	_, err := Activate(io.Discard,
+		&daemondefs.SpawnConfig{DbPath: "db", SockPath: "sock", RunDir: ".", Passphrase: "passphrase"})
+	if err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+	if !slices.Contains(gotArgs, "-db-encrypt") {
+		t.Errorf("got args %q, want -db-encrypt", gotArgs)
+	}
+	if !slices.Contains(gotEnv, env.ELVISH_DB_PASSPHRASE+"=passphrase") {
+		t.Errorf("got env %q, want $%s", gotEnv, env.ELVISH_DB_PASSPHRASE)
+	}
+}
+
 func TestActivate_RemovesHangingSocketAndSpawnsNewServer(t *testing.T) {
 	activated := 0
 	setupForActivate(t, func(name string, argv []string, attr *os.ProcAttr) error {
@@ -60,6 +164,30 @@ func TestActivate_RemovesHangingSocketAndSpawnsNewServer(t *testing.T) {
 	}
 }
 
+func TestActivate_ReturnsSpawnTimeoutError(t *testing.T) {
+	setupForActivate(t, func(string, []string, *os.ProcAttr) error { return nil })
+
+	for _, verbose := range []bool{false, true} {
+		var stderr strings.Builder
+		timeout := 50 * time.Millisecond
+		_, err := Activate(&stderr, &daemondefs.SpawnConfig{
+			DbPath: "db", SockPath: "sock", RunDir: ".",
+			SpawnTimeout: timeout, Verbose: verbose})
+
+		var timeoutErr *daemondefs.SpawnTimeoutError
+		if !errors.As(err, &timeoutErr) {
+			t.Fatalf("got error %v, want *SpawnTimeoutError", err)
+		}
+		if timeoutErr.Timeout != timeout || timeoutErr.Attempts < 2 {
+			t.Errorf("got timeout %v after %d attempts, want %v after at least 2",
+				timeoutErr.Timeout, timeoutErr.Attempts, timeout)
+		}
+		if gotMsg := strings.Contains(stderr.String(), "Daemon is not ready yet"); gotMsg != verbose {
+			t.Errorf("with Verbose = %v, got stderr %q", verbose, stderr.String())
+		}
+	}
+}
+
 func TestActivate_FailsIfCannotStatSock(t *testing.T) {
 	setup(t)
 	// Build a path for which Lstat will return a non-nil err such that
@@ -4,6 +4,7 @@ package daemon
 
 import (
 	"io"
+	"net"
 	"os"
 	"os/user"
 	"testing"
@@ -13,15 +14,25 @@ import (
 	"src.elv.sh/pkg/must"
 )
 
-func TestActivate_InterruptsOutdatedServerAndSpawnsNewServer(t *testing.T) {
+func TestActivate_UpgradesOutdatedServerWithSocketHandoff(t *testing.T) {
 	activated := 0
+	handedOff := false
 	setupForActivate(t, func(name string, argv []string, attr *os.ProcAttr) error {
-		startServer(t, argv)
+		sigCh := make(chan os.Signal)
+		opts := ServeOpts{Signals: sigCh}
+		if len(attr.Files) > 3 {
+			opts.Handoff = must.OK1(net.FileListener(attr.Files[3]))
+			handedOff = true
+		}
+		startServerOpts(t, argv, opts)
+		t.Cleanup(func() { close(sigCh) })
 		activated++
 		return nil
 	})
 	version := api.Version - 1
 	oldServer := startServerOpts(t, cli("sock", "db"), ServeOpts{Version: &version})
+	oldClient := startClient(t, "sock")
+	must.OK1(oldClient.AddCmd("echo old"))
 
 	_, err := Activate(io.Discard,
 		&daemondefs.SpawnConfig{DbPath: "db", SockPath: "sock", RunDir: "."})
@@ -31,7 +42,17 @@ func TestActivate_InterruptsOutdatedServerAndSpawnsNewServer(t *testing.T) {
 	if activated != 1 {
 		t.Errorf("got activated %v times, want 1", activated)
 	}
+	if !handedOff {
+		t.Errorf("socket not handed off to new server")
+	}
 	oldServer.WaitQuit()
+
+	// The client of the old server transparently reconnects to the new
+	// server, which has access to the database.
+	cmds, err := oldClient.CmdsWithSeq(0, -1)
+	if len(cmds) != 1 || cmds[0].Text != "echo old" || err != nil {
+		t.Errorf("got (%v, %v), want the command added to the old server", cmds, err)
+	}
 }
 
 func TestActivate_FailsIfUnableToRemoveHangingSocket(t *testing.T) {
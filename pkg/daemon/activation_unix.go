@@ -0,0 +1,46 @@
+//go:build unix
+
+package daemon
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+	"src.elv.sh/pkg/env"
+)
+
+// The first file descriptor passed with socket activation. Can be overridden
+// in tests.
+var listenFDsStart = 3
+
+// Returns the listener passed by a service manager with socket activation, or
+// nil if the daemon is not started with socket activation.
+//
+// This follows the protocol of systemd's sd_listen_fds: $LISTEN_PID is the
+// pid of the daemon, and $LISTEN_FDS is the number of file descriptors passed,
+// starting from 3.
+func activationListener() (net.Listener, error) {
+	pid, fds := os.Getenv(env.LISTEN_PID), os.Getenv(env.LISTEN_FDS)
+	if pid != strconv.Itoa(os.Getpid()) || fds == "" {
+		return nil, nil
+	}
+	// Don't pass the variables on to child processes.
+	os.Unsetenv(env.LISTEN_PID)
+	os.Unsetenv(env.LISTEN_FDS)
+	os.Unsetenv(env.LISTEN_FDNAMES)
+	n, err := strconv.Atoi(fds)
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid $%s: %q", env.LISTEN_FDS, fds)
+	}
+	if n > 1 {
+		return nil, fmt.Errorf("socket activation passed %d sockets, want 1", n)
+	}
+	unix.CloseOnExec(listenFDsStart)
+	f := os.NewFile(uintptr(listenFDsStart), "socket activation")
+	// FileListener duplicates the file descriptor, so f can be closed.
+	defer f.Close()
+	return net.FileListener(f)
+}
@@ -0,0 +1,67 @@
+//go:build unix
+
+package daemon
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"testing"
+
+	"src.elv.sh/pkg/must"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestActivationListener_NotActivated(t *testing.T) {
+	testutil.Unsetenv(t, "LISTEN_PID")
+	testutil.Unsetenv(t, "LISTEN_FDS")
+	if l, err := activationListener(); l != nil || err != nil {
+		t.Errorf("got (%v, %v), want (nil, nil)", l, err)
+	}
+
+	// LISTEN_PID is for another process.
+	testutil.Setenv(t, "LISTEN_PID", "1")
+	testutil.Setenv(t, "LISTEN_FDS", "1")
+	if l, err := activationListener(); l != nil || err != nil {
+		t.Errorf("got (%v, %v), want (nil, nil)", l, err)
+	}
+}
+
+func TestActivationListener_InvalidFDs(t *testing.T) {
+	testutil.Setenv(t, "LISTEN_PID", strconv.Itoa(os.Getpid()))
+	testutil.Setenv(t, "LISTEN_FDS", "2")
+	if _, err := activationListener(); err == nil {
+		t.Errorf("got nil error, want error")
+	}
+}
+
+func TestProgram_SocketActivation(t *testing.T) {
+	setup(t)
+	// Simulate a service manager by creating the socket and passing its file
+	// descriptor.
+	l := must.OK1(net.Listen("unix", "sock"))
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	f := must.OK1(l.(*net.UnixListener).File())
+	l.Close()
+	defer f.Close()
+	testutil.Set(t, &listenFDsStart, int(f.Fd()))
+	testutil.Setenv(t, "LISTEN_PID", strconv.Itoa(os.Getpid()))
+	testutil.Setenv(t, "LISTEN_FDS", "1")
+
+	server := startServer(t, cli("sock", "db"))
+	cl := newSockClient("sock")
+	if managed, err := cl.managed(); !managed || err != nil {
+		t.Errorf("managed() -> (%v, %v), want (true, nil)", managed, err)
+	}
+	if _, ok := os.LookupEnv("LISTEN_PID"); ok {
+		t.Errorf("LISTEN_PID not unset")
+	}
+	cl.Close()
+
+	// The daemon exits when all clients have disconnected, but doesn't
+	// remove the socket file, which belongs to the service manager.
+	server.WaitQuit()
+	if _, err := os.Lstat("sock"); err != nil {
+		t.Errorf("socket file removed: %v", err)
+	}
+}
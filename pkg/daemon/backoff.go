@@ -0,0 +1,34 @@
+package daemon
+
+import "time"
+
+// Initial and maximum intervals between attempts when waiting for the daemon.
+// Can be overridden in tests.
+var (
+	backoffInitial = time.Millisecond
+	backoffMax     = 100 * time.Millisecond
+)
+
+// Exponential backoff for polling until a deadline.
+type backoff struct {
+	deadline time.Time
+	wait     time.Duration
+	attempts int
+}
+
+func newBackoff(timeout time.Duration) *backoff {
+	return &backoff{deadline: time.Now().Add(timeout), wait: backoffInitial}
+}
+
+// Records a failed attempt, and returns how long to wait before the next one,
+// or false if the deadline has passed.
+func (b *backoff) next() (time.Duration, bool) {
+	b.attempts++
+	remaining := time.Until(b.deadline)
+	if remaining <= 0 {
+		return 0, false
+	}
+	wait := min(b.wait, remaining)
+	b.wait = min(2*b.wait, backoffMax)
+	return wait, true
+}
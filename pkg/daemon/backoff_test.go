@@ -0,0 +1,25 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestBackoff(t *testing.T) {
+	testutil.Set(t, &backoffInitial, time.Millisecond)
+	testutil.Set(t, &backoffMax, 4*time.Millisecond)
+	b := newBackoff(time.Hour)
+	for i, want := range []time.Duration{1, 2, 4, 4} {
+		wait, ok := b.next()
+		if wait != want*time.Millisecond || !ok {
+			t.Errorf("attempt %d: got (%v, %v), want (%v, true)", i+1, wait, ok, want*time.Millisecond)
+		}
+	}
+
+	b = newBackoff(0)
+	if _, ok := b.next(); ok || b.attempts != 1 {
+		t.Errorf("got ok after deadline")
+	}
+}
@@ -2,6 +2,7 @@ package daemon
 
 import (
 	"errors"
+	"fmt"
 	"net"
 	"sync"
 
@@ -19,21 +20,51 @@ var (
 	ErrDaemonUnreachable = errors.New("daemon offline")
 )
 
+// UnsupportedError is returned when calling an RPC method that the daemon
+// doesn't support, because it runs an older version of Elvish. The daemon is
+// only restarted automatically when its API version is incompatible.
+type UnsupportedError struct {
+	Method string
+}
+
+func (e UnsupportedError) Error() string {
+	return fmt.Sprintf("daemon doesn't support %s, since it runs an older version of Elvish; restart the daemon to upgrade it", e.Method)
+}
+
 // Implementation of the Client interface.
 type client struct {
 	sockPath  string
+	dial      func() (net.Conn, error)
 	rpcClient *rpc.Client
 	waits     sync.WaitGroup
+
+	// Methods supported by the daemon, fetched with the Methods RPC before the
+	// first call to other methods. Nil if not fetched yet, or if the daemon
+	// doesn't support the Methods RPC.
+	methodsMu sync.Mutex
+	methods   map[string]bool
+	// If not nil, closed when the client has been activated in the background
+	// by activateAsync. Requests wait for it.
+	ready chan struct{}
 }
 
 // NewClient creates a new Client instance that talks to the socket. Connection
 // creation is deferred to the first request.
 func NewClient(sockPath string) daemondefs.Client {
-	return &client{sockPath, nil, sync.WaitGroup{}}
+	return newSockClient(sockPath)
+}
+
+func newSockClient(sockPath string) *client {
+	return newClient(sockPath, func() (net.Conn, error) { return dial(sockPath) })
 }
 
-// SockPath returns the socket path that the Client talks to. If the client is
-// nil, it returns an empty string.
+func newClient(sockPath string, dial func() (net.Conn, error)) *client {
+	return &client{sockPath: sockPath, dial: dial}
+}
+
+// SockPath returns the socket path that the Client talks to, or the address
+// for a Client created with NewRemoteClient. If the client is nil, it returns
+// an empty string.
 func (c *client) SockPath() string {
 	return c.sockPath
 }
@@ -41,11 +72,19 @@ func (c *client) SockPath() string {
 // ResetConn resets the current connection. A new connection will be established
 // the next time a request is made. If the client is nil, it does nothing.
 func (c *client) ResetConn() error {
+	if c.ready != nil {
+		// The connection may still be being set up by activateAsync.
+		<-c.ready
+	}
 	if c.rpcClient == nil {
 		return nil
 	}
 	rc := c.rpcClient
 	c.rpcClient = nil
+	// The daemon may be replaced by another version.
+	c.methodsMu.Lock()
+	c.methods = nil
+	c.methodsMu.Unlock()
 	return rc.Close()
 }
 
@@ -59,10 +98,51 @@ func (c *client) Close() error {
 func (c *client) call(f string, req, res any) error {
 	c.waits.Add(1)
 	defer c.waits.Done()
+	if c.ready != nil {
+		<-c.ready
+	}
+	if !c.supports(f) {
+		return UnsupportedError{f}
+	}
+	return c.rawCall(f, req, res)
+}
 
+// Methods that are called before checking the API version, or to find out
+// the supported methods. They are assumed to be supported.
+var basicMethods = map[string]bool{"Version": true, "Methods": true, "Pid": true}
+
+// Returns whether the daemon supports the method, fetching the supported
+// methods if needed. Methods are assumed to be supported if they can't be
+// fetched.
+func (c *client) supports(f string) bool {
+	if basicMethods[f] {
+		return true
+	}
+	c.methodsMu.Lock()
+	methods := c.methods
+	c.methodsMu.Unlock()
+	if methods == nil {
+		// The lock is not held during the call, since rawCall may reset the
+		// connection, which also resets the methods.
+		res := &api.MethodsResponse{}
+		if err := c.rawCall("Methods", &api.MethodsRequest{}, res); err != nil {
+			return true
+		}
+		methods = make(map[string]bool, len(res.Methods))
+		for _, m := range res.Methods {
+			methods[m] = true
+		}
+		c.methodsMu.Lock()
+		c.methods = methods
+		c.methodsMu.Unlock()
+	}
+	return methods[f]
+}
+
+func (c *client) rawCall(f string, req, res any) error {
 	for attempt := 0; attempt < retriesOnShutdown; attempt++ {
 		if c.rpcClient == nil {
-			conn, err := net.Dial("unix", c.sockPath)
+			conn, err := c.dial()
 			if err != nil {
 				return err
 			}
@@ -70,10 +150,16 @@ func (c *client) call(f string, req, res any) error {
 		}
 
 		err := c.rpcClient.Call(api.ServiceName+"."+f, req, res)
+		var opErr *net.OpError
 		if err == rpc.ErrShutdown {
 			// Clear rpcClient so as to reconnect next time
 			c.rpcClient = nil
 			continue
+		} else if errors.As(err, &opErr) {
+			// The request could not be sent, because the daemon has stopped
+			// reading requests from this connection while shutting down.
+			c.ResetConn()
+			continue
 		} else {
 			return err
 		}
@@ -99,6 +185,27 @@ func (c *client) Pid() (int, error) {
 	return res.Pid, err
 }
 
+func (c *client) managed() (bool, error) {
+	req := &api.ManagedRequest{}
+	res := &api.ManagedResponse{}
+	err := c.call("Managed", req, res)
+	return res.Managed, err
+}
+
+func (c *client) Stats() (daemondefs.Stats, error) {
+	req := &api.StatsRequest{}
+	res := &api.StatsResponse{}
+	err := c.call("Stats", req, res)
+	return res.Stats, err
+}
+
+func (c *client) shutdown(handoff string) (bool, error) {
+	req := &api.ShutdownRequest{Handoff: handoff}
+	res := &api.ShutdownResponse{}
+	err := c.call("Shutdown", req, res)
+	return res.HandedOff, err
+}
+
 func (c *client) NextCmdSeq() (int, error) {
 	req := &api.NextCmdRequest{}
 	res := &api.NextCmdSeqResponse{}
@@ -148,6 +255,111 @@ func (c *client) PrevCmd(upto int, prefix string) (storedefs.Cmd, error) {
 	return storedefs.Cmd{Text: res.Text, Seq: res.Seq}, err
 }
 
+func (c *client) AddCmdRecords(records []storedefs.CmdRecord) error {
+	req := &api.AddCmdRecordsRequest{Records: records}
+	res := &api.AddCmdRecordsResponse{}
+	err := c.call("AddCmdRecords", req, res)
+	return err
+}
+
+func (c *client) CmdRecords(from, upto int) ([]storedefs.CmdRecord, error) {
+	req := &api.CmdRecordsRequest{From: from, Upto: upto}
+	res := &api.CmdRecordsResponse{}
+	err := c.call("CmdRecords", req, res)
+	return res.Records, err
+}
+
+func (c *client) SetCmdResult(seq int, dir string, result storedefs.CmdResult) error {
+	req := &api.SetCmdResultRequest{Seq: seq, Dir: dir, Result: result}
+	res := &api.SetCmdResultResponse{}
+	err := c.call("SetCmdResult", req, res)
+	return err
+}
+
+func (c *client) SetCmdWorkspace(seq int, workspace string) error {
+	req := &api.SetCmdWorkspaceRequest{Seq: seq, Workspace: workspace}
+	res := &api.SetCmdWorkspaceResponse{}
+	err := c.call("SetCmdWorkspace", req, res)
+	return err
+}
+
+func (c *client) WorkspaceCmds(workspace string, from, upto int) ([]storedefs.Cmd, error) {
+	req := &api.WorkspaceCmdsRequest{Workspace: workspace, From: from, Upto: upto}
+	res := &api.WorkspaceCmdsResponse{}
+	err := c.call("WorkspaceCmds", req, res)
+	return res.Cmds, err
+}
+
+func (c *client) Dedup() (storedefs.Dedup, error) {
+	req := &api.DedupRequest{}
+	res := &api.DedupResponse{}
+	err := c.call("Dedup", req, res)
+	return res.Dedup, err
+}
+
+func (c *client) SetDedup(d storedefs.Dedup) error {
+	req := &api.SetDedupRequest{Dedup: d}
+	res := &api.SetDedupResponse{}
+	err := c.call("SetDedup", req, res)
+	return err
+}
+
+func (c *client) Retention() (storedefs.Retention, error) {
+	req := &api.RetentionRequest{}
+	res := &api.RetentionResponse{}
+	err := c.call("Retention", req, res)
+	return res.Retention, err
+}
+
+func (c *client) SetRetention(r storedefs.Retention) error {
+	req := &api.SetRetentionRequest{Retention: r}
+	res := &api.SetRetentionResponse{}
+	err := c.call("SetRetention", req, res)
+	return err
+}
+
+func (c *client) Prune(f storedefs.PruneFilter) (int, error) {
+	req := &api.PruneRequest{Filter: f}
+	res := &api.PruneResponse{}
+	err := c.call("Prune", req, res)
+	return res.N, err
+}
+
+func (c *client) IgnorePatterns() ([]string, error) {
+	req := &api.IgnorePatternsRequest{}
+	res := &api.IgnorePatternsResponse{}
+	err := c.call("IgnorePatterns", req, res)
+	return res.Patterns, err
+}
+
+func (c *client) SetIgnorePatterns(patterns []string) error {
+	req := &api.SetIgnorePatternsRequest{Patterns: patterns}
+	res := &api.SetIgnorePatternsResponse{}
+	err := c.call("SetIgnorePatterns", req, res)
+	return err
+}
+
+func (c *client) Compact() (storedefs.CompactReport, error) {
+	req := &api.CompactRequest{}
+	res := &api.CompactResponse{}
+	err := c.call("Compact", req, res)
+	return res.Report, err
+}
+
+func (c *client) Backup(path string) (int64, error) {
+	req := &api.BackupRequest{Path: path}
+	res := &api.BackupResponse{}
+	err := c.call("Backup", req, res)
+	return res.Size, err
+}
+
+func (c *client) Restore(path string) error {
+	req := &api.RestoreRequest{Path: path}
+	res := &api.RestoreResponse{}
+	err := c.call("Restore", req, res)
+	return err
+}
+
 func (c *client) AddDir(dir string, incFactor float64) error {
 	req := &api.AddDirRequest{Dir: dir, IncFactor: incFactor}
 	res := &api.AddDirResponse{}
@@ -168,3 +380,87 @@ func (c *client) Dirs(blacklist map[string]struct{}) ([]storedefs.Dir, error) {
 	err := c.call("Dirs", req, res)
 	return res.Dirs, err
 }
+
+func (c *client) DirScoring() (storedefs.DirScoring, error) {
+	req := &api.DirScoringRequest{}
+	res := &api.DirScoringResponse{}
+	err := c.call("DirScoring", req, res)
+	return res.Scoring, err
+}
+
+func (c *client) SetDirScoring(s storedefs.DirScoring) error {
+	req := &api.SetDirScoringRequest{Scoring: s}
+	res := &api.SetDirScoringResponse{}
+	err := c.call("SetDirScoring", req, res)
+	return err
+}
+
+func (c *client) PinDir(dir string) error {
+	req := &api.PinDirRequest{Dir: dir}
+	res := &api.PinDirResponse{}
+	err := c.call("PinDir", req, res)
+	return err
+}
+
+func (c *client) UnpinDir(dir string) error {
+	req := &api.UnpinDirRequest{Dir: dir}
+	res := &api.UnpinDirResponse{}
+	err := c.call("UnpinDir", req, res)
+	return err
+}
+
+func (c *client) PinnedDirs() ([]string, error) {
+	req := &api.PinnedDirsRequest{}
+	res := &api.PinnedDirsResponse{}
+	err := c.call("PinnedDirs", req, res)
+	return res.Dirs, err
+}
+
+func (c *client) NewSession(name string, cfg daemondefs.SessionConfig) error {
+	req := &api.NewSessionRequest{Name: name, Config: cfg}
+	res := &api.NewSessionResponse{}
+	err := c.call("NewSession", req, res)
+	return err
+}
+
+func (c *client) Sessions() ([]daemondefs.Session, error) {
+	req := &api.SessionsRequest{}
+	res := &api.SessionsResponse{}
+	err := c.call("Sessions", req, res)
+	return res.Sessions, err
+}
+
+func (c *client) ReadSession(name string, offset int) ([]byte, int, bool, error) {
+	req := &api.ReadSessionRequest{Name: name, Offset: offset}
+	res := &api.ReadSessionResponse{}
+	err := c.call("ReadSession", req, res)
+	return res.Data, res.Next, res.Exited, err
+}
+
+func (c *client) WriteSession(name string, data []byte) error {
+	req := &api.WriteSessionRequest{Name: name, Data: data}
+	res := &api.WriteSessionResponse{}
+	err := c.call("WriteSession", req, res)
+	return err
+}
+
+func (c *client) ResizeSession(name string, rows, cols int) error {
+	req := &api.ResizeSessionRequest{Name: name, Rows: rows, Cols: cols}
+	res := &api.ResizeSessionResponse{}
+	err := c.call("ResizeSession", req, res)
+	return err
+}
+
+func (c *client) KillSession(name string) error {
+	req := &api.KillSessionRequest{Name: name}
+	res := &api.KillSessionResponse{}
+	err := c.call("KillSession", req, res)
+	return err
+}
+
+func (c *client) LatestVersion(feed string) (string, error) {
+	req := &api.LatestVersionRequest{Feed: feed}
+	res := &api.LatestVersionResponse{}
+	err := c.call("LatestVersion", req, res)
+	return res.Version, err
+}
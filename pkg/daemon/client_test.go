@@ -0,0 +1,48 @@
+package daemon
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"src.elv.sh/pkg/daemon/internal/api"
+	"src.elv.sh/pkg/rpc"
+)
+
+// A daemon from an older version of Elvish that only supports some methods.
+type oldService struct{}
+
+func (oldService) Version(req *api.VersionRequest, res *api.VersionResponse) error {
+	res.Version = api.Version
+	return nil
+}
+
+func (oldService) Methods(req *api.MethodsRequest, res *api.MethodsResponse) error {
+	res.Methods = []string{"Methods", "NextCmdSeq", "Version"}
+	return nil
+}
+
+func (oldService) NextCmdSeq(req *api.NextCmdRequest, res *api.NextCmdSeqResponse) error {
+	res.Seq = 10
+	return nil
+}
+
+func TestClient_FailsCallsToUnsupportedMethods(t *testing.T) {
+	server := rpc.NewServer()
+	server.RegisterName(api.ServiceName, oldService{})
+	cl := newClient("old", func() (net.Conn, error) {
+		serverConn, clientConn := net.Pipe()
+		go server.ServeConn(serverConn)
+		return clientConn, nil
+	})
+	defer cl.Close()
+
+	if seq, err := cl.NextCmdSeq(); seq != 10 || err != nil {
+		t.Errorf("NextCmdSeq() -> (%v, %v), want (10, nil)", seq, err)
+	}
+	_, err := cl.AddCmd("echo foo")
+	var unsupported UnsupportedError
+	if !errors.As(err, &unsupported) || unsupported.Method != "AddCmd" {
+		t.Errorf("AddCmd() -> error %v, want UnsupportedError for AddCmd", err)
+	}
+}
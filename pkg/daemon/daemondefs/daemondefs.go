@@ -5,7 +5,9 @@
 package daemondefs
 
 import (
+	"fmt"
 	"io"
+	"time"
 
 	"src.elv.sh/pkg/store/storedefs"
 )
@@ -20,6 +22,68 @@ type Client interface {
 	Pid() (int, error)
 	SockPath() string
 	Version() (int, error)
+
+	NewSession(name string, cfg SessionConfig) error
+	Sessions() ([]Session, error)
+	// ReadSession returns the output of a session from the given offset, and
+	// the offset after the end of the output. It waits for a while if there is
+	// no output yet. If the output before the offset has been discarded, it
+	// starts from the earliest output kept.
+	ReadSession(name string, offset int) (data []byte, next int, exited bool, err error)
+	WriteSession(name string, data []byte) error
+	ResizeSession(name string, rows, cols int) error
+	KillSession(name string) error
+
+	// LatestVersion returns the latest version of Elvish from a release feed.
+	// The daemon fetches each feed at most once a day, and uses the cached
+	// result otherwise.
+	LatestVersion(feed string) (string, error)
+
+	// Stats returns statistics of the daemon.
+	Stats() (Stats, error)
+	// Compact checks the integrity of the database and compacts it.
+	Compact() (storedefs.CompactReport, error)
+	// Backup writes a consistent copy of the database to an absolute path,
+	// returning the size of the copy.
+	Backup(path string) (int64, error)
+	// Restore replaces the database with a backup at an absolute path.
+	Restore(path string) error
+}
+
+// SessionConfig keeps configurations for starting a session in the daemon.
+type SessionConfig struct {
+	// Working directory and environment variables of the session.
+	Dir string
+	Env []string
+	// Size of the terminal.
+	Rows, Cols int
+}
+
+// Session represents a detachable session hosted by the daemon.
+type Session struct {
+	Name   string
+	Pid    int
+	Exited bool
+}
+
+// Stats contains statistics of the daemon.
+type Stats struct {
+	// Time since the daemon started.
+	Uptime time.Duration
+	// Number of connected clients.
+	Clients int
+	// Size of the database file in bytes, or -1 if it is unknown.
+	StoreSize int64
+	// Statistics of the RPC methods that have been called, sorted by name.
+	Calls []CallStats
+}
+
+// CallStats contains statistics of the calls to an RPC method.
+type CallStats struct {
+	Method string
+	Count  int
+	// Total and maximum time spent in the method.
+	Total, Max time.Duration
 }
 
 // ActivateFunc is a function that activates a daemon client, possibly by
@@ -35,4 +99,54 @@ type SpawnConfig struct {
 	SockPath string
 	// RunDir is the directory in which to place the daemon log file.
 	RunDir string
+	// If positive, passed to the daemon as -daemon-idle-timeout.
+	IdleTimeout time.Duration
+	// If not empty, the daemon is spawned with -db-encrypt, and the passphrase
+	// is passed in $ELVISH_DB_PASSPHRASE.
+	Passphrase string
+	// If positive, overrides the default total time to wait for the daemon to
+	// come up after spawning it, retrying with exponential backoff.
+	SpawnTimeout time.Duration
+	// If true, write a message to stderr before each retry.
+	Verbose bool
+	// If not nil, connect to a daemon listening on TCP instead of the socket,
+	// and never spawn a daemon.
+	Remote *RemoteConfig
+	// If not nil, the ActivateFunc may return a client before the daemon is
+	// ready, and connect to it in the background; requests made with the
+	// client wait for the connection. The function is called with the error
+	// of connecting, or nil, when it finishes.
+	Activated func(error)
+}
+
+// SpawnTimeoutError is returned when a spawned daemon doesn't come up within
+// the spawn timeout.
+type SpawnTimeoutError struct {
+	Timeout time.Duration
+	// Number of attempts to connect to the daemon.
+	Attempts int
+	// The error of the last attempt.
+	Err error
+}
+
+func (e *SpawnTimeoutError) Error() string {
+	return fmt.Sprintf("daemon did not come up within %v after %d attempts: %v",
+		e.Timeout, e.Attempts, e.Err)
+}
+
+func (e *SpawnTimeoutError) Unwrap() error { return e.Err }
+
+// RemoteConfig keeps configurations for connecting to a daemon listening on
+// TCP.
+type RemoteConfig struct {
+	// Address of the daemon, like "example.com:7788".
+	Addr string
+	// Token to authenticate with, if the daemon requires one.
+	Token string
+	// File containing the CA certificates used to verify the daemon's
+	// certificate, in PEM. If empty, the system's CA certificates are used.
+	CAFile string
+	// Files containing the client certificate and its key, in PEM, if the
+	// daemon requires client certificates.
+	CertFile, KeyFile string
 }
@@ -0,0 +1,153 @@
+//go:build unix
+
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"src.elv.sh/pkg/env"
+)
+
+// How long to wait for the old daemon to send its listening socket. Can be
+// overridden in tests.
+var handoffTimeout = time.Second
+
+// The file descriptor of the listening socket passed to the new daemon.
+const handoffFD = 3
+
+// Returns the listening socket handed off by the old daemon, or nil if there
+// is none. The new daemon is spawned with $ELVISH_DAEMON_HANDOFF_FD set to the
+// file descriptor of the socket.
+func handoffListener() (net.Listener, error) {
+	fd := os.Getenv(env.ELVISH_DAEMON_HANDOFF_FD)
+	if fd == "" {
+		return nil, nil
+	}
+	// Don't pass the variable on to child processes.
+	os.Unsetenv(env.ELVISH_DAEMON_HANDOFF_FD)
+	n, err := strconv.Atoi(fd)
+	if err != nil || n < 3 {
+		return nil, fmt.Errorf("invalid $%s: %q", env.ELVISH_DAEMON_HANDOFF_FD, fd)
+	}
+	unix.CloseOnExec(n)
+	f := os.NewFile(uintptr(n), "handoff")
+	// FileListener duplicates the file descriptor, so f can be closed.
+	defer f.Close()
+	return net.FileListener(f)
+}
+
+// Returns the environment variable to pass the handoff socket to the new
+// daemon, which is passed as the fourth file.
+func handoffEnv() string {
+	return env.ELVISH_DAEMON_HANDOFF_FD + "=" + strconv.Itoa(handoffFD)
+}
+
+// Returns a duplicate of the listening socket to hand off. Closing the
+// listener after calling this function doesn't remove the socket file.
+func listenerFile(l net.Listener) (*os.File, error) {
+	ul, ok := l.(*net.UnixListener)
+	if !ok {
+		return nil, fmt.Errorf("cannot hand off %T", l)
+	}
+	ul.SetUnlinkOnClose(false)
+	return ul.File()
+}
+
+// Sends the listening socket to the Unix socket at path.
+func sendListener(path string, f *os.File) error {
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(handoffTimeout))
+	_, _, err = conn.WriteMsgUnix([]byte{0}, unix.UnixRights(int(f.Fd())), nil)
+	return err
+}
+
+// Receives the listening socket from the old daemon.
+type handoffReceiver struct {
+	path string
+	l    *net.UnixListener
+	ch   chan *os.File
+}
+
+// Starts receiving the listening socket on a Unix socket in dir.
+func newHandoffReceiver(dir string) (*handoffReceiver, error) {
+	path, err := filepath.Abs(filepath.Join(dir, "handoff-"+strconv.Itoa(os.Getpid())))
+	if err != nil {
+		return nil, err
+	}
+	// A file left by a process with the same pid that was terminated
+	// abnormally.
+	os.Remove(path)
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	r := &handoffReceiver{path, l, make(chan *os.File, 1)}
+	go func() {
+		f, err := r.accept()
+		if err != nil {
+			logger.Println("failed to receive socket from old daemon:", err)
+		}
+		r.ch <- f
+	}()
+	return r, nil
+}
+
+func (r *handoffReceiver) accept() (*os.File, error) {
+	conn, err := r.l.AcceptUnix()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	buf := make([]byte, 1)
+	oob := make([]byte, unix.CmsgSpace(4))
+	_, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) != 1 {
+		return nil, fmt.Errorf("got %d control messages, want 1", len(msgs))
+	}
+	fds, err := unix.ParseUnixRights(&msgs[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(fds) != 1 {
+		for _, fd := range fds {
+			unix.Close(fd)
+		}
+		return nil, fmt.Errorf("got %d file descriptors, want 1", len(fds))
+	}
+	return os.NewFile(uintptr(fds[0]), "handoff"), nil
+}
+
+// Returns the received socket. The old daemon sends it before responding to
+// the Shutdown RPC, so this should only be called after that.
+func (r *handoffReceiver) receive() (*os.File, error) {
+	select {
+	case f := <-r.ch:
+		if f == nil {
+			return nil, errors.New("failed to receive socket from old daemon")
+		}
+		return f, nil
+	case <-time.After(handoffTimeout):
+		return nil, fmt.Errorf("old daemon did not send socket within %v", handoffTimeout)
+	}
+}
+
+// Stops receiving and removes the Unix socket.
+func (r *handoffReceiver) close() { r.l.Close() }
@@ -3,11 +3,19 @@
 package api
 
 import (
+	"src.elv.sh/pkg/daemon/daemondefs"
 	"src.elv.sh/pkg/store/storedefs"
 )
 
-// Version is the API version. It should be bumped any time the API changes.
-const Version = -93
+// Version is the API version. It should be bumped, towards 0, any time the API
+// changes in a way that is incompatible with older clients or daemons, like
+// changing the meaning of an existing method. Clients restart daemons with an
+// older version.
+//
+// Adding methods doesn't require bumping the version. Clients ask the daemon
+// for the methods it supports with the Methods RPC, and fail calls to other
+// methods with an error.
+const Version = -92
 
 // ServiceName is the name of the RPC service exposed by the daemon.
 const ServiceName = "Daemon"
@@ -20,12 +28,45 @@ type VersionResponse struct {
 	Version int
 }
 
+type MethodsRequest struct{}
+
+type MethodsResponse struct {
+	// Names of the methods supported by the daemon, without the service name.
+	Methods []string
+}
+
 type PidRequest struct{}
 
 type PidResponse struct {
 	Pid int
 }
 
+type ManagedRequest struct{}
+
+type ManagedResponse struct {
+	// Whether the daemon's socket is managed by a service manager like
+	// systemd, instead of the daemon itself.
+	Managed bool
+}
+
+type StatsRequest struct{}
+
+type StatsResponse struct {
+	Stats daemondefs.Stats
+}
+
+type ShutdownRequest struct {
+	// If not empty, the path of a Unix socket to which the daemon sends its
+	// listening socket, so that a new daemon can take it over.
+	Handoff string
+}
+
+type ShutdownResponse struct {
+	// Whether the listening socket was sent to Handoff. If false, the daemon
+	// has removed the socket file.
+	HandedOff bool
+}
+
 // Cmd requests.
 
 type NextCmdSeqRequest struct{}
@@ -95,6 +136,114 @@ type PrevCmdResponse struct {
 	Text string
 }
 
+type AddCmdRecordsRequest struct {
+	Records []storedefs.CmdRecord
+}
+
+type AddCmdRecordsResponse struct{}
+
+type CmdRecordsRequest struct {
+	From int
+	Upto int
+}
+
+type CmdRecordsResponse struct {
+	Records []storedefs.CmdRecord
+}
+
+type SetCmdResultRequest struct {
+	Seq    int
+	Dir    string
+	Result storedefs.CmdResult
+}
+
+type SetCmdResultResponse struct{}
+
+type SetCmdWorkspaceRequest struct {
+	Seq       int
+	Workspace string
+}
+
+type SetCmdWorkspaceResponse struct{}
+
+type WorkspaceCmdsRequest struct {
+	Workspace string
+	From      int
+	Upto      int
+}
+
+type WorkspaceCmdsResponse struct {
+	Cmds []storedefs.Cmd
+}
+
+type DedupRequest struct{}
+
+type DedupResponse struct {
+	Dedup storedefs.Dedup
+}
+
+type SetDedupRequest struct {
+	Dedup storedefs.Dedup
+}
+
+type SetDedupResponse struct{}
+
+type RetentionRequest struct{}
+
+type RetentionResponse struct {
+	Retention storedefs.Retention
+}
+
+type SetRetentionRequest struct {
+	Retention storedefs.Retention
+}
+
+type SetRetentionResponse struct{}
+
+type PruneRequest struct {
+	Filter storedefs.PruneFilter
+}
+
+type PruneResponse struct {
+	N int
+}
+
+type IgnorePatternsRequest struct{}
+
+type IgnorePatternsResponse struct {
+	Patterns []string
+}
+
+type SetIgnorePatternsRequest struct {
+	Patterns []string
+}
+
+type SetIgnorePatternsResponse struct{}
+
+// Compact requests.
+
+type CompactRequest struct{}
+
+type CompactResponse struct {
+	Report storedefs.CompactReport
+}
+
+// Backup requests.
+
+type BackupRequest struct {
+	Path string
+}
+
+type BackupResponse struct {
+	Size int64
+}
+
+type RestoreRequest struct {
+	Path string
+}
+
+type RestoreResponse struct{}
+
 // Dir requests.
 
 type AddDirRequest struct {
@@ -117,3 +266,89 @@ type DirsRequest struct {
 type DirsResponse struct {
 	Dirs []storedefs.Dir
 }
+
+type DirScoringRequest struct{}
+
+type DirScoringResponse struct {
+	Scoring storedefs.DirScoring
+}
+
+type SetDirScoringRequest struct {
+	Scoring storedefs.DirScoring
+}
+
+type SetDirScoringResponse struct{}
+
+type PinDirRequest struct {
+	Dir string
+}
+
+type PinDirResponse struct{}
+
+type UnpinDirRequest struct {
+	Dir string
+}
+
+type UnpinDirResponse struct{}
+
+type PinnedDirsRequest struct{}
+
+type PinnedDirsResponse struct {
+	Dirs []string
+}
+
+// Session requests.
+
+type NewSessionRequest struct {
+	Name   string
+	Config daemondefs.SessionConfig
+}
+
+type NewSessionResponse struct{}
+
+type SessionsRequest struct{}
+
+type SessionsResponse struct {
+	Sessions []daemondefs.Session
+}
+
+type ReadSessionRequest struct {
+	Name   string
+	Offset int
+}
+
+type ReadSessionResponse struct {
+	Data   []byte
+	Next   int
+	Exited bool
+}
+
+type WriteSessionRequest struct {
+	Name string
+	Data []byte
+}
+
+type WriteSessionResponse struct{}
+
+type ResizeSessionRequest struct {
+	Name       string
+	Rows, Cols int
+}
+
+type ResizeSessionResponse struct{}
+
+type KillSessionRequest struct {
+	Name string
+}
+
+type KillSessionResponse struct{}
+
+// Update check requests.
+
+type LatestVersionRequest struct {
+	Feed string
+}
+
+type LatestVersionResponse struct {
+	Version string
+}
@@ -0,0 +1,229 @@
+package daemon
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// On Windows, the daemon listens on a named pipe. Named pipes don't live in the
+// file system, so the daemon also creates a regular file at the socket path,
+// and the pipe name is derived from the path. This way, detecting and removing
+// stale sockets works the same way as on Unix.
+
+const (
+	pipeBufferSize = 64 * 1024
+	// How many times and how long to wait when all instances of the pipe are
+	// busy, which happens briefly when the daemon is accepting a connection.
+	pipeBusyRetries = 50
+	pipeBusyWait    = 10 * time.Millisecond
+)
+
+var errConnRefused = windows.ERROR_FILE_NOT_FOUND
+
+// Returns the name of the named pipe for the socket path.
+func pipeName(sockPath string) (string, error) {
+	abs, err := filepath.Abs(sockPath)
+	if err != nil {
+		return "", err
+	}
+	// Paths on Windows are case-insensitive.
+	sum := sha256.Sum256([]byte(strings.ToLower(abs)))
+	return `\\.\pipe\elvish-` + hex.EncodeToString(sum[:8]), nil
+}
+
+func listen(sockPath string) (net.Listener, error) {
+	name, err := pipeName(sockPath)
+	if err != nil {
+		return nil, err
+	}
+	sa, err := currentUserOnly()
+	if err != nil {
+		return nil, err
+	}
+	// Like a Unix socket, fail if the file already exists.
+	f, err := os.OpenFile(sockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	_, err = f.WriteString(name)
+	f.Close()
+	if err != nil {
+		os.Remove(sockPath)
+		return nil, err
+	}
+	l := &pipeListener{sockPath: sockPath, name: name, sa: sa}
+	l.handle, err = l.newInstance(true)
+	if err != nil {
+		os.Remove(sockPath)
+		return nil, &net.OpError{Op: "listen", Net: "pipe", Addr: pipeAddr(name), Err: err}
+	}
+	return l, nil
+}
+
+func dial(sockPath string) (net.Conn, error) {
+	name, err := pipeName(sockPath)
+	if err != nil {
+		return nil, err
+	}
+	return dialPipe(name)
+}
+
+func dialPipe(name string) (net.Conn, error) {
+	name16, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; ; i++ {
+		h, err := windows.CreateFile(name16,
+			windows.GENERIC_READ|windows.GENERIC_WRITE, 0, nil, windows.OPEN_EXISTING, 0, 0)
+		if err == nil {
+			return newPipeConn(h, name, false), nil
+		}
+		if err == windows.ERROR_PIPE_BUSY && i < pipeBusyRetries {
+			time.Sleep(pipeBusyWait)
+			continue
+		}
+		return nil, &net.OpError{Op: "dial", Net: "pipe", Addr: pipeAddr(name),
+			Err: os.NewSyscallError("CreateFile", err)}
+	}
+}
+
+// Returns security attributes that only allow access from the current user.
+func currentUserOnly() (*windows.SecurityAttributes, error) {
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return nil, err
+	}
+	sd, err := windows.SecurityDescriptorFromString(
+		"D:P(A;;GA;;;" + user.User.Sid.String() + ")")
+	if err != nil {
+		return nil, err
+	}
+	return &windows.SecurityAttributes{
+		Length:             uint32(unsafe.Sizeof(windows.SecurityAttributes{})),
+		SecurityDescriptor: sd,
+	}, nil
+}
+
+type pipeAddr string
+
+func (a pipeAddr) Network() string { return "pipe" }
+func (a pipeAddr) String() string  { return string(a) }
+
+type pipeListener struct {
+	sockPath string
+	name     string
+	sa       *windows.SecurityAttributes
+
+	mu sync.Mutex
+	// The pipe instance waiting for the next connection.
+	handle    windows.Handle
+	accepting bool
+	closed    bool
+}
+
+func (l *pipeListener) newInstance(first bool) (windows.Handle, error) {
+	name16, err := windows.UTF16PtrFromString(l.name)
+	if err != nil {
+		return windows.InvalidHandle, err
+	}
+	flags := uint32(windows.PIPE_ACCESS_DUPLEX)
+	if first {
+		// Fail if another process has created the pipe.
+		flags |= windows.FILE_FLAG_FIRST_PIPE_INSTANCE
+	}
+	return windows.CreateNamedPipe(name16, flags,
+		windows.PIPE_TYPE_BYTE|windows.PIPE_READMODE_BYTE|windows.PIPE_WAIT|
+			windows.PIPE_REJECT_REMOTE_CLIENTS,
+		windows.PIPE_UNLIMITED_INSTANCES, pipeBufferSize, pipeBufferSize, 0, l.sa)
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil, net.ErrClosed
+	}
+	h := l.handle
+	l.accepting = true
+	l.mu.Unlock()
+
+	err := windows.ConnectNamedPipe(h, nil)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.accepting = false
+	if l.closed {
+		windows.CloseHandle(h)
+		return nil, net.ErrClosed
+	}
+	next, nextErr := l.newInstance(false)
+	if nextErr != nil {
+		windows.CloseHandle(h)
+		l.closed = true
+		return nil, &net.OpError{Op: "accept", Net: "pipe", Addr: l.Addr(), Err: nextErr}
+	}
+	l.handle = next
+	if err != nil && err != windows.ERROR_PIPE_CONNECTED {
+		// The client went away before the connection was established.
+		windows.CloseHandle(h)
+		return nil, &net.OpError{Op: "accept", Net: "pipe", Addr: l.Addr(), Err: err}
+	}
+	return newPipeConn(h, l.name, true), nil
+}
+
+func (l *pipeListener) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	accepting := l.accepting
+	l.mu.Unlock()
+	if accepting {
+		// Unblock Accept by connecting to the instance it is waiting on; it
+		// will close the instance.
+		if conn, err := dialPipe(l.name); err == nil {
+			conn.Close()
+		}
+	} else {
+		windows.CloseHandle(l.handle)
+	}
+	os.Remove(l.sockPath)
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr { return pipeAddr(l.name) }
+
+type pipeConn struct {
+	*os.File
+	handle windows.Handle
+	server bool
+}
+
+func newPipeConn(h windows.Handle, name string, server bool) *pipeConn {
+	return &pipeConn{os.NewFile(uintptr(h), name), h, server}
+}
+
+func (c *pipeConn) Close() error {
+	// Cancel any pending read or write, which would otherwise keep closing the
+	// handle blocked.
+	windows.CancelIoEx(c.handle, nil)
+	if c.server {
+		windows.DisconnectNamedPipe(c.handle)
+	}
+	return c.File.Close()
+}
+
+func (c *pipeConn) LocalAddr() net.Addr  { return pipeAddr(c.File.Name()) }
+func (c *pipeConn) RemoteAddr() net.Addr { return pipeAddr(c.File.Name()) }
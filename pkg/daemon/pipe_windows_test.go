@@ -0,0 +1,74 @@
+package daemon
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestPipeName(t *testing.T) {
+	testutil.InTempDir(t)
+	a, _ := pipeName("sock")
+	b, _ := pipeName("SOCK")
+	c, _ := pipeName("other")
+	if !strings.HasPrefix(a, `\\.\pipe\elvish-`) {
+		t.Errorf("got pipe name %q, want prefix elvish-", a)
+	}
+	if a != b {
+		t.Errorf("pipe names differ for paths differing only in case: %q, %q", a, b)
+	}
+	if a == c {
+		t.Errorf("pipe names are the same for different paths: %q", a)
+	}
+}
+
+func TestPipe_ListenAndDial(t *testing.T) {
+	testutil.InTempDir(t)
+	if _, err := dial("sock"); !errors.Is(err, errConnRefused) {
+		t.Errorf("dial before listen returns %v, want errConnRefused", err)
+	}
+
+	l, err := listen("sock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat("sock"); err != nil {
+		t.Errorf("socket file not created: %v", err)
+	}
+	if _, err := listen("sock"); err == nil {
+		t.Errorf("listen on existing socket file succeeded")
+	}
+
+	accepted := make(chan string)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			t.Error(err)
+			close(accepted)
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 5)
+		io.ReadFull(conn, buf)
+		accepted <- string(buf)
+	}()
+
+	conn, err := dial("sock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write([]byte("hello"))
+	if got := <-accepted; got != "hello" {
+		t.Errorf("server got %q, want %q", got, "hello")
+	}
+	conn.Close()
+
+	l.Close()
+	if _, err := os.Stat("sock"); !os.IsNotExist(err) {
+		t.Errorf("socket file not removed after closing listener")
+	}
+}
@@ -6,12 +6,15 @@
 package daemon
 
 import (
+	"errors"
 	"net"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"src.elv.sh/pkg/daemon/internal/api"
+	"src.elv.sh/pkg/env"
 	"src.elv.sh/pkg/logutil"
 	"src.elv.sh/pkg/prog"
 	"src.elv.sh/pkg/rpc"
@@ -24,6 +27,8 @@ var logger = logutil.GetLogger("[daemon] ")
 type Program struct {
 	run   bool
 	paths *prog.DaemonPaths
+	tcp   tcpFlags
+	idle  time.Duration
 	// Used in tests.
 	serveOpts ServeOpts
 }
@@ -32,6 +37,11 @@ func (p *Program) RegisterFlags(fs *prog.FlagSet) {
 	fs.BoolVar(&p.run, "daemon", false,
 		"[internal flag] Run the storage daemon instead of an Elvish shell")
 	p.paths = fs.DaemonPaths()
+	p.tcp.register(fs)
+	fs.DurationVar(&p.idle, "daemon-idle-timeout", 0,
+		"Exit the daemon after it has had no clients for this long; "+
+			"0 means exiting as soon as the last client disconnects, "+
+			"or never when listening on TCP")
 }
 
 func (p *Program) Run(fds [3]*os.File, args []string) error {
@@ -41,12 +51,44 @@ func (p *Program) Run(fds [3]*os.File, args []string) error {
 	if len(args) > 0 {
 		return prog.BadUsage("arguments are not allowed with -daemon")
 	}
+	tcp, err := p.tcp.config()
+	if err != nil {
+		return err
+	}
+	if p.idle < 0 {
+		return prog.BadUsage("-daemon-idle-timeout must not be negative")
+	}
+	opts := p.serveOpts
+	if p.idle > 0 {
+		opts.IdleTimeout = p.idle
+	}
+	if tcp != nil {
+		opts.TCP = tcp
+	}
+	if l, err := activationListener(); err != nil {
+		return err
+	} else if l != nil {
+		opts.Listener = l
+	}
+	if l, err := handoffListener(); err != nil {
+		return err
+	} else if l != nil {
+		opts.Handoff = l
+	}
+	if p.paths.Encrypt {
+		opts.Passphrase = os.Getenv(env.ELVISH_DB_PASSPHRASE)
+		if opts.Passphrase == "" {
+			return prog.BadUsage("-db-encrypt requires $" + env.ELVISH_DB_PASSPHRASE)
+		}
+		// Don't pass the passphrase on to sessions.
+		os.Unsetenv(env.ELVISH_DB_PASSPHRASE)
+	}
 
 	// The stdout is redirected to a unique log file (see the spawn function),
 	// so just use it for logging.
 	logutil.SetOutput(fds[1])
 	setUmaskForDaemon()
-	exit := Serve(p.paths.Sock, p.paths.DB, p.serveOpts)
+	exit := Serve(p.paths.Sock, p.paths.DB, opts)
 	return prog.Exit(exit)
 }
 
@@ -59,47 +101,176 @@ type ServeOpts struct {
 	Signals <-chan os.Signal
 	// If not nil, overrides the response of the Version RPC.
 	Version *int
+	// If not nil, overrides the command to run for each session. The default
+	// is to run the Elvish executable serving the daemon, connected to the
+	// daemon.
+	SessionCommand []string
+	// If not nil, used instead of listening on the socket. The listener is
+	// managed by a service manager, so the socket file is not removed when the
+	// daemon exits.
+	Listener net.Listener
+	// If not nil, used instead of listening on the socket. The listener is
+	// handed off by an outdated daemon; unlike Listener, the daemon owns the
+	// socket file and removes it when exiting.
+	Handoff net.Listener
+	// If not nil, also listen on TCP. The daemon then keeps running after all
+	// clients have disconnected, until it receives a signal.
+	TCP *TCPConfig
+	// If positive, the daemon exits after it has had no clients and no running
+	// sessions for this long, instead of as soon as the last client
+	// disconnects. This also applies when TCP is set.
+	IdleTimeout time.Duration
+	// If not empty, the command history in the database is encrypted with a
+	// key derived from the passphrase.
+	Passphrase string
+}
+
+// How long to wait for pending requests to finish after the Shutdown RPC. Can
+// be overridden in tests.
+var shutdownTimeout = time.Second
+
+// How often the daemon applies the retention policy of the command history,
+// in addition to when it starts. Can be overridden in tests.
+var retentionInterval = time.Hour
+
+// A connection that has been accepted and authenticated, and the server for
+// it.
+type acceptedConn struct {
+	conn   net.Conn
+	server *rpc.Server
+}
+
+var errCannotHandoff = errors.New("cannot hand off socket of a daemon listening on TCP or using socket activation")
+
+// A request sent from the Shutdown RPC to Serve.
+type shutdownRequest struct {
+	handoff string
+	done    chan<- shutdownResult
+}
+
+type shutdownResult struct {
+	handedOff bool
+	err       error
 }
 
 // Serve runs the daemon service, listening on the socket specified by sockpath
 // and serving data from dbpath until all clients have exited. See doc for
 // ServeOpts for additional options.
 func Serve(sockpath, dbpath string, opts ServeOpts) int {
+	start := time.Now()
 	logger.Println("pid is", syscall.Getpid())
-	logger.Println("going to listen", sockpath)
-	listener, err := net.Listen("unix", sockpath)
-	if err != nil {
-		logger.Printf("failed to listen on %s: %v", sockpath, err)
-		logger.Println("aborting")
-		return 2
+	managed := opts.Listener != nil
+	listener := opts.Listener
+	if managed {
+		logger.Println("using socket from socket activation", listener.Addr())
+	} else if opts.Handoff != nil {
+		logger.Println("using socket handed off by old daemon", sockpath)
+		listener = opts.Handoff
+	} else {
+		logger.Println("going to listen", sockpath)
+		var err error
+		listener, err = listen(sockpath)
+		if err != nil {
+			logger.Printf("failed to listen on %s: %v", sockpath, err)
+			logger.Println("aborting")
+			return 2
+		}
+	}
+	listeners := []net.Listener{listener}
+	// Authenticates connections accepted by each listener.
+	auths := []func(net.Conn) error{nil}
+	// Serves the connections accepted by each listener.
+	server := rpc.NewServer()
+	servers := []*rpc.Server{server}
+	if opts.TCP != nil {
+		logger.Println("going to listen", opts.TCP.Addr)
+		tcpListener, err := listenTCP(opts.TCP)
+		if err != nil {
+			logger.Printf("failed to listen on %s: %v", opts.TCP.Addr, err)
+			logger.Println("aborting")
+			listener.Close()
+			return 2
+		}
+		listeners = append(listeners, tcpListener)
+		token := opts.TCP.Token
+		auths = append(auths, func(conn net.Conn) error { return authenticate(conn, token) })
+		servers = append(servers, rpc.NewServer())
 	}
 
-	st, err := store.NewStore(dbpath)
+	var st store.DBStore
+	var err error
+	if opts.Passphrase != "" {
+		st, err = store.NewEncryptedStore(dbpath, opts.Passphrase)
+	} else {
+		st, err = store.NewStore(dbpath)
+	}
 	if err != nil {
 		logger.Printf("failed to create storage: %v", err)
 		logger.Printf("serving anyway")
+	} else {
+		applyRetention(st)
+	}
+	var retentionCh <-chan time.Time
+	if err == nil {
+		retentionTicker := time.NewTicker(retentionInterval)
+		defer retentionTicker.Stop()
+		retentionCh = retentionTicker.C
 	}
 
-	server := rpc.NewServer()
 	version := api.Version
 	if opts.Version != nil {
 		version = *opts.Version
 	}
-	server.RegisterName(api.ServiceName, &service{version, st, err})
+	sessionCmd := opts.SessionCommand
+	if sessionCmd == nil {
+		exe, err := os.Executable()
+		if err != nil {
+			exe = "elvish"
+		}
+		sessionCmd = []string{exe, "-sock", sockpath, "-db", dbpath}
+	}
+	sessions := newSessions(sessionCmd)
+	shutdownCh := make(chan shutdownRequest)
+	stats := &serverStats{start: start, dbPath: dbpath, servers: servers}
+	svc := service{
+		version: version, store: st, err: err, sessions: sessions,
+		updates: newUpdateChecker(st), managed: managed,
+		shutdown: shutdownCh, stats: stats}
+	server.RegisterName(api.ServiceName, &svc)
+	if len(servers) > 1 {
+		remoteSvc := svc
+		remoteSvc.remote = true
+		servers[1].RegisterName(api.ServiceName, &remoteSvc)
+	}
 
-	connCh := make(chan net.Conn, 10)
-	listenErrCh := make(chan error, 1)
-	go func() {
-		for {
-			conn, err := listener.Accept()
-			if err != nil {
-				listenErrCh <- err
-				close(listenErrCh)
-				return
+	connCh := make(chan acceptedConn, 10)
+	listenErrCh := make(chan error, len(listeners))
+	for i, listener := range listeners {
+		auth, server := auths[i], servers[i]
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					listenErrCh <- err
+					return
+				}
+				if auth == nil {
+					connCh <- acceptedConn{conn, server}
+					continue
+				}
+				go func() {
+					if err := auth(conn); err != nil {
+						logger.Printf("failed to authenticate %v: %v", conn.RemoteAddr(), err)
+						conn.Close()
+						return
+					}
+					connCh <- acceptedConn{conn, server}
+				}()
 			}
-			connCh <- conn
-		}
-	}()
+		}()
+	}
+	persistent := opts.TCP != nil
+	listening := len(listeners)
 
 	sigCh := opts.Signals
 	if sigCh == nil {
@@ -110,6 +281,19 @@ func Serve(sockpath, dbpath string, opts ServeOpts) int {
 
 	conns := make(map[net.Conn]struct{})
 	connDoneCh := make(chan net.Conn, 10)
+	// Waits until there are at most n connections.
+	waitConns := func(n int) {
+		timeout := time.After(shutdownTimeout)
+		for len(conns) > n {
+			select {
+			case conn := <-connDoneCh:
+				delete(conns, conn)
+			case <-timeout:
+				logger.Printf("timed out waiting for %d connections", len(conns)-n)
+				return
+			}
+		}
+	}
 
 	interrupt := func() {
 		if len(conns) == 0 {
@@ -123,10 +307,41 @@ func Serve(sockpath, dbpath string, opts ServeOpts) int {
 		}
 	}
 
+	// When the daemon has no clients and no running sessions, it is idle. If
+	// IdleTimeout is positive, idleCh fires when the daemon has been idle for
+	// that long.
+	var idleTimer *time.Timer
+	var idleCh <-chan time.Time
+	stopIdleTimer := func() {
+		if idleTimer != nil {
+			idleTimer.Stop()
+			idleTimer, idleCh = nil, nil
+		}
+	}
+	// Called when the daemon may have become idle. Returns whether the daemon
+	// should exit right away.
+	checkIdle := func() bool {
+		if len(conns) > 0 || sessions.running() > 0 {
+			return false
+		}
+		if opts.IdleTimeout > 0 {
+			if idleTimer == nil {
+				idleTimer = time.NewTimer(opts.IdleTimeout)
+				idleCh = idleTimer.C
+			}
+			return false
+		}
+		return !persistent
+	}
+	// A daemon that no client ever connects to is also idle.
+	checkIdle()
+
 	if opts.Ready != nil {
 		close(opts.Ready)
 	}
 
+	// Set when the daemon exits because of the Shutdown RPC.
+	var shutdown *shutdownRequest
 loop:
 	for {
 		select {
@@ -136,29 +351,93 @@ loop:
 			break loop
 		case err := <-listenErrCh:
 			logger.Println("could not listen:", err)
+			listening--
+			if listening > 0 {
+				continue
+			}
 			if len(conns) == 0 {
 				logger.Println("exiting since there are no clients")
 				break loop
 			}
 			logger.Println("continuing to serve until all existing clients exit")
-		case conn := <-connCh:
-			conns[conn] = struct{}{}
+		case ac := <-connCh:
+			stopIdleTimer()
+			conns[ac.conn] = struct{}{}
+			stats.clients.Store(int64(len(conns)))
 			go func() {
-				server.ServeConn(conn)
-				connDoneCh <- conn
+				ac.server.ServeConn(ac.conn)
+				connDoneCh <- ac.conn
 			}()
 		case conn := <-connDoneCh:
 			delete(conns, conn)
-			if len(conns) == 0 {
+			stats.clients.Store(int64(len(conns)))
+			if checkIdle() {
 				logger.Println("all clients disconnected, exiting")
 				break loop
 			}
+		case <-sessions.exitCh:
+			if checkIdle() {
+				logger.Println("all clients disconnected and sessions exited, exiting")
+				break loop
+			}
+		case <-retentionCh:
+			applyRetention(st)
+		case <-idleCh:
+			idleTimer, idleCh = nil, nil
+			logger.Printf("no clients for %v, exiting", opts.IdleTimeout)
+			break loop
+		case req := <-shutdownCh:
+			if req.handoff != "" && (persistent || managed) {
+				req.done <- shutdownResult{err: errCannotHandoff}
+				continue
+			}
+			logger.Println("shutting down as requested")
+			shutdown = &req
+			break loop
 		}
 	}
 
-	err = os.Remove(sockpath)
-	if err != nil {
-		logger.Printf("failed to remove socket %s: %v", sockpath, err)
+	stoppedListening := false
+	var handoff *os.File
+	if shutdown != nil {
+		if shutdown.handoff != "" {
+			handoff, err = listenerFile(listener)
+			if err != nil {
+				logger.Println("cannot hand off socket:", err)
+			}
+		}
+		closeListeners(listeners)
+		stoppedListening = true
+	drainPending:
+		for {
+			select {
+			case ac := <-connCh:
+				ac.conn.Close()
+			default:
+				break drainPending
+			}
+		}
+		// Stop reading requests from the clients, and wait for the pending
+		// ones to finish. The connection of the Shutdown RPC itself remains
+		// until its response is sent at the end.
+		for conn := range conns {
+			if c, ok := conn.(interface{ CloseRead() error }); ok {
+				c.CloseRead()
+			} else {
+				conn.Close()
+			}
+		}
+		waitConns(1)
+	}
+
+	sessions.killAll()
+
+	// When handing off the socket, it will be used by the new daemon.
+	if !managed && handoff == nil {
+		err = os.Remove(sockpath)
+		if err != nil {
+			logger.Printf("failed to remove socket %s: %v", sockpath, err)
+		}
 	}
 	if st != nil {
 		err = st.Close()
@@ -166,11 +445,48 @@ loop:
 			logger.Printf("failed to close storage: %v", err)
 		}
 	}
-	err = listener.Close()
-	if err != nil {
-		logger.Printf("failed to close listener: %v", err)
+	if !stoppedListening {
+		closeListeners(listeners)
+	}
+	// Ensure that the listener goroutines have exited before returning
+	for ; listening > 0; listening-- {
+		<-listenErrCh
+	}
+
+	if shutdown != nil {
+		// The database has been closed, so the new daemon can open it.
+		handedOff := false
+		if handoff != nil {
+			err := sendListener(shutdown.handoff, handoff)
+			handoff.Close()
+			if err == nil {
+				handedOff = true
+			} else {
+				logger.Println("failed to hand off socket:", err)
+				os.Remove(sockpath)
+			}
+		}
+		shutdown.done <- shutdownResult{handedOff: handedOff}
+		// Wait for the response to be sent.
+		waitConns(0)
 	}
-	// Ensure that the listener goroutine has exited before returning
-	<-listenErrCh
 	return 0
 }
+
+func closeListeners(listeners []net.Listener) {
+	for _, listener := range listeners {
+		err := listener.Close()
+		if err != nil {
+			logger.Printf("failed to close listener: %v", err)
+		}
+	}
+}
+
+func applyRetention(st store.DBStore) {
+	n, err := st.ApplyRetention()
+	if err != nil {
+		logger.Println("failed to apply retention policy:", err)
+	} else if n > 0 {
+		logger.Printf("removed %d commands according to the retention policy", n)
+	}
+}
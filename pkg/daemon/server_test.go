@@ -2,14 +2,20 @@ package daemon
 
 import (
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
 
 	"src.elv.sh/pkg/daemon/daemondefs"
 	"src.elv.sh/pkg/daemon/internal/api"
+	"src.elv.sh/pkg/env"
 	"src.elv.sh/pkg/must"
 	"src.elv.sh/pkg/prog"
+	"src.elv.sh/pkg/store"
+	"src.elv.sh/pkg/store/storedefs"
 	"src.elv.sh/pkg/store/storetest"
 	"src.elv.sh/pkg/testutil"
 )
@@ -24,6 +30,11 @@ func TestProgram_ServesClientRequests(t *testing.T) {
 	if gotVersion != api.Version || err != nil {
 		t.Errorf(".Version() -> (%v, %v), want (%v, nil)", gotVersion, err, api.Version)
 	}
+	res := &api.MethodsResponse{}
+	if err := newSockClient("sock").call("Methods", &api.MethodsRequest{}, res); err != nil ||
+		!slices.Contains(res.Methods, "AddCmd") || slices.Contains(res.Methods, "Daemon.AddCmd") {
+		t.Errorf("Methods -> (%v, %v), want method names including AddCmd", res.Methods, err)
+	}
 
 	gotPid, err := client.Pid()
 	wantPid := syscall.Getpid()
@@ -36,6 +47,117 @@ func TestProgram_ServesClientRequests(t *testing.T) {
 	storetest.TestDir(t, client)
 }
 
+func TestProgram_Stats(t *testing.T) {
+	setup(t)
+	startServer(t, cli("sock", "db"))
+	client := startClient(t, "sock")
+	must.OK1(client.AddCmd("echo foo"))
+	must.OK1(client.AddCmd("echo bar"))
+
+	stats, err := client.Stats()
+	if err != nil {
+		t.Fatalf("Stats() -> error %v", err)
+	}
+	if stats.Uptime <= 0 || stats.Clients != 1 || stats.StoreSize <= 0 {
+		t.Errorf("got uptime %v, %v clients and store size %v, want positive values and 1 client",
+			stats.Uptime, stats.Clients, stats.StoreSize)
+	}
+	var addCmd *daemondefs.CallStats
+	for i, c := range stats.Calls {
+		if c.Method == "AddCmd" {
+			addCmd = &stats.Calls[i]
+		}
+	}
+	if addCmd == nil || addCmd.Count != 2 || addCmd.Max > addCmd.Total {
+		t.Errorf("got stats of AddCmd %v, want 2 calls", addCmd)
+	}
+}
+
+func TestProgram_Compact(t *testing.T) {
+	setup(t)
+	startServer(t, cli("sock", "db"))
+	client := startClient(t, "sock")
+	must.OK1(client.AddCmd("echo foo"))
+
+	report, err := client.Compact()
+	if err != nil {
+		t.Fatalf("Compact() -> error %v", err)
+	}
+	if report.SizeBefore <= 0 || report.SizeAfter <= 0 || len(report.Buckets) == 0 {
+		t.Errorf("got report %v, want positive sizes and some buckets", report)
+	}
+	// The store still works after compacting.
+	if cmd, err := client.Cmd(1); cmd != "echo foo" || err != nil {
+		t.Errorf("Cmd(1) -> (%q, %v), want (%q, nil)", cmd, err, "echo foo")
+	}
+}
+
+func TestProgram_BackupAndRestore(t *testing.T) {
+	setup(t)
+	startServer(t, cli("sock", "db"))
+	client := startClient(t, "sock")
+	must.OK1(client.AddCmd("echo foo"))
+	backup := must.OK1(filepath.Abs("backup"))
+
+	if size, err := client.Backup(backup); size <= 0 || err != nil {
+		t.Fatalf("Backup -> (%v, %v), want positive size and no error", size, err)
+	}
+	must.OK1(client.AddCmd("echo bar"))
+	if err := client.Restore(backup); err != nil {
+		t.Fatalf("Restore -> error %v", err)
+	}
+	if next, err := client.NextCmdSeq(); next != 2 || err != nil {
+		t.Errorf("NextCmdSeq -> (%v, %v), want (2, nil)", next, err)
+	}
+
+	if _, err := client.Backup("backup"); err == nil {
+		t.Errorf("Backup with relative path -> no error")
+	}
+	if err := client.Restore("backup"); err == nil {
+		t.Errorf("Restore with relative path -> no error")
+	}
+}
+
+func TestProgram_AppliesRetentionPeriodically(t *testing.T) {
+	setup(t)
+	testutil.Set(t, &retentionInterval, 10*time.Millisecond)
+	startServer(t, cli("sock", "db"))
+	client := startClient(t, "sock")
+	must.OK(client.SetRetention(storedefs.Retention{MaxAge: 50 * time.Millisecond}))
+	seq := must.OK1(client.AddCmd("echo foo"))
+
+	deadline := time.Now().Add(testutil.Scaled(time.Second))
+	for {
+		_, err := client.Cmd(seq)
+		if err != nil && err.Error() == storedefs.ErrNoMatchingCmd.Error() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("command not removed by retention policy, Cmd -> error %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestProgram_EncryptsStore(t *testing.T) {
+	setup(t)
+	testutil.Setenv(t, env.ELVISH_DB_PASSPHRASE, "passphrase")
+	startServer(t, append(cli("sock", "db"), "-db-encrypt"))
+	client := startClient(t, "sock")
+	must.OK1(client.AddCmd("echo secret"))
+	must.OK1(client.Compact())
+
+	if cmd, err := client.Cmd(1); cmd != "echo secret" || err != nil {
+		t.Errorf("Cmd(1) -> (%q, %v), want (%q, nil)", cmd, err, "echo secret")
+	}
+	if strings.Contains(must.ReadFileString("db"), "echo secret") {
+		t.Errorf("database file contains the command in plaintext")
+	}
+	if _, ok := os.LookupEnv(env.ELVISH_DB_PASSPHRASE); ok {
+		t.Errorf("$%s is still set", env.ELVISH_DB_PASSPHRASE)
+	}
+}
+
 func TestProgram_StillServesIfCannotOpenDB(t *testing.T) {
 	setup(t)
 	must.WriteFile("db", "not a valid bolt database")
@@ -70,6 +192,68 @@ func TestProgram_QuitsOnSignalChannelWithClients(t *testing.T) {
 	}
 }
 
+func TestProgram_QuitsAfterIdleTimeoutWithNoClient(t *testing.T) {
+	setup(t)
+	sigCh := make(chan os.Signal)
+	server := startServerOpts(t, cli("sock", "db"),
+		ServeOpts{Signals: sigCh, IdleTimeout: 10 * time.Millisecond})
+	t.Cleanup(func() { close(sigCh) })
+
+	server.WaitQuit()
+}
+
+func TestProgram_QuitsAfterIdleTimeoutAfterClientsDisconnect(t *testing.T) {
+	setup(t)
+	sigCh := make(chan os.Signal)
+	server := startServerOpts(t, cli("sock", "db"),
+		ServeOpts{Signals: sigCh, IdleTimeout: testutil.Scaled(100 * time.Millisecond)})
+	t.Cleanup(func() { close(sigCh) })
+
+	client := NewClient("sock")
+	must.OK1(client.AddCmd("echo foo"))
+	client.Close()
+	// The daemon is still running after the only client has disconnected,
+	// and has kept the data.
+	client = startClient(t, "sock")
+	cmds, err := client.CmdsWithSeq(0, -1)
+	if len(cmds) != 1 || err != nil {
+		t.Errorf("got (%v, %v), want 1 command", cmds, err)
+	}
+	client.Close()
+
+	if server.WaitQuit() {
+		if _, err := os.Stat("sock"); !os.IsNotExist(err) {
+			t.Errorf("socket still exists after daemon quit")
+		}
+	}
+}
+
+func TestProgram_QuitsOnShutdownRPC(t *testing.T) {
+	setup(t)
+	server := startServerOpts(t, cli("sock", "db"), ServeOpts{})
+	client := newSockClient("sock")
+	must.OK1(client.AddCmd("echo foo"))
+
+	handedOff, err := client.shutdown("")
+	if handedOff || err != nil {
+		t.Errorf("shutdown(\"\") -> (%v, %v), want (false, nil)", handedOff, err)
+	}
+	// The socket has been removed and the database has been closed when the
+	// RPC returns.
+	if _, err := os.Stat("sock"); !os.IsNotExist(err) {
+		t.Errorf("socket still exists after shutdown")
+	}
+	st, err := store.NewStore("db")
+	if err != nil {
+		t.Fatalf("cannot open database after shutdown: %v", err)
+	}
+	if cmd, err := st.Cmd(1); cmd != "echo foo" || err != nil {
+		t.Errorf("Cmd(1) -> (%q, %v), want (%q, nil)", cmd, err, "echo foo")
+	}
+	st.Close()
+	server.WaitQuit()
+}
+
 func setup(t *testing.T) {
 	testutil.Umask(t, 0)
 	testutil.InTempDir(t)
@@ -1,17 +1,44 @@
 package daemon
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"syscall"
+	"time"
 
+	"src.elv.sh/pkg/daemon/daemondefs"
 	"src.elv.sh/pkg/daemon/internal/api"
-	"src.elv.sh/pkg/store/storedefs"
+	"src.elv.sh/pkg/rpc"
+	"src.elv.sh/pkg/store"
 )
 
 // A net/rpc service for the daemon.
 type service struct {
-	version int
-	store   storedefs.Store
-	err     error
+	version  int
+	store    store.DBStore
+	err      error
+	sessions *sessions
+	updates  *updateChecker
+	managed  bool
+	// Whether the service is for connections over TCP, which can't use the
+	// RPCs that access files of the daemon host.
+	remote   bool
+	shutdown chan<- shutdownRequest
+	stats    *serverStats
+}
+
+// Information used by the Stats RPC.
+type serverStats struct {
+	start  time.Time
+	dbPath string
+	// The servers for each listener. The first one is for the Unix socket.
+	servers []*rpc.Server
+	// Updated by Serve.
+	clients atomic.Int64
 }
 
 // Implementations of RPC methods.
@@ -22,12 +49,73 @@ func (s *service) Version(req *api.VersionRequest, res *api.VersionResponse) err
 	return nil
 }
 
+// Methods returns the names of the RPC methods supported by the daemon.
+func (s *service) Methods(req *api.MethodsRequest, res *api.MethodsResponse) error {
+	for _, m := range s.stats.servers[0].Methods() {
+		if name, ok := strings.CutPrefix(m, api.ServiceName+"."); ok {
+			res.Methods = append(res.Methods, name)
+		}
+	}
+	return nil
+}
+
 // Pid returns the process ID of the daemon.
 func (s *service) Pid(req *api.PidRequest, res *api.PidResponse) error {
 	res.Pid = syscall.Getpid()
 	return nil
 }
 
+// Managed returns whether the daemon was started with socket activation.
+func (s *service) Managed(req *api.ManagedRequest, res *api.ManagedResponse) error {
+	res.Managed = s.managed
+	return nil
+}
+
+// Stats returns statistics of the daemon.
+func (s *service) Stats(req *api.StatsRequest, res *api.StatsResponse) error {
+	stats := daemondefs.Stats{
+		Uptime:    time.Since(s.stats.start),
+		Clients:   int(s.stats.clients.Load()),
+		StoreSize: -1,
+	}
+	if info, err := os.Stat(s.stats.dbPath); err == nil {
+		stats.StoreSize = info.Size()
+	}
+	for _, server := range s.stats.servers {
+		for _, m := range server.Stats() {
+			stats.Calls = addCallStats(stats.Calls, daemondefs.CallStats{
+				Method: strings.TrimPrefix(m.ServiceMethod, api.ServiceName+"."),
+				Count:  int(m.NumCalls), Total: m.TotalTime, Max: m.MaxTime})
+		}
+	}
+	res.Stats = stats
+	return nil
+}
+
+// Adds the statistics of a method to calls, merging them with the existing
+// ones of the same method.
+func addCallStats(calls []daemondefs.CallStats, c daemondefs.CallStats) []daemondefs.CallStats {
+	i, found := slices.BinarySearchFunc(calls, c.Method,
+		func(c daemondefs.CallStats, method string) int { return strings.Compare(c.Method, method) })
+	if !found {
+		return slices.Insert(calls, i, c)
+	}
+	calls[i].Count += c.Count
+	calls[i].Total += c.Total
+	calls[i].Max = max(calls[i].Max, c.Max)
+	return calls
+}
+
+// Shutdown makes the daemon exit after finishing pending requests and closing
+// the database, optionally handing off its listening socket to a new daemon.
+func (s *service) Shutdown(req *api.ShutdownRequest, res *api.ShutdownResponse) error {
+	done := make(chan shutdownResult)
+	s.shutdown <- shutdownRequest{req.Handoff, done}
+	result := <-done
+	res.HandedOff = result.handedOff
+	return result.err
+}
+
 func (s *service) NextCmdSeq(req *api.NextCmdSeqRequest, res *api.NextCmdSeqResponse) error {
 	if s.err != nil {
 		return s.err
@@ -90,6 +178,150 @@ func (s *service) PrevCmd(req *api.PrevCmdRequest, res *api.PrevCmdResponse) err
 	return err
 }
 
+func (s *service) AddCmdRecords(req *api.AddCmdRecordsRequest, res *api.AddCmdRecordsResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.store.AddCmdRecords(req.Records)
+}
+
+func (s *service) CmdRecords(req *api.CmdRecordsRequest, res *api.CmdRecordsResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	records, err := s.store.CmdRecords(req.From, req.Upto)
+	res.Records = records
+	return err
+}
+
+func (s *service) SetCmdResult(req *api.SetCmdResultRequest, res *api.SetCmdResultResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.store.SetCmdResult(req.Seq, req.Dir, req.Result)
+}
+
+func (s *service) SetCmdWorkspace(req *api.SetCmdWorkspaceRequest, res *api.SetCmdWorkspaceResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.store.SetCmdWorkspace(req.Seq, req.Workspace)
+}
+
+func (s *service) WorkspaceCmds(req *api.WorkspaceCmdsRequest, res *api.WorkspaceCmdsResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	cmds, err := s.store.WorkspaceCmds(req.Workspace, req.From, req.Upto)
+	res.Cmds = cmds
+	return err
+}
+
+func (s *service) Dedup(req *api.DedupRequest, res *api.DedupResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	dedup, err := s.store.Dedup()
+	res.Dedup = dedup
+	return err
+}
+
+func (s *service) SetDedup(req *api.SetDedupRequest, res *api.SetDedupResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.store.SetDedup(req.Dedup)
+}
+
+func (s *service) Retention(req *api.RetentionRequest, res *api.RetentionResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	retention, err := s.store.Retention()
+	res.Retention = retention
+	return err
+}
+
+func (s *service) SetRetention(req *api.SetRetentionRequest, res *api.SetRetentionResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.store.SetRetention(req.Retention)
+}
+
+func (s *service) Prune(req *api.PruneRequest, res *api.PruneResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	n, err := s.store.Prune(req.Filter)
+	res.N = n
+	return err
+}
+
+func (s *service) IgnorePatterns(req *api.IgnorePatternsRequest, res *api.IgnorePatternsResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	patterns, err := s.store.IgnorePatterns()
+	res.Patterns = patterns
+	return err
+}
+
+func (s *service) SetIgnorePatterns(req *api.SetIgnorePatternsRequest, res *api.SetIgnorePatternsResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.store.SetIgnorePatterns(req.Patterns)
+}
+
+// Compact compacts the database.
+func (s *service) Compact(req *api.CompactRequest, res *api.CompactResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	report, err := s.store.Compact()
+	res.Report = report
+	return err
+}
+
+// Backup writes a copy of the database to a file.
+func (s *service) Backup(req *api.BackupRequest, res *api.BackupResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.remote {
+		return errRemoteBackup
+	}
+	if !filepath.IsAbs(req.Path) {
+		return errRelativeBackupPath
+	}
+	size, err := s.store.Backup(req.Path)
+	res.Size = size
+	return err
+}
+
+// Restore replaces the database with a backup.
+func (s *service) Restore(req *api.RestoreRequest, res *api.RestoreResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.remote {
+		return errRemoteBackup
+	}
+	if !filepath.IsAbs(req.Path) {
+		return errRelativeBackupPath
+	}
+	return s.store.Restore(req.Path)
+}
+
+// The working directory of the daemon is unrelated to that of the client, so
+// paths of backups must be absolute.
+var errRelativeBackupPath = errors.New("path of backup must be absolute")
+
+// Backups are files on the daemon host, which clients connected over TCP
+// should not be able to write or read.
+var errRemoteBackup = errors.New("backup and restore are not available over TCP")
+
 func (s *service) AddDir(req *api.AddDirRequest, res *api.AddDirResponse) error {
 	if s.err != nil {
 		return s.err
@@ -112,3 +344,86 @@ func (s *service) Dirs(req *api.DirsRequest, res *api.DirsResponse) error {
 	res.Dirs = dirs
 	return err
 }
+
+func (s *service) DirScoring(req *api.DirScoringRequest, res *api.DirScoringResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	scoring, err := s.store.DirScoring()
+	res.Scoring = scoring
+	return err
+}
+
+func (s *service) SetDirScoring(req *api.SetDirScoringRequest, res *api.SetDirScoringResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.store.SetDirScoring(req.Scoring)
+}
+
+func (s *service) PinDir(req *api.PinDirRequest, res *api.PinDirResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.store.PinDir(req.Dir)
+}
+
+func (s *service) UnpinDir(req *api.UnpinDirRequest, res *api.UnpinDirResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.store.UnpinDir(req.Dir)
+}
+
+func (s *service) PinnedDirs(req *api.PinnedDirsRequest, res *api.PinnedDirsResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	dirs, err := s.store.PinnedDirs()
+	res.Dirs = dirs
+	return err
+}
+
+func (s *service) NewSession(req *api.NewSessionRequest, res *api.NewSessionResponse) error {
+	return s.sessions.new(req.Name, req.Config)
+}
+
+func (s *service) Sessions(req *api.SessionsRequest, res *api.SessionsResponse) error {
+	res.Sessions = s.sessions.list()
+	return nil
+}
+
+func (s *service) ReadSession(req *api.ReadSessionRequest, res *api.ReadSessionResponse) error {
+	session, err := s.sessions.get(req.Name)
+	if err != nil {
+		return err
+	}
+	res.Data, res.Next, res.Exited = session.read(req.Offset)
+	return nil
+}
+
+func (s *service) WriteSession(req *api.WriteSessionRequest, res *api.WriteSessionResponse) error {
+	session, err := s.sessions.get(req.Name)
+	if err != nil {
+		return err
+	}
+	return session.write(req.Data)
+}
+
+func (s *service) ResizeSession(req *api.ResizeSessionRequest, res *api.ResizeSessionResponse) error {
+	session, err := s.sessions.get(req.Name)
+	if err != nil {
+		return err
+	}
+	return session.resize(req.Rows, req.Cols)
+}
+
+func (s *service) KillSession(req *api.KillSessionRequest, res *api.KillSessionResponse) error {
+	return s.sessions.kill(req.Name)
+}
+
+func (s *service) LatestVersion(req *api.LatestVersionRequest, res *api.LatestVersionResponse) error {
+	version, err := s.updates.latest(req.Feed)
+	res.Version = version
+	return err
+}
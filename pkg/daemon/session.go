@@ -0,0 +1,206 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/creack/pty"
+	"src.elv.sh/pkg/daemon/daemondefs"
+	"src.elv.sh/pkg/parse"
+)
+
+// Maximum number of bytes of output kept for each session, so that it can be
+// shown when a client attaches.
+const sessionBufferSize = 64 * 1024
+
+// How long ReadSession waits for new output.
+const sessionReadWait = time.Second
+
+// Sessions hosted by the daemon. Each session is a process running in a pty.
+type sessions struct {
+	// Command to run for each session.
+	cmd []string
+	// Notified whenever a session exits.
+	exitCh chan struct{}
+
+	mu sync.Mutex
+	m  map[string]*session
+}
+
+type session struct {
+	pty *os.File
+	cmd *exec.Cmd
+
+	mu sync.Mutex
+	// Output kept in the buffer, and the offset of its first byte in all the
+	// output of the session.
+	buf  []byte
+	base int
+	// Closed and replaced when there is new output or the session exits.
+	update chan struct{}
+	exited bool
+}
+
+func newSessions(cmd []string) *sessions {
+	return &sessions{cmd: cmd, exitCh: make(chan struct{}, 1), m: make(map[string]*session)}
+}
+
+func (ss *sessions) get(name string) (*session, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	s, ok := ss.m[name]
+	if !ok {
+		return nil, fmt.Errorf("no such session: %s", parse.Quote(name))
+	}
+	return s, nil
+}
+
+func (ss *sessions) new(name string, cfg daemondefs.SessionConfig) error {
+	if name == "" {
+		return fmt.Errorf("session name must not be empty")
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if s, ok := ss.m[name]; ok && !s.hasExited() {
+		return fmt.Errorf("session %s already exists", parse.Quote(name))
+	}
+	cmd := exec.Command(ss.cmd[0], ss.cmd[1:]...)
+	cmd.Dir = cfg.Dir
+	cmd.Env = cfg.Env
+	f, err := pty.StartWithSize(cmd, &pty.Winsize{Rows: uint16(cfg.Rows), Cols: uint16(cfg.Cols)})
+	if err != nil {
+		return err
+	}
+	s := &session{pty: f, cmd: cmd, update: make(chan struct{})}
+	ss.m[name] = s
+	logger.Printf("started session %s with pid %d", name, cmd.Process.Pid)
+	go func() {
+		s.relay()
+		cmd.Wait()
+		logger.Printf("session %s exited", name)
+		select {
+		case ss.exitCh <- struct{}{}:
+		default:
+		}
+	}()
+	return nil
+}
+
+// Reads the output of the session until it exits.
+func (s *session) relay() {
+	buf := make([]byte, 4096)
+	for {
+		n, err := s.pty.Read(buf)
+		s.mu.Lock()
+		if n > 0 {
+			s.buf = append(s.buf, buf[:n]...)
+			if excess := len(s.buf) - sessionBufferSize; excess > 0 {
+				s.buf = s.buf[excess:]
+				s.base += excess
+			}
+		}
+		if err != nil {
+			// On Linux, reading from the pty fails with EIO after the
+			// process exits.
+			s.exited = true
+			s.pty.Close()
+		}
+		close(s.update)
+		s.update = make(chan struct{})
+		s.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *session) hasExited() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.exited
+}
+
+func (s *session) read(offset int) ([]byte, int, bool) {
+	s.mu.Lock()
+	end := s.base + len(s.buf)
+	if offset >= end && !s.exited {
+		update := s.update
+		s.mu.Unlock()
+		select {
+		case <-update:
+		case <-time.After(sessionReadWait):
+		}
+		s.mu.Lock()
+		end = s.base + len(s.buf)
+	}
+	defer s.mu.Unlock()
+	offset = max(min(offset, end), s.base)
+	return append([]byte(nil), s.buf[offset-s.base:]...), end, s.exited
+}
+
+func (s *session) write(data []byte) error {
+	_, err := s.pty.Write(data)
+	return err
+}
+
+func (s *session) resize(rows, cols int) error {
+	return pty.Setsize(s.pty, &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)})
+}
+
+func (ss *sessions) list() []daemondefs.Session {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	list := make([]daemondefs.Session, 0, len(ss.m))
+	for name, s := range ss.m {
+		list = append(list, daemondefs.Session{
+			Name: name, Pid: s.cmd.Process.Pid, Exited: s.hasExited()})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Returns the number of sessions that haven't exited.
+func (ss *sessions) running() int {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	n := 0
+	for _, s := range ss.m {
+		if !s.hasExited() {
+			n++
+		}
+	}
+	return n
+}
+
+// Kills the session if it is running, and removes it.
+func (ss *sessions) kill(name string) error {
+	s, err := ss.get(name)
+	if err != nil {
+		return err
+	}
+	if !s.hasExited() {
+		if err := s.cmd.Process.Kill(); err != nil {
+			return err
+		}
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	delete(ss.m, name)
+	return nil
+}
+
+// Kills all the sessions.
+func (ss *sessions) killAll() {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	for name, s := range ss.m {
+		if !s.hasExited() {
+			s.cmd.Process.Kill()
+		}
+		delete(ss.m, name)
+	}
+}
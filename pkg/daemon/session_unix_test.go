@@ -0,0 +1,93 @@
+//go:build unix
+
+package daemon
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/daemon/daemondefs"
+	"src.elv.sh/pkg/must"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestProgram_Sessions(t *testing.T) {
+	setup(t)
+	sigCh := make(chan os.Signal)
+	startServerOpts(t, cli("sock", "db"),
+		ServeOpts{Signals: sigCh, SessionCommand: []string{"sh"}})
+	t.Cleanup(func() { close(sigCh) })
+	client := startClient(t, "sock")
+
+	cfg := daemondefs.SessionConfig{Env: os.Environ(), Rows: 24, Cols: 80}
+	if err := client.NewSession("foo", cfg); err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	if err := client.NewSession("foo", cfg); err == nil {
+		t.Errorf("NewSession with existing name: got nil error, want non-nil")
+	}
+
+	sessions, err := client.Sessions()
+	if err != nil || len(sessions) != 1 || sessions[0].Name != "foo" || sessions[0].Exited {
+		t.Errorf("Sessions() -> (%v, %v), want one running session foo", sessions, err)
+	}
+
+	must.OK(client.WriteSession("foo", []byte("echo hello\n")))
+	offset, _ := readSessionUntil(t, client, 0, "hello")
+
+	// Reading again from the start gets the same output.
+	data, _, _, err := client.ReadSession("foo", 0)
+	if err != nil || !strings.Contains(string(data), "hello") {
+		t.Errorf("ReadSession from 0 -> (%q, %v), want containing hello", data, err)
+	}
+
+	must.OK(client.ResizeSession("foo", 30, 100))
+	must.OK(client.WriteSession("foo", []byte("stty size\n")))
+	offset, _ = readSessionUntil(t, client, offset, "30 100")
+
+	must.OK(client.WriteSession("foo", []byte("exit\n")))
+	if _, exited := readSessionUntil(t, client, offset, ""); !exited {
+		t.Errorf("session didn't exit")
+	}
+	sessions, err = client.Sessions()
+	want := []daemondefs.Session{{Name: "foo", Pid: sessions[0].Pid, Exited: true}}
+	if err != nil || !reflect.DeepEqual(sessions, want) {
+		t.Errorf("Sessions() -> (%v, %v), want %v", sessions, err, want)
+	}
+
+	// An exited session can be replaced.
+	must.OK(client.NewSession("foo", cfg))
+	must.OK(client.KillSession("foo"))
+	if sessions, err := client.Sessions(); err != nil || len(sessions) != 0 {
+		t.Errorf("Sessions() -> (%v, %v), want no sessions", sessions, err)
+	}
+
+	if err := client.WriteSession("bar", nil); err == nil {
+		t.Errorf("WriteSession to nonexistent session: got nil error, want non-nil")
+	}
+}
+
+// Reads the output of the "foo" session until it contains the given string, or
+// the session exits. Returns the offset after the output and whether the session
+// has exited.
+func readSessionUntil(t *testing.T, client daemondefs.Client, offset int, s string) (int, bool) {
+	t.Helper()
+	var output []byte
+	deadline := time.Now().Add(testutil.Scaled(5 * time.Second))
+	for time.Now().Before(deadline) {
+		data, next, exited, err := client.ReadSession("foo", offset)
+		if err != nil {
+			t.Fatalf("ReadSession: %v", err)
+		}
+		output = append(output, data...)
+		offset = next
+		if (s != "" && strings.Contains(string(output), s)) || exited {
+			return offset, exited
+		}
+	}
+	t.Fatalf("timed out waiting for %q, got output %q", s, output)
+	return 0, false
+}
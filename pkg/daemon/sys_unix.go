@@ -3,6 +3,7 @@
 package daemon
 
 import (
+	"net"
 	"os"
 	"syscall"
 
@@ -11,6 +12,10 @@ import (
 
 var errConnRefused = syscall.ECONNREFUSED
 
+func listen(sockPath string) (net.Listener, error) { return net.Listen("unix", sockPath) }
+
+func dial(sockPath string) (net.Conn, error) { return net.Dial("unix", sockPath) }
+
 // Make sure that files created by the daemon is not accessible to other users.
 func setUmaskForDaemon() { unix.Umask(0077) }
 
@@ -1,16 +1,39 @@
 package daemon
 
 import (
+	"errors"
+	"net"
 	"os"
 	"syscall"
 )
 
-// https://docs.microsoft.com/en-us/windows/win32/winsock/windows-sockets-error-codes-2
-var errConnRefused = syscall.Errno(10061)
-
 // No-op on Windows.
 func setUmaskForDaemon() {}
 
+// Socket activation is not supported on Windows.
+func activationListener() (net.Listener, error) { return nil, nil }
+
+// Handing off the listening socket to a new daemon is not supported on
+// Windows; the new daemon creates a new named pipe instead.
+
+var errHandoffUnsupported = errors.New("socket handoff is not supported on Windows")
+
+func handoffListener() (net.Listener, error) { return nil, nil }
+
+func handoffEnv() string { return "" }
+
+func listenerFile(net.Listener) (*os.File, error) { return nil, errHandoffUnsupported }
+
+func sendListener(string, *os.File) error { return errHandoffUnsupported }
+
+type handoffReceiver struct{ path string }
+
+func newHandoffReceiver(string) (*handoffReceiver, error) { return nil, errHandoffUnsupported }
+
+func (*handoffReceiver) receive() (*os.File, error) { return nil, errHandoffUnsupported }
+
+func (*handoffReceiver) close() {}
+
 // A subset of possible process creation flags, value taken from
 // https://msdn.microsoft.com/en-us/library/windows/desktop/ms684863(v=vs.85).aspx
 const (
@@ -0,0 +1,204 @@
+package daemon
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"src.elv.sh/pkg/daemon/daemondefs"
+	"src.elv.sh/pkg/prog"
+)
+
+// The daemon can optionally listen on TCP, so that clients on other machines
+// (or in containers) can share it. Connections are always encrypted with TLS,
+// and clients must authenticate with a token, a client certificate or both.
+//
+// After the TLS handshake, the client sends the token followed by "\n", and
+// the daemon responds with "ok\n" if the client is authenticated, before the
+// RPC protocol starts. The client sends an empty token if it doesn't have one.
+
+// TCPConfig configures the TCP listener of the daemon.
+type TCPConfig struct {
+	// Address to listen on, like "0.0.0.0:7788".
+	Addr string
+	// Used for the TLS handshake; must have a certificate. To require client
+	// certificates, set ClientAuth and ClientCAs.
+	TLS *tls.Config
+	// If not empty, clients must send this token.
+	Token string
+}
+
+// Flags for the TCP listener.
+type tcpFlags struct {
+	addr, cert, key, clientCA, tokenFile string
+}
+
+func (f *tcpFlags) register(fs *prog.FlagSet) {
+	fs.StringVar(&f.addr, "daemon-tcp", "",
+		"[with -daemon] Also listen on TCP on this address, with TLS")
+	fs.StringVar(&f.cert, "daemon-tls-cert", "",
+		"[with -daemon-tcp] File containing the TLS certificate of the daemon")
+	fs.StringVar(&f.key, "daemon-tls-key", "",
+		"[with -daemon-tcp] File containing the key of the TLS certificate")
+	fs.StringVar(&f.clientCA, "daemon-tls-client-ca", "",
+		"[with -daemon-tcp] File containing CA certificates; require client certificates signed by them")
+	fs.StringVar(&f.tokenFile, "daemon-token-file", "",
+		"[with -daemon-tcp] File containing the token that clients must send")
+}
+
+// Returns the TCPConfig from the flags, or nil if -daemon-tcp is not given.
+func (f *tcpFlags) config() (*TCPConfig, error) {
+	if f.addr == "" {
+		if f.cert != "" || f.key != "" || f.clientCA != "" || f.tokenFile != "" {
+			return nil, prog.BadUsage("-daemon-tls-cert, -daemon-tls-key, -daemon-tls-client-ca and -daemon-token-file require -daemon-tcp")
+		}
+		return nil, nil
+	}
+	if f.cert == "" || f.key == "" {
+		return nil, prog.BadUsage("-daemon-tcp requires -daemon-tls-cert and -daemon-tls-key")
+	}
+	if f.clientCA == "" && f.tokenFile == "" {
+		return nil, prog.BadUsage("-daemon-tcp requires -daemon-token-file, -daemon-tls-client-ca or both")
+	}
+	cert, err := tls.LoadX509KeyPair(f.cert, f.key)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &TCPConfig{Addr: f.addr, TLS: &tls.Config{
+		Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}}
+	if f.clientCA != "" {
+		pool, err := loadCertPool(f.clientCA)
+		if err != nil {
+			return nil, err
+		}
+		cfg.TLS.ClientCAs = pool
+		cfg.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if f.tokenFile != "" {
+		token, err := os.ReadFile(f.tokenFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Token = strings.TrimSpace(string(token))
+		if cfg.Token == "" || strings.Contains(cfg.Token, "\n") {
+			return nil, fmt.Errorf("token file %s must contain a non-empty line", f.tokenFile)
+		}
+	}
+	return cfg, nil
+}
+
+func loadCertPool(filename string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates in %s", filename)
+	}
+	return pool, nil
+}
+
+const (
+	authTimeout   = 10 * time.Second
+	maxTokenLen   = 4096
+	authOKMessage = "ok\n"
+)
+
+var errAuthFailed = errors.New("daemon rejected authentication")
+
+func listenTCP(cfg *TCPConfig) (net.Listener, error) {
+	return tls.Listen("tcp", cfg.Addr, cfg.TLS)
+}
+
+// Authenticates a connection accepted by the TCP listener.
+func authenticate(conn net.Conn, token string) error {
+	conn.SetDeadline(time.Now().Add(authTimeout))
+	defer conn.SetDeadline(time.Time{})
+	got, err := readLine(conn)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+		return errors.New("wrong token")
+	}
+	_, err = conn.Write([]byte(authOKMessage))
+	return err
+}
+
+// Reads a line without reading past it, since the rest belongs to the RPC
+// protocol.
+func readLine(conn net.Conn) (string, error) {
+	var sb strings.Builder
+	buf := make([]byte, 1)
+	for sb.Len() <= maxTokenLen {
+		if _, err := conn.Read(buf); err != nil {
+			return "", err
+		}
+		if buf[0] == '\n' {
+			return sb.String(), nil
+		}
+		sb.WriteByte(buf[0])
+	}
+	return "", errors.New("token too long")
+}
+
+// NewRemoteClient creates a Client that talks to a daemon listening on TCP.
+// Like NewClient, connection creation is deferred to the first request.
+func NewRemoteClient(cfg daemondefs.RemoteConfig) (daemondefs.Client, error) {
+	tlsCfg, err := clientTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	dial := func() (net.Conn, error) {
+		conn, err := tls.DialWithDialer(&net.Dialer{Timeout: authTimeout}, "tcp", cfg.Addr, tlsCfg)
+		if err != nil {
+			return nil, err
+		}
+		if err := sendToken(conn, cfg.Token); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("connect to %s: %w", cfg.Addr, err)
+		}
+		return conn, nil
+	}
+	return newClient(cfg.Addr, dial), nil
+}
+
+func clientTLSConfig(cfg daemondefs.RemoteConfig) (*tls.Config, error) {
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.CAFile != "" {
+		pool, err := loadCertPool(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.RootCAs = pool
+	}
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	return tlsCfg, nil
+}
+
+func sendToken(conn net.Conn, token string) error {
+	conn.SetDeadline(time.Now().Add(authTimeout))
+	defer conn.SetDeadline(time.Time{})
+	if _, err := conn.Write([]byte(token + "\n")); err != nil {
+		return err
+	}
+	buf := make([]byte, len(authOKMessage))
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != authOKMessage {
+		return errAuthFailed
+	}
+	return nil
+}
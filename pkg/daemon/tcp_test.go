@@ -0,0 +1,248 @@
+package daemon
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/daemon/daemondefs"
+	"src.elv.sh/pkg/daemon/internal/api"
+	"src.elv.sh/pkg/must"
+)
+
+func TestTCP_Token(t *testing.T) {
+	setup(t)
+	ca := newTestCA(t)
+	ca.issue(t, "server", true)
+	addr := startTCPServer(t, &TCPConfig{
+		TLS: serverTLS(t, "server"), Token: "secret"})
+
+	cl := startRemoteClient(t, daemondefs.RemoteConfig{
+		Addr: addr, Token: "secret", CAFile: "ca.pem"})
+	if v, err := cl.Version(); v != api.Version || err != nil {
+		t.Errorf("Version() -> (%v, %v), want (%v, nil)", v, err, api.Version)
+	}
+	if _, err := cl.AddCmd("echo remote"); err != nil {
+		t.Errorf("AddCmd() -> %v", err)
+	}
+	if cmd, err := cl.Cmd(1); cmd != "echo remote" || err != nil {
+		t.Errorf("Cmd(1) -> (%q, %v), want (%q, nil)", cmd, err, "echo remote")
+	}
+
+	cl = startRemoteClient(t, daemondefs.RemoteConfig{
+		Addr: addr, Token: "wrong", CAFile: "ca.pem"})
+	if _, err := cl.Version(); !errors.Is(err, errAuthFailed) {
+		t.Errorf("Version() with wrong token -> %v, want errAuthFailed", err)
+	}
+}
+
+func TestTCP_RefusesBackupAndRestore(t *testing.T) {
+	setup(t)
+	ca := newTestCA(t)
+	ca.issue(t, "server", true)
+	addr := startTCPServer(t, &TCPConfig{TLS: serverTLS(t, "server"), Token: "secret"})
+	backup := filepath.Join(must.OK1(os.Getwd()), "backup")
+
+	cl := startRemoteClient(t, daemondefs.RemoteConfig{
+		Addr: addr, Token: "secret", CAFile: "ca.pem"})
+	if _, err := cl.Backup(backup); err == nil || err.Error() != errRemoteBackup.Error() {
+		t.Errorf("Backup over TCP -> %v, want %v", err, errRemoteBackup)
+	}
+	if _, err := os.Stat(backup); err == nil {
+		t.Errorf("Backup over TCP created the backup")
+	}
+	if err := cl.Restore(backup); err == nil || err.Error() != errRemoteBackup.Error() {
+		t.Errorf("Restore over TCP -> %v, want %v", err, errRemoteBackup)
+	}
+
+	// They are still available over the Unix socket, and calls over both
+	// connections are counted in the stats.
+	local := NewClient("sock")
+	defer local.Close()
+	if _, err := local.Backup(backup); err != nil {
+		t.Errorf("Backup over Unix socket -> %v", err)
+	}
+	stats, err := local.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	backupCalls := 0
+	for _, c := range stats.Calls {
+		if c.Method == "Backup" {
+			backupCalls = c.Count
+		}
+	}
+	if backupCalls != 2 {
+		t.Errorf("got %d calls of Backup in stats, want 2", backupCalls)
+	}
+}
+
+func TestTCP_ClientCert(t *testing.T) {
+	setup(t)
+	ca := newTestCA(t)
+	ca.issue(t, "server", true)
+	ca.issue(t, "client", false)
+	tlsCfg := serverTLS(t, "server")
+	tlsCfg.ClientCAs = must.OK1(loadCertPool("ca.pem"))
+	tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	addr := startTCPServer(t, &TCPConfig{TLS: tlsCfg})
+
+	cl := startRemoteClient(t, daemondefs.RemoteConfig{
+		Addr: addr, CAFile: "ca.pem", CertFile: "client.pem", KeyFile: "client-key.pem"})
+	if v, err := cl.Version(); v != api.Version || err != nil {
+		t.Errorf("Version() -> (%v, %v), want (%v, nil)", v, err, api.Version)
+	}
+
+	cl = startRemoteClient(t, daemondefs.RemoteConfig{Addr: addr, CAFile: "ca.pem"})
+	if _, err := cl.Version(); err == nil {
+		t.Errorf("Version() without client certificate succeeded")
+	}
+}
+
+func TestTCP_DaemonKeepsRunningWithoutClients(t *testing.T) {
+	setup(t)
+	ca := newTestCA(t)
+	ca.issue(t, "server", true)
+	addr := startTCPServer(t, &TCPConfig{TLS: serverTLS(t, "server"), Token: "secret"})
+
+	cl := NewClient("sock")
+	cl.Version()
+	cl.Close()
+	rcl := startRemoteClient(t, daemondefs.RemoteConfig{
+		Addr: addr, Token: "secret", CAFile: "ca.pem"})
+	if _, err := rcl.Version(); err != nil {
+		t.Errorf("Version() after all clients disconnected -> %v", err)
+	}
+}
+
+func TestTCP_DaemonRefusesHandoff(t *testing.T) {
+	setup(t)
+	ca := newTestCA(t)
+	ca.issue(t, "server", true)
+	startTCPServer(t, &TCPConfig{TLS: serverTLS(t, "server"), Token: "secret"})
+
+	cl := newSockClient("sock")
+	defer cl.Close()
+	if _, err := cl.shutdown("handoff"); err == nil || err.Error() != errCannotHandoff.Error() {
+		t.Errorf("shutdown(\"handoff\") -> %v, want %v", err, errCannotHandoff)
+	}
+	// The daemon is still running.
+	if _, err := cl.Version(); err != nil {
+		t.Errorf("Version() after refused handoff -> %v", err)
+	}
+}
+
+func TestActivate_Remote(t *testing.T) {
+	setup(t)
+	ca := newTestCA(t)
+	ca.issue(t, "server", true)
+	addr := startTCPServer(t, &TCPConfig{TLS: serverTLS(t, "server"), Token: "secret"})
+
+	cl, err := Activate(nil, &daemondefs.SpawnConfig{Remote: &daemondefs.RemoteConfig{
+		Addr: addr, Token: "secret", CAFile: "ca.pem"}})
+	if err != nil {
+		t.Errorf("Activate -> error %v", err)
+	}
+	if cl != nil {
+		cl.Close()
+	}
+
+	_, err = Activate(nil, &daemondefs.SpawnConfig{Remote: &daemondefs.RemoteConfig{
+		Addr: addr, CAFile: "bad-ca.pem"}})
+	if err == nil {
+		t.Errorf("Activate with bad CA file -> nil error")
+	}
+}
+
+// Starts a daemon listening on the socket "sock" and TCP, returning the TCP
+// address.
+func startTCPServer(t *testing.T, cfg *TCPConfig) string {
+	t.Helper()
+	cfg.Addr = freeAddr(t)
+	sigCh := make(chan os.Signal)
+	startServerOpts(t, cli("sock", "db"), ServeOpts{TCP: cfg, Signals: sigCh})
+	// Like startServer, this runs before the cleanup function added by
+	// startServerOpts.
+	t.Cleanup(func() { close(sigCh) })
+	return cfg.Addr
+}
+
+func startRemoteClient(t *testing.T, cfg daemondefs.RemoteConfig) daemondefs.Client {
+	t.Helper()
+	cl, err := NewRemoteClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cl.Close() })
+	return cl
+}
+
+func freeAddr(t *testing.T) string {
+	l := must.OK1(net.Listen("tcp", "127.0.0.1:0"))
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func serverTLS(t *testing.T, name string) *tls.Config {
+	cert := must.OK1(tls.LoadX509KeyPair(name+".pem", name+"-key.pem"))
+	return &tls.Config{Certificates: []tls.Certificate{cert}}
+}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// Creates a CA and writes its certificate to ca.pem.
+func newTestCA(t *testing.T) testCA {
+	key := must.OK1(ecdsa.GenerateKey(elliptic.P256(), rand.Reader))
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der := must.OK1(x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key))
+	writePEM(t, "ca.pem", "CERTIFICATE", der)
+	return testCA{must.OK1(x509.ParseCertificate(der)), key}
+}
+
+// Issues a certificate for 127.0.0.1, writing it to name.pem and its key to
+// name-key.pem.
+func (ca testCA) issue(t *testing.T, name string, server bool) {
+	key := must.OK1(ecdsa.GenerateKey(elliptic.P256(), rand.Reader))
+	usage := x509.ExtKeyUsageClientAuth
+	if server {
+		usage = x509.ExtKeyUsageServerAuth
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der := must.OK1(x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key))
+	writePEM(t, name+".pem", "CERTIFICATE", der)
+	writePEM(t, name+"-key.pem", "EC PRIVATE KEY", must.OK1(x509.MarshalECPrivateKey(key)))
+}
+
+func writePEM(t *testing.T, filename, typ string, der []byte) {
+	must.WriteFile(filename, string(pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})))
+}
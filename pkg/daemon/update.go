@@ -0,0 +1,86 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"src.elv.sh/pkg/store"
+)
+
+// Minimum interval between checks of the same release feed.
+const updateCheckInterval = 24 * time.Hour
+
+const updateCheckTimeout = 10 * time.Second
+
+// Checks release feeds for the latest version of Elvish, caching the results
+// so that each feed is fetched at most once per updateCheckInterval.
+//
+// The results are also saved in the store if it is not nil, since the daemon
+// is restarted when all its clients have exited, possibly many times a day.
+type updateChecker struct {
+	mu    sync.Mutex
+	store store.DBStore
+	cache map[string]store.UpdateCheck
+}
+
+func newUpdateChecker(st store.DBStore) *updateChecker {
+	return &updateChecker{store: st, cache: make(map[string]store.UpdateCheck)}
+}
+
+// Returns the latest version from the feed. Failures are also cached, so that
+// an unreachable feed is not tried again until the interval has passed.
+func (c *updateChecker) latest(feed string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.cache[feed]
+	if !ok && c.store != nil {
+		r, _ = c.store.UpdateCheck(feed)
+	}
+	if time.Since(r.Time) >= updateCheckInterval {
+		version, err := fetchLatestVersion(feed)
+		r = store.UpdateCheck{Time: time.Now(), Version: version}
+		if err != nil {
+			r.Error = err.Error()
+		}
+		if c.store != nil {
+			// Failing to save the result only means that the feed may be
+			// fetched again sooner.
+			c.store.SetUpdateCheck(feed, r)
+		}
+	}
+	c.cache[feed] = r
+	if r.Error != "" {
+		return "", errors.New(r.Error)
+	}
+	return r.Version, nil
+}
+
+// Fetches the latest version from a release feed, a URL serving a JSON object
+// whose tag_name field is the version, optionally prefixed with "v". This is
+// the format of the "latest release" endpoint of the GitHub API.
+func fetchLatestVersion(feed string) (string, error) {
+	client := http.Client{Timeout: updateCheckTimeout}
+	resp, err := client.Get(feed)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("release feed responded with %s", resp.Status)
+	}
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return "", fmt.Errorf("invalid release feed: %w", err)
+	}
+	if release.TagName == "" {
+		return "", fmt.Errorf("invalid release feed: no tag_name")
+	}
+	return strings.TrimPrefix(release.TagName, "v"), nil
+}
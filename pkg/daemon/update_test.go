@@ -0,0 +1,86 @@
+package daemon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/store"
+)
+
+func TestUpdateChecker(t *testing.T) {
+	requests := 0
+	feed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"tag_name": "v0.99.0", "name": "0.99.0"}`))
+	}))
+	defer feed.Close()
+
+	c := newUpdateChecker(nil)
+	for i := 0; i < 2; i++ {
+		version, err := c.latest(feed.URL)
+		if version != "0.99.0" || err != nil {
+			t.Errorf("latest -> (%q, %v), want (%q, nil)", version, err, "0.99.0")
+		}
+	}
+	if requests != 1 {
+		t.Errorf("feed requested %d times, want 1", requests)
+	}
+}
+
+func TestUpdateChecker_SavesResultsInStore(t *testing.T) {
+	requests := 0
+	feed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"tag_name": "v0.99.0"}`))
+	}))
+	defer feed.Close()
+	st := store.MustTempStore(t)
+
+	// A new checker, like one in a restarted daemon, uses the saved result.
+	for i := 0; i < 2; i++ {
+		version, err := newUpdateChecker(st).latest(feed.URL)
+		if version != "0.99.0" || err != nil {
+			t.Errorf("latest -> (%q, %v), want (%q, nil)", version, err, "0.99.0")
+		}
+	}
+	if requests != 1 {
+		t.Errorf("feed requested %d times, want 1", requests)
+	}
+
+	// Saved results older than the interval are not used.
+	st.SetUpdateCheck(feed.URL, store.UpdateCheck{
+		Time: time.Now().Add(-updateCheckInterval), Version: "0.98.0"})
+	version, _ := newUpdateChecker(st).latest(feed.URL)
+	if version != "0.99.0" || requests != 2 {
+		t.Errorf("got version %q after %d requests, want %q after 2", version, requests, "0.99.0")
+	}
+
+	// Failures are saved too.
+	st.SetUpdateCheck(feed.URL, store.UpdateCheck{Time: time.Now(), Error: "bad feed"})
+	if _, err := newUpdateChecker(st).latest(feed.URL); err == nil || err.Error() != "bad feed" {
+		t.Errorf("got error %v, want bad feed", err)
+	}
+}
+
+func TestUpdateChecker_Errors(t *testing.T) {
+	feed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/not-found":
+			http.NotFound(w, r)
+		case "/invalid-json":
+			w.Write([]byte(`[`))
+		case "/no-tag":
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer feed.Close()
+
+	c := newUpdateChecker(nil)
+	for _, path := range []string{"/not-found", "/invalid-json", "/no-tag"} {
+		if _, err := c.latest(feed.URL + path); err == nil {
+			t.Errorf("latest(%q) -> nil error, want non-nil", path)
+		}
+	}
+}
@@ -100,14 +100,15 @@ func makeTransform(t pureTransformer) func(*tk.CodeBuffer) {
 
 // Implementation of pure movers.
 
+// Moving left and right moves over one extended grapheme cluster, so that the
+// dot is never inside a character as perceived by the user.
+
 func moveDotLeft(buffer string, dot int) int {
-	_, w := utf8.DecodeLastRuneInString(buffer[:dot])
-	return dot - w
+	return dot - wcwidth.LastCluster(buffer[:dot])
 }
 
 func moveDotRight(buffer string, dot int) int {
-	_, w := utf8.DecodeRuneInString(buffer[dot:])
-	return dot + w
+	return dot + wcwidth.NextCluster(buffer[dot:])
 }
 
 func moveDotSOL(buffer string, dot int) int {
@@ -324,6 +324,9 @@ func TestMoveDotLeftRight(t *testing.T) {
 		Args("精灵", 0).Rets(0),
 		Args("精灵", 3).Rets(0),
 		Args("精灵", 6).Rets(3),
+		// Extended grapheme clusters are moved over as a whole
+		Args("ae\u0301", 4).Rets(1),
+		Args("👍🏽", len("👍🏽")).Rets(0),
 	)
 
 	tt.Test(t, moveDotRight,
@@ -332,6 +335,8 @@ func TestMoveDotLeftRight(t *testing.T) {
 		Args("精灵", 0).Rets(3),
 		Args("精灵", 3).Rets(6),
 		Args("精灵", 6).Rets(6),
+		Args("e\u0301a", 0).Rets(3),
+		Args("🇨🇳🇺🇸", 0).Rets(len("🇨🇳")),
 	)
 }
 
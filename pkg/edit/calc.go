@@ -0,0 +1,273 @@
+package edit
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode"
+
+	"src.elv.sh/pkg/cli/modes"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/mods/math"
+	"src.elv.sh/pkg/parse"
+)
+
+func initCalc(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
+	bindingVar := newBindingVar(emptyBindingsMap)
+	bindings := newMapBindings(ed, ev, bindingVar)
+	app := ed.app
+	nb.AddNs("calc",
+		eval.BuildNsNamed("edit:calc").
+			AddVar("binding", bindingVar).
+			AddGoFns(map[string]any{
+				"start": func() {
+					w, err := modes.NewCalc(app, modes.CalcSpec{
+						Bindings: bindings,
+						Evaluate: func(expr string) (string, error) { return calcEvaluate(ev, expr) },
+					})
+					startMode(app, w, err)
+				},
+				"accept": func() {
+					w, ok := app.ActiveWidget().(modes.Calc)
+					if !ok {
+						notifyError(app, errNotInCalcMode)
+						return
+					}
+					notifyError(app, w.Accept())
+				},
+			}))
+}
+
+var errNotInCalcMode = errors.New("not in calc mode")
+
+// Evaluates an infix arithmetic expression. The expression is translated to
+// Elvish code using the arithmetic builtins and the math: module, so it
+// follows the same rules for exact and inexact numbers, except that
+// non-integer rationals in the result are converted to floating-point numbers.
+func calcEvaluate(ev *eval.Evaler, expr string) (string, error) {
+	code, err := translateCalc(expr)
+	if err != nil {
+		return "", err
+	}
+	port, collect, err := eval.ValueCapturePort()
+	if err != nil {
+		return "", err
+	}
+	err = ev.Eval(
+		parse.Source{Name: "[calc]", Code: "put " + code},
+		eval.EvalCfg{
+			Ports:  []*eval.Port{nil, port},
+			Global: eval.BuildNs().AddNs("math", math.Ns).Ns(),
+		})
+	values := collect()
+	if err != nil {
+		if exc, ok := err.(eval.Exception); ok {
+			// Use the reason without the stack trace.
+			err = exc.Reason()
+		}
+		return "", err
+	}
+	if len(values) != 1 {
+		return "", fmt.Errorf("expression evaluated to %d values", len(values))
+	}
+	result := values[0]
+	if r, ok := result.(*big.Rat); ok {
+		f, _ := r.Float64()
+		result = f
+	}
+	return vals.ToString(result), nil
+}
+
+// Functions in the math: module that can be called in calc expressions.
+var calcFns = map[string]bool{
+	"abs": true, "acos": true, "acosh": true, "asin": true, "asinh": true,
+	"atan": true, "atan2": true, "atanh": true, "ceil": true, "cos": true,
+	"cosh": true, "floor": true, "log": true, "log10": true, "log2": true,
+	"max": true, "min": true, "pow": true, "round": true, "sin": true,
+	"sinh": true, "sqrt": true, "tan": true, "tanh": true, "trunc": true,
+}
+
+// Translates an infix expression to Elvish code. The grammar is:
+//
+//	Expr    = Term { ('+' | '-') Term }
+//	Term    = Unary { ('*' | '/' | '%') Unary }
+//	Unary   = ('+' | '-') Unary | Power
+//	Power   = Primary [ '^' Unary ]
+//	Primary = Number | Name | Name '(' [ Expr { ',' Expr } ] ')' | '(' Expr ')'
+//
+// Names that are not followed by '(' must be constants in the math: module.
+func translateCalc(expr string) (string, error) {
+	p := &calcParser{src: expr}
+	p.next()
+	code, err := p.expr()
+	if err != nil {
+		return "", err
+	}
+	if p.tok != "" {
+		return "", fmt.Errorf("unexpected %q", p.tok)
+	}
+	return code, nil
+}
+
+type calcParser struct {
+	src string
+	pos int
+	// The current token, or "" at the end of input.
+	tok string
+}
+
+var errCalcUnexpectedEnd = errors.New("unexpected end of expression")
+
+func (p *calcParser) next() {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos == len(p.src) {
+		p.tok = ""
+		return
+	}
+	c := rune(p.src[p.pos])
+	switch {
+	case isCalcNumberRune(c):
+		for p.pos < len(p.src) {
+			c := rune(p.src[p.pos])
+			if isCalcNumberRune(c) || unicode.IsLetter(c) ||
+				((c == '+' || c == '-') && (p.src[p.pos-1] == 'e' || p.src[p.pos-1] == 'E')) {
+				p.pos++
+			} else {
+				break
+			}
+		}
+	case unicode.IsLetter(c):
+		for p.pos < len(p.src) && (unicode.IsLetter(rune(p.src[p.pos])) || unicode.IsDigit(rune(p.src[p.pos]))) {
+			p.pos++
+		}
+	default:
+		p.pos++
+	}
+	p.tok = p.src[start:p.pos]
+}
+
+func isCalcNumberRune(c rune) bool { return ('0' <= c && c <= '9') || c == '.' }
+
+func (p *calcParser) binary(operand func() (string, error), ops map[string]string) (string, error) {
+	lhs, err := operand()
+	if err != nil {
+		return "", err
+	}
+	for {
+		op, ok := ops[p.tok]
+		if !ok {
+			return lhs, nil
+		}
+		p.next()
+		rhs, err := operand()
+		if err != nil {
+			return "", err
+		}
+		lhs = "(" + op + " " + lhs + " " + rhs + ")"
+	}
+}
+
+func (p *calcParser) expr() (string, error) {
+	return p.binary(p.term, map[string]string{"+": "+", "-": "-"})
+}
+
+func (p *calcParser) term() (string, error) {
+	return p.binary(p.unary, map[string]string{"*": "*", "/": "/", "%": "%"})
+}
+
+func (p *calcParser) unary() (string, error) {
+	switch p.tok {
+	case "+":
+		p.next()
+		return p.unary()
+	case "-":
+		p.next()
+		operand, err := p.unary()
+		if err != nil {
+			return "", err
+		}
+		return "(- " + operand + ")", nil
+	}
+	return p.power()
+}
+
+func (p *calcParser) power() (string, error) {
+	base, err := p.primary()
+	if err != nil {
+		return "", err
+	}
+	if p.tok != "^" {
+		return base, nil
+	}
+	p.next()
+	exp, err := p.unary()
+	if err != nil {
+		return "", err
+	}
+	return "(math:pow " + base + " " + exp + ")", nil
+}
+
+func (p *calcParser) primary() (string, error) {
+	tok := p.tok
+	switch {
+	case tok == "":
+		return "", errCalcUnexpectedEnd
+	case tok == "(":
+		p.next()
+		code, err := p.expr()
+		if err != nil {
+			return "", err
+		}
+		if err := p.expect(")"); err != nil {
+			return "", err
+		}
+		return code, nil
+	case isCalcNumberRune(rune(tok[0])):
+		p.next()
+		return "(num " + parse.Quote(tok) + ")", nil
+	case unicode.IsLetter(rune(tok[0])):
+		p.next()
+		if p.tok != "(" {
+			if tok == "pi" || tok == "e" {
+				return "$math:" + tok, nil
+			}
+			return "", fmt.Errorf("unknown constant %s", tok)
+		}
+		if !calcFns[tok] {
+			return "", fmt.Errorf("unknown function %s", tok)
+		}
+		p.next()
+		var args []string
+		for p.tok != ")" {
+			if len(args) > 0 {
+				if err := p.expect(","); err != nil {
+					return "", err
+				}
+			}
+			arg, err := p.expr()
+			if err != nil {
+				return "", err
+			}
+			args = append(args, arg)
+		}
+		p.next()
+		return "(math:" + tok + " " + strings.Join(args, " ") + ")", nil
+	}
+	return "", fmt.Errorf("unexpected %q", tok)
+}
+
+func (p *calcParser) expect(tok string) error {
+	if p.tok == "" {
+		return errCalcUnexpectedEnd
+	}
+	if p.tok != tok {
+		return fmt.Errorf("unexpected %q, expecting %q", p.tok, tok)
+	}
+	p.next()
+	return nil
+}
@@ -0,0 +1,98 @@
+package edit
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/ui"
+)
+
+func TestCalc(t *testing.T) {
+	f := setup(t)
+
+	evals(f.Evaler, `edit:calc:start`)
+	f.TTYCtrl.Inject(term.K('1'), term.K('/'), term.K('4'))
+	f.TestTTY(t,
+		"~> ", "\n",
+		" CALC  1/4", Styles,
+		"******    ", term.DotHere, "\n",
+		"= 0.25",
+	)
+
+	f.TTYCtrl.Inject(term.K('\n'))
+	f.TestTTY(t,
+		"~> 0.25", Styles,
+		"   !!!!", term.DotHere,
+	)
+}
+
+func TestCalc_AcceptInvalidExpression(t *testing.T) {
+	f := setup(t)
+
+	evals(f.Evaler, `edit:calc:start`)
+	f.TTYCtrl.Inject(term.K('1'), term.K('+'))
+	f.TestTTY(t,
+		"~> ", "\n",
+		" CALC  1+", Styles,
+		"******   ", term.DotHere, "\n",
+		"unexpected end of expression", Styles,
+		"!!!!!!!!!!!!!!!!!!!!!!!!!!!!",
+	)
+
+	f.TTYCtrl.Inject(term.K('\n'))
+	f.TTYCtrl.TestMsg(t,
+		ui.Concat(ui.T("error:", ui.FgRed), ui.T(" unexpected end of expression")))
+}
+
+func TestCalc_NotInMode(t *testing.T) {
+	f := setup(t)
+
+	evals(f.Evaler, `edit:calc:accept`)
+	f.TTYCtrl.TestMsg(t,
+		ui.Concat(ui.T("error:", ui.FgRed), ui.T(" not in calc mode")))
+}
+
+var calcEvaluateTests = []struct {
+	expr    string
+	want    string
+	wantErr string
+}{
+	{expr: "1 + 2 * 3", want: "7"},
+	{expr: "(1 + 2) * 3", want: "9"},
+	{expr: "10 - 4 - 3", want: "3"},
+	{expr: "7 % 3", want: "1"},
+	{expr: "1/4", want: "0.25"},
+	{expr: "-2^2", want: "-4"},
+	{expr: "2^3^2", want: "512"},
+	{expr: "2^-1", want: "0.5"},
+	{expr: "1.5e2 + 0x10", want: "166.0"},
+	{expr: "sqrt(16)", want: "4.0"},
+	{expr: "max(1, 5, 3)", want: "5"},
+	{expr: "floor(pi)", want: "3.0"},
+
+	{expr: "1 +", wantErr: "unexpected end of expression"},
+	{expr: "1 2", wantErr: `unexpected "2"`},
+	{expr: "(1", wantErr: "unexpected end of expression"},
+	{expr: "max(1 2)", wantErr: `unexpected "2", expecting ","`},
+	{expr: "foo(1)", wantErr: "unknown function foo"},
+	{expr: "x", wantErr: "unknown constant x"},
+	{expr: "1 & 2", wantErr: `unexpected "&"`},
+	{expr: "1x", wantErr: "wrong type for arg #0: cannot parse as number: 1x"},
+}
+
+func TestCalcEvaluate(t *testing.T) {
+	ev := eval.NewEvaler()
+	for _, test := range calcEvaluateTests {
+		got, err := calcEvaluate(ev, test.expr)
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("calcEvaluate(%q) -> error %v, want %q", test.expr, err, test.wantErr)
+			}
+			continue
+		}
+		if got != test.want || err != nil {
+			t.Errorf("calcEvaluate(%q) -> (%q, %v), want (%q, nil)", test.expr, got, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package edit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"src.elv.sh/pkg/edit/complete"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/ui"
+)
+
+var (
+	errHelpParserNotFn = errors.New("help parser is not a function")
+	errHelpNotInPath   = errors.New("flags can only be completed for commands in $E:PATH")
+)
+
+// How long to wait for the output of "cmd --help".
+const helpTimeout = 2 * time.Second
+
+// Caches the help text of external commands, keyed by the path of the
+// executable. An entry is invalidated when the executable is modified.
+// Failures are cached too, so that a command without any help output is not run
+// again on every completion.
+type helpCache struct {
+	mutex   sync.Mutex
+	entries map[string]helpCacheEntry
+}
+
+type helpCacheEntry struct {
+	modTime time.Time
+	text    string
+	err     error
+}
+
+func (c *helpCache) get(path string) (string, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	c.mutex.Lock()
+	entry, ok := c.entries[path]
+	c.mutex.Unlock()
+	if ok && entry.modTime.Equal(stat.ModTime()) {
+		return entry.text, entry.err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), helpTimeout)
+	defer cancel()
+	// Many commands exit with a non-zero status or write to stderr when asked
+	// for help, so the error is ignored as long as there is some output.
+	out, err := exec.CommandContext(ctx, path, "--help").CombinedOutput()
+	text := string(out)
+	if len(out) == 0 {
+		if err == nil {
+			err = errors.New("no output from --help")
+		}
+	} else {
+		err = nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]helpCacheEntry)
+	}
+	c.entries[path] = helpCacheEntry{stat.ModTime(), text, err}
+	return text, err
+}
+
+// Generates candidates for the flags of an external command by parsing the
+// output of "cmd --help". The help text is parsed by the function in parsers
+// keyed by the command name if it exists, or parseHelp otherwise.
+//
+// Only commands found in an absolute directory of $E:PATH are run, and they
+// are subject to the restricted mode of the Evaler.
+func generateFlags(ev *eval.Evaler, cache *helpCache, parsers vals.Map, args []string) ([]complete.RawItem, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	name := strings.TrimPrefix(args[0], "e:")
+	if strings.ContainsAny(name, "/"+string(filepath.Separator)) {
+		return nil, errHelpNotInPath
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return nil, err
+	}
+	if !filepath.IsAbs(path) {
+		return nil, errHelpNotInPath
+	}
+	if err := ev.CheckExternal(path); err != nil {
+		return nil, err
+	}
+	help, err := cache.get(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if v, ok := parsers.Index(name); ok {
+		parser, ok := v.(eval.Callable)
+		if !ok {
+			return nil, errHelpParserNotFn
+		}
+		port1, collect, err := eval.ValueCapturePort()
+		if err != nil {
+			return nil, err
+		}
+		err = ev.Call(parser,
+			eval.CallCfg{Args: []any{help}, From: "[editor help parser]"},
+			eval.EvalCfg{Ports: []*eval.Port{nil, port1, {File: os.Stderr}}})
+		var items []complete.RawItem
+		for _, v := range collect() {
+			switch v := v.(type) {
+			case complexItem:
+				items = append(items, complete.ComplexItem(v))
+			default:
+				items = append(items, complete.PlainItem(vals.ToString(v)))
+			}
+		}
+		return items, err
+	}
+
+	var items []complete.RawItem
+	for _, flag := range parseHelp(help) {
+		c := complete.ComplexItem{Stem: flag.name}
+		if flag.desc != "" {
+			c.Display = ui.T(flag.name + " (" + flag.desc + ")")
+		}
+		items = append(items, c)
+	}
+	return items, nil
+}
+
+// Reports whether the default argument completer should complete the flags of
+// cmd with generateFlags, which is the case when cmd refers to an external
+// command listed in helpCommands.
+func completesFlagsFromHelp(ev *eval.Evaler, helpCommands vals.List, cmd string) bool {
+	name := strings.TrimPrefix(cmd, "e:")
+	for it := helpCommands.Iterator(); it.HasElem(); it.Next() {
+		if vals.ToString(it.Elem()) == name {
+			return isExternalCommand(ev, cmd)
+		}
+	}
+	return false
+}
+
+// Reports whether cmd refers to an external command.
+func isExternalCommand(ev *eval.Evaler, cmd string) bool {
+	if rest, ok := strings.CutPrefix(cmd, "e:"); ok {
+		return hasExternalCommand(rest)
+	}
+	if eval.IsBuiltinSpecial[cmd] || strings.Contains(cmd, ":") ||
+		hasFn(ev.Builtin(), cmd) || hasFn(ev.Global(), cmd) {
+		return false
+	}
+	return hasExternalCommand(cmd)
+}
+
+type helpFlag struct {
+	name string
+	desc string
+}
+
+// Parses flags and their descriptions from a help text in the common format
+// of GNU and many other programs, where each flag is listed on its own line
+// like one of the following:
+//
+//	-a, --all             do not ignore entries starting with .
+//	    --color[=WHEN]    colorize the output
+//	-w, --width=COLS
+//	                      set output width to COLS
+//
+// The description is separated from the flags by at least 2 spaces or a tab,
+// or is on the next line with a deeper indentation.
+func parseHelp(help string) []helpFlag {
+	lines := strings.Split(help, "\n")
+	var flags []helpFlag
+	seen := make(map[string]bool)
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " \t")
+		if !strings.HasPrefix(trimmed, "-") {
+			continue
+		}
+		spec, desc := trimmed, ""
+		if j := descIndex(trimmed); j != -1 {
+			spec, desc = trimmed[:j], strings.TrimSpace(trimmed[j:])
+		}
+		if desc == "" && i+1 < len(lines) {
+			next := lines[i+1]
+			nextTrimmed := strings.TrimLeft(next, " \t")
+			if len(next)-len(nextTrimmed) > len(line)-len(trimmed) &&
+				!strings.HasPrefix(nextTrimmed, "-") {
+				desc = strings.TrimSpace(nextTrimmed)
+			}
+		}
+		for _, field := range strings.FieldsFunc(spec, func(r rune) bool {
+			return r == ',' || r == ' '
+		}) {
+			name := field
+			if j := strings.IndexAny(field, "=[<"); j != -1 {
+				name = field[:j]
+			}
+			if !isFlagName(name) || seen[name] {
+				continue
+			}
+			seen[name] = true
+			flags = append(flags, helpFlag{name, desc})
+		}
+	}
+	return flags
+}
+
+// Returns the index of the first tab or 2 consecutive spaces in s, or -1 if
+// there is none.
+func descIndex(s string) int {
+	i, j := strings.Index(s, "\t"), strings.Index(s, "  ")
+	if i == -1 || (j != -1 && j < i) {
+		return j
+	}
+	return i
+}
+
+func isFlagName(s string) bool {
+	body := strings.TrimPrefix(strings.TrimPrefix(s, "-"), "-")
+	if body == "" || len(s)-len(body) == 0 {
+		return false
+	}
+	for i, r := range body {
+		if !(r == '-' && i > 0 || r == '_' || r == '?' ||
+			'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
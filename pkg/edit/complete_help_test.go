@@ -0,0 +1,202 @@
+package edit
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/env"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/must"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/testutil"
+	"src.elv.sh/pkg/ui"
+)
+
+// Only uses builtin commands of sh, since PATH only contains the directory of
+// the fake command.
+const fakeHelpScript = `#!/bin/sh
+echo run >> runs
+echo 'Usage: fake [OPTION]... [FILE]...'
+echo
+echo 'Options:'
+echo '  -a, --all        show all'
+echo '      --color[=WHEN]'
+echo '                   colorize the output'
+echo '  -n NUM           number of lines'
+`
+
+func setupFakeCommand(t *testing.T) *fixture {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake command is a shell script")
+	}
+	f := setup(t)
+	bin := filepath.Join(f.Home, "bin")
+	testutil.Setenv(t, env.PATH, bin)
+	mustMkdirAll(bin)
+	err := os.WriteFile(filepath.Join(bin, "fake"), []byte(fakeHelpScript), 0700)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+func TestCompletion_FlagsFromHelp(t *testing.T) {
+	f := setupFakeCommand(t)
+
+	evals(f.Evaler, `set edit:completion:help-commands = [fake]`)
+	feedInput(f.TTYCtrl, "fake -")
+	f.TTYCtrl.Inject(term.K(ui.Tab))
+	f.TestTTY(t,
+		"~> fake --all\n", Styles,
+		"   vvvv _____",
+		" COMPLETING argument  ", Styles,
+		"********************* ", term.DotHere, "\n",
+		"--all (show all)             \n", Styles,
+		"+++++++++++++++++++++++++++++",
+		"--color (colorize the output)\n",
+		"-a (show all)                \n",
+		"-n (number of lines)         ",
+	)
+}
+
+func TestCompletion_FlagsFromHelp_NotInHelpCommands(t *testing.T) {
+	f := setupFakeCommand(t)
+
+	// Filenames are completed instead.
+	must.OK(os.WriteFile("-file", nil, 0600))
+	evals(f.Evaler, `set edit:completion:help-commands = [other]`)
+	feedInput(f.TTYCtrl, "fake -")
+	f.TTYCtrl.Inject(term.K(ui.Tab))
+	f.TestTTY(t,
+		"~> fake -file ", Styles,
+		"   vvvv       ", term.DotHere,
+	)
+	testFileExists(t, "runs", false)
+}
+
+func TestCompleteFlags_DoesNotRunRelativePaths(t *testing.T) {
+	f := setupFakeCommand(t)
+	testutil.Setenv(t, env.PATH, "bin")
+
+	for _, code := range []string{
+		`edit:complete-flags ./bin/fake -`, `edit:complete-flags bin/fake -`,
+		// Found through a relative directory in $E:PATH.
+		`edit:complete-flags fake -`,
+	} {
+		if err := evalErr(f.Evaler, code); err == nil {
+			t.Errorf("%s: got no error", code)
+		}
+	}
+	testFileExists(t, "runs", false)
+}
+
+func TestCompleteFlags_RespectsRestrictedMode(t *testing.T) {
+	f := setupFakeCommand(t)
+	must.OK(f.Evaler.Restrict(eval.Restrictions{}))
+
+	err := evalErr(f.Evaler, `edit:complete-flags fake -`)
+	if err == nil || !strings.Contains(err.Error(), "restricted mode") {
+		t.Errorf("got error %v, want restricted mode error", err)
+	}
+	testFileExists(t, "runs", false)
+}
+
+func TestCompleteFlags_CachesFailures(t *testing.T) {
+	f := setupFakeCommand(t)
+	err := os.WriteFile(filepath.Join(f.Home, "bin", "quiet"),
+		[]byte("#!/bin/sh\necho run >> runs\nexit 1\n"), 0700)
+	if err != nil {
+		panic(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := evalErr(f.Evaler, `edit:complete-flags quiet -`); err == nil {
+			t.Errorf("got no error")
+		}
+	}
+	runs, _ := os.ReadFile("runs")
+	if n := strings.Count(string(runs), "run"); n != 1 {
+		t.Errorf("quiet --help was run %d times, want 1", n)
+	}
+}
+
+func TestCompleteFlags_CachesHelp(t *testing.T) {
+	f := setupFakeCommand(t)
+
+	evals(f.Evaler,
+		`var @a = (edit:complete-flags fake --a)`,
+		`var @b = (edit:complete-flags fake --c)`)
+	want := vals.MakeList(
+		complexItem{Stem: "-a", Display: ui.T("-a (show all)")},
+		complexItem{Stem: "--all", Display: ui.T("--all (show all)")},
+		complexItem{Stem: "--color", Display: ui.T("--color (colorize the output)")},
+		complexItem{Stem: "-n", Display: ui.T("-n (number of lines)")})
+	testGlobal(t, f.Evaler, "a", want)
+	testGlobal(t, f.Evaler, "b", want)
+	runs, _ := os.ReadFile("runs")
+	if n := strings.Count(string(runs), "run"); n != 1 {
+		t.Errorf("fake --help was run %d times, want 1", n)
+	}
+}
+
+func TestCompleteFlags_HelpParser(t *testing.T) {
+	f := setupFakeCommand(t)
+
+	evals(f.Evaler,
+		`set edit:completion:help-parser[fake] = {|help| put $help[0..5] }`,
+		`var @cands = (edit:complete-flags fake -)`)
+	testGlobal(t, f.Evaler, "cands", vals.MakeList("Usage"))
+}
+
+func evalErr(ev *eval.Evaler, code string) error {
+	return ev.Eval(parse.Source{Name: "[test]", Code: code}, eval.EvalCfg{})
+}
+
+var parseHelpTests = []struct {
+	name string
+	help string
+	want []helpFlag
+}{
+	{
+		name: "flags and descriptions on the same line",
+		help: "  -a, --all    do not ignore\n  -l\tuse long format\n",
+		want: []helpFlag{
+			{"-a", "do not ignore"}, {"--all", "do not ignore"},
+			{"-l", "use long format"}},
+	},
+	{
+		name: "description on the next line",
+		help: "  -w, --width=COLS\n        set output width\n  -x\n  -y  why\n",
+		want: []helpFlag{
+			{"-w", "set output width"}, {"--width", "set output width"},
+			{"-x", ""}, {"-y", "why"}},
+	},
+	{
+		name: "flag arguments",
+		help: "--color[=WHEN]  colorize\n-n NUM  lines\n--file <path>  file\n",
+		want: []helpFlag{
+			{"--color", "colorize"}, {"-n", "lines"}, {"--file", "file"}},
+	},
+	{
+		name: "non-flag lines and duplicates",
+		help: "Usage: ls\n - a bullet\n  --  end of options\n-a  first\n-a  second\n",
+		want: []helpFlag{{"-a", "first"}},
+	},
+}
+
+func TestParseHelp(t *testing.T) {
+	for _, test := range parseHelpTests {
+		t.Run(test.name, func(t *testing.T) {
+			got := parseHelp(test.help)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
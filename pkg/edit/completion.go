@@ -55,6 +55,9 @@ func completionStart(ed *Editor, bindings tk.Bindings, ev *eval.Evaler, cfg comp
 		return
 	}
 	if smart {
+		if ed.snippetNext() {
+			return
+		}
 		ed.applyAutofix()
 	}
 	buf := codeArea.CopyState().Buffer
@@ -110,12 +113,19 @@ func initCompletion(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
 	bindings := newMapBindings(ed, ev, bindingVar)
 	matcherMapVar := newMapVar(vals.EmptyMap)
 	argGeneratorMapVar := newMapVar(vals.EmptyMap)
+	helpParserMapVar := newMapVar(vals.EmptyMap)
+	helpCommandsVar := newListVar(vals.EmptyList)
+	var cache helpCache
+	generateFlagsFn := func(args []string) ([]complete.RawItem, error) {
+		return generateFlags(ev, &cache, helpParserMapVar.Get().(vals.Map), args)
+	}
 	cfg := func() complete.Config {
 		return complete.Config{
 			Filterer: adaptMatcherMap(
 				ed, ev, matcherMapVar.Get().(vals.Map)),
 			ArgGenerator: adaptArgGeneratorMap(
-				ev, argGeneratorMapVar.Get().(vals.Map)),
+				ev, argGeneratorMapVar.Get().(vals.Map),
+				helpCommandsVar.Get().(vals.List), generateFlagsFn),
 		}
 	}
 	generateForSudo := func(args []string) ([]complete.RawItem, error) {
@@ -124,6 +134,7 @@ func initCompletion(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
 	nb.AddGoFns(map[string]any{
 		"complete-filename": wrapArgGenerator(complete.GenerateFileNames),
 		"complete-dirname":  wrapArgGenerator(complete.GenerateDirNames),
+		"complete-flags":    wrapArgGenerator(generateFlagsFn),
 		"complete-getopt":   completeGetopt,
 		"complete-sudo":     wrapArgGenerator(generateForSudo),
 		"complex-candidate": complexCandidate,
@@ -137,6 +148,8 @@ func initCompletion(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
 			AddVars(map[string]vars.Var{
 				"arg-completer": argGeneratorMapVar,
 				"binding":       bindingVar,
+				"help-commands": helpCommandsVar,
+				"help-parser":   helpParserMapVar,
 				"matcher":       matcherMapVar,
 			}).
 			AddGoFns(map[string]any{
@@ -149,6 +162,11 @@ func initCompletion(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
 				"down-cycle":  func() { listingDownCycle(app) },
 				"left":        func() { listingLeft(app) },
 				"right":       func() { listingRight(app) },
+				"toggle-mark": func() {
+					if w, ok := app.ActiveWidget().(modes.Completion); ok {
+						w.ToggleMark()
+					}
+				},
 			}))
 }
 
@@ -343,13 +361,21 @@ func adaptMatcherMap(nt notifier, ev *eval.Evaler, m vals.Map) complete.Filterer
 	}
 }
 
-func adaptArgGeneratorMap(ev *eval.Evaler, m vals.Map) complete.ArgGenerator {
+// Adapts $edit:completion:arg-completer into an ArgGenerator. When there is no
+// arg completer for a command, flags of external commands in helpCommands are
+// completed with generateFlags, and filenames are completed otherwise.
+func adaptArgGeneratorMap(ev *eval.Evaler, m vals.Map, helpCommands vals.List, generateFlags complete.ArgGenerator) complete.ArgGenerator {
 	return func(args []string) ([]complete.RawItem, error) {
 		gen, ok := lookupFn(m, args[0])
 		if !ok {
 			return nil, fmt.Errorf("arg completer for %s not a function", args[0])
 		}
 		if gen == nil {
+			if len(args) > 1 && strings.HasPrefix(args[len(args)-1], "-") && completesFlagsFromHelp(ev, helpCommands, args[0]) {
+				if items, err := generateFlags(args); err == nil && len(items) > 0 {
+					return items, nil
+				}
+			}
 			return complete.GenerateFileNames(args)
 		}
 		argValues := make([]any, len(args))
@@ -26,6 +26,28 @@ func TestCompletionAddon(t *testing.T) {
 	)
 }
 
+func TestCompletionAddon_ToggleMark(t *testing.T) {
+	f := setup(t)
+
+	testutil.ApplyDir(testutil.Dir{"a": "", "b": "", "c": ""})
+
+	feedInput(f.TTYCtrl, "echo \t")
+	f.TTYCtrl.Inject(term.K('T', ui.Ctrl), term.K('T', ui.Ctrl))
+	f.TestTTY(t,
+		"~> echo a b \n", Styles,
+		"   vvvv ____",
+		" COMPLETING argument  ", Styles,
+		"********************* ", term.DotHere, "\n",
+		"a  b  c", Styles,
+		"_  _  +",
+	)
+	f.TTYCtrl.Inject(term.K(ui.Enter))
+	f.TestTTY(t,
+		"~> echo a b ", Styles,
+		"   vvvv", term.DotHere,
+	)
+}
+
 func TestCompletionAddon_CompletesLongestCommonPrefix(t *testing.T) {
 	f := setup(t)
 
@@ -3,7 +3,9 @@ package edit
 import (
 	"fmt"
 	"os"
+	"regexp"
 	"strings"
+	"time"
 
 	"src.elv.sh/pkg/cli"
 	"src.elv.sh/pkg/cli/histutil"
@@ -11,13 +13,15 @@ import (
 	"src.elv.sh/pkg/eval"
 	"src.elv.sh/pkg/eval/vals"
 	"src.elv.sh/pkg/eval/vars"
+	"src.elv.sh/pkg/parse"
 	"src.elv.sh/pkg/store/storedefs"
 )
 
-func initMaxHeight(appSpec *cli.AppSpec, nb eval.NsBuilder) {
+func initMaxHeight(appSpec *cli.AppSpec, ev *eval.Evaler, nb eval.NsBuilder) {
 	maxHeight := newIntVar(-1)
 	appSpec.MaxHeight = func() int { return maxHeight.GetRaw().(int) }
-	nb.AddVar("max-height", maxHeight)
+	nb.AddVar("max-height", ev.RegisterOption(
+		eval.OptionSpec{Name: "edit:max-height", Default: -1}, maxHeight))
 }
 
 func initReadlineHooks(appSpec *cli.AppSpec, ev *eval.Evaler, nb eval.NsBuilder) {
@@ -41,20 +45,65 @@ func initAfterReadline(appSpec *cli.AppSpec, ev *eval.Evaler, nb eval.NsBuilder)
 	})
 }
 
-func initAddCmdFilters(appSpec *cli.AppSpec, ev *eval.Evaler, nb eval.NsBuilder, s histutil.Store) {
+func initAddCmdFilters(appSpec *cli.AppSpec, ed *Editor, ev *eval.Evaler, nb eval.NsBuilder, s histutil.Store, st storedefs.Store) {
 	ignoreLeadingSpace := eval.NewGoFn("<ignore-cmd-with-leading-space>",
 		func(s string) bool { return !strings.HasPrefix(s, " ") })
 	filters := newListVar(vals.MakeList(ignoreLeadingSpace))
 	nb.AddVar("add-cmd-filters", filters)
+	ignorePatterns := newListVar(vals.EmptyList)
+	nb.AddVar("add-cmd-ignore-patterns", ignorePatterns)
 
+	// The command added to the database in the current REPL cycle, whose
+	// result is recorded after it has been run.
+	var pendingSeq int
+	var pendingDir string
 	appSpec.AfterReadline = append(appSpec.AfterReadline, func(code string) {
+		pendingSeq = 0
 		if code != "" &&
+			!matchIgnorePatterns("$<edit>:add-cmd-ignore-patterns",
+				ignorePatterns.Get().(vals.List), code) &&
 			callFilters(ev, "$<edit>:add-cmd-filters",
 				filters.Get().(vals.List), code) {
-			s.AddCmd(storedefs.Cmd{Text: code, Seq: -1})
+			seq, err := s.AddCmd(storedefs.Cmd{Text: code, Seq: -1})
+			// The store returns 0 when the command matches its own ignore
+			// patterns.
+			if err == nil && seq > 0 && st != nil {
+				pendingSeq = seq
+				pendingDir, _ = os.Getwd()
+				if root := ed.workspaceOf(pendingDir); root != "" {
+					st.SetCmdWorkspace(seq, root)
+				}
+			}
 		}
 		// TODO(xiaq): Handle the error.
 	})
+	ed.AfterCommand = append(ed.AfterCommand,
+		func(src parse.Source, duration float64, err error) {
+			if pendingSeq == 0 {
+				return
+			}
+			st.SetCmdResult(pendingSeq, pendingDir, storedefs.CmdResult{
+				Duration: time.Duration(duration * float64(time.Second)),
+				Exit:     exitStatus(err)})
+			pendingSeq = 0
+		})
+}
+
+// Returns the exit status of a command line that has been run, following the
+// convention documented in [storedefs.CmdResult].
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exc, ok := err.(eval.Exception); ok {
+		if exit, ok := exc.Reason().(eval.ExternalCmdExit); ok {
+			if exit.Signaled() {
+				return 128 + int(exit.Signal())
+			}
+			return exit.ExitStatus()
+		}
+	}
+	return 1
 }
 
 func initGlobalBindings(appSpec *cli.AppSpec, nt notifier, ev *eval.Evaler, nb eval.NsBuilder) {
@@ -109,6 +158,30 @@ func callFilters(ev *eval.Evaler, name string, filters vals.List, args ...any) b
 }
 
 // TODO: This is not testable as it depends on stderr. Make it testable.
+// Returns whether the command matches any of the regular expressions in the
+// list. Invalid elements are treated as matching, so that a mistake in the list
+// doesn't cause sensitive commands to be saved.
+func matchIgnorePatterns(name string, patterns vals.List, cmd string) bool {
+	i := -1
+	for it := patterns.Iterator(); it.HasElem(); it.Next() {
+		i++
+		s, ok := it.Elem().(string)
+		if !ok {
+			complain("%s[%d] not string", name, i)
+			return true
+		}
+		re, err := regexp.Compile(s)
+		if err != nil {
+			complain("%s[%d] invalid regular expression: %v", name, i, err)
+			return true
+		}
+		if re.MatchString(cmd) {
+			return true
+		}
+	}
+	return false
+}
+
 func complain(format string, args ...any) {
 	diag.ShowError(os.Stderr, fmt.Errorf(format, args...))
 }
@@ -1,9 +1,13 @@
 package edit
 
 import (
+	"errors"
+	"reflect"
 	"testing"
+	"time"
 
 	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/parse"
 	"src.elv.sh/pkg/store/storedefs"
 	"src.elv.sh/pkg/ui"
 )
@@ -107,6 +111,24 @@ func TestAddCmdFilters(t *testing.T) {
 			input:       " echo\n",
 			wantHistory: nil,
 		},
+		{
+			name:        "ignore pattern matches",
+			rc:          "set edit:add-cmd-ignore-patterns = [AWS_SECRET 'password=']",
+			input:       "curl -d password=foo\n",
+			wantHistory: nil,
+		},
+		{
+			name:        "ignore pattern doesn't match",
+			rc:          "set edit:add-cmd-ignore-patterns = [AWS_SECRET 'password=']",
+			input:       "echo\n",
+			wantHistory: []storedefs.Cmd{{Text: "echo", Seq: 1}},
+		},
+		{
+			name:        "invalid ignore pattern",
+			rc:          "set edit:add-cmd-ignore-patterns = ['(']",
+			input:       "echo\n",
+			wantHistory: nil,
+		},
 	}
 
 	for _, c := range cases {
@@ -133,6 +155,23 @@ func TestAddCmdFilters_SkipsRemainingOnFalse(t *testing.T) {
 	testGlobal(t, f.Evaler, "called", false)
 }
 
+func TestAddCmdFilters_RecordsResult(t *testing.T) {
+	f := setup(t)
+
+	feedInput(f.TTYCtrl, "echo\n")
+	f.Wait()
+	f.Editor.RunAfterCommandHooks(parse.Source{}, 1.5, errors.New("failed"))
+
+	records, err := f.Store.CmdRecords(0, -1)
+	want := &storedefs.CmdResult{Duration: 1500 * time.Millisecond, Exit: 1}
+	if len(records) != 1 || err != nil {
+		t.Fatalf("got records (%v, %v), want 1 record", records, err)
+	}
+	if r := records[0]; r.Dir != f.Home || !reflect.DeepEqual(r.Result, want) {
+		t.Errorf("got dir %q and result %v, want %q and %v", r.Dir, r.Result, f.Home, want)
+	}
+}
+
 func TestGlobalBindings(t *testing.T) {
 	f := setup(t, rc(
 		`var called = $false`,
@@ -145,3 +184,18 @@ func TestGlobalBindings(t *testing.T) {
 
 	testGlobal(t, f.Evaler, "called", true)
 }
+
+func TestOptions(t *testing.T) {
+	f := setup(t)
+
+	evals(f.Evaler,
+		`option edit:max-height 10`,
+		`var max-height = $edit:max-height`,
+		`var dump = (option &dump | slurp)`,
+		`var err = ?(set edit:prompt-stale-threshold = -1)`)
+	testGlobal(t, f.Evaler, "max-height", 10)
+	testGlobal(t, f.Evaler, "dump", "option edit:max-height (num 10)\n")
+	if _, hasErr := getGlobal(f.Evaler, "err").(error); !hasErr {
+		t.Errorf("setting edit:prompt-stale-threshold to -1 did not result in error")
+	}
+}
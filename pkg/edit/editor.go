@@ -33,6 +33,19 @@ type Editor struct {
 	// edit:completion:smart-start to apply the autofix easily. This field is
 	// set in initHighlighter.
 	applyAutofix func()
+	// Similarly, this lets edit:completion:smart-start move to the next tab
+	// stop of an active snippet. It reports whether there was an active
+	// snippet. This field is set in initSnippet.
+	snippetNext func() bool
+	// Returns the values of $edit:check-updates and $edit:update-feed. This
+	// field is set in initUpdateCheck.
+	updateCheckConfig func() (bool, string)
+	// Returns the root of the workspace containing dir according to
+	// $edit:location:workspaces, or "" if there is none. This field is set in
+	// initLocation.
+	workspaceOf func(dir string) string
+
+	recovery bufferRecovery
 
 	// Maybe move this to another type that represents the REPL cycle as a whole, not just the
 	// read/edit portion represented by the Editor type.
@@ -56,20 +69,19 @@ func NewEditor(tty cli.TTY, ev *eval.Evaler, st storedefs.Store) *Editor {
 	ed := &Editor{excList: vals.EmptyList}
 	ed.autofix.Store("")
 	nb := eval.BuildNsNamed("edit")
-	appSpec := cli.AppSpec{TTY: tty}
+	macroTTY := &macroTTY{TTY: tty}
+	appSpec := cli.AppSpec{TTY: macroTTY}
 
-	hs, err := newHistStore(st)
-	if err != nil {
-		_ = err // TODO(xiaq): Report the error.
-	}
+	hs := newHistStore(st)
 
-	initMaxHeight(&appSpec, nb)
+	initMaxHeight(&appSpec, ev, nb)
 	initReadlineHooks(&appSpec, ev, nb)
-	initAddCmdFilters(&appSpec, ev, nb, hs)
+	initAddCmdFilters(&appSpec, ed, ev, nb, hs, st)
 	initGlobalBindings(&appSpec, ed, ev, nb)
 	initInsertAPI(&appSpec, ed, ev, nb)
 	initHighlighter(&appSpec, ed, ev, nb)
 	initPrompts(&appSpec, ed, ev, nb)
+	initBufferRecovery(&appSpec, &ed.recovery)
 	ed.app = cli.NewApp(appSpec)
 
 	initExceptionsAPI(ed, nb)
@@ -79,8 +91,11 @@ func NewEditor(tty cli.TTY, ev *eval.Evaler, st storedefs.Store) *Editor {
 	initNavigation(ed, ev, nb)
 	initCompletion(ed, ev, nb)
 	initHistWalk(ed, ev, hs, nb)
+	initHistsearch(ed, ev, hs, nb)
 	initInstant(ed, ev, nb)
 	initMinibuf(ed, ev, nb)
+	initCalc(ed, ev, nb)
+	initMacro(ed, macroTTY, nb)
 
 	initRepl(ed, ev, nb)
 	initBufferBuiltins(ed.app, nb)
@@ -88,6 +103,7 @@ func NewEditor(tty cli.TTY, ev *eval.Evaler, st storedefs.Store) *Editor {
 	initMiscBuiltins(ed, nb)
 	initStateAPI(ed.app, nb)
 	initStoreAPI(ed.app, nb, hs)
+	initUpdateCheck(ed, ev, nb)
 
 	ed.ns = nb.Ns()
 	initElvishState(ev, ed.ns)
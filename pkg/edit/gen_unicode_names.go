@@ -0,0 +1,119 @@
+//go:build ignore
+
+// Generates unicode_names.txt from UnicodeData.txt in the Unicode Character
+// Database. When no path is given, the latest version is downloaded from
+// unicodeDataURL.
+//
+// Usage: go run gen_unicode_names.go [path/to/UnicodeData.txt]
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Blocks of characters to include. Including all named characters would make
+// the table more than 40 times as large, with most entries being characters
+// that are rarely typed by hand.
+var ranges = [][2]rune{
+	{0x00A0, 0x00FF},   // Latin-1 Supplement
+	{0x0370, 0x03FF},   // Greek and Coptic
+	{0x2000, 0x206F},   // General Punctuation
+	{0x20A0, 0x20CF},   // Currency Symbols
+	{0x2100, 0x214F},   // Letterlike Symbols
+	{0x2150, 0x218F},   // Number Forms
+	{0x2190, 0x21FF},   // Arrows
+	{0x2200, 0x22FF},   // Mathematical Operators
+	{0x2300, 0x23FF},   // Miscellaneous Technical
+	{0x2460, 0x24FF},   // Enclosed Alphanumerics
+	{0x2500, 0x257F},   // Box Drawing
+	{0x2580, 0x259F},   // Block Elements
+	{0x25A0, 0x25FF},   // Geometric Shapes
+	{0x2600, 0x26FF},   // Miscellaneous Symbols
+	{0x2700, 0x27BF},   // Dingbats
+	{0x2B00, 0x2BFF},   // Miscellaneous Symbols and Arrows
+	{0x1F300, 0x1F5FF}, // Miscellaneous Symbols and Pictographs
+	{0x1F600, 0x1F64F}, // Emoticons
+	{0x1F680, 0x1F6FF}, // Transport and Map Symbols
+	{0x1F900, 0x1F9FF}, // Supplemental Symbols and Pictographs
+	{0x1FA70, 0x1FAFF}, // Symbols and Pictographs Extended-A
+}
+
+const unicodeDataURL = "https://www.unicode.org/Public/UCD/latest/ucd/UnicodeData.txt"
+
+func main() {
+	if len(os.Args) > 2 {
+		log.Fatal("usage: go run gen_unicode_names.go [UnicodeData.txt]")
+	}
+	in, err := openUnicodeData(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer in.Close()
+	out, err := os.Create("unicode_names.txt")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer out.Close()
+	w := bufio.NewWriter(out)
+	defer w.Flush()
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ";")
+		if len(fields) < 3 {
+			continue
+		}
+		code, err := strconv.ParseUint(fields[0], 16, 32)
+		if err != nil || !included(rune(code)) {
+			continue
+		}
+		name, category := fields[1], fields[2]
+		if strings.HasPrefix(name, "<") || !printable(category) {
+			continue
+		}
+		fmt.Fprintf(w, "%X\t%s\n", code, strings.ToLower(name))
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func openUnicodeData(args []string) (io.ReadCloser, error) {
+	if len(args) == 1 {
+		return os.Open(args[0])
+	}
+	resp, err := http.Get(unicodeDataURL)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", unicodeDataURL, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+func included(r rune) bool {
+	for _, rg := range ranges {
+		if rg[0] <= r && r <= rg[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// Excludes control, format, separator and combining characters.
+func printable(category string) bool {
+	switch category[0] {
+	case 'C', 'Z', 'M':
+		return false
+	}
+	return true
+}
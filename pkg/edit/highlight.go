@@ -21,7 +21,7 @@ func initHighlighter(appSpec *cli.AppSpec, ed *Editor, ev *eval.Evaler, nb eval.
 			ed.autofix.Store(autofix)
 			return autofix, eval.UnpackCompilationErrors(err)
 		},
-		HasCommand: func(cmd string) bool { return hasCommand(ev, cmd) },
+		HasCommand: func(cmd string) bool { return HasCommand(ev, cmd) },
 		AutofixTip: func(autofix string) ui.Text {
 			return bindingTips(ed.ns, "insert:binding",
 				bindingTip("autofix: "+autofix, "apply-autofix"),
@@ -44,7 +44,9 @@ func initHighlighter(appSpec *cli.AppSpec, ed *Editor, ev *eval.Evaler, nb eval.
 	nb.AddGoFn("apply-autofix", ed.applyAutofix)
 }
 
-func hasCommand(ev *eval.Evaler, cmd string) bool {
+// HasCommand returns whether cmd names a command, either a special command, a
+// function, or an external command. It is used for highlighting.
+func HasCommand(ev *eval.Evaler, cmd string) bool {
 	if eval.IsBuiltinSpecial[cmd] {
 		return true
 	}
@@ -65,6 +67,9 @@ func hasCommand(ev *eval.Evaler, cmd string) bool {
 		if hasFn(ev.Builtin(), first) || hasFn(ev.Global(), first) {
 			return true
 		}
+		if _, ok := ev.AutoloadPath(first); ok {
+			return true
+		}
 	case first == "e:":
 		return hasExternalCommand(rest)
 	default:
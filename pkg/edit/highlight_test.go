@@ -89,7 +89,12 @@ func TestMakeHasCommand(t *testing.T) {
 	mustMkdirAll("a/b/c")
 	mustMkExecutable("a/b/c/executable")
 
-	tt.Test(t, hasCommand,
+	// Set up an autoload directory.
+	mustMkdirAll("autoload")
+	mustWriteFile("autoload/autoloaded.elv", "fn autoloaded { }")
+	ev.AutoloadDirs = []string{filepath.Join(testDir, "autoload")}
+
+	tt.Test(t, HasCommand,
 		// Builtin special form
 		Args(ev, "if").Rets(true),
 
@@ -105,6 +110,9 @@ func TestMakeHasCommand(t *testing.T) {
 		Args(ev, "a:bad").Rets(false),
 		Args(ev, "a:b:bad").Rets(false),
 
+		// Autoloaded function
+		Args(ev, "autoloaded").Rets(true),
+
 		// Non-searching directory and external
 		Args(ev, "./a").Rets(true),
 		Args(ev, "a/b").Rets(true),
@@ -133,6 +141,13 @@ func mustMkdirAll(path string) {
 	}
 }
 
+func mustWriteFile(path, content string) {
+	err := os.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		panic(err)
+	}
+}
+
 func mustMkExecutable(path string) {
 	if runtime.GOOS == "windows" {
 		path += ".exe"
@@ -9,34 +9,46 @@ import (
 
 // A wrapper of histutil.Store that is concurrency-safe and supports an
 // additional FastForward method.
+//
+// The wrapped store is created when it is first used instead of when the
+// editor is created, so that the start of the shell doesn't wait for the
+// daemon to come up.
 type histStore struct {
 	m  sync.Mutex
 	db storedefs.Store
 	hs histutil.Store
 }
 
-func newHistStore(db storedefs.Store) (*histStore, error) {
-	hs, err := histutil.NewHybridStore(db)
-	return &histStore{db: db, hs: hs}, err
+func newHistStore(db storedefs.Store) *histStore {
+	return &histStore{db: db}
+}
+
+// Returns the wrapped store. Must be called with s.m held.
+func (s *histStore) store() histutil.Store {
+	if s.hs == nil {
+		// TODO(xiaq): Report the error.
+		s.hs, _ = histutil.NewHybridStore(s.db)
+	}
+	return s.hs
 }
 
 func (s *histStore) AddCmd(cmd storedefs.Cmd) (int, error) {
 	s.m.Lock()
 	defer s.m.Unlock()
-	return s.hs.AddCmd(cmd)
+	return s.store().AddCmd(cmd)
 }
 
 // AllCmds returns a slice of all interactive commands in oldest to newest order.
 func (s *histStore) AllCmds() ([]storedefs.Cmd, error) {
 	s.m.Lock()
 	defer s.m.Unlock()
-	return s.hs.AllCmds()
+	return s.store().AllCmds()
 }
 
 func (s *histStore) Cursor(prefix string) histutil.Cursor {
 	s.m.Lock()
 	defer s.m.Unlock()
-	return cursor{&s.m, histutil.NewDedupCursor(s.hs.Cursor(prefix))}
+	return cursor{&s.m, histutil.NewDedupCursor(s.store().Cursor(prefix))}
 }
 
 func (s *histStore) FastForward() error {
@@ -0,0 +1,43 @@
+package edit
+
+import (
+	"errors"
+
+	"src.elv.sh/pkg/cli"
+	"src.elv.sh/pkg/cli/modes"
+	"src.elv.sh/pkg/eval"
+)
+
+func initHistsearch(ed *Editor, ev *eval.Evaler, hs *histStore, nb eval.NsBuilder) {
+	bindingVar := newBindingVar(emptyBindingsMap)
+	bindings := newMapBindings(ed, ev, bindingVar)
+	app := ed.app
+	nb.AddNs("histsearch",
+		eval.BuildNsNamed("edit:histsearch").
+			AddVar("binding", bindingVar).
+			AddGoFns(map[string]any{
+				"start": func() {
+					w, err := modes.NewHistsearch(app, modes.HistsearchSpec{
+						Bindings: bindings, Store: hs})
+					startMode(app, w, err)
+				},
+				"older": func() { notifyError(app, histsearchDo(app, modes.Histsearch.Older)) },
+				"newer": func() { notifyError(app, histsearchDo(app, modes.Histsearch.Newer)) },
+				"accept": func() {
+					notifyError(app, histsearchDo(app, func(w modes.Histsearch) error {
+						w.Accept()
+						return nil
+					}))
+				},
+			}))
+}
+
+var errNotInHistsearchMode = errors.New("not in history search mode")
+
+func histsearchDo(app cli.App, f func(modes.Histsearch) error) error {
+	w, ok := app.ActiveWidget().(modes.Histsearch)
+	if !ok {
+		return errNotInHistsearchMode
+	}
+	return f(w)
+}
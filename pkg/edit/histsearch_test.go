@@ -0,0 +1,75 @@
+package edit
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/store/storedefs"
+	"src.elv.sh/pkg/ui"
+)
+
+func TestHistsearch(t *testing.T) {
+	f := setup(t, storeOp(func(s storedefs.Store) {
+		s.AddCmd("echo a")
+		s.AddCmd("echo b")
+	}))
+
+	evals(f.Evaler, `edit:histsearch:start`)
+	f.TTYCtrl.Inject(term.K('e'))
+	f.TestTTY(t,
+		"~> echo b", Styles,
+		"   VVVV__", "\n",
+		" HISTORY SEARCH  e", Styles,
+		"**************** ", term.DotHere, "\n",
+		"echo b", Styles,
+		"+     ",
+	)
+
+	f.TTYCtrl.Inject(term.K('R', ui.Ctrl))
+	f.TestTTY(t,
+		"~> echo a", Styles,
+		"   VVVV__", "\n",
+		" HISTORY SEARCH  e", Styles,
+		"**************** ", term.DotHere, "\n",
+		"echo a", Styles,
+		"+     ",
+	)
+
+	f.TTYCtrl.Inject(term.K('R', ui.Ctrl))
+	f.TTYCtrl.TestMsg(t,
+		ui.Concat(ui.T("error:", ui.FgRed), ui.T(" no matching command")))
+
+	f.TTYCtrl.Inject(term.K('\n'))
+	f.TestTTY(t,
+		"~> echo a", Styles,
+		"   vvvv  ", term.DotHere,
+	)
+}
+
+func TestHistsearch_Close(t *testing.T) {
+	f := setup(t, storeOp(func(s storedefs.Store) {
+		s.AddCmd("echo a")
+	}))
+
+	evals(f.Evaler, `edit:histsearch:start`)
+	f.TTYCtrl.Inject(term.K('e'))
+	f.TestTTY(t,
+		"~> echo a", Styles,
+		"   VVVV__", "\n",
+		" HISTORY SEARCH  e", Styles,
+		"**************** ", term.DotHere, "\n",
+		"echo a", Styles,
+		"+     ",
+	)
+
+	f.TTYCtrl.Inject(term.K('[', ui.Ctrl))
+	f.TestTTY(t, "~> ", term.DotHere)
+}
+
+func TestHistsearch_NotInMode(t *testing.T) {
+	f := setup(t)
+
+	evals(f.Evaler, `edit:histsearch:older`)
+	f.TTYCtrl.TestMsg(t,
+		ui.Concat(ui.T("error:", ui.FgRed), ui.T(" not in history search mode")))
+}
@@ -25,9 +25,11 @@ func initInsertAPI(appSpec *cli.AppSpec, nt notifier, ev *eval.Evaler, nb eval.N
 
 	quotePaste := newBoolVar(false)
 	appSpec.QuotePaste = func() bool { return quotePaste.GetRaw().(bool) }
+	quotePasteOption := ev.RegisterOption(
+		eval.OptionSpec{Name: "edit:insert:quote-paste", Default: false}, quotePaste)
 
 	toggleQuotePaste := func() {
-		quotePaste.Set(!quotePaste.Get().(bool))
+		quotePasteOption.Set(!quotePaste.Get().(bool))
 	}
 
 	nb.AddVar("abbr", simpleAbbrVar)
@@ -36,7 +38,7 @@ func initInsertAPI(appSpec *cli.AppSpec, nt notifier, ev *eval.Evaler, nb eval.N
 	nb.AddGoFn("toggle-quote-paste", toggleQuotePaste)
 	nb.AddNs("insert", eval.BuildNs().
 		AddVar("binding", bindingVar).
-		AddVar("quote-paste", quotePaste))
+		AddVar("quote-paste", quotePasteOption))
 }
 
 func makeMapIterator(mv vars.PtrVar) func(func(a, b string)) {
@@ -1,6 +1,7 @@
 package edit
 
 import (
+	"errors"
 	"os"
 
 	"src.elv.sh/pkg/cli"
@@ -34,9 +35,12 @@ func initListings(ed *Editor, ev *eval.Evaler, st storedefs.Store, histStore his
 				},
 			}))
 
-	initHistlist(ed, ev, histStore, bindingVar, nb)
+	initHistlist(ed, ev, st, histStore, bindingVar, nb)
 	initLastcmd(ed, ev, histStore, bindingVar, nb)
 	initLocation(ed, ev, st, bindingVar, nb)
+	initPalette(ed, ev, bindingVar, nb)
+	initUnicode(ed, ev, bindingVar, nb)
+	initSnippet(ed, ev, bindingVar, nb)
 }
 
 var filterSpec = modes.FilterSpec{
@@ -50,10 +54,20 @@ var filterSpec = modes.FilterSpec{
 	Highlighter: filter.Highlight,
 }
 
-func initHistlist(ed *Editor, ev *eval.Evaler, histStore histutil.Store, commonBindingVar vars.PtrVar, nb eval.NsBuilder) {
+func initHistlist(ed *Editor, ev *eval.Evaler, st storedefs.Store, histStore histutil.Store, commonBindingVar vars.PtrVar, nb eval.NsBuilder) {
 	bindingVar := newBindingVar(emptyBindingsMap)
 	bindings := newMapBindings(ed, ev, bindingVar, commonBindingVar)
 	dedup := newBoolVar(true)
+	details := newBoolVar(false)
+	workspace := newBoolVar(false)
+	var cmdRecords func() ([]storedefs.CmdRecord, error)
+	var workspaceCmds func(string) ([]storedefs.Cmd, error)
+	if st != nil {
+		cmdRecords = func() ([]storedefs.CmdRecord, error) { return st.CmdRecords(0, -1) }
+		workspaceCmds = func(root string) ([]storedefs.Cmd, error) {
+			return st.WorkspaceCmds(root, 0, -1)
+		}
+	}
 	ns := eval.BuildNsNamed("edit:histlist").
 		AddVar("binding", bindingVar).
 		AddGoFns(map[string]any{
@@ -64,10 +78,27 @@ func initHistlist(ed *Editor, ev *eval.Evaler, histStore histutil.Store, commonB
 					Dedup: func() bool {
 						return dedup.Get().(bool)
 					},
+					CmdRecords: cmdRecords,
+					Details: func() bool {
+						return details.Get().(bool)
+					},
+					Workspace: func() string {
+						wd, err := os.Getwd()
+						if err != nil {
+							return ""
+						}
+						return ed.workspaceOf(wd)
+					},
+					WorkspaceCmds: workspaceCmds,
+					InWorkspace: func() bool {
+						return workspace.Get().(bool)
+					},
 					Filter: filterSpec,
 					CodeAreaRPrompt: func() ui.Text {
 						return bindingTips(ed.ns, "histlist:binding",
-							bindingTip("dedup", "histlist:toggle-dedup"))
+							bindingTip("dedup", "histlist:toggle-dedup"),
+							bindingTip("details", "histlist:toggle-details"),
+							bindingTip("workspace", "histlist:toggle-workspace"))
 					},
 				})
 				startMode(ed.app, w, err)
@@ -77,6 +108,16 @@ func initHistlist(ed *Editor, ev *eval.Evaler, histStore histutil.Store, commonB
 				listingRefilter(ed.app)
 				ed.app.Redraw()
 			},
+			"toggle-details": func() {
+				details.Set(!details.Get().(bool))
+				listingRefilter(ed.app)
+				ed.app.Redraw()
+			},
+			"toggle-workspace": func() {
+				workspace.Set(!workspace.Get().(bool))
+				listingRefilter(ed.app)
+				ed.app.Redraw()
+			},
 		}).Ns()
 	nb.AddNs("histlist", ns)
 }
@@ -104,6 +145,37 @@ func initLocation(ed *Editor, ev *eval.Evaler, st storedefs.Store, commonBinding
 	bindings := newMapBindings(ed, ev, bindingVar, commonBindingVar)
 	workspaceIterator := modes.LocationWSIterator(
 		adaptToIterateStringPair(workspacesVar))
+	ed.workspaceOf = func(dir string) string {
+		_, root := workspaceIterator.Parse(dir)
+		return root
+	}
+	// Directories in $edit:location:pinned come before those pinned in the
+	// store.
+	iteratePinned := adaptToIterateString(pinnedVar)
+	var setPinned func(string, bool) error
+	if st != nil {
+		iteratePinnedVar := iteratePinned
+		iteratePinned = func(f func(string)) {
+			seen := make(map[string]bool)
+			iteratePinnedVar(func(dir string) {
+				seen[dir] = true
+				f(dir)
+			})
+			// TODO: Surface the error.
+			dirs, _ := st.PinnedDirs()
+			for _, dir := range dirs {
+				if !seen[dir] {
+					f(dir)
+				}
+			}
+		}
+		setPinned = func(dir string, pinned bool) error {
+			if pinned {
+				return st.PinDir(dir)
+			}
+			return st.UnpinDir(dir)
+		}
+	}
 
 	nb.AddNs("location",
 		eval.BuildNsNamed("edit:location").
@@ -113,15 +185,28 @@ func initLocation(ed *Editor, ev *eval.Evaler, st storedefs.Store, commonBinding
 				"pinned":     pinnedVar,
 				"workspaces": workspacesVar,
 			}).
-			AddGoFn("start", func() {
-				w, err := modes.NewLocation(ed.app, modes.LocationSpec{
-					Bindings: bindings, Store: dirStore{ev, st},
-					IteratePinned:     adaptToIterateString(pinnedVar),
-					IterateHidden:     adaptToIterateString(hiddenVar),
-					IterateWorkspaces: workspaceIterator,
-					Filter:            filterSpec,
-				})
-				startMode(ed.app, w, err)
+			AddGoFns(map[string]any{
+				"start": func() {
+					// The location mode does its own fuzzy matching instead of
+					// using the filter DSL.
+					w, err := modes.NewLocation(ed.app, modes.LocationSpec{
+						Bindings: bindings, Store: dirStore{ev, st},
+						IteratePinned:     iteratePinned,
+						SetPinned:         setPinned,
+						IterateHidden:     adaptToIterateString(hiddenVar),
+						IterateWorkspaces: workspaceIterator,
+					})
+					startMode(ed.app, w, err)
+				},
+				"remove": func() {
+					notifyError(ed.app, locationDo(ed.app, modes.Location.Remove))
+				},
+				"descend": func() {
+					notifyError(ed.app, locationDo(ed.app, modes.Location.Descend))
+				},
+				"toggle-pin": func() {
+					notifyError(ed.app, locationDo(ed.app, modes.Location.TogglePin))
+				},
 			}))
 	ev.AfterChdir = append(ev.AfterChdir, func(string) {
 		wd, err := os.Getwd()
@@ -139,6 +224,16 @@ func initLocation(ed *Editor, ev *eval.Evaler, st storedefs.Store, commonBinding
 	})
 }
 
+var errNotInLocationMode = errors.New("not in location mode")
+
+func locationDo(app cli.App, f func(modes.Location) error) error {
+	w, ok := app.ActiveWidget().(modes.Location)
+	if !ok {
+		return errNotInLocationMode
+	}
+	return f(w)
+}
+
 func listingAccept(app cli.App) {
 	if w, ok := activeComboBox(app); ok {
 		w.ListBox().Accept()
@@ -218,6 +313,13 @@ func (d dirStore) Dirs(blacklist map[string]struct{}) ([]storedefs.Dir, error) {
 	return d.st.Dirs(blacklist)
 }
 
+func (d dirStore) DelDir(dir string) error {
+	if d.st == nil {
+		return errors.New("no directory history store")
+	}
+	return d.st.DelDir(dir)
+}
+
 func (d dirStore) Getwd() (string, error) {
 	return os.Getwd()
 }
@@ -33,11 +33,53 @@ func TestLocationAddon(t *testing.T) {
 		"********** ", term.DotHere, "\n",
 		"  * /opt                                          \n", Styles,
 		"++++++++++++++++++++++++++++++++++++++++++++++++++",
-		" 10 /home/elf\n",
-		" 10 /usr/bin",
+		" 10 /h/elf\n",
+		" 10 /u/bin",
 	)
 }
 
+func TestLocationAddon_Remove(t *testing.T) {
+	f := setup(t, storeOp(func(s storedefs.Store) {
+		s.AddDir("/usr/bin", 1)
+		s.AddDir("/tmp", 1)
+	}))
+
+	f.TTYCtrl.Inject(term.K('L', ui.Ctrl), term.K('D', ui.Ctrl))
+	f.TestTTY(t,
+		"~> \n",
+		" LOCATION  ", Styles,
+		"********** ", term.DotHere, "\n",
+		" 10 /u/bin                                        ", Styles,
+		"++++++++++++++++++++++++++++++++++++++++++++++++++",
+	)
+	dirs, _ := f.Store.Dirs(map[string]struct{}{})
+	if len(dirs) != 1 || dirs[0].Path != "/usr/bin" {
+		t.Errorf("got dirs %v, want only /usr/bin", dirs)
+	}
+}
+
+func TestLocationAddon_TogglePin(t *testing.T) {
+	f := setup(t, storeOp(func(s storedefs.Store) {
+		s.AddDir("/usr/bin", 1)
+		s.AddDir("/tmp", 1)
+		s.PinDir("/opt")
+	}))
+
+	f.TTYCtrl.Inject(term.K('L', ui.Ctrl), term.K(ui.Down), term.K('P', ui.Ctrl))
+	f.TestTTY(t,
+		"~> \n",
+		" LOCATION  ", Styles,
+		"********** ", term.DotHere, "\n",
+		"  * /opt\n",
+		"  * /tmp                                          \n", Styles,
+		"++++++++++++++++++++++++++++++++++++++++++++++++++",
+		" 10 /u/bin",
+	)
+	if dirs, _ := f.Store.PinnedDirs(); !reflect.DeepEqual(dirs, []string{"/opt", "/tmp"}) {
+		t.Errorf("got pinned dirs %v, want [/opt /tmp]", dirs)
+	}
+}
+
 func TestLocationAddon_Workspace(t *testing.T) {
 	f := setup(t, storeOp(func(s storedefs.Store) {
 		s.AddDir("/usr/bin", 1)
@@ -65,13 +107,43 @@ func TestLocationAddon_Workspace(t *testing.T) {
 		"********** ", term.DotHere, "\n",
 		" 10 ws/bin                                        \n", Styles,
 		"++++++++++++++++++++++++++++++++++++++++++++++++++",
-		" 10 /usr/bin",
+		" 10 /u/bin",
 	)
 
 	f.TTYCtrl.Inject(term.K(ui.Enter))
 	f.TestTTY(t, "~/ws1/bin> ", term.DotHere)
 }
 
+func TestHistlistAddon_Workspace(t *testing.T) {
+	f := setup(t, storeOp(func(s storedefs.Store) {
+		s.AddCmd("echo outside")
+	}))
+
+	testutil.ApplyDir(testutil.Dir{"ws1": testutil.Dir{"bin": testutil.Dir{}}})
+	evals(f.Evaler, `set edit:location:workspaces = [&ws=$E:HOME/ws.]`)
+	err := f.Evaler.Chdir("ws1/bin")
+	if err != nil {
+		t.Skip("chdir:", err)
+	}
+
+	feedInput(f.TTYCtrl, "echo inside\n")
+	f.TestTTY(t, "inside\n", "~/ws1/bin> ", term.DotHere)
+	home, _ := os.UserHomeDir()
+	cmds, err := f.Store.WorkspaceCmds(filepath.Join(home, "ws1"), 0, -1)
+	if len(cmds) != 1 || cmds[0].Text != "echo inside" || err != nil {
+		t.Errorf("got workspace cmds (%v, %v), want only echo inside", cmds, err)
+	}
+
+	f.TTYCtrl.Inject(term.K('R', ui.Ctrl), term.K('O', ui.Ctrl))
+	f.TestTTY(t,
+		"~/ws1/bin> \n",
+		" HISTORY (dedup on) (workspace on)  ", Styles,
+		"*********************************** ", term.DotHere, "\n",
+		"   2 echo inside                                  ", Styles,
+		"++++++++++++++++++++++++++++++++++++++++++++++++++",
+	)
+}
+
 func TestLocation_AddDir(t *testing.T) {
 	f := setup(t)
 
@@ -79,14 +79,16 @@ func TestHistlistAddon(t *testing.T) {
 		s.AddCmd("ls")
 		s.AddCmd("LS")
 	}))
+	// Keep the binding tips within the width of the terminal.
+	evals(f.Evaler, `set edit:histlist:binding = (dissoc $edit:histlist:binding Ctrl-O)`)
 
 	f.TTYCtrl.Inject(term.K('R', ui.Ctrl))
 	f.TestTTY(t,
 		"~> \n",
 		" HISTORY (dedup on)  ", Styles,
 		"******************** ", term.DotHere,
-		"                 Ctrl-D dedup\n", Styles,
-		"                 ++++++      ",
+		"  Ctrl-D dedup Ctrl-T details\n", Styles,
+		"  ++++++       ++++++        ",
 		"   2 echo\n",
 		"   3 ls\n",
 		"   4 LS                                           ", Styles,
@@ -98,8 +100,8 @@ func TestHistlistAddon(t *testing.T) {
 		"~> \n",
 		" HISTORY  ", Styles,
 		"********* ", term.DotHere,
-		"                            Ctrl-D dedup\n", Styles,
-		"                            ++++++      ",
+		"             Ctrl-D dedup Ctrl-T details\n", Styles,
+		"             ++++++       ++++++        ",
 		"   1 ls\n",
 		"   2 echo\n",
 		"   3 ls\n",
@@ -115,8 +117,8 @@ func TestHistlistAddon(t *testing.T) {
 		"~> \n",
 		" HISTORY (dedup on)  l", Styles,
 		"********************  ", term.DotHere,
-		"                Ctrl-D dedup\n", Styles,
-		"                ++++++      ",
+		" Ctrl-D dedup Ctrl-T details\n", Styles,
+		" ++++++       ++++++        ",
 		"   3 ls\n",
 		"   4 LS                                           ", Styles,
 		"++++++++++++++++++++++++++++++++++++++++++++++++++",
@@ -128,8 +130,8 @@ func TestHistlistAddon(t *testing.T) {
 		"~> \n",
 		" HISTORY (dedup on)  L", Styles,
 		"********************  ", term.DotHere,
-		"                Ctrl-D dedup\n", Styles,
-		"                ++++++      ",
+		" Ctrl-D dedup Ctrl-T details\n", Styles,
+		" ++++++       ++++++        ",
 		"   4 LS                                           ", Styles,
 		"++++++++++++++++++++++++++++++++++++++++++++++++++",
 	)
@@ -153,6 +155,14 @@ func TestLastCmdAddon(t *testing.T) {
 	)
 }
 
+func TestLocationAddon_NotInMode(t *testing.T) {
+	f := setup(t)
+
+	evals(f.Evaler, `edit:location:descend`)
+	f.TTYCtrl.TestMsg(t,
+		ui.Concat(ui.T("error:", ui.FgRed), ui.T(" not in location mode")))
+}
+
 func TestCustomListing_PassingList(t *testing.T) {
 	f := setup(t)
 
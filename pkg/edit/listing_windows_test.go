@@ -32,8 +32,8 @@ func TestLocationAddon(t *testing.T) {
 		"********** ", term.DotHere, "\n",
 		`  * C:\opt                                        `+"\n", Styles,
 		"++++++++++++++++++++++++++++++++++++++++++++++++++",
-		` 10 C:\home\elf`+"\n",
-		` 10 C:\usr\bin`,
+		` 10 C:\h\elf`+"\n",
+		` 10 C:\u\bin`,
 	)
 }
 
@@ -65,7 +65,7 @@ func TestLocationAddon_Workspace(t *testing.T) {
 		"********** ", term.DotHere, "\n",
 		` 10 ws\bin                                        `+"\n", Styles,
 		"++++++++++++++++++++++++++++++++++++++++++++++++++",
-		` 10 C:\usr\bin`,
+		` 10 C:\u\bin`,
 	)
 
 	f.TTYCtrl.Inject(term.K(ui.Enter))
@@ -0,0 +1,170 @@
+package edit
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"src.elv.sh/pkg/cli"
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/eval/vars"
+	"src.elv.sh/pkg/ui"
+)
+
+var (
+	errAlreadyRecording = errors.New("already recording a macro")
+	errNotRecording     = errors.New("not recording a macro")
+	errAlreadyReplaying = errors.New("already replaying a macro")
+)
+
+// A TTY that can record the key events read from it, and replay key events by
+// returning them from ReadEvent before reading from the underlying TTY.
+//
+// Replaying works because the app only calls ReadEvent after it has finished
+// handling the previous event, including running any bindings that queue
+// events to replay.
+type macroTTY struct {
+	cli.TTY
+
+	mutex     sync.Mutex
+	recording bool
+	recorded  []ui.Key
+	replay    []ui.Key
+	// Whether the last recorded key is still being handled, in which case it
+	// is the key that triggered any call to stopRecording.
+	handlingRecorded bool
+}
+
+func (t *macroTTY) ReadEvent() (term.Event, error) {
+	t.mutex.Lock()
+	t.handlingRecorded = false
+	if len(t.replay) > 0 {
+		k := t.replay[0]
+		t.replay = t.replay[1:]
+		t.mutex.Unlock()
+		return term.KeyEvent(k), nil
+	}
+	t.mutex.Unlock()
+
+	event, err := t.TTY.ReadEvent()
+	if k, ok := event.(term.KeyEvent); ok && err == nil {
+		t.mutex.Lock()
+		if t.recording {
+			t.recorded = append(t.recorded, ui.Key(k))
+			t.handlingRecorded = true
+		}
+		t.mutex.Unlock()
+	}
+	return event, err
+}
+
+// CloseReader is called when the app stops reading events, after it has
+// finished handling the last one.
+func (t *macroTTY) CloseReader() {
+	t.mutex.Lock()
+	t.handlingRecorded = false
+	t.mutex.Unlock()
+	t.TTY.CloseReader()
+}
+
+func (t *macroTTY) isRecording() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.recording
+}
+
+func (t *macroTTY) startRecording() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.recording {
+		return errAlreadyRecording
+	}
+	t.recording, t.recorded = true, nil
+	return nil
+}
+
+// Stops recording and returns the recorded keys. If the last recorded key is
+// still being handled, it is dropped, since it is the key that triggered the
+// stop.
+func (t *macroTTY) stopRecording() ([]ui.Key, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if !t.recording {
+		return nil, errNotRecording
+	}
+	t.recording = false
+	keys := t.recorded
+	if t.handlingRecorded && len(keys) > 0 {
+		keys = keys[:len(keys)-1]
+	}
+	return keys, nil
+}
+
+func (t *macroTTY) startReplaying(keys []ui.Key, times int) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if len(t.replay) > 0 {
+		return errAlreadyReplaying
+	}
+	for i := 0; i < times; i++ {
+		t.replay = append(t.replay, keys...)
+	}
+	return nil
+}
+
+type macroReplayOpts struct{ Times int }
+
+func (o *macroReplayOpts) SetDefaultOptions() { o.Times = 1 }
+
+func initMacro(ed *Editor, tty *macroTTY, nb eval.NsBuilder) {
+	keysVar := newListVar(vals.EmptyList)
+	app := ed.app
+	nb.AddNs("macro",
+		eval.BuildNsNamed("edit:macro").
+			AddVars(map[string]vars.Var{
+				"keys":      keysVar,
+				"recording": vars.FromGet(func() any { return tty.isRecording() }),
+			}).
+			AddGoFns(map[string]any{
+				"record": func() {
+					if err := tty.startRecording(); err != nil {
+						notifyError(app, err)
+						return
+					}
+					app.Notify(ui.T("Recording macro"))
+				},
+				"stop": func() {
+					keys, err := tty.stopRecording()
+					if err != nil {
+						notifyError(app, err)
+						return
+					}
+					list := vals.EmptyList
+					for _, k := range keys {
+						list = list.Conj(k)
+					}
+					keysVar.Set(list)
+					app.Notify(ui.T(fmt.Sprintf("Recorded macro of %d keys", len(keys))))
+				},
+				"replay": func(opts macroReplayOpts) error {
+					var keys []ui.Key
+					var errKey error
+					errIterate := vals.Iterate(keysVar.Get(), func(v any) bool {
+						var k ui.Key
+						k, errKey = toKey(v)
+						keys = append(keys, k)
+						return errKey == nil
+					})
+					if errIterate != nil {
+						return errIterate
+					}
+					if errKey != nil {
+						return errKey
+					}
+					notifyError(app, tty.startReplaying(keys, opts.Times))
+					return nil
+				},
+			}))
+}
@@ -0,0 +1,79 @@
+package edit
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/ui"
+)
+
+func TestMacro(t *testing.T) {
+	f := setup(t, rc(`set edit:insert:binding[Ctrl-X] = { edit:macro:replay &times=2 }`))
+
+	f.TTYCtrl.Inject(term.K('(', ui.Alt))
+	f.TTYCtrl.TestMsg(t, ui.T("Recording macro"))
+	evals(f.Evaler, `var recording = $edit:macro:recording`)
+	testGlobal(t, f.Evaler, "recording", true)
+
+	feedInput(f.TTYCtrl, "ab")
+	f.TTYCtrl.Inject(term.K(ui.Left), term.K(')', ui.Alt))
+	f.TTYCtrl.TestMsg(t, ui.T("Recorded macro of 3 keys"))
+	evals(f.Evaler,
+		`set recording = $edit:macro:recording`,
+		`var keys = $edit:macro:keys`)
+	testGlobal(t, f.Evaler, "recording", false)
+	testGlobal(t, f.Evaler, "keys",
+		vals.MakeList(ui.K('a'), ui.K('b'), ui.K(ui.Left)))
+
+	f.TTYCtrl.Inject(term.K('e', ui.Alt))
+	f.TestTTY(t,
+		"~> aa", Styles,
+		"   !!", term.DotHere, "bb", Styles,
+		"!!",
+	)
+
+	f.TTYCtrl.Inject(term.K('X', ui.Ctrl))
+	f.TestTTY(t,
+		"~> aaaa", Styles,
+		"   !!!!", term.DotHere, "bbbb", Styles,
+		"!!!!",
+	)
+}
+
+func TestMacro_StopOutsideBinding(t *testing.T) {
+	f := setup(t)
+
+	f.TTYCtrl.Inject(term.K('(', ui.Alt))
+	f.TTYCtrl.TestMsg(t, ui.T("Recording macro"))
+	feedInput(f.TTYCtrl, "ab")
+	f.TestTTY(t, "~> ab", Styles,
+		"   !!", term.DotHere)
+
+	evals(f.Evaler, `edit:macro:stop`, `var keys = $edit:macro:keys`)
+	f.TTYCtrl.TestMsg(t, ui.T("Recorded macro of 2 keys"))
+	testGlobal(t, f.Evaler, "keys", vals.MakeList(ui.K('a'), ui.K('b')))
+}
+
+func TestMacro_Errors(t *testing.T) {
+	f := setup(t)
+
+	f.TTYCtrl.Inject(term.K(')', ui.Alt))
+	f.TTYCtrl.TestMsg(t,
+		ui.Concat(ui.T("error:", ui.FgRed), ui.T(" not recording a macro")))
+
+	f.TTYCtrl.Inject(term.K('(', ui.Alt), term.K('(', ui.Alt))
+	f.TTYCtrl.TestMsg(t,
+		ui.Concat(ui.T("error:", ui.FgRed), ui.T(" already recording a macro")))
+}
+
+func TestMacro_ReplayCustomKeys(t *testing.T) {
+	f := setup(t, rc(`set edit:macro:keys = [x Left (edit:key y)]`))
+
+	f.TTYCtrl.Inject(term.K('e', ui.Alt))
+	f.TestTTY(t,
+		"~> y", Styles,
+		"   !", term.DotHere, "x", Styles,
+		"!",
+	)
+}
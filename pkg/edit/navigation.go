@@ -22,22 +22,28 @@ func navInsertSelected(app cli.App) {
 	if !ok {
 		return
 	}
-	fname := w.SelectedName()
-	if fname == "" {
+	fnames := w.MarkedNames()
+	if len(fnames) > 0 {
+		w.ClearMarks()
+	} else if fname := w.SelectedName(); fname != "" {
+		fnames = []string{fname}
+	} else {
 		// User pressed Alt-Enter or Enter in an empty directory with nothing
 		// selected; don't do anything.
 		return
 	}
 
 	codeArea.MutateState(func(s *tk.CodeAreaState) {
-		dot := s.Buffer.Dot
-		if dot != 0 && !strings.ContainsRune(" \n", rune(s.Buffer.Content[dot-1])) {
-			// The dot is not at the beginning of a buffer, and the previous
-			// character is not a space or newline. Insert a space.
-			s.Buffer.InsertAtDot(" ")
+		for _, fname := range fnames {
+			dot := s.Buffer.Dot
+			if dot != 0 && !strings.ContainsRune(" \n", rune(s.Buffer.Content[dot-1])) {
+				// The dot is not at the beginning of a buffer, and the previous
+				// character is not a space or newline. Insert a space.
+				s.Buffer.InsertAtDot(" ")
+			}
+			// Insert the filename.
+			s.Buffer.InsertAtDot(parse.Quote(fname))
 		}
-		// Insert the selected filename.
-		s.Buffer.InsertAtDot(parse.Quote(fname))
 	})
 }
 
@@ -74,6 +80,9 @@ func initNavigation(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
 	bindingVar := newBindingVar(emptyBindingsMap)
 	bindings := newMapBindings(ed, ev, bindingVar)
 	widthRatioVar := newListVar(vals.MakeList(1.0, 3.0, 4.0))
+	widthRatioOption := ev.RegisterOption(eval.OptionSpec{
+		Name: "edit:navigation:width-ratio", Default: widthRatioVar.Get()},
+		widthRatioVar)
 
 	selectedFileVar := vars.FromGet(func() any {
 		if w, ok := activeNavigation(ed.app); ok {
@@ -88,7 +97,7 @@ func initNavigation(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
 	ns := eval.BuildNsNamed("edit:navigation").
 		AddVars(map[string]vars.Var{
 			"binding":     bindingVar,
-			"width-ratio": widthRatioVar,
+			"width-ratio": widthRatioOption,
 		}).
 		AddGoFns(map[string]any{
 			"start": func() {
@@ -127,6 +136,14 @@ func initNavigation(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
 			"file-preview-down": actOnNavigation(app,
 				func(w modes.Navigation) { w.ScrollPreview(1) }),
 
+			"toggle-mark": actOnNavigation(app, modes.Navigation.ToggleMark),
+
+			"rename": func() { navRename(app, ev) },
+			"mkdir":  func() { navMkdir(app, ev) },
+			"trash":  func() { navTrash(app, ev) },
+			"copy":   func() { navTransfer(app, ev, "COPY", false, copyPath) },
+			"move":   func() { navTransfer(app, ev, "MOVE", true, movePath) },
+
 			"insert-selected":          func() { navInsertSelected(app) },
 			"insert-selected-and-quit": func() { navInsertSelectedAndQuit(app) },
 
@@ -0,0 +1,320 @@
+package edit
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"src.elv.sh/pkg/cli"
+	"src.elv.sh/pkg/cli/modes"
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/eval"
+)
+
+// File operations in the navigation mode. They act on files in the current
+// directory, which with the OS-backed navigation cursor is always the working
+// directory. All of them respect the restricted mode of the Evaler.
+
+var (
+	errNoSelectedFile = errors.New("no selected file")
+	errNotADirectory  = errors.New("not a directory")
+	errIntoItself     = errors.New("cannot move or copy a directory into itself")
+)
+
+// Returns the names of the marked files, or the name of the selected file if
+// there are no marked files.
+func navTargets(w modes.Navigation) ([]string, error) {
+	if names := w.MarkedNames(); len(names) > 0 {
+		return names, nil
+	}
+	if name := w.SelectedName(); name != "" {
+		return []string{name}, nil
+	}
+	return nil, errNoSelectedFile
+}
+
+// Returns a short description of a list of filenames for use in prompts.
+func describeNames(names []string) string {
+	if len(names) == 1 {
+		return names[0]
+	}
+	return fmt.Sprintf("%d files", len(names))
+}
+
+func navRename(app cli.App, ev *eval.Evaler) {
+	w, ok := activeNavigation(app)
+	if !ok {
+		return
+	}
+	name := w.SelectedName()
+	if name == "" {
+		notifyError(app, errNoSelectedFile)
+		return
+	}
+	navPrompt(app, " RENAME ", name, func(newName string) error {
+		if newName == "" || newName == name {
+			return nil
+		}
+		if err := checkNotExist(newName); err != nil {
+			return err
+		}
+		if err := ev.CheckWrite(name); err != nil {
+			return err
+		}
+		if err := ev.CheckWrite(newName); err != nil {
+			return err
+		}
+		if err := os.Rename(name, newName); err != nil {
+			return err
+		}
+		w.Refresh(newName)
+		return nil
+	})
+}
+
+func navMkdir(app cli.App, ev *eval.Evaler) {
+	w, ok := activeNavigation(app)
+	if !ok {
+		return
+	}
+	navPrompt(app, " MKDIR ", "", func(name string) error {
+		if name == "" {
+			return nil
+		}
+		if err := ev.CheckWrite(name); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(name, 0777); err != nil {
+			return err
+		}
+		// Select the first component if the created directory is nested.
+		first, _, _ := strings.Cut(filepath.ToSlash(name), "/")
+		w.Refresh(first)
+		return nil
+	})
+}
+
+func navTrash(app cli.App, ev *eval.Evaler) {
+	w, ok := activeNavigation(app)
+	if !ok {
+		return
+	}
+	names, err := navTargets(w)
+	if err != nil {
+		notifyError(app, err)
+		return
+	}
+	navConfirm(app, " TRASH "+describeNames(names)+"? (y/n) ", func() error {
+		defer w.Refresh("")
+		w.ClearMarks()
+		for _, name := range names {
+			if err := ev.CheckWrite(name); err != nil {
+				return err
+			}
+			if err := moveToTrash(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Starts a prompt for the destination directory, and copies or moves the
+// target files there. If removesSrc is true, the target files must also be
+// writable in restricted mode.
+func navTransfer(app cli.App, ev *eval.Evaler, caption string, removesSrc bool, transfer func(src, dst string) error) {
+	w, ok := activeNavigation(app)
+	if !ok {
+		return
+	}
+	names, err := navTargets(w)
+	if err != nil {
+		notifyError(app, err)
+		return
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		notifyError(app, err)
+		return
+	}
+	caption = " " + caption + " " + describeNames(names) + " TO "
+	navPrompt(app, caption, wd+string(filepath.Separator), func(dest string) error {
+		if dest == "" {
+			return nil
+		}
+		if stat, err := os.Stat(dest); err != nil {
+			return err
+		} else if !stat.IsDir() {
+			return fmt.Errorf("%s: %w", dest, errNotADirectory)
+		}
+		defer w.Refresh("")
+		w.ClearMarks()
+		for _, name := range names {
+			target := filepath.Join(dest, name)
+			if err := checkNotExist(target); err != nil {
+				return err
+			}
+			if removesSrc {
+				if err := ev.CheckWrite(name); err != nil {
+					return err
+				}
+			}
+			if err := ev.CheckWrite(target); err != nil {
+				return err
+			}
+			if err := transfer(name, target); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Pushes a code area for entering a line of text, initialized with the given
+// text. When the text is submitted, the code area is closed and f is called
+// with the text.
+func navPrompt(app cli.App, caption, text string, f func(string) error) {
+	var w tk.CodeArea
+	w = tk.NewCodeArea(tk.CodeAreaSpec{
+		Prompt: modes.Prompt(caption, true),
+		OnSubmit: func() {
+			app.PopAddon()
+			notifyError(app, f(w.CopyState().Buffer.Content))
+		},
+		State: tk.CodeAreaState{
+			Buffer: tk.CodeBuffer{Content: text, Dot: len(text)}},
+	})
+	app.PushAddon(w)
+	app.Redraw()
+}
+
+// Pushes a code area that asks for confirmation. Pressing y closes it and
+// calls f; pressing any other key just closes it.
+func navConfirm(app cli.App, caption string, f func() error) {
+	w := tk.NewCodeArea(tk.CodeAreaSpec{
+		Prompt: modes.Prompt(caption, true),
+		Bindings: tk.FuncBindings(func(_ tk.Widget, event term.Event) bool {
+			if _, ok := event.(term.KeyEvent); !ok {
+				return false
+			}
+			app.PopAddon()
+			if event == term.K('y') || event == term.K('Y') {
+				notifyError(app, f())
+			}
+			return true
+		}),
+	})
+	app.PushAddon(w)
+	app.Redraw()
+}
+
+func checkNotExist(name string) error {
+	_, err := os.Lstat(name)
+	if err == nil {
+		return &fs.PathError{Op: "create", Path: name, Err: fs.ErrExist}
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Can be overridden in tests.
+var osRename = os.Rename
+
+// Moves a file or directory, falling back to copying and removing when the
+// destination is on a different filesystem. The source is only removed after
+// it has been fully copied; a partial copy is removed instead.
+func movePath(src, dst string) error {
+	if err := checkNotInside(src, dst); err != nil {
+		return err
+	}
+	err := osRename(src, dst)
+	if err == nil || !errors.Is(err, errCrossDevice) {
+		return err
+	}
+	if err := checkNotExist(dst); err != nil {
+		return err
+	}
+	if err := copyTree(src, dst); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// Copies a file or directory recursively, preserving permissions and symlinks.
+func copyPath(src, dst string) error {
+	if err := checkNotInside(src, dst); err != nil {
+		return err
+	}
+	return copyTree(src, dst)
+}
+
+// Returns an error if dst is inside src, in which case moving or copying src
+// to dst would never finish.
+func checkNotInside(src, dst string) error {
+	absSrc, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+	absDst, err := filepath.Abs(dst)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(absSrc, absDst); err == nil && rel != "." && rel != ".." &&
+		!strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel) {
+		return fmt.Errorf("%s: %w", dst, errIntoItself)
+	}
+	return nil
+}
+
+func copyTree(src, dst string) error {
+	stat, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	switch {
+	case stat.Mode()&fs.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	case stat.IsDir():
+		if err := os.Mkdir(dst, stat.Mode().Perm()); err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			err := copyTree(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name()))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		in, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, stat.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, in)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	}
+}
@@ -0,0 +1,230 @@
+package edit
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/env"
+	"src.elv.sh/pkg/testutil"
+	"src.elv.sh/pkg/ui"
+)
+
+func TestNavigation_Rename(t *testing.T) {
+	f := setupNav(t)
+
+	f.TTYCtrl.Inject(term.K('N', ui.Ctrl), term.K(ui.F2))
+	f.TestTTY(t,
+		filepath.Join("~", "d"), "> ", "\n",
+		" NAVIGATING            Ctrl-H hidden Ctrl-F filter\n", Styles,
+		"************           ++++++        ++++++       ",
+		" d      a                 \n", Styles,
+		"###### ++++++++++++++++++ ",
+		"        e                \n", Styles,
+		"       //////////////////",
+		" RENAME  a", Styles,
+		"******** ", term.DotHere,
+	)
+
+	f.TTYCtrl.Inject(term.K(ui.Backspace))
+	feedInput(f.TTYCtrl, "b\n")
+	f.TestTTY(t,
+		filepath.Join("~", "d"), "> ", term.DotHere, "\n",
+		" NAVIGATING            Ctrl-H hidden Ctrl-F filter\n", Styles,
+		"************           ++++++        ++++++       ",
+		" d      b                 \n", Styles,
+		"###### ++++++++++++++++++ ",
+		"        e                ", Styles,
+		"       //////////////////",
+	)
+	testFileExists(t, "b", true)
+	testFileExists(t, "a", false)
+}
+
+func TestNavigation_Rename_TargetExists(t *testing.T) {
+	f := setupNav(t)
+
+	f.TTYCtrl.Inject(term.K('N', ui.Ctrl), term.K(ui.F2), term.K(ui.Backspace))
+	feedInput(f.TTYCtrl, "e\n")
+	f.TTYCtrl.TestMsg(t,
+		ui.Concat(ui.T("error:", ui.FgRed), ui.T(" create e: file already exists")))
+	testFileExists(t, "a", true)
+}
+
+func TestNavigation_Mkdir(t *testing.T) {
+	f := setupNav(t)
+
+	f.TTYCtrl.Inject(term.K('N', ui.Ctrl), term.K(ui.F7))
+	feedInput(f.TTYCtrl, "c\n")
+	f.TestTTY(t,
+		filepath.Join("~", "d"), "> ", term.DotHere, "\n",
+		" NAVIGATING            Ctrl-H hidden Ctrl-F filter\n", Styles,
+		"************           ++++++        ++++++       ",
+		" d      a                 \n", Styles,
+		"###### ",
+		"        c                \n", Styles,
+		"       ##################",
+		"        e                ", Styles,
+		"       //////////////////",
+	)
+}
+
+func TestNavigation_Copy(t *testing.T) {
+	f := setupNav(t)
+
+	// Copy "a" into "e".
+	f.TTYCtrl.Inject(term.K('N', ui.Ctrl), term.K(ui.F5))
+	feedInput(f.TTYCtrl, "e\n")
+	f.TestTTY(t,
+		filepath.Join("~", "d"), "> ", term.DotHere, "\n",
+		" NAVIGATING            Ctrl-H hidden Ctrl-F filter\n", Styles,
+		"************           ++++++        ++++++       ",
+		" d      a                 \n", Styles,
+		"###### ++++++++++++++++++ ",
+		"        e                ", Styles,
+		"       //////////////////",
+	)
+	testFileExists(t, "a", true)
+	testFileExists(t, filepath.Join("e", "a"), true)
+}
+
+func TestNavigation_Move(t *testing.T) {
+	f := setupNav(t)
+
+	f.TTYCtrl.Inject(term.K('N', ui.Ctrl), term.K(ui.F6))
+	feedInput(f.TTYCtrl, "e\n")
+	f.TestTTY(t,
+		filepath.Join("~", "d"), "> ", term.DotHere, "\n",
+		" NAVIGATING            Ctrl-H hidden Ctrl-F filter\n", Styles,
+		"************           ++++++        ++++++       ",
+		" d      e                  a                      ", Styles,
+		"###### ################## ++++++++++++++++++++++++",
+	)
+	testFileExists(t, "a", false)
+	testFileExists(t, filepath.Join("e", "a"), true)
+}
+
+func TestNavigation_Trash(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("trash location is only tested on Linux")
+	}
+	f := setupNav(t)
+	dataHome := testutil.TempDir(t)
+	testutil.Setenv(t, env.XDG_DATA_HOME, dataHome)
+
+	// Answering anything other than y cancels.
+	f.TTYCtrl.Inject(term.K('N', ui.Ctrl), term.K(ui.F8))
+	f.TestTTY(t,
+		filepath.Join("~", "d"), "> ", "\n",
+		" NAVIGATING            Ctrl-H hidden Ctrl-F filter\n", Styles,
+		"************           ++++++        ++++++       ",
+		" d      a                 \n", Styles,
+		"###### ++++++++++++++++++ ",
+		"        e                \n", Styles,
+		"       //////////////////",
+		" TRASH a? (y/n)  ", Styles,
+		"**************** ", term.DotHere,
+	)
+	f.TTYCtrl.Inject(term.K('n'))
+	f.TestTTY(t,
+		filepath.Join("~", "d"), "> ", term.DotHere, "\n",
+		" NAVIGATING            Ctrl-H hidden Ctrl-F filter\n", Styles,
+		"************           ++++++        ++++++       ",
+		" d      a                 \n", Styles,
+		"###### ++++++++++++++++++ ",
+		"        e                ", Styles,
+		"       //////////////////",
+	)
+	testFileExists(t, "a", true)
+
+	f.TTYCtrl.Inject(term.K(ui.F8), term.K('y'))
+	f.TestTTY(t,
+		filepath.Join("~", "d"), "> ", term.DotHere, "\n",
+		" NAVIGATING            Ctrl-H hidden Ctrl-F filter\n", Styles,
+		"************           ++++++        ++++++       ",
+		" d      e                 ", Styles,
+		"###### ################## ",
+	)
+	testFileExists(t, "a", false)
+	testFileExists(t, filepath.Join(dataHome, "Trash", "files", "a"), true)
+	testFileExists(t, filepath.Join(dataHome, "Trash", "info", "a.trashinfo"), true)
+}
+
+func testFileExists(t *testing.T, name string, want bool) {
+	t.Helper()
+	_, err := os.Lstat(name)
+	if exists := err == nil; exists != want {
+		t.Errorf("%s exists = %v, want %v", name, exists, want)
+	}
+}
+
+func TestMovePath_IntoItself(t *testing.T) {
+	testutil.InTempDir(t)
+	testutil.ApplyDir(testutil.Dir{"a": testutil.Dir{"b": testutil.Dir{}}})
+
+	for _, transfer := range []func(src, dst string) error{movePath, copyPath} {
+		err := transfer("a", filepath.Join("a", "b", "a"))
+		if !errors.Is(err, errIntoItself) {
+			t.Errorf("got error %v, want %v", err, errIntoItself)
+		}
+		testFileExists(t, filepath.Join("a", "b"), true)
+		testFileExists(t, filepath.Join("a", "b", "a"), false)
+	}
+}
+
+func TestMovePath_RenameError(t *testing.T) {
+	testutil.InTempDir(t)
+	testutil.ApplyDir(testutil.Dir{"a": testutil.Dir{"f": "content"}})
+	renameErr := &os.LinkError{Op: "rename", Old: "a", New: "b", Err: fs.ErrPermission}
+	testutil.Set(t, &osRename, func(string, string) error { return renameErr })
+
+	// Errors other than crossing filesystems don't fall back to copying.
+	err := movePath("a", "b")
+	if err != renameErr {
+		t.Errorf("got error %v, want %v", err, renameErr)
+	}
+	testFileExists(t, filepath.Join("a", "f"), true)
+	testFileExists(t, "b", false)
+}
+
+func TestMovePath_CrossDevice(t *testing.T) {
+	testutil.InTempDir(t)
+	testutil.ApplyDir(testutil.Dir{"a": testutil.Dir{"f": "content", "g": "content"}})
+	testutil.Set(t, &osRename, func(old, new string) error {
+		return &os.LinkError{Op: "rename", Old: old, New: new, Err: errCrossDevice}
+	})
+
+	err := movePath("a", "b")
+	if err != nil {
+		t.Errorf("got error %v", err)
+	}
+	testFileExists(t, "a", false)
+	testFileExists(t, filepath.Join("b", "f"), true)
+	testFileExists(t, filepath.Join("b", "g"), true)
+}
+
+func TestMovePath_CrossDevice_CopyFails(t *testing.T) {
+	testutil.InTempDir(t)
+	testutil.Set(t, &osRename, func(old, new string) error {
+		return &os.LinkError{Op: "rename", Old: old, New: new, Err: errCrossDevice}
+	})
+	testutil.ApplyDir(testutil.Dir{"c": testutil.Dir{"f": "content", "g": "content"}})
+	testutil.ChmodOrSkip(t, filepath.Join("c", "g"), 0)
+	if f, err := os.Open(filepath.Join("c", "g")); err == nil {
+		f.Close()
+		t.Skip("unreadable file can be read")
+	}
+	// When the copy fails halfway, the source is kept and the partial copy is
+	// removed.
+	err := movePath("c", "d")
+	if err == nil {
+		t.Errorf("got no error")
+	}
+	testFileExists(t, filepath.Join("c", "f"), true)
+	testFileExists(t, filepath.Join("c", "g"), true)
+	testFileExists(t, "d", false)
+}
@@ -0,0 +1,9 @@
+//go:build unix
+
+package edit
+
+import "syscall"
+
+// The error from os.Rename when the source and destination are on different
+// filesystems.
+var errCrossDevice error = syscall.EXDEV
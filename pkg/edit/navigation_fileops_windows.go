@@ -0,0 +1,7 @@
+package edit
+
+import "golang.org/x/sys/windows"
+
+// The error from os.Rename when the source and destination are on different
+// volumes.
+var errCrossDevice error = windows.ERROR_NOT_SAME_DEVICE
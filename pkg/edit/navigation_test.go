@@ -77,6 +77,20 @@ func TestNavigation_WidthRatio(t *testing.T) {
 // Test corner case: Inserting a selection when the CLI cursor is not at the
 // start of the edit buffer, but the preceding char is a space, does not
 // insert another space.
+func TestNavigation_InsertsMarked(t *testing.T) {
+	f := setupNav(t)
+
+	feedInput(f.TTYCtrl, "put")
+	f.TTYCtrl.Inject(term.K('N', ui.Ctrl))
+	f.TTYCtrl.Inject(term.K(ui.Tab), term.K(ui.Tab)) // mark "a" and "e"
+	f.TTYCtrl.Inject(term.K(ui.Enter))
+	f.TestTTY(t,
+		filepath.Join("~", "d"), "> ",
+		"put a e", Styles,
+		"vvv    ", term.DotHere,
+	)
+}
+
 func TestNavigation_EnterDoesNotAddSpaceAfterSpace(t *testing.T) {
 	f := setupNav(t)
 
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package edit
+
+import "errors"
+
+var errTrashUnsupported = errors.New("moving to trash is not supported on this platform")
+
+func moveToTrash(name string) error { return errTrashUnsupported }
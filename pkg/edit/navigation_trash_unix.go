@@ -0,0 +1,92 @@
+//go:build unix
+
+package edit
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"src.elv.sh/pkg/env"
+	"src.elv.sh/pkg/fsutil"
+)
+
+// Moves a file or directory to the trash. On macOS, this is ~/.Trash;
+// elsewhere, this is the home trash of the freedesktop.org trash
+// specification.
+func moveToTrash(name string) error {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return err
+	}
+	home, err := fsutil.GetHome("")
+	if err != nil {
+		return err
+	}
+	if runtime.GOOS == "darwin" {
+		dir := filepath.Join(home, ".Trash")
+		dst, err := trashTarget(dir, filepath.Base(abs), "")
+		if err != nil {
+			return err
+		}
+		return movePath(abs, filepath.Join(dir, dst))
+	}
+
+	dataHome := os.Getenv(env.XDG_DATA_HOME)
+	if dataHome == "" {
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	filesDir := filepath.Join(dataHome, "Trash", "files")
+	infoDir := filepath.Join(dataHome, "Trash", "info")
+	for _, dir := range []string{filesDir, infoDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+	dst, err := trashTarget(filesDir, filepath.Base(abs), infoDir)
+	if err != nil {
+		return err
+	}
+	// The info file is created exclusively to reserve the name, as required by
+	// the specification.
+	infoPath := filepath.Join(infoDir, dst+".trashinfo")
+	info, err := os.OpenFile(infoPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(info, "[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: abs}).EscapedPath(), time.Now().Format("2006-01-02T15:04:05"))
+	if closeErr := info.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = movePath(abs, filepath.Join(filesDir, dst))
+	}
+	if err != nil {
+		os.Remove(infoPath)
+	}
+	return err
+}
+
+// Finds a name in dir that is not used yet, starting with base and appending
+// a number if necessary. If infoDir is not empty, the corresponding
+// .trashinfo file must not exist either.
+func trashTarget(dir, base, infoDir string) (string, error) {
+	name := base
+	for i := 2; ; i++ {
+		err := checkNotExist(filepath.Join(dir, name))
+		if err == nil && infoDir != "" {
+			err = checkNotExist(filepath.Join(infoDir, name+".trashinfo"))
+		}
+		if err == nil {
+			return name, nil
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+		name = fmt.Sprintf("%s.%d", base, i)
+	}
+}
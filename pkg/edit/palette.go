@@ -0,0 +1,133 @@
+package edit
+
+import (
+	"sort"
+	"strings"
+
+	"src.elv.sh/pkg/cli/modes"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/eval/vars"
+	"src.elv.sh/pkg/ui"
+)
+
+func initPalette(ed *Editor, ev *eval.Evaler, commonBindingVar vars.PtrVar, nb eval.NsBuilder) {
+	bindingVar := newBindingVar(emptyBindingsMap)
+	bindings := newMapBindings(ed, ev, bindingVar, commonBindingVar)
+	commandsVar := newMapVar(vals.EmptyMap)
+	nb.AddNs("palette",
+		eval.BuildNsNamed("edit:palette").
+			AddVars(map[string]vars.Var{
+				"binding":  bindingVar,
+				"commands": commandsVar,
+			}).
+			AddGoFn("start", func() {
+				paletteStart(ed, ev, bindings, commandsVar.Get().(vals.Map))
+			}))
+}
+
+type paletteEntry struct {
+	name string
+	fn   eval.Callable
+	keys []ui.Key
+}
+
+func paletteStart(ed *Editor, ev *eval.Evaler, bindings tk.Bindings, commands vals.Map) {
+	entries := paletteEntries(ed.ns, commands)
+	fns := make(map[string]eval.Callable, len(entries))
+	for _, entry := range entries {
+		fns[entry.name] = entry.fn
+	}
+	w, err := modes.NewListing(ed.app, modes.ListingSpec{
+		Bindings: bindings,
+		Caption:  " PALETTE ",
+		GetItems: func(q string) ([]modes.ListingItem, int) {
+			p := filterSpec.Maker(q)
+			var items []modes.ListingItem
+			for _, entry := range entries {
+				if p(entry.name) {
+					items = append(items, modes.ListingItem{
+						ToAccept: entry.name, ToShow: showPaletteEntry(entry)})
+				}
+			}
+			return items, 0
+		},
+		Accept: func(name string) {
+			callWithNotifyPorts(ed, ev, fns[name])
+		},
+	})
+	startMode(ed.app, w, err)
+}
+
+// Returns all the functions in the edit: namespace that can be called without
+// arguments, plus the user-defined commands, sorted by name. The keys of each
+// entry include those in the global binding, the insert mode binding, and the
+// binding of the namespace of the function.
+func paletteEntries(ns *eval.Ns, commands vals.Map) []paletteEntry {
+	commonMaps := []bindingsMap{
+		getVar(ns, "global-binding").(bindingsMap),
+		getVar(ns, "insert:binding").(bindingsMap),
+	}
+	var entries []paletteEntry
+	add := func(name string, fn eval.Callable, maps []bindingsMap) {
+		var keys []ui.Key
+		for _, m := range maps {
+			for _, k := range keysBoundTo(m, []any{fn}) {
+				if !containsKey(keys, k) {
+					keys = append(keys, k)
+				}
+			}
+		}
+		entries = append(entries, paletteEntry{name, fn, keys})
+	}
+	var walk func(ns *eval.Ns, prefix string, maps []bindingsMap)
+	walk = func(ns *eval.Ns, prefix string, maps []bindingsMap) {
+		if v := ns.IndexString("binding"); v != nil {
+			if m, ok := v.Get().(bindingsMap); ok {
+				maps = append([]bindingsMap{m}, maps...)
+			}
+		}
+		ns.IterateKeysString(func(name string) {
+			switch {
+			case strings.HasSuffix(name, eval.FnSuffix):
+				fn, ok := ns.IndexString(name).Get().(eval.Callable)
+				if ok && eval.AcceptsNoArgs(fn) {
+					add(prefix+strings.TrimSuffix(name, eval.FnSuffix), fn, maps)
+				}
+			case strings.HasSuffix(name, eval.NsSuffix):
+				if sub, ok := ns.IndexString(name).Get().(*eval.Ns); ok {
+					walk(sub, prefix+name, maps)
+				}
+			}
+		})
+	}
+	walk(ns, "edit:", commonMaps)
+	for it := commands.Iterator(); it.HasElem(); it.Next() {
+		k, v := it.Elem()
+		name, nameOk := k.(string)
+		fn, fnOk := v.(eval.Callable)
+		if nameOk && fnOk {
+			add(name, fn, commonMaps)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	return entries
+}
+
+func containsKey(keys []ui.Key, k ui.Key) bool {
+	for _, key := range keys {
+		if key == k {
+			return true
+		}
+	}
+	return false
+}
+
+func showPaletteEntry(entry paletteEntry) ui.Text {
+	t := ui.T(entry.name)
+	for _, k := range entry.keys {
+		t = ui.Concat(t, ui.T(" "), ui.T(k.String(), ui.FgMagenta))
+	}
+	return t
+}
@@ -0,0 +1,54 @@
+package edit
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/ui"
+)
+
+func TestPalette(t *testing.T) {
+	f := setup(t)
+
+	evals(f.Evaler,
+		`var called = $false`,
+		`set edit:palette:commands[my-command] = { set called = $true }`,
+		`set edit:insert:binding[Ctrl-Y] = $edit:palette:commands[my-command]`)
+	f.TTYCtrl.Inject(term.K('p', ui.Alt))
+	feedInput(f.TTYCtrl, "kill-line")
+	f.TestTTY(t,
+		"~> \n",
+		" PALETTE  kill-line", Styles,
+		"*********          ", term.DotHere, "\n",
+		"edit:kill-line-left Ctrl-U                        \n", Styles,
+		"++++++++++++++++++++XXXXXXXXXXXXXXXXXXXXXXXXXXXXXX",
+		"edit:kill-line-right Ctrl-K                       ", Styles,
+		"                     -----------------------------",
+	)
+
+	// User-defined commands are shown and can be called.
+	f.TTYCtrl.Inject(term.K('[', ui.Ctrl), term.K('p', ui.Alt))
+	feedInput(f.TTYCtrl, "my-command")
+	f.TestTTY(t,
+		"~> \n",
+		" PALETTE  my-command", Styles,
+		"*********           ", term.DotHere, "\n",
+		"my-command Ctrl-Y                                 ", Styles,
+		"+++++++++++XXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXX",
+	)
+	f.TTYCtrl.Inject(term.K(ui.Enter))
+	f.TestTTY(t, "~> ", term.DotHere)
+	testGlobal(t, f.Evaler, "called", true)
+}
+
+func TestPalette_ExcludesFunctionsTakingArgs(t *testing.T) {
+	f := setup(t)
+
+	f.TTYCtrl.Inject(term.K('p', ui.Alt))
+	feedInput(f.TTYCtrl, "insert-at-dot")
+	f.TestTTY(t,
+		"~> \n",
+		" PALETTE  insert-at-dot", Styles,
+		"*********              ", term.DotHere,
+	)
+}
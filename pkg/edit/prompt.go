@@ -1,6 +1,7 @@
 package edit
 
 import (
+	"errors"
 	"io"
 	"os"
 	"os/user"
@@ -23,7 +24,9 @@ func initPrompts(appSpec *cli.AppSpec, nt notifier, ev *eval.Evaler, nb eval.NsB
 
 	rpromptPersistentVar := newBoolVar(false)
 	appSpec.RPromptPersistent = func() bool { return rpromptPersistentVar.Get().(bool) }
-	nb.AddVar("rprompt-persistent", rpromptPersistentVar)
+	nb.AddVar("rprompt-persistent", ev.RegisterOption(
+		eval.OptionSpec{Name: "edit:rprompt-persistent", Default: false},
+		rpromptPersistentVar))
 }
 
 func initPrompt(p *cli.Prompt, name string, val eval.Callable, nt notifier, ev *eval.Evaler, nb eval.NsBuilder) {
@@ -32,7 +35,9 @@ func initPrompt(p *cli.Prompt, name string, val eval.Callable, nt notifier, ev *
 	eagernessVar := newIntVar(5)
 	nb.AddVar("-"+name+"-eagerness", eagernessVar)
 	staleThresholdVar := newFloatVar(0.2)
-	nb.AddVar(name+"-stale-threshold", staleThresholdVar)
+	nb.AddVar(name+"-stale-threshold", ev.RegisterOption(eval.OptionSpec{
+		Name: "edit:" + name + "-stale-threshold", Default: 0.2,
+		Validate: validateNonNegative}, staleThresholdVar))
 	staleTransformVar := newFnVar(
 		eval.NewGoFn("<default stale transform>", defaultStaleTransform))
 	nb.AddVar(name+"-stale-transform", staleTransformVar)
@@ -52,6 +57,17 @@ func initPrompt(p *cli.Prompt, name string, val eval.Callable, nt notifier, ev *
 	})
 }
 
+func validateNonNegative(v any) error {
+	var f float64
+	if err := vals.ScanToGo(v, &f); err != nil {
+		return err
+	}
+	if f < 0 {
+		return errors.New("must be >= 0")
+	}
+	return nil
+}
+
 func getDefaultPromptVals() (prompt, rprompt eval.Callable) {
 	user, userErr := user.Current()
 	isRoot := userErr == nil && user.Uid == "0"
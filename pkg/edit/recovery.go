@@ -0,0 +1,100 @@
+package edit
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"unicode/utf8"
+
+	"src.elv.sh/pkg/cli"
+	"src.elv.sh/pkg/cli/tk"
+)
+
+// Saves the code buffer so that it can be recovered after an abnormal exit.
+type bufferRecovery struct {
+	mu   sync.Mutex
+	path string
+}
+
+// Format of the file storing the code buffer.
+type savedBuffer struct {
+	Content string `json:"content"`
+	Dot     int    `json:"dot"`
+}
+
+func initBufferRecovery(appSpec *cli.AppSpec, r *bufferRecovery) {
+	appSpec.OnBufferChange = append(appSpec.OnBufferChange, r.save)
+	appSpec.AfterReadline = append(appSpec.AfterReadline, func(string) { r.remove() })
+}
+
+func (r *bufferRecovery) save(b tk.CodeBuffer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.path == "" {
+		return
+	}
+	if b.Content == "" {
+		os.Remove(r.path)
+		return
+	}
+	data, err := json.Marshal(savedBuffer{b.Content, b.Dot})
+	if err != nil {
+		return
+	}
+	// Write to a temporary file first, so that the saved buffer is never left
+	// half-written.
+	tmp := r.path + ".tmp"
+	if os.WriteFile(tmp, data, 0600) != nil {
+		return
+	}
+	os.Rename(tmp, r.path)
+}
+
+func (r *bufferRecovery) remove() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.path != "" {
+		os.Remove(r.path)
+	}
+}
+
+// EnableBufferRecovery makes the editor save the code buffer to the file at
+// path whenever it changes, and remove the file when the code is submitted or
+// the buffer becomes empty. If the process exits abnormally while the user is
+// editing, the buffer can later be read back with [ReadSavedBuffer].
+//
+// The file should be in a directory only accessible to the current user.
+func (ed *Editor) EnableBufferRecovery(path string) {
+	ed.recovery.mu.Lock()
+	defer ed.recovery.mu.Unlock()
+	ed.recovery.path = path
+}
+
+// ReadSavedBuffer reads a code buffer saved by an editor with buffer recovery
+// enabled, returning the content and the position of the cursor.
+func ReadSavedBuffer(path string) (string, int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", 0, err
+	}
+	var b savedBuffer
+	if err := json.Unmarshal(data, &b); err != nil {
+		return "", 0, err
+	}
+	dot := b.Dot
+	if dot < 0 || dot > len(b.Content) ||
+		(dot < len(b.Content) && !utf8.RuneStart(b.Content[dot])) {
+		dot = len(b.Content)
+	}
+	return b.Content, dot, nil
+}
+
+// RestoreBuffer replaces the code buffer with the given content and cursor
+// position. It is meant to be called before ReadCode, to restore a buffer
+// read with [ReadSavedBuffer].
+func (ed *Editor) RestoreBuffer(content string, dot int) {
+	codeArea := ed.app.ActiveWidget().(tk.CodeArea)
+	codeArea.MutateState(func(s *tk.CodeAreaState) {
+		s.Buffer = tk.CodeBuffer{Content: content, Dot: dot}
+	})
+}
@@ -0,0 +1,56 @@
+package edit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/must"
+	"src.elv.sh/pkg/ui"
+)
+
+func TestBufferRecovery(t *testing.T) {
+	f := setup(t)
+	path := filepath.Join(f.Home, "buffer.json")
+	f.Editor.EnableBufferRecovery(path)
+
+	feedInput(f.TTYCtrl, "echo")
+	f.TTYCtrl.Inject(term.K(ui.Left))
+	f.TestTTY(t, "~> ech", Styles,
+		"   vvv", term.DotHere, "o", Styles,
+		"v")
+	content, dot, err := ReadSavedBuffer(path)
+	if content != "echo" || dot != 3 || err != nil {
+		t.Errorf("ReadSavedBuffer -> (%q, %v, %v), want (%q, 3, nil)", content, dot, err, "echo")
+	}
+
+	// The file is removed when the code is submitted.
+	f.TTYCtrl.Inject(term.K('\n'))
+	f.Wait()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("saved buffer not removed after submission")
+	}
+}
+
+func TestReadSavedBuffer_FixesInvalidDot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "buffer.json")
+	for _, data := range []string{
+		`{"content": "foo", "dot": 10}`,
+		`{"content": "foo", "dot": -1}`,
+		`{"content": "你好", "dot": 1}`,
+	} {
+		must.WriteFile(path, data)
+		content, dot, err := ReadSavedBuffer(path)
+		if err != nil || dot != len(content) {
+			t.Errorf("ReadSavedBuffer with %s -> (%q, %v, %v), want dot at end", data, content, dot, err)
+		}
+	}
+}
+
+func TestRestoreBuffer(t *testing.T) {
+	f := setup(t)
+	f.Editor.RestoreBuffer("echo foo", 4)
+	f.TestTTY(t, "~> echo", Styles,
+		"   vvvv", term.DotHere, " foo")
+}
@@ -0,0 +1,246 @@
+package edit
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"src.elv.sh/pkg/cli"
+	"src.elv.sh/pkg/cli/modes"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/eval/vars"
+	"src.elv.sh/pkg/ui"
+)
+
+// A tab stop in an expanded snippet, spanning the byte range [from, to) of the
+// code buffer.
+type snippetStop struct {
+	num      int
+	from, to int
+}
+
+// State of the tab stops of the last inserted snippet.
+type snippetState struct {
+	// The content of the code buffer when the current tab stop was entered.
+	content string
+	stops   []snippetStop
+	// Index of the current tab stop.
+	current int
+}
+
+var errNoActiveSnippet = errors.New("no active snippet")
+
+func initSnippet(ed *Editor, ev *eval.Evaler, commonBindingVar vars.PtrVar, nb eval.NsBuilder) {
+	bindingVar := newBindingVar(emptyBindingsMap)
+	bindings := newMapBindings(ed, ev, bindingVar, commonBindingVar)
+	snippetsVar := newMapVar(vals.EmptyMap)
+	app := ed.app
+	var state *snippetState
+	next := func() bool {
+		if !snippetCheck(app, state) {
+			state = nil
+			return false
+		}
+		if !snippetNext(app, state) {
+			state = nil
+		}
+		return true
+	}
+	ed.snippetNext = next
+	nb.AddNs("snippet",
+		eval.BuildNsNamed("edit:snippet").
+			AddVars(map[string]vars.Var{
+				"binding":  bindingVar,
+				"snippets": snippetsVar,
+			}).
+			AddGoFns(map[string]any{
+				"start": func() {
+					snippetStart(app, bindings, snippetsVar.Get().(vals.Map), &state)
+				},
+				"active": func() bool { return snippetCheck(app, state) },
+				"next": func() {
+					if !next() {
+						notifyError(app, errNoActiveSnippet)
+					}
+				},
+			}))
+}
+
+func snippetStart(app cli.App, bindings tk.Bindings, snippets vals.Map, state **snippetState) {
+	var names []string
+	templates := make(map[string]string)
+	for it := snippets.Iterator(); it.HasElem(); it.Next() {
+		k, v := it.Elem()
+		name, ok1 := k.(string)
+		template, ok2 := v.(string)
+		if ok1 && ok2 {
+			names = append(names, name)
+			templates[name] = template
+		}
+	}
+	sort.Strings(names)
+	nameWidth := 0
+	for _, name := range names {
+		nameWidth = max(nameWidth, len(name))
+	}
+	w, err := modes.NewListing(app, modes.ListingSpec{
+		Bindings: bindings,
+		Caption:  " SNIPPET ",
+		GetItems: func(q string) ([]modes.ListingItem, int) {
+			p := filterSpec.Maker(q)
+			var items []modes.ListingItem
+			for _, name := range names {
+				if !p(name) {
+					continue
+				}
+				text, _ := expandSnippet(templates[name])
+				preview, _, _ := strings.Cut(text, "\n")
+				items = append(items, modes.ListingItem{
+					ToAccept: name,
+					ToShow: ui.Concat(
+						ui.T(name+strings.Repeat(" ", nameWidth-len(name)+2)),
+						ui.T(preview, ui.FgMagenta)),
+				})
+			}
+			return items, 0
+		},
+		Accept: func(name string) {
+			*state = snippetInsert(app, templates[name])
+		},
+	})
+	startMode(app, w, err)
+}
+
+// Inserts the expansion of a snippet at the dot, and moves the dot to the
+// first tab stop. Returns the state of the remaining tab stops, or nil if
+// there are none.
+func snippetInsert(app cli.App, template string) *snippetState {
+	codeArea, ok := focusedCodeArea(app)
+	if !ok {
+		return nil
+	}
+	text, stops := expandSnippet(template)
+	var state *snippetState
+	codeArea.MutateState(func(s *tk.CodeAreaState) {
+		dot := s.Buffer.Dot
+		s.Buffer.InsertAtDot(text)
+		for i := range stops {
+			stops[i].from += dot
+			stops[i].to += dot
+		}
+		s.Buffer.Dot = stops[0].to
+		if len(stops) > 1 {
+			state = &snippetState{content: s.Buffer.Content, stops: stops}
+		}
+	})
+	return state
+}
+
+// Reports whether state is still valid, which is the case when the code
+// buffer has only been edited within the current tab stop.
+func snippetCheck(app cli.App, state *snippetState) bool {
+	if state == nil {
+		return false
+	}
+	codeArea, ok := focusedCodeArea(app)
+	if !ok {
+		return false
+	}
+	content := codeArea.CopyState().Buffer.Content
+	cur := state.stops[state.current]
+	delta := len(content) - len(state.content)
+	return cur.to+delta >= cur.from &&
+		strings.HasPrefix(content, state.content[:cur.from]) &&
+		strings.HasSuffix(content, state.content[cur.to:])
+}
+
+// Moves the dot to the next tab stop, taking into account the edits made
+// within the current tab stop. Returns whether there are more tab stops.
+func snippetNext(app cli.App, state *snippetState) bool {
+	codeArea, _ := focusedCodeArea(app)
+	more := false
+	codeArea.MutateState(func(s *tk.CodeAreaState) {
+		content := s.Buffer.Content
+		delta := len(content) - len(state.content)
+		cur := state.stops[state.current]
+		for i := range state.stops {
+			if i != state.current && state.stops[i].from >= cur.to {
+				state.stops[i].from += delta
+				state.stops[i].to += delta
+			}
+		}
+		state.current++
+		state.content = content
+		s.Buffer.Dot = state.stops[state.current].to
+		more = state.current+1 < len(state.stops)
+	})
+	return more
+}
+
+// Expands a snippet template, returning the text and the tab stops in the
+// order they should be visited. The returned tab stops always end with the
+// final tab stop, which is $0 if it is present, or the end of text otherwise.
+func expandSnippet(template string) (string, []snippetStop) {
+	var sb strings.Builder
+	var stops []snippetStop
+	hasFinal := false
+	for i := 0; i < len(template); i++ {
+		if template[i] != '$' || i+1 == len(template) {
+			sb.WriteByte(template[i])
+			continue
+		}
+		rest := template[i+1:]
+		var num, n int
+		var placeholder string
+		switch {
+		case rest[0] == '$':
+			sb.WriteByte('$')
+			i++
+			continue
+		case isASCIIDigit(rest[0]):
+			n = 1
+			for n < len(rest) && isASCIIDigit(rest[n]) {
+				n++
+			}
+			num, _ = strconv.Atoi(rest[:n])
+		case rest[0] == '{' && len(rest) > 1 && isASCIIDigit(rest[1]):
+			end := strings.IndexByte(rest, '}')
+			if end == -1 {
+				sb.WriteByte('$')
+				continue
+			}
+			numText, defaultText, _ := strings.Cut(rest[1:end], ":")
+			var err error
+			num, err = strconv.Atoi(numText)
+			if err != nil {
+				sb.WriteByte('$')
+				continue
+			}
+			placeholder = defaultText
+			n = end + 1
+		default:
+			sb.WriteByte('$')
+			continue
+		}
+		from := sb.Len()
+		sb.WriteString(placeholder)
+		stops = append(stops, snippetStop{num, from, sb.Len()})
+		if num == 0 {
+			hasFinal = true
+		}
+		i += n
+	}
+	sort.SliceStable(stops, func(i, j int) bool {
+		// $0 sorts last.
+		return stops[i].num != 0 && (stops[j].num == 0 || stops[i].num < stops[j].num)
+	})
+	if !hasFinal {
+		stops = append(stops, snippetStop{0, sb.Len(), sb.Len()})
+	}
+	return sb.String(), stops
+}
+
+func isASCIIDigit(b byte) bool { return '0' <= b && b <= '9' }
@@ -0,0 +1,100 @@
+package edit
+
+import (
+	"reflect"
+	"testing"
+
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/ui"
+)
+
+func TestSnippet(t *testing.T) {
+	f := setup(t, rc(
+		`set edit:snippet:snippets = [&rsync='rsync ${1:src}/ ${2:dest}$0 -av' &ls='ls $1']`,
+		`set edit:insert:binding[Alt-s] = $edit:snippet:start~`))
+
+	f.TTYCtrl.Inject(term.K('s', ui.Alt))
+	f.TestTTY(t,
+		"~> \n",
+		" SNIPPET  ", Styles,
+		"*********  ", term.DotHere, "\n",
+		"ls     ls                                         \n", Styles,
+		"+++++++XXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXX",
+		"rsync  rsync src/ dest -av                        ", Styles,
+		"       --------------------------------------------",
+	)
+
+	// Accepting moves the dot to the end of the first tab stop.
+	feedInput(f.TTYCtrl, "rs")
+	f.TTYCtrl.Inject(term.K(ui.Enter))
+	f.TestTTY(t,
+		"~> rsync src", Styles,
+		"   !!!!!    ", term.DotHere, "/ dest -av",
+	)
+
+	// Edits within the current tab stop are taken into account.
+	f.TTYCtrl.Inject(term.K(ui.Backspace), term.K(ui.Backspace), term.K(ui.Backspace))
+	feedInput(f.TTYCtrl, "/tmp/a")
+	f.TTYCtrl.Inject(term.K(ui.Tab))
+	f.TestTTY(t,
+		"~> rsync /tmp/a/ dest", Styles,
+		"   !!!!!             ", term.DotHere, " -av",
+	)
+	evals(f.Evaler, `var active = (edit:snippet:active)`)
+	testGlobal(t, f.Evaler, "active", true)
+
+	// Moving to $0 finishes the snippet.
+	f.TTYCtrl.Inject(term.K(ui.Tab))
+	f.TestTTY(t,
+		"~> rsync /tmp/a/ dest", Styles,
+		"   !!!!!             ", term.DotHere, " -av",
+	)
+	evals(f.Evaler, `set active = (edit:snippet:active)`)
+	testGlobal(t, f.Evaler, "active", false)
+}
+
+func TestSnippet_EditOutsideTabStop(t *testing.T) {
+	f := setup(t, rc(
+		`set edit:snippet:snippets = [&e='echo $1 $2']`,
+		`set edit:insert:binding[Alt-s] = $edit:snippet:start~`))
+
+	f.TTYCtrl.Inject(term.K('s', ui.Alt), term.K(ui.Enter))
+	f.TestTTY(t,
+		"~> echo ", Styles,
+		"   vvvv ", term.DotHere, " ",
+	)
+
+	f.TTYCtrl.Inject(term.K(ui.End))
+	feedInput(f.TTYCtrl, "x")
+	f.TestTTY(t,
+		"~> echo  x", Styles,
+		"   vvvv   ", term.DotHere,
+	)
+	evals(f.Evaler, `edit:snippet:next`)
+	f.TTYCtrl.TestMsg(t,
+		ui.Concat(ui.T("error:", ui.FgRed), ui.T(" no active snippet")))
+}
+
+var expandSnippetTests = []struct {
+	template  string
+	wantText  string
+	wantStops []snippetStop
+}{
+	{"echo", "echo", []snippetStop{{0, 4, 4}}},
+	{"a $2 b $1", "a  b ", []snippetStop{{1, 5, 5}, {2, 2, 2}, {0, 5, 5}}},
+	{"${1:foo} $0 ${1:bar}", "foo  bar",
+		[]snippetStop{{1, 0, 3}, {1, 5, 8}, {0, 4, 4}}},
+	{"$10 $2", " ", []snippetStop{{2, 1, 1}, {10, 0, 0}, {0, 1, 1}}},
+	{"$$1 $x ${x} $", "$1 $x ${x} $", []snippetStop{{0, 12, 12}}},
+	{"${1:unclosed", "${1:unclosed", []snippetStop{{0, 12, 12}}},
+}
+
+func TestExpandSnippet(t *testing.T) {
+	for _, test := range expandSnippetTests {
+		text, stops := expandSnippet(test.template)
+		if text != test.wantText || !reflect.DeepEqual(stops, test.wantStops) {
+			t.Errorf("expandSnippet(%q) -> (%q, %v), want (%q, %v)",
+				test.template, text, stops, test.wantText, test.wantStops)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package edit
+
+//go:generate go run gen_unicode_names.go
+
+import (
+	_ "embed"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"src.elv.sh/pkg/cli/modes"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/eval/vars"
+	"src.elv.sh/pkg/ui"
+)
+
+//go:embed unicode_names.txt
+var unicodeNamesTxt string
+
+type unicodeChar struct {
+	r    rune
+	name string
+}
+
+var unicodeChars = sync.OnceValue(func() []unicodeChar {
+	lines := strings.Split(strings.TrimSuffix(unicodeNamesTxt, "\n"), "\n")
+	chars := make([]unicodeChar, 0, len(lines))
+	for _, line := range lines {
+		code, name, _ := strings.Cut(line, "\t")
+		r, err := strconv.ParseUint(code, 16, 32)
+		if err != nil {
+			continue
+		}
+		chars = append(chars, unicodeChar{rune(r), name})
+	}
+	return chars
+})
+
+// Maximum number of recently used characters to remember.
+const maxUnicodeRecent = 20
+
+func initUnicode(ed *Editor, ev *eval.Evaler, commonBindingVar vars.PtrVar, nb eval.NsBuilder) {
+	bindingVar := newBindingVar(emptyBindingsMap)
+	bindings := newMapBindings(ed, ev, bindingVar, commonBindingVar)
+	recentVar := newListVar(vals.EmptyList)
+	nb.AddNs("unicode",
+		eval.BuildNsNamed("edit:unicode").
+			AddVars(map[string]vars.Var{
+				"binding": bindingVar,
+				"recent":  recentVar,
+			}).
+			AddGoFn("start", func() { unicodeStart(ed, bindings, recentVar) }))
+}
+
+func unicodeStart(ed *Editor, bindings tk.Bindings, recentVar vars.PtrVar) {
+	var recent []string
+	vals.Iterate(recentVar.Get(), func(v any) bool {
+		if s, ok := v.(string); ok && s != "" {
+			recent = append(recent, s)
+		}
+		return true
+	})
+	names := make(map[rune]string)
+	for _, c := range unicodeChars() {
+		names[c.r] = c.name
+	}
+	w, err := modes.NewListing(ed.app, modes.ListingSpec{
+		Bindings: bindings,
+		Caption:  " UNICODE ",
+		GetItems: func(q string) ([]modes.ListingItem, int) {
+			p := filterSpec.Maker(q)
+			var items []modes.ListingItem
+			isRecent := make(map[rune]bool)
+			for _, s := range recent {
+				r := []rune(s)[0]
+				isRecent[r] = true
+				if c := (unicodeChar{r, names[r]}); p(unicodeFilterText(c)) {
+					items = append(items, unicodeListingItem(c))
+				}
+			}
+			for _, c := range unicodeChars() {
+				if !isRecent[c.r] && p(unicodeFilterText(c)) {
+					items = append(items, unicodeListingItem(c))
+				}
+			}
+			return items, 0
+		},
+		Accept: func(s string) {
+			codeArea, ok := focusedCodeArea(ed.app)
+			if !ok {
+				return
+			}
+			codeArea.MutateState(func(st *tk.CodeAreaState) {
+				st.Buffer.InsertAtDot(s)
+			})
+			newRecent := vals.MakeList(s)
+			for _, r := range recent {
+				if r != s && newRecent.Len() < maxUnicodeRecent {
+					newRecent = newRecent.Conj(r)
+				}
+			}
+			recentVar.Set(newRecent)
+		},
+	})
+	startMode(ed.app, w, err)
+}
+
+// The text used for filtering contains both the name and the code point, so
+// that characters can also be found with queries like "u+2603".
+func unicodeFilterText(c unicodeChar) string {
+	return fmt.Sprintf("%s u+%04x", c.name, c.r)
+}
+
+func unicodeListingItem(c unicodeChar) modes.ListingItem {
+	return modes.ListingItem{
+		ToAccept: string(c.r),
+		ToShow:   ui.T(fmt.Sprintf("%c %s U+%04X", c.r, c.name, c.r)),
+	}
+}
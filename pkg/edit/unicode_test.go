@@ -0,0 +1,68 @@
+package edit
+
+import (
+	"strings"
+	"testing"
+
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/ui"
+	"src.elv.sh/pkg/wcwidth"
+)
+
+func TestUnicode(t *testing.T) {
+	f := setup(t)
+
+	evals(f.Evaler, `set edit:insert:binding[Alt-u] = $edit:unicode:start~`)
+	f.TTYCtrl.Inject(term.K('u', ui.Alt))
+	feedInput(f.TTYCtrl, "snowman")
+	f.TestTTY(t,
+		"~> \n",
+		" UNICODE  snowman", Styles,
+		"*********        ", term.DotHere, "\n",
+		pad("☃ snowman U+2603")+"\n", Styles,
+		"++++++++++++++++++++++++++++++++++++++++++++++++++",
+		pad("⛄ snowman without snow U+26C4")+"\n",
+		pad("⛇ black snowman U+26C7"),
+	)
+
+	// Accepting inserts the character and records it as recently used.
+	f.TTYCtrl.Inject(term.K(ui.Down), term.K(ui.Enter))
+	f.TestTTY(t, "~> ⛄", Styles,
+		"   !", term.DotHere)
+	evals(f.Evaler, `var r = $edit:unicode:recent`)
+	testGlobal(t, f.Evaler, "r", vals.MakeList("⛄"))
+
+	// Recently used characters are shown first.
+	f.TTYCtrl.Inject(term.K('u', ui.Alt))
+	feedInput(f.TTYCtrl, "snowman")
+	f.TestTTY(t,
+		"~> ⛄\n", Styles,
+		"   !",
+		" UNICODE  snowman", Styles,
+		"*********        ", term.DotHere, "\n",
+		pad("⛄ snowman without snow U+26C4")+"\n", Styles,
+		"++++++++++++++++++++++++++++++++++++++++++++++++++",
+		pad("☃ snowman U+2603")+"\n",
+		pad("⛇ black snowman U+26C7"),
+	)
+}
+
+func TestUnicode_CodePointQuery(t *testing.T) {
+	f := setup(t)
+
+	evals(f.Evaler, `edit:unicode:start`)
+	feedInput(f.TTYCtrl, "u+1f600")
+	f.TestTTY(t,
+		"~> \n",
+		" UNICODE  u+1f600", Styles,
+		"*********        ", term.DotHere, "\n",
+		pad("😀 grinning face U+1F600"), Styles,
+		"++++++++++++++++++++++++++++++++++++++++++++++++++",
+	)
+}
+
+// Pads s to the width of the test terminal.
+func pad(s string) string {
+	return s + strings.Repeat(" ", 50-wcwidth.Of(s))
+}
@@ -0,0 +1,57 @@
+package edit
+
+import (
+	"src.elv.sh/pkg/buildinfo"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vars"
+	"src.elv.sh/pkg/ui"
+)
+
+const defaultUpdateFeed = "https://api.github.com/repos/elves/elvish/releases/latest"
+
+func initUpdateCheck(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
+	checkUpdates := newBoolVar(false)
+	feed := defaultUpdateFeed
+	updateFeed := vars.FromPtr(&feed)
+	nb.AddVar("check-updates", ev.RegisterOption(
+		eval.OptionSpec{Name: "edit:check-updates", Default: false}, checkUpdates))
+	nb.AddVar("update-feed", ev.RegisterOption(
+		eval.OptionSpec{Name: "edit:update-feed", Default: defaultUpdateFeed}, updateFeed))
+	ed.updateCheckConfig = func() (bool, string) {
+		return checkUpdates.Get().(bool), updateFeed.Get().(string)
+	}
+}
+
+// CheckUpdate checks whether a newer version of Elvish is available if
+// $edit:check-updates is true, using latest to get the latest version from the
+// release feed. If a newer version is available, a notification is shown.
+//
+// Errors from latest are ignored, since the check is not essential.
+func (ed *Editor) CheckUpdate(latest func(feed string) (string, error)) {
+	enabled, feed := ed.updateCheckConfig()
+	if !enabled {
+		return
+	}
+	version, err := latest(feed)
+	if err != nil {
+		return
+	}
+	if isNewerVersion(version, buildinfo.Value.Version) {
+		ed.Notify(ui.T("Elvish " + version + " is available (running " +
+			buildinfo.Value.Version + "); see https://elv.sh/get"))
+	}
+}
+
+// Returns whether version a is newer than b. Returns false if either version
+// can't be parsed.
+func isNewerVersion(a, b string) bool {
+	av, ok := buildinfo.ParseVersion(a)
+	if !ok {
+		return false
+	}
+	bv, ok := buildinfo.ParseVersion(b)
+	if !ok {
+		return false
+	}
+	return buildinfo.CompareVersions(av, bv) > 0
+}
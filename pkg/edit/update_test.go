@@ -0,0 +1,65 @@
+package edit
+
+import (
+	"errors"
+	"testing"
+
+	"src.elv.sh/pkg/buildinfo"
+	"src.elv.sh/pkg/testutil"
+	"src.elv.sh/pkg/ui"
+)
+
+func TestIsNewerVersion(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"0.23.0", "0.22.0-dev.unknown", true},
+		{"0.22.0", "0.22.0+ubuntu", false},
+		{"0.22.0-rc.1", "0.22.0", false},
+		{"bad", "0.22.0", false},
+		{"0.23.0", "bad", false},
+	}
+	for _, test := range tests {
+		if got := isNewerVersion(test.a, test.b); got != test.want {
+			t.Errorf("isNewerVersion(%q, %q) -> %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestCheckUpdate(t *testing.T) {
+	testutil.Set(t, &buildinfo.Value.Version, "0.22.0")
+	f := setup(t)
+
+	called := false
+	latest := func(feed string) (string, error) {
+		called = true
+		return "0.23.0", nil
+	}
+
+	// Disabled by default.
+	f.Editor.CheckUpdate(latest)
+	if called {
+		t.Errorf("latest called when $edit:check-updates is $false")
+	}
+
+	evals(f.Evaler, "set edit:check-updates = $true", "set edit:update-feed = https://example.com/feed")
+	var gotFeed string
+	f.Editor.CheckUpdate(func(feed string) (string, error) {
+		gotFeed = feed
+		return "0.23.0", nil
+	})
+	if gotFeed != "https://example.com/feed" {
+		t.Errorf("got feed %q, want %q", gotFeed, "https://example.com/feed")
+	}
+	f.TTYCtrl.TestMsg(t,
+		ui.T("Elvish 0.23.0 is available (running 0.22.0); see https://elv.sh/get"))
+
+	// No notification when the version is not newer, or when there's an error.
+	nNotes := len(f.Editor.app.CopyState().Notes)
+	f.Editor.CheckUpdate(func(string) (string, error) { return "0.22.0", nil })
+	f.Editor.CheckUpdate(func(string) (string, error) { return "", errors.New("error") })
+	if n := len(f.Editor.app.CopyState().Notes); n != nNotes {
+		t.Errorf("got %d new notes, want none", n-nNotes)
+	}
+}
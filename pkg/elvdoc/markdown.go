@@ -0,0 +1,37 @@
+package elvdoc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Markdown converts the elvdocs of a module to a Markdown document, with the
+// module doc at the top, followed by sections for variables and functions.
+// The title is used as the top-level heading.
+func Markdown(title string, docs Docs) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n", title)
+	if docs.File != nil && docs.File.Content != "" {
+		fmt.Fprintf(&sb, "\n%s", docs.File.Content)
+	}
+	writeSection := func(heading string, entries []Entry) {
+		if len(entries) == 0 {
+			return
+		}
+		fmt.Fprintf(&sb, "\n## %s\n", heading)
+		entries = append([]Entry(nil), entries...)
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+		for _, entry := range entries {
+			fmt.Fprintf(&sb, "\n### %s\n", entry.Name)
+			if content := entry.FullContent(); content != "" {
+				// The content of functions without docs ends with an empty
+				// line after the usage.
+				fmt.Fprintf(&sb, "\n%s\n", strings.TrimRight(content, "\n"))
+			}
+		}
+	}
+	writeSection("Variables", docs.Vars)
+	writeSection("Functions", docs.Fns)
+	return sb.String()
+}
@@ -0,0 +1,53 @@
+package elvdoc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdown(t *testing.T) {
+	docs, err := Extract(strings.NewReader(dedent(`
+		# Utilities.
+
+		# The version.
+		var version = 1.0
+
+		# Greets someone.
+		fn greet {|name| echo hello $name }
+
+		fn add {|a b| + $a $b }
+		`)), "util:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := dedent(`
+		# util
+
+		Utilities.
+
+		## Variables
+
+		### $util:version
+
+		The version.
+
+		## Functions
+
+		### util:add
+
+		` + "```" + `elvish
+		util:add $a $b
+		` + "```" + `
+
+		### util:greet
+
+		` + "```" + `elvish
+		util:greet $name
+		` + "```" + `
+
+		Greets someone.
+		`)
+	if got := Markdown("util", docs); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
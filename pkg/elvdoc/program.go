@@ -0,0 +1,120 @@
+package elvdoc
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"src.elv.sh/pkg/md"
+	"src.elv.sh/pkg/prog"
+)
+
+// Program is the doc generation subprogram. It extracts elvdocs from Elvish
+// modules and writes them as Markdown or HTML.
+type Program struct {
+	docGen bool
+	format string
+}
+
+func (p *Program) RegisterFlags(fs *prog.FlagSet) {
+	fs.BoolVar(&p.docGen, "doc-gen", false,
+		"Generate API docs from the doc comments of the modules in the arguments and quit")
+	fs.StringVar(&p.format, "doc-format", "md",
+		"Format of -doc-gen, either md (Markdown) or html")
+}
+
+func (p *Program) Run(fds [3]*os.File, args []string) error {
+	if !p.docGen {
+		return prog.NextProgram()
+	}
+	if p.format != "md" && p.format != "html" {
+		return prog.BadUsage("-doc-format must be md or html")
+	}
+	if len(args) == 0 {
+		return prog.BadUsage("-doc-gen requires module files or directories as arguments")
+	}
+	var docs []string
+	for _, arg := range args {
+		modules, err := findModules(arg)
+		if err != nil {
+			return err
+		}
+		for _, mod := range modules {
+			markdown, err := moduleMarkdown(mod.spec, mod.path)
+			if err != nil {
+				return err
+			}
+			docs = append(docs, markdown)
+		}
+	}
+	output := strings.Join(docs, "\n")
+	if p.format == "html" {
+		output = md.RenderString(output, &md.HTMLCodec{})
+	}
+	_, err := fds[1].WriteString(output)
+	return err
+}
+
+type moduleFile struct {
+	// The module spec used to import the module, like "a/b".
+	spec string
+	path string
+}
+
+// Finds the module files from an argument of -doc-gen. If the argument is a
+// directory, it is treated like a module search directory.
+func findModules(arg string) ([]moduleFile, error) {
+	info, err := os.Stat(arg)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		spec := strings.TrimSuffix(filepath.Base(arg), ".elv")
+		return []moduleFile{{spec, arg}}, nil
+	}
+	var modules []moduleFile
+	err = fs.WalkDir(os.DirFS(arg), ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && path.Ext(p) == ".elv" {
+			modules = append(modules,
+				moduleFile{strings.TrimSuffix(p, ".elv"), filepath.Join(arg, p)})
+		}
+		return nil
+	})
+	return modules, err
+}
+
+// Returns the Markdown document for the module with the given spec in the
+// given file.
+func moduleMarkdown(spec, filename string) (string, error) {
+	docs, err := ExtractFromFile(filename, ModulePrefix(spec))
+	if err != nil {
+		return "", err
+	}
+	return Markdown(spec, docs), nil
+}
+
+// ExtractFromFile extracts the elvdoc of a module from a file.
+func ExtractFromFile(filename, symbolPrefix string) (Docs, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return Docs{}, err
+	}
+	defer file.Close()
+	docs, err := Extract(file, symbolPrefix)
+	if err != nil {
+		return Docs{}, fmt.Errorf("%s: %w", filename, err)
+	}
+	return docs, nil
+}
+
+// ModulePrefix returns the symbol prefix of a module imported with the given
+// spec without renaming, like "b:" for "a/b".
+func ModulePrefix(spec string) string {
+	return path.Base(spec) + ":"
+}
@@ -0,0 +1,270 @@
+// Package elvfmt formats Elvish code.
+//
+// The formatter only changes whitespace outside string literals, so it never
+// changes the meaning of the code:
+//
+//   - Lines in brackets are indented with 2 spaces more than the line that
+//     opens the brackets, and lines that continue a command with ^ or a
+//     pipeline with | are indented with 2 more spaces.
+//
+//   - Runs of spaces between words are replaced with one space, except after
+//     the = of map pairs, where they are often used to align the values.
+//     Trailing whitespace is removed.
+//
+//   - A space is added before the closing brace of a lambda if there is none,
+//     like in { echo foo }.
+//
+//   - Runs of blank lines are replaced with one blank line, and blank lines
+//     at the start and the end are removed.
+//
+// Line breaks are kept, so long pipelines are not rewrapped.
+package elvfmt
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+
+	"src.elv.sh/pkg/parse"
+)
+
+const indentUnit = "  "
+
+var errChangedCode = errors.New("code bug: formatting changed the code")
+
+// Format formats the code in the source. It returns an error if the code can't
+// be parsed.
+func Format(src parse.Source) (string, error) {
+	tree, err := parse.Parse(src, parse.Config{})
+	if err != nil {
+		return "", err
+	}
+	f := &formatter{lineStart: true}
+	for _, leaf := range leaves(tree.Root, nil) {
+		f.leaf(leaf)
+	}
+	formatted := f.sb.String()
+	if formatted != "" && !strings.HasSuffix(formatted, "\n") {
+		formatted += "\n"
+	}
+
+	// The formatter only changes whitespace, so the result should have the
+	// same tokens in the same syntactic structures.
+	newTree, err := parse.Parse(parse.Source{Name: src.Name, Code: formatted}, parse.Config{})
+	if err != nil || !sameTokens(tree.Root, newTree.Root) {
+		return "", errChangedCode
+	}
+	return formatted, nil
+}
+
+// Returns the leaf nodes under the node, which cover all of its source text.
+func leaves(n parse.Node, acc []parse.Node) []parse.Node {
+	children := parse.Children(n)
+	if len(children) == 0 {
+		return append(acc, n)
+	}
+	for _, ch := range children {
+		acc = leaves(ch, acc)
+	}
+	return acc
+}
+
+type formatter struct {
+	sb strings.Builder
+	// For each open bracket, the indentation level of the line that opens it.
+	levels []int
+	// The indentation level of the current line.
+	lineLevel int
+	// Whether nothing has been written on the current line.
+	lineStart bool
+	// Spaces to write before the next token on the line.
+	space string
+	// Whether there are blank lines before the next token.
+	blank bool
+	// Whether the next line continues the current one, with ^ or |.
+	continued bool
+	// Whether the last character was \r.
+	afterCR bool
+}
+
+func (f *formatter) leaf(n parse.Node) {
+	text := parse.SourceText(n)
+	if text == "" {
+		// Like empty chunks.
+		return
+	}
+	if _, ok := n.(*parse.Sep); !ok || !isSpaces(text) {
+		f.token(n, text)
+		f.afterCR = false
+		return
+	}
+	if _, ok := parse.Parent(n).(*parse.MapPair); ok && !f.lineStart && strings.Trim(text, " \t") == "" {
+		f.space = text
+		return
+	}
+	for text != "" {
+		afterCR := f.afterCR
+		f.afterCR = false
+		switch {
+		case text[0] == ' ' || text[0] == '\t':
+			if !f.lineStart {
+				f.space = " "
+			}
+			text = text[1:]
+		case text[0] == '\r':
+			f.newline()
+			f.afterCR = true
+			text = text[1:]
+		case text[0] == '\n':
+			// The \n of \r\n may be in a different leaf.
+			if !afterCR {
+				f.newline()
+			}
+			text = text[1:]
+		case text[0] == '#':
+			end := strings.IndexAny(text, "\r\n")
+			if end == -1 {
+				end = len(text)
+			}
+			if f.lineStart {
+				f.indent(false, false)
+			} else {
+				f.sb.WriteByte(' ')
+			}
+			f.sb.WriteString(strings.TrimRight(text[:end], " \t"))
+			f.lineStart, f.space = false, ""
+			text = text[end:]
+		case text[0] == '^':
+			f.sb.WriteString(f.space)
+			f.sb.WriteByte('^')
+			f.lineStart = false
+			f.continued = true
+			text = text[1:]
+		}
+	}
+}
+
+func (f *formatter) newline() {
+	if f.lineStart {
+		if f.sb.Len() > 0 {
+			f.blank = true
+		}
+		return
+	}
+	f.sb.WriteByte('\n')
+	f.lineStart, f.space = true, ""
+}
+
+// Writes the indentation of a line that starts with a closing bracket or is
+// continued from the previous one.
+func (f *formatter) indent(closing, continued bool) {
+	if f.blank {
+		f.sb.WriteByte('\n')
+		f.blank = false
+	}
+	f.lineLevel = 0
+	if n := len(f.levels); n > 0 {
+		f.lineLevel = f.levels[n-1]
+		if !closing {
+			f.lineLevel++
+		}
+	}
+	if continued {
+		f.lineLevel++
+	}
+	f.sb.WriteString(strings.Repeat(indentUnit, f.lineLevel))
+}
+
+func (f *formatter) token(n parse.Node, text string) {
+	closing := isSep(n) && (text == ")" || text == "]" || text == "}")
+	if f.lineStart {
+		f.indent(closing, f.continued && !closing)
+	} else if f.space != "" {
+		f.sb.WriteString(f.space)
+	} else if text == "}" && isLambda(parse.Parent(n)) {
+		f.sb.WriteByte(' ')
+	}
+	f.sb.WriteString(text)
+	f.lineStart, f.space, f.continued = false, "", false
+
+	if isSep(n) {
+		switch {
+		case closing:
+			if len(f.levels) > 0 {
+				f.levels = f.levels[:len(f.levels)-1]
+			}
+		case strings.HasSuffix(text, "(") || strings.HasSuffix(text, "[") || strings.HasSuffix(text, "{"):
+			f.levels = append(f.levels, f.lineLevel)
+		case text == "|":
+			_, inPipeline := parse.Parent(n).(*parse.Pipeline)
+			f.continued = inPipeline
+		}
+	}
+}
+
+func isSep(n parse.Node) bool {
+	_, ok := n.(*parse.Sep)
+	return ok
+}
+
+func isLambda(n parse.Node) bool {
+	pn, ok := n.(*parse.Primary)
+	return ok && pn.Type == parse.Lambda
+}
+
+// Returns whether the text of a Sep consists of whitespace, comments and line
+// continuations.
+func isSpaces(text string) bool {
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case ' ', '\t', '\r', '\n':
+		case '#':
+			for i+1 < len(text) && text[i+1] != '\n' && text[i+1] != '\r' {
+				i++
+			}
+		case '^':
+			if !strings.HasPrefix(text[i+1:], "\n") && !strings.HasPrefix(text[i+1:], "\r\n") {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Returns whether the trees have the same tokens, which are leaves other than
+// whitespace and comments, in the same syntactic structures.
+func sameTokens(a, b parse.Node) bool {
+	ta, tb := tokens(a), tokens(b)
+	if len(ta) != len(tb) {
+		return false
+	}
+	for i := range ta {
+		if ta[i] != tb[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns a description of each token, including the types of its ancestors.
+func tokens(root parse.Node) []string {
+	var descs []string
+	for _, leaf := range leaves(root, nil) {
+		text := parse.SourceText(leaf)
+		if isSep(leaf) && isSpaces(text) {
+			continue
+		}
+		var sb strings.Builder
+		for n := leaf; n != nil; n = parse.Parent(n) {
+			sb.WriteString(reflect.TypeOf(n).Elem().Name())
+			if pn, ok := n.(*parse.Primary); ok {
+				sb.WriteString(pn.Type.String())
+			}
+			sb.WriteByte(' ')
+		}
+		descs = append(descs, sb.String()+text)
+	}
+	return descs
+}
@@ -0,0 +1,105 @@
+package elvfmt
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/parse"
+)
+
+var formatTests = []struct {
+	name string
+	code string
+	want string
+}{
+	{
+		name: "empty",
+		code: "",
+		want: "",
+	},
+	{
+		name: "spaces between words and at the end of lines",
+		code: "echo   foo\t bar  \necho ( put  x )\n",
+		want: "echo foo bar\necho ( put x )\n",
+	},
+	{
+		name: "trailing newline is added",
+		code: "echo foo",
+		want: "echo foo\n",
+	},
+	{
+		name: "indentation",
+		code: "fn f {|x|\n        if $x {\n echo [\n a\n  ]\n    } else {\n  put (\nb)\n}\n}\n",
+		want: "fn f {|x|\n  if $x {\n    echo [\n      a\n    ]\n  } else {\n    put (\n      b)\n  }\n}\n",
+	},
+	{
+		name: "brackets opened on the same line",
+		code: "put [(each {|x|\nput $x\n})]\nput [a\nb]\n",
+		want: "put [(each {|x|\n  put $x\n})]\nput [a\n  b]\n",
+	},
+	{
+		name: "maps",
+		code: "var m = [\n&a=   1\n    &bc= [&c=2]  \n]\n",
+		want: "var m = [\n  &a=   1\n  &bc= [&c=2]\n]\n",
+	},
+	{
+		name: "continued lines",
+		code: "echo foo ^\nbar ^\n        baz\nput x |\neach {|x| put $x }\n",
+		want: "echo foo ^\n  bar ^\n  baz\nput x |\n  each {|x| put $x }\n",
+	},
+	{
+		name: "space before the closing brace of lambdas",
+		code: "each { echo $it} [a]; each {|x|put $x}\n",
+		want: "each { echo $it } [a]; each {|x|put $x }\n",
+	},
+	{
+		name: "braced lists are kept",
+		code: "echo {a,b}\n",
+		want: "echo {a,b}\n",
+	},
+	{
+		name: "blank lines",
+		code: "\n\necho foo\n\n\n\necho bar\n\n\n",
+		want: "echo foo\n\necho bar\n",
+	},
+	{
+		name: "comments",
+		code: "# comment   \n  echo foo   # another\nfn f {\n# inside\n}\n",
+		want: "# comment\necho foo # another\nfn f {\n  # inside\n}\n",
+	},
+	{
+		name: "string literals are kept",
+		code: "echo 'a  \n   b' \"c   d\"\n",
+		want: "echo 'a  \n   b' \"c   d\"\n",
+	},
+	{
+		name: "CRLF",
+		code: "echo foo\r\necho bar\r\n",
+		want: "echo foo\necho bar\n",
+	},
+}
+
+func TestFormat(t *testing.T) {
+	for _, test := range formatTests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Format(parse.Source{Name: "[test]", Code: test.code})
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			if got != test.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, test.want)
+			}
+			// Formatting is idempotent.
+			again, err := Format(parse.Source{Name: "[test]", Code: got})
+			if again != got || err != nil {
+				t.Errorf("formatting again got (%q, %v), want (%q, nil)", again, err, got)
+			}
+		})
+	}
+}
+
+func TestFormat_ParseError(t *testing.T) {
+	_, err := Format(parse.Source{Name: "[test]", Code: "echo ["})
+	if err == nil {
+		t.Errorf("got no error")
+	}
+}
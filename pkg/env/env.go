@@ -4,14 +4,38 @@ package env
 
 // Environment variables with special significance to Elvish.
 const (
+	COLORTERM = "COLORTERM"
 	HOME      = "HOME"
 	LS_COLORS = "LS_COLORS"
 	NO_COLOR  = "NO_COLOR"
 	PATH      = "PATH"
 	PWD       = "PWD"
 	SHLVL     = "SHLVL"
+	TERM      = "TERM"
 	USERNAME  = "USERNAME"
 
+	// Extra module search directories, searched before the default ones
+	ELVISH_LIB_DIRS = "ELVISH_LIB_DIRS"
+	// Overrides of the paths of the database file, the daemon's socket, and
+	// the directory containing the database file
+	ELVISH_DB       = "ELVISH_DB"
+	ELVISH_SOCK     = "ELVISH_SOCK"
+	ELVISH_DATA_DIR = "ELVISH_DATA_DIR"
+	// Passphrase for encrypting the command history in the database, used
+	// with -db-encrypt
+	ELVISH_DB_PASSPHRASE = "ELVISH_DB_PASSPHRASE"
+	// Configuration for connecting to a daemon listening on TCP
+	ELVISH_DAEMON_ADDR  = "ELVISH_DAEMON_ADDR"
+	ELVISH_DAEMON_TOKEN = "ELVISH_DAEMON_TOKEN"
+	ELVISH_DAEMON_CA    = "ELVISH_DAEMON_CA"
+	ELVISH_DAEMON_CERT  = "ELVISH_DAEMON_CERT"
+	ELVISH_DAEMON_KEY   = "ELVISH_DAEMON_KEY"
+	// Idle timeout of the daemon spawned by the shell, how long the shell
+	// waits for it to come up, and whether to show the progress of waiting
+	ELVISH_DAEMON_IDLE_TIMEOUT  = "ELVISH_DAEMON_IDLE_TIMEOUT"
+	ELVISH_DAEMON_SPAWN_TIMEOUT = "ELVISH_DAEMON_SPAWN_TIMEOUT"
+	ELVISH_DAEMON_VERBOSE       = "ELVISH_DAEMON_VERBOSE"
+
 	// Only used on Unix
 	XDG_CONFIG_HOME = "XDG_CONFIG_HOME"
 	XDG_DATA_DIRS   = "XDG_DATA_DIRS"
@@ -19,6 +43,15 @@ const (
 	XDG_RUNTIME_DIR = "XDG_RUNTIME_DIR"
 	XDG_STATE_HOME  = "XDG_STATE_HOME"
 
+	// Used for socket activation of the daemon; only used on Unix
+	LISTEN_PID     = "LISTEN_PID"
+	LISTEN_FDS     = "LISTEN_FDS"
+	LISTEN_FDNAMES = "LISTEN_FDNAMES"
+
+	// Used to pass the listening socket from an outdated daemon to the new
+	// daemon; only used on Unix
+	ELVISH_DAEMON_HANDOFF_FD = "ELVISH_DAEMON_HANDOFF_FD"
+
 	// Only used on Windows
 	PATHEXT = "PATHEXT"
 
@@ -0,0 +1,151 @@
+package eval
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"src.elv.sh/pkg/fsutil"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/persistent/hash"
+)
+
+// AutoloadPath returns the path of the file that defines the autoloaded
+// function with the given name, searching ev.AutoloadDirs in order.
+func (ev *Evaler) AutoloadPath(name string) (string, bool) {
+	if name == "" || strings.ContainsRune(name, ':') || fsutil.DontSearch(name) {
+		return "", false
+	}
+	if len(ev.AutoloadDirs) == 0 {
+		return "", false
+	}
+	return ev.autoloads.find(ev.AutoloadDirs, name)
+}
+
+// Caches the names of the autoload files in each directory, since every
+// command that is neither a builtin nor defined in Elvish code is looked up in
+// the autoload directories whenever it is called, including in loops.
+//
+// A directory is read again when its modification time has changed, which is
+// checked at most once for each call to Eval or Call, so that looking up
+// commands doesn't involve any system calls most of the time.
+type autoloadCache struct {
+	mu sync.Mutex
+	// Whether the modification times of the directories should be checked on
+	// the next lookup.
+	stale bool
+	dirs  map[string]autoloadDir
+}
+
+type autoloadDir struct {
+	mtime time.Time
+	// Names of the functions whose autoload files are in the directory.
+	names map[string]struct{}
+}
+
+// Makes the next lookup check the directories for changes.
+func (c *autoloadCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stale = true
+}
+
+func (c *autoloadCache) find(dirs []string, name string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dirs == nil {
+		c.dirs = make(map[string]autoloadDir)
+	}
+	for _, dir := range dirs {
+		if d, ok := c.dirs[dir]; !ok || c.stale {
+			c.dirs[dir] = readAutoloadDir(dir, d, ok)
+		}
+	}
+	c.stale = false
+	for _, dir := range dirs {
+		if _, ok := c.dirs[dir].names[name]; ok {
+			return filepath.Join(dir, name+".elv"), true
+		}
+	}
+	return "", false
+}
+
+// Reads the names of the autoload files in dir, unless it hasn't changed since
+// old was read.
+func readAutoloadDir(dir string, old autoloadDir, hasOld bool) autoloadDir {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return autoloadDir{}
+	}
+	if hasOld && info.ModTime().Equal(old.mtime) {
+		return old
+	}
+	d := autoloadDir{mtime: info.ModTime(), names: make(map[string]struct{})}
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".elv")
+		if !ok {
+			continue
+		}
+		// Follow symlinks.
+		if info, err := os.Stat(filepath.Join(dir, entry.Name())); err == nil && info.Mode().IsRegular() {
+			d.names[name] = struct{}{}
+		}
+	}
+	return d
+}
+
+// autoloadableCmd is a command whose name can't be resolved at compilation
+// time. When called, it calls the autoloaded function of the same name if it
+// exists, or the external command of the same name otherwise.
+type autoloadableCmd struct {
+	Name string
+}
+
+func (c autoloadableCmd) Kind() string { return "fn" }
+
+func (c autoloadableCmd) Equal(a any) bool { return c == a }
+
+func (c autoloadableCmd) Hash() uint32 { return hash.String(c.Name) }
+
+func (c autoloadableCmd) Repr(int) string {
+	return "<autoloadable " + parse.Quote(c.Name) + ">"
+}
+
+func (c autoloadableCmd) Call(fm *Frame, args []any, opts map[string]any) error {
+	path, ok := fm.Evaler.AutoloadPath(c.Name)
+	if !ok {
+		return externalCmd{c.Name}.Call(fm, args, opts)
+	}
+	fn, err := autoload(fm, c.Name, path)
+	if err != nil {
+		return err
+	}
+	return fn.Call(fm, args, opts)
+}
+
+// Evaluates the autoload file like a module, and returns the function it
+// defines. The file is only evaluated once.
+func autoload(fm *Frame, name, path string) (Callable, error) {
+	ns, ok := fm.Evaler.modules[path]
+	if !ok {
+		code, err := readFileUTF8(path)
+		if err != nil {
+			return nil, err
+		}
+		src := parse.Source{Name: path, Code: code, IsFile: true}
+		ns, err = evalModule(fm, path, src, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if v := ns.IndexString(name + FnSuffix); v != nil {
+		if fn, ok := v.Get().(Callable); ok {
+			return fn, nil
+		}
+	}
+	return nil, fmt.Errorf("autoload file %s doesn't define function %s", path, name)
+}
@@ -0,0 +1,47 @@
+package eval
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"src.elv.sh/pkg/must"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestAutoloadPath_CachesDirectories(t *testing.T) {
+	dir := testutil.TempDir(t)
+	ev := NewEvaler()
+	ev.AutoloadDirs = []string{dir}
+
+	if _, ok := ev.AutoloadPath("hello"); ok {
+		t.Errorf("found autoload file before it is created")
+	}
+	must.WriteFile(filepath.Join(dir, "hello.elv"), "fn hello { }")
+	// The directory is not checked again until the next Eval or Call.
+	if _, ok := ev.AutoloadPath("hello"); ok {
+		t.Errorf("found autoload file without invalidating the cache")
+	}
+	ev.autoloads.invalidate()
+	if path, ok := ev.AutoloadPath("hello"); path != filepath.Join(dir, "hello.elv") || !ok {
+		t.Errorf("AutoloadPath -> (%q, %v) after the file is created", path, ok)
+	}
+
+	must.OK(os.Remove(filepath.Join(dir, "hello.elv")))
+	ev.autoloads.invalidate()
+	if _, ok := ev.AutoloadPath("hello"); ok {
+		t.Errorf("found autoload file after it is removed")
+	}
+
+	// Directories are not required to exist, and are read once created.
+	ev.AutoloadDirs = []string{filepath.Join(dir, "new")}
+	if _, ok := ev.AutoloadPath("hello"); ok {
+		t.Errorf("found autoload file in nonexistent directory")
+	}
+	must.MkdirAll(filepath.Join(dir, "new"))
+	must.WriteFile(filepath.Join(dir, "new", "hello.elv"), "fn hello { }")
+	ev.autoloads.invalidate()
+	if _, ok := ev.AutoloadPath("hello"); !ok {
+		t.Errorf("didn't find autoload file in new directory")
+	}
+}
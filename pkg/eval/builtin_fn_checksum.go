@@ -0,0 +1,156 @@
+package eval
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/parse"
+)
+
+// The checksum command.
+
+func init() {
+	addBuiltinFns(map[string]any{
+		"checksum": checksum,
+	})
+}
+
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+type checksumOpts struct {
+	Algorithm string
+	Expected  string
+	Checksums string
+	Name      string
+}
+
+func (opts *checksumOpts) SetDefaultOptions() { opts.Algorithm = "sha256" }
+
+func checksum(fm *Frame, opts checksumOpts, args ...string) (vals.Map, error) {
+	newHash, ok := checksumAlgorithms[opts.Algorithm]
+	if !ok {
+		return nil, errs.BadValue{What: "&algorithm",
+			Valid: "md5, sha1, sha256 or sha512", Actual: parse.Quote(opts.Algorithm)}
+	}
+	if opts.Expected != "" && opts.Checksums != "" {
+		return nil, errs.BadValue{What: "options",
+			Valid: "at most one of &expected and &checksums", Actual: "both"}
+	}
+
+	var name string
+	var in io.Reader
+	switch len(args) {
+	case 0:
+		name, in = opts.Name, fm.InputFile()
+	case 1:
+		f, err := os.Open(args[0])
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		name, in = args[0], f
+		if opts.Name != "" {
+			name = opts.Name
+		}
+	default:
+		return nil, errs.ArityMismatch{What: "arguments",
+			ValidLow: 0, ValidHigh: 1, Actual: len(args)}
+	}
+
+	h := newHash()
+	if _, err := io.Copy(h, in); err != nil {
+		return nil, err
+	}
+	digest := hex.EncodeToString(h.Sum(nil))
+
+	expected := opts.Expected
+	if opts.Checksums != "" {
+		if name == "" {
+			return nil, errs.BadValue{What: "&name",
+				Valid: "non-empty when using &checksums with byte input", Actual: "empty"}
+		}
+		var err error
+		expected, err = lookupChecksum(opts.Checksums, opts.Algorithm, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := vals.MakeMap("algorithm", opts.Algorithm, "digest", digest)
+	if name != "" {
+		result = result.Assoc("name", name)
+	}
+	if expected != "" {
+		result = result.Assoc("expected", strings.ToLower(expected)).
+			Assoc("ok", strings.EqualFold(expected, digest))
+	}
+	return result, nil
+}
+
+// Looks up the checksum of a file in a checksums file, matching either the
+// whole name or its base name. Both the GNU format ("<digest>  <name>") and the
+// BSD format ("SHA256 (<name>) = <digest>") are supported.
+func lookupChecksum(path, algorithm, name string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	want := filepath.ToSlash(name)
+	wantBase := filepath.Base(name)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		digest, entry, ok := parseChecksumLine(sc.Text(), algorithm)
+		if !ok {
+			continue
+		}
+		entry = strings.TrimPrefix(entry, "./")
+		if entry == want || filepath.Base(entry) == wantBase {
+			return digest, nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no checksum for %s in %s", parse.Quote(name), parse.Quote(path))
+}
+
+func parseChecksumLine(line, algorithm string) (digest, name string, ok bool) {
+	line = strings.TrimRight(line, "\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", false
+	}
+	// BSD format.
+	if prefix := strings.ToUpper(algorithm) + " ("; strings.HasPrefix(line, prefix) {
+		i := strings.LastIndex(line, ") = ")
+		if i == -1 {
+			return "", "", false
+		}
+		return line[i+len(") = "):], line[len(prefix):i], true
+	}
+	// GNU format. The name is preceded by "*" in binary mode.
+	digest, name, ok = strings.Cut(line, " ")
+	if !ok {
+		return "", "", false
+	}
+	name = strings.TrimPrefix(strings.TrimPrefix(name, " "), "*")
+	return digest, name, true
+}
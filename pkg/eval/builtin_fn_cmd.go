@@ -1,16 +1,16 @@
 package eval
 
 import (
+	"fmt"
 	"os"
 	"os/exec"
 
 	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
 )
 
 // Command and process control.
 
-// TODO(xiaq): Document "fg".
-
 func init() {
 	addBuiltinFns(map[string]any{
 		// Command resolution
@@ -19,9 +19,15 @@ func init() {
 		"search-external": searchExternal,
 
 		// Process control
-		"fg":   fg,
-		"exec": execFn,
-		"exit": exit,
+		"jobs":   jobs,
+		"fg":     fg,
+		"bg":     bg,
+		"disown": disown,
+		"exec":   execFn,
+		"exit":   exit,
+
+		// Restricted mode
+		"restrict": restrict,
 	})
 }
 
@@ -38,6 +44,66 @@ func searchExternal(cmd string) (string, error) {
 	return exec.LookPath(cmd)
 }
 
+func jobs(fm *Frame) error {
+	out := fm.ValueOutput()
+	for _, info := range fm.Evaler.jobs.list() {
+		if err := out.Put(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fg(fm *Frame, ids ...int) error {
+	if len(ids) > 1 {
+		return errs.ArityMismatch{What: "arguments", ValidLow: 0, ValidHigh: 1, Actual: len(ids)}
+	}
+	jobs, err := fm.Evaler.jobs.find(ids, false)
+	if err != nil {
+		return err
+	}
+	j := jobs[0]
+	if j.pg != nil {
+		if pgid := j.pg.getPgid(); pgid != 0 {
+			if err := putInFg(pgid); err != nil {
+				return err
+			}
+			defer putSelfInFg()
+		}
+	}
+	<-fm.Evaler.resumeJob(j, false)
+	return fm.Evaler.jobs.lastErr(j)
+}
+
+func bg(fm *Frame, ids ...int) error {
+	jobs, err := fm.Evaler.jobs.find(ids, true)
+	if err != nil {
+		return err
+	}
+	for _, j := range jobs {
+		if !fm.Evaler.jobs.isStopped(j) {
+			return fmt.Errorf("job %d is already running", j.id)
+		}
+	}
+	for _, j := range jobs {
+		fm.Evaler.resumeJob(j, true)
+	}
+	return nil
+}
+
+func disown(fm *Frame, pids ...int) error {
+	if len(pids) == 0 {
+		fm.Evaler.children.disownAll()
+		return nil
+	}
+	for _, pid := range pids {
+		if !fm.Evaler.children.disown(pgidOf(pid)) {
+			return fmt.Errorf("no background job or stopped command with PID %d", pid)
+		}
+	}
+	return nil
+}
+
 // Can be overridden in tests.
 var osExit = os.Exit
 
@@ -55,3 +121,26 @@ func exit(fm *Frame, codes ...int) error {
 	osExit(code)
 	return nil
 }
+
+type restrictOpts struct {
+	Externals vals.List
+	Root      string
+	Writable  vals.List
+}
+
+func (opts *restrictOpts) SetDefaultOptions() {
+	opts.Externals = vals.EmptyList
+	opts.Writable = vals.EmptyList
+}
+
+func restrict(fm *Frame, opts restrictOpts) error {
+	var r Restrictions
+	if err := vals.ScanListToGo(opts.Externals, &r.Externals); err != nil {
+		return err
+	}
+	r.Root = opts.Root
+	if err := vals.ScanListToGo(opts.Writable, &r.Writable); err != nil {
+		return err
+	}
+	return fm.Evaler.Restrict(r)
+}
@@ -4,14 +4,17 @@ import (
 	"errors"
 	"math"
 	"math/big"
+	"strconv"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"golang.org/x/sync/semaphore"
 
 	"src.elv.sh/pkg/errutil"
 	"src.elv.sh/pkg/eval/errs"
 	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/parse"
 )
 
 // Flow control.
@@ -28,6 +31,7 @@ func init() {
 		"break":       breakFn,
 		"continue":    continueFn,
 		"defer":       deferFn,
+		"retry":       retry,
 		// Iterations.
 		"each":  each,
 		"peach": peach,
@@ -52,6 +56,97 @@ func runParallel(fm *Frame, functions ...Callable) error {
 	return MakePipelineError(exceptions)
 }
 
+type retryOpts struct {
+	N       int
+	Backoff string
+	Delay   any
+	If      Callable
+	OnRetry Callable
+}
+
+func (o *retryOpts) SetDefaultOptions() {
+	o.N = 5
+	o.Backoff = "exp"
+	o.Delay = "1s"
+}
+
+func retry(fm *Frame, opts retryOpts, f Callable) error {
+	if opts.N < 1 {
+		return errs.BadValue{What: "n option",
+			Valid: "positive integer", Actual: strconv.Itoa(opts.N)}
+	}
+	switch opts.Backoff {
+	case "constant", "linear", "exp":
+	default:
+		return errs.BadValue{What: "backoff option",
+			Valid: "constant, linear or exp", Actual: parse.Quote(opts.Backoff)}
+	}
+	delay, ok := ScanDuration(opts.Delay)
+	if !ok || delay < 0 {
+		return errs.BadValue{What: "delay option",
+			Valid: "non-negative number or duration string", Actual: vals.ReprPlain(opts.Delay)}
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := f.Call(fm.Fork(), NoArgs, NoOpts)
+		if err == nil || attempt == opts.N {
+			return err
+		}
+		if _, isFlow := Reason(err).(Flow); isFlow || Reason(err) == ErrInterrupted {
+			return err
+		}
+		if opts.If != nil {
+			shouldRetry, errIf := callRetryIf(fm, opts.If, err)
+			if errIf != nil {
+				return errIf
+			}
+			if !shouldRetry {
+				return err
+			}
+		}
+
+		d := delay
+		switch opts.Backoff {
+		case "linear":
+			d *= time.Duration(attempt)
+		case "exp":
+			d <<= attempt - 1
+		}
+		if opts.OnRetry != nil {
+			info := vals.MakeMap(
+				"attempt", attempt, "exception", err, "delay", d.Seconds())
+			if errCb := opts.OnRetry.Call(fm.Fork(), []any{info}, NoOpts); errCb != nil {
+				return errCb
+			}
+		}
+		select {
+		case <-fm.Context().Done():
+			return ErrInterrupted
+		case <-timeAfter(fm, d):
+		}
+	}
+}
+
+func callRetryIf(fm *Frame, pred Callable, exc error) (bool, error) {
+	outputs, err := fm.CaptureOutput(func(fm *Frame) error {
+		return pred.Call(fm, []any{exc}, NoOpts)
+	})
+	if err != nil {
+		return false, err
+	}
+	if len(outputs) != 1 {
+		return false, errs.ArityMismatch{
+			What:     "number of outputs of the &if callback",
+			ValidLow: 1, ValidHigh: 1, Actual: len(outputs)}
+	}
+	if b, ok := outputs[0].(bool); ok {
+		return b, nil
+	}
+	return false, errs.BadValue{
+		What:  "output of the &if callback",
+		Valid: "boolean", Actual: vals.Kind(outputs[0])}
+}
+
 func each(fm *Frame, f Callable, inputs Inputs) error {
 	broken := false
 	var err error
@@ -2,18 +2,21 @@ package eval
 
 import (
 	"bufio"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"math/big"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"src.elv.sh/pkg/diag"
 	"src.elv.sh/pkg/eval/errs"
 	"src.elv.sh/pkg/eval/vals"
 	"src.elv.sh/pkg/parse"
 	"src.elv.sh/pkg/strutil"
+	"src.elv.sh/pkg/wcwidth"
 )
 
 // Input and output.
@@ -23,6 +26,7 @@ func init() {
 		// Value output
 		"put":    put,
 		"repeat": repeat,
+		"format": format,
 
 		// Bytes input
 		"read-bytes": readBytes,
@@ -50,11 +54,19 @@ func init() {
 		"slurp":           slurp,
 		"from-lines":      fromLines,
 		"from-json":       fromJSON,
+		"from-cbor":       fromCBOR,
+		"from-yaml":       fromYAML,
+		"from-toml":       fromTOML,
+		"from-csv":        fromCSV,
 		"from-terminated": fromTerminated,
 
 		// Value to bytes
 		"to-lines":      toLines,
 		"to-json":       toJSON,
+		"to-cbor":       toCBOR,
+		"to-yaml":       toYAML,
+		"to-toml":       toTOML,
+		"to-csv":        toCSV,
 		"to-terminated": toTerminated,
 	})
 }
@@ -158,13 +170,20 @@ func print(fm *Frame, opts printOpts, args ...any) error {
 }
 
 func printf(fm *Frame, template string, args ...any) error {
+	_, err := fmt.Fprintf(fm.ByteOutput(), template, wrapFormatArgs(args)...)
+	return err
+}
+
+func format(template string, args ...any) string {
+	return fmt.Sprintf(template, wrapFormatArgs(args)...)
+}
+
+func wrapFormatArgs(args []any) []any {
 	wrappedArgs := make([]any, len(args))
 	for i, arg := range args {
 		wrappedArgs[i] = formatter{arg}
 	}
-
-	_, err := fmt.Fprintf(fm.ByteOutput(), template, wrappedArgs...)
-	return err
+	return wrappedArgs
 }
 
 type formatter struct {
@@ -175,10 +194,10 @@ func (f formatter) Format(state fmt.State, r rune) {
 	wrapped := f.wrapped
 	switch r {
 	case 's':
-		writeFmt(state, 's', vals.ToString(wrapped))
+		writeStringFmt(state, vals.ToString(wrapped))
 	case 'q':
 		// TODO: Support using the precision flag to specify indentation.
-		writeFmt(state, 's', vals.ReprPlain(wrapped))
+		writeStringFmt(state, vals.ReprPlain(wrapped))
 	case 'v':
 		var s string
 		if state.Flag('#') {
@@ -186,7 +205,14 @@ func (f formatter) Format(state fmt.State, r rune) {
 		} else {
 			s = vals.ToString(wrapped)
 		}
-		writeFmt(state, 's', s)
+		writeStringFmt(state, s)
+	case 'j':
+		bs, err := json.Marshal(wrapped)
+		if err != nil {
+			fmt.Fprintf(state, "%%!%c(%s)", r, err.Error())
+			return
+		}
+		writeStringFmt(state, string(bs))
 	case 't':
 		writeFmt(state, 't', vals.Bool(wrapped))
 	case 'b', 'c', 'd', 'o', 'O', 'x', 'X', 'U':
@@ -208,6 +234,28 @@ func (f formatter) Format(state fmt.State, r rune) {
 	}
 }
 
+// Writes a string to State, using the width and precision it stores as column
+// widths on the terminal rather than numbers of codepoints, so that strings
+// containing wide characters can be aligned.
+func writeStringFmt(state fmt.State, s string) {
+	if p, ok := state.Precision(); ok {
+		s = wcwidth.Trim(s, p)
+	}
+	if w, ok := state.Width(); ok {
+		if pad := w - wcwidth.Of(s); pad > 0 {
+			switch {
+			case state.Flag('-'):
+				s += strings.Repeat(" ", pad)
+			case state.Flag('0'):
+				s = strings.Repeat("0", pad) + s
+			default:
+				s = strings.Repeat(" ", pad) + s
+			}
+		}
+	}
+	state.Write([]byte(s))
+}
+
 // Writes to State using the flag it stores, but with a potentially different
 // verb and value.
 func writeFmt(state fmt.State, v rune, val any) {
@@ -349,74 +397,216 @@ func fromLines(fm *Frame) error {
 	}
 }
 
-func fromJSON(fm *Frame) error {
+type fromJSONOpts struct {
+	Stream  bool
+	Ordered bool
+	Strict  bool
+}
+
+func (*fromJSONOpts) SetDefaultOptions() {}
+
+var errTrailingJSON = errors.New("unexpected data after JSON value")
+
+func fromJSON(fm *Frame, opts fromJSONOpts) error {
 	in := fm.InputFile()
 	out := fm.ValueOutput()
 
 	dec := json.NewDecoder(in)
-	// See comments below about using json.Number.
+	// See the comments in vals.FromJSON about using json.Number.
 	dec.UseNumber()
 	for {
-		var v any
-		err := dec.Decode(&v)
+		var converted any
+		if opts.Stream || opts.Ordered {
+			tok, err := dec.Token()
+			if err != nil {
+				return eofOK(err, opts.Strict)
+			}
+			if opts.Stream && tok == json.Delim('[') {
+				// Output the elements as they are decoded.
+				for dec.More() {
+					tok, err := dec.Token()
+					if err != nil {
+						return err
+					}
+					elem, err := vals.DecodeJSON(dec, tok, opts.Ordered)
+					if err != nil {
+						return err
+					}
+					if err := out.Put(elem); err != nil {
+						return err
+					}
+				}
+				if _, err := dec.Token(); err != nil {
+					return eofOK(err, true)
+				}
+				if opts.Strict {
+					return checkNoMoreJSON(dec)
+				}
+				continue
+			}
+			converted, err = vals.DecodeJSON(dec, tok, opts.Ordered)
+			if err != nil {
+				return err
+			}
+		} else {
+			var v any
+			err := dec.Decode(&v)
+			if err != nil {
+				return eofOK(err, opts.Strict)
+			}
+			converted, err = vals.FromJSON(v)
+			if err != nil {
+				return err
+			}
+		}
+		if opts.Strict {
+			if err := checkNoMoreJSON(dec); err != nil {
+				return err
+			}
+		}
+		err := out.Put(converted)
+		if err != nil || opts.Strict {
+			return err
+		}
+	}
+}
+
+// Returns nil if err is io.EOF, unless the EOF is unexpected, in which case
+// io.ErrUnexpectedEOF is returned.
+func eofOK(err error, unexpected bool) error {
+	if err == io.EOF {
+		if unexpected {
+			return io.ErrUnexpectedEOF
+		}
+		return nil
+	}
+	return err
+}
+
+// Checks that there is nothing other than whitespace left in the input.
+func checkNoMoreJSON(dec *json.Decoder) error {
+	if _, err := dec.Token(); err != io.EOF {
+		return errTrailingJSON
+	}
+	return nil
+}
+
+func fromCBOR(fm *Frame) error {
+	dec := vals.NewCBORDecoder(fm.InputFile())
+	out := fm.ValueOutput()
+	for {
+		v, err := dec.Decode()
 		if err != nil {
 			if err == io.EOF {
 				return nil
 			}
 			return err
 		}
-		converted, err := fromJSONInterface(v)
+		err = out.Put(v)
 		if err != nil {
 			return err
 		}
-		err = out.Put(converted)
+	}
+}
+
+func fromYAML(fm *Frame) error {
+	dec := vals.NewYAMLDecoder(fm.InputFile())
+	out := fm.ValueOutput()
+	for {
+		v, err := dec.Decode()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		err = out.Put(v)
 		if err != nil {
 			return err
 		}
 	}
 }
 
-// Converts a interface{} that results from json.Unmarshal to an Elvish value.
-func fromJSONInterface(v any) (any, error) {
-	switch v := v.(type) {
-	case nil, bool, string:
-		return v, nil
-	case json.Number:
-		// The JSON syntax doesn't restrict the precision of numbers. Since
-		// we called json.Decoder.UseNumber, it preserves the full number
-		// literal, and we can try parsing it as a big int.
-		if z, ok := new(big.Int).SetString(v.String(), 0); ok {
-			// Also normalize to int if the value fits.
-			return vals.NormalizeBigInt(z), nil
-		}
-		// Parse as float64 instead. This can error if the number is not an
-		// integer and exceeds the range of float64.
-		return strconv.ParseFloat(v.String(), 64)
-	case float64:
-		return v, nil
-	case []any:
-		vec := vals.EmptyList
-		for _, elem := range v {
-			converted, err := fromJSONInterface(elem)
-			if err != nil {
-				return nil, err
+func fromTOML(fm *Frame) error {
+	v, err := vals.DecodeTOML(fm.InputFile())
+	if err != nil {
+		return err
+	}
+	return fm.ValueOutput().Put(v)
+}
+
+type fromCSVOpts struct {
+	Delimiter string
+	Header    bool
+}
+
+func (opts *fromCSVOpts) SetDefaultOptions() {
+	opts.Delimiter = ","
+	opts.Header = true
+}
+
+func fromCSV(fm *Frame, opts fromCSVOpts) error {
+	delim, err := csvDelimiter(opts.Delimiter)
+	if err != nil {
+		return err
+	}
+	r := csv.NewReader(fm.InputFile())
+	r.Comma = delim
+	out := fm.ValueOutput()
+	var header []string
+	for first := true; ; first = false {
+		record, err := r.Read()
+		if err != nil {
+			if err == io.EOF {
+				return nil
 			}
-			vec = vec.Conj(converted)
+			return err
 		}
-		return vec, nil
-	case map[string]any:
-		m := vals.EmptyMap
-		for key, val := range v {
-			convertedVal, err := fromJSONInterface(val)
-			if err != nil {
-				return nil, err
+		if first {
+			// Files written by spreadsheet programs often start with a BOM.
+			record[0] = strings.TrimPrefix(record[0], "\ufeff")
+			if opts.Header {
+				seen := make(map[string]bool)
+				for _, name := range record {
+					if seen[name] {
+						return fmt.Errorf("duplicate column %s in CSV header", parse.Quote(name))
+					}
+					seen[name] = true
+				}
+				header = record
+				continue
 			}
-			m = m.Assoc(key, convertedVal)
 		}
-		return m, nil
-	default:
-		return nil, fmt.Errorf("unexpected json type: %T", v)
+		var v any
+		if opts.Header {
+			// The reader ensures that all records have the same number of
+			// fields as the header.
+			m := vals.EmptyOrderedMap
+			for i, field := range record {
+				m = m.Assoc(header[i], field)
+			}
+			v = m
+		} else {
+			l := vals.EmptyList
+			for _, field := range record {
+				l = l.Conj(field)
+			}
+			v = l
+		}
+		err = out.Put(v)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func csvDelimiter(s string) (rune, error) {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 || size != len(s) || r == utf8.RuneError || r == '"' || r == '\r' || r == '\n' {
+		return 0, errs.BadValue{What: "delimiter",
+			Valid: "a single character other than a double quote or newline", Actual: parse.Quote(s)}
 	}
+	return r, nil
 }
 
 func fromTerminated(fm *Frame, terminator string) error {
@@ -474,8 +664,15 @@ func toTerminated(fm *Frame, terminator string, inputs Inputs) error {
 	return errOut
 }
 
-func toJSON(fm *Frame, inputs Inputs) error {
+type toJSONOpts struct{ Indent string }
+
+func (*toJSONOpts) SetDefaultOptions() {}
+
+func toJSON(fm *Frame, opts toJSONOpts, inputs Inputs) error {
 	encoder := json.NewEncoder(fm.ByteOutput())
+	if opts.Indent != "" {
+		encoder.SetIndent("", opts.Indent)
+	}
 
 	var errEncode error
 	inputs(func(v any) {
@@ -486,3 +683,187 @@ func toJSON(fm *Frame, inputs Inputs) error {
 	})
 	return errEncode
 }
+
+func toCBOR(fm *Frame, inputs Inputs) error {
+	out := fm.ByteOutput()
+	var buf []byte
+	var errEncode error
+	inputs(func(v any) {
+		if errEncode != nil {
+			return
+		}
+		buf, errEncode = vals.AppendCBOR(buf[:0], v)
+		if errEncode == nil {
+			_, errEncode = out.Write(buf)
+		}
+	})
+	return errEncode
+}
+
+func toYAML(fm *Frame, inputs Inputs) error {
+	out := fm.ByteOutput()
+	var buf []byte
+	var errEncode error
+	first := true
+	inputs(func(v any) {
+		if errEncode != nil {
+			return
+		}
+		buf = buf[:0]
+		if !first {
+			// Separate documents.
+			buf = append(buf, "---\n"...)
+		}
+		first = false
+		buf, errEncode = vals.AppendYAML(buf, v)
+		if errEncode == nil {
+			_, errEncode = out.Write(buf)
+		}
+	})
+	return errEncode
+}
+
+func toTOML(fm *Frame, inputs Inputs) error {
+	out := fm.ByteOutput()
+	var buf []byte
+	var errEncode error
+	inputs(func(v any) {
+		if errEncode != nil {
+			return
+		}
+		buf, errEncode = vals.AppendTOML(buf[:0], v)
+		if errEncode == nil {
+			_, errEncode = out.Write(buf)
+		}
+	})
+	return errEncode
+}
+
+type toCSVOpts struct {
+	Delimiter string
+	Header    bool
+	Columns   vals.List
+}
+
+func (opts *toCSVOpts) SetDefaultOptions() {
+	opts.Delimiter = ","
+	opts.Header = true
+}
+
+func toCSV(fm *Frame, opts toCSVOpts, inputs Inputs) error {
+	delim, err := csvDelimiter(opts.Delimiter)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(fm.ByteOutput())
+	w.Comma = delim
+
+	var columns []any
+	// Names of the columns, used to check that maps don't have any other key
+	// when the columns are not specified explicitly.
+	var columnNames map[string]bool
+	hasColumns := opts.Columns != nil
+	if hasColumns {
+		for it := opts.Columns.Iterator(); it.HasElem(); it.Next() {
+			columns = append(columns, it.Elem())
+		}
+	}
+	wroteHeader := false
+
+	var errEncode error
+	inputs(func(v any) {
+		if errEncode != nil {
+			return
+		}
+		var record []string
+		switch vals.Kind(v) {
+		case "list":
+			record, errEncode = csvListRecord(v)
+		case "map":
+			if !hasColumns {
+				columns, errEncode = vals.EncodingKeys(v)
+				if errEncode != nil {
+					return
+				}
+				columnNames = make(map[string]bool)
+				for _, column := range columns {
+					columnNames[vals.ToString(column)] = true
+				}
+				hasColumns = true
+			}
+			if opts.Header && !wroteHeader {
+				header := make([]string, len(columns))
+				for i, column := range columns {
+					header[i] = vals.ToString(column)
+				}
+				errEncode = w.Write(header)
+				if errEncode != nil {
+					return
+				}
+				wroteHeader = true
+			}
+			record, errEncode = csvMapRecord(v, columns, columnNames)
+		default:
+			errEncode = fmt.Errorf("input to to-csv must be list or map, got %s", vals.Kind(v))
+		}
+		if errEncode != nil {
+			return
+		}
+		errEncode = w.Write(record)
+		if errEncode == nil {
+			w.Flush()
+			errEncode = w.Error()
+		}
+	})
+	return errEncode
+}
+
+func csvListRecord(v any) ([]string, error) {
+	var record []string
+	var errField error
+	err := vals.Iterate(v, func(elem any) bool {
+		var field string
+		field, errField = csvField(elem)
+		record = append(record, field)
+		return errField == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, errField
+}
+
+func csvMapRecord(v any, columns []any, columnNames map[string]bool) ([]string, error) {
+	if columnNames != nil {
+		var errKey error
+		vals.IterateKeys(v, func(k any) bool {
+			if !columnNames[vals.ToString(k)] {
+				errKey = fmt.Errorf("map has key %s not in the CSV columns", vals.ReprPlain(k))
+			}
+			return errKey == nil
+		})
+		if errKey != nil {
+			return nil, errKey
+		}
+	}
+	record := make([]string, len(columns))
+	for i, column := range columns {
+		// Missing keys are written as empty fields.
+		if elem, err := vals.Index(v, column); err == nil {
+			record[i], err = csvField(elem)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return record, nil
+}
+
+func csvField(v any) (string, error) {
+	switch vals.Kind(v) {
+	case "string", "number":
+		return vals.ToString(v), nil
+	default:
+		return "", fmt.Errorf("cannot write value of kind %s to CSV", vals.Kind(v))
+	}
+}
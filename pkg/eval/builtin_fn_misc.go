@@ -6,7 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sort"
 	"sync"
+	"time"
 
 	"src.elv.sh/pkg/diag"
 	"src.elv.sh/pkg/eval/errs"
@@ -27,10 +29,13 @@ func init() {
 		"constantly": constantly,
 
 		// Introspection
-		"call":    call,
-		"resolve": resolve,
-		"eval":    eval,
-		"use-mod": useMod,
+		"call":           call,
+		"resolve":        resolve,
+		"eval":           eval,
+		"eval-in":        evalIn,
+		"use-mod":        useMod,
+		"reexport":       reexport,
+		"reload-modules": reloadModules,
 
 		"deprecate": deprecate,
 
@@ -129,6 +134,36 @@ func eval(fm *Frame, opts evalOpts, code string) error {
 	return exc
 }
 
+type evalInOpts struct {
+	OnEnd Callable
+}
+
+func (*evalInOpts) SetDefaultOptions() {}
+
+func evalIn(fm *Frame, opts evalInOpts, ns *Ns, code string) error {
+	src := parse.Source{Name: fmt.Sprintf("[eval-in %d]", nextEvalCount()), Code: code}
+	tree, err := parse.Parse(src, parse.Config{WarningWriter: fm.ErrorFile()})
+	if err != nil {
+		return err
+	}
+	op, err := compileSandboxed(ns.static(), tree, fm.ErrorFile())
+	if err != nil {
+		return err
+	}
+	newFm := &Frame{
+		fm.Evaler, fm.ctx, fm.ports, fm.traceback, fm.background, fm.jobControl, fm.pgroup,
+		src, ns, new(Ns), nil, nil}
+	newNs, exec := op.prepare(newFm)
+	exc := exec()
+	if opts.OnEnd != nil {
+		errCb := opts.OnEnd.Call(fm.Fork(), []any{newNs}, NoOpts)
+		if exc == nil {
+			return errCb
+		}
+	}
+	return exc
+}
+
 // Used to generate unique names for each source passed to eval.
 var (
 	evalCount      int
@@ -147,6 +182,75 @@ func useMod(fm *Frame, spec string) (*Ns, error) {
 	return use(fm, spec, nil)
 }
 
+var errReexportOutsideModule = errors.New("reexport can only be used when a module is being loaded")
+
+func reexport(fm *Frame, ns *Ns) error {
+	if fm.reexports == nil {
+		return errReexportOutsideModule
+	}
+	*fm.reexports = append(*fm.reexports, ns)
+	return nil
+}
+
+type reloadModulesOpts struct{ All bool }
+
+func (*reloadModulesOpts) SetDefaultOptions() {}
+
+func reloadModules(fm *Frame, opts reloadModulesOpts) error {
+	ev := fm.Evaler
+	paths := make([]string, 0, len(ev.moduleModTimes))
+	for path := range ev.moduleModTimes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	out := fm.ValueOutput()
+	for _, path := range paths {
+		oldModTime := ev.moduleModTimes[path]
+		newModTime := modTime(path + ".elv")
+		if !opts.All && newModTime.Equal(oldModTime) {
+			continue
+		}
+		err := reloadModule(fm, path, newModTime)
+		if err != nil {
+			return err
+		}
+		err = out.Put(path + ".elv")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Re-evaluates the module with the given key and replaces the content of its
+// namespace in place, so that all existing references to the namespace see
+// the new content.
+func reloadModule(fm *Frame, path string, newModTime time.Time) error {
+	ev := fm.Evaler
+	oldNs := ev.modules[path]
+	code, err := readFileUTF8(path + ".elv")
+	if err != nil {
+		return err
+	}
+	src := parse.Source{Name: path + ".elv", Code: code, IsFile: true}
+	newNs, err := evalModule(fm, path, src, nil)
+	if err != nil {
+		// Keep the old module.
+		ev.modules[path] = oldNs
+		return err
+	}
+	ev.moduleModTimes[path] = newModTime
+	oldContent := *oldNs
+	*oldNs = *newNs
+	ev.modules[path] = oldNs
+	if v := oldNs.IndexString("on-reload" + FnSuffix); v != nil {
+		if hook, ok := v.Get().(Callable); ok {
+			return hook.Call(fm.Fork(), []any{&oldContent}, NoOpts)
+		}
+	}
+	return nil
+}
+
 func deprecate(fm *Frame, msg string) {
 	var ctx *diag.Context
 	if fm.traceback.Next != nil {
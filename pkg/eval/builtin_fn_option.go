@@ -0,0 +1,78 @@
+package eval
+
+import (
+	"fmt"
+
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/parse"
+)
+
+// Builtins for options.
+
+func init() {
+	addBuiltinFns(map[string]any{
+		"option": option,
+	})
+}
+
+type optionOpts struct{ Dump bool }
+
+func (*optionOpts) SetDefaultOptions() {}
+
+func option(fm *Frame, opts optionOpts, args ...any) error {
+	if opts.Dump {
+		if len(args) > 0 {
+			return errs.ArityMismatch{What: "arguments", ValidLow: 0, ValidHigh: 0, Actual: len(args)}
+		}
+		return dumpOptions(fm)
+	}
+	switch len(args) {
+	case 0:
+		out := fm.ValueOutput()
+		for _, opt := range fm.Evaler.allOptions() {
+			err := out.Put(vals.MakeMap(
+				"name", opt.spec.Name,
+				"kind", opt.kind(),
+				"default", opt.spec.Default,
+				"value", opt.v.Get()))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case 1, 2:
+		name, ok := args[0].(string)
+		if !ok {
+			return errs.BadValue{What: "option name",
+				Valid: "string", Actual: vals.Kind(args[0])}
+		}
+		if len(args) == 2 {
+			return fm.Evaler.SetOption(name, args[1])
+		}
+		v, err := fm.Evaler.Option(name)
+		if err != nil {
+			return err
+		}
+		return fm.ValueOutput().Put(v)
+	default:
+		return errs.ArityMismatch{What: "arguments", ValidLow: 0, ValidHigh: 2, Actual: len(args)}
+	}
+}
+
+// Writes code that sets all the options that differ from their defaults.
+func dumpOptions(fm *Frame) error {
+	out := fm.ByteOutput()
+	for _, opt := range fm.Evaler.allOptions() {
+		v := opt.v.Get()
+		if vals.Equal(v, opt.spec.Default) {
+			continue
+		}
+		_, err := fmt.Fprintf(out, "option %s %s\n",
+			parse.Quote(opt.spec.Name), vals.ReprPlain(v))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,6 +1,9 @@
 package eval
 
 import (
+	"unicode"
+	"unicode/utf8"
+
 	"src.elv.sh/pkg/eval/errs"
 	"src.elv.sh/pkg/eval/vals"
 )
@@ -51,18 +54,15 @@ var ErrUncomparable = errs.BadValue{
 	Valid: "comparable values", Actual: "uncomparable values"}
 
 type compareOptions struct {
-	Total bool
+	Total    bool
+	Natural  bool
+	FoldCase bool
 }
 
 func (opts *compareOptions) SetDefaultOptions() {}
 
 func compare(opts compareOptions, a, b any) (int, error) {
-	var o vals.Ordering
-	if opts.Total {
-		o = vals.CmpTotal(a, b)
-	} else {
-		o = vals.Cmp(a, b)
-	}
+	o := collation{opts.Natural, opts.FoldCase}.cmp(a, b, opts.Total)
 	switch o {
 	case vals.CmpLess:
 		return -1, nil
@@ -74,3 +74,102 @@ func compare(opts compareOptions, a, b any) (int, error) {
 		return 0, ErrUncomparable
 	}
 }
+
+// Options that affect how strings are compared by compare and order.
+type collation struct {
+	// Compare runs of digits by their numeric values.
+	natural bool
+	// Compare letters case-insensitively.
+	foldCase bool
+}
+
+// Compares two values, using c when both are strings, and vals.CmpTotal or
+// vals.Cmp otherwise.
+func (c collation) cmp(a, b any, total bool) vals.Ordering {
+	if c.natural || c.foldCase {
+		if sa, ok := a.(string); ok {
+			if sb, ok := b.(string); ok {
+				return c.cmpStrings(sa, sb)
+			}
+		}
+	}
+	if total {
+		return vals.CmpTotal(a, b)
+	}
+	return vals.Cmp(a, b)
+}
+
+func (c collation) cmpStrings(a, b string) vals.Ordering {
+	if o := c.cmpStringsInner(a, b); o != vals.CmpEqual {
+		return o
+	}
+	// Break ties with the normal order, so that only identical strings are
+	// considered equal.
+	return vals.Cmp(a, b)
+}
+
+func (c collation) cmpStringsInner(a, b string) vals.Ordering {
+	for a != "" && b != "" {
+		if c.natural && isASCIIDigit(a[0]) && isASCIIDigit(b[0]) {
+			na, nb := digitsPrefixLen(a), digitsPrefixLen(b)
+			if o := cmpDigits(a[:na], b[:nb]); o != vals.CmpEqual {
+				return o
+			}
+			a, b = a[na:], b[nb:]
+			continue
+		}
+		ra, na := utf8.DecodeRuneInString(a)
+		rb, nb := utf8.DecodeRuneInString(b)
+		if c.foldCase {
+			ra, rb = unicode.ToLower(ra), unicode.ToLower(rb)
+		}
+		if ra != rb {
+			if ra < rb {
+				return vals.CmpLess
+			}
+			return vals.CmpMore
+		}
+		a, b = a[na:], b[nb:]
+	}
+	switch {
+	case a == "" && b == "":
+		return vals.CmpEqual
+	case a == "":
+		return vals.CmpLess
+	default:
+		return vals.CmpMore
+	}
+}
+
+func isASCIIDigit(b byte) bool { return '0' <= b && b <= '9' }
+
+func digitsPrefixLen(s string) int {
+	i := 0
+	for i < len(s) && isASCIIDigit(s[i]) {
+		i++
+	}
+	return i
+}
+
+// Compares two non-empty strings of digits by their numeric values.
+func cmpDigits(a, b string) vals.Ordering {
+	a, b = trimLeadingZeros(a), trimLeadingZeros(b)
+	switch {
+	case len(a) < len(b):
+		return vals.CmpLess
+	case len(a) > len(b):
+		return vals.CmpMore
+	case a < b:
+		return vals.CmpLess
+	case a > b:
+		return vals.CmpMore
+	}
+	return vals.CmpEqual
+}
+
+func trimLeadingZeros(s string) string {
+	for len(s) > 1 && s[0] == '0' {
+		s = s[1:]
+	}
+	return s
+}
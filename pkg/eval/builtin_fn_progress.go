@@ -0,0 +1,183 @@
+package eval
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/sys"
+	"src.elv.sh/pkg/wcwidth"
+)
+
+// The progress command.
+
+func init() {
+	addBuiltinFns(map[string]any{
+		"progress": progress,
+	})
+}
+
+const (
+	// Minimum interval between two redraws of the progress bar.
+	progressInterval = 100 * time.Millisecond
+	// Width to assume when the width of the terminal can't be determined.
+	progressDefaultWidth = 80
+)
+
+type progressOpts struct {
+	Total int
+	Bytes bool
+	Label string
+}
+
+func (*progressOpts) SetDefaultOptions() {}
+
+func progress(fm *Frame, opts progressOpts, inputs Inputs) error {
+	if opts.Total < 0 {
+		return errs.BadValue{What: "&total",
+			Valid: "non-negative", Actual: fmt.Sprint(opts.Total)}
+	}
+
+	var bar *progressBar
+	if errFile := fm.ErrorFile(); sys.IsATTY(errFile.Fd()) {
+		_, width := sys.WinSize(errFile)
+		if width <= 0 {
+			width = progressDefaultWidth
+		}
+		bar = newProgressBar(errFile, opts, width)
+		defer bar.clear()
+	}
+
+	if opts.Bytes {
+		var w io.Writer = fm.ByteOutput()
+		if bar != nil {
+			w = progressWriter{w, bar}
+		}
+		_, err := io.Copy(w, fm.InputFile())
+		return err
+	}
+
+	out := fm.ValueOutput()
+	var errOut error
+	inputs(func(v any) {
+		if errOut != nil {
+			return
+		}
+		errOut = out.Put(v)
+		if errOut == nil && bar != nil {
+			bar.add(1)
+		}
+	})
+	return errOut
+}
+
+type progressWriter struct {
+	w   io.Writer
+	bar *progressBar
+}
+
+func (pw progressWriter) Write(p []byte) (int, error) {
+	n, err := pw.w.Write(p)
+	pw.bar.add(n)
+	return n, err
+}
+
+var progressSpinner = []string{"-", "\\", "|", "/"}
+
+type progressBar struct {
+	w     io.Writer
+	opts  progressOpts
+	width int
+
+	start    time.Time
+	lastDraw time.Time
+	n        int
+	frame    int
+}
+
+func newProgressBar(w io.Writer, opts progressOpts, width int) *progressBar {
+	return &progressBar{w: w, opts: opts, width: width, start: timeNow()}
+}
+
+// Adds n to the count, and redraws the progress bar if enough time has passed
+// since the last redraw.
+func (b *progressBar) add(n int) {
+	b.n += n
+	now := timeNow()
+	if b.frame > 0 && now.Sub(b.lastDraw) < progressInterval {
+		return
+	}
+	b.lastDraw = now
+	// Draw the line, and clear anything left over from the previous one.
+	fmt.Fprint(b.w, "\r"+b.line(now)+"\033[K")
+	b.frame++
+}
+
+// Clears the progress bar if it has been drawn.
+func (b *progressBar) clear() {
+	if b.frame > 0 {
+		fmt.Fprint(b.w, "\r\033[K")
+	}
+}
+
+// Returns the content of the progress bar, which is never wider than the
+// terminal minus one column, to avoid wrapping.
+func (b *progressBar) line(now time.Time) string {
+	var sb strings.Builder
+	if b.opts.Label != "" {
+		sb.WriteString(b.opts.Label + " ")
+	}
+	elapsed := now.Sub(b.start)
+	if b.opts.Total == 0 {
+		fmt.Fprintf(&sb, "%s %s %s", progressSpinner[b.frame%len(progressSpinner)],
+			b.count(b.n), formatProgressDuration(elapsed))
+		return wcwidth.Trim(sb.String(), b.width-1)
+	}
+
+	done := min(b.n, b.opts.Total)
+	eta := "?"
+	if done > 0 {
+		remaining := time.Duration(float64(elapsed) * float64(b.opts.Total-done) / float64(done))
+		eta = formatProgressDuration(remaining)
+	}
+	suffix := fmt.Sprintf("%s/%s %3d%% ETA %s",
+		b.count(b.n), b.count(b.opts.Total), done*100/b.opts.Total, eta)
+
+	// The bar takes up all the remaining width, excluding the two brackets
+	// and the space after it.
+	if barWidth := b.width - 1 - wcwidth.Of(sb.String()) - len(suffix) - 3; barWidth > 0 {
+		filled := barWidth * done / b.opts.Total
+		sb.WriteString("[" + strings.Repeat("=", filled) +
+			strings.Repeat(" ", barWidth-filled) + "] ")
+	}
+	sb.WriteString(suffix)
+	return wcwidth.Trim(sb.String(), b.width-1)
+}
+
+func (b *progressBar) count(n int) string {
+	if b.opts.Bytes {
+		return formatByteSize(n)
+	}
+	return fmt.Sprint(n)
+}
+
+func formatProgressDuration(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
+
+// Formats a byte size using binary units, like "1.5 KiB".
+func formatByteSize(n int) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	size := float64(n) / 1024
+	i := 0
+	for size >= 1024 && i < len(units)-1 {
+		size /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f %ciB", size, units[i])
+}
@@ -0,0 +1,84 @@
+package eval
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestProgressBar_Line(t *testing.T) {
+	t0 := time.Unix(0, 0)
+	tests := []struct {
+		name    string
+		opts    progressOpts
+		width   int
+		n       int
+		elapsed time.Duration
+		want    string
+	}{
+		{"total", progressOpts{Total: 100}, 40, 25, 10 * time.Second,
+			"[====             ] 25/100  25% ETA 30s"},
+		{"no progress yet", progressOpts{Total: 10}, 30, 0, time.Second,
+			"[           ] 0/10   0% ETA ?"},
+		{"over total", progressOpts{Total: 10}, 30, 12, time.Second,
+			"[=========] 12/10 100% ETA 0s"},
+		{"label", progressOpts{Total: 2, Label: "foo"}, 30, 1, time.Second,
+			"foo [===    ] 1/2  50% ETA 1s"},
+		{"no room for bar", progressOpts{Total: 2, Label: "foo"}, 20, 1, time.Second,
+			"foo 1/2  50% ETA 1s"},
+		{"no total", progressOpts{}, 80, 42, 3 * time.Second,
+			"- 42 3s"},
+		{"bytes", progressOpts{Bytes: true}, 80, 1536, time.Minute,
+			"- 1.5 KiB 1m0s"},
+		{"truncated", progressOpts{Label: "a very long label"}, 10, 1, 0,
+			"a very lo"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := &progressBar{opts: test.opts, width: test.width, start: t0, n: test.n}
+			got := b.line(t0.Add(test.elapsed))
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestProgressBar_RedrawsAtIntervalAndClears(t *testing.T) {
+	now := time.Unix(0, 0)
+	testutil.Set(t, &timeNow, func() time.Time { return now })
+	var sb strings.Builder
+	b := newProgressBar(&sb, progressOpts{}, 80)
+
+	b.add(1)
+	now = now.Add(progressInterval / 2)
+	b.add(1)
+	now = now.Add(progressInterval)
+	b.add(1)
+	b.clear()
+
+	want := "\r- 1 0s\033[K" + "\r\\ 3 0s\033[K" + "\r\033[K"
+	if got := sb.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFormatByteSize(t *testing.T) {
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{3 << 20, "3.0 MiB"},
+		{5 << 30, "5.0 GiB"},
+	}
+	for _, test := range tests {
+		if got := formatByteSize(test.n); got != test.want {
+			t.Errorf("formatByteSize(%d) = %q, want %q", test.n, got, test.want)
+		}
+	}
+}
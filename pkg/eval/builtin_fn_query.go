@@ -0,0 +1,674 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/parse"
+)
+
+// The query command, which evaluates a small jq-like language over values.
+
+func init() {
+	addBuiltinFns(map[string]any{
+		"query": query,
+	})
+}
+
+// Unlike most commands that take inputs, query treats each argument as one
+// input instead of iterating over a single argument, since the inputs are
+// typically containers.
+func query(fm *Frame, expr string, values ...any) error {
+	q, err := parseQuery(expr)
+	if err != nil {
+		return err
+	}
+	out := fm.ValueOutput()
+	if len(values) > 0 {
+		for _, v := range values {
+			if err := q.eval(v, out.Put); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var errQuery error
+	fm.IterateInputs(func(v any) {
+		if errQuery != nil {
+			return
+		}
+		errQuery = q.eval(v, out.Put)
+	})
+	return errQuery
+}
+
+// A node in the AST of a query. Evaluating a node against a value passes zero
+// or more values to out.
+type queryNode interface {
+	eval(v any, out func(any) error) error
+}
+
+type queryIdentity struct{}
+
+func (queryIdentity) eval(v any, out func(any) error) error { return out(v) }
+
+type queryLiteral struct{ value any }
+
+func (q queryLiteral) eval(_ any, out func(any) error) error { return out(q.value) }
+
+// Feeds the outputs of left to right.
+type queryPipe struct{ left, right queryNode }
+
+func (q queryPipe) eval(v any, out func(any) error) error {
+	return q.left.eval(v, func(v any) error { return q.right.eval(v, out) })
+}
+
+// Outputs the outputs of all the nodes in turn.
+type queryComma struct{ nodes []queryNode }
+
+func (q queryComma) eval(v any, out func(any) error) error {
+	for _, node := range q.nodes {
+		if err := node.eval(v, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Indexes the outputs of base with a key.
+type queryIndex struct {
+	base queryNode
+	key  string
+}
+
+func (q queryIndex) eval(v any, out func(any) error) error {
+	return q.base.eval(v, func(v any) error {
+		if v == nil || vals.Kind(v) == "map" && !vals.HasKey(v, q.key) {
+			return out(nil)
+		}
+		elem, err := vals.Index(v, q.key)
+		if err != nil {
+			return err
+		}
+		return out(elem)
+	})
+}
+
+// Outputs the values in the outputs of base, which must be lists or maps.
+type queryIterate struct{ base queryNode }
+
+func (q queryIterate) eval(v any, out func(any) error) error {
+	return q.base.eval(v, func(v any) error {
+		if vals.Kind(v) == "map" {
+			var errOut error
+			errIterate := vals.IterateKeys(v, func(k any) bool {
+				var elem any
+				elem, errOut = vals.Index(v, k)
+				if errOut == nil {
+					errOut = out(elem)
+				}
+				return errOut == nil
+			})
+			if errIterate != nil {
+				return errIterate
+			}
+			return errOut
+		}
+		if vals.Kind(v) != "list" {
+			return fmt.Errorf("%s cannot be iterated", vals.Kind(v))
+		}
+		var errOut error
+		errIterate := vals.Iterate(v, func(elem any) bool {
+			errOut = out(elem)
+			return errOut == nil
+		})
+		if errIterate != nil {
+			return errIterate
+		}
+		return errOut
+	})
+}
+
+// Collects all the outputs of inner into a list.
+type queryList struct{ inner queryNode }
+
+func (q queryList) eval(v any, out func(any) error) error {
+	list := vals.EmptyList
+	if q.inner != nil {
+		err := q.inner.eval(v, func(v any) error {
+			list = list.Conj(v)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return out(list)
+}
+
+// Builds maps from entries. If the value of an entry has multiple outputs,
+// one map is built for each combination.
+type queryMap struct{ entries []queryMapEntry }
+
+type queryMapEntry struct {
+	key   string
+	value queryNode
+}
+
+func (q queryMap) eval(v any, out func(any) error) error {
+	var build func(i int, m vals.Map) error
+	build = func(i int, m vals.Map) error {
+		if i == len(q.entries) {
+			return out(m)
+		}
+		entry := q.entries[i]
+		return entry.value.eval(v, func(value any) error {
+			return build(i+1, m.Assoc(entry.key, value))
+		})
+	}
+	return build(0, vals.EmptyMap)
+}
+
+// Outputs v if any output of cond is booleanly true.
+type querySelect struct{ cond queryNode }
+
+func (q querySelect) eval(v any, out func(any) error) error {
+	selected := false
+	err := q.cond.eval(v, func(c any) error {
+		selected = selected || vals.Bool(c)
+		return nil
+	})
+	if err != nil || !selected {
+		return err
+	}
+	return out(v)
+}
+
+// A filter with no arguments, like keys.
+type queryFunc func(v any) (any, error)
+
+func (f queryFunc) eval(v any, out func(any) error) error {
+	result, err := f(v)
+	if err != nil {
+		return err
+	}
+	return out(result)
+}
+
+var queryFuncs = map[string]queryFunc{
+	"keys": func(v any) (any, error) {
+		list := vals.EmptyList
+		err := vals.IterateKeys(v, func(k any) bool {
+			list = list.Conj(k)
+			return true
+		})
+		return list, err
+	},
+	"length": func(v any) (any, error) {
+		if n := vals.Len(v); n >= 0 {
+			return n, nil
+		}
+		return nil, fmt.Errorf("%s has no length", vals.Kind(v))
+	},
+	"not": func(v any) (any, error) { return !vals.Bool(v), nil },
+}
+
+// Applies a binary operator to the outputs of both operands, for each
+// combination of them.
+type queryBinary struct {
+	op          string
+	left, right queryNode
+}
+
+func (q queryBinary) eval(v any, out func(any) error) error {
+	return q.left.eval(v, func(l any) error {
+		if q.op == "and" && !vals.Bool(l) || q.op == "or" && vals.Bool(l) {
+			return out(q.op == "or")
+		}
+		return q.right.eval(v, func(r any) error {
+			if q.op == "and" || q.op == "or" {
+				return out(vals.Bool(r))
+			}
+			result, err := queryCompare(q.op, l, r)
+			if err != nil {
+				return err
+			}
+			return out(result)
+		})
+	})
+}
+
+// Compares two values. Numbers and strings that can be parsed as numbers are
+// compared numerically when at least one of them is a number, or when the
+// operator is an ordering operator. Other strings are compared
+// lexicographically.
+func queryCompare(op string, l, r any) (bool, error) {
+	var ordering vals.Ordering
+	ln, lIsNum := toQueryNum(l)
+	rn, rIsNum := toQueryNum(r)
+	ordered := op != "==" && op != "!="
+	switch {
+	case ln != nil && rn != nil && (lIsNum || rIsNum || ordered):
+		ordering = vals.Cmp(ln, rn)
+	case !ordered:
+		return vals.Equal(l, r) == (op == "=="), nil
+	default:
+		ordering = vals.Cmp(l, r)
+	}
+	switch op {
+	case "==":
+		return ordering == vals.CmpEqual, nil
+	case "!=":
+		return ordering != vals.CmpEqual, nil
+	}
+	if ordering == vals.CmpUncomparable {
+		return false, fmt.Errorf("cannot compare %s and %s", vals.ReprPlain(l), vals.ReprPlain(r))
+	}
+	switch op {
+	case "<":
+		return ordering == vals.CmpLess, nil
+	case "<=":
+		return ordering != vals.CmpMore, nil
+	case ">":
+		return ordering == vals.CmpMore, nil
+	default: // ">="
+		return ordering != vals.CmpLess, nil
+	}
+}
+
+// Converts a number or a string that can be parsed as a number to a number,
+// and reports whether the argument is already a number. It returns nil if the
+// argument can't be converted.
+func toQueryNum(v any) (vals.Num, bool) {
+	switch v := v.(type) {
+	case int, *big.Int, *big.Rat, float64:
+		return v, true
+	case string:
+		return vals.ParseNum(v), false
+	}
+	return nil, false
+}
+
+// Parser.
+
+var errQueryEmpty = errors.New("empty query")
+
+type queryParser struct {
+	src string
+	pos int
+}
+
+func parseQuery(src string) (queryNode, error) {
+	p := &queryParser{src: src}
+	p.skipSpace()
+	if p.pos == len(src) {
+		return nil, errQueryEmpty
+	}
+	node, err := p.pipeline()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(src) {
+		return nil, p.errorf("unexpected %s", p.describeNext())
+	}
+	return node, nil
+}
+
+func (p *queryParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("bad query at char %d: %s", p.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *queryParser) describeNext() string {
+	if p.pos == len(p.src) {
+		return "end of query"
+	}
+	r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+	return parse.Quote(string(r))
+}
+
+func (p *queryParser) skipSpace() {
+	for p.pos < len(p.src) && strings.ContainsRune(" \t\r\n", rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+// Consumes s and any whitespace after it if the source continues with s.
+func (p *queryParser) consume(s string) bool {
+	if !strings.HasPrefix(p.src[p.pos:], s) {
+		return false
+	}
+	p.pos += len(s)
+	p.skipSpace()
+	return true
+}
+
+func (p *queryParser) expect(s string) error {
+	if !p.consume(s) {
+		return p.errorf("expect %s, got %s", s, p.describeNext())
+	}
+	return nil
+}
+
+// pipeline = comma { '|' comma }
+func (p *queryParser) pipeline() (queryNode, error) {
+	node, err := p.comma()
+	for err == nil && p.consume("|") {
+		var right queryNode
+		right, err = p.comma()
+		node = queryPipe{node, right}
+	}
+	return node, err
+}
+
+// comma = or { ',' or }
+func (p *queryParser) comma() (queryNode, error) {
+	node, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []queryNode{node}
+	for p.consume(",") {
+		node, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return queryComma{nodes}, nil
+}
+
+// or = and { 'or' and }
+func (p *queryParser) or() (queryNode, error) {
+	node, err := p.and()
+	for err == nil && p.consumeKeyword("or") {
+		var right queryNode
+		right, err = p.and()
+		node = queryBinary{"or", node, right}
+	}
+	return node, err
+}
+
+// and = compare { 'and' compare }
+func (p *queryParser) and() (queryNode, error) {
+	node, err := p.compare()
+	for err == nil && p.consumeKeyword("and") {
+		var right queryNode
+		right, err = p.compare()
+		node = queryBinary{"and", node, right}
+	}
+	return node, err
+}
+
+var queryCompareOps = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// compare = term [ op term ]
+func (p *queryParser) compare() (queryNode, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range queryCompareOps {
+		if p.consume(op) {
+			right, err := p.term()
+			if err != nil {
+				return nil, err
+			}
+			return queryBinary{op, left, right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *queryParser) term() (queryNode, error) {
+	switch {
+	case p.pos == len(p.src):
+		return nil, p.errorf("unexpected end of query")
+	case p.src[p.pos] == '.':
+		return p.path()
+	case p.consume("("):
+		node, err := p.pipeline()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return p.suffixes(node)
+	case p.consume("["):
+		if p.consume("]") {
+			return queryList{}, nil
+		}
+		inner, err := p.pipeline()
+		if err != nil {
+			return nil, err
+		}
+		return queryList{inner}, p.expect("]")
+	case p.consume("{"):
+		return p.mapBody()
+	case p.consume("$"):
+		switch name := p.word(); name {
+		case "nil":
+			return queryLiteral{nil}, nil
+		case "true":
+			return queryLiteral{true}, nil
+		case "false":
+			return queryLiteral{false}, nil
+		default:
+			return nil, p.errorf("unknown variable $%s", name)
+		}
+	case p.src[p.pos] == '\'' || p.src[p.pos] == '"':
+		s, err := p.quoted()
+		if err != nil {
+			return nil, err
+		}
+		return queryLiteral{s}, nil
+	}
+	start := p.pos
+	word := p.word()
+	if word == "" {
+		return nil, p.errorf("unexpected %s", p.describeNext())
+	}
+	switch word {
+	case "select":
+		cond, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return querySelect{cond}, nil
+	case "and", "or":
+		p.pos = start
+		return nil, p.errorf("unexpected %s", word)
+	}
+	if f, ok := queryFuncs[word]; ok {
+		return f, nil
+	}
+	return queryLiteral{word}, nil
+}
+
+// Consumes a keyword if the source continues with it as a whole word.
+func (p *queryParser) consumeKeyword(kw string) bool {
+	start := p.pos
+	if p.word() == kw {
+		return true
+	}
+	p.pos = start
+	return false
+}
+
+func isQueryWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("-_./+@%~", r)
+}
+
+func isQueryNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_'
+}
+
+// Consumes a bareword, which may be empty, and the whitespace after it.
+func (p *queryParser) word() string {
+	return p.run(isQueryWordRune)
+}
+
+func (p *queryParser) run(isRune func(rune) bool) string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		r, n := utf8.DecodeRuneInString(p.src[p.pos:])
+		if !isRune(r) {
+			break
+		}
+		p.pos += n
+	}
+	s := p.src[start:p.pos]
+	p.skipSpace()
+	return s
+}
+
+// Parses a quoted string, either in single quotes with '' standing for a
+// single quote, or in double quotes with Go-style escape sequences.
+func (p *queryParser) quoted() (string, error) {
+	quote := p.src[p.pos]
+	for i := p.pos + 1; i < len(p.src); i++ {
+		switch {
+		case p.src[i] == '\\' && quote == '"':
+			i++
+		case p.src[i] == quote:
+			if quote == '\'' && i+1 < len(p.src) && p.src[i+1] == '\'' {
+				i++
+				continue
+			}
+			raw := p.src[p.pos : i+1]
+			var s string
+			if quote == '\'' {
+				s = strings.ReplaceAll(raw[1:len(raw)-1], "''", "'")
+			} else {
+				var err error
+				s, err = strconv.Unquote(raw)
+				if err != nil {
+					return "", p.errorf("bad string %s", raw)
+				}
+			}
+			p.pos = i + 1
+			p.skipSpace()
+			return s, nil
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+// path = '.' [ name | string ] suffixes
+func (p *queryParser) path() (queryNode, error) {
+	p.pos++ // '.'
+	var node queryNode = queryIdentity{}
+	key, ok, err := p.pathKey()
+	if err != nil {
+		return nil, err
+	} else if ok {
+		node = queryIndex{node, key}
+	}
+	return p.suffixes(node)
+}
+
+// Parses a key directly after a '.', which can be a name or a quoted string.
+func (p *queryParser) pathKey() (string, bool, error) {
+	if p.pos < len(p.src) && (p.src[p.pos] == '\'' || p.src[p.pos] == '"') {
+		key, err := p.quoted()
+		return key, err == nil, err
+	}
+	start := p.pos
+	for p.pos < len(p.src) {
+		r, n := utf8.DecodeRuneInString(p.src[p.pos:])
+		if !isQueryNameRune(r) {
+			break
+		}
+		p.pos += n
+	}
+	return p.src[start:p.pos], p.pos > start, nil
+}
+
+// suffixes = { '.' (name | string) | '[' ']' | '[' (word | string) ']' }
+func (p *queryParser) suffixes(node queryNode) (queryNode, error) {
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case '.':
+			p.pos++
+			key, ok, err := p.pathKey()
+			if err != nil {
+				return nil, err
+			} else if !ok {
+				return nil, p.errorf("expect key after ., got %s", p.describeNext())
+			}
+			node = queryIndex{node, key}
+		case '[':
+			p.pos++
+			p.skipSpace()
+			if p.consume("]") {
+				node = queryIterate{node}
+				continue
+			}
+			var key string
+			if p.pos < len(p.src) && (p.src[p.pos] == '\'' || p.src[p.pos] == '"') {
+				var err error
+				key, err = p.quoted()
+				if err != nil {
+					return nil, err
+				}
+			} else {
+				key = p.word()
+				if key == "" {
+					return nil, p.errorf("expect index, got %s", p.describeNext())
+				}
+			}
+			if !strings.HasPrefix(p.src[p.pos:], "]") {
+				return nil, p.errorf("expect ], got %s", p.describeNext())
+			}
+			p.pos++
+			node = queryIndex{node, key}
+		default:
+			p.skipSpace()
+			return node, nil
+		}
+	}
+	return node, nil
+}
+
+// mapBody = [ entry { ',' entry } ] '}'
+// entry = (name | string) [ ':' or ]
+func (p *queryParser) mapBody() (queryNode, error) {
+	var entries []queryMapEntry
+	for !p.consume("}") {
+		if len(entries) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		var key string
+		if p.pos < len(p.src) && (p.src[p.pos] == '\'' || p.src[p.pos] == '"') {
+			var err error
+			if key, err = p.quoted(); err != nil {
+				return nil, err
+			}
+		} else {
+			key = p.run(isQueryNameRune)
+			if key == "" {
+				return nil, p.errorf("expect key, got %s", p.describeNext())
+			}
+		}
+		var value queryNode = queryIndex{queryIdentity{}, key}
+		if p.consume(":") {
+			var err error
+			if value, err = p.or(); err != nil {
+				return nil, err
+			}
+		}
+		entries = append(entries, queryMapEntry{key, value})
+	}
+	return queryMap{entries}, nil
+}
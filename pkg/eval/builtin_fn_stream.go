@@ -145,6 +145,8 @@ type orderOptions struct {
 	Key      Callable
 	Total    bool
 	LessThan Callable
+	Natural  bool
+	FoldCase bool
 }
 
 func (opt *orderOptions) SetDefaultOptions() {}
@@ -153,10 +155,17 @@ func (opt *orderOptions) SetDefaultOptions() {}
 // &less-than options are specified.
 var ErrBothTotalAndLessThan = errors.New("both &total and &less-than specified")
 
+// ErrBothCollationAndLessThan is returned by order when &less-than is
+// specified along with &natural or &fold-case.
+var ErrBothCollationAndLessThan = errors.New("both &natural or &fold-case and &less-than specified")
+
 func order(fm *Frame, opts orderOptions, inputs Inputs) error {
 	if opts.Total && opts.LessThan != nil {
 		return ErrBothTotalAndLessThan
 	}
+	if (opts.Natural || opts.FoldCase) && opts.LessThan != nil {
+		return ErrBothCollationAndLessThan
+	}
 	var values, keys []any
 	inputs(func(v any) { values = append(values, v) })
 	if opts.Key != nil {
@@ -176,7 +185,8 @@ func order(fm *Frame, opts orderOptions, inputs Inputs) error {
 		}
 	}
 
-	s := &slice{fm, opts.Total, opts.LessThan, values, keys, nil}
+	s := &slice{fm, opts.Total, collation{opts.Natural, opts.FoldCase},
+		opts.LessThan, values, keys, nil}
 	if opts.Reverse {
 		sort.Stable(sort.Reverse(s))
 	} else {
@@ -197,12 +207,13 @@ func order(fm *Frame, opts orderOptions, inputs Inputs) error {
 }
 
 type slice struct {
-	fm       *Frame
-	total    bool
-	lessThan Callable
-	values   []any
-	keys     []any // nil if no keys
-	err      error
+	fm        *Frame
+	total     bool
+	collation collation
+	lessThan  Callable
+	values    []any
+	keys      []any // nil if no keys
+	err       error
 }
 
 func (s *slice) Len() int { return len(s.values) }
@@ -220,11 +231,8 @@ func (s *slice) Less(i, j int) bool {
 	}
 
 	if s.lessThan == nil {
-		// Use a builtin comparator depending on s.mixed.
-		if s.total {
-			return vals.CmpTotal(a, b) == vals.CmpLess
-		}
-		o := vals.Cmp(a, b)
+		// Use a builtin comparator depending on s.total and s.collation.
+		o := s.collation.cmp(a, b, s.total)
 		if o == vals.CmpUncomparable {
 			s.err = ErrUncomparable
 			return true
@@ -16,6 +16,7 @@ func init() {
 	addBuiltinFns(map[string]any{
 		"styled-segment":     styledSegment,
 		"styled":             styled,
+		"parse-sgr":          ui.ParseSGREscapedText,
 		"render-styledown":   styledown.Render,
 		"derender-styledown": derenderStyledown,
 	})
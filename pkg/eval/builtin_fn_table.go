@@ -0,0 +1,208 @@
+package eval
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/ui"
+	"src.elv.sh/pkg/wcwidth"
+)
+
+// The table command.
+
+func init() {
+	addBuiltinFns(map[string]any{
+		"table": table,
+	})
+}
+
+type tableOpts struct {
+	Columns     vals.List
+	SortBy      string
+	Reverse     bool
+	MaxWidth    int
+	Header      bool
+	HeaderStyle string
+}
+
+func (opts *tableOpts) SetDefaultOptions() { opts.Header = true }
+
+func table(fm *Frame, opts tableOpts, inputs Inputs) error {
+	var headerStylings []ui.Styling
+	if opts.HeaderStyle != "" {
+		styling := ui.ParseStyling(opts.HeaderStyle)
+		if styling == nil {
+			return fmt.Errorf("%s is not a valid style transformer", parse.Quote(opts.HeaderStyle))
+		}
+		headerStylings = append(headerStylings, styling)
+	}
+
+	var rows []any
+	var errInput error
+	inputs(func(v any) {
+		if errInput != nil {
+			return
+		}
+		if vals.Kind(v) != "map" {
+			errInput = fmt.Errorf("input to table must be map, got %s", vals.Kind(v))
+			return
+		}
+		rows = append(rows, v)
+	})
+	if errInput != nil {
+		return errInput
+	}
+
+	var columns []any
+	if opts.Columns != nil {
+		for it := opts.Columns.Iterator(); it.HasElem(); it.Next() {
+			columns = append(columns, it.Elem())
+		}
+	} else {
+		columns = tableColumns(rows)
+	}
+
+	if opts.SortBy != "" {
+		sort.SliceStable(rows, func(i, j int) bool {
+			a, b := tableCell(rows[i], opts.SortBy), tableCell(rows[j], opts.SortBy)
+			if opts.Reverse {
+				a, b = b, a
+			}
+			return tableCmp(a, b) == vals.CmpLess
+		})
+	} else if opts.Reverse {
+		slices.Reverse(rows)
+	}
+
+	if len(columns) == 0 {
+		return nil
+	}
+
+	// Convert all cells to text first, since the width of each column depends
+	// on all the cells in it.
+	var cells [][]ui.Text
+	if opts.Header {
+		header := make([]ui.Text, len(columns))
+		for i, column := range columns {
+			header[i] = ui.T(vals.ToString(column), headerStylings...)
+		}
+		cells = append(cells, header)
+	}
+	for _, row := range rows {
+		rowCells := make([]ui.Text, len(columns))
+		for i, column := range columns {
+			rowCells[i] = tableText(tableCell(row, column))
+		}
+		cells = append(cells, rowCells)
+	}
+
+	widths := make([]int, len(columns))
+	for _, rowCells := range cells {
+		for i, cell := range rowCells {
+			if opts.MaxWidth > 0 && tableTextWidth(cell) > opts.MaxWidth {
+				cell = append(cell.TrimWcwidth(opts.MaxWidth-1), &ui.Segment{Text: "…"})
+				rowCells[i] = cell
+			}
+			widths[i] = max(widths[i], tableTextWidth(cell))
+		}
+	}
+
+	var sb strings.Builder
+	for _, rowCells := range cells {
+		var line strings.Builder
+		for i, cell := range rowCells {
+			if i > 0 {
+				line.WriteString("  ")
+			}
+			line.WriteString(renderTableText(cell))
+			line.WriteString(strings.Repeat(" ", widths[i]-tableTextWidth(cell)))
+		}
+		sb.WriteString(strings.TrimRight(line.String(), " ") + "\n")
+	}
+	_, err := fm.ByteOutput().WriteString(sb.String())
+	return err
+}
+
+// Returns the keys of all the rows, sorted.
+func tableColumns(rows []any) []any {
+	seen := make(map[string]bool)
+	var names []string
+	for _, row := range rows {
+		vals.IterateKeys(row, func(k any) bool {
+			if name := vals.ToString(k); !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+			return true
+		})
+	}
+	sort.Strings(names)
+	columns := make([]any, len(names))
+	for i, name := range names {
+		columns[i] = name
+	}
+	return columns
+}
+
+func tableCell(row, column any) any {
+	v, err := vals.Index(row, column)
+	if err != nil {
+		return nil
+	}
+	return v
+}
+
+// Compares two cells for sorting. Numbers and strings that look like numbers
+// are compared numerically; other values that are not comparable are compared
+// by their string representations.
+func tableCmp(a, b any) vals.Ordering {
+	an, _ := toQueryNum(a)
+	bn, _ := toQueryNum(b)
+	if an != nil && bn != nil {
+		return vals.Cmp(an, bn)
+	}
+	if o := vals.Cmp(a, b); o != vals.CmpUncomparable {
+		return o
+	}
+	return vals.Cmp(vals.ToString(a), vals.ToString(b))
+}
+
+func tableText(v any) ui.Text {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case ui.Text:
+		return v
+	case *ui.Segment:
+		return ui.TextFromSegment(v)
+	default:
+		return ui.T(vals.ToString(v))
+	}
+}
+
+func tableTextWidth(t ui.Text) int {
+	w := 0
+	for _, seg := range t {
+		w += wcwidth.Of(seg.Text)
+	}
+	return w
+}
+
+// Renders text with VT escape sequences, or as plain text if it has no
+// styles.
+func renderTableText(t ui.Text) string {
+	for _, seg := range t {
+		if seg.SGR() != "" {
+			return t.VTString()
+		}
+	}
+	var sb strings.Builder
+	for _, seg := range t {
+		sb.WriteString(seg.Text)
+	}
+	return sb.String()
+}
@@ -1,6 +1,7 @@
 package eval
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"math/big"
@@ -15,6 +16,7 @@ import (
 func init() {
 	addBuiltinFns(map[string]any{
 		"sleep":     sleep,
+		"timeout":   timeout,
 		"time":      timeCmd,
 		"benchmark": benchmark,
 	})
@@ -29,24 +31,10 @@ var (
 )
 
 func sleep(fm *Frame, duration any) error {
-	var f float64
-	var d time.Duration
-
-	if err := vals.ScanToGo(duration, &f); err == nil {
-		d = time.Duration(f * float64(time.Second))
-	} else {
-		// See if it is a duration string rather than a simple number.
-		switch duration := duration.(type) {
-		case string:
-			d, err = time.ParseDuration(duration)
-			if err != nil {
-				return ErrInvalidSleepDuration
-			}
-		default:
-			return ErrInvalidSleepDuration
-		}
+	d, ok := ScanDuration(duration)
+	if !ok {
+		return ErrInvalidSleepDuration
 	}
-
 	if d < 0 {
 		return ErrNegativeSleepDuration
 	}
@@ -59,24 +47,137 @@ func sleep(fm *Frame, duration any) error {
 	}
 }
 
-type timeOpt struct{ OnEnd Callable }
+// ScanDuration converts a number of seconds or a duration string (as accepted
+// by [time.ParseDuration]) to a duration.
+func ScanDuration(duration any) (time.Duration, bool) {
+	var f float64
+	if err := vals.ScanToGo(duration, &f); err == nil {
+		return time.Duration(f * float64(time.Second)), true
+	}
+	// See if it is a duration string rather than a simple number.
+	if s, ok := duration.(string); ok {
+		d, err := time.ParseDuration(s)
+		return d, err == nil
+	}
+	return 0, false
+}
+
+// Timeout is the reason of the exception thrown by timeout when the command
+// doesn't finish in time.
+type Timeout struct {
+	Duration time.Duration
+}
+
+func (t Timeout) Error() string {
+	return "timed out after " + t.Duration.String()
+}
+
+func (t Timeout) Kind() string           { return "timeout-error" }
+func (t Timeout) Fields() vals.MethodMap { return timeoutFields{t} }
+
+type timeoutFields struct{ t Timeout }
+
+func (timeoutFields) Type() string       { return "timeout" }
+func (f timeoutFields) Duration() string { return f.t.Duration.String() }
+
+type timeoutOpts struct{ KillAfter any }
+
+func (o *timeoutOpts) SetDefaultOptions() { o.KillAfter = "5s" }
+
+func timeout(fm *Frame, opts timeoutOpts, duration, cmd any, args ...any) error {
+	d, ok := ScanDuration(duration)
+	if !ok || d < 0 {
+		return errs.BadValue{What: "duration",
+			Valid: "non-negative number or duration string", Actual: vals.ReprPlain(duration)}
+	}
+	killAfter, ok := ScanDuration(opts.KillAfter)
+	if !ok || killAfter < 0 {
+		return errs.BadValue{What: "kill-after option",
+			Valid: "non-negative number or duration string", Actual: vals.ReprPlain(opts.KillAfter)}
+	}
+	var f Callable
+	switch cmd := cmd.(type) {
+	case Callable:
+		f = cmd
+	case string:
+		f = NewExternalCmd(cmd)
+	default:
+		return errs.BadValue{What: "command",
+			Valid: "callable or string", Actual: vals.ReprPlain(cmd)}
+	}
+
+	ctx, cancel := context.WithTimeout(fm.ctx, d)
+	defer cancel()
+	newFm := fm.Fork()
+	newFm.ctx = ctx
+	// Run external commands in their own process group, so that the whole
+	// command tree can be terminated.
+	pg := newSubProcGroup(fm.pgroup, &fm.Evaler.children)
+	defer pg.done()
+	newFm.pgroup = pg
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			if ctx.Err() != context.DeadlineExceeded {
+				return
+			}
+			pg.terminate(false)
+			select {
+			case <-time.After(killAfter):
+				pg.terminate(true)
+			case <-done:
+			}
+		case <-done:
+		}
+	}()
+	err := f.Call(newFm, args, NoOpts)
+	close(done)
+	if ctx.Err() == context.DeadlineExceeded {
+		return Timeout{d}
+	}
+	return err
+}
+
+type timeOpt struct {
+	OnEnd Callable
+	Stats bool
+}
 
 func (o *timeOpt) SetDefaultOptions() {}
 
 func timeCmd(fm *Frame, opts timeOpt, f Callable) error {
+	ru0, hasRusage := getRusage()
 	t0 := time.Now()
 	err := f.Call(fm, NoArgs, NoOpts)
 	t1 := time.Now()
+	ru1, _ := getRusage()
 
 	dt := t1.Sub(t0)
+	var result any = dt.Seconds()
+	if opts.Stats {
+		stats := vals.MakeMap("wall", dt.Seconds())
+		if hasRusage {
+			stats = stats.Assoc("user", (ru1.user-ru0.user).Seconds()).
+				Assoc("sys", (ru1.sys-ru0.sys).Seconds()).
+				Assoc("max-rss", vals.Int64ToNum(ru1.maxRSS))
+		}
+		result = stats
+	}
 	if opts.OnEnd != nil {
 		newFm := fm.Fork()
-		errCb := opts.OnEnd.Call(newFm, []any{dt.Seconds()}, NoOpts)
+		errCb := opts.OnEnd.Call(newFm, []any{result}, NoOpts)
 		if err == nil {
 			err = errCb
 		}
 	} else {
-		_, errWrite := fmt.Fprintln(fm.ByteOutput(), dt)
+		var errWrite error
+		if opts.Stats {
+			errWrite = fm.ValueOutput().Put(result)
+		} else {
+			_, errWrite = fmt.Fprintln(fm.ByteOutput(), dt)
+		}
 		if err == nil {
 			err = errWrite
 		}
@@ -22,8 +22,10 @@ import (
 	"path/filepath"
 	"reflect"
 	"strings"
+	"time"
 	"unicode/utf8"
 
+	"src.elv.sh/pkg/buildinfo"
 	"src.elv.sh/pkg/diag"
 	"src.elv.sh/pkg/eval/vals"
 	"src.elv.sh/pkg/eval/vars"
@@ -374,27 +376,69 @@ func (op fnWrap) exec(fm *Frame) Exception {
 	return nil
 }
 
-// UseForm = 'use' StringPrimary
+// UseForm = 'use' StringPrimary [ [ 'as' ] StringPrimary ] [ 'import' { StringPrimary } ]
 func compileUse(cp *compiler, fn *parse.Form) effectOp {
+	if cp.sandboxed {
+		cp.errorpf(fn, "use disallowed in eval-in")
+		return nopOp{}
+	}
 	args := getArgs(cp, fn)
 	spec := args.get(0, "module spec").stringLiteral()
-	name := ""
-	if args.has(1) {
-		name = args.get(1, "module name").stringLiteral()
-	} else {
-		name = spec[strings.LastIndexByte(spec, '/')+1:]
+	name := spec[strings.LastIndexByte(spec, '/')+1:]
+	explicitName := false
+	i := 1
+	if args.hasKeyword(i, "as") {
+		name = args.get(i+1, "module name").stringLiteral()
+		explicitName = true
+		i += 2
+	} else if args.has(i) && !args.hasKeyword(i, "import") {
+		name = args.get(i, "module name").stringLiteral()
+		explicitName = true
+		i++
+	}
+	var imports []useImport
+	if args.hasKeyword(i, "import") {
+		i++
+		if !args.has(i) {
+			// Reports the missing argument.
+			args.get(i, "name to import")
+		}
+		for ; args.has(i); i++ {
+			arg := args.get(i, "name to import")
+			sym := arg.stringLiteral()
+			if strings.ContainsRune(sym, ':') {
+				args.errorpf(arg.any(), "can't import qualified name %s", parse.Quote(sym))
+			}
+			imports = append(imports, useImport{arg.any().Range(), sym, -1})
+		}
 	}
 	if !args.finish() {
 		return nil
 	}
 
-	return useOp{fn.Range(), cp.thisScope().add(name + NsSuffix), spec}
+	// When only importing selected names, the module itself is not bound
+	// unless a name is given explicitly.
+	varIndex := -1
+	if explicitName || len(imports) == 0 {
+		varIndex = cp.thisScope().add(name + NsSuffix)
+	}
+	for i := range imports {
+		imports[i].varIndex = cp.thisScope().add(imports[i].name)
+	}
+	return useOp{fn.Range(), varIndex, spec, imports}
 }
 
 type useOp struct {
 	diag.Ranging
 	varIndex int
 	spec     string
+	imports  []useImport
+}
+
+type useImport struct {
+	diag.Ranging
+	name     string
+	varIndex int
 }
 
 func (op useOp) exec(fm *Frame) Exception {
@@ -402,7 +446,20 @@ func (op useOp) exec(fm *Frame) Exception {
 	if err != nil {
 		return fm.errorp(op, err)
 	}
-	fm.local.slots[op.varIndex].Set(ns)
+	if op.varIndex != -1 {
+		fm.local.slots[op.varIndex].Set(ns)
+	}
+	for _, imp := range op.imports {
+		v := ns.IndexString(imp.name)
+		if v == nil {
+			return fm.errorpf(imp, "module %s has no variable $%s",
+				parse.Quote(op.spec), parse.Quote(imp.name))
+		}
+		err := fm.local.slots[imp.varIndex].Set(v.Get())
+		if err != nil {
+			return fm.errorp(imp, err)
+		}
+	}
 	return nil
 }
 
@@ -427,14 +484,11 @@ func use(fm *Frame, spec string, r diag.Ranger) (*Ns, error) {
 		return useFromFile(fm, spec, path, r)
 	}
 
-	// Handle imports of pre-defined modules like `builtin` and `str`.
+	// Handle imports of pre-defined modules like `builtin` and `str`, and
+	// bundled modules that have already been loaded.
 	if ns, ok := fm.Evaler.modules[spec]; ok {
 		return ns, nil
 	}
-	if code, ok := fm.Evaler.BundledModules[spec]; ok {
-		return evalModule(fm, spec,
-			parse.Source{Name: "[bundled " + spec + "]", Code: code}, r)
-	}
 
 	// Handle imports relative to the Elvish module search directories.
 	//
@@ -448,6 +502,13 @@ func use(fm *Frame, spec string, r diag.Ranger) (*Ns, error) {
 		return ns, err
 	}
 
+	// Handle imports of bundled modules. These are searched after the module
+	// search directories, so that users can override them.
+	if code, ok := fm.Evaler.BundledModules[spec]; ok {
+		return evalModule(fm, spec,
+			parse.Source{Name: "[bundled " + spec + "]", Code: code}, r)
+	}
+
 	// Sadly, we couldn't resolve the module spec.
 	return nil, NoSuchModule{spec}
 }
@@ -467,7 +528,11 @@ func useFromFile(fm *Frame, spec, path string, r diag.Ranger) (*Ns, error) {
 			return nil, err
 		}
 		src := parse.Source{Name: path + ".elv", Code: code, IsFile: true}
-		return evalModule(fm, path, src, r)
+		ns, err := evalModule(fm, path, src, r)
+		if err == nil {
+			fm.Evaler.moduleModTimes[path] = modTime(path + ".elv")
+		}
+		return ns, err
 	}
 
 	plug, err := pluginOpen(path + ".so")
@@ -488,6 +553,16 @@ func useFromFile(fm *Frame, spec, path string, r diag.Ranger) (*Ns, error) {
 	return *ns, nil
 }
 
+// Returns the modification time of a file, or the zero time if it can't be
+// determined.
+func modTime(name string) time.Time {
+	info, err := os.Stat(name)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
 func readFileUTF8(fname string) (string, error) {
 	bytes, err := os.ReadFile(fname)
 	if err != nil {
@@ -501,7 +576,8 @@ func readFileUTF8(fname string) (string, error) {
 
 // TODO: Make access to fm.Evaler.modules concurrency-safe.
 func evalModule(fm *Frame, key string, src parse.Source, r diag.Ranger) (*Ns, error) {
-	ns, exec, err := fm.PrepareEval(src, r, new(Ns))
+	var reexports []*Ns
+	ns, exec, err := fm.prepareEval(src, r, new(Ns), &reexports)
 	if err != nil {
 		return nil, err
 	}
@@ -514,6 +590,16 @@ func evalModule(fm *Frame, key string, src parse.Source, r diag.Ranger) (*Ns, er
 		delete(fm.Evaler.modules, key)
 		return nil, err
 	}
+	if len(reexports) > 0 {
+		// Names defined by the module itself take precedence over re-exported
+		// ones; among re-exported namespaces, later ones take precedence.
+		combined := new(Ns)
+		for _, reexport := range reexports {
+			combined = CombineNs(combined, reexport)
+		}
+		ns = CombineNs(combined, ns)
+		fm.Evaler.modules[key] = ns
+	}
 	return ns, nil
 }
 
@@ -885,7 +971,7 @@ func (op *tryOp) exec(fm *Frame) Exception {
 	return fm.errorp(op, err)
 }
 
-// PragmaForm = 'pragma' 'fallback-resolver' '=' { Compound }
+// PragmaForm = 'pragma' Name '=' { Compound }
 func compilePragma(cp *compiler, fn *parse.Form) effectOp {
 	args := getArgs(cp, fn)
 	name := args.get(0, "pragma name").stringLiteral()
@@ -910,12 +996,36 @@ func compilePragma(cp *compiler, fn *parse.Form) effectOp {
 			cp.errorpfPartial(valueNode,
 				"invalid value for unknown-command: %s", parse.Quote(value))
 		}
+	case "unset-env":
+		value := stringLiteralOrError(cp, valueNode, "value for unset-env")
+		switch value {
+		case "empty":
+			cp.currentPragma().unsetEnvIsError = false
+		case "error":
+			cp.currentPragma().unsetEnvIsError = true
+		default:
+			cp.errorpfPartial(valueNode,
+				"invalid value for unset-env: %s", parse.Quote(value))
+		}
+	case "min-elvish-version":
+		value := stringLiteralOrError(cp, valueNode, "value for min-elvish-version")
+		min, ok := buildinfo.ParseVersion(value)
+		if !ok {
+			cp.errorpfPartial(valueNode,
+				"invalid value for min-elvish-version: %s", parse.Quote(value))
+		} else if current, _ := buildinfo.ParseVersion(elvishVersion); buildinfo.CompareVersions(current, min) < 0 {
+			cp.errorpf(valueNode,
+				"requires Elvish %s or later, but this is Elvish %s", value, elvishVersion)
+		}
 	default:
 		cp.errorpfPartial(fn.Args[0], "unknown pragma %s", parse.Quote(name))
 	}
 	return nopOp{}
 }
 
+// The version that min-elvish-version pragmas are checked against.
+var elvishVersion = buildinfo.VersionBase
+
 func (cp *compiler) compileOneLValue(n *parse.Compound, f lvalueFlag) lvalue {
 	if len(n.Indexings) != 1 {
 		cp.errorpf(n, "must be valid lvalue")
@@ -12,3 +12,16 @@ var (
 	// NoOpts is an empty option map. It can be used as an argument to Call.
 	NoOpts = map[string]any{}
 )
+
+// AcceptsNoArgs returns whether c can be called without any arguments. It
+// returns true if c is neither a builtin function nor a closure, since the
+// arity of such callables is not known.
+func AcceptsNoArgs(c Callable) bool {
+	switch c := c.(type) {
+	case *goFn:
+		return len(c.normalArgs) == 0
+	case *Closure:
+		return len(c.ArgNames) == 0 || (len(c.ArgNames) == 1 && c.RestArg == 0)
+	}
+	return true
+}
@@ -0,0 +1,74 @@
+package eval
+
+import (
+	"sort"
+	"sync"
+)
+
+// Keeps track of the process groups of child processes that may still be
+// running: foreground pipelines, background jobs and stopped commands. They are
+// hung up by [Evaler.HangUpChildren] unless disowned.
+type childGroups struct {
+	mu sync.Mutex
+	// Maps process group IDs to whether they have been disowned.
+	m map[int]bool
+}
+
+func (c *childGroups) add(pgid int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.m == nil {
+		c.m = make(map[int]bool)
+	}
+	c.m[pgid] = false
+}
+
+func (c *childGroups) remove(pgid int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.m, pgid)
+}
+
+// Disowns a process group, and returns whether it was being tracked.
+func (c *childGroups) disown(pgid int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.m[pgid]; !ok {
+		return false
+	}
+	c.m[pgid] = true
+	return true
+}
+
+func (c *childGroups) disownAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for pgid := range c.m {
+		c.m[pgid] = true
+	}
+}
+
+// Returns the process group IDs that have not been disowned, sorted.
+func (c *childGroups) owned() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var pgids []int
+	for pgid, disowned := range c.m {
+		if !disowned {
+			pgids = append(pgids, pgid)
+		}
+	}
+	sort.Ints(pgids)
+	return pgids
+}
+
+// HangUpChildren sends SIGHUP to the process groups of all child processes
+// that are still running, including stopped ones, unless they have been
+// disowned with the disown builtin. It does nothing on Windows.
+//
+// It should be called when an interactive shell exits.
+func (ev *Evaler) HangUpChildren() {
+	for _, pgid := range ev.children.owned() {
+		hangUp(pgid)
+	}
+}
@@ -87,11 +87,33 @@ func (op *pipelineOp) exec(fm *Frame) Exception {
 		return fm.errorp(op, ErrInterrupted)
 	}
 
+	var bgJob *job
 	if op.bg {
+		jobControl := fm.jobControl
 		fm = fm.Fork()
 		fm.ctx = context.Background()
 		fm.background = true
+		fm.jobControl = false
+		fm.pgroup = nil
+		if jobControl {
+			// Put the external commands in the job in their own process
+			// group, so that it can be stopped and resumed as a whole.
+			fm.pgroup = newBgProcGroup(&fm.Evaler.children)
+		}
+		bgJob = fm.Evaler.jobs.addRunning(op.source, fm.pgroup)
 		fm.Evaler.addNumBgJobs(1)
+	} else if fm.jobControl && fm.pgroup == nil {
+		// Put the external commands in this pipeline in their own process
+		// group, including those started by functions called in it.
+		fm = fm.Fork()
+		pg := newProcGroup(&fm.Evaler.children)
+		fm.pgroup = pg
+		defer func() {
+			pg.done()
+			// If some commands have been stopped, for example by Ctrl-Z, the
+			// pipeline becomes a job that can be resumed with fg or bg.
+			fm.Evaler.jobs.addStopped(op.source, pg, pg.stoppedPids())
+		}()
 	}
 
 	nforms := len(op.forms)
@@ -161,20 +183,17 @@ func (op *pipelineOp) exec(fm *Frame) Exception {
 		go func() {
 			wg.Wait()
 			fm.Evaler.addNumBgJobs(-1)
-			if notify := fm.Evaler.BgJobNotify; notify != nil {
-				msg := "job " + op.source + " finished"
-				err := MakePipelineError(excs)
-				if err != nil {
-					msg += ", errors = " + err.Error()
-				}
-				if fm.Evaler.getNotifyBgJobSuccess() || err != nil {
-					notify(msg)
-				}
+			var stopped []int
+			if pg := fm.pgroup; pg != nil {
+				stopped = pg.stoppedPids()
+				pg.done()
 			}
+			fm.Evaler.endJobRun(bgJob, MakePipelineError(excs), stopped)
 		}()
 		return nil
 	}
 	wg.Wait()
+	fm.Evaler.setPipeStatus(excs)
 	return fm.errorp(op, MakePipelineError(excs))
 }
 
@@ -214,6 +233,9 @@ type ordinaryCmd struct {
 	headOp valuesOp
 	argOps []valuesOp
 	optsOp *mapPairsOp
+	// Whether the head must evaluate to a callable, as opposed to a string that
+	// names an external command.
+	noExternal bool
 }
 
 func (cp *compiler) formBody(n *parse.Form) formBody {
@@ -236,11 +258,15 @@ func (cp *compiler) formBody(n *parse.Form) formBody {
 		// Head is a literal string: resolve to function or external (special
 		// commands are already handled above).
 		if _, fnRef := resolveCmdHeadInternally(cp, head, n.Head); fnRef != nil {
-			headOp = variableOp{n.Head.Range(), false, head + FnSuffix, fnRef}
+			headOp = variableOp{n.Head.Range(), false, head + FnSuffix, fnRef, false}
 		} else {
 			cp.autofixUnresolvedVar(head + FnSuffix)
-			if cp.currentPragma().unknownCommandIsExternal || fsutil.DontSearch(head) {
+			if cp.sandboxed {
+				cp.errorpfPartial(n.Head, "unknown command disallowed in eval-in")
+			} else if fsutil.DontSearch(head) {
 				headOp = literalValues(n.Head, NewExternalCmd(head))
+			} else if cp.currentPragma().unknownCommandIsExternal {
+				headOp = literalValues(n.Head, autoloadableCmd{head})
 			} else {
 				cp.errorpfPartial(n.Head, "unknown command disallowed by current pragma")
 			}
@@ -252,7 +278,7 @@ func (cp *compiler) formBody(n *parse.Form) formBody {
 
 	argOps := cp.compoundOps(n.Args)
 	optsOp := cp.mapPairs(n.Opts)
-	return formBody{ordinaryCmd: ordinaryCmd{headOp, argOps, optsOp}}
+	return formBody{ordinaryCmd: ordinaryCmd{headOp, argOps, optsOp, cp.sandboxed}}
 }
 
 func (op *formOp) exec(fm *Frame, fops *[]formOwnedPort) (errRet Exception) {
@@ -279,7 +305,7 @@ func (op *formOp) exec(fm *Frame, fops *[]formOwnedPort) (errRet Exception) {
 		return nil
 	}
 
-	headFn, err := evalForCommand(fm, cmd.headOp, "command")
+	headFn, err := evalForCommand(fm, cmd.headOp, "command", !cmd.noExternal)
 	if err != nil {
 		return fm.errorp(cmd.headOp, err)
 	}
@@ -316,7 +342,7 @@ func (op *formOp) exec(fm *Frame, fops *[]formOwnedPort) (errRet Exception) {
 	return &exception{err, fm.traceback}
 }
 
-func evalForCommand(fm *Frame, op valuesOp, what string) (Callable, error) {
+func evalForCommand(fm *Frame, op valuesOp, what string, allowExternal bool) (Callable, error) {
 	value, err := evalForValue(fm, op, what)
 	if err != nil {
 		return nil, err
@@ -325,14 +351,16 @@ func evalForCommand(fm *Frame, op valuesOp, what string) (Callable, error) {
 	case Callable:
 		return value, nil
 	case string:
-		if fsutil.DontSearch(value) {
+		if allowExternal && fsutil.DontSearch(value) {
 			return NewExternalCmd(value), nil
 		}
 	}
+	valid := "callable or string containing slash"
+	if !allowExternal {
+		valid = "callable"
+	}
 	return nil, fm.errorp(op, errs.BadValue{
-		What:   what,
-		Valid:  "callable or string containing slash",
-		Actual: vals.ReprPlain(value)})
+		What: what, Valid: valid, Actual: vals.ReprPlain(value)})
 }
 
 func allTrue(vs []any) bool {
@@ -374,6 +402,9 @@ func (cp *compiler) redirOp(n *parse.Redir) *redirOp {
 		// TODO: Record and get redirection sign position
 		cp.errorpf(n, "bad redirection sign")
 	}
+	if cp.sandboxed && !n.RightIsFd {
+		cp.errorpf(n, "redirection from or to file disallowed in eval-in")
+	}
 	return &redirOp{n.Range(), dstOp, cp.compoundOp(n.Right), n.RightIsFd, n.Mode, flag}
 }
 
@@ -448,6 +479,11 @@ func (op *redirOp) exec(fm *Frame, fops *[]formOwnedPort) Exception {
 	}
 	switch src := src.(type) {
 	case string:
+		if op.flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+			if err := fm.Evaler.CheckWrite(src); err != nil {
+				return fm.errorp(op, err)
+			}
+		}
 		f, err := os.OpenFile(src, op.flag, defaultFileRedirPerm)
 		if err != nil {
 			return fm.errorpf(op, "failed to open file %s: %s", vals.ReprPlain(src), err)
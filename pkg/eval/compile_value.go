@@ -34,6 +34,9 @@ func (cp *compiler) compoundOp(n *parse.Compound) valuesOp {
 	indexings := n.Indexings
 
 	if n.Indexings[0].Head.Type == parse.Tilde {
+		if cp.sandboxed {
+			cp.errorpf(n.Indexings[0].Head, "tilde expansion disallowed in eval-in")
+		}
 		// A lone ~.
 		if len(n.Indexings) == 1 {
 			return loneTildeOp{n.Range()}
@@ -269,8 +272,12 @@ func (cp *compiler) primaryOp(n *parse.Primary) valuesOp {
 			// expensive and let's call this good enough for now.
 			cp.errorpfPartial(n, "variable $%s not found", parse.Quote(qname))
 		}
-		return &variableOp{n.Range(), sigil != "", qname, ref}
+		unsetIsError := ref != nil && ref.scope == envScope && cp.currentPragma().unsetEnvIsError
+		return &variableOp{n.Range(), sigil != "", qname, ref, unsetIsError}
 	case parse.Wildcard:
+		if cp.sandboxed {
+			cp.errorpf(n, "wildcard disallowed in eval-in")
+		}
 		seg, err := wildcardToSegment(parse.SourceText(n))
 		if err != nil {
 			cp.errorpf(n, "%s", err)
@@ -313,6 +320,9 @@ type variableOp struct {
 	explode bool
 	qname   string
 	ref     *varRef
+	// Whether reading an unset variable is an error. This is only set for
+	// environment variables when the unset-env pragma is "error".
+	unsetIsError bool
 }
 
 func (op variableOp) exec(fm *Frame) ([]any, Exception) {
@@ -320,6 +330,11 @@ func (op variableOp) exec(fm *Frame) ([]any, Exception) {
 	if variable == nil {
 		return nil, fm.errorpf(op, "variable $%s not found", parse.Quote(op.qname))
 	}
+	if op.unsetIsError {
+		if u, ok := variable.(vars.UnsettableVar); ok && !u.IsSet() {
+			return nil, fm.errorpf(op, "environment variable $%s is not set", parse.Quote(op.qname))
+		}
+	}
 	value := variable.Get()
 	if op.explode {
 		vs, err := vals.Collect(value)
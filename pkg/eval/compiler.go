@@ -35,10 +35,15 @@ type compiler struct {
 	errors []*CompilationError
 	// Suggested code to fix potential issues found during compilation.
 	autofixes []string
+	// Whether the code is compiled for eval-in, in which case the code can't
+	// access anything outside its namespace, like external commands,
+	// environment variables, modules and files.
+	sandboxed bool
 }
 
 type scopePragma struct {
 	unknownCommandIsExternal bool
+	unsetEnvIsError          bool
 }
 
 func compile(b, g *staticNs, modules []string, tree parse.Tree, w io.Writer) (nsOp, []string, error) {
@@ -47,11 +52,24 @@ func compile(b, g *staticNs, modules []string, tree parse.Tree, w io.Writer) (ns
 		b, []*staticNs{g}, []*staticUpNs{new(staticUpNs)},
 		[]*scopePragma{{unknownCommandIsExternal: true}},
 		modules,
-		w, newDeprecationRegistry(), tree.Source, nil, nil}
+		w, newDeprecationRegistry(), tree.Source, nil, nil, false}
 	chunkOp := cp.chunkOp(tree.Root)
 	return nsOp{chunkOp, g}, cp.autofixes, diag.PackErrors(cp.errors)
 }
 
+// Like compile, but compiles the code for eval-in, with an empty builtin
+// namespace.
+func compileSandboxed(g *staticNs, tree parse.Tree, w io.Writer) (nsOp, error) {
+	g = g.clone()
+	cp := &compiler{
+		new(staticNs), []*staticNs{g}, []*staticUpNs{new(staticUpNs)},
+		[]*scopePragma{{unknownCommandIsExternal: false}},
+		nil,
+		w, newDeprecationRegistry(), tree.Source, nil, nil, true}
+	chunkOp := cp.chunkOp(tree.Root)
+	return nsOp{chunkOp, g}, diag.PackErrors(cp.errors)
+}
+
 type nsOp struct {
 	inner    effectOp
 	template *staticNs
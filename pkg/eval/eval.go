@@ -9,6 +9,7 @@ import (
 	"os"
 	"strconv"
 	"sync"
+	"time"
 
 	"src.elv.sh/pkg/env"
 	"src.elv.sh/pkg/eval/vals"
@@ -50,6 +51,8 @@ type Evaler struct {
 	BeforeChdir, AfterChdir []func(string)
 	// Directories to search libraries.
 	LibDirs []string
+	// Directories to search autoloaded functions.
+	AutoloadDirs []string
 	// Source code of internal bundled modules indexed by use specs.
 	BundledModules map[string]string
 	// Callback to notify the success or failure of background jobs. Must not be
@@ -75,6 +78,11 @@ type Evaler struct {
 	// Internal modules are indexed by use specs. External modules are indexed by
 	// absolute paths.
 	modules map[string]*Ns
+	// Modification times of the files of external modules when they were
+	// loaded, indexed by the same keys as modules. Used by reload-modules.
+	moduleModTimes map[string]time.Time
+	// Restrictions in restricted mode; nil if not in restricted mode.
+	restrictions *restrictions
 
 	// Various states and configs exposed to Elvish code.
 	//
@@ -86,6 +94,24 @@ type Evaler struct {
 	notifyBgJobSuccess bool
 	// The current number of background jobs, exposed as $num-bg-jobs.
 	numBgJobs int
+	// The exceptions of the forms in the last foreground pipeline, with nil
+	// for forms that didn't throw, exposed as $pipestatus.
+	pipeStatus []Exception
+
+	// Stopped and background jobs. This has its own mutex.
+	jobs jobTable
+
+	// Signal handlers set by Elvish code. This has its own mutex.
+	signals signalHandlers
+
+	// Process groups of child processes that may still be running.
+	children childGroups
+
+	// Names of the autoload files in AutoloadDirs. This has its own mutex.
+	autoloads autoloadCache
+
+	// Options registered with RegisterOption. This has its own mutex.
+	options optionRegistry
 }
 
 // NewEvaler creates a new Evaler.
@@ -104,6 +130,7 @@ func NewEvaler() *Evaler {
 		deprecations: newDeprecationRegistry(),
 
 		modules:        make(map[string]*Ns),
+		moduleModTimes: make(map[string]time.Time),
 		BundledModules: make(map[string]string),
 
 		valuePrefix:        defaultValuePrefix,
@@ -111,6 +138,7 @@ func NewEvaler() *Evaler {
 		numBgJobs:          0,
 		Args:               vals.EmptyList,
 	}
+	ev.options.m = make(map[string]*registeredOption)
 
 	ev.PreExitHooks = []func(){func() {
 		CallHook(ev, nil, "before-exit", beforeExitHookElvish.Get().(vals.List))
@@ -127,12 +155,15 @@ func NewEvaler() *Evaler {
 		AddVar("before-exit", beforeExitHookElvish).
 		AddVar("before-chdir", beforeChdirElvish).
 		AddVar("after-chdir", afterChdirElvish).
-		AddVar("value-out-indicator",
-			vars.FromPtrWithMutex(&ev.valuePrefix, &ev.mu)).
-		AddVar("notify-bg-job-success",
-			vars.FromPtrWithMutex(&ev.notifyBgJobSuccess, &ev.mu)).
+		AddVar("value-out-indicator", ev.RegisterOption(
+			OptionSpec{Name: "value-out-indicator", Default: defaultValuePrefix},
+			vars.FromPtrWithMutex(&ev.valuePrefix, &ev.mu))).
+		AddVar("notify-bg-job-success", ev.RegisterOption(
+			OptionSpec{Name: "notify-bg-job-success", Default: defaultNotifyBgJobSuccess},
+			vars.FromPtrWithMutex(&ev.notifyBgJobSuccess, &ev.mu))).
 		AddVar("num-bg-jobs",
 			vars.FromGet(func() any { return strconv.Itoa(ev.getNumBgJobs()) })).
+		AddVar("pipestatus", vars.FromGet(func() any { return ev.getPipeStatus() })).
 		AddVar("args", vars.FromGet(func() any { return ev.Args })))
 
 	// Install the "builtin" module after extension is complete.
@@ -239,12 +270,34 @@ func (ev *Evaler) addNumBgJobs(delta int) {
 	ev.numBgJobs += delta
 }
 
+func (ev *Evaler) getPipeStatus() vals.List {
+	ev.mu.RLock()
+	defer ev.mu.RUnlock()
+	li := vals.EmptyList
+	for _, exc := range ev.pipeStatus {
+		if exc == nil {
+			exc = OK
+		}
+		li = li.Conj(exc)
+	}
+	return li
+}
+
+func (ev *Evaler) setPipeStatus(excs []Exception) {
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	ev.pipeStatus = excs
+}
+
 // Chdir changes the current directory, and updates $E:PWD on success
 //
 // It runs the functions in beforeChdir immediately before changing the
 // directory, and the functions in afterChdir immediately after (if chdir was
 // successful). It returns nil as long as the directory changing part succeeds.
 func (ev *Evaler) Chdir(path string) error {
+	if err := ev.checkChdir(path); err != nil {
+		return err
+	}
 	for _, hook := range ev.BeforeChdir {
 		hook(path)
 	}
@@ -278,7 +331,9 @@ type EvalCfg struct {
 	// DummyOutputPort respectively.
 	Ports []*Port
 	// Whether the Eval method should try to put the Elvish in the foreground
-	// after the code is executed.
+	// after the code is executed. If the standard input is a terminal, this
+	// also puts each foreground pipeline in its own process group, and gives
+	// it control of the terminal while it runs.
 	PutInFg bool
 	// If not nil, used the given global namespace, instead of Evaler's own.
 	Global *Ns
@@ -375,6 +430,7 @@ func (ev *Evaler) Call(f Callable, callCfg CallCfg, evalCfg EvalCfg) error {
 }
 
 func (ev *Evaler) prepareFrame(src parse.Source, cfg EvalCfg) (*Frame, func()) {
+	ev.autoloads.invalidate()
 	intCtx := cfg.Interrupts
 	if intCtx == nil {
 		intCtx = context.Background()
@@ -382,7 +438,9 @@ func (ev *Evaler) prepareFrame(src parse.Source, cfg EvalCfg) (*Frame, func()) {
 
 	ports := fillDefaultDummyPorts(cfg.Ports)
 
-	fm := &Frame{ev, intCtx, ports, nil, false, src, cfg.Global, new(Ns), nil}
+	jobControl := cfg.PutInFg && canUseJobControl()
+	fm := &Frame{ev, intCtx, ports, nil, false, jobControl, nil,
+		src, cfg.Global, new(Ns), nil, nil}
 	return fm, func() {
 		if cfg.PutInFg {
 			err := putSelfInFg()
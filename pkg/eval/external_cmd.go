@@ -90,6 +90,9 @@ func (e externalCmd) Call(fm *Frame, argVals []any, opts map[string]any) error {
 	if err != nil {
 		return err
 	}
+	if err := fm.Evaler.CheckExternal(path); err != nil {
+		return err
+	}
 
 	if runtime.GOOS == "windows" && !filepath.IsAbs(path) {
 		// For some reason, Windows's CreateProcess API doesn't like forward
@@ -105,13 +108,32 @@ func (e externalCmd) Call(fm *Frame, argVals []any, opts map[string]any) error {
 
 	args[0] = path
 
-	sys := makeSysProcAttr(fm.background)
-	proc, err := os.StartProcess(path, args, &os.ProcAttr{Files: files, Sys: sys})
+	var proc *os.Process
+	if fm.pgroup != nil {
+		proc, err = fm.pgroup.start(path, args, files)
+	} else {
+		sys := makeSysProcAttr(fm.background)
+		proc, err = os.StartProcess(path, args, &os.ProcAttr{Files: files, Sys: sys})
+	}
 	if err != nil {
 		return err
 	}
+	if fm.background && fm.pgroup == nil {
+		// Background commands are started in their own process groups.
+		fm.Evaler.children.add(proc.Pid)
+		defer fm.Evaler.children.remove(proc.Pid)
+	}
 
-	state, err := proc.Wait()
+	var ws syscall.WaitStatus
+	if fm.pgroup != nil {
+		ws, err = fm.pgroup.wait(proc)
+	} else {
+		var state *os.ProcessState
+		state, err = proc.Wait()
+		if state != nil {
+			ws = state.Sys().(syscall.WaitStatus)
+		}
+	}
 	if err != nil {
 		// This should be a can't happen situation. Nonetheless, treat it as a
 		// soft error rather than panicking since the Go documentation is not
@@ -119,12 +141,11 @@ func (e externalCmd) Call(fm *Frame, argVals []any, opts map[string]any) error {
 		// calling `Wait` twice on a particular process object.
 		return err
 	}
-	ws := state.Sys().(syscall.WaitStatus)
 	if ws.Signaled() && isSIGPIPE(ws.Signal()) {
 		readerGone := fm.ports[1].readerGone
 		if readerGone != nil && readerGone.Load() {
 			return errs.ReaderGone{}
 		}
 	}
-	return NewExternalCmdExit(e.Name, state.Sys().(syscall.WaitStatus), proc.Pid)
+	return NewExternalCmdExit(e.Name, ws, proc.Pid)
 }
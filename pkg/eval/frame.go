@@ -35,12 +35,20 @@ type Frame struct {
 	ports      []*Port
 	traceback  *StackTrace
 	background bool
+	// Whether foreground pipelines get their own process groups, and the
+	// process group of the current foreground pipeline, if any.
+	jobControl bool
+	pgroup     *procGroup
 
 	// The following fields are only relevant when running Elvish code (as
 	// opposed to a builtin function or external command).
 	src       parse.Source
 	local, up *Ns
 	defers    *[]func(*Frame) Exception
+
+	// Namespaces to re-export from the module being loaded, or nil if no
+	// module is being loaded.
+	reexports *[]*Ns
 }
 
 // PrepareEval prepares a piece of code for evaluation in a copy of the current
@@ -53,6 +61,12 @@ type Frame struct {
 // returns the altered local namespace, function that can be called to actuate
 // the evaluation, and a nil error.
 func (fm *Frame) PrepareEval(src parse.Source, r diag.Ranger, ns *Ns) (*Ns, func() Exception, error) {
+	return fm.prepareEval(src, r, ns, nil)
+}
+
+// Like PrepareEval, but also sets the slice to store re-exported namespaces
+// in.
+func (fm *Frame) prepareEval(src parse.Source, r diag.Ranger, ns *Ns, reexports *[]*Ns) (*Ns, func() Exception, error) {
 	tree, err := parse.Parse(src, parse.Config{WarningWriter: fm.ErrorFile()})
 	if err != nil {
 		return nil, nil, err
@@ -66,7 +80,8 @@ func (fm *Frame) PrepareEval(src parse.Source, r diag.Ranger, ns *Ns) (*Ns, func
 		traceback = fm.addTraceback(r)
 	}
 	newFm := &Frame{
-		fm.Evaler, fm.ctx, fm.ports, traceback, fm.background, src, local, new(Ns), nil}
+		fm.Evaler, fm.ctx, fm.ports, traceback, fm.background, fm.jobControl, fm.pgroup,
+		src, local, new(Ns), nil, reexports}
 	op, _, err := compile(fm.Evaler.Builtin().static(), local.static(), nil, tree, fm.ErrorFile())
 	if err != nil {
 		return nil, nil, err
@@ -18,3 +18,27 @@ func TestGoFnAsValue(t *testing.T) {
 		NotEqual(fn2).
 		Repr("<builtin fn1>")
 }
+
+func TestAcceptsNoArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   Callable
+		want bool
+	}{
+		{"no args", NewGoFn("f", func() {}), true},
+		{"frame and options", NewGoFn("f", func(*Frame, RawOptions) {}), true},
+		{"variadic", NewGoFn("f", func(...int) {}), true},
+		{"normal arg", NewGoFn("f", func(string) {}), false},
+		{"inputs", NewGoFn("f", func(Inputs) {}), true},
+		{"closure without args", &Closure{RestArg: -1}, true},
+		{"closure with rest arg", &Closure{ArgNames: []string{"a"}, RestArg: 0}, true},
+		{"closure with arg", &Closure{ArgNames: []string{"a"}, RestArg: -1}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := AcceptsNoArgs(test.fn); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+
+	"src.elv.sh/pkg/eval/vals"
+)
+
+// Keeps track of jobs, which are pipelines that have been stopped, for example
+// by Ctrl-Z, or that are running in the background. Jobs can be listed with the
+// jobs builtin and resumed with fg and bg.
+type jobTable struct {
+	mu   sync.Mutex
+	jobs []*job
+	// Incremented whenever a job is added, stopped or resumed in the
+	// background, to find the current job.
+	seq int
+}
+
+// A job. The fields other than id, source and pg are guarded by the mutex of
+// the jobTable.
+type job struct {
+	id     int
+	source string
+	// The process group of the external commands in the job. Nil for
+	// background jobs when job control is not available.
+	pg *procGroup
+	// PIDs of the stopped processes. Empty when the job is running.
+	stopped []int
+	// Closed when the current run of the job ends, either because the job
+	// has finished or because it has stopped.
+	runDone chan struct{}
+	// The error of the last run.
+	err error
+	// Whether the job has been brought to the foreground with fg, in which
+	// case its end is not notified.
+	fg  bool
+	seq int
+}
+
+func (j *job) state() string {
+	if len(j.stopped) > 0 {
+		return "stopped"
+	}
+	return "running"
+}
+
+// Adds a job that is running.
+func (jt *jobTable) addRunning(source string, pg *procGroup) *job {
+	return jt.add(&job{source: source, pg: pg, runDone: make(chan struct{})})
+}
+
+// Adds a job that has been stopped. The job is not added if pids is empty.
+func (jt *jobTable) addStopped(source string, pg *procGroup, pids []int) {
+	if len(pids) > 0 {
+		jt.add(&job{source: source, pg: pg, stopped: pids, runDone: closedRunDone()})
+	}
+}
+
+func (jt *jobTable) add(j *job) *job {
+	jt.mu.Lock()
+	defer jt.mu.Unlock()
+	j.id = 1
+	if n := len(jt.jobs); n > 0 {
+		j.id = jt.jobs[n-1].id + 1
+	}
+	jt.seq++
+	j.seq = jt.seq
+	jt.jobs = append(jt.jobs, j)
+	return j
+}
+
+func closedRunDone() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}
+
+// Ends the current run of a job. If some of its processes have stopped, the
+// job is kept as a stopped job; otherwise it is removed. Unless the job is in
+// the foreground, this is notified with BgJobNotify.
+func (ev *Evaler) endJobRun(j *job, err error, stopped []int) {
+	jt := &ev.jobs
+	jt.mu.Lock()
+	j.err = err
+	close(j.runDone)
+	fg := j.fg
+	j.fg = false
+	if len(stopped) > 0 {
+		j.stopped = stopped
+		jt.seq++
+		j.seq = jt.seq
+	} else {
+		jt.jobs = slices.DeleteFunc(jt.jobs, func(j2 *job) bool { return j2 == j })
+	}
+	jt.mu.Unlock()
+
+	notify := ev.BgJobNotify
+	if fg || notify == nil {
+		return
+	}
+	if len(stopped) > 0 {
+		notify("job " + j.source + " stopped")
+	} else if err != nil {
+		notify("job " + j.source + " finished, errors = " + err.Error())
+	} else if ev.getNotifyBgJobSuccess() {
+		notify("job " + j.source + " finished")
+	}
+}
+
+// Finds a job by its ID, or by the PID of its process group or one of its
+// stopped processes. If there are no arguments, finds the current job, which
+// is the one most recently added, stopped or resumed in the background; if
+// stoppedOnly is true, only stopped jobs are considered.
+func (jt *jobTable) find(ids []int, stoppedOnly bool) ([]*job, error) {
+	jt.mu.Lock()
+	defer jt.mu.Unlock()
+	if len(ids) == 0 {
+		var current *job
+		for _, j := range jt.jobs {
+			if (!stoppedOnly || len(j.stopped) > 0) && (current == nil || j.seq > current.seq) {
+				current = j
+			}
+		}
+		if current == nil {
+			if stoppedOnly {
+				return nil, errNoStoppedJob
+			}
+			return nil, errNoCurrentJob
+		}
+		return []*job{current}, nil
+	}
+	jobs := make([]*job, len(ids))
+	for i, id := range ids {
+		jobs[i] = jt.findByID(id)
+		if jobs[i] == nil {
+			return nil, fmt.Errorf("no job with ID or PID %d", id)
+		}
+	}
+	return jobs, nil
+}
+
+func (jt *jobTable) findByID(id int) *job {
+	for _, j := range jt.jobs {
+		if j.id == id {
+			return j
+		}
+	}
+	for _, j := range jt.jobs {
+		if j.pg != nil && j.pg.getPgid() == id {
+			return j
+		}
+		for _, pid := range j.stopped {
+			if pid == id {
+				return j
+			}
+		}
+	}
+	return nil
+}
+
+var (
+	errNoCurrentJob = errors.New("no current job")
+	errNoStoppedJob = errors.New("no stopped job")
+)
+
+// Resumes a stopped job, and returns a channel that is closed when the job
+// stops again or finishes. If the job is already running, only returns the
+// channel. If inBg is true, the job becomes the current job.
+func (ev *Evaler) resumeJob(j *job, inBg bool) <-chan struct{} {
+	jt := &ev.jobs
+	jt.mu.Lock()
+	defer jt.mu.Unlock()
+	j.fg = !inBg
+	if inBg {
+		jt.seq++
+		j.seq = jt.seq
+	}
+	if len(j.stopped) == 0 {
+		return j.runDone
+	}
+	j.stopped = nil
+	j.runDone = make(chan struct{})
+	runDone := j.runDone
+	pids := j.pg.resume()
+	go func() {
+		excs := make([]Exception, len(pids))
+		var wg sync.WaitGroup
+		wg.Add(len(pids))
+		for i, pid := range pids {
+			go func() {
+				defer wg.Done()
+				// This never fails on Unix.
+				proc, _ := os.FindProcess(pid)
+				ws, err := j.pg.wait(proc)
+				if err == nil {
+					err = NewExternalCmdExit(j.pg.name(pid), ws, pid)
+				}
+				if err != nil {
+					excs[i] = &exception{err, nil}
+				}
+			}()
+		}
+		wg.Wait()
+		stopped := j.pg.stoppedPids()
+		j.pg.done()
+		ev.endJobRun(j, MakePipelineError(excs), stopped)
+	}()
+	return runDone
+}
+
+func (jt *jobTable) isStopped(j *job) bool {
+	jt.mu.Lock()
+	defer jt.mu.Unlock()
+	return len(j.stopped) > 0
+}
+
+func (jt *jobTable) lastErr(j *job) error {
+	jt.mu.Lock()
+	defer jt.mu.Unlock()
+	return j.err
+}
+
+// Returns information about the current jobs, ordered by ID.
+func (jt *jobTable) list() []vals.Map {
+	jt.mu.Lock()
+	defer jt.mu.Unlock()
+	infos := make([]vals.Map, len(jt.jobs))
+	for i, j := range jt.jobs {
+		info := vals.MakeMap("id", j.id, "source", j.source, "state", j.state())
+		if j.pg != nil {
+			if pgid := j.pg.getPgid(); pgid != 0 {
+				info = info.Assoc("pgid", pgid)
+			}
+		}
+		infos[i] = info
+	}
+	return infos
+}
@@ -0,0 +1,147 @@
+package eval
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/eval/vars"
+	"src.elv.sh/pkg/parse"
+)
+
+// OptionSpec describes an option, a setting of the Evaler or the editor that
+// is registered with [Evaler.RegisterOption].
+//
+// Options are exposed to Elvish code both as variables and via the option
+// builtin, which can also list all options and dump the ones that have been
+// changed.
+type OptionSpec struct {
+	// Name of the option, which is also the qualified name of the variable
+	// exposing it, like "edit:max-height".
+	Name string
+	// The default value. Its kind is reported as the kind of the option;
+	// values of other kinds are accepted if the variable storing the option
+	// accepts them, like numeric strings for number options.
+	Default any
+	// If not nil, called to validate new values before they are set.
+	Validate func(v any) error
+}
+
+// Registered options, keyed by name.
+type optionRegistry struct {
+	mu sync.RWMutex
+	m  map[string]*registeredOption
+}
+
+type registeredOption struct {
+	spec OptionSpec
+	v    vars.Var
+
+	mu    sync.Mutex
+	hooks []func(old, new any)
+}
+
+// NoSuchOption is returned when an option doesn't exist.
+type NoSuchOption struct {
+	Name string
+}
+
+func (e NoSuchOption) Error() string {
+	return "no such option: " + parse.Quote(e.Name)
+}
+
+// RegisterOption registers an option whose value is stored in v. It returns a
+// variable that validates new values before setting v, and calls the change
+// hooks afterwards; it should be put in the namespace in place of v.
+//
+// If an option with the same name has already been registered, it is
+// replaced.
+func (ev *Evaler) RegisterOption(spec OptionSpec, v vars.Var) vars.Var {
+	opt := &registeredOption{spec: spec, v: v}
+	ev.options.mu.Lock()
+	defer ev.options.mu.Unlock()
+	ev.options.m[spec.Name] = opt
+	return optionVar{opt}
+}
+
+// OnOptionChange adds a function to be called with the old and new values
+// after an option has been changed.
+func (ev *Evaler) OnOptionChange(name string, f func(old, new any)) error {
+	opt, err := ev.getOption(name)
+	if err != nil {
+		return err
+	}
+	opt.mu.Lock()
+	defer opt.mu.Unlock()
+	opt.hooks = append(opt.hooks, f)
+	return nil
+}
+
+// Option returns the value of an option.
+func (ev *Evaler) Option(name string) (any, error) {
+	opt, err := ev.getOption(name)
+	if err != nil {
+		return nil, err
+	}
+	return opt.v.Get(), nil
+}
+
+// SetOption validates and sets the value of an option.
+func (ev *Evaler) SetOption(name string, v any) error {
+	opt, err := ev.getOption(name)
+	if err != nil {
+		return err
+	}
+	return opt.set(v)
+}
+
+func (ev *Evaler) getOption(name string) (*registeredOption, error) {
+	ev.options.mu.RLock()
+	defer ev.options.mu.RUnlock()
+	opt, ok := ev.options.m[name]
+	if !ok {
+		return nil, NoSuchOption{name}
+	}
+	return opt, nil
+}
+
+// Returns all the options, sorted by name.
+func (ev *Evaler) allOptions() []*registeredOption {
+	ev.options.mu.RLock()
+	defer ev.options.mu.RUnlock()
+	opts := make([]*registeredOption, 0, len(ev.options.m))
+	for _, opt := range ev.options.m {
+		opts = append(opts, opt)
+	}
+	sort.Slice(opts, func(i, j int) bool { return opts[i].spec.Name < opts[j].spec.Name })
+	return opts
+}
+
+func (opt *registeredOption) kind() string { return vals.Kind(opt.spec.Default) }
+
+func (opt *registeredOption) set(v any) error {
+	if opt.spec.Validate != nil {
+		if err := opt.spec.Validate(v); err != nil {
+			return fmt.Errorf("invalid value for option %s: %w", opt.spec.Name, err)
+		}
+	}
+	opt.mu.Lock()
+	old := opt.v.Get()
+	if err := opt.v.Set(v); err != nil {
+		opt.mu.Unlock()
+		return err
+	}
+	new := opt.v.Get()
+	hooks := opt.hooks
+	opt.mu.Unlock()
+	for _, hook := range hooks {
+		hook(old, new)
+	}
+	return nil
+}
+
+type optionVar struct{ opt *registeredOption }
+
+func (ov optionVar) Get() any        { return ov.opt.v.Get() }
+func (ov optionVar) Set(v any) error { return ov.opt.set(v) }
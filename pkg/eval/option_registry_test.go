@@ -0,0 +1,59 @@
+package eval_test
+
+import (
+	"errors"
+	"testing"
+
+	. "src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vars"
+	"src.elv.sh/pkg/parse"
+)
+
+func TestRegisterOption(t *testing.T) {
+	ev := NewEvaler()
+	x := "default"
+	errBad := errors.New("bad")
+	v := ev.RegisterOption(OptionSpec{
+		Name: "x", Default: "default",
+		Validate: func(v any) error {
+			if v == "bad" {
+				return errBad
+			}
+			return nil
+		}}, vars.FromPtr(&x))
+	ev.ExtendGlobal(BuildNs().AddVar("x", v))
+
+	var changes [][2]any
+	if err := ev.OnOptionChange("x", func(old, new any) {
+		changes = append(changes, [2]any{old, new})
+	}); err != nil {
+		t.Fatalf("OnOptionChange: %v", err)
+	}
+
+	if err := ev.SetOption("x", "foo"); err != nil {
+		t.Errorf("SetOption: %v", err)
+	}
+	// Setting the variable also calls the hook.
+	if err := ev.Eval(parse.Source{Name: "[test]", Code: "set x = bar"}, EvalCfg{}); err != nil {
+		t.Errorf("Eval: %v", err)
+	}
+	if err := ev.SetOption("x", "bad"); !errors.Is(err, errBad) {
+		t.Errorf("SetOption with invalid value: got %v, want %v", err, errBad)
+	}
+	if got, err := ev.Option("x"); got != "bar" || err != nil {
+		t.Errorf("Option -> (%v, %v), want (bar, nil)", got, err)
+	}
+	if x != "bar" {
+		t.Errorf("backing variable is %q, want bar", x)
+	}
+	if len(changes) != 2 || changes[0] != [2]any{"default", "foo"} || changes[1] != [2]any{"foo", "bar"} {
+		t.Errorf("got changes %v", changes)
+	}
+
+	if _, err := ev.Option("y"); err != (NoSuchOption{"y"}) {
+		t.Errorf("Option of nonexistent option: got %v", err)
+	}
+	if err := ev.OnOptionChange("y", func(old, new any) {}); err != (NoSuchOption{"y"}) {
+		t.Errorf("OnOptionChange of nonexistent option: got %v", err)
+	}
+}
@@ -10,7 +10,8 @@ import (
 var Args = tt.Args
 
 type opts struct {
-	Foo string
+	Foo     string
+	FooJSON string `name:"foo-json"`
 }
 
 // Equal is required by cmp.Diff, since opts contains unexported fields.
@@ -28,6 +29,8 @@ func TestScanOptions(t *testing.T) {
 	tt.Test(t, tt.Fn(wrapper).Named("scanOptions"),
 		Args(RawOptions{"foo": "lorem ipsum"}, opts{}).
 			Rets(opts{Foo: "lorem ipsum"}, nil),
+		Args(RawOptions{"foo-json": "lorem ipsum"}, opts{}).
+			Rets(opts{FooJSON: "lorem ipsum"}, nil),
 		Args(RawOptions{"bar": 20}, opts{}).
 			Rets(opts{}, UnknownOption{"bar"}),
 	)
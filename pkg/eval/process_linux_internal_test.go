@@ -0,0 +1,159 @@
+//go:build linux
+
+package eval
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/testutil"
+)
+
+// The tests below run Elvish code in a child process whose controlling
+// terminal is a pty, so that job control is enabled.
+
+var jobControlInPtyTests = []struct {
+	name string
+	code string
+	// Input to write after the output contains the first string, and the
+	// output expected after that.
+	wantBefore string
+	input      string
+	wantAfter  string
+}{
+	{
+		name:       "code after an external command can read from the terminal",
+		code:       "fn f { /bin/true; echo reading; echo got: (read-line) }; f",
+		wantBefore: "reading",
+		input:      "hello\n",
+		wantAfter:  "got: hello",
+	},
+	{
+		name:       "code after an external command can be interrupted",
+		code:       "fn f { /bin/true; echo looping; while $true { } }; f",
+		wantBefore: "looping",
+		input:      "\x03",
+		wantAfter:  "result: interrupted",
+	},
+}
+
+func TestJobControlInPty(t *testing.T) {
+	for _, test := range jobControlInPtyTests {
+		t.Run(test.name, func(t *testing.T) {
+			pty := runInPty(t, test.code)
+			pty.waitForOutput(t, test.wantBefore)
+			pty.master.WriteString(test.input)
+			pty.waitForOutput(t, test.wantAfter)
+		})
+	}
+}
+
+// Not a real test; run by runInPty in the child process.
+func TestJobControlInPtyHelper(t *testing.T) {
+	code := os.Getenv("ELVISH_TEST_PTY_CODE")
+	if code == "" {
+		return
+	}
+	ports, cleanupPorts := PortsFromStdFiles("")
+	defer cleanupPorts()
+	intCtx, cleanupInt := ListenInterrupts()
+	defer cleanupInt()
+	err := NewEvaler().Eval(parse.Source{Name: "[test]", Code: code},
+		EvalCfg{Ports: ports, Interrupts: intCtx, PutInFg: true})
+	if exc, ok := err.(Exception); ok {
+		err = exc.Reason()
+	}
+	fmt.Println("result:", err)
+}
+
+type ptyChild struct {
+	master *os.File
+	mu     sync.Mutex
+	output bytes.Buffer
+}
+
+func runInPty(t *testing.T, code string) *ptyChild {
+	t.Helper()
+	master, slave := openPty(t)
+	proc, err := os.StartProcess(os.Args[0],
+		[]string{os.Args[0], "-test.run=^TestJobControlInPtyHelper$"},
+		&os.ProcAttr{
+			Env:   append(os.Environ(), "ELVISH_TEST_PTY_CODE="+code),
+			Files: []*os.File{slave, slave, slave},
+			Sys:   &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 0},
+		})
+	slave.Close()
+	if err != nil {
+		master.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		proc.Kill()
+		proc.Wait()
+		master.Close()
+	})
+
+	c := &ptyChild{master: master}
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, err := master.Read(buf)
+			c.mu.Lock()
+			c.output.Write(buf[:n])
+			c.mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return c
+}
+
+func (c *ptyChild) waitForOutput(t *testing.T, want string) {
+	t.Helper()
+	deadline := time.Now().Add(testutil.Scaled(5 * time.Second))
+	for {
+		c.mu.Lock()
+		output := c.output.String()
+		c.mu.Unlock()
+		if strings.Contains(output, want) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("output doesn't contain %q, got:\n%s", want, output)
+		}
+		time.Sleep(testutil.Scaled(10 * time.Millisecond))
+	}
+}
+
+func openPty(t *testing.T) (master, slave *os.File) {
+	t.Helper()
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Skip("can't open pty:", err)
+	}
+	fd := int(master.Fd())
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		master.Close()
+		t.Skip("can't unlock pty:", err)
+	}
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		master.Close()
+		t.Skip("can't get pty number:", err)
+	}
+	slave, err = os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		t.Skip("can't open pty slave:", err)
+	}
+	return master, slave
+}
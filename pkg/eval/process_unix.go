@@ -3,8 +3,13 @@
 package eval
 
 import (
+	"errors"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
 	"syscall"
 
 	"src.elv.sh/pkg/sys"
@@ -28,3 +33,222 @@ func putSelfInFg() error {
 func makeSysProcAttr(bg bool) *syscall.SysProcAttr {
 	return &syscall.SysProcAttr{Setpgid: bg}
 }
+
+// Whether foreground pipelines can be put in their own process groups, which
+// requires a terminal.
+func canUseJobControl() bool {
+	return sys.IsATTY(os.Stdin.Fd())
+}
+
+// A process group for the external commands in a pipeline.
+//
+// The first command started creates the group, and the other ones join it. If
+// fg is true, the group is given control of the terminal whenever a command is
+// started in it, and Elvish takes it back as soon as no command in the group is
+// running, so that code running between two external commands (such as the
+// rest of a function) can use the terminal.
+type procGroup struct {
+	fg       bool
+	children *childGroups
+	mu       sync.Mutex
+	pgid     int
+	// Number of processes that have been started and have neither exited nor
+	// stopped.
+	running int
+	// PIDs of the processes that have stopped.
+	stopped []int
+	// Names of the commands that have been started, used when they are
+	// waited for again after being resumed.
+	names map[int]string
+}
+
+func newProcGroup(children *childGroups) *procGroup {
+	return &procGroup{fg: true, children: children}
+}
+
+// Creates a process group for the external commands in a background job, which
+// is not given control of the terminal.
+func newBgProcGroup(children *childGroups) *procGroup {
+	return &procGroup{children: children}
+}
+
+// Creates a process group for the external commands run by timeout. It is
+// given control of the terminal if the parent group would be.
+func newSubProcGroup(parent *procGroup, children *childGroups) *procGroup {
+	return &procGroup{fg: parent != nil && parent.fg, children: children}
+}
+
+func (pg *procGroup) start(path string, args []string, files []*os.File) (*os.Process, error) {
+	pg.mu.Lock()
+	defer pg.mu.Unlock()
+	if pg.pgid != 0 {
+		proc, err := os.StartProcess(path, args, &os.ProcAttr{
+			Files: files, Sys: pg.sysProcAttr(pg.pgid)})
+		// Joining the group can fail with EPERM if all the processes in it
+		// have exited; create a new group in that case.
+		if !errors.Is(err, syscall.EPERM) {
+			pg.addStarted(proc, path)
+			return proc, err
+		}
+	}
+	proc, err := os.StartProcess(path, args, &os.ProcAttr{
+		Files: files, Sys: pg.sysProcAttr(0)})
+	if err == nil {
+		if pg.pgid != 0 {
+			pg.children.remove(pg.pgid)
+		}
+		pg.pgid = proc.Pid
+		pg.children.add(pg.pgid)
+	}
+	pg.addStarted(proc, path)
+	return proc, err
+}
+
+// Returns the attributes for starting a process in the group with the given
+// ID, or a new group if pgid is 0. Elvish may have taken back the terminal
+// since the group was created, so it is given to the group again every time.
+func (pg *procGroup) sysProcAttr(pgid int) *syscall.SysProcAttr {
+	attr := &syscall.SysProcAttr{Setpgid: true, Pgid: pgid}
+	if pg.fg {
+		attr.Foreground = true
+		attr.Ctty = int(os.Stdin.Fd())
+	}
+	return attr
+}
+
+// Must be called with pg.mu held.
+func (pg *procGroup) addStarted(proc *os.Process, path string) {
+	if proc == nil {
+		return
+	}
+	pg.running++
+	if pg.names == nil {
+		pg.names = make(map[int]string)
+	}
+	pg.names[proc.Pid] = filepath.Base(path)
+}
+
+// Returns the name of the command with the given PID.
+func (pg *procGroup) name(pid int) string {
+	pg.mu.Lock()
+	defer pg.mu.Unlock()
+	if name, ok := pg.names[pid]; ok {
+		return name
+	}
+	return "[pid " + strconv.Itoa(pid) + "]"
+}
+
+// Waits for a process in the group to either exit or stop. If it is stopped,
+// for example by Ctrl-Z, or it was the last running process in the group,
+// Elvish takes back control of the terminal.
+func (pg *procGroup) wait(proc *os.Process) (syscall.WaitStatus, error) {
+	var ws syscall.WaitStatus
+	for {
+		_, err := syscall.Wait4(proc.Pid, &ws, syscall.WUNTRACED, nil)
+		if err == syscall.EINTR {
+			continue
+		} else if err != nil {
+			return ws, err
+		}
+		break
+	}
+	pg.mu.Lock()
+	defer pg.mu.Unlock()
+	pg.running--
+	if ws.Stopped() {
+		pg.stopped = append(pg.stopped, proc.Pid)
+	} else {
+		proc.Release()
+	}
+	// This is done with pg.mu held, so that it can't take the terminal away
+	// from a process that is being started concurrently.
+	if pg.fg && (ws.Stopped() || pg.running == 0) {
+		putSelfInFg()
+	}
+	return ws, nil
+}
+
+// Puts Elvish back in the foreground if the group has taken control of the
+// terminal. The group is no longer tracked unless some process in it has been
+// stopped.
+func (pg *procGroup) done() {
+	pg.mu.Lock()
+	defer pg.mu.Unlock()
+	if pg.pgid == 0 {
+		return
+	}
+	if len(pg.stopped) == 0 {
+		pg.children.remove(pg.pgid)
+	}
+	if pg.fg {
+		putSelfInFg()
+	}
+}
+
+func (pg *procGroup) getPgid() int {
+	pg.mu.Lock()
+	defer pg.mu.Unlock()
+	return pg.pgid
+}
+
+func (pg *procGroup) stoppedPids() []int {
+	pg.mu.Lock()
+	defer pg.mu.Unlock()
+	return slices.Clone(pg.stopped)
+}
+
+// Continues the stopped processes in the group, and returns their PIDs. The
+// caller is responsible for giving the group control of the terminal if
+// needed, so the group no longer does that itself.
+func (pg *procGroup) resume() []int {
+	pg.mu.Lock()
+	defer pg.mu.Unlock()
+	pg.fg = false
+	pids := pg.stopped
+	pg.stopped = nil
+	pg.running += len(pids)
+	syscall.Kill(-pg.pgid, syscall.SIGCONT)
+	return pids
+}
+
+// Gives control of the terminal to a process group.
+func putInFg(pgid int) error {
+	if !sys.IsATTY(os.Stdin.Fd()) {
+		return nil
+	}
+	signal.Ignore(syscall.SIGTTOU)
+	defer signal.Reset(syscall.SIGTTOU)
+	return eunix.Tcsetpgrp(0, pgid)
+}
+
+// Sends SIGTERM, or SIGKILL if force is true, to the processes in the group.
+func (pg *procGroup) terminate(force bool) {
+	pg.mu.Lock()
+	defer pg.mu.Unlock()
+	if pg.pgid == 0 {
+		return
+	}
+	sig := syscall.SIGTERM
+	if force {
+		sig = syscall.SIGKILL
+	}
+	syscall.Kill(-pg.pgid, sig)
+	// Stopped processes only handle SIGTERM after they are continued.
+	syscall.Kill(-pg.pgid, syscall.SIGCONT)
+}
+
+func hangUp(pgid int) {
+	syscall.Kill(-pgid, syscall.SIGHUP)
+	// Stopped processes only handle SIGHUP after they are continued.
+	syscall.Kill(-pgid, syscall.SIGCONT)
+}
+
+// Returns the process group ID of a process, or pid itself if it can't be
+// determined.
+func pgidOf(pid int) int {
+	pgid, err := syscall.Getpgid(pid)
+	if err != nil {
+		return pid
+	}
+	return pgid
+}
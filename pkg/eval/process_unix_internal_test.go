@@ -0,0 +1,186 @@
+//go:build unix
+
+package eval
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestProcGroup_PutsProcessesInSameGroup(t *testing.T) {
+	pg := &procGroup{children: new(childGroups)}
+	files := []*os.File{os.Stdin, os.Stdout, os.Stderr}
+
+	sleep := startForTest(t, pg, "/bin/sh", "-c", "exec sleep 10")
+	defer sleep.Kill()
+	sh := startForTest(t, pg, "/bin/sh", "-c", "exit 3")
+
+	if pgid := mustGetpgid(t, sleep.Pid); pgid != sleep.Pid {
+		t.Errorf("first process has pgid %d, want its own pid %d", pgid, sleep.Pid)
+	}
+	if pgid := mustGetpgid(t, sh.Pid); pgid != sleep.Pid {
+		t.Errorf("second process has pgid %d, want %d", pgid, sleep.Pid)
+	}
+	ws, err := pg.wait(sh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ws.Exited() || ws.ExitStatus() != 3 {
+		t.Errorf("got wait status %v, want exit status 3", ws)
+	}
+
+	// After all processes in the group have exited, a new group is created.
+	sleep.Kill()
+	pg.wait(sleep)
+	proc, err := pg.start("/bin/sh", []string{"/bin/sh", "-c", "exit 0"}, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pg.pgid != proc.Pid {
+		t.Errorf("pg.pgid = %d, want new pid %d", pg.pgid, proc.Pid)
+	}
+	pg.wait(proc)
+}
+
+func TestProcGroup_WaitReturnsWhenStopped(t *testing.T) {
+	pg := &procGroup{children: new(childGroups)}
+	proc := startForTest(t, pg, "/bin/sh", "-c", "kill -STOP $$; exit 0")
+	defer proc.Kill()
+
+	ws, err := pg.wait(proc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ws.Stopped() || ws.StopSignal() != syscall.SIGSTOP {
+		t.Errorf("got wait status %v, want stopped by SIGSTOP", ws)
+	}
+	err = NewExternalCmdExit("sh", ws, proc.Pid)
+	if _, ok := err.(ExternalCmdExit); !ok {
+		t.Errorf("got error %v, want ExternalCmdExit", err)
+	}
+
+	// The process can be continued and waited for again.
+	proc.Signal(syscall.SIGCONT)
+	ws, err = pg.wait(proc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ws.Exited() || ws.ExitStatus() != 0 {
+		t.Errorf("got wait status %v, want exit status 0", ws)
+	}
+}
+
+func startForTest(t *testing.T, pg *procGroup, args ...string) *os.Process {
+	t.Helper()
+	files := []*os.File{os.Stdin, os.Stdout, os.Stderr}
+	proc, err := pg.start(args[0], args, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return proc
+}
+
+func mustGetpgid(t *testing.T, pid int) int {
+	t.Helper()
+	pgid, err := syscall.Getpgid(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pgid
+}
+
+func TestHangUpChildren(t *testing.T) {
+	ev := NewEvaler()
+	// The background job is started asynchronously, so wait for it to be
+	// tracked.
+	err := ev.Eval(parse.Source{Name: "[test]", Code: "/bin/sh -c 'exec sleep 10' &"}, EvalCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForChildren(t, ev, 1)
+
+	ev.HangUpChildren()
+	waitForChildren(t, ev, 0)
+
+	// Disowned children are not hung up.
+	err = ev.Eval(parse.Source{Name: "[test]", Code: "/bin/sh -c 'exec sleep 10' &"}, EvalCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pgids := waitForChildren(t, ev, 1)
+	defer syscall.Kill(-pgids[0], syscall.SIGKILL)
+	ev.children.disownAll()
+	ev.HangUpChildren()
+	time.Sleep(testutil.Scaled(10 * time.Millisecond))
+	if err := syscall.Kill(-pgids[0], 0); err != nil {
+		t.Errorf("disowned child was hung up")
+	}
+}
+
+// Waits until n child process groups are tracked, and returns them.
+func waitForChildren(t *testing.T, ev *Evaler, n int) []int {
+	t.Helper()
+	deadline := time.Now().Add(testutil.Scaled(time.Second))
+	for {
+		ev.children.mu.Lock()
+		pgids := make([]int, 0, len(ev.children.m))
+		for pgid := range ev.children.m {
+			pgids = append(pgids, pgid)
+		}
+		ev.children.mu.Unlock()
+		if len(pgids) == n {
+			return pgids
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d child process groups, want %d", len(pgids), n)
+		}
+		time.Sleep(testutil.Scaled(time.Millisecond))
+	}
+}
+
+func TestResumeJob(t *testing.T) {
+	ev := NewEvaler()
+	notes := make(chan string, 10)
+	ev.BgJobNotify = func(s string) { notes <- s }
+	pg := newProcGroup(&ev.children)
+	// Don't take control of the terminal in tests.
+	pg.fg = false
+	proc := startForTest(t, pg, "/bin/sh", "-c", "kill -STOP $$; exit 3")
+	defer proc.Kill()
+	if ws, _ := pg.wait(proc); !ws.Stopped() {
+		t.Fatalf("got wait status %v, want stopped", ws)
+	}
+	pg.done()
+	ev.jobs.addStopped("sh", pg, pg.stoppedPids())
+
+	jobs, err := ev.jobs.find(nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := jobs[0]
+	if found, _ := ev.jobs.find([]int{proc.Pid}, false); len(found) != 1 || found[0] != j {
+		t.Errorf("job not found by PID")
+	}
+
+	<-ev.resumeJob(j, true)
+	if note := <-notes; note != "job sh finished, errors = sh exited with 3" {
+		t.Errorf("got notification %q", note)
+	}
+	if exc, ok := ev.jobs.lastErr(j).(Exception); !ok || !isExitStatus(exc.Reason(), 3) {
+		t.Errorf("got error %v, want exit status 3", ev.jobs.lastErr(j))
+	}
+	if list := ev.jobs.list(); len(list) != 0 {
+		t.Errorf("got jobs %v after the job finished, want none", list)
+	}
+	waitForChildren(t, ev, 0)
+}
+
+func isExitStatus(err error, status int) bool {
+	exit, ok := err.(ExternalCmdExit)
+	return ok && exit.Exited() && exit.ExitStatus() == status
+}
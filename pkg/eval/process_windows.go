@@ -1,6 +1,10 @@
 package eval
 
-import "syscall"
+import (
+	"os"
+	"sync"
+	"syscall"
+)
 
 // Nop on Windows.
 func putSelfInFg() error { return nil }
@@ -15,3 +19,73 @@ func makeSysProcAttr(bg bool) *syscall.SysProcAttr {
 	}
 	return &syscall.SysProcAttr{CreationFlags: flags}
 }
+
+// Job control is not supported on Windows.
+func canUseJobControl() bool { return false }
+
+// Only created by timeout on Windows, since canUseJobControl always returns
+// false. Windows has no process groups, so it keeps track of the processes
+// directly.
+type procGroup struct {
+	mu    sync.Mutex
+	procs map[*os.Process]bool
+}
+
+func newProcGroup(*childGroups) *procGroup { return &procGroup{} }
+
+func newSubProcGroup(*procGroup, *childGroups) *procGroup { return &procGroup{} }
+
+func (pg *procGroup) start(path string, args []string, files []*os.File) (*os.Process, error) {
+	proc, err := os.StartProcess(path, args, &os.ProcAttr{Files: files, Sys: makeSysProcAttr(false)})
+	if err == nil {
+		pg.mu.Lock()
+		defer pg.mu.Unlock()
+		if pg.procs == nil {
+			pg.procs = make(map[*os.Process]bool)
+		}
+		pg.procs[proc] = true
+	}
+	return proc, err
+}
+
+func (pg *procGroup) wait(proc *os.Process) (syscall.WaitStatus, error) {
+	state, err := proc.Wait()
+	pg.mu.Lock()
+	delete(pg.procs, proc)
+	pg.mu.Unlock()
+	if err != nil {
+		return syscall.WaitStatus{}, err
+	}
+	return state.Sys().(syscall.WaitStatus), nil
+}
+
+// Kills the processes in the group. Windows has no equivalent of SIGTERM, so
+// force is ignored.
+func (pg *procGroup) terminate(force bool) {
+	pg.mu.Lock()
+	defer pg.mu.Unlock()
+	for proc := range pg.procs {
+		proc.Kill()
+	}
+}
+
+func (pg *procGroup) done() {}
+
+func newBgProcGroup(*childGroups) *procGroup { return &procGroup{} }
+
+func (pg *procGroup) getPgid() int { return 0 }
+
+// Processes are never stopped on Windows.
+func (pg *procGroup) stoppedPids() []int { return nil }
+
+func (pg *procGroup) resume() []int { return nil }
+
+func (pg *procGroup) name(int) string { return "" }
+
+// Nop on Windows.
+func putInFg(int) error { return nil }
+
+// Nop on Windows.
+func hangUp(int) {}
+
+func pgidOf(pid int) int { return pid }
@@ -0,0 +1,207 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"src.elv.sh/pkg/env"
+	"src.elv.sh/pkg/parse"
+)
+
+// Restrictions configure the restricted mode of an Evaler, used for kiosk
+// accounts and constrained automation.
+type Restrictions struct {
+	// External commands that can be run. Names without a path separator are
+	// looked up in $E:PATH when the restrictions are applied; the commands
+	// are later matched by their absolute paths, so changing $E:PATH doesn't
+	// allow running other commands with the same names.
+	Externals []string
+	// If not empty, the working directory can't be changed to outside this
+	// directory.
+	Root string
+	// Directories in which files can be created, written or removed.
+	Writable []string
+}
+
+// RestrictedError is returned when an operation is not allowed in restricted
+// mode.
+type RestrictedError struct {
+	// What is not allowed, like "run external command".
+	Action string
+	Path   string
+}
+
+func (e RestrictedError) Error() string {
+	return fmt.Sprintf("restricted mode: cannot %s %s", e.Action, parse.Quote(e.Path))
+}
+
+// Restrictions of an Evaler, with all paths absolute.
+type restrictions struct {
+	externals []string
+	root      string
+	writable  []string
+}
+
+// Restrict puts the Evaler in restricted mode. If it is already in restricted
+// mode, the restrictions are combined, so that nothing that was not allowed
+// becomes allowed: externals and writable directories not allowed by the
+// current restrictions are ignored, and the root must be within the current
+// root.
+//
+// If the working directory is outside the root, it is changed to the root.
+func (ev *Evaler) Restrict(r Restrictions) error {
+	var newR restrictions
+	for _, name := range r.Externals {
+		if path, err := externalPath(name); err == nil {
+			newR.externals = append(newR.externals, path)
+		}
+	}
+	if r.Root != "" {
+		root, err := resolvePath(r.Root)
+		if err != nil {
+			return err
+		}
+		newR.root = root
+	}
+	for _, dir := range r.Writable {
+		dir, err := resolvePath(dir)
+		if err != nil {
+			return err
+		}
+		newR.writable = append(newR.writable, dir)
+	}
+
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	if old := ev.restrictions; old != nil {
+		newR.externals = slices.DeleteFunc(newR.externals, func(path string) bool {
+			return !slices.Contains(old.externals, path)
+		})
+		if newR.root == "" {
+			newR.root = old.root
+		} else if old.root != "" && !isWithin(newR.root, old.root) {
+			return RestrictedError{"use root", r.Root}
+		}
+		newR.writable = slices.DeleteFunc(newR.writable, func(dir string) bool {
+			return !isWithinAny(dir, old.writable)
+		})
+	}
+	if newR.root != "" {
+		if wd, err := resolvePath("."); err != nil || !isWithin(wd, newR.root) {
+			if err := os.Chdir(newR.root); err != nil {
+				return err
+			}
+			os.Setenv(env.PWD, newR.root)
+		}
+	}
+	ev.restrictions = &newR
+	return nil
+}
+
+// IsRestricted returns whether the Evaler is in restricted mode.
+func (ev *Evaler) IsRestricted() bool {
+	ev.mu.RLock()
+	defer ev.mu.RUnlock()
+	return ev.restrictions != nil
+}
+
+func (ev *Evaler) getRestrictions() *restrictions {
+	ev.mu.RLock()
+	defer ev.mu.RUnlock()
+	return ev.restrictions
+}
+
+// CheckExternal returns a RestrictedError if the Evaler is in restricted mode
+// and the external command at the given path can't be run.
+func (ev *Evaler) CheckExternal(path string) error {
+	r := ev.getRestrictions()
+	if r == nil {
+		return nil
+	}
+	if abs, err := filepath.Abs(path); err == nil && slices.Contains(r.externals, abs) {
+		return nil
+	}
+	return RestrictedError{"run external command", path}
+}
+
+// CheckWrite returns a RestrictedError if the Evaler is in restricted mode and
+// the given path can't be written, created or removed.
+func (ev *Evaler) CheckWrite(path string) error {
+	r := ev.getRestrictions()
+	if r == nil {
+		return nil
+	}
+	if resolved, err := resolvePath(path); err == nil && isWithinAny(resolved, r.writable) {
+		return nil
+	}
+	return RestrictedError{"write to", path}
+}
+
+func (ev *Evaler) checkChdir(path string) error {
+	r := ev.getRestrictions()
+	if r == nil || r.root == "" {
+		return nil
+	}
+	if resolved, err := resolvePath(path); err == nil && isWithin(resolved, r.root) {
+		return nil
+	}
+	return RestrictedError{"change directory to", path}
+}
+
+// Returns the absolute path of an external command.
+func externalPath(name string) (string, error) {
+	if !strings.ContainsRune(name, filepath.Separator) && !strings.ContainsRune(name, '/') {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			return "", err
+		}
+		name = path
+	}
+	return filepath.Abs(name)
+}
+
+// Returns the absolute path with all symlinks resolved. If the path doesn't
+// exist, symlinks are resolved in the longest prefix that exists.
+func resolvePath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	var rest []string
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", err
+		}
+		// Prepend the base name.
+		rest = append([]string{filepath.Base(path)}, rest...)
+		path = parent
+	}
+}
+
+// Returns whether path is dir or inside it. Both must be absolute and clean.
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && (rel == "." || filepath.IsLocal(rel))
+}
+
+func isWithinAny(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if isWithin(path, dir) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+//go:build unix
+
+package eval
+
+import (
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// Resource usage of the Elvish process and the child processes it has waited
+// for, used by time &stats.
+type rusage struct {
+	user, sys time.Duration
+	// The maximum resident set size in bytes.
+	maxRSS int64
+}
+
+func getRusage() (rusage, bool) {
+	var self, children syscall.Rusage
+	if syscall.Getrusage(syscall.RUSAGE_SELF, &self) != nil ||
+		syscall.Getrusage(syscall.RUSAGE_CHILDREN, &children) != nil {
+		return rusage{}, false
+	}
+	maxRSS := max(int64(self.Maxrss), int64(children.Maxrss))
+	if runtime.GOOS != "darwin" {
+		// ru_maxrss is in kilobytes except on macOS.
+		maxRSS *= 1024
+	}
+	return rusage{
+		user:   timevalDuration(self.Utime) + timevalDuration(children.Utime),
+		sys:    timevalDuration(self.Stime) + timevalDuration(children.Stime),
+		maxRSS: maxRSS,
+	}, true
+}
+
+func timevalDuration(tv syscall.Timeval) time.Duration {
+	return time.Duration(tv.Nano())
+}
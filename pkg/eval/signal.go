@@ -0,0 +1,139 @@
+package eval
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"src.elv.sh/pkg/diag"
+)
+
+// Signal handlers set by Elvish code.
+type signalHandlers struct {
+	mu  sync.Mutex
+	fns map[os.Signal]Callable
+	// The number of active calls to DeferSignals, and the signals received
+	// while it is positive.
+	deferring int
+	pending   []os.Signal
+}
+
+// SetSignalHandler sets the function to call when the signal is received, or
+// removes the handler if fn is nil.
+//
+// The Evaler doesn't listen to signals itself; handlers are only called when
+// the signals are passed to [Evaler.HandleSignal].
+func (ev *Evaler) SetSignalHandler(sig os.Signal, fn Callable) {
+	sh := &ev.signals
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if fn == nil {
+		delete(sh.fns, sig)
+		return
+	}
+	if sh.fns == nil {
+		sh.fns = make(map[os.Signal]Callable)
+	}
+	sh.fns[sig] = fn
+}
+
+func (ev *Evaler) signalHandler(sig os.Signal) Callable {
+	sh := &ev.signals
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	return sh.fns[sig]
+}
+
+// HandleSignal calls the handler of the signal with the given standard files,
+// and returns whether there is a handler. If signals are being deferred with
+// [Evaler.DeferSignals], the handler is called later instead.
+//
+// Errors thrown by the handler are written to files[2].
+func (ev *Evaler) HandleSignal(sig os.Signal, files [3]*os.File) bool {
+	sh := &ev.signals
+	sh.mu.Lock()
+	fn := sh.fns[sig]
+	if fn != nil && sh.deferring > 0 {
+		sh.pending = append(sh.pending, sig)
+		sh.mu.Unlock()
+		return true
+	}
+	sh.mu.Unlock()
+	if fn == nil {
+		return false
+	}
+	ev.callSignalHandler(sig, fn, files)
+	return true
+}
+
+// DeferSignals defers calling the handlers of signals until the returned
+// function is called, which calls the handlers of the signals received in the
+// meantime with the given standard files. Calls can be nested, in which case
+// the handlers are called when the outermost one ends.
+func (ev *Evaler) DeferSignals() func(files [3]*os.File) {
+	sh := &ev.signals
+	sh.mu.Lock()
+	sh.deferring++
+	sh.mu.Unlock()
+	return func(files [3]*os.File) {
+		sh.mu.Lock()
+		sh.deferring--
+		var pending []os.Signal
+		if sh.deferring == 0 {
+			pending, sh.pending = sh.pending, nil
+		}
+		sh.mu.Unlock()
+		for _, sig := range pending {
+			// The handler may have been removed in the meantime.
+			if fn := ev.signalHandler(sig); fn != nil {
+				ev.callSignalHandler(sig, fn, files)
+			}
+		}
+	}
+}
+
+func (ev *Evaler) callSignalHandler(sig os.Signal, fn Callable, files [3]*os.File) {
+	ports, cleanup := PortsFromFiles(files, ev.ValuePrefix())
+	defer cleanup()
+	err := ev.Call(fn, CallCfg{From: "[signal " + sig.String() + "]"}, EvalCfg{Ports: ports})
+	if err != nil {
+		diag.ShowError(files[2], err)
+	}
+}
+
+// ListenInterrupts is like the function [ListenInterrupts], except that SIGINT
+// doesn't cancel the Context while it has a handler set with
+// [Evaler.SetSignalHandler].
+func (ev *Evaler) ListenInterrupts() (context.Context, func()) {
+	return listenInterrupts(func(sig os.Signal) bool {
+		return sig == syscall.SIGINT && ev.signalHandler(sig) != nil
+	})
+}
+
+func listenInterrupts(ignore func(os.Signal) bool) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGQUIT)
+
+	go func() {
+		defer signal.Stop(sigCh)
+		for {
+			select {
+			case sig := <-sigCh:
+				if !ignore(sig) {
+					cancel()
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ctx, func() {
+		cancel()
+	}
+}
@@ -0,0 +1,66 @@
+package eval_test
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+
+	. "src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/must"
+)
+
+func TestHandleSignal(t *testing.T) {
+	ev := NewEvaler()
+	var calls []string
+	handler := func(name string) Callable {
+		return NewGoFn(name, func() { calls = append(calls, name) })
+	}
+	files := [3]*os.File{DevNull, DevNull, DevNull}
+
+	if ev.HandleSignal(syscall.SIGINT, files) {
+		t.Errorf("HandleSignal returns true without a handler")
+	}
+
+	ev.SetSignalHandler(syscall.SIGINT, handler("int"))
+	ev.SetSignalHandler(syscall.SIGTERM, handler("term"))
+	if !ev.HandleSignal(syscall.SIGINT, files) {
+		t.Errorf("HandleSignal returns false with a handler")
+	}
+	if want := []string{"int"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("got calls %v, want %v", calls, want)
+	}
+
+	calls = nil
+	resume := ev.DeferSignals()
+	resumeInner := ev.DeferSignals()
+	ev.HandleSignal(syscall.SIGTERM, files)
+	ev.HandleSignal(syscall.SIGINT, files)
+	resumeInner(files)
+	if len(calls) > 0 {
+		t.Errorf("handlers called while signals are deferred: %v", calls)
+	}
+	resume(files)
+	if want := []string{"term", "int"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("got calls %v, want %v", calls, want)
+	}
+
+	ev.SetSignalHandler(syscall.SIGINT, nil)
+	if ev.HandleSignal(syscall.SIGINT, files) {
+		t.Errorf("HandleSignal returns true after the handler is removed")
+	}
+}
+
+func TestHandleSignal_ShowsError(t *testing.T) {
+	ev := NewEvaler()
+	ev.SetSignalHandler(syscall.SIGTERM,
+		NewGoFn("f", func() error { return errors.New("bad handler") }))
+	r, w := must.Pipe()
+	ev.HandleSignal(syscall.SIGTERM, [3]*os.File{DevNull, DevNull, w})
+	w.Close()
+	if stderr := string(must.ReadAllAndClose(r)); !strings.Contains(stderr, "bad handler") {
+		t.Errorf("stderr is %q, want it to contain %q", stderr, "bad handler")
+	}
+}
@@ -8,6 +8,7 @@ var (
 	TimeAfter     = &timeAfter
 	TimeNow       = &timeNow
 	NextEvalCount = &nextEvalCount
+	ElvishVersion = &elvishVersion
 
 	ExceptionCauseStartMarker = &exceptionCauseStartMarker
 	ExceptionCauseEndMarker   = &exceptionCauseEndMarker
@@ -80,6 +80,16 @@ func TestTranscripts(t *testing.T) {
 			ev.ExtendGlobal(eval.BuildNs().
 				AddVar("lib", vars.NewReadOnly(libdir)))
 		},
+		"tmp-autoload-dir", func(t *testing.T, ev *eval.Evaler) {
+			dir := testutil.TempDir(t)
+			ev.AutoloadDirs = []string{dir}
+			ev.ExtendGlobal(eval.BuildNs().
+				AddVar("autoload", vars.NewReadOnly(dir)))
+		},
+		"add-bundled-module", func(ev *eval.Evaler, arg string) {
+			name, code, _ := strings.Cut(arg, " ")
+			ev.BundledModules[name] = code
+		},
 		"two-tmp-lib-dirs", func(t *testing.T, ev *eval.Evaler) {
 			libdir1 := testutil.TempDir(t)
 			libdir2 := testutil.TempDir(t)
@@ -107,6 +117,9 @@ func TestTranscripts(t *testing.T) {
 			c := must.OK1(strconv.Atoi(arg))
 			testutil.Set(t, eval.NextEvalCount, func() int { return c })
 		},
+		"mock-elvish-version", func(t *testing.T, arg string) {
+			testutil.Set(t, eval.ElvishVersion, arg)
+		},
 		"mock-time-after", func(t *testing.T) {
 			testutil.Set(t, eval.TimeAfter,
 				func(fm *eval.Frame, d time.Duration) <-chan time.Time {
@@ -0,0 +1,424 @@
+package vals
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"unicode/utf8"
+)
+
+// CBOR major types, as defined in RFC 8949.
+const (
+	cborUint byte = iota
+	cborNegInt
+	cborBytes
+	cborText
+	cborArray
+	cborMap
+	cborTag
+	cborSimple
+)
+
+// CBOR tags used in the encoding of numbers.
+const (
+	cborTagPosBignum = 2
+	cborTagNegBignum = 3
+	cborTagRational  = 30
+)
+
+// The maximum nesting depth of arrays, maps and tags when decoding CBOR.
+const maxCBORDepth = 1000
+
+var (
+	errCBORBreak       = errors.New("unexpected CBOR break")
+	errCBORTooDeep     = errors.New("CBOR data nested too deeply")
+	errCBORBadRational = errors.New("malformed CBOR rational number")
+)
+
+// AppendCBOR appends the CBOR encoding of v to buf. Numbers are encoded with
+// their underlying representation preserved: integers as CBOR integers or
+// bignums, rationals with the rational number tag (30), and floating-point
+// numbers as CBOR floats. Strings are encoded as text strings if they are
+// valid UTF-8, and byte strings otherwise.
+func AppendCBOR(buf []byte, v any) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(buf, cborSimple<<5|22), nil
+	case bool:
+		if v {
+			return append(buf, cborSimple<<5|21), nil
+		}
+		return append(buf, cborSimple<<5|20), nil
+	case string:
+		major := cborText
+		if !utf8.ValidString(v) {
+			major = cborBytes
+		}
+		return append(appendCBORHead(buf, major, uint64(len(v))), v...), nil
+	case int:
+		if v >= 0 {
+			return appendCBORHead(buf, cborUint, uint64(v)), nil
+		}
+		return appendCBORHead(buf, cborNegInt, uint64(-1-v)), nil
+	case *big.Int:
+		return appendCBORBigInt(buf, v), nil
+	case *big.Rat:
+		buf = appendCBORHead(buf, cborTag, cborTagRational)
+		buf = appendCBORHead(buf, cborArray, 2)
+		buf = appendCBORBigInt(buf, v.Num())
+		return appendCBORBigInt(buf, v.Denom()), nil
+	case float64:
+		if f32 := float32(v); float64(f32) == v {
+			buf = append(buf, cborSimple<<5|26)
+			return binary.BigEndian.AppendUint32(buf, math.Float32bits(f32)), nil
+		}
+		buf = append(buf, cborSimple<<5|27)
+		return binary.BigEndian.AppendUint64(buf, math.Float64bits(v)), nil
+	}
+
+	switch Kind(v) {
+	case "list":
+		buf = appendCBORHead(buf, cborArray, uint64(Len(v)))
+		var err error
+		errIterate := Iterate(v, func(elem any) bool {
+			buf, err = AppendCBOR(buf, elem)
+			return err == nil
+		})
+		if errIterate != nil {
+			return nil, errIterate
+		}
+		return buf, err
+	case "map":
+		var keys []any
+		errIterate := IterateKeys(v, func(k any) bool {
+			keys = append(keys, k)
+			return true
+		})
+		if errIterate != nil {
+			return nil, errIterate
+		}
+		buf = appendCBORHead(buf, cborMap, uint64(len(keys)))
+		for _, k := range keys {
+			elem, err := Index(v, k)
+			if err != nil {
+				return nil, err
+			}
+			if buf, err = AppendCBOR(buf, k); err != nil {
+				return nil, err
+			}
+			if buf, err = AppendCBOR(buf, elem); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	}
+	return nil, fmt.Errorf("cannot encode value of kind %s to CBOR", Kind(v))
+}
+
+func appendCBORBigInt(buf []byte, z *big.Int) []byte {
+	if z.Sign() >= 0 {
+		if z.IsUint64() {
+			return appendCBORHead(buf, cborUint, z.Uint64())
+		}
+		buf = appendCBORHead(buf, cborTag, cborTagPosBignum)
+		return appendCBORBytes(buf, z.Bytes())
+	}
+	// A negative bignum encodes -1-z.
+	m := new(big.Int).Neg(z)
+	m.Sub(m, big.NewInt(1))
+	if m.IsUint64() {
+		return appendCBORHead(buf, cborNegInt, m.Uint64())
+	}
+	buf = appendCBORHead(buf, cborTag, cborTagNegBignum)
+	return appendCBORBytes(buf, m.Bytes())
+}
+
+func appendCBORBytes(buf []byte, b []byte) []byte {
+	return append(appendCBORHead(buf, cborBytes, uint64(len(b))), b...)
+}
+
+func appendCBORHead(buf []byte, major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return append(buf, major<<5|byte(n))
+	case n <= math.MaxUint8:
+		return append(buf, major<<5|24, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, major<<5|25), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(buf, major<<5|26), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(buf, major<<5|27), n)
+	}
+}
+
+// CBORDecoder decodes a sequence of CBOR data items into Elvish values.
+type CBORDecoder struct {
+	r *bufio.Reader
+}
+
+// NewCBORDecoder returns a CBORDecoder reading from r.
+func NewCBORDecoder(r io.Reader) *CBORDecoder {
+	return &CBORDecoder{bufio.NewReader(r)}
+}
+
+// Decode decodes the next data item. It returns io.EOF if there are no more
+// data items, and io.ErrUnexpectedEOF if the input ends in the middle of a
+// data item.
+//
+// Both text and byte strings are decoded as strings. Tags other than those
+// used in the output of AppendCBOR are ignored, and the undefined value is
+// decoded as nil.
+func (d *CBORDecoder) Decode() (any, error) {
+	if _, err := d.r.Peek(1); err != nil {
+		return nil, err
+	}
+	v, err := d.decode(0)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return v, err
+}
+
+func (d *CBORDecoder) decode(depth int) (any, error) {
+	if depth > maxCBORDepth {
+		return nil, errCBORTooDeep
+	}
+	major, info, err := d.readInitial()
+	if err != nil {
+		return nil, err
+	}
+	if major == cborSimple {
+		return d.decodeSimple(info)
+	}
+	if info == 31 {
+		return d.decodeIndefinite(major, depth)
+	}
+	n, err := d.readArgument(info)
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case cborUint:
+		return Uint64ToNum(n), nil
+	case cborNegInt:
+		if n <= math.MaxInt64 {
+			return Int64ToNum(-1 - int64(n)), nil
+		}
+		z := new(big.Int).SetUint64(n)
+		return z.Neg(z.Add(z, big.NewInt(1))), nil
+	case cborBytes, cborText:
+		if n > math.MaxInt64 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		var sb bytes.Buffer
+		if _, err := io.CopyN(&sb, d.r, int64(n)); err != nil {
+			return nil, err
+		}
+		return sb.String(), nil
+	case cborArray:
+		list := EmptyList
+		for i := uint64(0); i < n; i++ {
+			elem, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			list = list.Conj(elem)
+		}
+		return list, nil
+	case cborMap:
+		m := EmptyMap
+		for i := uint64(0); i < n; i++ {
+			k, v, err := d.decodePair(depth)
+			if err != nil {
+				return nil, err
+			}
+			m = m.Assoc(k, v)
+		}
+		return m, nil
+	default: // cborTag
+		content, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return decodeCBORTag(n, content)
+	}
+}
+
+func (d *CBORDecoder) decodePair(depth int) (any, any, error) {
+	k, err := d.decode(depth + 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	v, err := d.decode(depth + 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	return k, v, nil
+}
+
+func (d *CBORDecoder) decodeIndefinite(major byte, depth int) (any, error) {
+	switch major {
+	case cborBytes, cborText:
+		var sb bytes.Buffer
+		for {
+			chunk, err := d.decode(depth + 1)
+			if err == errCBORBreak {
+				return sb.String(), nil
+			} else if err != nil {
+				return nil, err
+			}
+			s, ok := chunk.(string)
+			if !ok {
+				return nil, errors.New("malformed CBOR indefinite-length string")
+			}
+			sb.WriteString(s)
+		}
+	case cborArray:
+		list := EmptyList
+		for {
+			elem, err := d.decode(depth + 1)
+			if err == errCBORBreak {
+				return list, nil
+			} else if err != nil {
+				return nil, err
+			}
+			list = list.Conj(elem)
+		}
+	case cborMap:
+		m := EmptyMap
+		for {
+			k, v, err := d.decodePair(depth)
+			if err == errCBORBreak {
+				return m, nil
+			} else if err != nil {
+				return nil, err
+			}
+			m = m.Assoc(k, v)
+		}
+	default:
+		return nil, fmt.Errorf("malformed CBOR: indefinite length for major type %d", major)
+	}
+}
+
+func (d *CBORDecoder) decodeSimple(info byte) (any, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 25:
+		n, err := d.readArgument(info)
+		return float16ToFloat64(uint16(n)), err
+	case 26:
+		n, err := d.readArgument(info)
+		return float64(math.Float32frombits(uint32(n))), err
+	case 27:
+		n, err := d.readArgument(info)
+		return math.Float64frombits(n), err
+	case 31:
+		return nil, errCBORBreak
+	default:
+		return nil, fmt.Errorf("unsupported CBOR simple value %d", info)
+	}
+}
+
+func decodeCBORTag(tag uint64, content any) (any, error) {
+	switch tag {
+	case cborTagPosBignum, cborTagNegBignum:
+		s, ok := content.(string)
+		if !ok {
+			return nil, errors.New("malformed CBOR bignum")
+		}
+		z := new(big.Int).SetBytes([]byte(s))
+		if tag == cborTagNegBignum {
+			z.Neg(z.Add(z, big.NewInt(1)))
+		}
+		return NormalizeBigInt(z), nil
+	case cborTagRational:
+		list, ok := content.(List)
+		if !ok || list.Len() != 2 {
+			return nil, errCBORBadRational
+		}
+		num, _ := list.Index(0)
+		denom, _ := list.Index(1)
+		if !isInteger(num) || !isInteger(denom) {
+			return nil, errCBORBadRational
+		}
+		d := PromoteToBigInt(denom)
+		if d.Sign() <= 0 {
+			return nil, errCBORBadRational
+		}
+		return NormalizeBigRat(new(big.Rat).SetFrac(PromoteToBigInt(num), d)), nil
+	default:
+		return content, nil
+	}
+}
+
+func isInteger(v any) bool {
+	switch v.(type) {
+	case int, *big.Int:
+		return true
+	}
+	return false
+}
+
+func (d *CBORDecoder) readInitial() (byte, byte, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	return b >> 5, b & 0x1f, nil
+}
+
+func (d *CBORDecoder) readArgument(info byte) (uint64, error) {
+	if info < 24 {
+		return uint64(info), nil
+	}
+	var size int
+	switch info {
+	case 24:
+		size = 1
+	case 25:
+		size = 2
+	case 26:
+		size = 4
+	case 27:
+		size = 8
+	default:
+		return 0, fmt.Errorf("malformed CBOR: reserved additional information %d", info)
+	}
+	var b [8]byte
+	if _, err := io.ReadFull(d.r, b[8-size:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(b[:]), nil
+}
+
+// Converts an IEEE 754 half-precision number to a float64.
+func float16ToFloat64(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1
+	}
+	exp := int(h>>10) & 0x1f
+	frac := float64(h & 0x3ff)
+	switch exp {
+	case 0:
+		return sign * math.Ldexp(frac, -24)
+	case 0x1f:
+		if frac == 0 {
+			return math.Inf(int(sign))
+		}
+		return math.NaN()
+	default:
+		return sign * math.Ldexp(frac+1024, exp-25)
+	}
+}
@@ -0,0 +1,132 @@
+package vals
+
+import (
+	"encoding/hex"
+	"io"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+
+	"src.elv.sh/pkg/tt"
+)
+
+func encodeCBORHex(v any) (string, error) {
+	buf, err := AppendCBOR(nil, v)
+	return hex.EncodeToString(buf), err
+}
+
+func decodeCBORHex(s string) (any, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return NewCBORDecoder(strings.NewReader(string(b))).Decode()
+}
+
+// Examples from appendix A of RFC 8949.
+func TestAppendCBOR(t *testing.T) {
+	tt.Test(t, encodeCBORHex,
+		Args(0).Rets("00", nil),
+		Args(23).Rets("17", nil),
+		Args(24).Rets("1818", nil),
+		Args(1000).Rets("1903e8", nil),
+		Args(1000000).Rets("1a000f4240", nil),
+		Args(-1).Rets("20", nil),
+		Args(-1000).Rets("3903e7", nil),
+		Args(bigInt("18446744073709551615")).Rets("1bffffffffffffffff", nil),
+		Args(bigInt("18446744073709551616")).Rets("c249010000000000000000", nil),
+		Args(bigInt("-18446744073709551616")).Rets("3bffffffffffffffff", nil),
+		Args(bigInt("-18446744073709551617")).Rets("c349010000000000000000", nil),
+		Args(big.NewRat(-1, 3)).Rets("d81e822003", nil),
+
+		Args(1.5).Rets("fa3fc00000", nil),
+		Args(1.1).Rets("fb3ff199999999999a", nil),
+		Args(math.Inf(1)).Rets("fa7f800000", nil),
+
+		Args(false).Rets("f4", nil),
+		Args(true).Rets("f5", nil),
+		Args(nil).Rets("f6", nil),
+
+		Args("").Rets("60", nil),
+		Args("IETF").Rets("6449455446", nil),
+		Args("ü").Rets("62c3bc", nil),
+		Args("\xff").Rets("41ff", nil),
+
+		Args(MakeList(1, MakeList(2, 3))).Rets("8201820203", nil),
+		Args(MakeMap("a", 1)).Rets("a1616101", nil),
+		Args(fieldMap{"lorem", "ipsum", 23}).Rets("a363666f6f656c6f72656d6362617265697073756d67666f6f2d62617217", nil),
+
+		Args(MakeList(func() {})).Rets("", tt.Any),
+	)
+}
+
+func TestCBORDecoder(t *testing.T) {
+	tt.Test(t, decodeCBORHex,
+		Args("00").Rets(0, nil),
+		Args("1bffffffffffffffff").Rets(bigInt("18446744073709551615"), nil),
+		Args("3903e7").Rets(-1000, nil),
+		Args("3bffffffffffffffff").Rets(bigInt("-18446744073709551616"), nil),
+		Args("c249010000000000000000").Rets(bigInt("18446744073709551616"), nil),
+		Args("c349010000000000000000").Rets(bigInt("-18446744073709551617"), nil),
+		Args("c24101").Rets(1, nil),
+		Args("d81e822003").Rets(big.NewRat(-1, 3), nil),
+		Args("d81e820402").Rets(2, nil),
+
+		Args("f93c00").Rets(1.0, nil),
+		Args("f93e00").Rets(1.5, nil),
+		Args("f9c400").Rets(-4.0, nil),
+		Args("f90001").Rets(5.960464477539063e-8, nil),
+		Args("f97c00").Rets(math.Inf(1), nil),
+		Args("fa47c35000").Rets(100000.0, nil),
+		Args("fb3ff199999999999a").Rets(1.1, nil),
+
+		Args("f4").Rets(false, nil),
+		Args("f5").Rets(true, nil),
+		Args("f6").Rets(nil, nil),
+		Args("f7").Rets(nil, nil),
+
+		Args("6449455446").Rets("IETF", nil),
+		Args("4401020304").Rets("\x01\x02\x03\x04", nil),
+		Args("7f657374726561646d696e67ff").Rets("streaming", nil),
+		Args("5f42010243030405ff").Rets("\x01\x02\x03\x04\x05", nil),
+
+		Args("83010203").Rets(eq(MakeList(1, 2, 3)), nil),
+		Args("9f018202039f0405ffff").Rets(eq(MakeList(1, MakeList(2, 3), MakeList(4, 5))), nil),
+		Args("a201020304").Rets(eq(MakeMap(1, 2, 3, 4)), nil),
+		Args("bf61610161629f0203ffff").Rets(eq(MakeMap("a", 1, "b", MakeList(2, 3))), nil),
+		// Unknown tags are ignored.
+		Args("c11a514b67b0").Rets(1363896240, nil),
+
+		// Errors.
+		Args("").Rets(nil, io.EOF),
+		Args("1903").Rets(nil, io.ErrUnexpectedEOF),
+		Args("6449").Rets(nil, io.ErrUnexpectedEOF),
+		Args("8201").Rets(nil, io.ErrUnexpectedEOF),
+		Args("ff").Rets(nil, errCBORBreak),
+		Args("1c").Rets(nil, tt.Any),
+		Args("f820").Rets(nil, tt.Any),
+		Args("c201").Rets(nil, tt.Any),
+		Args("d81e8101").Rets(nil, errCBORBadRational),
+		Args("d81e820100").Rets(nil, errCBORBadRational),
+		Args("7f01ff").Rets(nil, tt.Any),
+		Args(strings.Repeat("81", maxCBORDepth+2)+"00").Rets(nil, errCBORTooDeep),
+	)
+}
+
+func TestCBOR_RoundTrip(t *testing.T) {
+	values := []any{
+		MakeMap("list", MakeList("a", 1, 1.5, bigInt(z), big.NewRat(1, 2)),
+			"nested", MakeMap(MakeList(1), nil), "bool", true),
+	}
+	for _, v := range values {
+		buf, err := AppendCBOR(nil, v)
+		if err != nil {
+			t.Fatalf("AppendCBOR(%s) -> error %v", ReprPlain(v), err)
+		}
+		got, err := NewCBORDecoder(strings.NewReader(string(buf))).Decode()
+		if err != nil || !Equal(got, v) {
+			t.Errorf("round trip of %s -> %s, %v", ReprPlain(v), ReprPlain(got), err)
+		}
+	}
+}
@@ -11,8 +11,8 @@ import (
 //
 // A field map is a struct with at least one field, and all fields are exported
 // and non-anonymous. In Elvish code, it behaves exactly the same as a map: the
-// keys are dash-case versions of the field names, and the values are the field
-// value.
+// keys are dash-case versions of the field names (or the value of the "name"
+// tag of the field if it has one), and the values are the field value.
 func IsFieldMap(v any) bool {
 	return GetFieldMapKeys(v) != nil
 }
@@ -61,7 +61,11 @@ func makeFieldMapKeys(t reflect.Type) FieldMapKeys {
 		if field.PkgPath != "" || field.Anonymous {
 			return nil
 		}
-		keys[i] = strutil.CamelToDashed(field.Name)
+		if name, ok := field.Tag.Lookup("name"); ok {
+			keys[i] = name
+		} else {
+			keys[i] = strutil.CamelToDashed(field.Name)
+		}
 	}
 	return keys
 }
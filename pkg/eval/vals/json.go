@@ -0,0 +1,112 @@
+package vals
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+)
+
+// FromJSON converts a value that results from decoding JSON with
+// [json.Decoder.UseNumber] to an Elvish value.
+func FromJSON(v any) (any, error) {
+	switch v := v.(type) {
+	case nil, bool, string:
+		return v, nil
+	case json.Number:
+		// The JSON syntax doesn't restrict the precision of numbers. Since
+		// the decoder is configured with UseNumber, it preserves the full number
+		// literal, and we can try parsing it as a big int.
+		if z, ok := new(big.Int).SetString(v.String(), 0); ok {
+			// Also normalize to int if the value fits.
+			return NormalizeBigInt(z), nil
+		}
+		// Parse as float64 instead. This can error if the number is not an
+		// integer and exceeds the range of float64.
+		return strconv.ParseFloat(v.String(), 64)
+	case float64:
+		return v, nil
+	case []any:
+		vec := EmptyList
+		for _, elem := range v {
+			converted, err := FromJSON(elem)
+			if err != nil {
+				return nil, err
+			}
+			vec = vec.Conj(converted)
+		}
+		return vec, nil
+	case map[string]any:
+		m := EmptyMap
+		for key, val := range v {
+			convertedVal, err := FromJSON(val)
+			if err != nil {
+				return nil, err
+			}
+			m = m.Assoc(key, convertedVal)
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("unexpected json type: %T", v)
+	}
+}
+
+// DecodeJSON decodes a JSON value whose first token is tok, which has just
+// been read from dec, reading the rest of the value from dec. The decoder must
+// use [json.Decoder.UseNumber]. Numbers are converted in the same way as
+// [FromJSON]. If ordered is true, objects are converted to maps that remember
+// the order of keys (see [EmptyOrderedMap]).
+func DecodeJSON(dec *json.Decoder, tok json.Token, ordered bool) (any, error) {
+	switch tok {
+	case json.Delim('['):
+		vec := EmptyList
+		for dec.More() {
+			elem, err := decodeNextJSON(dec, ordered)
+			if err != nil {
+				return nil, err
+			}
+			vec = vec.Conj(elem)
+		}
+		_, err := nextToken(dec)
+		return vec, err
+	case json.Delim('{'):
+		m := EmptyMap
+		if ordered {
+			m = EmptyOrderedMap
+		}
+		for dec.More() {
+			key, err := nextToken(dec)
+			if err != nil {
+				return nil, err
+			}
+			val, err := decodeNextJSON(dec, ordered)
+			if err != nil {
+				return nil, err
+			}
+			m = m.Assoc(key, val)
+		}
+		_, err := nextToken(dec)
+		return m, err
+	default:
+		return FromJSON(tok)
+	}
+}
+
+func decodeNextJSON(dec *json.Decoder, ordered bool) (any, error) {
+	tok, err := nextToken(dec)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeJSON(dec, tok, ordered)
+}
+
+// Like dec.Token, but returns io.ErrUnexpectedEOF instead of io.EOF, since it
+// is only called in the middle of a value.
+func nextToken(dec *json.Decoder) (json.Token, error) {
+	tok, err := dec.Token()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return tok, err
+}
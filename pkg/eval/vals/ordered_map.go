@@ -0,0 +1,139 @@
+package vals
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"src.elv.sh/pkg/persistent/hashmap"
+	"src.elv.sh/pkg/persistent/vector"
+)
+
+// EmptyOrderedMap is an empty map that remembers the order in which keys are
+// added. Iterating over it, including encoding it as JSON, follows that order.
+//
+// It is otherwise the same as other maps: it has the kind "map", and compares
+// equal to maps with the same pairs in any order. Associating a key that
+// already exists keeps its position. Dissociating a key takes linear time.
+var EmptyOrderedMap Map = orderedMap{EmptyMap, vector.Empty}
+
+type orderedMap struct {
+	m    Map
+	keys vector.Vector
+}
+
+func (om orderedMap) Len() int { return om.m.Len() }
+
+func (om orderedMap) Index(k any) (any, bool) { return om.m.Index(k) }
+
+func (om orderedMap) Assoc(k, v any) hashmap.Map {
+	keys := om.keys
+	if !hashmap.HasKey(om.m, k) {
+		keys = keys.Conj(k)
+	}
+	return orderedMap{om.m.Assoc(k, v), keys}
+}
+
+func (om orderedMap) Dissoc(k any) hashmap.Map {
+	if !hashmap.HasKey(om.m, k) {
+		return om
+	}
+	keys := vector.Empty
+	for it := om.keys.Iterator(); it.HasElem(); it.Next() {
+		if key := it.Elem(); !Equal(key, k) {
+			keys = keys.Conj(key)
+		}
+	}
+	return orderedMap{om.m.Dissoc(k), keys}
+}
+
+func (om orderedMap) Iterator() hashmap.Iterator {
+	return &orderedMapIterator{om.m, om.keys.Iterator()}
+}
+
+func (om orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for it := om.Iterator(); it.HasElem(); it.Next() {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		k, v := it.Elem()
+		kString, err := jsonKey(k)
+		if err != nil {
+			return nil, err
+		}
+		kBytes, err := json.Marshal(kString)
+		if err != nil {
+			return nil, err
+		}
+		vBytes, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(kBytes)
+		buf.WriteByte(':')
+		buf.Write(vBytes)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// EncodingKeys returns the keys of a map in the order they are encoded in: the
+// order of insertion for ordered maps, and sorted order for other maps.
+func EncodingKeys(m any) ([]any, error) {
+	var keys []any
+	err := IterateKeys(m, func(k any) bool {
+		keys = append(keys, k)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := m.(orderedMap); !ok {
+		sort.Slice(keys, func(i, j int) bool {
+			return CmpTotal(keys[i], keys[j]) == CmpLess
+		})
+	}
+	return keys, nil
+}
+
+// Converts a map key to a JSON object key, in the same way as the MarshalJSON
+// method of other maps.
+func jsonKey(k any) (string, error) {
+	kref := reflect.ValueOf(k)
+	if kref.Kind() == reflect.String {
+		return kref.String(), nil
+	}
+	if t, ok := k.(encoding.TextMarshaler); ok {
+		b, err := t.MarshalText()
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+	switch kref.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(kref.Int(), 10), nil
+	}
+	return "", fmt.Errorf("unsupported key type %T", k)
+}
+
+type orderedMapIterator struct {
+	m  Map
+	it vector.Iterator
+}
+
+func (it *orderedMapIterator) Elem() (any, any) {
+	k := it.it.Elem()
+	v, _ := it.m.Index(k)
+	return k, v
+}
+
+func (it *orderedMapIterator) HasElem() bool { return it.it.HasElem() }
+
+func (it *orderedMapIterator) Next() { it.it.Next() }
@@ -0,0 +1,38 @@
+package vals
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestOrderedMap(t *testing.T) {
+	m := EmptyOrderedMap.Assoc("foo", "bar").Assoc("lorem", "ipsum").Assoc("a", "b")
+	TestValue(t, m).
+		Kind("map").
+		Len(3).
+		Repr("[&a=b &foo=bar &lorem=ipsum]").
+		Equal(MakeMap("a", "b", "foo", "bar", "lorem", "ipsum")).
+		NotEqual(MakeMap("a", "b", "foo", "bar")).
+		AllKeys("foo", "lorem", "a").
+		Index("lorem", "ipsum").
+		HasNoKey("bad")
+
+	// Existing keys keep their positions.
+	TestValue(t, m.Assoc("foo", "new")).
+		AllKeys("foo", "lorem", "a").
+		Index("foo", "new")
+	TestValue(t, m.Dissoc("lorem")).
+		Len(2).
+		AllKeys("foo", "a")
+	TestValue(t, m.Dissoc("bad")).
+		AllKeys("foo", "lorem", "a")
+}
+
+func TestOrderedMap_MarshalJSON(t *testing.T) {
+	m := EmptyOrderedMap.Assoc("z", 1).Assoc("a", EmptyOrderedMap.Assoc("y", "x").Assoc("b", "c"))
+	got, err := json.Marshal(m)
+	want := `{"z":1,"a":{"y":"x","b":"c"}}`
+	if string(got) != want || err != nil {
+		t.Errorf("got %s, %v; want %s, nil", got, err, want)
+	}
+}
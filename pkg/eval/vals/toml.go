@@ -0,0 +1,861 @@
+package vals
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// The maximum nesting depth of arrays and inline tables when decoding TOML.
+const maxTOMLDepth = 1000
+
+// DecodeTOML reads a TOML document from r and decodes it into a map.
+//
+// Tables are decoded as maps that preserve the order of their keys (see
+// [EmptyOrderedMap]), and arrays, including arrays of tables, as lists.
+// Integers are decoded as exact integers, without the 64-bit limit of TOML,
+// and floats as floating-point numbers. Since Elvish has no types for dates
+// and times, they are decoded as strings of their original text.
+func DecodeTOML(r io.Reader) (Map, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	src := strings.TrimPrefix(string(data), "\ufeff")
+	p := &tomlParser{src: strings.ReplaceAll(src, "\r\n", "\n")}
+	root, err := p.parseDocument()
+	if err != nil {
+		return nil, err
+	}
+	return root.toMap(), nil
+}
+
+type tomlParser struct {
+	src   string
+	pos   int
+	depth int
+}
+
+// A table being decoded. The values are *tomlTable, *tomlTableArray, []any
+// for arrays, or other values.
+type tomlTable struct {
+	keys   []string
+	values map[string]any
+	// Whether the table was defined with a [table] header, with dotted keys,
+	// or as an inline table. Tables that are none of these have only been
+	// created implicitly as parents of other tables, and can still be defined
+	// with a [table] header.
+	header, dotted, inline bool
+}
+
+// An array of tables, defined with [[array]] headers.
+type tomlTableArray struct {
+	tables []*tomlTable
+}
+
+func newTOMLTable() *tomlTable {
+	return &tomlTable{values: make(map[string]any)}
+}
+
+func (t *tomlTable) set(k string, v any) {
+	t.keys = append(t.keys, k)
+	t.values[k] = v
+}
+
+func (t *tomlTable) toMap() Map {
+	m := EmptyOrderedMap
+	for _, k := range t.keys {
+		m = m.Assoc(k, tomlToValue(t.values[k]))
+	}
+	return m
+}
+
+func tomlToValue(v any) any {
+	switch v := v.(type) {
+	case *tomlTable:
+		return v.toMap()
+	case *tomlTableArray:
+		l := EmptyList
+		for _, t := range v.tables {
+			l = l.Conj(t.toMap())
+		}
+		return l
+	case []any:
+		l := EmptyList
+		for _, elem := range v {
+			l = l.Conj(tomlToValue(elem))
+		}
+		return l
+	}
+	return v
+}
+
+func (p *tomlParser) errorf(format string, args ...any) error {
+	line := 1 + strings.Count(p.src[:p.pos], "\n")
+	return fmt.Errorf("invalid TOML on line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (p *tomlParser) unexpected() error {
+	if p.eof() {
+		return p.errorf("unexpected end of input")
+	}
+	r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+	return p.errorf("unexpected %q", r)
+}
+
+func (p *tomlParser) eof() bool { return p.pos >= len(p.src) }
+
+// Returns the byte i bytes after the current position, or 0 if that is beyond
+// the end of input.
+func (p *tomlParser) peekAt(i int) byte {
+	if p.pos+i < len(p.src) {
+		return p.src[p.pos+i]
+	}
+	return 0
+}
+
+func (p *tomlParser) peek() byte { return p.peekAt(0) }
+
+func (p *tomlParser) enter() error {
+	p.depth++
+	if p.depth > maxTOMLDepth {
+		return p.errorf("data nested too deeply")
+	}
+	return nil
+}
+
+func (p *tomlParser) leave() { p.depth-- }
+
+func (p *tomlParser) skipBlanks() {
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.pos++
+	}
+}
+
+func (p *tomlParser) skipComment() {
+	if p.peek() == '#' {
+		for !p.eof() && p.src[p.pos] != '\n' {
+			p.pos++
+		}
+	}
+}
+
+// Skips blanks, line breaks and comments, which can appear between the
+// elements of arrays.
+func (p *tomlParser) skipSpace() {
+	for {
+		p.skipBlanks()
+		p.skipComment()
+		if p.peek() != '\n' {
+			return
+		}
+		p.pos++
+	}
+}
+
+func (p *tomlParser) parseDocument() (*tomlTable, error) {
+	root := newTOMLTable()
+	root.header = true
+	current := root
+	for {
+		p.skipBlanks()
+		switch p.peek() {
+		case '\n':
+			p.pos++
+			continue
+		case '#':
+		case '[':
+			t, err := p.parseHeader(root)
+			if err != nil {
+				return nil, err
+			}
+			current = t
+		default:
+			if p.eof() {
+				return root, nil
+			}
+			if err := p.parseKeyValue(current); err != nil {
+				return nil, err
+			}
+		}
+		p.skipBlanks()
+		p.skipComment()
+		if !p.eof() {
+			if p.peek() != '\n' {
+				return nil, p.unexpected()
+			}
+			p.pos++
+		}
+	}
+}
+
+// Parses a [table] or [[array]] header, and returns the table that the
+// following pairs belong to.
+func (p *tomlParser) parseHeader(root *tomlTable) (*tomlTable, error) {
+	array := strings.HasPrefix(p.src[p.pos:], "[[")
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+	p.pos += len(closing)
+	p.skipBlanks()
+	keys, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlanks()
+	if !strings.HasPrefix(p.src[p.pos:], closing) {
+		return nil, p.errorf("expected %q", closing)
+	}
+	p.pos += len(closing)
+
+	t := root
+	for i, k := range keys[:len(keys)-1] {
+		switch v := t.values[k].(type) {
+		case nil:
+			child := newTOMLTable()
+			t.set(k, child)
+			t = child
+		case *tomlTable:
+			if v.inline {
+				return nil, p.errorf("cannot add to inline table %s", formatTOMLKey(keys[:i+1]))
+			}
+			t = v
+		case *tomlTableArray:
+			t = v.tables[len(v.tables)-1]
+		default:
+			return nil, p.errorf("%s is not a table", formatTOMLKey(keys[:i+1]))
+		}
+	}
+	last := keys[len(keys)-1]
+	existing, exists := t.values[last]
+	if array {
+		if !exists {
+			existing = &tomlTableArray{}
+			t.set(last, existing)
+		}
+		ta, ok := existing.(*tomlTableArray)
+		if !ok {
+			return nil, p.errorf("%s is not an array of tables", formatTOMLKey(keys))
+		}
+		elem := newTOMLTable()
+		elem.header = true
+		ta.tables = append(ta.tables, elem)
+		return elem, nil
+	}
+	if !exists {
+		child := newTOMLTable()
+		child.header = true
+		t.set(last, child)
+		return child, nil
+	}
+	if child, ok := existing.(*tomlTable); ok && !child.header && !child.dotted && !child.inline {
+		child.header = true
+		return child, nil
+	}
+	return nil, p.errorf("%s is defined more than once", formatTOMLKey(keys))
+}
+
+// Parses a key/value pair and adds it to t.
+func (p *tomlParser) parseKeyValue(t *tomlTable) error {
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	p.skipBlanks()
+	if p.peek() != '=' {
+		return p.errorf("expected '=' after a key")
+	}
+	p.pos++
+	p.skipBlanks()
+	v, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+	for i, k := range keys[:len(keys)-1] {
+		switch child := t.values[k].(type) {
+		case nil:
+			newChild := newTOMLTable()
+			newChild.dotted = true
+			t.set(k, newChild)
+			t = newChild
+		case *tomlTable:
+			if !child.dotted {
+				return p.errorf("cannot add to table %s with dotted keys", formatTOMLKey(keys[:i+1]))
+			}
+			t = child
+		default:
+			return p.errorf("%s is not a table", formatTOMLKey(keys[:i+1]))
+		}
+	}
+	last := keys[len(keys)-1]
+	if _, exists := t.values[last]; exists {
+		return p.errorf("%s is defined more than once", formatTOMLKey(keys))
+	}
+	t.set(last, v)
+	return nil
+}
+
+// Parses a dotted key.
+func (p *tomlParser) parseKey() ([]string, error) {
+	var keys []string
+	for {
+		var k string
+		var err error
+		switch b := p.peek(); {
+		case b == '"':
+			k, err = p.parseBasicString()
+		case b == '\'':
+			k, err = p.parseLiteralString()
+		case isTOMLBareKeyChar(b):
+			start := p.pos
+			for isTOMLBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			k = p.src[start:p.pos]
+		default:
+			return nil, p.errorf("expected a key")
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+		p.skipBlanks()
+		if p.peek() != '.' {
+			return keys, nil
+		}
+		p.pos++
+		p.skipBlanks()
+	}
+}
+
+func isTOMLBareKeyChar(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9' ||
+		b == '_' || b == '-'
+}
+
+// Formats a dotted key for error messages.
+func formatTOMLKey(keys []string) string {
+	var buf []byte
+	for i, k := range keys {
+		if i > 0 {
+			buf = append(buf, '.')
+		}
+		buf, _ = appendTOMLKey(buf, k)
+	}
+	return string(buf)
+}
+
+var (
+	tomlIntRegexp = regexp.MustCompile(
+		`^(?:[-+]?(?:0|[1-9](?:_?[0-9])*)|0x[0-9a-fA-F](?:_?[0-9a-fA-F])*|0o[0-7](?:_?[0-7])*|0b[01](?:_?[01])*)$`)
+	tomlFloatRegexp = regexp.MustCompile(
+		`^[-+]?(?:0|[1-9](?:_?[0-9])*)(?:\.[0-9](?:_?[0-9])*)?(?:[eE][-+]?[0-9](?:_?[0-9])*)?$`)
+	tomlDateRegexp     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	tomlDateTimeRegexp = regexp.MustCompile(
+		`^(?:(\d{4}-\d{2}-\d{2})(?:[Tt ](\d{2}:\d{2}:\d{2})(?:\.\d+)?(?:[Zz]|[-+]\d{2}:\d{2})?)?|(\d{2}:\d{2}:\d{2})(?:\.\d+)?)$`)
+)
+
+func (p *tomlParser) parseValue() (any, error) {
+	rest := p.src[p.pos:]
+	switch {
+	case strings.HasPrefix(rest, `"""`), strings.HasPrefix(rest, "'''"):
+		return p.parseMultilineString()
+	case strings.HasPrefix(rest, `"`):
+		return p.parseBasicString()
+	case strings.HasPrefix(rest, "'"):
+		return p.parseLiteralString()
+	case strings.HasPrefix(rest, "["):
+		return p.parseArray()
+	case strings.HasPrefix(rest, "{"):
+		return p.parseInlineTable()
+	}
+	// Booleans, numbers, and dates and times.
+	start := p.pos
+	for {
+		b := p.peek()
+		if isTOMLBareKeyChar(b) || b == '+' || b == '.' || b == ':' ||
+			// A space can separate a date and a time.
+			b == ' ' && tomlDateRegexp.MatchString(p.src[start:p.pos]) &&
+				'0' <= p.peekAt(1) && p.peekAt(1) <= '9' {
+			p.pos++
+		} else {
+			break
+		}
+	}
+	s := p.src[start:p.pos]
+	switch s {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "inf", "+inf":
+		return math.Inf(1), nil
+	case "-inf":
+		return math.Inf(-1), nil
+	case "nan", "+nan", "-nan":
+		return math.NaN(), nil
+	}
+	if tomlIntRegexp.MatchString(s) {
+		// Base 0 supports the prefixes and underscores.
+		z, _ := new(big.Int).SetString(s, 0)
+		return NormalizeBigInt(z), nil
+	}
+	if tomlFloatRegexp.MatchString(s) {
+		f, err := strconv.ParseFloat(strings.ReplaceAll(s, "_", ""), 64)
+		if err == nil {
+			return f, nil
+		}
+	} else if m := tomlDateTimeRegexp.FindStringSubmatch(s); m != nil && validTOMLDateTime(m) {
+		return s, nil
+	}
+	p.pos = start
+	if s == "" {
+		return nil, p.unexpected()
+	}
+	return nil, p.errorf("invalid value %q", s)
+}
+
+// Checks the ranges of the fields of a date, time, or date and time, matched
+// by tomlDateTimeRegexp.
+func validTOMLDateTime(m []string) bool {
+	if m[1] != "" {
+		if _, err := time.Parse("2006-01-02", m[1]); err != nil {
+			return false
+		}
+	}
+	for _, t := range []string{m[2], m[3]} {
+		// Leap seconds are allowed.
+		if t != "" && !strings.HasSuffix(t, ":60") {
+			if _, err := time.Parse("15:04:05", t); err != nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+var tomlEscapes = map[byte]string{
+	'b': "\b", 't': "\t", 'n': "\n", 'f': "\f", 'r': "\r", '"': "\"", '\\': "\\",
+}
+
+var tomlHexEscapeLens = map[byte]int{'u': 4, 'U': 8}
+
+func isTOMLControl(b byte) bool {
+	return b < 0x20 && b != '\t' || b == 0x7f
+}
+
+// Parses an escape sequence in a basic string and writes the result to sb.
+func (p *tomlParser) parseEscape(sb *strings.Builder) error {
+	if s, ok := tomlEscapes[p.peekAt(1)]; ok {
+		sb.WriteString(s)
+		p.pos += 2
+		return nil
+	}
+	if n, ok := tomlHexEscapeLens[p.peekAt(1)]; ok && p.pos+2+n <= len(p.src) {
+		r, err := strconv.ParseUint(p.src[p.pos+2:p.pos+2+n], 16, 32)
+		if err == nil && utf8.ValidRune(rune(r)) {
+			sb.WriteRune(rune(r))
+			p.pos += 2 + n
+			return nil
+		}
+	}
+	return p.errorf("invalid escape sequence")
+}
+
+func (p *tomlParser) parseBasicString() (string, error) {
+	p.pos++
+	var sb strings.Builder
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		switch b := p.src[p.pos]; {
+		case b == '"':
+			p.pos++
+			return sb.String(), nil
+		case b == '\\':
+			if err := p.parseEscape(&sb); err != nil {
+				return "", err
+			}
+		case isTOMLControl(b):
+			return "", p.errorf("control characters in strings must be escaped")
+		default:
+			sb.WriteByte(b)
+			p.pos++
+		}
+	}
+}
+
+func (p *tomlParser) parseLiteralString() (string, error) {
+	p.pos++
+	start := p.pos
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		switch b := p.src[p.pos]; {
+		case b == '\'':
+			p.pos++
+			return p.src[start : p.pos-1], nil
+		case isTOMLControl(b):
+			return "", p.errorf("control characters are not allowed in literal strings")
+		}
+		p.pos++
+	}
+}
+
+// Parses a multi-line basic or a multi-line literal string.
+func (p *tomlParser) parseMultilineString() (string, error) {
+	q := p.src[p.pos]
+	delim := p.src[p.pos : p.pos+3]
+	p.pos += 3
+	// A line break right after the opening delimiter is trimmed.
+	if p.peek() == '\n' {
+		p.pos++
+	}
+	var sb strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated multi-line string")
+		}
+		if strings.HasPrefix(p.src[p.pos:], delim) {
+			// Up to two quotes can appear right before the closing delimiter.
+			n := 3
+			for n < 5 && p.peekAt(n) == q {
+				n++
+			}
+			sb.WriteString(p.src[p.pos : p.pos+n-3])
+			p.pos += n
+			return sb.String(), nil
+		}
+		switch b := p.src[p.pos]; {
+		case b == '\\' && q == '"':
+			j := p.pos + 1
+			for j < len(p.src) && (p.src[j] == ' ' || p.src[j] == '\t') {
+				j++
+			}
+			if j == len(p.src) || p.src[j] == '\n' {
+				// A backslash at the end of a line trims all the whitespace
+				// after it.
+				p.pos = j
+				for p.peek() == ' ' || p.peek() == '\t' || p.peek() == '\n' {
+					p.pos++
+				}
+				continue
+			}
+			if err := p.parseEscape(&sb); err != nil {
+				return "", err
+			}
+		case isTOMLControl(b) && b != '\n':
+			return "", p.errorf("control characters in strings must be escaped")
+		default:
+			sb.WriteByte(b)
+			p.pos++
+		}
+	}
+}
+
+func (p *tomlParser) parseArray() (any, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	p.pos++
+	elems := []any{}
+	for {
+		p.skipSpace()
+		if p.peek() == ']' {
+			p.pos++
+			return elems, nil
+		}
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, v)
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return elems, nil
+		default:
+			if p.eof() {
+				return nil, p.errorf("unterminated array")
+			}
+			return nil, p.errorf("expected ',' or ']' in array")
+		}
+	}
+}
+
+// Parses an inline table. Unlike arrays, inline tables must be on one line.
+func (p *tomlParser) parseInlineTable() (any, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	p.pos++
+	t := newTOMLTable()
+	t.inline = true
+	p.skipBlanks()
+	if p.peek() == '}' {
+		p.pos++
+		return t, nil
+	}
+	for {
+		p.skipBlanks()
+		if err := p.parseKeyValue(t); err != nil {
+			return nil, err
+		}
+		p.skipBlanks()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return t, nil
+		default:
+			return nil, p.errorf("expected ',' or '}' in inline table")
+		}
+	}
+}
+
+var errTOMLInvalidUTF8 = errors.New("cannot encode string with invalid UTF-8 to TOML")
+
+// AppendTOML appends v, which must be a map, to buf as a TOML document. The
+// keys of ordered maps (see [EmptyOrderedMap]) are written in order, and those
+// of other maps are sorted; all keys must be strings.
+//
+// Maps are written as tables and lists of maps as arrays of tables, except
+// inside other lists, where they are written as inline tables. Rational
+// numbers are written as strings like "1/2". Since TOML has no null value,
+// $nil cannot be written.
+func AppendTOML(buf []byte, v any) ([]byte, error) {
+	if Kind(v) != "map" {
+		return nil, fmt.Errorf("cannot encode value of kind %s as a TOML document", Kind(v))
+	}
+	return appendTOMLTable(buf, v, nil, false)
+}
+
+// Appends the pairs of a table, followed by its sub-tables and arrays of
+// tables. The header is written when path is not empty, unless the table only
+// has other tables, in which case it is implied by their headers.
+func appendTOMLTable(buf []byte, m any, path []string, arrayElem bool) ([]byte, error) {
+	keys, err := EncodingKeys(m)
+	if err != nil {
+		return nil, err
+	}
+	type pair struct {
+		key   string
+		value any
+	}
+	var pairs, tables, arrays []pair
+	for _, k := range keys {
+		ks, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot encode map key of kind %s to TOML", Kind(k))
+		}
+		v, err := Index(m, k)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case Kind(v) == "map":
+			tables = append(tables, pair{ks, v})
+		case isTOMLTableArray(v):
+			arrays = append(arrays, pair{ks, v})
+		default:
+			pairs = append(pairs, pair{ks, v})
+		}
+	}
+
+	if len(path) > 0 && (arrayElem || len(pairs) > 0 || len(tables)+len(arrays) == 0) {
+		if len(buf) > 0 {
+			buf = append(buf, '\n')
+		}
+		open, close := "[", "]"
+		if arrayElem {
+			open, close = "[[", "]]"
+		}
+		buf = append(buf, open...)
+		for i, k := range path {
+			if i > 0 {
+				buf = append(buf, '.')
+			}
+			if buf, err = appendTOMLKey(buf, k); err != nil {
+				return nil, err
+			}
+		}
+		buf = append(buf, close+"\n"...)
+	}
+	for _, p := range pairs {
+		if buf, err = appendTOMLKey(buf, p.key); err != nil {
+			return nil, err
+		}
+		buf = append(buf, " = "...)
+		if buf, err = appendTOMLValue(buf, p.value); err != nil {
+			return nil, err
+		}
+		buf = append(buf, '\n')
+	}
+	subPath := func(k string) []string {
+		return append(path[:len(path):len(path)], k)
+	}
+	for _, p := range tables {
+		if buf, err = appendTOMLTable(buf, p.value, subPath(p.key), false); err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range arrays {
+		errIterate := Iterate(p.value, func(elem any) bool {
+			buf, err = appendTOMLTable(buf, elem, subPath(p.key), true)
+			return err == nil
+		})
+		if errIterate != nil {
+			return nil, errIterate
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+// Reports whether v is a non-empty list of maps.
+func isTOMLTableArray(v any) bool {
+	if Kind(v) != "list" {
+		return false
+	}
+	n := 0
+	allMaps := true
+	Iterate(v, func(elem any) bool {
+		n++
+		allMaps = Kind(elem) == "map"
+		return allMaps
+	})
+	return n > 0 && allMaps
+}
+
+// Appends a value that is not a table or an array of tables.
+func appendTOMLValue(buf []byte, v any) ([]byte, error) {
+	switch v := v.(type) {
+	case bool:
+		return strconv.AppendBool(buf, v), nil
+	case string:
+		return appendTOMLString(buf, v)
+	case int:
+		return strconv.AppendInt(buf, int64(v), 10), nil
+	case *big.Int:
+		return v.Append(buf, 10), nil
+	case *big.Rat:
+		return appendTOMLString(buf, v.String())
+	case float64:
+		switch {
+		case math.IsInf(v, 1):
+			return append(buf, "inf"...), nil
+		case math.IsInf(v, -1):
+			return append(buf, "-inf"...), nil
+		case math.IsNaN(v):
+			return append(buf, "nan"...), nil
+		}
+		return append(buf, formatFloat64(v)...), nil
+	}
+	var err error
+	switch Kind(v) {
+	case "list":
+		buf = append(buf, '[')
+		first := true
+		errIterate := Iterate(v, func(elem any) bool {
+			if !first {
+				buf = append(buf, ", "...)
+			}
+			first = false
+			buf, err = appendTOMLValue(buf, elem)
+			return err == nil
+		})
+		if errIterate != nil {
+			return nil, errIterate
+		}
+		if err != nil {
+			return nil, err
+		}
+		return append(buf, ']'), nil
+	case "map":
+		keys, err := EncodingKeys(v)
+		if err != nil {
+			return nil, err
+		}
+		if len(keys) == 0 {
+			return append(buf, "{}"...), nil
+		}
+		buf = append(buf, "{ "...)
+		for i, k := range keys {
+			ks, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("cannot encode map key of kind %s to TOML", Kind(k))
+			}
+			if i > 0 {
+				buf = append(buf, ", "...)
+			}
+			if buf, err = appendTOMLKey(buf, ks); err != nil {
+				return nil, err
+			}
+			buf = append(buf, " = "...)
+			elem, err := Index(v, k)
+			if err != nil {
+				return nil, err
+			}
+			if buf, err = appendTOMLValue(buf, elem); err != nil {
+				return nil, err
+			}
+		}
+		return append(buf, " }"...), nil
+	}
+	return nil, fmt.Errorf("cannot encode value of kind %s to TOML", Kind(v))
+}
+
+// Appends a key, quoted if it is not a valid bare key.
+func appendTOMLKey(buf []byte, k string) ([]byte, error) {
+	for i := 0; i < len(k); i++ {
+		if !isTOMLBareKeyChar(k[i]) {
+			return appendTOMLString(buf, k)
+		}
+	}
+	if k == "" {
+		return append(buf, `""`...), nil
+	}
+	return append(buf, k...), nil
+}
+
+func appendTOMLString(buf []byte, s string) ([]byte, error) {
+	if !utf8.ValidString(s) {
+		return nil, errTOMLInvalidUTF8
+	}
+	buf = append(buf, '"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			buf = append(buf, '\\', byte(r))
+		case r == '\n':
+			buf = append(buf, `\n`...)
+		case r == '\t':
+			buf = append(buf, `\t`...)
+		case r == '\r':
+			buf = append(buf, `\r`...)
+		case r < 0x20 || r == 0x7f:
+			buf = fmt.Appendf(buf, `\u%04X`, r)
+		default:
+			buf = utf8.AppendRune(buf, r)
+		}
+	}
+	return append(buf, '"'), nil
+}
@@ -0,0 +1,164 @@
+package vals
+
+import (
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+
+	"src.elv.sh/pkg/tt"
+)
+
+func encodeTOML(v any) (string, error) {
+	buf, err := AppendTOML(nil, v)
+	return string(buf), err
+}
+
+func decodeTOML(s string) (Map, error) {
+	return DecodeTOML(strings.NewReader(s))
+}
+
+func TestAppendTOML(t *testing.T) {
+	tt.Test(t, encodeTOML,
+		Args(EmptyMap).Rets("", nil),
+		Args(MakeMap("s", "a\"\\\n\x01é", "i", 1, "z", bigInt(z), "r", big.NewRat(1, 2),
+			"f", 1.0, "inf", math.Inf(-1), "b", true)).Rets(
+			"b = true\n"+
+				"f = 1.0\n"+
+				"i = 1\n"+
+				"inf = -inf\n"+
+				`r = "1/2"`+"\n"+
+				`s = "a\"\\\n\u0001é"`+"\n"+
+				"z = "+z+"\n", nil),
+		// Keys that are not bare keys are quoted.
+		Args(MakeMap("a.b", 1, "", 2, "a-B_1", 3)).Rets(
+			`"" = 2`+"\n"+"a-B_1 = 3\n"+`"a.b" = 1`+"\n", nil),
+		// Lists and maps in lists are inline.
+		Args(MakeMap("l", MakeList(1, MakeList("a"), MakeMap("k", "v", "l", EmptyMap), EmptyList))).Rets(
+			`l = [1, ["a"], { k = "v", l = {} }, []]`+"\n", nil),
+		// Tables and arrays of tables come after pairs.
+		Args(MakeMap(
+			"t", MakeMap("x", 1, "sub", MakeMap("y", 2)),
+			"only-tables", MakeMap("sub", MakeMap("z", 3)),
+			"empty", EmptyMap,
+			"a", MakeList(MakeMap("n", 1), EmptyMap),
+			"v", 0)).Rets(
+			"v = 0\n"+
+				"\n[empty]\n"+
+				"\n[only-tables.sub]\nz = 3\n"+
+				"\n[t]\nx = 1\n"+
+				"\n[t.sub]\ny = 2\n"+
+				"\n[[a]]\nn = 1\n"+
+				"\n[[a]]\n", nil),
+		// Ordered maps keep their order.
+		Args(EmptyOrderedMap.Assoc("z", 1).Assoc("a", 2)).Rets("z = 1\na = 2\n", nil),
+
+		Args("foo").Rets("", tt.Any),
+		Args(MakeMap("a", nil)).Rets("", tt.Any),
+		Args(MakeMap(1, 2)).Rets("", tt.Any),
+		Args(MakeMap("a", "\xff")).Rets("", errTOMLInvalidUTF8),
+		Args(MakeMap("a", MakeList(func() {}))).Rets("", tt.Any),
+	)
+}
+
+func TestDecodeTOML(t *testing.T) {
+	tt.Test(t, decodeTOML,
+		Args("").Rets(eq(EmptyMap), nil),
+		Args("# comment\n\n").Rets(eq(EmptyMap), nil),
+
+		// Strings.
+		Args(`a = "\"\\\t\u00e9\U0001F600" # comment`).Rets(eq(MakeMap("a", "\"\\\té😀")), nil),
+		Args(`a = 'C:\path'`).Rets(eq(MakeMap("a", `C:\path`)), nil),
+		Args("a = \"\"\"\nx\n  \"y\" \\\n\n   z\"\"\"\"\"").Rets(eq(MakeMap("a", "x\n  \"y\" z\"\"")), nil),
+		Args("a = '''\nx\\n\ny'''").Rets(eq(MakeMap("a", "x\\n\ny")), nil),
+
+		// Numbers.
+		Args("a = +1_000\nb = -0\nc = 0xdead_BEEF\nd = 0o755\ne = 0b101\nf = "+z).Rets(
+			eq(MakeMap("a", 1000, "b", 0, "c", 0xdeadbeef, "d", 0o755, "e", 5, "f", bigInt(z))), nil),
+		Args("a = 1.5\nb = -2e-3\nc = 1_0.0_1\nd = -inf\ne = nan").Rets(tt.Any, nil),
+		Args("a = 1.5\nb = -2e-3\nc = 1_0.0_1\nd = -inf").Rets(
+			eq(MakeMap("a", 1.5, "b", -2e-3, "c", 10.01, "d", math.Inf(-1))), nil),
+
+		// Booleans, and dates and times, which are kept as strings.
+		Args("a = true\nb = 1979-05-27T07:32:00Z\nc = 1979-05-27 07:32:00.5-07:00\n"+
+			"d = 1979-05-27\ne = 07:32:00").Rets(
+			eq(MakeMap("a", true, "b", "1979-05-27T07:32:00Z", "c", "1979-05-27 07:32:00.5-07:00",
+				"d", "1979-05-27", "e", "07:32:00")), nil),
+
+		// Arrays and inline tables.
+		Args("a = [\n  1, # comment\n  [\"b\"],\n  {c = 1, d.e = 2},\n]\nb = []\nc = {}").Rets(
+			eq(MakeMap("a", MakeList(1, MakeList("b"), MakeMap("c", 1, "d", MakeMap("e", 2))),
+				"b", EmptyList, "c", EmptyMap)), nil),
+
+		// Keys and tables.
+		Args("a.\"b.c\" . 'd' = 1\n[t]\nx = 1\n[t.u]\n[v.w]\ny = 2\n[v]\nz = 3").Rets(
+			eq(MakeMap("a", MakeMap("b.c", MakeMap("d", 1)),
+				"t", MakeMap("x", 1, "u", EmptyMap),
+				"v", MakeMap("w", MakeMap("y", 2), "z", 3))), nil),
+		Args("[[a]]\nx = 1\n[a.sub]\ny = 2\n[[a]]\n[[a.b]]\nz = 3").Rets(
+			eq(MakeMap("a", MakeList(MakeMap("x", 1, "sub", MakeMap("y", 2)),
+				MakeMap("b", MakeList(MakeMap("z", 3)))))), nil),
+		Args("[fruit]\napple.color = 'red'\napple.taste.sweet = true\n[fruit.apple.texture]\nsmooth = true").Rets(
+			eq(MakeMap("fruit", MakeMap("apple", MakeMap("color", "red",
+				"taste", MakeMap("sweet", true), "texture", MakeMap("smooth", true))))), nil),
+
+		// Errors.
+		Args("a = 1\na = 2").Rets(nil, tt.Any),
+		Args("[a]\n[a]").Rets(nil, tt.Any),
+		Args("a.b = 1\n[a]").Rets(nil, tt.Any),
+		Args("[a.b.c]\n[a]\nb.c.d = 1").Rets(nil, tt.Any),
+		Args("a = {b = 1}\n[a.c]").Rets(nil, tt.Any),
+		Args("a = {b = 1}\na.c = 1").Rets(nil, tt.Any),
+		Args("a = [1]\n[[a]]").Rets(nil, tt.Any),
+		Args("a = 1\n[a.b]").Rets(nil, tt.Any),
+		Args("a = 1 2").Rets(nil, tt.Any),
+		Args("a =").Rets(nil, tt.Any),
+		Args("= 1").Rets(nil, tt.Any),
+		Args("a").Rets(nil, tt.Any),
+		Args("[a").Rets(nil, tt.Any),
+		Args("a = 01").Rets(nil, tt.Any),
+		Args("a = 1__0").Rets(nil, tt.Any),
+		Args("a = 2023-13-01").Rets(nil, tt.Any),
+		Args("a = .5").Rets(nil, tt.Any),
+		Args("a = \"x").Rets(nil, tt.Any),
+		Args("a = \"\\x41\"").Rets(nil, tt.Any),
+		Args("a = \"\x01\"").Rets(nil, tt.Any),
+		Args("a = '''x").Rets(nil, tt.Any),
+		Args("a = [1 2]").Rets(nil, tt.Any),
+		Args("a = {b = 1,}").Rets(nil, tt.Any),
+		Args("a = {b = 1\n}").Rets(nil, tt.Any),
+		Args("a = "+strings.Repeat("[", maxTOMLDepth+1)).Rets(nil, tt.Any),
+	)
+}
+
+func TestDecodeTOML_ErrorLine(t *testing.T) {
+	_, err := decodeTOML("a = 1\n\n[t]\na = 2\na = 3")
+	want := "invalid TOML on line 5: a is defined more than once"
+	if err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+}
+
+func TestTOML_RoundTrip(t *testing.T) {
+	values := []any{
+		MakeMap("list", MakeList("a", 1, 1.5, bigInt(z), MakeList(MakeMap("x", "y"))),
+			"nested", MakeMap("empty", EmptyMap, "a.b", MakeMap("c", "d"), "bool", true),
+			"tables", MakeList(MakeMap("x", 1, "sub", MakeMap("y", 2)), EmptyMap)),
+		EmptyOrderedMap.Assoc("z", MakeMap("b", 1, "a", 2)).Assoc("a", "#"),
+	}
+	for _, v := range values {
+		buf, err := AppendTOML(nil, v)
+		if err != nil {
+			t.Fatalf("AppendTOML(%s) -> error %v", ReprPlain(v), err)
+		}
+		got, err := decodeTOML(string(buf))
+		if err != nil || !Equal(got, v) {
+			t.Errorf("round trip of %s -> %s, %v", ReprPlain(v), ReprPlain(got), err)
+		}
+		// The order of keys is preserved when writing decoded maps.
+		buf2, _ := AppendTOML(nil, got)
+		if string(buf2) != string(buf) {
+			t.Errorf("re-encoding %s -> %q, want %q", ReprPlain(got), buf2, buf)
+		}
+	}
+}
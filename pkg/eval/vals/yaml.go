@@ -0,0 +1,1236 @@
+package vals
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The maximum nesting depth of collections when decoding YAML.
+const maxYAMLDepth = 1000
+
+// YAMLDecoder decodes a stream of YAML documents into Elvish values.
+//
+// Mappings are decoded as maps that preserve the order of their keys (see
+// [EmptyOrderedMap]), and sequences as lists. Keys of mappings must be scalars
+// and are always decoded as strings. Plain scalars are resolved according to
+// the core schema of YAML 1.2, which turns them into $nil, booleans, exact
+// integers or floating-point numbers when they look like one; other scalars
+// are decoded as strings.
+//
+// Anchors, aliases and the merge key "<<" of YAML 1.1 are supported. The core
+// tags (like !!str) change how scalars are resolved; other tags are ignored.
+// Complex mapping keys (those introduced by "?" and collections used as keys)
+// are not supported.
+type YAMLDecoder struct {
+	r io.Reader
+	p *yamlParser
+}
+
+// NewYAMLDecoder returns a YAMLDecoder reading from r.
+func NewYAMLDecoder(r io.Reader) *YAMLDecoder {
+	return &YAMLDecoder{r: r}
+}
+
+// Decode decodes the next document. It returns io.EOF if there are no more
+// documents.
+//
+// Since parsing YAML requires lookahead, all of the input is read on the first
+// call.
+func (d *YAMLDecoder) Decode() (any, error) {
+	if d.p == nil {
+		data, err := io.ReadAll(d.r)
+		if err != nil {
+			return nil, err
+		}
+		src := strings.TrimPrefix(string(data), "\ufeff")
+		d.p = &yamlParser{
+			src: strings.ReplaceAll(src, "\r\n", "\n"), anchors: make(map[string]any)}
+	}
+	return d.p.parseDocument()
+}
+
+type yamlParser struct {
+	src     string
+	pos     int
+	depth   int
+	anchors map[string]any
+}
+
+// Where a block node appears.
+type yamlContext int
+
+const (
+	yamlInDocument yamlContext = iota
+	yamlInMapping
+	yamlInSequence
+)
+
+func (p *yamlParser) errorf(format string, args ...any) error {
+	line := 1 + strings.Count(p.src[:p.pos], "\n")
+	return fmt.Errorf("invalid YAML on line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (p *yamlParser) unexpected() error {
+	if p.eof() {
+		return p.errorf("unexpected end of input")
+	}
+	r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+	return p.errorf("unexpected %q", r)
+}
+
+func (p *yamlParser) eof() bool { return p.pos >= len(p.src) }
+
+// Returns the byte i bytes after the current position, or 0 if that is beyond
+// the end of input.
+func (p *yamlParser) peekAt(i int) byte {
+	if p.pos+i < len(p.src) {
+		return p.src[p.pos+i]
+	}
+	return 0
+}
+
+func (p *yamlParser) peek() byte { return p.peekAt(0) }
+
+// Returns the column of the current position, starting from 0.
+func (p *yamlParser) col() int {
+	return p.pos - (strings.LastIndexByte(p.src[:p.pos], '\n') + 1)
+}
+
+func (p *yamlParser) enter() error {
+	p.depth++
+	if p.depth > maxYAMLDepth {
+		return p.errorf("data nested too deeply")
+	}
+	return nil
+}
+
+func (p *yamlParser) leave() { p.depth-- }
+
+// Reports whether b separates tokens. The 0 returned by peekAt at the end of
+// input also counts.
+func isYAMLSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == 0
+}
+
+func isYAMLFlowIndicator(b byte) bool {
+	return b == ',' || b == '[' || b == ']' || b == '{' || b == '}'
+}
+
+func (p *yamlParser) skipBlanks() {
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.pos++
+	}
+}
+
+func (p *yamlParser) skipComment() {
+	if p.peek() == '#' {
+		for !p.eof() && p.src[p.pos] != '\n' {
+			p.pos++
+		}
+	}
+}
+
+// Reports whether the rest of the line is empty or only has a comment.
+func (p *yamlParser) atLineEnd() bool {
+	return p.eof() || p.peek() == '\n' || p.peek() == '#'
+}
+
+// Skips blanks, comments and line breaks until the next content.
+func (p *yamlParser) skipToContent() error {
+	atLineStart := p.col() == 0
+	tab := false
+	for !p.eof() {
+		switch p.src[p.pos] {
+		case ' ':
+		case '\t':
+			tab = true
+		case '\n':
+			atLineStart, tab = true, false
+		case '#':
+			p.skipComment()
+			continue
+		default:
+			if atLineStart && tab {
+				return p.errorf("tabs cannot be used for indentation")
+			}
+			return nil
+		}
+		p.pos++
+	}
+	return nil
+}
+
+// Reports whether there is a document marker, "---" or "...", at the current
+// position.
+func (p *yamlParser) atDocMarker() bool {
+	rest := p.src[p.pos:]
+	return p.col() == 0 && (strings.HasPrefix(rest, "---") || strings.HasPrefix(rest, "...")) &&
+		isYAMLSpace(p.peekAt(3))
+}
+
+func (p *yamlParser) atSeqEntry() bool {
+	return p.peek() == '-' && isYAMLSpace(p.peekAt(1))
+}
+
+func (p *yamlParser) parseDocument() (any, error) {
+	for {
+		if err := p.skipToContent(); err != nil {
+			return nil, err
+		}
+		if p.eof() {
+			return nil, io.EOF
+		}
+		if p.col() == 0 && p.peek() == '%' {
+			// Directives are ignored.
+			for !p.eof() && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+			continue
+		}
+		if p.atDocMarker() && p.src[p.pos] == '.' {
+			p.pos += 3
+			continue
+		}
+		break
+	}
+	var v any
+	var err error
+	if p.atDocMarker() {
+		p.pos += 3
+		v, err = p.parseBlockNode(-1, yamlInDocument)
+	} else {
+		v, err = p.parseBlockAt(p.col(), -1, "")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := p.skipToContent(); err != nil {
+		return nil, err
+	}
+	if !p.eof() && !p.atDocMarker() {
+		return nil, p.errorf("unexpected content after the end of a node")
+	}
+	return v, nil
+}
+
+// Parses a block node after a "---", the ":" of a mapping entry or the "-" of
+// a sequence entry. The node can start on the same line or on a following
+// line; in the latter case it must be more indented than the parent
+// collection, except that a sequence in a mapping can have the same
+// indentation. If there is no such node, the node is empty.
+func (p *yamlParser) parseBlockNode(parentIndent int, ctx yamlContext) (any, error) {
+	p.skipBlanks()
+	anchor, tag, err := p.parseProperties()
+	if err != nil {
+		return nil, err
+	}
+	var v any
+	switch {
+	case p.atLineEnd():
+		p.skipComment()
+		if err := p.skipToContent(); err != nil {
+			return nil, err
+		}
+		c := p.col()
+		if p.eof() || p.atDocMarker() {
+			v, err = p.resolve(tag, "", true)
+		} else if c > parentIndent {
+			v, err = p.parseBlockAt(c, parentIndent, tag)
+		} else if c == parentIndent && ctx == yamlInMapping && p.atSeqEntry() {
+			v, err = p.parseBlockSeq(c, true)
+		} else {
+			v, err = p.resolve(tag, "", true)
+		}
+	case ctx == yamlInSequence && anchor == "" && tag == "":
+		// A compact collection, like "- a: b" or "- - a".
+		v, err = p.parseBlockAt(p.col(), parentIndent, "")
+	default:
+		v, err = p.parseInlineNode(parentIndent, tag)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if anchor != "" {
+		p.anchors[anchor] = v
+	}
+	return v, nil
+}
+
+// Parses a block node starting at the current position, which has the given
+// indentation.
+func (p *yamlParser) parseBlockAt(indent, parentIndent int, tag string) (any, error) {
+	if p.atSeqEntry() {
+		return p.parseBlockSeq(indent, false)
+	}
+	pos := p.pos
+	_, _, errKey := p.parseMappingKey()
+	p.pos = pos
+	if errKey == nil {
+		return p.parseBlockMap(indent)
+	}
+	return p.parseInlineNode(parentIndent, tag)
+}
+
+func (p *yamlParser) parseBlockSeq(indent int, inMapping bool) (any, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	l := EmptyList
+	for {
+		p.pos++ // The "-".
+		v, err := p.parseBlockNode(indent, yamlInSequence)
+		if err != nil {
+			return nil, err
+		}
+		l = l.Conj(v)
+		if err := p.skipToContent(); err != nil {
+			return nil, err
+		}
+		if p.eof() || p.atDocMarker() || p.col() < indent {
+			return l, nil
+		}
+		if p.col() > indent {
+			return nil, p.errorf("bad indentation of a sequence entry")
+		}
+		if !p.atSeqEntry() {
+			if inMapping {
+				return l, nil
+			}
+			return nil, p.errorf("expected a sequence entry")
+		}
+	}
+}
+
+func (p *yamlParser) parseBlockMap(indent int) (any, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	m := EmptyOrderedMap
+	var merges []any
+	for {
+		key, plain, err := p.parseMappingKey()
+		if err != nil {
+			return nil, err
+		}
+		isMerge := plain && key == "<<"
+		if _, exists := m.Index(key); exists && !isMerge {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		v, err := p.parseBlockNode(indent, yamlInMapping)
+		if err != nil {
+			return nil, err
+		}
+		if isMerge {
+			merges = append(merges, v)
+		} else {
+			m = m.Assoc(key, v)
+		}
+		if err := p.skipToContent(); err != nil {
+			return nil, err
+		}
+		if p.eof() || p.atDocMarker() || p.col() < indent {
+			break
+		}
+		if p.col() > indent {
+			return nil, p.errorf("bad indentation of a mapping entry")
+		}
+	}
+	return p.merge(m, merges)
+}
+
+// Merges the maps in the values of "<<" keys into m. Keys already in m, and
+// keys in earlier maps, take precedence.
+func (p *yamlParser) merge(m Map, merges []any) (Map, error) {
+	for _, v := range merges {
+		var ms []any
+		if l, ok := v.(List); ok {
+			for it := l.Iterator(); it.HasElem(); it.Next() {
+				ms = append(ms, it.Elem())
+			}
+		} else {
+			ms = []any{v}
+		}
+		for _, mm := range ms {
+			mm, ok := mm.(Map)
+			if !ok {
+				return nil, p.errorf("value of << must be a map or a list of maps")
+			}
+			for it := mm.Iterator(); it.HasElem(); it.Next() {
+				k, v := it.Elem()
+				if _, exists := m.Index(k); !exists {
+					m = m.Assoc(k, v)
+				}
+			}
+		}
+	}
+	return m, nil
+}
+
+// Parses a key of a block mapping and the ":" after it. Also returns whether
+// the key is an untagged plain scalar.
+func (p *yamlParser) parseMappingKey() (string, bool, error) {
+	anchor, tag, err := p.parseProperties()
+	if err != nil {
+		return "", false, err
+	}
+	var key string
+	plain := false
+	switch b := p.peek(); {
+	case b == '"' || b == '\'':
+		start := p.pos
+		key, err = p.parseQuoted()
+		if err != nil {
+			return "", false, err
+		}
+		if strings.Contains(p.src[start:p.pos], "\n") {
+			return "", false, p.errorf("mapping keys cannot span multiple lines")
+		}
+	case b == '*':
+		v, err := p.parseAlias()
+		if err != nil {
+			return "", false, err
+		}
+		s, ok := v.(string)
+		if !ok {
+			return "", false, p.errorf("mapping keys must be scalars")
+		}
+		key = s
+	case p.atPlainStart(false):
+		key = p.scanPlainLine(false)
+		plain = tag == ""
+	default:
+		return "", false, p.errorf("expected a mapping key")
+	}
+	p.skipBlanks()
+	if p.peek() != ':' || !isYAMLSpace(p.peekAt(1)) {
+		return "", false, p.errorf("expected ':' after a mapping key")
+	}
+	p.pos++
+	if anchor != "" {
+		p.anchors[anchor] = key
+	}
+	return key, plain, nil
+}
+
+// Parses a node that is not a block collection, which must be followed by the
+// end of line or a comment. Multi-line plain scalars and block scalars must be
+// more indented than parentIndent.
+func (p *yamlParser) parseInlineNode(parentIndent int, tag string) (any, error) {
+	anchor, tag2, err := p.parseProperties()
+	if err != nil {
+		return nil, err
+	}
+	if tag2 != "" {
+		tag = tag2
+	}
+	var v any
+	switch b := p.peek(); {
+	case b == '|' || b == '>':
+		s, err := p.parseBlockScalar(parentIndent)
+		if err != nil {
+			return nil, err
+		}
+		v, err = p.resolve(tag, s, false)
+		if err != nil {
+			return nil, err
+		}
+		if anchor != "" {
+			p.anchors[anchor] = v
+		}
+		// Block scalars already consume the line breaks after them.
+		return v, nil
+	case b == '[' || b == '{':
+		v, err = p.parseFlow()
+		if err == nil {
+			p.skipBlanks()
+			if p.peek() == ':' {
+				return nil, p.errorf("complex mapping keys are not supported")
+			}
+		}
+	case b == '"' || b == '\'':
+		var s string
+		s, err = p.parseQuoted()
+		if err == nil {
+			v, err = p.resolve(tag, s, false)
+		}
+	case b == '*':
+		v, err = p.parseAlias()
+	default:
+		var s string
+		s, err = p.parsePlain(parentIndent, false)
+		if err == nil {
+			v, err = p.resolve(tag, s, true)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if anchor != "" {
+		p.anchors[anchor] = v
+	}
+	p.skipBlanks()
+	if p.peek() == ':' && isYAMLSpace(p.peekAt(1)) {
+		return nil, p.errorf("mapping values are not allowed here")
+	}
+	if !p.atLineEnd() {
+		return nil, p.unexpected()
+	}
+	return v, nil
+}
+
+// Parses the anchor and tag of a node, if there are any.
+func (p *yamlParser) parseProperties() (anchor, tag string, err error) {
+	for {
+		switch p.peek() {
+		case '&':
+			anchor = p.scanName()
+			if anchor == "" {
+				return "", "", p.errorf("empty anchor name")
+			}
+		case '!':
+			start := p.pos
+			for !isYAMLSpace(p.peek()) && !isYAMLFlowIndicator(p.peek()) {
+				p.pos++
+			}
+			tag = p.src[start:p.pos]
+		default:
+			return anchor, tag, nil
+		}
+		p.skipBlanks()
+	}
+}
+
+// Scans the name of an anchor or alias, after the "&" or "*".
+func (p *yamlParser) scanName() string {
+	p.pos++
+	start := p.pos
+	for b := p.peek(); b == '-' || b == '_' || b >= 0x80 ||
+		'0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'; b = p.peek() {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *yamlParser) parseAlias() (any, error) {
+	name := p.scanName()
+	v, ok := p.anchors[name]
+	if !ok {
+		return nil, p.errorf("undefined alias %q", name)
+	}
+	return v, nil
+}
+
+var (
+	yamlIntRegexp   = regexp.MustCompile(`^(?:[-+]?[0-9]+|0o[0-7]+|0x[0-9a-fA-F]+)$`)
+	yamlFloatRegexp = regexp.MustCompile(`^[-+]?(?:\.[0-9]+|[0-9]+(?:\.[0-9]*)?)(?:[eE][-+]?[0-9]+)?$`)
+)
+
+// Resolves a plain scalar according to the core schema of YAML 1.2.
+func resolveYAMLPlain(s string) any {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF":
+		return math.Inf(1)
+	case "-.inf", "-.Inf", "-.INF":
+		return math.Inf(-1)
+	case ".nan", ".NaN", ".NAN":
+		return math.NaN()
+	}
+	if yamlIntRegexp.MatchString(s) {
+		z := new(big.Int)
+		switch {
+		case strings.HasPrefix(s, "0o"):
+			z.SetString(s[2:], 8)
+		case strings.HasPrefix(s, "0x"):
+			z.SetString(s[2:], 16)
+		default:
+			z.SetString(s, 10)
+		}
+		return NormalizeBigInt(z)
+	}
+	if yamlFloatRegexp.MatchString(s) {
+		// Numbers that are too large become infinities.
+		f, _ := strconv.ParseFloat(s, 64)
+		return f
+	}
+	return s
+}
+
+// Resolves a scalar according to its tag. Untagged plain scalars are resolved
+// with resolveYAMLPlain, and other untagged scalars are strings.
+func (p *yamlParser) resolve(tag, s string, plain bool) (any, error) {
+	if tag == "!" || tag == "!!str" {
+		return s, nil
+	}
+	if tag == "" || !strings.HasPrefix(tag, "!!") {
+		if plain {
+			return resolveYAMLPlain(s), nil
+		}
+		return s, nil
+	}
+	v := resolveYAMLPlain(s)
+	ok := false
+	switch tag {
+	case "!!null":
+		ok = v == nil
+	case "!!bool":
+		_, ok = v.(bool)
+	case "!!int":
+		switch v.(type) {
+		case int, *big.Int:
+			ok = true
+		}
+	case "!!float":
+		switch x := v.(type) {
+		case float64:
+			ok = true
+		case int:
+			v, ok = float64(x), true
+		case *big.Int:
+			v, _ = new(big.Float).SetInt(x).Float64()
+			ok = true
+		}
+	default:
+		// Other tags, like !!map and !!binary, are ignored.
+		if plain {
+			return v, nil
+		}
+		return s, nil
+	}
+	if !ok {
+		return nil, p.errorf("%q is not a valid %s", s, tag)
+	}
+	return v, nil
+}
+
+// Reports whether a plain scalar can start at the current position.
+func (p *yamlParser) atPlainStart(flow bool) bool {
+	switch b := p.peek(); b {
+	case 0, ' ', '\t', '\n', '#', ',', '[', ']', '{', '}', '&', '*', '!', '|', '>', '\'', '"', '%', '@', '`':
+		return false
+	case '-', '?', ':':
+		next := p.peekAt(1)
+		return !isYAMLSpace(next) && !(flow && isYAMLFlowIndicator(next))
+	}
+	return true
+}
+
+// Parses a plain scalar. In block context, it can continue on the following
+// lines if they are more indented than parentIndent. In flow context, it also
+// ends before flow indicators.
+func (p *yamlParser) parsePlain(parentIndent int, flow bool) (string, error) {
+	if !p.atPlainStart(flow) {
+		switch {
+		case p.atSeqEntry():
+			return "", p.errorf("sequence entries are not allowed here")
+		case p.peek() == '?' && isYAMLSpace(p.peekAt(1)):
+			return "", p.errorf("complex mapping keys are not supported")
+		}
+		return "", p.unexpected()
+	}
+	var sb strings.Builder
+	sb.WriteString(p.scanPlainLine(flow))
+	for p.peek() == '\n' {
+		// Look for a continuation line.
+		q, lineStart, breaks := p.pos, p.pos, 0
+		for q < len(p.src) && (p.src[q] == ' ' || p.src[q] == '\t' || p.src[q] == '\n') {
+			if p.src[q] == '\n' {
+				breaks++
+				lineStart = q + 1
+			}
+			q++
+		}
+		if q == len(p.src) || p.src[q] == '#' || (!flow && q-lineStart <= parentIndent) ||
+			(flow && isYAMLFlowIndicator(p.src[q])) {
+			break
+		}
+		pos := p.pos
+		p.pos = q
+		if p.atDocMarker() {
+			p.pos = pos
+			break
+		}
+		if breaks == 1 {
+			sb.WriteByte(' ')
+		} else {
+			sb.WriteString(strings.Repeat("\n", breaks-1))
+		}
+		sb.WriteString(p.scanPlainLine(flow))
+	}
+	return sb.String(), nil
+}
+
+// Scans a plain scalar until the end of line or anything that ends it, and
+// returns it without trailing blanks.
+func (p *yamlParser) scanPlainLine(flow bool) string {
+	start, end := p.pos, p.pos
+	for !p.eof() {
+		b := p.src[p.pos]
+		if b == '\n' ||
+			b == ':' && (isYAMLSpace(p.peekAt(1)) || flow && isYAMLFlowIndicator(p.peekAt(1))) ||
+			b == '#' && p.pos > start && (p.src[p.pos-1] == ' ' || p.src[p.pos-1] == '\t') ||
+			flow && isYAMLFlowIndicator(b) {
+			break
+		}
+		p.pos++
+		if b != ' ' && b != '\t' {
+			end = p.pos
+		}
+	}
+	return p.src[start:end]
+}
+
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n",
+	'v': "\v", 'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"",
+	'/': "/", '\\': "\\", 'N': "\u0085", '_': "\u00a0", 'L': "\u2028",
+	'P': "\u2029",
+}
+
+var yamlHexEscapeLens = map[byte]int{'x': 2, 'u': 4, 'U': 8}
+
+// Parses a single- or double-quoted scalar.
+func (p *yamlParser) parseQuoted() (string, error) {
+	q := p.src[p.pos]
+	p.pos++
+	var sb strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated quoted scalar")
+		}
+		switch b := p.src[p.pos]; {
+		case b == q:
+			if q == '\'' && p.peekAt(1) == '\'' {
+				sb.WriteByte('\'')
+				p.pos += 2
+				continue
+			}
+			p.pos++
+			return sb.String(), nil
+		case b == '\\' && q == '"':
+			if p.peekAt(1) == '\n' {
+				// An escaped line break is removed, along with the leading
+				// blanks of the next line.
+				p.pos += 2
+				p.skipBlanks()
+				continue
+			}
+			if s, ok := yamlEscapes[p.peekAt(1)]; ok {
+				sb.WriteString(s)
+				p.pos += 2
+				continue
+			}
+			if n, ok := yamlHexEscapeLens[p.peekAt(1)]; ok && p.pos+2+n <= len(p.src) {
+				r, err := strconv.ParseUint(p.src[p.pos+2:p.pos+2+n], 16, 32)
+				if err == nil && utf8.ValidRune(rune(r)) {
+					sb.WriteRune(rune(r))
+					p.pos += 2 + n
+					continue
+				}
+			}
+			return "", p.errorf("invalid escape sequence")
+		case b == ' ' || b == '\t':
+			// Trailing blanks of lines are removed.
+			start := p.pos
+			p.skipBlanks()
+			if p.peek() != '\n' {
+				sb.WriteString(p.src[start:p.pos])
+			}
+		case b == '\n':
+			// A single line break becomes a space, and each of the following
+			// empty lines becomes a line break.
+			breaks := 0
+			for p.peek() == '\n' {
+				breaks++
+				p.pos++
+				p.skipBlanks()
+			}
+			if breaks == 1 {
+				sb.WriteByte(' ')
+			} else {
+				sb.WriteString(strings.Repeat("\n", breaks-1))
+			}
+		default:
+			sb.WriteByte(b)
+			p.pos++
+		}
+	}
+}
+
+// Parses a literal (|) or folded (>) block scalar, including the line breaks
+// after it.
+func (p *yamlParser) parseBlockScalar(parentIndent int) (string, error) {
+	folded := p.src[p.pos] == '>'
+	p.pos++
+	// The chomping indicator: '-' to strip the final line break, '+' to keep
+	// all trailing line breaks, or 0 to keep only the final one.
+	chomp := byte(0)
+	// The indentation of the content, or -1 if it is to be detected from the
+	// first non-empty line.
+	indent := -1
+	for i := 0; i < 2; i++ {
+		switch b := p.peek(); {
+		case (b == '-' || b == '+') && chomp == 0:
+			chomp = b
+			p.pos++
+		case '1' <= b && b <= '9' && indent == -1:
+			indent = max(parentIndent, 0) + int(b-'0')
+			p.pos++
+		}
+	}
+	p.skipBlanks()
+	p.skipComment()
+	if !p.eof() && p.peek() != '\n' {
+		return "", p.errorf("invalid block scalar header")
+	}
+	if !p.eof() {
+		p.pos++
+	}
+
+	var lines []string
+	for !p.eof() {
+		lineEnd := strings.IndexByte(p.src[p.pos:], '\n')
+		if lineEnd == -1 {
+			lineEnd = len(p.src)
+		} else {
+			lineEnd += p.pos
+		}
+		line := p.src[p.pos:lineEnd]
+		spaces := len(line) - len(strings.TrimLeft(line, " "))
+		if spaces == len(line) && (indent == -1 || spaces <= indent) {
+			lines = append(lines, "")
+		} else {
+			if indent == -1 {
+				if spaces <= parentIndent {
+					break
+				}
+				indent = spaces
+			}
+			if spaces < indent || p.atDocMarker() {
+				break
+			}
+			lines = append(lines, line[indent:])
+		}
+		p.pos = min(lineEnd+1, len(p.src))
+	}
+
+	n := len(lines)
+	for n > 0 && lines[n-1] == "" {
+		n--
+	}
+	var sb strings.Builder
+	i := 0
+	for ; i < n && lines[i] == ""; i++ {
+		sb.WriteByte('\n')
+	}
+	moreIndented := func(s string) bool { return s[0] == ' ' || s[0] == '\t' }
+	for i < n {
+		sb.WriteString(lines[i])
+		j := i + 1
+		for j < n && lines[j] == "" {
+			j++
+		}
+		if j == n {
+			break
+		}
+		empties := j - i - 1
+		if folded && !moreIndented(lines[i]) && !moreIndented(lines[j]) {
+			if empties == 0 {
+				sb.WriteByte(' ')
+			} else {
+				sb.WriteString(strings.Repeat("\n", empties))
+			}
+		} else {
+			sb.WriteString(strings.Repeat("\n", empties+1))
+		}
+		i = j
+	}
+	if n > 0 && chomp != '-' {
+		sb.WriteByte('\n')
+	}
+	if chomp == '+' {
+		sb.WriteString(strings.Repeat("\n", len(lines)-n))
+	}
+	return sb.String(), nil
+}
+
+// The result of parsing a node in flow context. For scalars, str is the string
+// before resolution, which is used when the node is a mapping key.
+type yamlFlowNode struct {
+	v      any
+	str    string
+	scalar bool
+	// Whether the node is an untagged plain scalar.
+	plain bool
+}
+
+func (n yamlFlowNode) key(p *yamlParser) (string, error) {
+	if !n.scalar {
+		return "", p.errorf("complex mapping keys are not supported")
+	}
+	return n.str, nil
+}
+
+// Skips blanks, line breaks and comments in flow context.
+func (p *yamlParser) skipFlowSpace() {
+	for !p.eof() {
+		switch p.src[p.pos] {
+		case ' ', '\t', '\n':
+			p.pos++
+		case '#':
+			p.skipComment()
+		default:
+			return
+		}
+	}
+}
+
+func (p *yamlParser) parseFlow() (any, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	if p.src[p.pos] == '[' {
+		return p.parseFlowSeq()
+	}
+	return p.parseFlowMap()
+}
+
+func (p *yamlParser) parseFlowSeq() (any, error) {
+	p.pos++
+	l := EmptyList
+	for {
+		p.skipFlowSpace()
+		if p.peek() == ']' {
+			p.pos++
+			return l, nil
+		}
+		n, err := p.parseFlowNode()
+		if err != nil {
+			return nil, err
+		}
+		v := n.v
+		p.skipFlowSpace()
+		if p.peek() == ':' {
+			// A mapping with a single pair.
+			key, err := n.key(p)
+			if err != nil {
+				return nil, err
+			}
+			p.pos++
+			value, err := p.parseFlowValue()
+			if err != nil {
+				return nil, err
+			}
+			v = EmptyOrderedMap.Assoc(key, value)
+		}
+		l = l.Conj(v)
+		p.skipFlowSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return l, nil
+		default:
+			if p.eof() {
+				return nil, p.errorf("unterminated flow sequence")
+			}
+			return nil, p.errorf("expected ',' or ']' in flow sequence")
+		}
+	}
+}
+
+func (p *yamlParser) parseFlowMap() (any, error) {
+	p.pos++
+	m := EmptyOrderedMap
+	var merges []any
+	for {
+		p.skipFlowSpace()
+		if p.peek() == '}' {
+			p.pos++
+			return p.merge(m, merges)
+		}
+		n, err := p.parseFlowNode()
+		if err != nil {
+			return nil, err
+		}
+		key, err := n.key(p)
+		if err != nil {
+			return nil, err
+		}
+		isMerge := n.plain && key == "<<"
+		if _, exists := m.Index(key); exists && !isMerge {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		p.skipFlowSpace()
+		var v any
+		if p.peek() == ':' {
+			p.pos++
+			v, err = p.parseFlowValue()
+			if err != nil {
+				return nil, err
+			}
+		}
+		if isMerge {
+			merges = append(merges, v)
+		} else {
+			m = m.Assoc(key, v)
+		}
+		p.skipFlowSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return p.merge(m, merges)
+		default:
+			if p.eof() {
+				return nil, p.errorf("unterminated flow mapping")
+			}
+			return nil, p.errorf("expected ',' or '}' in flow mapping")
+		}
+	}
+}
+
+// Parses the value after a ":" in flow context, which may be empty.
+func (p *yamlParser) parseFlowValue() (any, error) {
+	p.skipFlowSpace()
+	switch p.peek() {
+	case ',', ']', '}':
+		return nil, nil
+	}
+	n, err := p.parseFlowNode()
+	return n.v, err
+}
+
+func (p *yamlParser) parseFlowNode() (yamlFlowNode, error) {
+	anchor, tag, err := p.parseProperties()
+	if err != nil {
+		return yamlFlowNode{}, err
+	}
+	var n yamlFlowNode
+	switch b := p.peek(); {
+	case b == '[' || b == '{':
+		n.v, err = p.parseFlow()
+	case b == '"' || b == '\'':
+		n.str, err = p.parseQuoted()
+		if err == nil {
+			n.scalar = true
+			n.v, err = p.resolve(tag, n.str, false)
+		}
+	case b == '*':
+		n.v, err = p.parseAlias()
+		n.str, n.scalar = n.v.(string)
+	default:
+		n.str, err = p.parsePlain(-1, true)
+		if err == nil {
+			n.scalar, n.plain = true, tag == ""
+			n.v, err = p.resolve(tag, n.str, true)
+		}
+	}
+	if err != nil {
+		return yamlFlowNode{}, err
+	}
+	if anchor != "" {
+		p.anchors[anchor] = n.v
+	}
+	return n, nil
+}
+
+var errYAMLInvalidUTF8 = errors.New("cannot encode string with invalid UTF-8 to YAML")
+
+// AppendYAML appends v to buf as a YAML document in block style. The keys of
+// ordered maps (see [EmptyOrderedMap]) are written in order, and those of
+// other maps are sorted; all keys must be strings. Strings are written as plain
+// scalars when they would be read back as the same strings, and are
+// double-quoted otherwise. Rational numbers are written as strings like "1/2".
+func AppendYAML(buf []byte, v any) ([]byte, error) {
+	return appendYAMLNode(buf, v, 0, yamlInDocument)
+}
+
+// Appends a node that is on its own (ctx == yamlInDocument), after a "key:",
+// or after a "-". The indent is that of the entries of the node if it is a
+// block collection.
+func appendYAMLNode(buf []byte, v any, indent int, ctx yamlContext) ([]byte, error) {
+	switch Kind(v) {
+	case "list":
+		var elems []any
+		err := Iterate(v, func(elem any) bool {
+			elems = append(elems, elem)
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(elems) == 0 {
+			break
+		}
+		buf = startYAMLCollection(buf, ctx)
+		for i, elem := range elems {
+			if i > 0 || ctx != yamlInSequence {
+				buf = appendSpaces(buf, indent)
+			}
+			buf = append(buf, '-')
+			if buf, err = appendYAMLNode(buf, elem, indent+2, yamlInSequence); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case "map":
+		keys, err := EncodingKeys(v)
+		if err != nil {
+			return nil, err
+		}
+		if len(keys) == 0 {
+			break
+		}
+		buf = startYAMLCollection(buf, ctx)
+		for i, k := range keys {
+			ks, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("cannot encode map key of kind %s to YAML", Kind(k))
+			}
+			if i > 0 || ctx != yamlInSequence {
+				buf = appendSpaces(buf, indent)
+			}
+			if buf, err = appendYAMLString(buf, ks); err != nil {
+				return nil, err
+			}
+			buf = append(buf, ':')
+			elem, err := Index(v, k)
+			if err != nil {
+				return nil, err
+			}
+			if buf, err = appendYAMLNode(buf, elem, indent+2, yamlInMapping); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	}
+	if ctx != yamlInDocument {
+		buf = append(buf, ' ')
+	}
+	buf, err := appendYAMLScalar(buf, v)
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, '\n'), nil
+}
+
+func startYAMLCollection(buf []byte, ctx yamlContext) []byte {
+	switch ctx {
+	case yamlInMapping:
+		return append(buf, '\n')
+	case yamlInSequence:
+		return append(buf, ' ')
+	}
+	return buf
+}
+
+func appendSpaces(buf []byte, n int) []byte {
+	for i := 0; i < n; i++ {
+		buf = append(buf, ' ')
+	}
+	return buf
+}
+
+// Appends a scalar, or an empty list or map.
+func appendYAMLScalar(buf []byte, v any) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(buf, "null"...), nil
+	case bool:
+		return strconv.AppendBool(buf, v), nil
+	case string:
+		return appendYAMLString(buf, v)
+	case int:
+		return strconv.AppendInt(buf, int64(v), 10), nil
+	case *big.Int:
+		return v.Append(buf, 10), nil
+	case *big.Rat:
+		return appendYAMLString(buf, v.String())
+	case float64:
+		switch {
+		case math.IsInf(v, 1):
+			return append(buf, ".inf"...), nil
+		case math.IsInf(v, -1):
+			return append(buf, "-.inf"...), nil
+		case math.IsNaN(v):
+			return append(buf, ".nan"...), nil
+		}
+		return append(buf, formatFloat64(v)...), nil
+	}
+	switch Kind(v) {
+	case "list":
+		return append(buf, "[]"...), nil
+	case "map":
+		return append(buf, "{}"...), nil
+	}
+	return nil, fmt.Errorf("cannot encode value of kind %s to YAML", Kind(v))
+}
+
+func appendYAMLString(buf []byte, s string) ([]byte, error) {
+	if !utf8.ValidString(s) {
+		return nil, errYAMLInvalidUTF8
+	}
+	if isYAMLPlainSafe(s) {
+		return append(buf, s...), nil
+	}
+	buf = append(buf, '"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			buf = append(buf, '\\', byte(r))
+		case r == '\n':
+			buf = append(buf, `\n`...)
+		case r == '\t':
+			buf = append(buf, `\t`...)
+		case r == '\r':
+			buf = append(buf, `\r`...)
+		case unicode.IsPrint(r):
+			buf = utf8.AppendRune(buf, r)
+		case r <= 0xff:
+			buf = fmt.Appendf(buf, `\x%02x`, r)
+		case r <= 0xffff:
+			buf = fmt.Appendf(buf, `\u%04x`, r)
+		default:
+			buf = fmt.Appendf(buf, `\U%08x`, r)
+		}
+	}
+	return append(buf, '"'), nil
+}
+
+// Reports whether s can be written as a plain scalar in block context and read
+// back as the same string.
+func isYAMLPlainSafe(s string) bool {
+	if s == "" || s == "<<" || strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@` ") ||
+		s[len(s)-1] == ' ' || s[len(s)-1] == ':' ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") {
+		return false
+	}
+	if _, ok := resolveYAMLPlain(s).(string); !ok {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
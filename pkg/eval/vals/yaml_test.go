@@ -0,0 +1,219 @@
+package vals
+
+import (
+	"io"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+
+	"src.elv.sh/pkg/tt"
+)
+
+func encodeYAML(v any) (string, error) {
+	buf, err := AppendYAML(nil, v)
+	return string(buf), err
+}
+
+func decodeYAML(s string) (any, error) {
+	return NewYAMLDecoder(strings.NewReader(s)).Decode()
+}
+
+// Decodes all the documents, and returns their reprs.
+func decodeAllYAML(s string) ([]string, error) {
+	dec := NewYAMLDecoder(strings.NewReader(s))
+	var reprs []string
+	for {
+		v, err := dec.Decode()
+		if err == io.EOF {
+			return reprs, nil
+		} else if err != nil {
+			return reprs, err
+		}
+		reprs = append(reprs, ReprPlain(v))
+	}
+}
+
+func TestAppendYAML(t *testing.T) {
+	tt.Test(t, encodeYAML,
+		Args("foo").Rets("foo\n", nil),
+		Args(nil).Rets("null\n", nil),
+		Args(true).Rets("true\n", nil),
+		Args(1).Rets("1\n", nil),
+		Args(bigInt(z)).Rets(z+"\n", nil),
+		Args(big.NewRat(1, 2)).Rets("1/2\n", nil),
+		Args(1.0).Rets("1.0\n", nil),
+		Args(math.Inf(-1)).Rets("-.inf\n", nil),
+		Args(math.NaN()).Rets(".nan\n", nil),
+
+		// Strings that would be read back as something else are quoted.
+		Args("").Rets(`""`+"\n", nil),
+		Args("1").Rets(`"1"`+"\n", nil),
+		Args("true").Rets(`"true"`+"\n", nil),
+		Args("~").Rets(`"~"`+"\n", nil),
+		Args("- a").Rets(`"- a"`+"\n", nil),
+		Args("a: b").Rets(`"a: b"`+"\n", nil),
+		Args("a #b").Rets(`"a #b"`+"\n", nil),
+		Args(" a").Rets(`" a"`+"\n", nil),
+		Args("a\n\"b\"\x01").Rets(`"a\n\"b\"\x01"`+"\n", nil),
+		Args("a b:c#d").Rets("a b:c#d\n", nil),
+		Args("\xff").Rets("", errYAMLInvalidUTF8),
+
+		Args(EmptyList).Rets("[]\n", nil),
+		Args(EmptyMap).Rets("{}\n", nil),
+		Args(MakeList("a", MakeList("b", "c"), MakeMap("k", "v", "l", "w"))).Rets(
+			"- a\n- - b\n  - c\n- k: v\n  l: w\n", nil),
+		Args(MakeMap("b", MakeList("x", EmptyList), "a", MakeMap("c", "d"))).Rets(
+			"a:\n  c: d\nb:\n  - x\n  - []\n", nil),
+		// Ordered maps keep their order.
+		Args(EmptyOrderedMap.Assoc("z", 1).Assoc("a", 2)).Rets("z: 1\na: 2\n", nil),
+
+		Args(MakeMap(1, 2)).Rets("", tt.Any),
+		Args(MakeList(func() {})).Rets("", tt.Any),
+	)
+}
+
+func TestYAMLDecoder(t *testing.T) {
+	tt.Test(t, decodeYAML,
+		// Resolution of plain scalars.
+		Args("foo").Rets("foo", nil),
+		Args("~").Rets(nil, nil),
+		Args("Null").Rets(nil, nil),
+		Args("TRUE").Rets(true, nil),
+		Args("false").Rets(false, nil),
+		Args("-12").Rets(-12, nil),
+		Args("0x1F").Rets(31, nil),
+		Args("0o17").Rets(15, nil),
+		Args(z).Rets(bigInt(z), nil),
+		Args("1.5e3").Rets(1500.0, nil),
+		Args(".5").Rets(0.5, nil),
+		Args("-.inf").Rets(math.Inf(-1), nil),
+		Args("1.2.3").Rets("1.2.3", nil),
+		Args("yes").Rets("yes", nil),
+
+		// Quoted scalars.
+		Args(`"1"`).Rets("1", nil),
+		Args(`'it''s'`).Rets("it's", nil),
+		Args(`"\t\u00e9\x41\"\\"`).Rets("\té"+`A"\`, nil),
+		Args("\"a  \n  b\n\n  c\\\n  d\"").Rets("a b\ncd", nil),
+		Args("'a\n  b'").Rets("a b", nil),
+
+		// Plain scalars spanning multiple lines.
+		Args("a\nb\n\nc").Rets("a b\nc", nil),
+		Args("k: a\n  b\n  c # comment").Rets(eq(MakeMap("k", "a b c")), nil),
+		// Comments end plain scalars.
+		Args("k: a\n  b # comment\n  c").Rets(nil, tt.Any),
+
+		// Block scalars.
+		Args("|\n a\n  b\n\n c\n\n").Rets("a\n b\n\nc\n", nil),
+		Args(">\n a\n b\n\n c\n   d\n e\n").Rets("a b\nc\n  d\ne\n", nil),
+		Args("|-\n a\n\n").Rets("a", nil),
+		Args("|+\n a\n\n").Rets("a\n\n", nil),
+		Args("- |2\n   a\n- b").Rets(eq(MakeList(" a\n", "b")), nil),
+		Args("k: |\n  a\n  # not a comment\nl: b").Rets(
+			eq(MakeMap("k", "a\n# not a comment\n", "l", "b")), nil),
+
+		// Block collections.
+		Args("a: 1\nb:\n  c: [x, y]\n  d:\n  - z\n  -\n  - - w\ne:").Rets(
+			eq(MakeMap("a", 1, "b", MakeMap("c", MakeList("x", "y"),
+				"d", MakeList("z", nil, MakeList("w"))), "e", nil)), nil),
+		Args("- a: 1\n  b: 2\n- c").Rets(
+			eq(MakeList(MakeMap("a", 1, "b", 2), "c")), nil),
+		Args("'a b': 1\n\"1\": 2\n3: 3\nnull: 4").Rets(
+			eq(MakeMap("a b", 1, "1", 2, "3", 3, "null", 4)), nil),
+		Args("url: http://x.com:80/#a # comment\n# comment\n").Rets(
+			eq(MakeMap("url", "http://x.com:80/#a")), nil),
+
+		// Flow collections.
+		Args("[a, 'b', [c], {d: e}, f: g, ]").Rets(
+			eq(MakeList("a", "b", MakeList("c"), MakeMap("d", "e"), MakeMap("f", "g"))), nil),
+		Args("{a: [\n  1, # comment\n  2], b, c: }").Rets(
+			eq(MakeMap("a", MakeList(1, 2), "b", nil, "c", nil)), nil),
+		Args(`{"a":1}`).Rets(eq(MakeMap("a", 1)), nil),
+
+		// Anchors, aliases and merge keys.
+		Args("- &a [x]\n- *a\n- &b\n  k: v\n- *b").Rets(
+			eq(MakeList(MakeList("x"), MakeList("x"), MakeMap("k", "v"), MakeMap("k", "v"))), nil),
+		Args("base: &b {x: 1, y: 2}\nd:\n  <<: *b\n  y: 3\n'<<': 4").Rets(
+			eq(MakeMap("base", MakeMap("x", 1, "y", 2), "d", MakeMap("x", 1, "y", 3), "<<", 4)), nil),
+		// Earlier maps take precedence.
+		Args("- &a {x: 1}\n- &b {x: 2, y: 2}\n- <<: [*a, *b]\n- {<<: *b, x: 3}").Rets(
+			eq(MakeList(MakeMap("x", 1), MakeMap("x", 2, "y", 2), MakeMap("x", 1, "y", 2),
+				MakeMap("x", 3, "y", 2))), nil),
+
+		// Tags.
+		Args("!!str 123").Rets("123", nil),
+		Args("! true").Rets("true", nil),
+		Args("!!float 1").Rets(1.0, nil),
+		Args("!!int '12'").Rets(12, nil),
+		Args("!custom 12").Rets(12, nil),
+		Args("!!int abc").Rets(nil, tt.Any),
+
+		// Documents.
+		Args("").Rets(nil, io.EOF),
+		Args("# comment\n").Rets(nil, io.EOF),
+		Args("---").Rets(nil, nil),
+		Args("--- a").Rets("a", nil),
+		Args("%YAML 1.2\n---\na\n...\n").Rets("a", nil),
+
+		// Errors.
+		Args("a: 1\n b: 2").Rets(nil, tt.Any),
+		Args("a: 1\n- b").Rets(nil, tt.Any),
+		Args("- a\nb: 1").Rets(nil, tt.Any),
+		Args("a: b: c").Rets(nil, tt.Any),
+		Args("a: - b").Rets(nil, tt.Any),
+		Args("a:\n\tb: 1").Rets(nil, tt.Any),
+		Args("a: [1, 2").Rets(nil, tt.Any),
+		Args("{a: 1]").Rets(nil, tt.Any),
+		Args("'a").Rets(nil, tt.Any),
+		Args(`"\q"`).Rets(nil, tt.Any),
+		Args("*a").Rets(nil, tt.Any),
+		Args("a: 1\na: 2").Rets(nil, tt.Any),
+		Args("? a\n: b").Rets(nil, tt.Any),
+		Args("[a]: b").Rets(nil, tt.Any),
+		Args("{[a]: b}").Rets(nil, tt.Any),
+		Args("@a").Rets(nil, tt.Any),
+		Args("<<: a").Rets(nil, tt.Any),
+		Args(strings.Repeat("[", maxYAMLDepth+1)).Rets(nil, tt.Any),
+	)
+}
+
+func TestYAMLDecoder_ErrorLine(t *testing.T) {
+	_, err := decodeYAML("a: 1\nb: [\n  c,\n  d: e: f]")
+	want := "invalid YAML on line 4: expected ',' or ']' in flow sequence"
+	if err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+}
+
+func TestYAMLDecoder_MultipleDocuments(t *testing.T) {
+	tt.Test(t, decodeAllYAML,
+		Args("a\n---\n- b\n...\n---\n--- c\n").Rets(
+			[]string{"a", "[b]", "$nil", "c"}, nil),
+		Args("a\n--- [\n").Rets([]string{"a"}, tt.Any),
+	)
+}
+
+func TestYAML_RoundTrip(t *testing.T) {
+	values := []any{
+		MakeMap("list", MakeList("a", 1, 1.5, bigInt(z), "1", "", "x: y", "a\nb"),
+			"nested", MakeMap("empty", EmptyMap, "null", nil, "- x", MakeList(EmptyList)),
+			"bool", true, "<<", "merge"),
+		EmptyOrderedMap.Assoc("z", MakeList(MakeMap("b", 1, "a", 2))).Assoc("a", "#"),
+	}
+	for _, v := range values {
+		buf, err := AppendYAML(nil, v)
+		if err != nil {
+			t.Fatalf("AppendYAML(%s) -> error %v", ReprPlain(v), err)
+		}
+		got, err := decodeYAML(string(buf))
+		if err != nil || !Equal(got, v) {
+			t.Errorf("round trip of %s -> %s, %v", ReprPlain(v), ReprPlain(got), err)
+		}
+		// The order of keys is preserved when writing decoded maps.
+		buf2, _ := AppendYAML(nil, got)
+		if string(buf2) != string(buf) {
+			t.Errorf("re-encoding %s -> %q, want %q", ReprPlain(got), buf2, buf)
+		}
+	}
+}
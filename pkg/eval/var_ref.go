@@ -47,6 +47,8 @@ type scopeSearcher interface {
 	searchLocal(k string) (staticVarInfo, int)
 	searchCapture(k string) (staticVarInfo, int)
 	searchBuiltin(k string, r diag.Ranger) (staticVarInfo, int)
+	// Whether the special namespaces e: and E: are unavailable.
+	isSandboxed() bool
 }
 
 // Resolves a qname into a varRef.
@@ -85,7 +87,7 @@ func resolveVarRefCapture(s scopeSearcher, qname string) *varRef {
 
 func resolveVarRefBuiltin(s scopeSearcher, qname string, r diag.Ranger) *varRef {
 	first, rest := SplitQName(qname)
-	if rest != "" {
+	if rest != "" && !s.isSandboxed() {
 		// Try special namespace first.
 		switch first {
 		case "e:":
@@ -181,6 +183,8 @@ func (cp *compiler) searchBuiltin(k string, r diag.Ranger) (staticVarInfo, int)
 	return info, index
 }
 
+func (cp *compiler) isSandboxed() bool { return cp.sandboxed }
+
 func (fm *Frame) searchLocal(k string) (staticVarInfo, int) {
 	return fm.local.lookup(k)
 }
@@ -192,3 +196,5 @@ func (fm *Frame) searchCapture(k string) (staticVarInfo, int) {
 func (fm *Frame) searchBuiltin(k string, r diag.Ranger) (staticVarInfo, int) {
 	return fm.Evaler.Builtin().lookup(k)
 }
+
+func (fm *Frame) isSandboxed() bool { return false }
@@ -0,0 +1,386 @@
+// Package lint implements a linter for Elvish code, which finds likely
+// mistakes that are not errors for the compiler.
+//
+// The linter works on the parse tree, and follows the static scoping rules of
+// the compiler. It reports:
+//
+//   - Variables and functions declared with var and fn in a function that are
+//     never used.
+//
+//   - Variables and functions declared with var and fn that shadow ones in
+//     an outer scope.
+//
+//   - Code after return, fail, break, continue and exit in the same block,
+//     which is never run.
+//
+//   - String literals that are not numbers passed to numeric commands like +
+//     and <, which fail when they are converted to numbers.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/parse/cmpd"
+)
+
+// ProblemTag parameterizes [diag.Error] to define [Problem].
+type ProblemTag struct{}
+
+// ErrorTag returns "lint problem".
+func (ProblemTag) ErrorTag() string { return "lint problem" }
+
+// Problem is a problem found by the linter.
+type Problem = diag.Error[ProblemTag]
+
+// Lint parses the source and returns the problems found in it, sorted by their
+// positions. It returns an error if the source can't be parsed.
+func Lint(src parse.Source) ([]*Problem, error) {
+	tree, err := parse.Parse(src, parse.Config{})
+	if err != nil {
+		return nil, err
+	}
+	l := &linter{src: src}
+	l.pushScope()
+	l.chunk(tree.Root)
+	l.popScope()
+	sort.SliceStable(l.problems, func(i, j int) bool {
+		return l.problems[i].Context.From < l.problems[j].Context.From
+	})
+	return l.problems, nil
+}
+
+type linter struct {
+	src      parse.Source
+	scopes   []*scope
+	problems []*Problem
+}
+
+type scope struct {
+	// The current declaration of each name. Names of functions have a "~"
+	// suffix, like variables that hold them.
+	names map[string]*decl
+	// All the declarations in the scope, including ones that have been
+	// replaced by a later declaration of the same name.
+	decls []*decl
+}
+
+type decl struct {
+	name string
+	node parse.Node
+	used bool
+	// Whether to report the declaration if it's not used. False for function
+	// arguments, variables of for and try, and all declarations in the
+	// top-level scope, which may be used by other modules.
+	checkUnused bool
+}
+
+func (l *linter) report(r diag.Ranger, format string, args ...any) {
+	l.problems = append(l.problems, &Problem{
+		Message: fmt.Sprintf(format, args...),
+		Context: *diag.NewContext(l.src.Name, l.src.Code, r)})
+}
+
+func (l *linter) pushScope() {
+	l.scopes = append(l.scopes, &scope{names: make(map[string]*decl)})
+}
+
+func (l *linter) popScope() {
+	sc := l.scopes[len(l.scopes)-1]
+	l.scopes = l.scopes[:len(l.scopes)-1]
+	for _, d := range sc.decls {
+		if d.checkUnused && !d.used {
+			l.report(d.node, "%s is declared but not used", describe(d.name))
+		}
+	}
+}
+
+// Declares a name in the current scope.
+func (l *linter) declare(name string, n parse.Node, checkUnused bool) {
+	if name == "" || name == "_" {
+		return
+	}
+	if outer := l.lookup(name, 0, len(l.scopes)-1); outer != nil {
+		l.report(n, "%s shadows one in an outer scope", describe(name))
+	}
+	sc := l.scopes[len(l.scopes)-1]
+	d := &decl{name: name, node: n,
+		checkUnused: checkUnused && len(l.scopes) > 1}
+	sc.names[name] = d
+	sc.decls = append(sc.decls, d)
+}
+
+// Finds the declaration of a name in the scopes in [from, to), searching from
+// the innermost scope. It returns nil if the name is not declared.
+func (l *linter) lookup(name string, from, to int) *decl {
+	for i := to - 1; i >= from; i-- {
+		if d := l.scopes[i].names[name]; d != nil {
+			return d
+		}
+	}
+	return nil
+}
+
+// Marks a variable referenced with the given name, like "x" in $x or
+// "local:x" in $local:x, as used.
+func (l *linter) use(ref string) {
+	_, qname := eval.SplitSigil(ref)
+	first, rest := eval.SplitQName(qname)
+	from, to := 0, len(l.scopes)
+	switch {
+	case rest == "":
+	case first == "local:":
+		qname, from = rest, to-1
+	case first == "up:":
+		qname, to = rest, to-1
+	default:
+		// A variable in a module or a special namespace.
+		return
+	}
+	if d := l.lookup(qname, from, to); d != nil {
+		d.used = true
+	}
+}
+
+// Returns the name of a variable or function for messages.
+func describe(name string) string {
+	if fn, ok := strings.CutSuffix(name, "~"); ok {
+		return "function " + fn
+	}
+	return "variable $" + name
+}
+
+func (l *linter) walk(n parse.Node) {
+	switch n := n.(type) {
+	case *parse.Chunk:
+		l.chunk(n)
+	case *parse.Form:
+		l.form(n)
+	case *parse.Primary:
+		l.primary(n)
+	default:
+		l.walkChildren(n)
+	}
+}
+
+func (l *linter) walkChildren(n parse.Node) {
+	for _, ch := range parse.Children(n) {
+		l.walk(ch)
+	}
+}
+
+// Commands that never continue with the next pipeline.
+var terminators = map[string]bool{
+	"return": true, "fail": true, "break": true, "continue": true, "exit": true,
+}
+
+func (l *linter) chunk(n *parse.Chunk) {
+	for i, pn := range n.Pipelines {
+		l.walk(pn)
+		if i+1 < len(n.Pipelines) && len(pn.Forms) == 1 && !pn.Background {
+			if head, ok := cmpd.StringLiteral(pn.Forms[0].Head); ok && terminators[head] {
+				next := n.Pipelines[i+1]
+				// Pipelines include the spaces after them.
+				text := strings.TrimRight(parse.SourceText(next), " \t")
+				l.report(diag.Ranging{From: next.From, To: next.From + len(text)},
+					"unreachable code after %s", head)
+				break
+			}
+		}
+	}
+}
+
+func (l *linter) form(n *parse.Form) {
+	head, _ := cmpd.StringLiteral(n.Head)
+	switch head {
+	case "var":
+		lhs, rhs := splitAssignment(n.Args)
+		l.walkAll(rhs)
+		for _, cn := range lhs {
+			if name, ok := unqualifiedName(cn); ok {
+				l.declare(name, cn, true)
+			} else {
+				l.walk(cn)
+			}
+		}
+	case "set", "tmp":
+		lhs, rhs := splitAssignment(n.Args)
+		l.walkAll(rhs)
+		for _, cn := range lhs {
+			l.assign(cn)
+		}
+	case "fn":
+		if len(n.Args) > 0 {
+			if name, ok := cmpd.StringLiteral(n.Args[0]); ok {
+				// Declared before the body, so that the function can call
+				// itself.
+				l.declare(name+"~", n.Args[0], true)
+			}
+			l.walkAll(n.Args[1:])
+		}
+	case "for":
+		if len(n.Args) > 0 {
+			l.setOrDeclare(n.Args[0])
+			l.walkAll(n.Args[1:])
+		}
+	case "try":
+		for i, cn := range n.Args {
+			if i > 0 && i+1 < len(n.Args) && isKeyword(n.Args[i-1], "catch") {
+				if _, ok := cmpd.StringLiteral(cn); ok {
+					l.setOrDeclare(cn)
+					continue
+				}
+			}
+			l.walk(cn)
+		}
+	case "del":
+		for _, cn := range n.Args {
+			if pn, ok := cmpd.Primary(cn); ok && pn.Type == parse.Bareword {
+				l.use(pn.Value)
+			} else {
+				l.walk(cn)
+			}
+		}
+	default:
+		if head != "" {
+			l.use(head + "~")
+			l.checkNumArgs(head, n.Args)
+		} else {
+			l.walk(n.Head)
+		}
+		l.walkAll(n.Args)
+	}
+	for _, opt := range n.Opts {
+		l.walk(opt)
+	}
+	for _, redir := range n.Redirs {
+		l.walk(redir)
+	}
+}
+
+func (l *linter) walkAll(nodes []*parse.Compound) {
+	for _, n := range nodes {
+		l.walk(n)
+	}
+}
+
+// Handles an LHS of set or tmp. Assigning a variable doesn't count as using
+// it, but assigning to an element of it does.
+func (l *linter) assign(cn *parse.Compound) {
+	if _, ok := unqualifiedName(cn); ok {
+		return
+	}
+	if len(cn.Indexings) == 1 && cn.Indexings[0].Head.Type == parse.Bareword {
+		in := cn.Indexings[0]
+		l.use(in.Head.Value)
+		for _, index := range in.Indices {
+			l.walk(index)
+		}
+		return
+	}
+	l.walk(cn)
+}
+
+// Handles the variable of for or try, which is assigned if it exists, and
+// declared otherwise.
+func (l *linter) setOrDeclare(cn *parse.Compound) {
+	name, ok := unqualifiedName(cn)
+	if !ok {
+		l.assign(cn)
+		return
+	}
+	if d := l.lookup(name, 0, len(l.scopes)); d == nil {
+		l.declare(name, cn, false)
+	}
+}
+
+func (l *linter) primary(n *parse.Primary) {
+	switch n.Type {
+	case parse.Variable:
+		l.use(n.Value)
+	case parse.Lambda:
+		// Default values of options are evaluated in the outer scope.
+		for _, opt := range n.MapPairs {
+			if opt.Value != nil {
+				l.walk(opt.Value)
+			}
+		}
+		l.pushScope()
+		for _, arg := range n.Elements {
+			if name, ok := unqualifiedName(arg); ok {
+				l.declare(name, arg, false)
+			}
+		}
+		for _, opt := range n.MapPairs {
+			if name, ok := unqualifiedName(opt.Key); ok {
+				l.declare(name, opt.Key, false)
+			}
+		}
+		l.chunk(n.Chunk)
+		l.popScope()
+	default:
+		l.walkChildren(n)
+	}
+}
+
+// Comparison commands and their counterparts for strings.
+var stringComparisons = map[string]string{
+	"==": "==s", "!=": "!=s", "<": "<s", "<=": "<=s", ">": ">s", ">=": ">=s",
+}
+
+var arithmetics = map[string]bool{"+": true, "-": true, "*": true, "/": true, "%": true}
+
+// Reports string literals that are not numbers in the arguments of builtin
+// numeric commands.
+func (l *linter) checkNumArgs(head string, args []*parse.Compound) {
+	strCmp, isCmp := stringComparisons[head]
+	if !isCmp && !arithmetics[head] {
+		return
+	}
+	if d := l.lookup(head+"~", 0, len(l.scopes)); d != nil {
+		// Not the builtin command.
+		return
+	}
+	for _, arg := range args {
+		s, ok := cmpd.StringLiteral(arg)
+		if !ok || vals.ParseNum(s) != nil {
+			continue
+		}
+		if isCmp {
+			l.report(arg, "%s is not a number; use %s to compare strings", parse.Quote(s), strCmp)
+		} else {
+			l.report(arg, "%s is not a number", parse.Quote(s))
+		}
+	}
+}
+
+// Splits the arguments of var, set or tmp into the LHS and the RHS.
+func splitAssignment(args []*parse.Compound) (lhs, rhs []*parse.Compound) {
+	for i, arg := range args {
+		if isKeyword(arg, "=") {
+			return args[:i], args[i+1:]
+		}
+	}
+	return args, nil
+}
+
+func isKeyword(cn *parse.Compound, keyword string) bool {
+	pn, ok := cmpd.Primary(cn)
+	return ok && pn.Type == parse.Bareword && pn.Value == keyword
+}
+
+// Returns the unqualified name in a bareword like "x" or "@x".
+func unqualifiedName(cn *parse.Compound) (string, bool) {
+	pn, ok := cmpd.Primary(cn)
+	if !ok || pn.Type != parse.Bareword {
+		return "", false
+	}
+	_, qname := eval.SplitSigil(pn.Value)
+	name, rest := eval.SplitQName(qname)
+	return name, rest == ""
+}
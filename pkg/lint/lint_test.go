@@ -0,0 +1,114 @@
+package lint_test
+
+import (
+	"reflect"
+	"testing"
+
+	"src.elv.sh/pkg/lint"
+	"src.elv.sh/pkg/parse"
+)
+
+var lintTests = []struct {
+	name string
+	code string
+	// Source text and message of each problem.
+	want [][2]string
+}{
+	{
+		name: "no problem",
+		code: "fn f {|x| var y = $x; put $y }; f 1",
+	},
+	{
+		name: "unused variable",
+		code: "fn f { var x = 1; var y; put $y }",
+		want: [][2]string{{"x", "variable $x is declared but not used"}},
+	},
+	{
+		name: "unused function",
+		code: "fn f { fn g { }; fn h { }; h }",
+		want: [][2]string{{"g", "function g is declared but not used"}},
+	},
+	{
+		name: "top-level and argument variables are not checked",
+		code: "var x = 1; fn f {|a &o=1| }; for i [] { }; try { } catch e { }",
+	},
+	{
+		name: "assignment is not a use",
+		code: "fn f { var x; set x = 1 }",
+		want: [][2]string{{"x", "variable $x is declared but not used"}},
+	},
+	{
+		name: "assignment to an element is a use",
+		code: "fn f { var m = [&]; set m[k] = v }",
+	},
+	{
+		name: "use in closure, with explode and local:",
+		code: "fn f { var x y; { put $@x }; put $local:y }",
+	},
+	{
+		name: "redeclaration in the same scope",
+		code: "fn f { var x = 1; var x = 2; put $x }",
+		want: [][2]string{{"x", "variable $x is declared but not used"}},
+	},
+	{
+		name: "shadowed variable",
+		code: "var x = 1; fn f { var x = 2; put $x }",
+		want: [][2]string{{"x", "variable $x shadows one in an outer scope"}},
+	},
+	{
+		name: "shadowed function",
+		code: "fn g { }; fn f { fn g { }; g }",
+		want: [][2]string{{"g", "function g shadows one in an outer scope"}},
+	},
+	{
+		name: "for variable assigns an existing variable",
+		code: "fn f { var x; for x [a] { }; put $x }",
+	},
+	{
+		name: "unreachable code",
+		code: "fn f { return; echo foo; echo bar }; while $true { break; echo }",
+		want: [][2]string{
+			{"echo foo", "unreachable code after return"},
+			{"echo", "unreachable code after break"},
+		},
+	},
+	{
+		name: "non-number string in numeric commands",
+		code: "+ 1 foo; == $x '1'; < 1 bar",
+		want: [][2]string{
+			{"foo", "foo is not a number"},
+			{"bar", "bar is not a number; use <s to compare strings"},
+		},
+	},
+	{
+		name: "user-defined numeric command",
+		code: "fn + {|@a| }; + foo",
+	},
+}
+
+func TestLint(t *testing.T) {
+	for _, test := range lintTests {
+		t.Run(test.name, func(t *testing.T) {
+			src := parse.Source{Name: "[test]", Code: test.code}
+			problems, err := lint.Lint(src)
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			var got [][2]string
+			for _, pr := range problems {
+				got = append(got, [2]string{
+					test.code[pr.Context.From:pr.Context.To], pr.Message})
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLint_ParseError(t *testing.T) {
+	_, err := lint.Lint(parse.Source{Name: "[test]", Code: "echo ["})
+	if err == nil {
+		t.Errorf("got no error")
+	}
+}
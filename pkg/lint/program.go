@@ -0,0 +1,111 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"unicode/utf8"
+
+	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/prog"
+)
+
+// Program is the linter subprogram. It checks the Elvish files in the
+// arguments, and writes the problems found to stderr, or to stdout as JSON if
+// -json is also given.
+//
+// It exits with 1 if any problem is found, and 2 if any file can't be read or
+// parsed.
+type Program struct {
+	lint bool
+	json *bool
+}
+
+func (p *Program) RegisterFlags(fs *prog.FlagSet) {
+	fs.BoolVar(&p.lint, "lint", false,
+		"Check the Elvish files in the arguments for likely mistakes and quit")
+	p.json = fs.JSON()
+}
+
+func (p *Program) Run(fds [3]*os.File, args []string) error {
+	if !p.lint {
+		return prog.NextProgram()
+	}
+	if len(args) == 0 {
+		return prog.BadUsage("-lint requires Elvish files as arguments")
+	}
+	exit := 0
+	var converted []problemInJSON
+	for _, arg := range args {
+		problems, err := lintFile(arg)
+		if err != nil {
+			exit = 2
+			if *p.json {
+				converted = append(converted, errsToJSON(arg, err)...)
+			} else {
+				diag.ShowError(fds[2], err)
+			}
+			continue
+		}
+		if len(problems) > 0 && exit == 0 {
+			exit = 1
+		}
+		if *p.json {
+			for _, pr := range problems {
+				converted = append(converted, problemToJSON(pr))
+			}
+		} else if len(problems) > 0 {
+			diag.ShowError(fds[2], diag.PackErrors(problems))
+		}
+	}
+	if *p.json {
+		if converted == nil {
+			converted = []problemInJSON{}
+		}
+		data, err := json.Marshal(converted)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(fds[1], "%s\n", data)
+	}
+	return prog.Exit(exit)
+}
+
+func lintFile(name string) ([]*Problem, error) {
+	code, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if !utf8.Valid(code) {
+		return nil, fmt.Errorf("%s: source is not UTF-8", name)
+	}
+	return Lint(parse.Source{Name: name, Code: string(code), IsFile: true})
+}
+
+// The JSON format of a problem, which is the same as the one used for errors
+// by -compileonly -json.
+type problemInJSON struct {
+	FileName string `json:"fileName"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+	Message  string `json:"message"`
+}
+
+func problemToJSON(pr *Problem) problemInJSON {
+	return problemInJSON{pr.Context.Name, pr.Context.From, pr.Context.To, pr.Message}
+}
+
+// Converts an error from reading or parsing a file. Errors without positions,
+// like I/O errors, are converted with a zero range.
+func errsToJSON(name string, err error) []problemInJSON {
+	parseErrs := parse.UnpackErrors(err)
+	if len(parseErrs) == 0 {
+		return []problemInJSON{{FileName: name, Message: err.Error()}}
+	}
+	converted := make([]problemInJSON, len(parseErrs))
+	for i, e := range parseErrs {
+		converted[i] = problemInJSON{e.Context.Name, e.Context.From, e.Context.To, e.Message}
+	}
+	return converted
+}
@@ -0,0 +1,396 @@
+// Package archive implements the archive: module, which supports reading and
+// writing tar and zip archives, and gzip compression.
+package archive
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/parse"
+)
+
+// Ns is the namespace for the archive: module.
+var Ns = eval.BuildNsNamed("archive").
+	AddGoFns(map[string]any{
+		"list":    list,
+		"extract": extract,
+		"create":  create,
+		"gzip":    gzipFn,
+		"gunzip":  gunzip,
+	}).Ns()
+
+// Archive formats.
+const (
+	formatTar   = "tar"
+	formatTarGz = "tar.gz"
+	formatZip   = "zip"
+)
+
+// Returns the format of an archive, either from the &format option or from the
+// extension of its name.
+func archiveFormat(format, name string) (string, error) {
+	switch format {
+	case formatTar, formatTarGz, formatZip:
+		return format, nil
+	case "":
+		lower := strings.ToLower(name)
+		switch {
+		case strings.HasSuffix(lower, ".tar"):
+			return formatTar, nil
+		case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+			return formatTarGz, nil
+		case strings.HasSuffix(lower, ".zip"):
+			return formatZip, nil
+		}
+		return "", fmt.Errorf("cannot determine format of %s; use &format", parse.Quote(name))
+	}
+	return "", errs.BadValue{What: "&format",
+		Valid: "tar, tar.gz or zip", Actual: parse.Quote(format)}
+}
+
+// An entry in an archive.
+type entry struct {
+	name   string
+	mode   fs.FileMode
+	size   int64
+	mtime  time.Time
+	target string // Only for symlinks
+	// Opens the content of the entry. It must be called before moving on to
+	// the next entry.
+	open func() (io.ReadCloser, error)
+}
+
+type formatOpts struct{ Format string }
+
+func (*formatOpts) SetDefaultOptions() {}
+
+func list(fm *eval.Frame, opts formatOpts, file string) error {
+	out := fm.ValueOutput()
+	return walkArchive(opts.Format, file, func(e entry) error {
+		return out.Put(entryMap(e))
+	})
+}
+
+func entryMap(e entry) vals.Map {
+	m := vals.MakeMap(
+		"name", e.name,
+		"type", typeName(e.mode),
+		"size", vals.Int64ToNum(e.size),
+		"perm", int(e.mode.Perm()),
+		"mtime", e.mtime.UTC().Format(time.RFC3339))
+	if e.mode.Type() == fs.ModeSymlink {
+		m = m.Assoc("target", e.target)
+	}
+	return m
+}
+
+func typeName(mode fs.FileMode) string {
+	switch mode.Type() {
+	case 0:
+		return "regular"
+	case fs.ModeDir:
+		return "dir"
+	case fs.ModeSymlink:
+		return "symlink"
+	default:
+		return "other"
+	}
+}
+
+// Calls f with each entry in an archive.
+func walkArchive(format, file string, f func(entry) error) error {
+	format, err := archiveFormat(format, file)
+	if err != nil {
+		return err
+	}
+	if format == formatZip {
+		return walkZip(file, f)
+	}
+	r, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if format == formatTarGz {
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		return walkTar(gr, f)
+	}
+	return walkTar(r, f)
+}
+
+type extractOpts struct {
+	Format string
+	Dir    string
+}
+
+func (opts *extractOpts) SetDefaultOptions() { opts.Dir = "." }
+
+func extract(fm *eval.Frame, opts extractOpts, file string) error {
+	return walkArchive(opts.Format, file, func(e entry) error {
+		return extractEntry(fm.Evaler, opts.Dir, e)
+	})
+}
+
+func extractEntry(ev *eval.Evaler, dir string, e entry) error {
+	name := filepath.FromSlash(strings.TrimSuffix(e.name, "/"))
+	if !filepath.IsLocal(name) {
+		return fmt.Errorf("unsafe path in archive: %s", parse.Quote(e.name))
+	}
+	path := filepath.Join(dir, name)
+	if err := ev.CheckWrite(path); err != nil {
+		return err
+	}
+	// The name is local, but symlinks created by earlier entries can still
+	// take it out of dir.
+	parent := filepath.Dir(path)
+	if e.mode.IsDir() {
+		parent = path
+	}
+	if err := checkInDir(dir, parent); err != nil {
+		return fmt.Errorf("unsafe path in archive: %s", parse.Quote(e.name))
+	}
+	switch e.mode.Type() {
+	case fs.ModeDir:
+		return os.MkdirAll(path, e.mode.Perm()|0o700)
+	case fs.ModeSymlink:
+		if filepath.IsAbs(e.target) ||
+			!filepath.IsLocal(filepath.Join(filepath.Dir(name), e.target)) {
+			return fmt.Errorf("unsafe symlink in archive: %s -> %s",
+				parse.Quote(e.name), parse.Quote(e.target))
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		// The target must also stay inside dir when it is resolved from
+		// the real parent directory. It is not joined with filepath.Join,
+		// which would resolve ".." before the symlinks in front of it.
+		realParent, err := filepath.EvalSymlinks(filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		target := realParent + string(filepath.Separator) + e.target
+		if checkInDir(dir, target) != nil {
+			return fmt.Errorf("unsafe symlink in archive: %s -> %s",
+				parse.Quote(e.name), parse.Quote(e.target))
+		}
+		return os.Symlink(e.target, path)
+	case 0:
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		// Replace a symlink with the same name instead of writing through
+		// it, like tar does.
+		if info, err := os.Lstat(path); err == nil && info.Mode().Type() == fs.ModeSymlink {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+		err := writeFile(path, e)
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(path, e.mtime, e.mtime)
+	default:
+		// Skip devices, named pipes and other special files.
+		return nil
+	}
+}
+
+// Checks that path, which is lexically inside dir, is still inside it after
+// resolving symlinks. Only the deepest existing ancestor of path is resolved,
+// since the rest doesn't exist yet and will be created as directories.
+func checkInDir(dir, path string) error {
+	realDir, err := filepath.EvalSymlinks(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Nothing has been extracted yet.
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for {
+		if _, err := os.Lstat(path); err == nil {
+			break
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		path = filepath.Dir(path)
+	}
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	// EvalSymlinks keeps relative paths relative.
+	realDir, _ = filepath.Abs(realDir)
+	realPath, _ = filepath.Abs(realPath)
+	rel, err := filepath.Rel(realDir, realPath)
+	if err != nil || !filepath.IsLocal(rel) {
+		return errors.New("outside the directory")
+	}
+	return nil
+}
+
+func writeFile(path string, e entry) error {
+	r, err := e.open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, e.mode.Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	return errors.Join(err, f.Close())
+}
+
+func create(fm *eval.Frame, opts formatOpts, file string, paths ...string) error {
+	format, err := archiveFormat(opts.Format, file)
+	if err != nil {
+		return err
+	}
+	if err := fm.Evaler.CheckWrite(file); err != nil {
+		return err
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	err = writeArchive(f, format, paths)
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		// Don't leave an incomplete archive behind.
+		os.Remove(file)
+	}
+	return err
+}
+
+func writeArchive(f *os.File, format string, paths []string) error {
+	self, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	var w archiveWriter
+	switch format {
+	case formatTar:
+		w = newTarWriter(f)
+	case formatTarGz:
+		w = newTarGzWriter(f)
+	case formatZip:
+		w = newZipWriter(f)
+	}
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			return addFile(w, p, self)
+		})
+		if err != nil {
+			w.Close()
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// Writes entries to an archive.
+type archiveWriter interface {
+	// Adds an entry. The content of regular files is read from r.
+	Add(e entry, r io.Reader) error
+	Close() error
+}
+
+// Adds a file to the archive, unless it is the archive itself.
+func addFile(w archiveWriter, path string, self fs.FileInfo) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if os.SameFile(info, self) {
+		return nil
+	}
+	e := entry{name: archiveName(path), mode: info.Mode(), mtime: info.ModTime()}
+	switch info.Mode().Type() {
+	case fs.ModeDir:
+		if e.name == "" || e.name == "." {
+			// The root of an absolute path, or the current directory.
+			return nil
+		}
+		e.name += "/"
+		return w.Add(e, nil)
+	case fs.ModeSymlink:
+		e.target, err = os.Readlink(path)
+		if err != nil {
+			return err
+		}
+		return w.Add(e, nil)
+	case 0:
+		e.size = info.Size()
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return w.Add(e, f)
+	default:
+		// Skip devices, named pipes and other special files.
+		return nil
+	}
+}
+
+// Converts a path to the name of an archive entry, which always uses forward
+// slashes and never starts with "/" or "../".
+func archiveName(path string) string {
+	path = filepath.Clean(path)
+	path = filepath.ToSlash(strings.TrimPrefix(path, filepath.VolumeName(path)))
+	for {
+		trimmed := strings.TrimPrefix(strings.TrimLeft(path, "/"), "../")
+		if trimmed == path {
+			break
+		}
+		path = trimmed
+	}
+	if path == ".." {
+		return ""
+	}
+	return path
+}
+
+type gzipOpts struct{ Level int }
+
+func (opts *gzipOpts) SetDefaultOptions() { opts.Level = gzip.DefaultCompression }
+
+func gzipFn(fm *eval.Frame, opts gzipOpts) error {
+	w, err := gzip.NewWriterLevel(fm.ByteOutput(), opts.Level)
+	if err != nil {
+		return errs.OutOfRange{What: "&level",
+			ValidLow: "-2", ValidHigh: "9", Actual: fmt.Sprint(opts.Level)}
+	}
+	_, err = io.Copy(w, fm.InputFile())
+	return errors.Join(err, w.Close())
+}
+
+func gunzip(fm *eval.Frame) error {
+	r, err := gzip.NewReader(fm.InputFile())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fm.ByteOutput(), r)
+	return errors.Join(err, r.Close())
+}
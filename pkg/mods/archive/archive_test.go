@@ -0,0 +1,49 @@
+package archive_test
+
+import (
+	"archive/tar"
+	"embed"
+	"os"
+	"testing"
+
+	"src.elv.sh/pkg/eval/evaltest"
+	"src.elv.sh/pkg/must"
+)
+
+//go:embed *.elvts *.elv
+var transcripts embed.FS
+
+func TestTranscripts(t *testing.T) {
+	evaltest.TestTranscriptsInFS(t, transcripts,
+		"create-unsafe-archives", func(t *testing.T) {
+			writeTar(t, "path.tar", &tar.Header{
+				Name: "../evil", Typeflag: tar.TypeReg, Mode: 0o644})
+			writeTar(t, "symlink.tar", &tar.Header{
+				Name: "link", Typeflag: tar.TypeSymlink, Linkname: "../evil"})
+			// Each entry looks local on its own, but together they escape
+			// the directory through the symlinks.
+			writeTar(t, "symlink-chain.tar",
+				&tar.Header{Name: "a/", Typeflag: tar.TypeDir, Mode: 0o755},
+				&tar.Header{Name: "a/b", Typeflag: tar.TypeSymlink, Linkname: ".."},
+				&tar.Header{Name: "a/b/c", Typeflag: tar.TypeSymlink, Linkname: ".."},
+				&tar.Header{Name: "a/b/c/evil", Typeflag: tar.TypeReg, Mode: 0o644})
+			writeTar(t, "through-symlink.tar",
+				&tar.Header{Name: "a/", Typeflag: tar.TypeDir, Mode: 0o755},
+				&tar.Header{Name: "a/b", Typeflag: tar.TypeSymlink, Linkname: ".."},
+				&tar.Header{Name: "c", Typeflag: tar.TypeSymlink, Linkname: "a/b/.."},
+				&tar.Header{Name: "c/evil", Typeflag: tar.TypeReg, Mode: 0o644})
+			writeTar(t, "file.tar", &tar.Header{
+				Name: "c/evil", Typeflag: tar.TypeReg, Mode: 0o644})
+		},
+	)
+}
+
+func writeTar(t *testing.T, name string, hs ...*tar.Header) {
+	f := must.OK1(os.Create(name))
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	for _, h := range hs {
+		must.OK(tw.WriteHeader(h))
+	}
+	must.OK(tw.Close())
+}
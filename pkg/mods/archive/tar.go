@@ -0,0 +1,72 @@
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+)
+
+func walkTar(r io.Reader, f func(entry) error) error {
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		err = f(entry{
+			name: h.Name, mode: h.FileInfo().Mode(), size: h.Size,
+			mtime: h.ModTime, target: h.Linkname,
+			open: func() (io.ReadCloser, error) { return io.NopCloser(tr), nil },
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+type tarWriter struct {
+	tw *tar.Writer
+	gw *gzip.Writer // Only for tar.gz archives
+}
+
+func newTarWriter(w io.Writer) tarWriter { return tarWriter{tar.NewWriter(w), nil} }
+
+func newTarGzWriter(w io.Writer) tarWriter {
+	gw := gzip.NewWriter(w)
+	return tarWriter{tar.NewWriter(gw), gw}
+}
+
+func (w tarWriter) Add(e entry, r io.Reader) error {
+	h := &tar.Header{
+		Name: e.name, Mode: int64(e.mode.Perm()), Size: e.size,
+		ModTime: e.mtime, Linkname: e.target, Format: tar.FormatPAX,
+	}
+	switch {
+	case e.mode.IsDir():
+		h.Typeflag = tar.TypeDir
+	case e.target != "":
+		h.Typeflag = tar.TypeSymlink
+	default:
+		h.Typeflag = tar.TypeReg
+	}
+	if err := w.tw.WriteHeader(h); err != nil {
+		return err
+	}
+	if r == nil {
+		return nil
+	}
+	_, err := io.Copy(w.tw, r)
+	return err
+}
+
+func (w tarWriter) Close() error {
+	err := w.tw.Close()
+	if w.gw != nil {
+		if errGzip := w.gw.Close(); err == nil {
+			err = errGzip
+		}
+	}
+	return err
+}
@@ -0,0 +1,70 @@
+package archive
+
+import (
+	"archive/zip"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+func walkZip(file string, f func(entry) error) error {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	for _, zf := range zr.File {
+		e := entry{
+			name: zf.Name, mode: zf.Mode(), size: int64(zf.UncompressedSize64),
+			mtime: zf.Modified, open: zf.Open,
+		}
+		if e.mode.Type() == fs.ModeSymlink {
+			// Zip archives store the target of symlinks as their content.
+			target, err := readZipFile(zf)
+			if err != nil {
+				return err
+			}
+			e.target = target
+		}
+		if err := f(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readZipFile(zf *zip.File) (string, error) {
+	r, err := zf.Open()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	var sb strings.Builder
+	_, err = io.Copy(&sb, r)
+	return sb.String(), err
+}
+
+type zipWriter struct{ zw *zip.Writer }
+
+func newZipWriter(w io.Writer) zipWriter { return zipWriter{zip.NewWriter(w)} }
+
+func (w zipWriter) Add(e entry, r io.Reader) error {
+	h := &zip.FileHeader{Name: e.name, Modified: e.mtime, Method: zip.Deflate}
+	h.SetMode(e.mode)
+	if e.mode.IsDir() {
+		h.Method = zip.Store
+	}
+	fw, err := w.zw.CreateHeader(h)
+	if err != nil {
+		return err
+	}
+	switch {
+	case e.mode.Type() == fs.ModeSymlink:
+		_, err = io.WriteString(fw, e.target)
+	case r != nil:
+		_, err = io.Copy(fw, r)
+	}
+	return err
+}
+
+func (w zipWriter) Close() error { return w.zw.Close() }
@@ -0,0 +1,8 @@
+package comp
+
+import _ "embed"
+
+// Code contains the source code of the comp module.
+//
+//go:embed comp.elv
+var Code string
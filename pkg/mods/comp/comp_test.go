@@ -0,0 +1,16 @@
+package comp_test
+
+import (
+	"embed"
+	"testing"
+
+	"src.elv.sh/pkg/eval/evaltest"
+	"src.elv.sh/pkg/mods"
+)
+
+//go:embed *.elvts
+var transcripts embed.FS
+
+func TestTranscripts(t *testing.T) {
+	evaltest.TestTranscriptsInFS(t, transcripts, "prepare-deps", mods.AddTo)
+}
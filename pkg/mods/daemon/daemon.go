@@ -6,6 +6,7 @@ import (
 
 	"src.elv.sh/pkg/daemon/daemondefs"
 	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vals"
 	"src.elv.sh/pkg/eval/vars"
 )
 
@@ -32,5 +33,36 @@ func Ns(d daemondefs.Client) *eval.Ns {
 		}).
 		AddGoFns(map[string]any{
 			"pid": getPid,
+			"sessions": func(fm *eval.Frame) error {
+				sessions, err := d.Sessions()
+				if err != nil {
+					return err
+				}
+				out := fm.ValueOutput()
+				for _, s := range sessions {
+					err := out.Put(vals.MakeMap(
+						"name", s.Name, "pid", strconv.Itoa(s.Pid), "exited", s.Exited))
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			"kill-session": d.KillSession,
+			"stats": func() (vals.Map, error) {
+				stats, err := d.Stats()
+				if err != nil {
+					return nil, err
+				}
+				calls := vals.EmptyList
+				for _, c := range stats.Calls {
+					calls = calls.Conj(vals.MakeMap(
+						"method", c.Method, "count", c.Count,
+						"total", c.Total.Seconds(), "max", c.Max.Seconds()))
+				}
+				return vals.MakeMap(
+					"uptime", stats.Uptime.Seconds(), "clients", stats.Clients,
+					"store-size", int(stats.StoreSize), "calls", calls), nil
+			},
 		}).Ns()
 }
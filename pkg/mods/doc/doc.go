@@ -3,6 +3,7 @@ package doc
 
 import (
 	"fmt"
+	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
@@ -19,7 +20,7 @@ var Ns = eval.BuildNsNamed("doc").
 	AddGoFns(map[string]any{
 		"show":     show,
 		"find":     find,
-		"source":   Source,
+		"source":   source,
 		"-symbols": symbols,
 	}).
 	Ns()
@@ -29,7 +30,7 @@ type showOptions struct{ Width int }
 func (opts *showOptions) SetDefaultOptions() {}
 
 func show(fm *eval.Frame, opts showOptions, fqname string) error {
-	doc, err := Source(fqname)
+	doc, err := sourceIn(fm.Evaler.LibDirs, fqname)
 	if err != nil {
 		return err
 	}
@@ -84,8 +85,18 @@ func find(fm *eval.Frame, qs ...string) {
 	}
 }
 
-// Source returns the doc source for a symbol.
+func source(fm *eval.Frame, qname string) (string, error) {
+	return sourceIn(fm.Evaler.LibDirs, qname)
+}
+
+// Source returns the doc source for a symbol in a builtin module.
 func Source(qname string) (string, error) {
+	return sourceIn(nil, qname)
+}
+
+// Returns the doc source for a symbol. If the symbol is not in a builtin
+// module, the module is looked up in libDirs.
+func sourceIn(libDirs []string, qname string) (string, error) {
 	isVar := strings.HasPrefix(qname, "$")
 	var ns string
 	if strings.ContainsRune(qname, ':') {
@@ -110,7 +121,10 @@ func Source(qname string) (string, error) {
 
 	docs, ok := docsMap()[ns]
 	if !ok {
-		return "", fmt.Errorf("no doc for %s", parse.Quote(qname))
+		docs, ok = libModuleDocs(libDirs, ns)
+		if !ok {
+			return "", fmt.Errorf("no doc for %s", parse.Quote(qname))
+		}
 	}
 	var entries []elvdoc.Entry
 	if isVar {
@@ -127,6 +141,23 @@ func Source(qname string) (string, error) {
 	return "", fmt.Errorf("no doc for %s", parse.Quote(qname))
 }
 
+// Extracts the elvdocs of the module with the given symbol prefix (like "a/b:"
+// for the module imported with "use a/b") from the first module search
+// directory that contains it.
+func libModuleDocs(libDirs []string, ns string) (elvdoc.Docs, bool) {
+	spec := strings.TrimSuffix(ns, ":")
+	if spec == "" || !filepath.IsLocal(spec) {
+		return elvdoc.Docs{}, false
+	}
+	for _, dir := range libDirs {
+		docs, err := elvdoc.ExtractFromFile(filepath.Join(dir, spec+".elv"), ns)
+		if err == nil {
+			return docs, true
+		}
+	}
+	return elvdoc.Docs{}, false
+}
+
 func symbols(fm *eval.Frame) error {
 	var names []string
 	for _, docs := range docsMap() {
@@ -6,7 +6,9 @@ import (
 	"testing"
 
 	"src.elv.sh/pkg/elvdoc"
+	"src.elv.sh/pkg/eval"
 	"src.elv.sh/pkg/eval/evaltest"
+	"src.elv.sh/pkg/eval/vars"
 	"src.elv.sh/pkg/mods/doc"
 	"src.elv.sh/pkg/must"
 	"src.elv.sh/pkg/testutil"
@@ -32,5 +34,11 @@ func TestTranscripts(t *testing.T) {
 	// The result of reading the FS is cached. As a result, this override can't
 	// be reverted, so we just do it here instead of properly inside a setup
 	// function.
-	evaltest.TestTranscriptsInFS(t, transcripts)
+	evaltest.TestTranscriptsInFS(t, transcripts,
+		"tmp-lib-dir", func(t *testing.T, ev *eval.Evaler) {
+			libdir := testutil.TempDir(t)
+			ev.LibDirs = []string{libdir}
+			ev.ExtendGlobal(eval.BuildNs().AddVar("lib", vars.NewReadOnly(libdir)))
+		},
+	)
 }
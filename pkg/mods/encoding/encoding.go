@@ -0,0 +1,144 @@
+// Package encoding implements the encoding: module, which provides hash
+// functions and common encodings of binary data.
+package encoding
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/url"
+	"strings"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
+)
+
+// Ns is the namespace for the encoding: module.
+var Ns = eval.BuildNsNamed("encoding").
+	AddGoFns(map[string]any{
+		"md5":    hashFn(md5.New),
+		"sha1":   hashFn(sha1.New),
+		"sha256": hashFn(sha256.New),
+		"sha512": hashFn(sha512.New),
+
+		"to-base64":   toBase64,
+		"from-base64": fromBase64,
+		"to-hex":      toHex,
+		"from-hex":    fromHex,
+
+		"escape-url":   escapeURL,
+		"unescape-url": unescapeURL,
+	}).Ns()
+
+// Returns a reader for the optional string argument, or the byte input if
+// there is no argument.
+func input(fm *eval.Frame, args []string) (io.Reader, error) {
+	switch len(args) {
+	case 0:
+		return fm.InputFile(), nil
+	case 1:
+		return strings.NewReader(args[0]), nil
+	default:
+		return nil, errs.ArityMismatch{What: "arguments",
+			ValidLow: 0, ValidHigh: 1, Actual: len(args)}
+	}
+}
+
+func readInput(fm *eval.Frame, args []string) ([]byte, error) {
+	in, err := input(fm, args)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(in)
+}
+
+func hashFn(newHash func() hash.Hash) func(*eval.Frame, ...string) (string, error) {
+	return func(fm *eval.Frame, args ...string) (string, error) {
+		in, err := input(fm, args)
+		if err != nil {
+			return "", err
+		}
+		h := newHash()
+		if _, err := io.Copy(h, in); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+}
+
+type base64Opts struct {
+	URL     bool
+	Padding bool
+}
+
+func (opts *base64Opts) SetDefaultOptions() { opts.Padding = true }
+
+func toBase64(fm *eval.Frame, opts base64Opts, args ...string) (string, error) {
+	data, err := readInput(fm, args)
+	if err != nil {
+		return "", err
+	}
+	return base64Encoding(opts.URL, opts.Padding).EncodeToString(data), nil
+}
+
+type fromBase64Opts struct{ URL bool }
+
+func (*fromBase64Opts) SetDefaultOptions() {}
+
+func fromBase64(opts fromBase64Opts, s string) (string, error) {
+	// Accept both padded and unpadded input. Newlines are ignored by the
+	// decoder, so trailing ones have to be removed before checking for the
+	// padding.
+	s = strings.TrimRight(s, "\r\n")
+	data, err := base64Encoding(opts.URL, strings.HasSuffix(s, "=")).DecodeString(s)
+	return string(data), err
+}
+
+func base64Encoding(urlSafe, padding bool) *base64.Encoding {
+	switch {
+	case urlSafe && padding:
+		return base64.URLEncoding
+	case urlSafe:
+		return base64.RawURLEncoding
+	case padding:
+		return base64.StdEncoding
+	default:
+		return base64.RawStdEncoding
+	}
+}
+
+func toHex(fm *eval.Frame, args ...string) (string, error) {
+	data, err := readInput(fm, args)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
+
+func fromHex(s string) (string, error) {
+	data, err := hex.DecodeString(s)
+	return string(data), err
+}
+
+type urlOpts struct{ Path bool }
+
+func (*urlOpts) SetDefaultOptions() {}
+
+func escapeURL(opts urlOpts, s string) string {
+	if opts.Path {
+		return url.PathEscape(s)
+	}
+	return url.QueryEscape(s)
+}
+
+func unescapeURL(opts urlOpts, s string) (string, error) {
+	if opts.Path {
+		return url.PathUnescape(s)
+	}
+	return url.QueryUnescape(s)
+}
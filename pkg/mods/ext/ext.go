@@ -0,0 +1,190 @@
+// Package ext implements the ext: module, which supports namespaces backed by
+// external processes.
+package ext
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vals"
+)
+
+// Ns is the namespace for the ext: module.
+var Ns = eval.BuildNsNamed("ext").
+	AddGoFns(map[string]any{
+		"load": load,
+	}).Ns()
+
+// Method used to discover the functions provided by the server.
+const functionsMethod = "elvish.functions"
+
+func load(fm *eval.Frame, name string, args ...string) (*eval.Ns, error) {
+	if fm.Evaler.IsRestricted() {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			return nil, err
+		}
+		if err := fm.Evaler.CheckExternal(path); err != nil {
+			return nil, err
+		}
+	}
+	r, w, err := startServer(name, args)
+	if err != nil {
+		return nil, err
+	}
+	c := newClient(r, w)
+	result, err := c.call(functionsMethod, nil)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := result.(vals.List)
+	if !ok {
+		return nil, fmt.Errorf("invalid result of %s: want list, got %s",
+			functionsMethod, vals.Kind(result))
+	}
+	var fnNames []string
+	if err := vals.ScanListToGo(list, &fnNames); err != nil {
+		return nil, fmt.Errorf("invalid result of %s: %w", functionsMethod, err)
+	}
+	nb := eval.BuildNsNamed(filepath.Base(name))
+	for _, fnName := range fnNames {
+		nb.AddGoFn(fnName, c.fn(fnName))
+	}
+	return nb.Ns(), nil
+}
+
+// Starts a server process, returning a reader for its stdout and a writer for
+// its stdin. Can be overridden in tests.
+var startServer = func(name string, args []string) (io.Reader, io.Writer, error) {
+	stdinR, stdinW, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		stdinR.Close()
+		stdinW.Close()
+		return nil, nil, err
+	}
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = stdinR
+	cmd.Stdout = stdoutW
+	cmd.Stderr = os.Stderr
+	err = cmd.Start()
+	// The child has inherited its ends of the pipes, or failed to start;
+	// either way the parent no longer needs them.
+	stdinR.Close()
+	stdoutW.Close()
+	if err != nil {
+		stdinW.Close()
+		stdoutR.Close()
+		return nil, nil, err
+	}
+	// The server exits when it sees EOF on its stdin, which happens at the
+	// latest when Elvish exits.
+	go cmd.Wait()
+	return stdoutR, stdinW, nil
+}
+
+type client struct {
+	mutex  sync.Mutex
+	enc    *json.Encoder
+	dec    *json.Decoder
+	nextID int
+}
+
+func newClient(r io.Reader, w io.Writer) *client {
+	return &client{enc: json.NewEncoder(w), dec: json.NewDecoder(r)}
+}
+
+type request struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      int    `json:"id"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+type params struct {
+	Args []any          `json:"args"`
+	Opts map[string]any `json:"opts"`
+}
+
+type response struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *responseError  `json:"error"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+var errServerExited = errors.New("server exited")
+
+// Sends a request and waits for its response. Requests are serialized, so the
+// server doesn't need to handle concurrent requests.
+func (c *client) call(method string, p any) (any, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	id := c.nextID
+	c.nextID++
+	err := c.enc.Encode(request{JSONRPC: "2.0", ID: id, Method: method, Params: p})
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+	var resp response
+	err = c.dec.Decode(&resp)
+	if err != nil {
+		if err == io.EOF {
+			return nil, errServerExited
+		}
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.ID != id {
+		return nil, fmt.Errorf("response has id %d, want %d", resp.ID, id)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("%s: %s", method, resp.Error.Message)
+	}
+	dec := json.NewDecoder(bytes.NewReader(resp.Result))
+	dec.UseNumber()
+	var result any
+	if err := dec.Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid result: %w", err)
+	}
+	return vals.FromJSON(result)
+}
+
+// Returns the implementation of a function that calls the given method.
+func (c *client) fn(method string) func(*eval.Frame, eval.RawOptions, ...any) error {
+	return func(fm *eval.Frame, opts eval.RawOptions, args ...any) error {
+		if args == nil {
+			args = []any{}
+		}
+		result, err := c.call(method, params{args, opts})
+		if err != nil {
+			return err
+		}
+		list, ok := result.(vals.List)
+		if !ok {
+			return fmt.Errorf("invalid result of %s: want list, got %s",
+				method, vals.Kind(result))
+		}
+		out := fm.ValueOutput()
+		for it := list.Iterator(); it.HasElem(); it.Next() {
+			if err := out.Put(it.Elem()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
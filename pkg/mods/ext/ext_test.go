@@ -0,0 +1,87 @@
+package ext_test
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/evaltest"
+	"src.elv.sh/pkg/eval/vars"
+	"src.elv.sh/pkg/mods/ext"
+	"src.elv.sh/pkg/testutil"
+)
+
+//go:embed *.elvts
+var transcripts embed.FS
+
+func TestTranscripts(t *testing.T) {
+	evaltest.TestTranscriptsInFS(t, transcripts,
+		"use-ext", func(ev *eval.Evaler) { ev.AddModule("ext", ext.Ns) },
+		"tmp-lib-dir", func(t *testing.T, ev *eval.Evaler) {
+			libdir := testutil.TempDir(t)
+			ev.LibDirs = []string{libdir}
+			ev.ExtendGlobal(eval.BuildNs().AddVar("lib", vars.NewReadOnly(libdir)))
+		},
+		"fake-server", func(t *testing.T) {
+			testutil.Set(t, ext.StartServer,
+				func(name string, args []string) (io.Reader, io.Writer, error) {
+					if name != "fake-server" {
+						return nil, nil, errors.New("no such server")
+					}
+					return startFakeServer(t)
+				})
+		},
+	)
+}
+
+// Starts a server in a goroutine, implementing a few methods for testing.
+func startFakeServer(t *testing.T) (io.Reader, io.Writer, error) {
+	stdinR, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+	t.Cleanup(func() {
+		stdinW.Close()
+		stdoutR.Close()
+	})
+	go func() {
+		defer stdoutW.Close()
+		dec := json.NewDecoder(stdinR)
+		enc := json.NewEncoder(stdoutW)
+		for {
+			var req struct {
+				ID     int
+				Method string
+				Params struct {
+					Args []any
+					Opts map[string]any
+				}
+			}
+			if dec.Decode(&req) != nil {
+				return
+			}
+			resp := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+			switch req.Method {
+			case "elvish.functions":
+				resp["result"] = []string{"echo", "opts", "fail", "bad-result", "exit"}
+			case "echo":
+				resp["result"] = req.Params.Args
+			case "opts":
+				resp["result"] = []any{req.Params.Opts}
+			case "fail":
+				resp["error"] = map[string]any{"code": 1, "message": "something bad"}
+			case "bad-result":
+				resp["result"] = "not a list"
+			case "exit":
+				return
+			default:
+				resp["error"] = map[string]any{"code": -32601, "message": "method not found"}
+			}
+			if enc.Encode(resp) != nil {
+				return
+			}
+		}
+	}()
+	return stdoutR, stdinW, nil
+}
@@ -0,0 +1,3 @@
+package ext
+
+var StartServer = &startServer
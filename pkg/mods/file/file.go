@@ -71,7 +71,7 @@ func (opts *openOutputOpts) SetDefaultOptions() {
 
 var errIfNotExistsAndIfExistsBothError = errors.New("both &if-not-exists and &if-exists are error")
 
-func openOutput(opts openOutputOpts, name string) (vals.File, error) {
+func openOutput(fm *eval.Frame, opts openOutputOpts, name string) (vals.File, error) {
 	perm := opts.CreatePerm
 	if perm < 0 || perm > 0o777 {
 		return nil, errs.OutOfRange{What: "create-perm option",
@@ -108,6 +108,9 @@ func openOutput(opts openOutputOpts, name string) (vals.File, error) {
 			Valid: "truncate, append, update or error", Actual: parse.Quote(opts.IfExists)}
 	}
 
+	if err := fm.Evaler.CheckWrite(name); err != nil {
+		return nil, err
+	}
 	return os.OpenFile(name, mode, fs.FileMode(perm))
 }
 
@@ -155,11 +158,14 @@ func tell(f vals.File) (vals.Num, error) {
 	return vals.Int64ToNum(offset), nil
 }
 
-func truncate(name string, rawSize vals.Num) error {
+func truncate(fm *eval.Frame, name string, rawSize vals.Num) error {
 	size, err := toInt64(rawSize, "size", 0, "0")
 	if err != nil {
 		return err
 	}
+	if err := fm.Evaler.CheckWrite(name); err != nil {
+		return err
+	}
 	return os.Truncate(name, size)
 }
 
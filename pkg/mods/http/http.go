@@ -0,0 +1,178 @@
+// Package http implements the http: module, a simple HTTP client.
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
+)
+
+// Ns is the namespace for the http: module.
+var Ns = eval.BuildNsNamed("http").
+	AddGoFns(map[string]any{
+		"get":     get,
+		"post":    post,
+		"request": request,
+	}).Ns()
+
+type requestOpts struct {
+	Headers   vals.Map
+	Body      string
+	JSON      any
+	Timeout   any
+	ParseJSON bool `name:"parse-json"`
+}
+
+func (opts *requestOpts) SetDefaultOptions() {
+	opts.Headers = vals.EmptyMap
+	opts.Timeout = 0
+}
+
+func get(fm *eval.Frame, opts requestOpts, url string) (vals.Map, error) {
+	return request(fm, opts, http.MethodGet, url)
+}
+
+func post(fm *eval.Frame, opts requestOpts, url string) (vals.Map, error) {
+	return request(fm, opts, http.MethodPost, url)
+}
+
+func request(fm *eval.Frame, opts requestOpts, method, url string) (vals.Map, error) {
+	timeout, ok := eval.ScanDuration(opts.Timeout)
+	if !ok || timeout < 0 {
+		return nil, errs.BadValue{What: "timeout option",
+			Valid: "non-negative number or duration string", Actual: vals.ReprPlain(opts.Timeout)}
+	}
+	body := []byte(opts.Body)
+	if opts.JSON != nil {
+		if opts.Body != "" {
+			return nil, errs.BadValue{What: "options",
+				Valid: "at most one of &body and &json", Actual: "both"}
+		}
+		var err error
+		body, err = json.Marshal(opts.JSON)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ctx := fm.Context()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(method), url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if opts.JSON != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	err = setHeaders(req, opts.Headers)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, timeoutError(ctx, timeout, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, timeoutError(ctx, timeout, err)
+	}
+
+	headers := vals.EmptyMap
+	for name, values := range resp.Header {
+		headers = headers.Assoc(strings.ToLower(name), strings.Join(values, ", "))
+	}
+	var bodyValue any = string(respBody)
+	if opts.ParseJSON {
+		bodyValue, err = parseJSON(respBody)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse response body (status %d) as JSON: %w",
+				resp.StatusCode, err)
+		}
+	}
+	return vals.MakeMap(
+		"status", resp.StatusCode, "headers", headers, "body", bodyValue), nil
+}
+
+// Converts err to the same error thrown by the timeout command if it was caused
+// by the timeout of the request.
+func timeoutError(ctx context.Context, timeout time.Duration, err error) error {
+	if timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return eval.Timeout{Duration: timeout}
+	}
+	return err
+}
+
+// Sets the headers of the request from a map, whose values may be strings or
+// lists of strings.
+func setHeaders(req *http.Request, headers vals.Map) error {
+	for it := headers.Iterator(); it.HasElem(); it.Next() {
+		k, v := it.Elem()
+		name, ok := k.(string)
+		if !ok {
+			return errs.BadValue{What: "header name",
+				Valid: "string", Actual: vals.ReprPlain(k)}
+		}
+		var values []string
+		switch v := v.(type) {
+		case string:
+			values = []string{v}
+		case vals.List:
+			for it := v.Iterator(); it.HasElem(); it.Next() {
+				s, ok := it.Elem().(string)
+				if !ok {
+					return errs.BadValue{What: "header value",
+						Valid: "string or list of strings", Actual: vals.ReprPlain(v)}
+				}
+				values = append(values, s)
+			}
+		default:
+			return errs.BadValue{What: "header value",
+				Valid: "string or list of strings", Actual: vals.ReprPlain(v)}
+		}
+		// The Host header is sent from the Host field, and ignored in Header.
+		if http.CanonicalHeaderKey(name) == "Host" && len(values) > 0 {
+			req.Host = values[len(values)-1]
+			continue
+		}
+		req.Header.Del(name)
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	return nil
+}
+
+// Parses a response body as JSON. An empty body is parsed as $nil.
+func parseJSON(body []byte) (any, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	// See the comments in vals.FromJSON about using json.Number.
+	dec.UseNumber()
+	var v any
+	err := dec.Decode(&v)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+	return vals.FromJSON(v)
+}
@@ -0,0 +1,60 @@
+package http_test
+
+import (
+	"embed"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/evaltest"
+	"src.elv.sh/pkg/eval/vars"
+)
+
+//go:embed *.elvts
+var transcripts embed.FS
+
+func TestTranscripts(t *testing.T) {
+	evaltest.TestTranscriptsInFS(t, transcripts,
+		"test-server", func(t *testing.T, ev *eval.Evaler) {
+			server := httptest.NewServer(http.HandlerFunc(handle))
+			t.Cleanup(server.Close)
+			ev.ExtendGlobal(eval.BuildNs().AddVar("server", vars.NewReadOnly(server.URL)))
+		},
+	)
+}
+
+func handle(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/echo":
+		// Responds with the method, the X-Test and Content-Type headers and
+		// the body of the request, with the status in the status query
+		// parameter.
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Set("X-Host", r.Host)
+		for _, value := range r.Header.Values("X-Test") {
+			w.Header().Add("X-Test", value)
+		}
+		if contentType := r.Header.Get("Content-Type"); contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		if status, err := strconv.Atoi(r.URL.Query().Get("status")); err == nil {
+			w.WriteHeader(status)
+		}
+		io.Copy(w, r.Body)
+	case "/json":
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"name": "elvish", "tags": ["shell", 1]}`+"\n")
+	case "/empty":
+		w.WriteHeader(http.StatusNoContent)
+	case "/slow":
+		<-r.Context().Done()
+	case "/redirect":
+		http.Redirect(w, r, "/json", http.StatusFound)
+	default:
+		http.Error(w, strings.TrimPrefix(r.URL.Path, "/")+" not found", http.StatusNotFound)
+	}
+}
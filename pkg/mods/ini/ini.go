@@ -0,0 +1,291 @@
+// Package ini implements the ini: module for reading and writing INI-style
+// configuration files.
+package ini
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/persistent/hashmap"
+)
+
+// Ns is the namespace for the ini: module.
+var Ns = eval.BuildNsNamed("ini").
+	AddGoFns(map[string]any{
+		"parse":     parse,
+		"to-string": toString,
+	}).Ns()
+
+type lineKind int
+
+const (
+	// A blank line or a comment.
+	otherLine lineKind = iota
+	sectionLine
+	keyLine
+)
+
+// A parsed line. For a section line, name is the section name. For a key line,
+// name is the key, and the value starts at valueStart; valueStart is -1 if the
+// line has no separator. For both, comment is the inline comment at the end of
+// the line, including the whitespace before it.
+type line struct {
+	kind       lineKind
+	name       string
+	valueStart int
+	value      string
+	comment    string
+}
+
+func parseLine(text string) (line, error) {
+	body := splitComment(text)
+	l, err := parseLineBody(body)
+	l.comment = text[len(body):]
+	return l, err
+}
+
+// Returns text without its inline comment, which starts with a '#' or ';' that
+// follows whitespace and is outside double quotes.
+func splitComment(text string) string {
+	inQuote := false
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '\\':
+			i++
+		case c == '"':
+			inQuote = !inQuote
+		case (c == '#' || c == ';') && !inQuote && i > 0 && (text[i-1] == ' ' || text[i-1] == '\t'):
+			return strings.TrimRight(text[:i], " \t")
+		}
+	}
+	return text
+}
+
+func parseLineBody(text string) (line, error) {
+	trimmed := strings.TrimSpace(text)
+	switch {
+	case trimmed == "" || trimmed[0] == '#' || trimmed[0] == ';':
+		return line{kind: otherLine}, nil
+	case trimmed[0] == '[':
+		if trimmed[len(trimmed)-1] != ']' {
+			return line{}, fmt.Errorf("unterminated section header %s", trimmed)
+		}
+		return line{kind: sectionLine, name: strings.TrimSpace(trimmed[1 : len(trimmed)-1])}, nil
+	}
+	i := strings.IndexAny(text, "=:")
+	if i == -1 {
+		return line{kind: keyLine, name: trimmed, valueStart: -1}, nil
+	}
+	valueStart := i + 1
+	for valueStart < len(text) && (text[valueStart] == ' ' || text[valueStart] == '\t') {
+		valueStart++
+	}
+	return line{kind: keyLine, name: strings.TrimSpace(text[:i]),
+		valueStart: valueStart, value: strings.TrimSpace(text[valueStart:])}, nil
+}
+
+func splitLines(text string) []string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func parse(fm *eval.Frame) error {
+	data, err := io.ReadAll(fm.InputFile())
+	if err != nil {
+		return err
+	}
+	m, err := parseString(string(data))
+	if err != nil {
+		return err
+	}
+	return fm.ValueOutput().Put(m)
+}
+
+func parseString(text string) (hashmap.Map, error) {
+	sections := make(map[string]hashmap.Map)
+	var order []string
+	section := ""
+	for i, text := range splitLines(text) {
+		l, err := parseLine(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		switch l.kind {
+		case sectionLine:
+			section = l.name
+			if _, ok := sections[section]; !ok {
+				sections[section] = vals.EmptyMap
+				order = append(order, section)
+			}
+		case keyLine:
+			if _, ok := sections[section]; !ok {
+				sections[section] = vals.EmptyMap
+				order = append(order, section)
+			}
+			sections[section] = sections[section].Assoc(l.name, l.value)
+		}
+	}
+	m := vals.EmptyMap
+	for _, name := range order {
+		m = m.Assoc(name, sections[name])
+	}
+	return m, nil
+}
+
+type toStringOpts struct{ Base string }
+
+func (*toStringOpts) SetDefaultOptions() {}
+
+func toString(opts toStringOpts, data hashmap.Map) (string, error) {
+	sections, err := convertSections(data)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if opts.Base == "" {
+		for _, name := range sortedKeys(sections) {
+			if name != "" {
+				if sb.Len() > 0 {
+					sb.WriteString("\n")
+				}
+				sb.WriteString("[" + name + "]\n")
+			}
+			writeKeys(&sb, sections[name], nil)
+		}
+		return sb.String(), nil
+	}
+	return update(opts.Base, sections)
+}
+
+// Converts the argument of to-string to a Go map, checking that each section
+// is a map.
+func convertSections(data hashmap.Map) (map[string]map[string]string, error) {
+	sections := make(map[string]map[string]string)
+	for it := data.Iterator(); it.HasElem(); it.Next() {
+		k, v := it.Elem()
+		name := vals.ToString(k)
+		section, ok := v.(hashmap.Map)
+		if !ok {
+			return nil, errs.BadValue{What: "INI section " + vals.ReprPlain(name),
+				Valid: "map", Actual: vals.ReprPlain(v)}
+		}
+		keys := make(map[string]string)
+		for it := section.Iterator(); it.HasElem(); it.Next() {
+			k, v := it.Elem()
+			keys[vals.ToString(k)] = vals.ToString(v)
+		}
+		sections[name] = keys
+	}
+	return sections, nil
+}
+
+// Writes the keys of a section that are not in the written set, in
+// lexicographical order.
+func writeKeys(sb *strings.Builder, keys map[string]string, written map[string]bool) {
+	for _, key := range sortedKeys(keys) {
+		if !written[key] {
+			sb.WriteString(key + " = " + keys[key] + "\n")
+		}
+	}
+}
+
+// Updates the base text to reflect sections. Comments and the formatting of
+// keys that still exist are preserved; keys and sections that no longer exist
+// are removed along with the comments in them; new keys are added to the end
+// of their sections, and new sections are added to the end.
+func update(base string, sections map[string]map[string]string) (string, error) {
+	var sb strings.Builder
+	// Lines of the current section that are not followed by any key yet. They
+	// are written after the new keys of the section, so that the new keys
+	// follow the last existing key instead of trailing blank lines or comments.
+	var pending []string
+	written := make(map[string]bool)
+	seenSections := make(map[string]bool)
+	section, keep := "", true
+	finishSection := func() {
+		if keep {
+			writeKeys(&sb, sections[section], written)
+		}
+		for _, text := range pending {
+			sb.WriteString(text + "\n")
+		}
+		pending = nil
+	}
+
+	for i, text := range splitLines(base) {
+		l, err := parseLine(text)
+		if err != nil {
+			return "", fmt.Errorf("line %d: %w", i+1, err)
+		}
+		switch l.kind {
+		case sectionLine:
+			finishSection()
+			if seenSections[l.name] {
+				// Keys of a repeated section have already been merged into
+				// its first occurrence.
+				section, keep, written = l.name, false, nil
+				continue
+			}
+			_, keep = sections[l.name]
+			section, written = l.name, make(map[string]bool)
+			seenSections[section] = true
+			if keep {
+				sb.WriteString(text + "\n")
+			}
+		case keyLine:
+			value, ok := sections[section][l.name]
+			if !keep || !ok || written[l.name] {
+				continue
+			}
+			for _, text := range pending {
+				sb.WriteString(text + "\n")
+			}
+			pending = nil
+			written[l.name] = true
+			if l.valueStart == -1 {
+				if value == "" {
+					sb.WriteString(text + "\n")
+				} else {
+					body := strings.TrimSuffix(text, l.comment)
+					sb.WriteString(strings.TrimRight(body, " \t") + " = " + value + l.comment + "\n")
+				}
+			} else {
+				sb.WriteString(text[:l.valueStart] + value + l.comment + "\n")
+			}
+		case otherLine:
+			if keep {
+				pending = append(pending, text)
+			}
+		}
+	}
+	finishSection()
+
+	for _, name := range sortedKeys(sections) {
+		if name == "" || seenSections[name] {
+			continue
+		}
+		if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n\n") {
+			sb.WriteString("\n")
+		}
+		sb.WriteString("[" + name + "]\n")
+		writeKeys(&sb, sections[name], nil)
+	}
+	return sb.String(), nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
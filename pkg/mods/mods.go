@@ -1,22 +1,40 @@
 // Package mods collects standard library modules.
+//
+// It also keeps a registry of native modules contributed by other packages,
+// which are added along with the standard library modules. See [Register].
 package mods
 
 import (
+	"fmt"
+	"sort"
+	"sync"
+
 	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/mods/archive"
+	"src.elv.sh/pkg/mods/comp"
 	"src.elv.sh/pkg/mods/doc"
+	"src.elv.sh/pkg/mods/encoding"
 	"src.elv.sh/pkg/mods/epm"
+	"src.elv.sh/pkg/mods/ext"
 	"src.elv.sh/pkg/mods/file"
 	"src.elv.sh/pkg/mods/flag"
+	"src.elv.sh/pkg/mods/http"
+	"src.elv.sh/pkg/mods/ini"
 	"src.elv.sh/pkg/mods/math"
 	"src.elv.sh/pkg/mods/md"
 	"src.elv.sh/pkg/mods/os"
 	"src.elv.sh/pkg/mods/path"
 	"src.elv.sh/pkg/mods/platform"
+	"src.elv.sh/pkg/mods/prompt"
 	"src.elv.sh/pkg/mods/re"
 	readline_binding "src.elv.sh/pkg/mods/readline-binding"
 	"src.elv.sh/pkg/mods/runtime"
+	"src.elv.sh/pkg/mods/ssh"
 	"src.elv.sh/pkg/mods/str"
+	"src.elv.sh/pkg/mods/term"
+	"src.elv.sh/pkg/mods/time"
 	"src.elv.sh/pkg/mods/unix"
+	"src.elv.sh/pkg/mods/xml"
 )
 
 // AddTo adds all standard library modules to the Evaler.
@@ -35,9 +53,72 @@ func AddTo(ev *eval.Evaler) {
 	ev.AddModule("doc", doc.Ns)
 	ev.AddModule("os", os.Ns)
 	ev.AddModule("md", md.Ns)
+	ev.AddModule("ext", ext.Ns)
+	ev.AddModule("xml", xml.Ns)
+	ev.AddModule("ini", ini.Ns)
+	ev.AddModule("term", term.Ns(ev))
+	ev.AddModule("archive", archive.Ns)
+	ev.AddModule("encoding", encoding.Ns)
+	ev.AddModule("http", http.Ns)
+	ev.AddModule("time", time.Ns)
+	// Replaced by a version that can record command history when the daemon
+	// is connected.
+	ev.AddModule("ssh", ssh.Ns(nil))
 	if unix.ExposeUnixNs {
 		ev.AddModule("unix", unix.Ns)
 	}
 	ev.BundledModules["epm"] = epm.Code
 	ev.BundledModules["readline-binding"] = readline_binding.Code
+	ev.BundledModules["prompt"] = prompt.Code
+	ev.BundledModules["comp"] = comp.Code
+
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	for _, name := range sortedRegistryNames() {
+		ev.AddModule(name, registry[name](ev))
+	}
+}
+
+var (
+	registryMutex sync.RWMutex
+	registry      = map[string]func(*eval.Evaler) *eval.Ns{}
+)
+
+// Register registers a native module, so that it is added by [AddTo] along
+// with the standard library modules and can be imported with "use $name".
+// The function is called by [AddTo] to build the namespace for each Evaler.
+//
+// Register is intended to be called from the init function of a package
+// implementing a native module. To build an Elvish binary with such modules,
+// write a main package like src.elv.sh/cmd/elvish and add blank imports of the
+// packages implementing them:
+//
+//	import _ "example.com/elvish-image"
+//
+// Register panics if the name is empty or already registered, or if ns is nil.
+// Registered modules are added after the standard library modules, so a
+// registered module with the same name as a standard library module replaces
+// it.
+func Register(name string, ns func(*eval.Evaler) *eval.Ns) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	if name == "" {
+		panic("mods: Register called with empty name")
+	}
+	if ns == nil {
+		panic("mods: Register called with nil function for " + name)
+	}
+	if _, dup := registry[name]; dup {
+		panic(fmt.Sprintf("mods: Register called twice for %s", name))
+	}
+	registry[name] = ns
+}
+
+func sortedRegistryNames() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
@@ -0,0 +1,48 @@
+package mods_test
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/mods"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestRegister(t *testing.T) {
+	mods.Register("test-registered", func(ev *eval.Evaler) *eval.Ns {
+		return eval.BuildNsNamed("test-registered").
+			AddGoFn("double", func(s string) string { return s + s }).Ns()
+	})
+
+	ev := eval.NewEvaler()
+	mods.AddTo(ev)
+	var out []any
+	ev.ExtendGlobal(eval.BuildNs().AddGoFn("out", func(v any) { out = append(out, v) }))
+	err := ev.Eval(parse.Source{Name: "[test]", Code: "use test-registered; out (test-registered:double foo)"}, eval.EvalCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0] != "foofoo" {
+		t.Errorf("got output %v, want [foofoo]", out)
+	}
+}
+
+func TestRegister_Panics(t *testing.T) {
+	ns := func(*eval.Evaler) *eval.Ns { return &eval.Ns{} }
+	mods.Register("test-dup", ns)
+	for _, tc := range []struct {
+		name string
+		f    func()
+	}{
+		{"empty name", func() { mods.Register("", ns) }},
+		{"nil function", func() { mods.Register("test-nil", nil) }},
+		{"duplicate name", func() { mods.Register("test-dup", ns) }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if testutil.Recover(tc.f) == nil {
+				t.Errorf("did not panic")
+			}
+		})
+	}
+}
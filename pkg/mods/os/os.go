@@ -26,10 +26,10 @@ var Ns = eval.BuildNsNamed("os").
 		// File CRUD.
 		"mkdir":      mkdir,
 		"mkdir-all":  mkdirAll,
-		"symlink":    os.Symlink,
+		"symlink":    symlink,
 		"remove":     remove,
 		"remove-all": removeAll,
-		"rename":     os.Rename,
+		"rename":     rename,
 		"chmod":      chmod,
 
 		// File query.
@@ -59,11 +59,17 @@ type mkdirOpts struct{ Perm int }
 
 func (opts *mkdirOpts) SetDefaultOptions() { opts.Perm = 0755 }
 
-func mkdir(opts mkdirOpts, path string) error {
+func mkdir(fm *eval.Frame, opts mkdirOpts, path string) error {
+	if err := fm.Evaler.CheckWrite(path); err != nil {
+		return err
+	}
 	return os.Mkdir(path, os.FileMode(opts.Perm))
 }
 
-func mkdirAll(opts mkdirOpts, path string) error {
+func mkdirAll(fm *eval.Frame, opts mkdirOpts, path string) error {
+	if err := fm.Evaler.CheckWrite(path); err != nil {
+		return err
+	}
 	return os.MkdirAll(path, os.FileMode(opts.Perm))
 }
 
@@ -72,20 +78,26 @@ var ErrEmptyPath = errs.BadValue{
 	What: "path", Valid: "non-empty string", Actual: "empty string"}
 
 // Wraps [os.Remove] to reject empty paths.
-func remove(path string) error {
+func remove(fm *eval.Frame, path string) error {
 	if path == "" {
 		return ErrEmptyPath
 	}
+	if err := fm.Evaler.CheckWrite(path); err != nil {
+		return err
+	}
 	return os.Remove(path)
 }
 
 // Wraps [os.RemoveAll] to reject empty paths, and resolve relative paths to
 // absolute paths first. The latter is necessary since the working directory
 // could be changed while [os.RemoveAll] is running.
-func removeAll(path string) error {
+func removeAll(fm *eval.Frame, path string) error {
 	if path == "" {
 		return ErrEmptyPath
 	}
+	if err := fm.Evaler.CheckWrite(path); err != nil {
+		return err
+	}
 	if !filepath.IsAbs(path) {
 		absPath, err := filepath.Abs(path)
 		if err != nil {
@@ -96,13 +108,32 @@ func removeAll(path string) error {
 	return os.RemoveAll(path)
 }
 
+// Wraps [os.Symlink] to check restricted mode.
+func symlink(fm *eval.Frame, oldname, newname string) error {
+	if err := fm.Evaler.CheckWrite(newname); err != nil {
+		return err
+	}
+	return os.Symlink(oldname, newname)
+}
+
+// Wraps [os.Rename] to check restricted mode.
+func rename(fm *eval.Frame, oldpath, newpath string) error {
+	if err := fm.Evaler.CheckWrite(oldpath); err != nil {
+		return err
+	}
+	if err := fm.Evaler.CheckWrite(newpath); err != nil {
+		return err
+	}
+	return os.Rename(oldpath, newpath)
+}
+
 type chmodOpts struct {
 	SpecialModes any
 }
 
 func (*chmodOpts) SetDefaultOptions() {}
 
-func chmod(opts chmodOpts, perm int, path string) error {
+func chmod(fm *eval.Frame, opts chmodOpts, perm int, path string) error {
 	if perm < 0 || perm > 0x777 {
 		return errs.OutOfRange{What: "permission bits",
 			ValidLow: "0", ValidHigh: "0o777", Actual: strconv.Itoa(perm)}
@@ -115,6 +146,9 @@ func chmod(opts chmodOpts, perm int, path string) error {
 		}
 		mode |= special
 	}
+	if err := fm.Evaler.CheckWrite(path); err != nil {
+		return err
+	}
 	return os.Chmod(path, mode)
 }
 
@@ -163,24 +197,37 @@ func (o *mktempOpt) SetDefaultOptions() {}
 
 // TempDir is exported so that the implementation may be shared by the path:
 // module.
-func TempDir(opts mktempOpt, args ...string) (string, error) {
+func TempDir(fm *eval.Frame, opts mktempOpt, args ...string) (string, error) {
 	pattern, err := optionalTempPattern(args)
 	if err != nil {
 		return "", err
 	}
+	if err := checkTempDir(fm, opts.Dir); err != nil {
+		return "", err
+	}
 	return os.MkdirTemp(opts.Dir, pattern)
 }
 
 // TempFile is exported so that the implementation may be shared by the path:
 // module.
-func TempFile(opts mktempOpt, args ...string) (*os.File, error) {
+func TempFile(fm *eval.Frame, opts mktempOpt, args ...string) (*os.File, error) {
 	pattern, err := optionalTempPattern(args)
 	if err != nil {
 		return nil, err
 	}
+	if err := checkTempDir(fm, opts.Dir); err != nil {
+		return nil, err
+	}
 	return os.CreateTemp(opts.Dir, pattern)
 }
 
+func checkTempDir(fm *eval.Frame, dir string) error {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return fm.Evaler.CheckWrite(dir)
+}
+
 func optionalTempPattern(args []string) (string, error) {
 	switch len(args) {
 	case 0:
@@ -26,6 +26,7 @@ var Ns = eval.BuildNsNamed("path").
 		"ext":    filepath.Ext,
 		"is-abs": filepath.IsAbs,
 		"join":   filepath.Join,
+		"rel":    filepath.Rel,
 
 		// Compatibility aliases; these have moved to os: but are kept here
 		// until we can properly emit deprecation messages.
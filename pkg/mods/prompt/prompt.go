@@ -0,0 +1,8 @@
+package prompt
+
+import _ "embed"
+
+// Code contains the source code of the prompt module.
+//
+//go:embed prompt.elv
+var Code string
@@ -0,0 +1,103 @@
+package ssh
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/parse"
+)
+
+// ServeRemote implements the remote side of ssh:run. It reads a request from
+// stdin, evaluates the code in it with ev, and writes the output to stdout as
+// messages. The stderr of the code is connected to stderr.
+//
+// The modules in the request are written to a temporary directory, which is
+// searched before the module search directories of ev.
+func ServeRemote(ev *eval.Evaler, stdin io.Reader, stdout io.Writer, stderr *os.File) error {
+	var req request
+	if err := json.NewDecoder(stdin).Decode(&req); err != nil {
+		return fmt.Errorf("invalid request: %w", err)
+	}
+
+	var mu sync.Mutex
+	enc := json.NewEncoder(stdout)
+	send := func(msg message) {
+		mu.Lock()
+		defer mu.Unlock()
+		enc.Encode(msg)
+	}
+	sendError := func(err error) {
+		s := err.Error()
+		send(message{Error: &s})
+	}
+
+	if len(req.Modules) > 0 {
+		dir, err := os.MkdirTemp("", "elvish-ssh-")
+		if err != nil {
+			sendError(err)
+			return nil
+		}
+		defer os.RemoveAll(dir)
+		for name, code := range req.Modules {
+			if err := writeModule(dir, name, code); err != nil {
+				sendError(err)
+				return nil
+			}
+		}
+		ev.LibDirs = append([]string{dir}, ev.LibDirs...)
+	}
+
+	port, done, err := eval.PipePort(
+		func(ch <-chan any) {
+			for v := range ch {
+				data, err := json.Marshal(v)
+				if err != nil {
+					// Values that can't be converted to JSON, like NaN,
+					// are sent as their representations.
+					data, _ = json.Marshal(vals.ReprPlain(v))
+				}
+				send(message{Value: data})
+			}
+		},
+		func(r *os.File) {
+			buf := make([]byte, 4096)
+			for {
+				n, err := r.Read(buf)
+				if n > 0 {
+					send(message{Bytes: buf[:n]})
+				}
+				if err != nil {
+					return
+				}
+			}
+		})
+	if err != nil {
+		sendError(err)
+		return nil
+	}
+	errPort := &eval.Port{File: stderr, Chan: eval.BlackholeChan}
+	err = ev.Eval(parse.Source{Name: "[ssh]", Code: req.Code},
+		eval.EvalCfg{Ports: []*eval.Port{eval.DummyInputPort, port, errPort}})
+	done()
+	if err != nil {
+		sendError(err)
+	}
+	return nil
+}
+
+func writeModule(dir, name, code string) error {
+	if !filepath.IsLocal(name) {
+		return fmt.Errorf("invalid module name: %s", parse.Quote(name))
+	}
+	path := filepath.Join(dir, name+".elv")
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(code), 0o600)
+}
@@ -0,0 +1,200 @@
+// Package ssh implements the ssh: module, which runs Elvish code on remote
+// hosts over SSH.
+package ssh
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/store/storedefs"
+)
+
+// Ns returns the namespace for the ssh: module. If s is not nil, it is used to
+// record remote commands in the command history.
+func Ns(s storedefs.Store) *eval.Ns {
+	return eval.BuildNsNamed("ssh").
+		AddGoFns(map[string]any{
+			"run": func(fm *eval.Frame, opts runOpts, host, code string) error {
+				return run(fm, s, opts, host, code)
+			},
+		}).Ns()
+}
+
+// RemoteError is thrown when the code run on a remote host throws an
+// exception.
+type RemoteError struct {
+	Host    string
+	Message string
+}
+
+func (e RemoteError) Error() string {
+	return fmt.Sprintf("exception on %s: %s", e.Host, e.Message)
+}
+
+var errNoHistory = errors.New("command history is not available")
+
+type runOpts struct {
+	Modules vals.List
+	History bool
+	SSH     string
+	Elvish  string
+}
+
+func (o *runOpts) SetDefaultOptions() {
+	o.Modules = vals.EmptyList
+	o.SSH = "ssh"
+	o.Elvish = "elvish"
+}
+
+// Request sent to the remote side, as one JSON object on its stdin.
+type request struct {
+	// Maps module names to their source code.
+	Modules map[string]string `json:"modules"`
+	Code    string            `json:"code"`
+}
+
+// Messages sent from the remote side, each as one JSON object on its own line
+// of its stdout. Exactly one field is set. Bytes are encoded in base64, since
+// they may not be valid UTF-8.
+type message struct {
+	Bytes []byte          `json:"bytes,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+	Error *string         `json:"error,omitempty"`
+}
+
+// Runs ssh with the given arguments. Can be overridden in tests.
+var runSSH = func(name string, args []string, stdin io.Reader, stdout io.Writer, stderr *os.File) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+func run(fm *eval.Frame, s storedefs.Store, opts runOpts, host, code string) error {
+	if opts.History && s == nil {
+		return errNoHistory
+	}
+	var modNames []string
+	if err := vals.ScanListToGo(opts.Modules, &modNames); err != nil {
+		return err
+	}
+	req := request{Modules: make(map[string]string), Code: code}
+	for _, name := range modNames {
+		src, err := readModule(fm.Evaler.LibDirs, name)
+		if err != nil {
+			return err
+		}
+		req.Modules[name] = src
+	}
+	reqJSON, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	if fm.Evaler.IsRestricted() {
+		path, err := exec.LookPath(opts.SSH)
+		if err != nil {
+			return err
+		}
+		if err := fm.Evaler.CheckExternal(path); err != nil {
+			return err
+		}
+	}
+
+	if opts.History {
+		if _, err := s.AddCmd("ssh:run " + parse.Quote(host) + " " + parse.Quote(code)); err != nil {
+			return err
+		}
+	}
+
+	pr, pw := io.Pipe()
+	readErr := make(chan error, 1)
+	go func() {
+		err := readMessages(fm, host, pr)
+		// Drain the rest of the output, so that ssh doesn't block.
+		io.Copy(io.Discard, pr)
+		readErr <- err
+	}()
+	// "--" stops ssh from parsing the host as an option, in case it starts
+	// with "-".
+	args := []string{"--", host, opts.Elvish + " -remote"}
+	err = runSSH(opts.SSH, args, strings.NewReader(string(reqJSON)+"\n"), pw, fm.Port(2).File)
+	pw.Close()
+	if rerr := <-readErr; rerr != nil {
+		return rerr
+	}
+	if err != nil {
+		return fmt.Errorf("running %s on %s: %w", opts.SSH, host, err)
+	}
+	return nil
+}
+
+// Reads the source code of a module from the module search directories.
+func readModule(libDirs []string, name string) (string, error) {
+	if !filepath.IsLocal(name) {
+		return "", errs.BadValue{What: "module name",
+			Valid: "relative path without ..", Actual: parse.Quote(name)}
+	}
+	for _, dir := range libDirs {
+		code, err := os.ReadFile(filepath.Join(dir, name+".elv"))
+		if err == nil {
+			return string(code), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("no such module: %s", name)
+}
+
+// Reads messages from the remote side, writing bytes and values to the output
+// of fm. Returns a RemoteError if the remote side reports an exception.
+func readMessages(fm *eval.Frame, host string, r io.Reader) error {
+	out := fm.ValueOutput()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		var msg message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			return fmt.Errorf("invalid message from %s: %w", host, err)
+		}
+		switch {
+		case msg.Bytes != nil:
+			if _, err := fm.ByteOutput().Write(msg.Bytes); err != nil {
+				return err
+			}
+		case msg.Value != nil:
+			v, err := valueFromJSON(msg.Value)
+			if err != nil {
+				return fmt.Errorf("invalid value from %s: %w", host, err)
+			}
+			if err := out.Put(v); err != nil {
+				return err
+			}
+		case msg.Error != nil:
+			return RemoteError{host, *msg.Error}
+		}
+	}
+	return scanner.Err()
+}
+
+func valueFromJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return vals.FromJSON(v)
+}
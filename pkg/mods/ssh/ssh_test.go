@@ -0,0 +1,53 @@
+package ssh_test
+
+import (
+	"embed"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/evaltest"
+	"src.elv.sh/pkg/eval/vars"
+	"src.elv.sh/pkg/mods/ssh"
+	storemod "src.elv.sh/pkg/mods/store"
+	"src.elv.sh/pkg/store"
+	"src.elv.sh/pkg/testutil"
+)
+
+//go:embed *.elvts
+var transcripts embed.FS
+
+func TestTranscripts(t *testing.T) {
+	evaltest.TestTranscriptsInFS(t, transcripts,
+		"use-ssh", func(ev *eval.Evaler) { ev.AddModule("ssh", ssh.Ns(nil)) },
+		"use-ssh-with-store", func(t *testing.T, ev *eval.Evaler) {
+			s := store.MustTempStore(t)
+			ev.AddModule("ssh", ssh.Ns(s))
+			ev.AddModule("store", storemod.Ns(s))
+		},
+		"tmp-lib-dir", func(t *testing.T, ev *eval.Evaler) {
+			libdir := testutil.TempDir(t)
+			ev.LibDirs = []string{libdir}
+			ev.ExtendGlobal(eval.BuildNs().AddVar("lib", vars.NewReadOnly(libdir)))
+		},
+		"fake-ssh", func(t *testing.T) {
+			testutil.Set(t, ssh.RunSSH, fakeSSH)
+		},
+	)
+}
+
+// Runs the remote side in-process with a new Evaler, instead of running ssh.
+func fakeSSH(name string, args []string, stdin io.Reader, stdout io.Writer, stderr *os.File) error {
+	if name != "ssh" {
+		return errors.New("unexpected ssh command " + name)
+	}
+	if len(args) != 3 || args[0] != "--" || args[2] != "elvish -remote" {
+		return errors.New("unexpected arguments")
+	}
+	if args[1] != "fake-host" {
+		return errors.New("exit status 255")
+	}
+	return ssh.ServeRemote(eval.NewEvaler(), stdin, stdout, stderr)
+}
@@ -0,0 +1,3 @@
+package ssh
+
+var RunSSH = &runSSH
@@ -1,7 +1,17 @@
 package store
 
 import (
+	"os"
+	"slices"
+	"time"
+
 	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/store/histexport"
+	"src.elv.sh/pkg/store/histimport"
+	"src.elv.sh/pkg/store/histstats"
 	"src.elv.sh/pkg/store/storedefs"
 )
 
@@ -12,12 +22,211 @@ func Ns(s storedefs.Store) *eval.Ns {
 			"add-cmd":      s.AddCmd,
 			"del-cmd":      s.DelCmd,
 			"cmd":          s.Cmd,
-			"cmds":         s.CmdsWithSeq,
-			"next-cmd":     s.NextCmd,
-			"prev-cmd":     s.PrevCmd,
+			"cmds": func(fm *eval.Frame, from, upto int) error {
+				records, err := s.CmdRecords(from, upto)
+				if err != nil {
+					return err
+				}
+				out := fm.ValueOutput()
+				for _, r := range records {
+					if err := out.Put(recordToMap(r)); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			"next-cmd": s.NextCmd,
+			"prev-cmd": s.PrevCmd,
+
+			"cmd-counts": func(fm *eval.Frame) error {
+				cmds, err := s.CmdsWithSeq(0, -1)
+				if err != nil {
+					return err
+				}
+				out := fm.ValueOutput()
+				for _, count := range histstats.CountCommands(cmds) {
+					if err := out.Put(count); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			"import-history": func(opts importHistoryOpts, path string) error {
+				return importHistory(s, opts, path)
+			},
+			"export": func(fm *eval.Frame, opts exportOpts) error {
+				_, err := histexport.Export(fm.ByteOutput(), s, opts.Format)
+				return err
+			},
+			"dedup": func() (string, error) {
+				d, err := s.Dedup()
+				return string(d), err
+			},
+			"set-dedup": func(mode string) error {
+				if !slices.Contains(storedefs.DedupModes, storedefs.Dedup(mode)) {
+					return errs.BadValue{What: "dedup mode",
+						Valid: "none, consecutive or all", Actual: parse.Quote(mode)}
+				}
+				return s.SetDedup(storedefs.Dedup(mode))
+			},
+			"retention": func() (vals.Map, error) {
+				r, err := s.Retention()
+				return vals.MakeMap("max-size", r.MaxSize, "max-age", r.MaxAge.Seconds()), err
+			},
+			"set-retention": func(opts retentionOpts) error {
+				return s.SetRetention(storedefs.Retention{MaxSize: opts.MaxSize,
+					MaxAge: time.Duration(opts.MaxAge * float64(time.Second))})
+			},
+			"prune": func(opts pruneOpts) (int, error) {
+				before, err := parseTime(opts.Before)
+				if err != nil {
+					return 0, err
+				}
+				return s.Prune(storedefs.PruneFilter{Before: before, Pattern: opts.Match})
+			},
+			"ignore-patterns": func(fm *eval.Frame) error {
+				patterns, err := s.IgnorePatterns()
+				if err != nil {
+					return err
+				}
+				out := fm.ValueOutput()
+				for _, p := range patterns {
+					if err := out.Put(p); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			"set-ignore-patterns": func(patterns ...string) error {
+				return s.SetIgnorePatterns(patterns)
+			},
 
 			"add-dir": func(dir string) error { return s.AddDir(dir, 1) },
 			"del-dir": s.DelDir,
 			"dirs":    func() ([]storedefs.Dir, error) { return s.Dirs(storedefs.NoBlacklist) },
+
+			"pin-dir":   s.PinDir,
+			"unpin-dir": s.UnpinDir,
+			"pinned-dirs": func(fm *eval.Frame) error {
+				dirs, err := s.PinnedDirs()
+				if err != nil {
+					return err
+				}
+				out := fm.ValueOutput()
+				for _, dir := range dirs {
+					if err := out.Put(dir); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			"dir-scoring": func() (vals.Map, error) {
+				d, err := s.DirScoring()
+				return vals.MakeMap("mode", string(d.Mode),
+					"decay", d.Decay, "increment", d.Increment), err
+			},
+			"set-dir-scoring": func(opts dirScoringOpts) error {
+				mode := storedefs.DirScoringMode(opts.Mode)
+				if mode != "" && !slices.Contains(storedefs.DirScoringModes, mode) {
+					return errs.BadValue{What: "&mode option",
+						Valid: "frecency, frequency or recency", Actual: parse.Quote(opts.Mode)}
+				}
+				return s.SetDirScoring(storedefs.DirScoring{
+					Mode: mode, Decay: opts.Decay, Increment: opts.Increment})
+			},
 		}).Ns()
 }
+
+// Converts a command record to a map. Unknown fields have the value $nil, and
+// times and durations are in seconds.
+func recordToMap(r storedefs.CmdRecord) vals.Map {
+	var t, dir, workspace, duration, exit any
+	if !r.Time.IsZero() {
+		t = float64(r.Time.UnixNano()) / float64(time.Second)
+	}
+	if r.Dir != "" {
+		dir = r.Dir
+	}
+	if r.Workspace != "" {
+		workspace = r.Workspace
+	}
+	if r.Result != nil {
+		duration, exit = r.Result.Duration.Seconds(), r.Result.Exit
+	}
+	return vals.MakeMap("seq", r.Seq, "text", r.Text,
+		"time", t, "dir", dir, "workspace", workspace,
+		"duration", duration, "exit", exit)
+}
+
+type retentionOpts struct {
+	MaxSize int
+	MaxAge  float64
+}
+
+func (*retentionOpts) SetDefaultOptions() {}
+
+type pruneOpts struct {
+	Before any
+	Match  string
+}
+
+func (*pruneOpts) SetDefaultOptions() {}
+
+// Layouts accepted by parseTime, tried in order.
+var timeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"}
+
+// Converts the &before option of store:prune to a time. The option may be $nil
+// for no time, a Unix time in seconds, or a date or time in a format in
+// timeLayouts, in the local time zone if not specified.
+func parseTime(v any) (time.Time, error) {
+	if v == nil {
+		return time.Time{}, nil
+	}
+	var sec float64
+	if err := vals.ScanToGo(v, &sec); err == nil {
+		return time.Unix(0, int64(sec*float64(time.Second))), nil
+	}
+	if s, ok := v.(string); ok {
+		for _, layout := range timeLayouts {
+			if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return time.Time{}, errs.BadValue{What: "&before option",
+		Valid: "Unix time in seconds or date", Actual: vals.ReprPlain(v)}
+}
+
+type dirScoringOpts struct {
+	Mode      string
+	Decay     float64
+	Increment float64
+}
+
+func (*dirScoringOpts) SetDefaultOptions() {}
+
+type exportOpts struct{ Format string }
+
+func (o *exportOpts) SetDefaultOptions() { o.Format = "text" }
+
+type importHistoryOpts struct{ Format string }
+
+func (*importHistoryOpts) SetDefaultOptions() {}
+
+func importHistory(s storedefs.Store, opts importHistoryOpts, path string) error {
+	format := opts.Format
+	if format == "" {
+		format = histimport.GuessFormat(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	entries, err := histimport.Parse(format, f)
+	if err != nil {
+		return err
+	}
+	_, err = histimport.Import(s, entries)
+	return err
+}
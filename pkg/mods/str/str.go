@@ -13,6 +13,7 @@ import (
 	"src.elv.sh/pkg/eval"
 	"src.elv.sh/pkg/eval/errs"
 	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/wcwidth"
 )
 
 var Ns = eval.BuildNsNamed("str").
@@ -26,6 +27,7 @@ var Ns = eval.BuildNsNamed("str").
 		"fields":          strings.Fields,
 		"from-codepoints": fromCodepoints,
 		"from-utf8-bytes": fromUtf8Bytes,
+		"graphemes":       graphemes,
 		"has-prefix":      strings.HasPrefix,
 		"has-suffix":      strings.HasSuffix,
 		"index":           strings.Index,
@@ -34,9 +36,11 @@ var Ns = eval.BuildNsNamed("str").
 		"join":       join,
 		"last-index": strings.LastIndex,
 		// TODO: LastIndexFunc, Map
-		"repeat":  repeat,
-		"replace": replace,
-		"split":   split,
+		"pad-left":  padLeft,
+		"pad-right": padRight,
+		"repeat":    repeat,
+		"replace":   replace,
+		"split":     split,
 		// TODO: SplitAfter
 		//lint:ignore SA1019 Elvish builtins need to be formally deprecated
 		// before removal
@@ -138,6 +142,32 @@ func repeat(s string, n int) (string, error) {
 	return strings.Repeat(s, n), nil
 }
 
+type padOpt struct{ With string }
+
+func (o *padOpt) SetDefaultOptions() { o.With = " " }
+
+func padLeft(opts padOpt, s string, width int) (string, error) {
+	padding, err := pad(opts, s, width)
+	return padding + s, err
+}
+
+func padRight(opts padOpt, s string, width int) (string, error) {
+	padding, err := pad(opts, s, width)
+	return s + padding, err
+}
+
+// Returns the padding needed to make s at least width columns wide.
+func pad(opts padOpt, s string, width int) (string, error) {
+	if utf8.RuneCountInString(opts.With) != 1 || wcwidth.Of(opts.With) != 1 {
+		return "", errs.BadValue{What: "with option",
+			Valid: "single character of width 1", Actual: vals.ReprPlain(opts.With)}
+	}
+	if w := wcwidth.Of(s); w < width {
+		return strings.Repeat(opts.With, width-w), nil
+	}
+	return "", nil
+}
+
 type maxOpt struct{ Max int }
 
 func (o *maxOpt) SetDefaultOptions() { o.Max = -1 }
@@ -169,6 +199,19 @@ func toCodepoints(fm *eval.Frame, s string) error {
 	return nil
 }
 
+func graphemes(fm *eval.Frame, s string) error {
+	out := fm.ValueOutput()
+	for s != "" {
+		n := wcwidth.NextCluster(s)
+		err := out.Put(s[:n])
+		if err != nil {
+			return err
+		}
+		s = s[n:]
+	}
+	return nil
+}
+
 func toUtf8Bytes(fm *eval.Frame, s string) error {
 	out := fm.ValueOutput()
 	for _, r := range []byte(s) {
@@ -0,0 +1,155 @@
+// Package term implements the term: module, which exposes information about
+// the terminal.
+package term
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"src.elv.sh/pkg/env"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/eval/vars"
+	"src.elv.sh/pkg/sys"
+)
+
+var errNotTerminal = errors.New("not a terminal")
+
+// Ns returns the namespace for the term: module.
+func Ns(ev *eval.Evaler) *eval.Ns {
+	return eval.BuildNsNamed("term").
+		AddVar("resize-hooks", newResizeHooksVar(ev)).
+		AddGoFns(map[string]any{
+			"is-tty":              isTTY,
+			"size":                size,
+			"color-depth":         colorDepth,
+			"supports-hyperlinks": supportsHyperlinks,
+			"hyperlink":           hyperlink,
+		}).Ns()
+}
+
+func isTTY(fm *eval.Frame) bool {
+	p := fm.Port(1)
+	return p != nil && p.File != nil && sys.IsATTY(p.File.Fd())
+}
+
+func size(fm *eval.Frame) (vals.Map, error) {
+	var files []*os.File
+	for _, i := range []int{1, 2, 0} {
+		if p := fm.Port(i); p != nil && p.File != nil {
+			files = append(files, p.File)
+		}
+	}
+	return terminalSize(files...)
+}
+
+// Returns the size of the first file that is a terminal.
+func terminalSize(files ...*os.File) (vals.Map, error) {
+	for _, f := range files {
+		if !sys.IsATTY(f.Fd()) {
+			continue
+		}
+		rows, cols := sys.WinSize(f)
+		if rows <= 0 || cols <= 0 {
+			continue
+		}
+		return vals.MakeMap("rows", rows, "columns", cols), nil
+	}
+	return nil, errNotTerminal
+}
+
+func colorDepth() int {
+	term := os.Getenv(env.TERM)
+	switch {
+	case os.Getenv(env.NO_COLOR) != "" || term == "dumb":
+		return 0
+	case os.Getenv(env.COLORTERM) == "truecolor" || os.Getenv(env.COLORTERM) == "24bit":
+		return 24
+	case os.Getenv("WT_SESSION") != "":
+		// Windows Terminal, which doesn't set $TERM or $COLORTERM.
+		return 24
+	case strings.Contains(term, "256color"):
+		return 8
+	case term == "" && runtime.GOOS != "windows":
+		return 0
+	}
+	return 4
+}
+
+// Terminals that support hyperlinks and can be identified by $TERM_PROGRAM or
+// $TERM.
+var (
+	hyperlinkTermPrograms = []string{"iTerm.app", "WezTerm", "vscode", "ghostty"}
+	hyperlinkTerms        = []string{"kitty", "foot", "alacritty", "ghostty", "wezterm"}
+)
+
+func supportsHyperlinks() bool {
+	term := os.Getenv(env.TERM)
+	if term == "dumb" {
+		return false
+	}
+	for _, p := range hyperlinkTermPrograms {
+		if os.Getenv("TERM_PROGRAM") == p {
+			return true
+		}
+	}
+	for _, t := range hyperlinkTerms {
+		if strings.Contains(term, t) {
+			return true
+		}
+	}
+	if os.Getenv("WT_SESSION") != "" || os.Getenv("KONSOLE_VERSION") != "" {
+		return true
+	}
+	// VTE-based terminals support hyperlinks since 0.50.
+	vte, err := strconv.Atoi(os.Getenv("VTE_VERSION"))
+	return err == nil && vte >= 5000
+}
+
+func hyperlink(url, text string) string {
+	return "\033]8;;" + url + "\033\\" + text + "\033]8;;\033\\"
+}
+
+// A variable holding the resize hooks. The signal handler for SIGWINCH is only
+// installed when the variable is first assigned.
+type resizeHooksVar struct {
+	vars.PtrVar
+	ev   *eval.Evaler
+	once *sync.Once
+}
+
+func newResizeHooksVar(ev *eval.Evaler) resizeHooksVar {
+	hooks := vals.EmptyList
+	return resizeHooksVar{vars.FromPtr(&hooks), ev, new(sync.Once)}
+}
+
+func (v resizeHooksVar) Set(val any) error {
+	err := v.PtrVar.Set(val)
+	if err == nil {
+		v.once.Do(v.watch)
+	}
+	return err
+}
+
+func (v resizeHooksVar) watch() {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, sys.SIGWINCH)
+	go func() {
+		for range sigCh {
+			v.callHooks()
+		}
+	}()
+}
+
+func (v resizeHooksVar) callHooks() {
+	size, err := terminalSize(os.Stdout, os.Stderr, os.Stdin)
+	if err != nil {
+		return
+	}
+	eval.CallHook(v.ev, nil, "$term:resize-hooks", v.Get().(vals.List), size)
+}
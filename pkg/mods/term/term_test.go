@@ -0,0 +1,27 @@
+package term_test
+
+import (
+	"embed"
+	"testing"
+
+	"src.elv.sh/pkg/eval/evaltest"
+	"src.elv.sh/pkg/testutil"
+)
+
+//go:embed *.elvts *.elv
+var transcripts embed.FS
+
+var termEnvVars = []string{
+	"NO_COLOR", "TERM", "COLORTERM", "TERM_PROGRAM",
+	"WT_SESSION", "KONSOLE_VERSION", "VTE_VERSION",
+}
+
+func TestTranscripts(t *testing.T) {
+	evaltest.TestTranscriptsInFS(t, transcripts,
+		"clean-term-env", func(t *testing.T) {
+			for _, name := range termEnvVars {
+				testutil.Unsetenv(t, name)
+			}
+		},
+	)
+}
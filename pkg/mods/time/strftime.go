@@ -0,0 +1,392 @@
+package time
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Directives that are shorthands for other formats.
+var strftimeShorthands = map[byte]string{
+	'c': "%a %b %e %H:%M:%S %Y",
+	'D': "%m/%d/%y",
+	'F': "%Y-%m-%d",
+	'r': "%I:%M:%S %p",
+	'R': "%H:%M",
+	'T': "%H:%M:%S",
+	'x': "%m/%d/%y",
+	'X': "%H:%M:%S",
+}
+
+// Directives that correspond to elements of Go layouts.
+var strftimeLayouts = map[byte]string{
+	'a': "Mon",
+	'A': "Monday",
+	'b': "Jan",
+	'B': "January",
+	'd': "02",
+	'e': "_2",
+	'h': "Jan",
+	'H': "15",
+	'I': "03",
+	'j': "002",
+	'm': "01",
+	'M': "04",
+	'p': "PM",
+	'S': "05",
+	'y': "06",
+	'Y': "2006",
+	'z': "-0700",
+	'Z': "MST",
+}
+
+var errTrailingPercent = errors.New("format ends with %")
+
+func unsupportedDirective(d byte) error {
+	return fmt.Errorf("unsupported directive %%%c", d)
+}
+
+// Formats t according to a format string of the C strftime function,
+// including some common GNU extensions.
+func strftime(t time.Time, format string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			sb.WriteByte(format[i])
+			continue
+		}
+		i++
+		if i == len(format) {
+			return "", errTrailingPercent
+		}
+		d := format[i]
+		if d == ':' && i+1 < len(format) && format[i+1] == 'z' {
+			i++
+			sb.WriteString(t.Format("-07:00"))
+			continue
+		}
+		if shorthand, ok := strftimeShorthands[d]; ok {
+			s, _ := strftime(t, shorthand)
+			sb.WriteString(s)
+			continue
+		}
+		if layout, ok := strftimeLayouts[d]; ok {
+			sb.WriteString(t.Format(layout))
+			continue
+		}
+		switch d {
+		case 'C':
+			fmt.Fprintf(&sb, "%02d", t.Year()/100)
+		case 'G':
+			year, _ := t.ISOWeek()
+			fmt.Fprintf(&sb, "%d", year)
+		case 'g':
+			year, _ := t.ISOWeek()
+			fmt.Fprintf(&sb, "%02d", year%100)
+		case 'k':
+			fmt.Fprintf(&sb, "%2d", t.Hour())
+		case 'l':
+			fmt.Fprintf(&sb, "%2s", t.Format("3"))
+		case 'N':
+			fmt.Fprintf(&sb, "%09d", t.Nanosecond())
+		case 'n':
+			sb.WriteByte('\n')
+		case 'P':
+			sb.WriteString(t.Format("pm"))
+		case 's':
+			fmt.Fprintf(&sb, "%d", t.Unix())
+		case 't':
+			sb.WriteByte('\t')
+		case 'u':
+			fmt.Fprintf(&sb, "%d", (int(t.Weekday())+6)%7+1)
+		case 'U':
+			fmt.Fprintf(&sb, "%02d", (t.YearDay()+6-int(t.Weekday()))/7)
+		case 'V':
+			_, week := t.ISOWeek()
+			fmt.Fprintf(&sb, "%02d", week)
+		case 'w':
+			fmt.Fprintf(&sb, "%d", t.Weekday())
+		case 'W':
+			fmt.Fprintf(&sb, "%02d", (t.YearDay()+6-(int(t.Weekday())+6)%7)/7)
+		case '%':
+			sb.WriteByte('%')
+		default:
+			return "", unsupportedDirective(d)
+		}
+	}
+	return sb.String(), nil
+}
+
+// Parses s according to format, which uses the same directives as strftime.
+// The location is used unless the format includes a time zone.
+func strptime(format, s string, loc *time.Location) (time.Time, error) {
+	p := strptimeParser{s: s, month: 1, day: 1, hour12: -1, pm: -1, yearDay: -1}
+	err := p.parse(format)
+	if err == nil && p.pos < len(p.s) {
+		err = fmt.Errorf("extra text %q", p.s[p.pos:])
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing time %q as %q: %w", s, format, err)
+	}
+	t, err := p.time(loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing time %q as %q: %w", s, format, err)
+	}
+	return t, nil
+}
+
+type strptimeParser struct {
+	s   string
+	pos int
+
+	year, month, day int
+	hour, min, sec   int
+	nsec             int
+	// The hour parsed from %I, or -1.
+	hour12 int
+	// Whether the time is PM, or -1 if not parsed.
+	pm int
+	// The day of year parsed from %j, or -1.
+	yearDay int
+	// The time zone, or nil if not parsed.
+	loc *time.Location
+	// The Unix time parsed from %s.
+	unix    int64
+	hasUnix bool
+}
+
+func (p *strptimeParser) parse(format string) error {
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if isSpace(c) {
+			// Whitespace in the format matches any amount of whitespace.
+			p.skipSpaces()
+			continue
+		}
+		if c != '%' {
+			if p.pos < len(p.s) && p.s[p.pos] == c {
+				p.pos++
+				continue
+			}
+			return fmt.Errorf("expected %q", c)
+		}
+		i++
+		if i == len(format) {
+			return errTrailingPercent
+		}
+		d := format[i]
+		if d == ':' && i+1 < len(format) && format[i+1] == 'z' {
+			i++
+			d = 'z'
+		}
+		if shorthand, ok := strftimeShorthands[d]; ok {
+			if err := p.parse(shorthand); err != nil {
+				return err
+			}
+			continue
+		}
+		var err error
+		switch d {
+		case 'Y':
+			p.year, err = p.number(d, 4, true)
+		case 'y':
+			var y int
+			y, err = p.number(d, 2, false)
+			// The same rule as POSIX.
+			if y < 69 {
+				p.year = 2000 + y
+			} else {
+				p.year = 1900 + y
+			}
+		case 'm':
+			p.month, err = p.numberIn(d, 2, 1, 12)
+		case 'd':
+			p.day, err = p.numberIn(d, 2, 1, 31)
+		case 'e':
+			p.skipSpaces()
+			p.day, err = p.numberIn(d, 2, 1, 31)
+		case 'H':
+			p.hour, err = p.numberIn(d, 2, 0, 23)
+		case 'k':
+			p.skipSpaces()
+			p.hour, err = p.numberIn(d, 2, 0, 23)
+		case 'I':
+			p.hour12, err = p.numberIn(d, 2, 1, 12)
+		case 'l':
+			p.skipSpaces()
+			p.hour12, err = p.numberIn(d, 2, 1, 12)
+		case 'M':
+			p.min, err = p.numberIn(d, 2, 0, 59)
+		case 'S':
+			p.sec, err = p.numberIn(d, 2, 0, 59)
+		case 'N':
+			start := p.pos
+			p.nsec, err = p.number(d, 9, false)
+			for n := p.pos - start; n < 9; n++ {
+				p.nsec *= 10
+			}
+		case 'j':
+			p.yearDay, err = p.numberIn(d, 3, 1, 366)
+		case 'b', 'B', 'h':
+			var i int
+			i, err = p.name(d, longMonthNames)
+			p.month = i + 1
+		case 'a', 'A':
+			// The weekday is checked for validity but otherwise ignored.
+			_, err = p.name(d, longDayNames)
+		case 'p', 'P':
+			p.pm, err = p.name(d, []string{"am", "pm"})
+		case 'z':
+			p.loc, err = p.offset()
+		case 'Z':
+			start := p.pos
+			for p.pos < len(p.s) && isLetter(p.s[p.pos]) {
+				p.pos++
+			}
+			switch name := p.s[start:p.pos]; name {
+			case "":
+				err = errors.New("expected time zone name for %Z")
+			case "UTC", "GMT", "Z":
+				p.loc = time.UTC
+			}
+		case 's':
+			var n int
+			n, err = p.number(d, 19, true)
+			p.unix, p.hasUnix = int64(n), true
+		case 'n', 't':
+			p.skipSpaces()
+		case '%':
+			if p.pos < len(p.s) && p.s[p.pos] == '%' {
+				p.pos++
+			} else {
+				err = errors.New("expected '%'")
+			}
+		default:
+			err = unsupportedDirective(d)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *strptimeParser) time(loc *time.Location) (time.Time, error) {
+	if p.loc != nil {
+		loc = p.loc
+	}
+	if p.hasUnix {
+		return time.Unix(p.unix, 0).In(loc), nil
+	}
+	hour := p.hour
+	if p.hour12 != -1 {
+		hour = p.hour12 % 12
+		if p.pm == 1 {
+			hour += 12
+		}
+	} else if p.pm == 1 && hour < 12 {
+		hour += 12
+	}
+	month, day := p.month, p.day
+	if p.yearDay != -1 {
+		t := time.Date(p.year, 1, p.yearDay, 0, 0, 0, 0, time.UTC)
+		if t.Year() != p.year {
+			return time.Time{}, errors.New("day of year out of range")
+		}
+		month, day = int(t.Month()), t.Day()
+	}
+	t := time.Date(p.year, time.Month(month), day, hour, p.min, p.sec, p.nsec, loc)
+	if t.Day() != day {
+		return time.Time{}, errors.New("day out of range")
+	}
+	return t, nil
+}
+
+// Parses a number with at most maxDigits digits, optionally preceded by a
+// sign.
+func (p *strptimeParser) number(d byte, maxDigits int, signed bool) (int, error) {
+	start := p.pos
+	if signed && p.pos < len(p.s) && (p.s[p.pos] == '+' || p.s[p.pos] == '-') {
+		p.pos++
+	}
+	digitsStart := p.pos
+	for p.pos < len(p.s) && p.pos-digitsStart < maxDigits && isDigit(p.s[p.pos]) {
+		p.pos++
+	}
+	if p.pos == digitsStart {
+		return 0, fmt.Errorf("expected number for %%%c", d)
+	}
+	n, err := strconv.Atoi(p.s[start:p.pos])
+	if err != nil {
+		return 0, fmt.Errorf("number for %%%c out of range", d)
+	}
+	return n, nil
+}
+
+func (p *strptimeParser) numberIn(d byte, maxDigits, lo, hi int) (int, error) {
+	n, err := p.number(d, maxDigits, false)
+	if err == nil && (n < lo || n > hi) {
+		return 0, fmt.Errorf("number for %%%c out of range", d)
+	}
+	return n, err
+}
+
+// Parses a name in names or its first three letters, case-insensitively, and
+// returns its index.
+func (p *strptimeParser) name(d byte, names []string) (int, error) {
+	rest := strings.ToLower(p.s[p.pos:])
+	for i, name := range names {
+		if strings.HasPrefix(rest, name) {
+			p.pos += len(name)
+			return i, nil
+		}
+	}
+	for i, name := range names {
+		if len(name) > 3 && strings.HasPrefix(rest, name[:3]) {
+			p.pos += 3
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("expected name for %%%c", d)
+}
+
+// Parses a time zone offset: "Z", or a sign followed by hh, hhmm or hh:mm.
+func (p *strptimeParser) offset() (*time.Location, error) {
+	if p.pos < len(p.s) && p.s[p.pos] == 'Z' {
+		p.pos++
+		return time.UTC, nil
+	}
+	start := p.pos
+	if p.pos < len(p.s) && (p.s[p.pos] == '+' || p.s[p.pos] == '-') {
+		p.pos++
+		for p.pos < len(p.s) && (isDigit(p.s[p.pos]) || p.s[p.pos] == ':') && p.pos-start < 6 {
+			p.pos++
+		}
+		if offset, ok := parseOffset(p.s[start:p.pos]); ok {
+			return time.FixedZone("", offset), nil
+		}
+	}
+	p.pos = start
+	return nil, errors.New("expected time zone offset for %z")
+}
+
+func (p *strptimeParser) skipSpaces() {
+	for p.pos < len(p.s) && isSpace(p.s[p.pos]) {
+		p.pos++
+	}
+}
+
+var longMonthNames = []string{
+	"january", "february", "march", "april", "may", "june",
+	"july", "august", "september", "october", "november", "december",
+}
+
+var longDayNames = []string{
+	"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday",
+}
+
+func isDigit(c byte) bool  { return '0' <= c && c <= '9' }
+func isSpace(c byte) bool  { return c == ' ' || c == '\t' || c == '\n' || c == '\r' }
+func isLetter(c byte) bool { return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') }
@@ -0,0 +1,227 @@
+// Package time implements the time: module, for working with times, dates and
+// durations.
+package time
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/eval/vars"
+	"src.elv.sh/pkg/persistent/hash"
+)
+
+// Ns is the namespace for the time: module.
+var Ns = eval.BuildNsNamed("time").
+	AddVars(map[string]vars.Var{
+		"rfc3339":      vars.NewReadOnly(time.RFC3339),
+		"rfc3339-nano": vars.NewReadOnly(time.RFC3339Nano),
+		"rfc1123":      vars.NewReadOnly(time.RFC1123),
+		"rfc1123z":     vars.NewReadOnly(time.RFC1123Z),
+		"kitchen":      vars.NewReadOnly(time.Kitchen),
+		"date-time":    vars.NewReadOnly(time.DateTime),
+		"date-only":    vars.NewReadOnly(time.DateOnly),
+		"time-only":    vars.NewReadOnly(time.TimeOnly),
+	}).
+	AddGoFns(map[string]any{
+		"now":    now,
+		"unix":   unix,
+		"parse":  parseTime,
+		"format": format,
+		"in":     in,
+
+		"add": add,
+		"sub": sub,
+
+		"parse-duration":  parseDuration,
+		"format-duration": formatDuration,
+	}).Ns()
+
+// A time value.
+type timeValue struct{ t time.Time }
+
+func (timeValue) Kind() string { return "time" }
+
+// Two time values are equal if they are the same instant in the same location.
+func (v timeValue) Equal(other any) bool {
+	w, ok := other.(timeValue)
+	return ok && v.t.Equal(w.t) && locationName(v.t) == locationName(w.t)
+}
+
+func (v timeValue) Hash() uint32 {
+	return hash.DJB(hash.UInt64(uint64(v.t.UnixNano())), hash.String(locationName(v.t)))
+}
+
+func (v timeValue) String() string { return v.t.Format(time.RFC3339Nano) }
+
+func (v timeValue) Repr(int) string { return "<time " + v.String() + ">" }
+
+func (v timeValue) Fields() vals.MethodMap { return timeFields{v.t} }
+
+type timeFields struct{ t time.Time }
+
+func (f timeFields) Year() int        { return f.t.Year() }
+func (f timeFields) Month() int       { return int(f.t.Month()) }
+func (f timeFields) Day() int         { return f.t.Day() }
+func (f timeFields) Hour() int        { return f.t.Hour() }
+func (f timeFields) Minute() int      { return f.t.Minute() }
+func (f timeFields) Second() int      { return f.t.Second() }
+func (f timeFields) Nanosecond() int  { return f.t.Nanosecond() }
+func (f timeFields) Weekday() int     { return int(f.t.Weekday()) }
+func (f timeFields) YearDay() int     { return f.t.YearDay() }
+func (f timeFields) Unix() int        { return int(f.t.Unix()) }
+func (f timeFields) Offset() int      { _, offset := f.t.Zone(); return offset }
+func (f timeFields) Zone() string     { return f.t.Format("MST") }
+func (f timeFields) Location() string { return locationName(f.t) }
+
+// Returns the name of the location of t, or its offset like "+09:00" if the
+// location is a fixed offset with no name.
+func locationName(t time.Time) string {
+	if name := t.Location().String(); name != "" {
+		return name
+	}
+	return t.Format("-07:00")
+}
+
+func now() timeValue { return timeValue{time.Now()} }
+
+func unix(sec vals.Num) (timeValue, error) {
+	switch sec := sec.(type) {
+	case int:
+		return timeValue{time.Unix(int64(sec), 0)}, nil
+	default:
+		f := vals.ConvertToFloat64(sec)
+		if math.IsInf(f, 0) || math.IsNaN(f) || math.Abs(f) > 1<<62 {
+			return timeValue{}, errs.BadValue{What: "seconds",
+				Valid: "finite number in range", Actual: vals.ReprPlain(sec)}
+		}
+		whole, frac := math.Modf(f)
+		return timeValue{time.Unix(int64(whole), int64(frac*1e9))}, nil
+	}
+}
+
+type parseOpts struct {
+	Strftime bool
+	TZ       string
+}
+
+func (opts *parseOpts) SetDefaultOptions() { opts.TZ = "Local" }
+
+func parseTime(opts parseOpts, layout, s string) (timeValue, error) {
+	loc, err := location(opts.TZ)
+	if err != nil {
+		return timeValue{}, err
+	}
+	var t time.Time
+	if opts.Strftime {
+		t, err = strptime(layout, s, loc)
+	} else {
+		t, err = time.ParseInLocation(layout, s, loc)
+	}
+	return timeValue{t}, err
+}
+
+type formatOpts struct{ Strftime bool }
+
+func (*formatOpts) SetDefaultOptions() {}
+
+func format(opts formatOpts, layout string, args ...timeValue) (string, error) {
+	var t time.Time
+	switch len(args) {
+	case 0:
+		t = time.Now()
+	case 1:
+		t = args[0].t
+	default:
+		return "", errs.ArityMismatch{What: "arguments",
+			ValidLow: 1, ValidHigh: 2, Actual: 1 + len(args)}
+	}
+	if opts.Strftime {
+		return strftime(t, layout)
+	}
+	return t.Format(layout), nil
+}
+
+func in(tz string, v timeValue) (timeValue, error) {
+	loc, err := location(tz)
+	if err != nil {
+		return timeValue{}, err
+	}
+	return timeValue{v.t.In(loc)}, nil
+}
+
+// Returns the location with the given name, which may be "Local", "UTC", a
+// name in the IANA time zone database like "Asia/Tokyo", or a fixed offset
+// like "+09:00", "+0900" or "+09".
+func location(name string) (*time.Location, error) {
+	if len(name) > 1 && (name[0] == '+' || name[0] == '-') {
+		if offset, ok := parseOffset(name); ok {
+			return time.FixedZone("", offset), nil
+		}
+	}
+	return time.LoadLocation(name)
+}
+
+// Parses an offset of the form "+hh:mm", "+hhmm" or "+hh" (or the same with
+// "-"), returning the offset in seconds.
+func parseOffset(s string) (int, bool) {
+	sign := 1
+	if s[0] == '-' {
+		sign = -1
+	}
+	digits := s[1:]
+	if len(digits) == 5 && digits[2] == ':' {
+		digits = digits[:2] + digits[3:]
+	}
+	if (len(digits) != 2 && len(digits) != 4) || strings.Trim(digits, "0123456789") != "" {
+		return 0, false
+	}
+	h, _ := strconv.Atoi(digits[:2])
+	m := 0
+	if len(digits) == 4 {
+		m, _ = strconv.Atoi(digits[2:])
+	}
+	if h > 23 || m > 59 {
+		return 0, false
+	}
+	return sign * (h*3600 + m*60), true
+}
+
+func add(v timeValue, duration any) (timeValue, error) {
+	d, err := scanDuration(duration)
+	if err != nil {
+		return timeValue{}, err
+	}
+	return timeValue{v.t.Add(d)}, nil
+}
+
+func sub(v, w timeValue) float64 {
+	return v.t.Sub(w.t).Seconds()
+}
+
+func parseDuration(s string) (float64, error) {
+	d, err := time.ParseDuration(s)
+	return d.Seconds(), err
+}
+
+func formatDuration(duration any) (string, error) {
+	d, err := scanDuration(duration)
+	if err != nil {
+		return "", err
+	}
+	return d.String(), nil
+}
+
+// Like eval.ScanDuration, but returns an error if the duration is invalid.
+func scanDuration(duration any) (time.Duration, error) {
+	d, ok := eval.ScanDuration(duration)
+	if !ok {
+		return 0, errs.BadValue{What: "duration",
+			Valid: "number or duration string", Actual: vals.ReprPlain(duration)}
+	}
+	return d, nil
+}
@@ -0,0 +1,63 @@
+//go:build unix
+
+package unix
+
+import (
+	"os"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
+)
+
+func trap(fm *eval.Frame, name string, fn eval.Callable) error {
+	sig, err := parseSignal(name)
+	if err != nil {
+		return err
+	}
+	fm.Evaler.SetSignalHandler(sig, fn)
+	return nil
+}
+
+func untrap(fm *eval.Frame, name string) error {
+	sig, err := parseSignal(name)
+	if err != nil {
+		return err
+	}
+	fm.Evaler.SetSignalHandler(sig, nil)
+	return nil
+}
+
+func deferSignals(fm *eval.Frame, fn eval.Callable) error {
+	resume := fm.Evaler.DeferSignals()
+	defer resume(frameFiles(fm))
+	return fn.Call(fm.Fork(), eval.NoArgs, eval.NoOpts)
+}
+
+// Parses a signal name like "SIGTERM" or "TERM".
+func parseSignal(name string) (os.Signal, error) {
+	sig := unix.SignalNum(name)
+	if !strings.HasPrefix(name, "SIG") {
+		sig = unix.SignalNum("SIG" + name)
+	}
+	if sig == 0 || sig == syscall.SIGKILL || sig == syscall.SIGSTOP {
+		return nil, errs.BadValue{What: "signal",
+			Valid: "name of a signal that can be caught", Actual: name}
+	}
+	return sig, nil
+}
+
+func frameFiles(fm *eval.Frame) [3]*os.File {
+	var files [3]*os.File
+	for i := range files {
+		if p := fm.Port(i); p != nil && p.File != nil {
+			files[i] = p.File
+		} else {
+			files[i] = eval.DevNull
+		}
+	}
+	return files
+}
@@ -21,6 +21,11 @@ var Ns = eval.BuildNs().
 	AddVars(map[string]vars.Var{
 		"umask":   UmaskVariable{},
 		"rlimits": rlimitsVar{},
+	}).
+	AddGoFns(map[string]any{
+		"trap":          trap,
+		"untrap":        untrap,
+		"defer-signals": deferSignals,
 	}).Ns()
 
 var logger = logutil.GetLogger("[mods/unix] ")
@@ -0,0 +1,204 @@
+package xml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"src.elv.sh/pkg/eval/vals"
+)
+
+// A step of a path, like "item", "//item[2]" or "@id".
+type step struct {
+	// Whether the step searches all descendants instead of only children.
+	descendant bool
+	// One of "name", "*", "@name", "@*", "text()" and ".".
+	test  string
+	preds []pred
+}
+
+// A predicate of a step, either a 1-based position or an attribute test.
+type pred struct {
+	pos      int
+	attr     string
+	hasValue bool
+	value    string
+}
+
+// Parses a path. Reports whether the path is absolute, in which case the
+// first step is matched against the root element itself.
+func parsePath(path string) (bool, []step, error) {
+	if path == "" {
+		return false, nil, fmt.Errorf("empty path")
+	}
+	absolute := strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "//")
+	var steps []step
+	rest := path
+	for i := 0; rest != "" || i == 0; i++ {
+		var s step
+		if r, ok := strings.CutPrefix(rest, "//"); ok {
+			s.descendant, rest = true, r
+		} else if r, ok := strings.CutPrefix(rest, "/"); ok {
+			rest = r
+		} else if i > 0 {
+			return false, nil, fmt.Errorf("bad path %q: expect / after step", path)
+		}
+		j := strings.IndexAny(rest, "/[")
+		if j == -1 {
+			j = len(rest)
+		}
+		s.test, rest = rest[:j], rest[j:]
+		if s.test == "" {
+			return false, nil, fmt.Errorf("bad path %q: empty step", path)
+		}
+		for strings.HasPrefix(rest, "[") {
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return false, nil, fmt.Errorf("bad path %q: unterminated predicate", path)
+			}
+			p, err := parsePred(rest[1:end])
+			if err != nil {
+				return false, nil, fmt.Errorf("bad path %q: %w", path, err)
+			}
+			s.preds, rest = append(s.preds, p), rest[end+1:]
+		}
+		if isTerminalTest(s.test) && rest != "" {
+			return false, nil, fmt.Errorf("bad path %q: %s must be the last step", path, s.test)
+		}
+		steps = append(steps, s)
+	}
+	return absolute, steps, nil
+}
+
+func parsePred(s string) (pred, error) {
+	if attr, ok := strings.CutPrefix(s, "@"); ok {
+		name, value, hasValue := strings.Cut(attr, "=")
+		if hasValue {
+			unquoted, err := unquote(value)
+			if err != nil {
+				return pred{}, err
+			}
+			value = unquoted
+		}
+		return pred{attr: name, hasValue: hasValue, value: value}, nil
+	}
+	pos, err := strconv.Atoi(s)
+	if err != nil || pos < 1 {
+		return pred{}, fmt.Errorf("bad predicate [%s]", s)
+	}
+	return pred{pos: pos}, nil
+}
+
+func unquote(s string) (string, error) {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1], nil
+	}
+	return "", fmt.Errorf("bad attribute value %s: must be quoted", s)
+}
+
+func isTerminalTest(test string) bool {
+	return strings.HasPrefix(test, "@") || test == "text()"
+}
+
+// Evaluates a path, returning the matched elements, attribute values and text.
+func evalPath(root *element, path string) ([]any, error) {
+	absolute, steps, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if absolute || steps[0].descendant {
+		// Start from a virtual document node whose only child is the root.
+		root = &element{attrs: vals.EmptyMap, children: []any{root}}
+	}
+	nodes := []any{root}
+	for _, s := range steps {
+		var next []any
+		seen := make(map[*element]bool)
+		for _, node := range nodes {
+			e, ok := node.(*element)
+			if !ok {
+				continue
+			}
+			contexts := []*element{e}
+			if s.descendant {
+				contexts = descendantsOrSelf(e, nil)
+			}
+			for _, context := range contexts {
+				for _, match := range applyPreds(matchStep(context, s.test), s.preds) {
+					if e, ok := match.(*element); ok {
+						if seen[e] {
+							continue
+						}
+						seen[e] = true
+					}
+					next = append(next, match)
+				}
+			}
+		}
+		nodes = next
+	}
+	return nodes, nil
+}
+
+func descendantsOrSelf(e *element, acc []*element) []*element {
+	acc = append(acc, e)
+	for _, child := range e.children {
+		if child, ok := child.(*element); ok {
+			acc = descendantsOrSelf(child, acc)
+		}
+	}
+	return acc
+}
+
+func matchStep(e *element, test string) []any {
+	var matches []any
+	switch {
+	case test == ".":
+		matches = append(matches, e)
+	case test == "text()":
+		for _, child := range e.children {
+			if s, ok := child.(string); ok {
+				matches = append(matches, s)
+			}
+		}
+	case test == "@*":
+		for _, name := range sortedKeys(e.attrs) {
+			value, _ := e.attrs.Index(name)
+			matches = append(matches, value)
+		}
+	case strings.HasPrefix(test, "@"):
+		if value, ok := e.attrs.Index(test[1:]); ok {
+			matches = append(matches, value)
+		}
+	default:
+		for _, child := range e.children {
+			if child, ok := child.(*element); ok && (test == "*" || child.tag == test) {
+				matches = append(matches, child)
+			}
+		}
+	}
+	return matches
+}
+
+func applyPreds(matches []any, preds []pred) []any {
+	for _, p := range preds {
+		var filtered []any
+		for i, match := range matches {
+			if p.pos > 0 {
+				if i+1 == p.pos {
+					filtered = append(filtered, match)
+				}
+				continue
+			}
+			e, ok := match.(*element)
+			if !ok {
+				continue
+			}
+			if value, ok := e.attrs.Index(p.attr); ok && (!p.hasValue || value == p.value) {
+				filtered = append(filtered, match)
+			}
+		}
+		matches = filtered
+	}
+	return matches
+}
@@ -0,0 +1,275 @@
+// Package xml implements the xml: module for parsing, querying and serializing
+// XML documents.
+package xml
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/persistent/hashmap"
+)
+
+// Ns is the namespace for the xml: module.
+var Ns = eval.BuildNsNamed("xml").
+	AddGoFns(map[string]any{
+		"parse":     parse,
+		"query":     query,
+		"to-string": toString,
+	}).Ns()
+
+var (
+	errNoRootElement        = errors.New("no root element")
+	errMultipleRootElements = errors.New("multiple root elements")
+)
+
+// An element, converted from or to a map with keys tag, attrs and children.
+// Each child is either an *element or a string.
+type element struct {
+	tag      string
+	attrs    hashmap.Map
+	children []any
+	// The map this element was converted from or to.
+	value hashmap.Map
+}
+
+func newElement(tag string, attrs hashmap.Map, children []any) *element {
+	var list vals.List = vals.EmptyList
+	for _, child := range children {
+		if e, ok := child.(*element); ok {
+			list = list.Conj(e.value)
+		} else {
+			list = list.Conj(child)
+		}
+	}
+	return &element{tag, attrs, children,
+		vals.MakeMap("tag", tag, "attrs", attrs, "children", list)}
+}
+
+// Converts a value to an element, checking that it has the expected shape.
+func toElement(v any) (*element, error) {
+	m, ok := v.(hashmap.Map)
+	if !ok {
+		return nil, badElement(v, "map")
+	}
+	tagValue, _ := m.Index("tag")
+	tag, ok := tagValue.(string)
+	if !ok || tag == "" {
+		return nil, badElement(v, "map with a non-empty string tag")
+	}
+	attrs := vals.EmptyMap
+	if attrsValue, ok := m.Index("attrs"); ok {
+		attrs, ok = attrsValue.(hashmap.Map)
+		if !ok {
+			return nil, badElement(v, "map whose attrs is a map")
+		}
+	}
+	var children []any
+	if childrenValue, ok := m.Index("children"); ok {
+		var errChild error
+		errIterate := vals.Iterate(childrenValue, func(child any) bool {
+			if s, ok := child.(string); ok {
+				children = append(children, s)
+				return true
+			}
+			var e *element
+			e, errChild = toElement(child)
+			children = append(children, e)
+			return errChild == nil
+		})
+		if errIterate != nil {
+			return nil, badElement(v, "map whose children is a list")
+		}
+		if errChild != nil {
+			return nil, errChild
+		}
+	}
+	return &element{tag, attrs, children, m}, nil
+}
+
+func badElement(v any, valid string) error {
+	return errs.BadValue{What: "XML element", Valid: valid, Actual: vals.ReprPlain(v)}
+}
+
+type parseOpts struct{ KeepSpace bool }
+
+func (*parseOpts) SetDefaultOptions() {}
+
+func parse(fm *eval.Frame, opts parseOpts) error {
+	root, err := parseElement(fm.InputFile(), opts.KeepSpace)
+	if err != nil {
+		return err
+	}
+	return fm.ValueOutput().Put(root.value)
+}
+
+func parseElement(r io.Reader, keepSpace bool) (*element, error) {
+	type frame struct {
+		tag      string
+		attrs    hashmap.Map
+		children []any
+		text     strings.Builder
+	}
+	// flushText moves the pending text of a frame into its children.
+	flushText := func(f *frame) {
+		text := f.text.String()
+		f.text.Reset()
+		if text == "" || !keepSpace && strings.TrimSpace(text) == "" {
+			return
+		}
+		f.children = append(f.children, text)
+	}
+
+	dec := xml.NewDecoder(r)
+	var stack []*frame
+	var root *element
+	for {
+		token, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			if root != nil && len(stack) == 0 {
+				return nil, errMultipleRootElements
+			}
+			attrs := vals.EmptyMap
+			for _, attr := range token.Attr {
+				attrs = attrs.Assoc(qualifiedName(attr.Name), attr.Value)
+			}
+			if len(stack) > 0 {
+				flushText(stack[len(stack)-1])
+			}
+			stack = append(stack, &frame{tag: qualifiedName(token.Name), attrs: attrs})
+		case xml.EndElement:
+			tag := qualifiedName(token.Name)
+			if len(stack) == 0 || stack[len(stack)-1].tag != tag {
+				line, _ := dec.InputPos()
+				return nil, fmt.Errorf("line %d: unexpected end element </%s>", line, tag)
+			}
+			f := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			flushText(f)
+			e := newElement(f.tag, f.attrs, f.children)
+			if len(stack) == 0 {
+				root = e
+			} else {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, e)
+			}
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(token)
+			}
+		}
+	}
+	if len(stack) > 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if root == nil {
+		return nil, errNoRootElement
+	}
+	return root, nil
+}
+
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+func query(fm *eval.Frame, v any, path string) error {
+	e, err := toElement(v)
+	if err != nil {
+		return err
+	}
+	results, err := evalPath(e, path)
+	if err != nil {
+		return err
+	}
+	out := fm.ValueOutput()
+	for _, result := range results {
+		if e, ok := result.(*element); ok {
+			result = e.value
+		}
+		if err := out.Put(result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type toStringOpts struct{ Indent string }
+
+func (*toStringOpts) SetDefaultOptions() {}
+
+func toString(opts toStringOpts, v any) (string, error) {
+	e, err := toElement(v)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	writeElement(&sb, e, opts.Indent, 0)
+	return sb.String(), nil
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;",
+		"\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+func writeElement(sb *strings.Builder, e *element, indent string, depth int) {
+	sb.WriteString("<" + e.tag)
+	for _, name := range sortedKeys(e.attrs) {
+		value, _ := e.attrs.Index(name)
+		sb.WriteString(" " + name + `="` + attrEscaper.Replace(vals.ToString(value)) + `"`)
+	}
+	if len(e.children) == 0 {
+		sb.WriteString("/>")
+		return
+	}
+	sb.WriteString(">")
+	// Whitespace is significant in elements that contain text, so they and
+	// their descendants are not indented.
+	for _, child := range e.children {
+		if _, ok := child.(string); ok {
+			indent = ""
+		}
+	}
+	pretty := indent != ""
+	for _, child := range e.children {
+		if pretty {
+			sb.WriteString("\n" + strings.Repeat(indent, depth+1))
+		}
+		switch child := child.(type) {
+		case *element:
+			writeElement(sb, child, indent, depth+1)
+		case string:
+			sb.WriteString(textEscaper.Replace(child))
+		}
+	}
+	if pretty {
+		sb.WriteString("\n" + strings.Repeat(indent, depth))
+	}
+	sb.WriteString("</" + e.tag + ">")
+}
+
+func sortedKeys(m hashmap.Map) []string {
+	var keys []string
+	for it := m.Iterator(); it.HasElem(); it.Next() {
+		k, _ := it.Elem()
+		keys = append(keys, vals.ToString(k))
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,86 @@
+// Package parsedump converts parse trees to a form that can be encoded as
+// JSON, for use by external tools.
+package parsedump
+
+import (
+	"fmt"
+	"reflect"
+
+	"src.elv.sh/pkg/parse"
+)
+
+// Node is a node in the parse tree.
+type Node struct {
+	// The Go type of the node, like "Form" or "Primary".
+	Type string `json:"type"`
+	// The byte range of the node in the source.
+	From int `json:"from"`
+	To   int `json:"to"`
+	// The field of the parent node that the node is stored in, like "Head" or
+	// "Args". Empty for the root node and nodes that are only part of the
+	// parse tree, like whitespace and brackets.
+	Field string `json:"field,omitempty"`
+	// Properties of the node other than its children, like the "Type" and
+	// "Value" of a Primary.
+	Props map[string]any `json:"props,omitempty"`
+	// The source text of the node. Only set when there are no children.
+	Text     string  `json:"text,omitempty"`
+	Children []*Node `json:"children,omitempty"`
+}
+
+// Dump converts a parse tree. The children of each node cover all of its
+// source text, including whitespace and comments.
+func Dump(n parse.Node) *Node {
+	return dump(n, "")
+}
+
+var nodeType = reflect.TypeOf((*parse.Node)(nil)).Elem()
+
+func dump(n parse.Node, field string) *Node {
+	v := reflect.ValueOf(n).Elem()
+	t := v.Type()
+	r := n.Range()
+	d := &Node{Type: t.Name(), From: r.From, To: r.To, Field: field}
+
+	fields := make(map[parse.Node]string)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			// The embedded node struct.
+			continue
+		}
+		fv := v.Field(i)
+		switch {
+		case f.Type.Implements(nodeType):
+			if !fv.IsNil() {
+				fields[fv.Interface().(parse.Node)] = f.Name
+			}
+		case f.Type.Kind() == reflect.Slice && f.Type.Elem().Implements(nodeType):
+			for j := 0; j < fv.Len(); j++ {
+				fields[fv.Index(j).Interface().(parse.Node)] = f.Name
+			}
+		default:
+			if d.Props == nil {
+				d.Props = make(map[string]any)
+			}
+			d.Props[f.Name] = prop(fv.Interface())
+		}
+	}
+
+	children := parse.Children(n)
+	if len(children) == 0 {
+		d.Text = parse.SourceText(n)
+	}
+	for _, ch := range children {
+		d.Children = append(d.Children, dump(ch, fields[ch]))
+	}
+	return d
+}
+
+// Converts enum properties like ExprCtx to their names.
+func prop(v any) any {
+	if s, ok := v.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return v
+}
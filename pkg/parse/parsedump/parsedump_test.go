@@ -0,0 +1,58 @@
+package parsedump_test
+
+import (
+	"strings"
+	"testing"
+
+	"src.elv.sh/pkg/parse"
+	. "src.elv.sh/pkg/parse/parsedump"
+)
+
+var dumpTests = []string{
+	"echo foo",
+	"a | b &\nc > out; d 2>&1 # comment",
+	"var m = [&k=[a b] &l={|x &o=1| put $x}]",
+	"echo {a,b}[0] (put x) ?(fail y) ~/*.go",
+}
+
+func TestDump(t *testing.T) {
+	for _, code := range dumpTests {
+		tree, err := parse.Parse(parse.Source{Name: "[test]", Code: code}, parse.Config{})
+		if err != nil {
+			t.Fatalf("parse %q: %v", code, err)
+		}
+		root := Dump(tree.Root)
+		var sb strings.Builder
+		checkNode(t, code, root, &sb)
+		if sb.String() != code {
+			t.Errorf("text of leaves of %q is %q", code, sb.String())
+		}
+	}
+}
+
+// Checks that the children of a node cover its range, and that all the
+// children other than Seps have fields. Writes the text of leaves to sb.
+func checkNode(t *testing.T, code string, n *Node, sb *strings.Builder) {
+	t.Helper()
+	if len(n.Children) == 0 {
+		if n.Text != code[n.From:n.To] {
+			t.Errorf("%s %d-%d has text %q, want %q", n.Type, n.From, n.To, n.Text, code[n.From:n.To])
+		}
+		sb.WriteString(n.Text)
+		return
+	}
+	pos := n.From
+	for _, ch := range n.Children {
+		if ch.From != pos {
+			t.Errorf("child %s of %s starts at %d, want %d", ch.Type, n.Type, ch.From, pos)
+		}
+		if ch.Type != "Sep" && ch.Field == "" {
+			t.Errorf("child %s of %s at %d has no field", ch.Type, n.Type, ch.From)
+		}
+		checkNode(t, code, ch, sb)
+		pos = ch.To
+	}
+	if pos != n.To {
+		t.Errorf("children of %s end at %d, want %d", n.Type, pos, n.To)
+	}
+}
@@ -0,0 +1,84 @@
+package parsedump
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/prog"
+)
+
+// Program is the parse dump subprogram. It parses the Elvish file in the
+// argument, or stdin if there is no argument, and writes the parse tree to
+// stdout as JSON.
+//
+// If the code has parse errors, the partial parse tree is still written, along
+// with the errors, and it exits with 1. It exits with 2 if the file can't be
+// read.
+type Program struct {
+	parseDump bool
+}
+
+func (p *Program) RegisterFlags(fs *prog.FlagSet) {
+	fs.BoolVar(&p.parseDump, "parse-dump", false,
+		"Write the parse tree of the Elvish file in the argument, or stdin, as JSON and quit")
+}
+
+func (p *Program) Run(fds [3]*os.File, args []string) error {
+	if !p.parseDump {
+		return prog.NextProgram()
+	}
+	var src parse.Source
+	switch len(args) {
+	case 0:
+		code, err := io.ReadAll(fds[0])
+		if err != nil {
+			return err
+		}
+		src = parse.Source{Name: "[stdin]", Code: string(code)}
+	case 1:
+		code, err := os.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+		src = parse.Source{Name: args[0], Code: string(code), IsFile: true}
+	default:
+		return prog.BadUsage("-parse-dump accepts at most one argument")
+	}
+	if !utf8.ValidString(src.Code) {
+		return fmt.Errorf("%s: source is not UTF-8", src.Name)
+	}
+
+	tree, err := parse.Parse(src, parse.Config{})
+	out := output{Root: Dump(tree.Root), Errors: []errorInJSON{}}
+	for _, e := range parse.UnpackErrors(err) {
+		out.Errors = append(out.Errors,
+			errorInJSON{e.Context.Name, e.Context.From, e.Context.To, e.Message})
+	}
+	data, errMarshal := json.Marshal(out)
+	if errMarshal != nil {
+		return errMarshal
+	}
+	fmt.Fprintf(fds[1], "%s\n", data)
+	if err != nil {
+		return prog.Exit(1)
+	}
+	return nil
+}
+
+type output struct {
+	Root   *Node         `json:"root"`
+	Errors []errorInJSON `json:"errors"`
+}
+
+// The JSON format of a parse error, which is the same as the one used by
+// -compileonly -json.
+type errorInJSON struct {
+	FileName string `json:"fileName"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+	Message  string `json:"message"`
+}
@@ -6,24 +6,35 @@ import "flag"
 // by multiple subprograms on demand.
 type FlagSet struct {
 	*flag.FlagSet
+	argv0       string
 	daemonPaths *DaemonPaths
 	json        *bool
 }
 
-// DaemonPaths stores the -db and -sock flags.
+// Argv0 returns the name Elvish is invoked with, the first element of the
+// arguments passed to [Run]. Login shells are invoked with a name starting
+// with "-".
+func (fs *FlagSet) Argv0() string { return fs.argv0 }
+
+// DaemonPaths stores the -db, -sock, -data-dir and -db-encrypt flags.
 type DaemonPaths struct {
-	DB, Sock string
+	DB, Sock, DataDir string
+	Encrypt           bool
 }
 
-// DaemonPaths returns a pointer to a struct storing the value of -db and
-// -sock flags, registering them on demand.
+// DaemonPaths returns a pointer to a struct storing the value of -db, -sock,
+// -data-dir and -db-encrypt flags, registering them on demand.
 func (fs *FlagSet) DaemonPaths() *DaemonPaths {
 	if fs.daemonPaths == nil {
 		var dp DaemonPaths
 		fs.StringVar(&dp.DB, "db", "",
-			"[internal flag] Path to the database file")
+			"Path to the database file, overriding $ELVISH_DB and -data-dir")
 		fs.StringVar(&dp.Sock, "sock", "",
-			"[internal flag] Path to the daemon's Unix socket")
+			"Path to the daemon's Unix socket, overriding $ELVISH_SOCK")
+		fs.StringVar(&dp.DataDir, "data-dir", "",
+			"Directory containing the database file, overriding $ELVISH_DATA_DIR")
+		fs.BoolVar(&dp.Encrypt, "db-encrypt", false,
+			"Encrypt the command history in the database with the passphrase in $ELVISH_DB_PASSPHRASE")
 		fs.daemonPaths = &dp
 	}
 	return fs.daemonPaths
@@ -34,7 +45,7 @@ func (fs *FlagSet) DaemonPaths() *DaemonPaths {
 func (fs *FlagSet) JSON() *bool {
 	if fs.json == nil {
 		fs.json = fs.Bool("json", false,
-			"Show the output from -buildinfo, -compileonly or -version in JSON")
+			"Show the output from -buildinfo, -compileonly, -export-history, -lint or -version in JSON")
 	}
 	return fs.json
 }
@@ -124,7 +124,7 @@ func Run(fds [3]*os.File, args []string, p Program) int {
 	fs.IntVar(&DeprecationLevel, "deprecation-level", DeprecationLevel,
 		"Show warnings for all features deprecated as of version 0.X")
 
-	p.RegisterFlags(&FlagSet{FlagSet: fs})
+	p.RegisterFlags(&FlagSet{FlagSet: fs, argv0: args[0]})
 
 	err := fs.Parse(args[1:])
 	if err != nil {
@@ -138,8 +138,10 @@ import (
 	"log"
 	"net"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
 // Precompute the reflect type for error.
@@ -151,6 +153,8 @@ type methodType struct {
 	ArgType    reflect.Type
 	ReplyType  reflect.Type
 	numCalls   uint
+	totalTime  time.Duration
+	maxTime    time.Duration
 }
 
 type service struct {
@@ -365,6 +369,50 @@ func (m *methodType) NumCalls() (n uint) {
 	return n
 }
 
+// MethodStats contains statistics of the calls to a method.
+type MethodStats struct {
+	ServiceMethod string // format: "Service.Method"
+	NumCalls      uint
+	// Total and maximum time spent in the method, not including the time
+	// spent in calls that are still in progress.
+	TotalTime, MaxTime time.Duration
+}
+
+// Methods returns the names of all the registered methods, in the format
+// "Service.Method", sorted.
+func (server *Server) Methods() []string {
+	var names []string
+	server.serviceMap.Range(func(_, svci any) bool {
+		svc := svci.(*service)
+		for name := range svc.method {
+			names = append(names, svc.name+"."+name)
+		}
+		return true
+	})
+	sort.Strings(names)
+	return names
+}
+
+// Stats returns statistics of the methods that have been called, sorted by
+// name.
+func (server *Server) Stats() []MethodStats {
+	var stats []MethodStats
+	server.serviceMap.Range(func(_, svci any) bool {
+		svc := svci.(*service)
+		for name, mtype := range svc.method {
+			mtype.Lock()
+			if mtype.numCalls > 0 {
+				stats = append(stats, MethodStats{
+					svc.name + "." + name, mtype.numCalls, mtype.totalTime, mtype.maxTime})
+			}
+			mtype.Unlock()
+		}
+		return true
+	})
+	sort.Slice(stats, func(i, j int) bool { return stats[i].ServiceMethod < stats[j].ServiceMethod })
+	return stats
+}
+
 func (s *service) call(server *Server, sending *sync.Mutex, wg *sync.WaitGroup, mtype *methodType, req *Request, argv, replyv reflect.Value, codec ServerCodec) {
 	if wg != nil {
 		defer wg.Done()
@@ -374,7 +422,13 @@ func (s *service) call(server *Server, sending *sync.Mutex, wg *sync.WaitGroup,
 	mtype.Unlock()
 	function := mtype.method.Func
 	// Invoke the method, providing a new value for the reply.
+	start := time.Now()
 	returnValues := function.Call([]reflect.Value{s.rcvr, argv, replyv})
+	d := time.Since(start)
+	mtype.Lock()
+	mtype.totalTime += d
+	mtype.maxTime = max(mtype.maxTime, d)
+	mtype.Unlock()
 	// The return value for the method is an error.
 	errInter := returnValues[0].Interface()
 	errmsg := ""
@@ -0,0 +1,137 @@
+//go:build unix
+
+package shell
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"src.elv.sh/pkg/daemon/daemondefs"
+	"src.elv.sh/pkg/sys"
+	"src.elv.sh/pkg/sys/eunix"
+)
+
+// Pressing Ctrl-] detaches from the session.
+const detachKey = 0x1d
+
+var errSessionExited = errors.New("session exited")
+
+// Attaches the terminal to a session hosted by the daemon, creating the
+// session first if it is not running. Returns when the user detaches or the
+// session exits.
+func attach(fds [3]*os.File, cl daemondefs.Client, name string) error {
+	fdIn := int(fds[0].Fd())
+	term, err := eunix.TermiosForFd(fdIn)
+	if err != nil {
+		return fmt.Errorf("-attach requires a terminal: %w", err)
+	}
+
+	sessions, err := cl.Sessions()
+	if err != nil {
+		return err
+	}
+	running := false
+	for _, s := range sessions {
+		if s.Name == name && !s.Exited {
+			running = true
+		}
+	}
+	rows, cols := sys.WinSize(fds[1])
+	if rows <= 0 || cols <= 0 {
+		rows, cols = 24, 80
+	}
+	if running {
+		// Changing the size also makes the editor in the session redraw.
+		cl.ResizeSession(name, rows, cols)
+	} else {
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		err = cl.NewSession(name, daemondefs.SessionConfig{
+			Dir: wd, Env: os.Environ(), Rows: rows, Cols: cols})
+		if err != nil {
+			return err
+		}
+	}
+
+	savedTerm := term.Copy()
+	term.SetICanon(false)
+	term.SetEcho(false)
+	term.SetISig(false)
+	term.SetIExten(false)
+	term.SetICRNL(false)
+	term.SetIXON(false)
+	term.SetVMin(1)
+	term.SetVTime(0)
+	if err := term.ApplyToFd(fdIn); err != nil {
+		return err
+	}
+	defer savedTerm.ApplyToFd(fdIn)
+
+	done := make(chan error, 2)
+	go func() {
+		offset := 0
+		for {
+			data, next, exited, err := cl.ReadSession(name, offset)
+			if err != nil {
+				done <- err
+				return
+			}
+			fds[1].Write(data)
+			offset = next
+			if exited {
+				done <- errSessionExited
+				return
+			}
+		}
+	}()
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := fds[0].Read(buf)
+			if err != nil {
+				done <- err
+				return
+			}
+			data := buf[:n]
+			i := bytes.IndexByte(data, detachKey)
+			if i >= 0 {
+				data = data[:i]
+			}
+			if err := cl.WriteSession(name, data); err != nil {
+				done <- err
+				return
+			}
+			if i >= 0 {
+				done <- nil
+				return
+			}
+		}
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, sys.SIGWINCH)
+	defer signal.Stop(sigCh)
+	for {
+		select {
+		case <-sigCh:
+			if rows, cols := sys.WinSize(fds[1]); rows > 0 && cols > 0 {
+				cl.ResizeSession(name, rows, cols)
+			}
+		case err := <-done:
+			savedTerm.ApplyToFd(fdIn)
+			switch err {
+			case nil:
+				fmt.Fprintf(fds[2], "\nDetached from session %s\n", name)
+			case errSessionExited:
+				fmt.Fprintf(fds[2], "\nSession %s exited\n", name)
+				return nil
+			}
+			return err
+		}
+	}
+}
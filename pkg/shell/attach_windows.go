@@ -0,0 +1,12 @@
+package shell
+
+import (
+	"errors"
+	"os"
+
+	"src.elv.sh/pkg/daemon/daemondefs"
+)
+
+func attach(fds [3]*os.File, cl daemondefs.Client, name string) error {
+	return errors.New("-attach is not supported on Windows")
+}
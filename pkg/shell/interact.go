@@ -2,11 +2,13 @@ package shell
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -15,8 +17,10 @@ import (
 	"src.elv.sh/pkg/daemon/daemondefs"
 	"src.elv.sh/pkg/diag"
 	"src.elv.sh/pkg/edit"
+	"src.elv.sh/pkg/env"
 	"src.elv.sh/pkg/eval"
 	"src.elv.sh/pkg/mods/daemon"
+	"src.elv.sh/pkg/mods/ssh"
 	"src.elv.sh/pkg/mods/store"
 	"src.elv.sh/pkg/parse"
 	"src.elv.sh/pkg/strutil"
@@ -31,6 +35,10 @@ var interactiveRescueShell bool = true
 // Configuration for the interactive mode.
 type interactCfg struct {
 	RC string
+	// Sourced before RC and when exiting respectively, in login sessions.
+	LoginRCs, LogoutRCs []string
+	// If not empty, the session is recorded into this file.
+	Record string
 
 	ActivateDaemon daemondefs.ActivateFunc
 	SpawnConfig    *daemondefs.SpawnConfig
@@ -43,31 +51,87 @@ type editor interface {
 	RunAfterCommandHooks(src parse.Source, duration float64, err error)
 }
 
+// Connects to the daemon if activate and spawnCfg are both non-nil, and
+// installs the store: and daemon: modules. Returns nil if not connected.
+//
+// If async is true, it doesn't wait for the daemon to come up; requests made
+// with the returned client wait for it instead, and errors are written to
+// stderr when connecting finishes.
+func activateDaemon(ev *eval.Evaler, stderr io.Writer, activate daemondefs.ActivateFunc, spawnCfg *daemondefs.SpawnConfig, async bool) daemondefs.Client {
+	if activate == nil || spawnCfg == nil {
+		return nil
+	}
+	if async {
+		asyncCfg := *spawnCfg
+		asyncCfg.Activated = func(err error) { showActivateError(stderr, err) }
+		spawnCfg = &asyncCfg
+	}
+	cl, err := activate(stderr, spawnCfg)
+	showActivateError(stderr, err)
+	if cl == nil {
+		return nil
+	}
+	// Even if error is not nil, we install daemon-related functionalities
+	// anyway. Daemon may eventually come online and become functional.
+	ev.PreExitHooks = append(ev.PreExitHooks, func() { cl.Close() })
+	ev.AddModule("store", store.Ns(cl))
+	ev.AddModule("daemon", daemon.Ns(cl))
+	ev.AddModule("ssh", ssh.Ns(cl))
+	return cl
+}
+
+func showActivateError(w io.Writer, err error) {
+	if err == nil {
+		return
+	}
+	// Written in one call, so that it is shown as one notification by
+	// daemonMessages.
+	var sb strings.Builder
+	fmt.Fprintln(&sb, "Cannot connect to daemon:", err)
+	var timeoutErr *daemondefs.SpawnTimeoutError
+	if errors.As(err, &timeoutErr) {
+		fmt.Fprintf(&sb, "If the daemon is slow to start, set $%s to wait longer than %v.\n",
+			env.ELVISH_DAEMON_SPAWN_TIMEOUT, timeoutErr.Timeout)
+	}
+	fmt.Fprintln(&sb, "Daemon-related functions will likely not work.")
+	io.WriteString(w, sb.String())
+}
+
+// Receives messages about connecting to the daemon in the background. They
+// are written to stderr until the editor is started, and shown as
+// notifications afterwards, so that they don't mess up the editor's UI.
+type daemonMessages struct {
+	mu     sync.Mutex
+	stderr io.Writer
+	notify func(ui.Text)
+}
+
+func (m *daemonMessages) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.notify == nil {
+		return m.stderr.Write(p)
+	}
+	m.notify(ui.T(strings.TrimSuffix(string(p), "\n")))
+	return len(p), nil
+}
+
+func (m *daemonMessages) setNotify(notify func(ui.Text)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.notify = notify
+}
+
 // Runs an interactive shell session.
 func interact(ev *eval.Evaler, fds [3]*os.File, cfg *interactCfg) {
 	if interactiveRescueShell {
-		defer handlePanic()
+		defer handlePanic(ev)
 	}
+	// Don't leave background jobs and stopped commands behind.
+	ev.PreExitHooks = append(ev.PreExitHooks, ev.HangUpChildren)
 
-	var daemonClient daemondefs.Client
-	if cfg.ActivateDaemon != nil && cfg.SpawnConfig != nil {
-		// TODO(xiaq): Connect to daemon and install daemon module
-		// asynchronously.
-		cl, err := cfg.ActivateDaemon(fds[2], cfg.SpawnConfig)
-		if err != nil {
-			fmt.Fprintln(fds[2], "Cannot connect to daemon:", err)
-			fmt.Fprintln(fds[2], "Daemon-related functions will likely not work.")
-		}
-		if cl != nil {
-			// Even if error is not nil, we install daemon-related
-			// functionalities anyway. Daemon may eventually come online and
-			// become functional.
-			daemonClient = cl
-			ev.PreExitHooks = append(ev.PreExitHooks, func() { cl.Close() })
-			ev.AddModule("store", store.Ns(cl))
-			ev.AddModule("daemon", daemon.Ns(cl))
-		}
-	}
+	daemonMsgs := &daemonMessages{stderr: fds[2]}
+	daemonClient := activateDaemon(ev, daemonMsgs, cfg.ActivateDaemon, cfg.SpawnConfig, true)
 
 	// Build Editor.
 	var ed editor
@@ -77,11 +141,29 @@ func interact(ev *eval.Evaler, fds [3]*os.File, cfg *interactCfg) {
 		newed := edit.NewEditor(cli.NewTTY(fds[0], fds[2]), ev, daemonClient)
 		ev.ExtendBuiltin(eval.BuildNs().AddNs("edit", newed))
 		ev.BgJobNotify = func(s string) { newed.Notify(ui.T(s)) }
+		daemonMsgs.setNotify(newed.Notify)
 		ed = newed
 	} else {
 		ed = newMinEditor(fds[0], fds[2])
 	}
 
+	if len(cfg.LogoutRCs) > 0 {
+		// Run before the other pre-exit hooks, which may close the connection
+		// to the daemon.
+		ev.PreExitHooks = append([]func(){func() {
+			for _, path := range cfg.LogoutRCs {
+				if err := sourceRC(fds, ev, ed, path); err != nil {
+					diag.ShowError(fds[2], err)
+				}
+			}
+		}}, ev.PreExitHooks...)
+	}
+	for _, path := range cfg.LoginRCs {
+		if err := sourceRC(fds, ev, ed, path); err != nil {
+			diag.ShowError(fds[2], err)
+		}
+	}
+
 	// Source rc.elv.
 	if cfg.RC != "" {
 		err := sourceRC(fds, ev, ed, cfg.RC)
@@ -90,6 +172,28 @@ func interact(ev *eval.Evaler, fds [3]*os.File, cfg *interactCfg) {
 		}
 	}
 
+	if ed, ok := ed.(*edit.Editor); ok {
+		if runDir, err := secureRunDir(); err == nil {
+			setupBufferRecovery(ed, runDir)
+		}
+		// Check for updates in the background after sourcing rc.elv, which
+		// may enable the check.
+		if daemonClient != nil {
+			go ed.CheckUpdate(daemonClient.LatestVersion)
+		}
+	}
+
+	var rec *recorder
+	if cfg.Record != "" {
+		var err error
+		rec, err = newRecorder(cfg.Record, fds[1])
+		if err != nil {
+			fmt.Fprintln(fds[2], "Cannot record session:", err)
+		} else {
+			defer rec.Close()
+		}
+	}
+
 	cooldown := time.Second
 	cmdNum := 0
 
@@ -124,8 +228,12 @@ func interact(ev *eval.Evaler, fds [3]*os.File, cfg *interactCfg) {
 		if strings.TrimSpace(line) == "" {
 			continue
 		}
-		err = evalInTTY(fds, ev, ed,
-			parse.Source{Name: fmt.Sprintf("[tty %v]", cmdNum), Code: line})
+		src := parse.Source{Name: fmt.Sprintf("[tty %v]", cmdNum), Code: line}
+		if rec != nil {
+			rec.eval(fds, ev, ed, src)
+			continue
+		}
+		err = evalInTTY(fds, ev, ed, src)
 		if err != nil {
 			diag.ShowError(fds[2], err)
 		}
@@ -133,7 +241,7 @@ func interact(ev *eval.Evaler, fds [3]*os.File, cfg *interactCfg) {
 }
 
 // Interactive mode panic handler.
-func handlePanic() {
+func handlePanic(ev *eval.Evaler) {
 	r := recover()
 	if r != nil {
 		println()
@@ -141,6 +249,8 @@ func handlePanic() {
 		println()
 		fmt.Println(r)
 		println("\nExecing recovery shell /bin/sh")
+		// The deferred call to PreExit in Run doesn't run when execing.
+		ev.PreExit()
 		syscall.Exec("/bin/sh", []string{"/bin/sh"}, os.Environ())
 	}
 }
@@ -148,7 +258,7 @@ func handlePanic() {
 func sourceRC(fds [3]*os.File, ev *eval.Evaler, ed editor, rcPath string) error {
 	absPath, err := filepath.Abs(rcPath)
 	if err != nil {
-		return fmt.Errorf("cannot get full path of rc.elv: %v", err)
+		return fmt.Errorf("cannot get full path of %s: %v", rcPath, err)
 	}
 	code, err := readFileUTF8(absPath)
 	if err != nil {
@@ -0,0 +1,81 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"src.elv.sh/pkg/fsutil"
+)
+
+// Files in the legacy ~/.elvish directory, which is no longer used since
+// 0.21.0, and functions returning their new paths.
+var legacyFiles = []struct {
+	name    string
+	newPath func() (string, error)
+}{
+	{"rc.elv", rcPath},
+	{"db", dbPath},
+	{"lib", configLibPath},
+}
+
+type migration struct{ from, to string }
+
+// Returns the files in ~/.elvish that can be moved to their new paths, which
+// must not exist yet.
+func legacyMigrations() []migration {
+	home, err := fsutil.GetHome("")
+	if err != nil {
+		return nil
+	}
+	var migrations []migration
+	for _, file := range legacyFiles {
+		from := filepath.Join(home, ".elvish", file.name)
+		if _, err := os.Lstat(from); err != nil {
+			continue
+		}
+		to, err := file.newPath()
+		if err != nil {
+			continue
+		}
+		if _, err := os.Lstat(to); !errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		migrations = append(migrations, migration{from, to})
+	}
+	return migrations
+}
+
+// Writes a warning if there are files in ~/.elvish that can be migrated.
+func warnLegacy(w io.Writer) {
+	migrations := legacyMigrations()
+	if len(migrations) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "Warning: the following files in ~/.elvish are no longer used:")
+	for _, m := range migrations {
+		fmt.Fprintf(w, "  %s (new path: %s)\n", m.from, m.to)
+	}
+	fmt.Fprintln(w, "Run elvish -migrate-legacy to move them to the new paths.")
+}
+
+// Moves files in ~/.elvish to their new paths.
+func migrateLegacy(w io.Writer) error {
+	migrations := legacyMigrations()
+	if len(migrations) == 0 {
+		fmt.Fprintln(w, "Nothing to migrate")
+		return nil
+	}
+	for _, m := range migrations {
+		if err := os.MkdirAll(filepath.Dir(m.to), 0700); err != nil {
+			return err
+		}
+		if err := os.Rename(m.from, m.to); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Moved %s to %s\n", m.from, m.to)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"src.elv.sh/pkg/env"
+	"src.elv.sh/pkg/must"
+	"src.elv.sh/pkg/prog"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestLogin_Argv0StartingWithDash(t *testing.T) {
+	testutil.InTempHome(t)
+	testutil.Setenv(t, env.XDG_CONFIG_HOME, must.OK1(filepath.Abs("cfg")))
+	testutil.Set(t, &systemConfigDirs, nil)
+	must.MkdirAll("cfg/elvish")
+	must.WriteFile("cfg/elvish/rc-login.elv", "echo login")
+
+	stdin := must.OK1(os.Open(os.DevNull))
+	defer stdin.Close()
+	// The prompt is written to stderr.
+	stderr := must.OK1(os.OpenFile(os.DevNull, os.O_WRONLY, 0))
+	defer stderr.Close()
+	r, w := must.Pipe()
+	exit := prog.Run([3]*os.File{stdin, w, stderr}, []string{"-elvish"}, &Program{})
+	w.Close()
+	output := string(must.ReadAllAndClose(r))
+
+	if exit != 0 {
+		t.Errorf("got exit %v, want 0", exit)
+	}
+	if output != "login\n" {
+		t.Errorf("got output %q, want %q", output, "login\n")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"src.elv.sh/pkg/daemon/daemondefs"
 	"src.elv.sh/pkg/env"
@@ -20,16 +21,81 @@ func rcPath() (string, error) {
 	}
 }
 
-func libPaths() ([]string, error) {
+// Returns the paths of a file in the system-wide and the user's config
+// directories, like rc-login.elv, in that order.
+func loginRCPaths(name string) []string {
+	var paths []string
+	for _, dir := range systemConfigDirs {
+		paths = append(paths, filepath.Join(dir, name))
+	}
+	if configHome := os.Getenv(env.XDG_CONFIG_HOME); configHome != "" {
+		paths = append(paths, filepath.Join(configHome, "elvish", name))
+	} else if configHome, err := defaultConfigHome(); err == nil {
+		paths = append(paths, filepath.Join(configHome, "elvish", name))
+	}
+	return paths
+}
+
+func autoloadPath() (string, error) {
+	if configHome := os.Getenv(env.XDG_CONFIG_HOME); configHome != "" {
+		return filepath.Join(configHome, "elvish", "autoload"), nil
+	} else if configHome, err := defaultConfigHome(); err == nil {
+		return filepath.Join(configHome, "elvish", "autoload"), nil
+	} else {
+		return "", fmt.Errorf("find autoload directory: %w", err)
+	}
+}
+
+// Splits a list of paths separated by [filepath.ListSeparator], dropping
+// empty elements.
+func splitList(s string) []string {
+	var paths []string
+	for _, path := range filepath.SplitList(s) {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// Returns the extra module search directories from the -lib-dirs flag if it is
+// non-empty, or from $ELVISH_LIB_DIRS otherwise. Relative paths are converted
+// to absolute paths, so that they are not affected by later changes to the
+// working directory.
+func extraLibPaths(flag string) []string {
+	dirs := flag
+	if dirs == "" {
+		dirs = os.Getenv(env.ELVISH_LIB_DIRS)
+	}
 	var paths []string
+	for _, dir := range filepath.SplitList(dirs) {
+		if dir == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		paths = append(paths, dir)
+	}
+	return paths
+}
 
+func configLibPath() (string, error) {
 	if configHome := os.Getenv(env.XDG_CONFIG_HOME); configHome != "" {
-		paths = append(paths, filepath.Join(configHome, "elvish", "lib"))
+		return filepath.Join(configHome, "elvish", "lib"), nil
 	} else if configHome, err := defaultConfigHome(); err == nil {
-		paths = append(paths, filepath.Join(configHome, "elvish", "lib"))
+		return filepath.Join(configHome, "elvish", "lib"), nil
 	} else {
-		return nil, fmt.Errorf("find roaming lib directory: %w", err)
+		return "", fmt.Errorf("find roaming lib directory: %w", err)
+	}
+}
+
+func libPaths() ([]string, error) {
+	configLib, err := configLibPath()
+	if err != nil {
+		return nil, err
 	}
+	paths := []string{configLib}
 
 	if dataHome := os.Getenv(env.XDG_DATA_HOME); dataHome != "" {
 		paths = append(paths, filepath.Join(dataHome, "elvish", "lib"))
@@ -54,22 +120,39 @@ func libPaths() ([]string, error) {
 }
 
 // Returns a SpawnConfig containing all the paths needed by the daemon. It
-// respects overrides of sock and db from CLI flags.
+// respects overrides of sock and db from CLI flags and environment variables.
+//
+// Flags take precedence over environment variables, which take precedence over
+// the default paths. The path of the database file is, in decreasing order of
+// precedence, from -db, from -data-dir, from $ELVISH_DB or from
+// $ELVISH_DATA_DIR.
 func daemonPaths(p *prog.DaemonPaths) (*daemondefs.SpawnConfig, error) {
 	runDir, err := secureRunDir()
 	if err != nil {
 		return nil, err
 	}
-	sock := p.Sock
+	sock := firstNonEmpty(p.Sock, os.Getenv(env.ELVISH_SOCK))
 	if sock == "" {
 		sock = filepath.Join(runDir, "sock")
+	} else if err := checkOverride(sock, "socket", false); err != nil {
+		return nil, err
 	}
 
 	db := p.DB
-	if db == "" {
-		var err error
-		db, err = dbPath()
-		if err != nil {
+	if db == "" && p.DataDir == "" {
+		db = os.Getenv(env.ELVISH_DB)
+	}
+	if db != "" {
+		if err := checkOverride(db, "database file", false); err != nil {
+			return nil, err
+		}
+	} else {
+		if dataDir := firstNonEmpty(p.DataDir, os.Getenv(env.ELVISH_DATA_DIR)); dataDir != "" {
+			if err := checkOverride(dataDir, "data directory", true); err != nil {
+				return nil, err
+			}
+			db = filepath.Join(dataDir, "db.bolt")
+		} else if db, err = dbPath(); err != nil {
 			return nil, err
 		}
 		err = os.MkdirAll(filepath.Dir(db), 0700)
@@ -77,7 +160,77 @@ func daemonPaths(p *prog.DaemonPaths) (*daemondefs.SpawnConfig, error) {
 			return nil, err
 		}
 	}
-	return &daemondefs.SpawnConfig{DbPath: db, SockPath: sock, RunDir: runDir}, nil
+	idle, err := durationEnv(env.ELVISH_DAEMON_IDLE_TIMEOUT)
+	if err != nil {
+		return nil, err
+	}
+	spawnTimeout, err := durationEnv(env.ELVISH_DAEMON_SPAWN_TIMEOUT)
+	if err != nil {
+		return nil, err
+	}
+	var passphrase string
+	if p.Encrypt {
+		passphrase = os.Getenv(env.ELVISH_DB_PASSPHRASE)
+		if passphrase == "" {
+			return nil, fmt.Errorf("-db-encrypt requires $%s", env.ELVISH_DB_PASSPHRASE)
+		}
+	}
+	return &daemondefs.SpawnConfig{
+		DbPath: db, SockPath: sock, RunDir: runDir, IdleTimeout: idle,
+		SpawnTimeout: spawnTimeout, Verbose: os.Getenv(env.ELVISH_DAEMON_VERBOSE) != "",
+		Remote: remoteDaemon(), Passphrase: passphrase}, nil
+}
+
+// Returns the non-negative duration in the environment variable, or 0 if it
+// is not set.
+func durationEnv(name string) (time.Duration, error) {
+	s := os.Getenv(name)
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid $%s: %q", name, s)
+	}
+	return d, nil
+}
+
+// Returns the configuration for connecting to a remote daemon from
+// $ELVISH_DAEMON_ADDR and related environment variables, or nil if
+// $ELVISH_DAEMON_ADDR is empty.
+func remoteDaemon() *daemondefs.RemoteConfig {
+	addr := os.Getenv(env.ELVISH_DAEMON_ADDR)
+	if addr == "" {
+		return nil
+	}
+	return &daemondefs.RemoteConfig{
+		Addr:     addr,
+		Token:    os.Getenv(env.ELVISH_DAEMON_TOKEN),
+		CAFile:   os.Getenv(env.ELVISH_DAEMON_CA),
+		CertFile: os.Getenv(env.ELVISH_DAEMON_CERT),
+		KeyFile:  os.Getenv(env.ELVISH_DAEMON_KEY),
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Validates a path overridden by a flag or an environment variable. If the
+// path exists, it must be a directory if and only if wantDir is true.
+func checkOverride(path, what string, wantDir bool) error {
+	if info, err := os.Stat(path); err == nil && info.IsDir() != wantDir {
+		if wantDir {
+			return fmt.Errorf("%s %s is not a directory", what, path)
+		}
+		return fmt.Errorf("%s %s is a directory", what, path)
+	}
+	return nil
 }
 
 func dbPath() (string, error) {
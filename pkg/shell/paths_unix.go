@@ -21,6 +21,9 @@ var defaultDataDirs = []string{
 	"/usr/share/elvish/lib",
 }
 
+// Directories containing system-wide config files, like rc-login.elv.
+var systemConfigDirs = []string{"/etc/elvish"}
+
 func defaultStateHome() (string, error) { return homePath(".local/state") }
 
 func homePath(suffix string) (string, error) {
@@ -14,6 +14,7 @@ var (
 	defaultDataHome   = localAppData
 	defaultDataDirs   = []string{}
 	defaultStateHome  = localAppData
+	systemConfigDirs  = []string{}
 )
 
 func localAppData() (string, error) {
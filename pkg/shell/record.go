@@ -0,0 +1,250 @@
+package shell
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/sys"
+)
+
+// A session recording is a file with one JSON object on each line. The first
+// line is a header, and the rest are events.
+
+type recordHeader struct {
+	Version int `json:"version"`
+	// Size of the terminal.
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// Unix timestamp of the start of the session.
+	Timestamp int64 `json:"timestamp"`
+}
+
+const recordVersion = 1
+
+// An event in a session recording. Exactly one of Command, Output and Done is
+// set.
+type recordEvent struct {
+	// Seconds since the start of the session.
+	Time float64 `json:"time"`
+	// A command accepted by the editor.
+	Command string `json:"command,omitempty"`
+	// Output of the command, from both stdout and stderr.
+	Output string `json:"output,omitempty"`
+	// Whether the command has finished.
+	Done bool `json:"done,omitempty"`
+	// Message of the exception thrown by the command, only set along with
+	// Done.
+	Exception string `json:"exception,omitempty"`
+}
+
+// Records an interactive session into a file.
+type recorder struct {
+	file  *os.File
+	start time.Time
+
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func newRecorder(path string, tty *os.File) (*recorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	height, width := sys.WinSize(tty)
+	if width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+	start := time.Now()
+	r := &recorder{file: file, start: start, enc: json.NewEncoder(file)}
+	err = r.enc.Encode(recordHeader{recordVersion, width, height, start.Unix()})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *recorder) write(e recordEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e.Time = time.Since(r.start).Seconds()
+	r.enc.Encode(e)
+}
+
+func (r *recorder) Close() error { return r.file.Close() }
+
+// Evaluates code like evalInTTY, showing any exception, and records the code
+// and its output.
+func (r *recorder) eval(fds [3]*os.File, ev *eval.Evaler, ed editor, src parse.Source) {
+	r.write(recordEvent{Command: src.Code})
+	files := fds
+	var wg sync.WaitGroup
+	for i := 1; i <= 2; i++ {
+		pr, pw, err := os.Pipe()
+		if err != nil {
+			// Fall back to not recording the output.
+			continue
+		}
+		files[i] = pw
+		wg.Add(1)
+		go func(out *os.File) {
+			defer wg.Done()
+			defer pr.Close()
+			buf := make([]byte, 4096)
+			for {
+				n, err := pr.Read(buf)
+				if n > 0 {
+					out.Write(buf[:n])
+					r.write(recordEvent{Output: string(buf[:n])})
+				}
+				if err != nil {
+					return
+				}
+			}
+		}(fds[i])
+	}
+	err := evalInTTYWithFiles(fds, files, ev, ed, src)
+	if err != nil {
+		diag.ShowError(files[2], err)
+	}
+	for i := 1; i <= 2; i++ {
+		if files[i] != fds[i] {
+			files[i].Close()
+		}
+	}
+	wg.Wait()
+	done := recordEvent{Done: true}
+	if err != nil {
+		done.Exception = err.Error()
+	}
+	r.write(done)
+}
+
+// Reads a session recording.
+func readRecording(r io.Reader) (recordHeader, []recordEvent, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16*1024*1024)
+	var header recordHeader
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return header, nil, err
+		}
+		return header, nil, errors.New("empty session recording")
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return header, nil, fmt.Errorf("invalid header: %w", err)
+	}
+	if header.Version != recordVersion {
+		return header, nil, fmt.Errorf("unsupported version %d", header.Version)
+	}
+	var events []recordEvent
+	for line := 2; scanner.Scan(); line++ {
+		var e recordEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return header, nil, fmt.Errorf("invalid event on line %d: %w", line, err)
+		}
+		events = append(events, e)
+	}
+	return header, events, scanner.Err()
+}
+
+// Prompt used when replaying commands.
+const replayPrompt = "~> "
+
+// Idle time longer than this is shortened to this when replaying.
+const replayIdleLimit = 2 * time.Second
+
+// Converts the events of a session recording to chunks of terminal output and
+// the seconds between the start of the session and each chunk. Pauses longer
+// than replayIdleLimit are shortened.
+func replayChunks(events []recordEvent) ([]string, []float64) {
+	var chunks []string
+	var times []float64
+	var lastRecorded, lastReplayed float64
+	for _, e := range events {
+		var chunk string
+		switch {
+		case e.Command != "":
+			chunk = replayPrompt + strings.ReplaceAll(e.Command, "\n", "\n   ") + "\n"
+		case e.Output != "":
+			chunk = e.Output
+		default:
+			continue
+		}
+		lastReplayed += min(max(e.Time-lastRecorded, 0), replayIdleLimit.Seconds())
+		lastRecorded = e.Time
+		chunks = append(chunks, chunk)
+		times = append(times, lastReplayed)
+	}
+	return chunks, times
+}
+
+// Replays the session recording in the named file to w, or converts it to the
+// asciicast format if asciicast is true.
+func replayFile(name string, w io.Writer, speed float64, asciicast bool) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if asciicast {
+		return toAsciicast(f, w)
+	}
+	return replay(f, w, speed)
+}
+
+// Replays a session recording, writing its output to w. The speed is a factor
+// applied to the recorded timing.
+func replay(r io.Reader, w io.Writer, speed float64) error {
+	_, events, err := readRecording(r)
+	if err != nil {
+		return err
+	}
+	chunks, times := replayChunks(events)
+	var last float64
+	for i, chunk := range chunks {
+		time.Sleep(time.Duration((times[i] - last) / speed * float64(time.Second)))
+		last = times[i]
+		if _, err := io.WriteString(w, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Converts a session recording to the asciicast v2 format, used by asciinema.
+// See https://docs.asciinema.org/manual/asciicast/v2/.
+func toAsciicast(r io.Reader, w io.Writer) error {
+	header, events, err := readRecording(r)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	err = enc.Encode(map[string]any{
+		"version": 2, "width": header.Width, "height": header.Height,
+		"timestamp": header.Timestamp})
+	if err != nil {
+		return err
+	}
+	chunks, times := replayChunks(events)
+	for i, chunk := range chunks {
+		// Terminals need CRLF line endings when the output is not processed
+		// by the TTY driver.
+		chunk = strings.ReplaceAll(chunk, "\n", "\r\n")
+		if err := enc.Encode([]any{times[i], "o", chunk}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"src.elv.sh/pkg/edit"
+	"src.elv.sh/pkg/ui"
+)
+
+// Prefix and suffix of the names of the files in the run directory that keep
+// the code buffers of interactive shells. The part in between is the pid.
+const (
+	bufferFilePrefix = "buffer-"
+	bufferFileSuffix = ".json"
+)
+
+// Makes the editor save its code buffer in the run directory. If a buffer was
+// left behind by a shell that exited abnormally, it is restored.
+func setupBufferRecovery(ed *edit.Editor, runDir string) {
+	content, dot, ok := takeAbandonedBuffer(runDir)
+	ed.EnableBufferRecovery(filepath.Join(runDir,
+		bufferFilePrefix+strconv.Itoa(os.Getpid())+bufferFileSuffix))
+	if ok {
+		ed.RestoreBuffer(content, dot)
+		ed.Notify(ui.T("Restored unfinished code from a session that exited abnormally; press Ctrl-C to discard it"))
+	}
+}
+
+// Finds the buffer files in the run directory left behind by processes that
+// no longer exist, and removes them. Returns the content of the most recently
+// modified one.
+func takeAbandonedBuffer(runDir string) (content string, dot int, ok bool) {
+	entries, err := os.ReadDir(runDir)
+	if err != nil {
+		return "", 0, false
+	}
+	var latest time.Time
+	for _, entry := range entries {
+		name := entry.Name()
+		pidString, ok1 := strings.CutPrefix(name, bufferFilePrefix)
+		pidString, ok2 := strings.CutSuffix(pidString, bufferFileSuffix)
+		if !ok1 || !ok2 {
+			continue
+		}
+		pid, err := strconv.Atoi(pidString)
+		if err != nil || processExists(pid) {
+			continue
+		}
+		path := filepath.Join(runDir, name)
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		c, d, err := edit.ReadSavedBuffer(path)
+		// Another shell starting at the same time may be racing to take the
+		// same file; only the one that removes it gets the content.
+		if os.Remove(path) != nil || err != nil {
+			continue
+		}
+		if !ok || info.ModTime().After(latest) {
+			content, dot, ok, latest = c, d, true, info.ModTime()
+		}
+	}
+	return content, dot, ok
+}
@@ -0,0 +1,48 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/must"
+)
+
+// Almost certainly not the pid of any process.
+const deadPid = 999999999
+
+func writeBufferFile(t *testing.T, dir string, pid int, content string, mtime time.Time) string {
+	t.Helper()
+	path := filepath.Join(dir, bufferFilePrefix+strconv.Itoa(pid)+bufferFileSuffix)
+	must.WriteFile(path, `{"content": "`+content+`", "dot": 1}`)
+	must.OK(os.Chtimes(path, mtime, mtime))
+	return path
+}
+
+func TestTakeAbandonedBuffer(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	old := writeBufferFile(t, dir, deadPid, "old", now.Add(-time.Hour))
+	latest := writeBufferFile(t, dir, deadPid-1, "latest", now)
+	alive := writeBufferFile(t, dir, os.Getpid(), "alive", now.Add(time.Hour))
+	must.WriteFile(filepath.Join(dir, "unrelated"), "")
+
+	content, dot, ok := takeAbandonedBuffer(dir)
+	if content != "latest" || dot != 1 || !ok {
+		t.Errorf("takeAbandonedBuffer -> (%q, %v, %v), want (%q, 1, true)", content, dot, ok, "latest")
+	}
+	for _, path := range []string{old, latest} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s not removed", path)
+		}
+	}
+	if _, err := os.Stat(alive); err != nil {
+		t.Errorf("buffer file of live process removed")
+	}
+
+	if _, _, ok := takeAbandonedBuffer(dir); ok {
+		t.Errorf("takeAbandonedBuffer returns ok again")
+	}
+}
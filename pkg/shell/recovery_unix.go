@@ -0,0 +1,19 @@
+//go:build unix
+
+package shell
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+func processExists(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	// EPERM means that the process exists but belongs to another user.
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -2,12 +2,16 @@
 package shell
 
 import (
+	"bufio"
 	"fmt"
 	"io"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
+	"text/tabwriter"
 	"time"
 
 	"src.elv.sh/pkg/cli/term"
@@ -16,8 +20,15 @@ import (
 	"src.elv.sh/pkg/eval"
 	"src.elv.sh/pkg/logutil"
 	"src.elv.sh/pkg/mods"
+	"src.elv.sh/pkg/mods/ssh"
 	"src.elv.sh/pkg/parse"
 	"src.elv.sh/pkg/prog"
+	"src.elv.sh/pkg/store"
+	"src.elv.sh/pkg/store/histexport"
+	"src.elv.sh/pkg/store/histimport"
+	"src.elv.sh/pkg/store/histmerge"
+	"src.elv.sh/pkg/store/histstats"
+	"src.elv.sh/pkg/store/storedefs"
 	"src.elv.sh/pkg/sys"
 	"src.elv.sh/pkg/ui"
 )
@@ -44,10 +55,35 @@ var logger = logutil.GetLogger("[shell] ")
 type Program struct {
 	ActivateDaemon daemondefs.ActivateFunc
 
+	argv0       string
+	login       bool
 	codeInArg   bool
 	compileOnly bool
 	noRC        bool
 	rc          string
+	libDirs     string
+	restricted  bool
+	externals   string
+	root        string
+	writable    string
+	remote      bool
+	record      string
+	replay      string
+	replaySpeed float64
+	asciicast   bool
+	attach      string
+	importHist  string
+	exportHist  bool
+	mergeHist   string
+	stats       bool
+	daemonStats bool
+	compactDB   bool
+	backupDB    string
+	restoreDB   string
+	migrate     bool
+	histFormat  string
+	web         bool
+	port        int
 	json        *bool
 	daemonPaths *prog.DaemonPaths
 }
@@ -56,9 +92,9 @@ func (p *Program) RegisterFlags(fs *prog.FlagSet) {
 	// script(1) (and possibly other programs) assume shells support -i
 	fs.Bool("i", false,
 		"A no-op flag, introduced for compatibility")
-	// termux (and possibly other programs) assume shells support -l
-	fs.Bool("l", false,
-		"A no-op flag, introduced for compatibility")
+	p.argv0 = fs.Argv0()
+	fs.BoolVar(&p.login, "l", false,
+		"Run as a login shell, reading rc-login.elv before the RC file when running interactively")
 	fs.BoolVar(&p.codeInArg, "c", false,
 		"Treat the first argument as code to execute")
 	fs.BoolVar(&p.compileOnly, "compileonly", false,
@@ -67,6 +103,52 @@ func (p *Program) RegisterFlags(fs *prog.FlagSet) {
 		"Don't read the RC file when running interactively")
 	fs.StringVar(&p.rc, "rc", "",
 		"Path to the RC file when running interactively")
+	fs.StringVar(&p.libDirs, "lib-dirs", "",
+		"Extra module search directories, overriding $"+env.ELVISH_LIB_DIRS)
+	fs.BoolVar(&p.restricted, "restricted", false,
+		"Run in restricted mode")
+	fs.StringVar(&p.externals, "allowed-externals", "",
+		"External commands that can be run in restricted mode, separated by "+string(filepath.ListSeparator))
+	fs.StringVar(&p.root, "root", "",
+		"Directory that the working directory can't leave in restricted mode")
+	fs.StringVar(&p.writable, "writable", "",
+		"Directories that can be written in restricted mode, separated by "+string(filepath.ListSeparator))
+	fs.StringVar(&p.attach, "attach", "",
+		"Attach to a session hosted by the daemon, creating it if it is not running")
+	fs.StringVar(&p.importHist, "import-history", "",
+		"Import the command history of another shell from a file into the daemon's database")
+	fs.StringVar(&p.histFormat, "history-format", "",
+		"Format of the file for -import-history (bash, zsh or fish); guessed from the file name by default")
+	fs.BoolVar(&p.exportHist, "export-history", false,
+		"Write the command history in the daemon's database, one command per line, or as JSON with -json")
+	fs.StringVar(&p.mergeHist, "merge-history", "",
+		"Merge the command history in another database file, or written by -export-history -json, into the daemon's database")
+	fs.BoolVar(&p.stats, "stats", false,
+		"Show statistics of the command and directory history in the daemon's database")
+	fs.BoolVar(&p.daemonStats, "daemon-stats", false,
+		"Show statistics of the daemon, like its uptime and the time spent in each RPC method")
+	fs.BoolVar(&p.compactDB, "compact-db", false,
+		"Check the integrity of the database and compact it, using the daemon if it is running")
+	fs.StringVar(&p.backupDB, "backup-db", "",
+		"Write a consistent copy of the daemon's database to a file, without stopping the daemon")
+	fs.StringVar(&p.restoreDB, "restore-db", "",
+		"Replace the daemon's database with a copy written by -backup-db")
+	fs.BoolVar(&p.migrate, "migrate-legacy", false,
+		"Move files in the legacy ~/.elvish directory to their new paths")
+	fs.StringVar(&p.record, "record", "",
+		"Record the interactive session into a file")
+	fs.StringVar(&p.replay, "replay", "",
+		"Replay a session recorded with -record")
+	fs.Float64Var(&p.replaySpeed, "replay-speed", 1,
+		"Speed factor of -replay")
+	fs.BoolVar(&p.asciicast, "asciicast", false,
+		"Convert the recording to replay to the asciicast format used by asciinema")
+	fs.BoolVar(&p.remote, "remote", false,
+		"Evaluate code sent by ssh:run from another host; used on the remote host")
+	fs.BoolVar(&p.web, "web", false,
+		"Serve a web UI for an interactive session instead of using the terminal")
+	fs.IntVar(&p.port, "port", defaultWebPort,
+		"Port for the web UI")
 
 	p.json = fs.JSON()
 	if p.ActivateDaemon != nil {
@@ -77,14 +159,121 @@ func (p *Program) RegisterFlags(fs *prog.FlagSet) {
 func (p *Program) Run(fds [3]*os.File, args []string) error {
 	cleanup1 := incSHLVL()
 	defer cleanup1()
-	cleanup2 := initSignal(fds)
-	defer cleanup2()
 
 	// https://no-color.org
 	ui.NoColor = os.Getenv(env.NO_COLOR) != ""
-	interactive := len(args) == 0
+	if !p.restricted && (p.externals != "" || p.root != "" || p.writable != "") {
+		return prog.BadUsage("-allowed-externals, -root and -writable require -restricted")
+	}
+
+	if p.port != defaultWebPort && !p.web {
+		return prog.BadUsage("-port requires -web")
+	}
+	if p.web && len(args) > 0 {
+		return prog.BadUsage("arguments are not allowed with -web")
+	}
+
+	if p.replay == "" && (p.replaySpeed != 1 || p.asciicast) {
+		return prog.BadUsage("-replay-speed and -asciicast require -replay")
+	}
+	if p.replaySpeed <= 0 {
+		return prog.BadUsage("-replay-speed must be positive")
+	}
+	if p.replay != "" {
+		return replayFile(p.replay, fds[1], p.replaySpeed, p.asciicast)
+	}
+	if p.attach != "" {
+		if len(args) > 0 || p.web || p.remote || p.record != "" {
+			return prog.BadUsage("-attach doesn't work with arguments, -web, -remote or -record")
+		}
+		return p.attachSession(fds)
+	}
+	if p.histFormat != "" && p.importHist == "" {
+		return prog.BadUsage("-history-format requires -import-history")
+	}
+	if p.importHist != "" {
+		if len(args) > 0 || p.web || p.remote || p.record != "" {
+			return prog.BadUsage("-import-history doesn't work with arguments, -web, -remote or -record")
+		}
+		return p.importHistory(fds)
+	}
+	if p.exportHist {
+		if len(args) > 0 || p.web || p.remote || p.record != "" {
+			return prog.BadUsage("-export-history doesn't work with arguments, -web, -remote or -record")
+		}
+		return p.exportHistory(fds)
+	}
+	if p.mergeHist != "" {
+		if len(args) > 0 || p.web || p.remote || p.record != "" {
+			return prog.BadUsage("-merge-history doesn't work with arguments, -web, -remote or -record")
+		}
+		return p.mergeHistory(fds)
+	}
+	if p.migrate {
+		if len(args) > 0 || p.web || p.remote || p.record != "" {
+			return prog.BadUsage("-migrate-legacy doesn't work with arguments, -web, -remote or -record")
+		}
+		return migrateLegacy(fds[1])
+	}
+	if p.stats {
+		if len(args) > 0 || p.web || p.remote || p.record != "" {
+			return prog.BadUsage("-stats doesn't work with arguments, -web, -remote or -record")
+		}
+		return p.showStats(fds)
+	}
+	if p.daemonStats {
+		if len(args) > 0 || p.web || p.remote || p.record != "" {
+			return prog.BadUsage("-daemon-stats doesn't work with arguments, -web, -remote or -record")
+		}
+		return p.showDaemonStats(fds)
+	}
+	if p.compactDB {
+		if len(args) > 0 || p.web || p.remote || p.record != "" {
+			return prog.BadUsage("-compact-db doesn't work with arguments, -web, -remote or -record")
+		}
+		return p.compactDatabase(fds)
+	}
+	if p.backupDB != "" {
+		if len(args) > 0 || p.web || p.remote || p.record != "" {
+			return prog.BadUsage("-backup-db doesn't work with arguments, -web, -remote or -record")
+		}
+		return p.backupDatabase(fds)
+	}
+	if p.restoreDB != "" {
+		if len(args) > 0 || p.web || p.remote || p.record != "" {
+			return prog.BadUsage("-restore-db doesn't work with arguments, -web, -remote or -record")
+		}
+		return p.restoreDatabase(fds)
+	}
+	if p.record != "" && (len(args) > 0 || p.web || p.remote) {
+		return prog.BadUsage("-record only works with interactive sessions in the terminal")
+	}
+	if p.remote && (len(args) > 0 || p.web) {
+		return prog.BadUsage("-remote doesn't work with arguments or -web")
+	}
+
+	interactive := len(args) == 0 && !p.remote
+	if interactive {
+		warnLegacy(fds[2])
+	}
 	ev := p.makeEvaler(fds[2], interactive)
 	defer ev.PreExit()
+	if p.restricted {
+		err := ev.Restrict(eval.Restrictions{
+			Externals: splitList(p.externals),
+			Root:      p.root,
+			Writable:  splitList(p.writable),
+		})
+		if err != nil {
+			return fmt.Errorf("cannot enter restricted mode: %w", err)
+		}
+	}
+	cleanup2 := initSignal(fds, ev)
+	defer cleanup2()
+
+	if p.remote {
+		return ssh.ServeRemote(ev, fds[0], fds[1], fds[2])
+	}
 
 	if !interactive {
 		exit := script(
@@ -103,12 +292,344 @@ func (p *Program) Run(fds [3]*os.File, args []string) error {
 		}
 	}
 
+	if p.web {
+		return webSession(ev, fds, &webCfg{
+			Port:           p.port,
+			ActivateDaemon: p.ActivateDaemon, SpawnConfig: spawnCfg})
+	}
+
+	var loginRCs, logoutRCs []string
+	if (p.login || strings.HasPrefix(p.argv0, "-")) && !p.noRC {
+		loginRCs = loginRCPaths("rc-login.elv")
+		logoutRCs = loginRCPaths("rc-logout.elv")
+		// Run the system-wide one last.
+		slices.Reverse(logoutRCs)
+	}
 	interact(ev, fds, &interactCfg{
 		RC:             ev.EffectiveRcPath,
+		LoginRCs:       loginRCs,
+		LogoutRCs:      logoutRCs,
+		Record:         p.record,
 		ActivateDaemon: p.ActivateDaemon, SpawnConfig: spawnCfg})
 	return nil
 }
 
+// Connects to the daemon for a flag that requires it.
+func (p *Program) connectDaemon(fds [3]*os.File, flag string) (daemondefs.Client, error) {
+	if p.ActivateDaemon == nil {
+		return nil, fmt.Errorf("%s requires the daemon, which is not supported by this build", flag)
+	}
+	spawnCfg, err := daemonPaths(p.daemonPaths)
+	if err != nil {
+		return nil, err
+	}
+	cl, err := p.ActivateDaemon(fds[2], spawnCfg)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to daemon: %w", err)
+	}
+	return cl, nil
+}
+
+func (p *Program) attachSession(fds [3]*os.File) error {
+	cl, err := p.connectDaemon(fds, "-attach")
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	return attach(fds, cl, p.attach)
+}
+
+func (p *Program) importHistory(fds [3]*os.File) error {
+	format := p.histFormat
+	if format == "" {
+		format = histimport.GuessFormat(p.importHist)
+	}
+	f, err := os.Open(p.importHist)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	entries, err := histimport.Parse(format, f)
+	if err != nil {
+		return err
+	}
+	cl, err := p.connectDaemon(fds, "-import-history")
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	n, err := histimport.Import(cl, entries)
+	fmt.Fprintf(fds[1], "Imported %d commands from %s\n", n, p.importHist)
+	return err
+}
+
+func (p *Program) exportHistory(fds [3]*os.File) error {
+	cl, err := p.connectDaemon(fds, "-export-history")
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	format := "text"
+	if *p.json {
+		format = "json"
+	}
+	_, err = histexport.Export(fds[1], cl, format)
+	return err
+}
+
+func (p *Program) mergeHistory(fds [3]*os.File) error {
+	records, err := readHistory(p.mergeHist, p.daemonPaths)
+	if err != nil {
+		return err
+	}
+	cl, err := p.connectDaemon(fds, "-merge-history")
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	n, err := histmerge.Merge(cl, records)
+	fmt.Fprintf(fds[1], "Merged %d commands from %s\n", n, p.mergeHist)
+	return err
+}
+
+// Reads the command history in a file, which is either a database file or
+// written by -export-history -json. If the database file is encrypted, it is
+// opened with the passphrase when -db-encrypt is given.
+func readHistory(path string, paths *prog.DaemonPaths) ([]storedefs.CmdRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// The JSON format has one object on each line, while a database file
+	// starts with the header of its first page.
+	r := bufio.NewReader(f)
+	if first, err := r.Peek(1); err == nil && first[0] == '{' {
+		return histexport.ParseJSON(r)
+	}
+	f.Close()
+
+	// Opening the database as a store can modify it, for example by creating
+	// missing buckets, so open a copy instead. The database may be in a
+	// folder synced from another machine, and should be left untouched.
+	dir, err := os.MkdirTemp("", "elvish-merge-history-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	dbCopy := filepath.Join(dir, "db")
+	err = store.CopyReadOnly(path, dbCopy)
+	if store.IsLocked(err) {
+		return nil, fmt.Errorf("%s is being used by a daemon; stop it, or merge the output of -export-history -json instead", path)
+	} else if err != nil {
+		return nil, err
+	}
+
+	st, err := store.NewStore(dbCopy)
+	if err == store.ErrEncrypted && paths != nil && paths.Encrypt {
+		// Only open the database with the passphrase if it is already
+		// encrypted, since that would encrypt it otherwise.
+		passphrase := os.Getenv(env.ELVISH_DB_PASSPHRASE)
+		if passphrase == "" {
+			return nil, fmt.Errorf("-db-encrypt requires $%s", env.ELVISH_DB_PASSPHRASE)
+		}
+		st, err = store.NewEncryptedStore(dbCopy, passphrase)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer st.Close()
+	return histmerge.Records(st)
+}
+
+// Number of commands and directories shown by -stats.
+const statsTop = 10
+
+func (p *Program) showStats(fds [3]*os.File) error {
+	cl, err := p.connectDaemon(fds, "-stats")
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	st, err := histstats.Compute(cl)
+	if err != nil {
+		return err
+	}
+	st.Write(fds[1], statsTop)
+	return nil
+}
+
+func (p *Program) showDaemonStats(fds [3]*os.File) error {
+	cl, err := p.connectDaemon(fds, "-daemon-stats")
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	stats, err := cl.Stats()
+	if err != nil {
+		return err
+	}
+	writeDaemonStats(fds[1], stats)
+	return nil
+}
+
+func writeDaemonStats(w io.Writer, stats daemondefs.Stats) {
+	fmt.Fprintln(w, "Uptime:", stats.Uptime.Round(time.Second))
+	fmt.Fprintln(w, "Clients:", stats.Clients)
+	if stats.StoreSize >= 0 {
+		fmt.Fprintln(w, "Database size:", formatSize(stats.StoreSize))
+	} else {
+		fmt.Fprintln(w, "Database size: unknown")
+	}
+	if len(stats.Calls) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nRPC calls:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  Method\tCalls\tAverage\tMax")
+	for _, c := range stats.Calls {
+		avg := c.Total / time.Duration(max(c.Count, 1))
+		fmt.Fprintf(tw, "  %s\t%d\t%v\t%v\n", c.Method, c.Count,
+			avg.Round(time.Microsecond), c.Max.Round(time.Microsecond))
+	}
+	tw.Flush()
+}
+
+func (p *Program) compactDatabase(fds [3]*os.File) error {
+	paths := p.daemonPaths
+	if paths == nil {
+		paths = &prog.DaemonPaths{}
+	}
+	spawnCfg, err := daemonPaths(paths)
+	if err != nil {
+		return err
+	}
+	var report storedefs.CompactReport
+	if spawnCfg.Remote != nil {
+		report, err = p.compactWithDaemon(fds)
+	} else {
+		report, err = compactFile(spawnCfg.DbPath, spawnCfg.Passphrase)
+		if store.IsLocked(err) {
+			// The database is being used by a running daemon.
+			report, err = p.compactWithDaemon(fds)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	writeCompactReport(fds[1], spawnCfg.DbPath, report)
+	return nil
+}
+
+// Compacts a database file that is not being used by a daemon. If the
+// passphrase is not empty, the database is encrypted with it.
+func compactFile(path, passphrase string) (storedefs.CompactReport, error) {
+	// Opening the database creates the file if it doesn't exist.
+	if _, err := os.Stat(path); err != nil {
+		return storedefs.CompactReport{}, err
+	}
+	var st store.DBStore
+	var err error
+	if passphrase != "" {
+		st, err = store.NewEncryptedStore(path, passphrase)
+	} else {
+		st, err = store.NewStore(path)
+	}
+	if err != nil {
+		return storedefs.CompactReport{}, err
+	}
+	report, err := st.Compact()
+	if closeErr := st.Close(); err == nil {
+		err = closeErr
+	}
+	return report, err
+}
+
+func (p *Program) compactWithDaemon(fds [3]*os.File) (storedefs.CompactReport, error) {
+	cl, err := p.connectDaemon(fds, "-compact-db")
+	if err != nil {
+		return storedefs.CompactReport{}, err
+	}
+	defer cl.Close()
+	return cl.Compact()
+}
+
+func (p *Program) backupDatabase(fds [3]*os.File) error {
+	path, err := backupPath(p.backupDB)
+	if err != nil {
+		return err
+	}
+	cl, err := p.connectDaemon(fds, "-backup-db")
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	size, err := cl.Backup(path)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(fds[1], "Backed up the database to %s (%s)\n", p.backupDB, formatSize(size))
+	return nil
+}
+
+func (p *Program) restoreDatabase(fds [3]*os.File) error {
+	path, err := backupPath(p.restoreDB)
+	if err != nil {
+		return err
+	}
+	cl, err := p.connectDaemon(fds, "-restore-db")
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	if err := cl.Restore(path); err != nil {
+		return err
+	}
+	fmt.Fprintf(fds[1], "Restored the database from %s\n", p.restoreDB)
+	return nil
+}
+
+// Returns the path of a backup to send to the daemon. The path is made absolute
+// unless the daemon is remote, in which case it is a path on the machine of
+// the daemon and is sent as is.
+func backupPath(path string) (string, error) {
+	if remoteDaemon() != nil {
+		return path, nil
+	}
+	return filepath.Abs(path)
+}
+
+func writeCompactReport(w io.Writer, path string, report storedefs.CompactReport) {
+	fmt.Fprintln(w, "Integrity check passed")
+	fmt.Fprintf(w, "Compacted %s from %s to %s\n", path,
+		formatSize(report.SizeBefore), formatSize(report.SizeAfter))
+	if len(report.Buckets) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nBuckets:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  Name\tKeys\tSize")
+	for _, b := range report.Buckets {
+		fmt.Fprintf(tw, "  %s\t%d\t%s\n", b.Name, b.Keys, formatSize(b.Size))
+	}
+	tw.Flush()
+}
+
+// Formats a size in bytes with a binary unit.
+func formatSize(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	size := float64(n) / 1024
+	for _, unit := range []string{"KiB", "MiB", "GiB"} {
+		if size < 1024 || unit == "GiB" {
+			return fmt.Sprintf("%.1f %s", size, unit)
+		}
+		size /= 1024
+	}
+	panic("unreachable")
+}
+
 // Creates an Evaler, sets the module search directories and installs all the
 // standard builtin modules.
 //
@@ -138,11 +659,17 @@ func (p *Program) makeEvaler(stderr io.Writer, interactive bool) *eval.Evaler {
 		}
 	}
 
+	ev.LibDirs = extraLibPaths(p.libDirs)
 	libs, err := libPaths()
 	if err != nil {
 		fmt.Fprintln(stderr, "Warning: resolving lib paths:", err)
 	} else {
-		ev.LibDirs = libs
+		ev.LibDirs = append(ev.LibDirs, libs...)
+	}
+	if autoload, err := autoloadPath(); err != nil {
+		fmt.Fprintln(stderr, "Warning:", err)
+	} else {
+		ev.AutoloadDirs = []string{autoload}
 	}
 
 	mods.AddTo(ev)
@@ -166,12 +693,16 @@ func incSHLVL() func() {
 	}
 }
 
-func initSignal(fds [3]*os.File) func() {
+func initSignal(fds [3]*os.File, ev *eval.Evaler) func() {
 	sigCh := sys.NotifySignals()
 	go func() {
 		for sig := range sigCh {
 			logger.Println("signal", sig)
-			handleSignal(sig, fds[2])
+			if ev.HandleSignal(sig, fds) {
+				// Handlers set by Elvish code replace the default handling.
+				continue
+			}
+			handleSignal(sig, fds[2], ev)
 		}
 	}()
 
@@ -182,12 +713,18 @@ func initSignal(fds [3]*os.File) func() {
 }
 
 func evalInTTY(fds [3]*os.File, ev *eval.Evaler, ed editor, src parse.Source) error {
+	return evalInTTYWithFiles(fds, fds, ev, ed, src)
+}
+
+// Like evalInTTY, but the code uses files instead of fds as its standard files.
+// The terminal is still set up with fds.
+func evalInTTYWithFiles(fds, files [3]*os.File, ev *eval.Evaler, ed editor, src parse.Source) error {
 	start := time.Now()
-	ports, cleanup := eval.PortsFromFiles(fds, ev.ValuePrefix())
+	ports, cleanup := eval.PortsFromFiles(files, ev.ValuePrefix())
 	defer cleanup()
 	restore := term.SetupForEval(fds[0], fds[1])
 	defer restore()
-	ctx, done := eval.ListenInterrupts()
+	ctx, done := ev.ListenInterrupts()
 	err := ev.Eval(src, eval.EvalCfg{
 		Ports: ports, Interrupts: ctx, PutInFg: true})
 	done()
@@ -8,12 +8,17 @@ import (
 	"os"
 	"syscall"
 
+	"src.elv.sh/pkg/eval"
 	"src.elv.sh/pkg/sys"
 )
 
-func handleSignal(sig os.Signal, stderr io.Writer) {
+func handleSignal(sig os.Signal, stderr io.Writer, ev *eval.Evaler) {
 	switch sig {
 	case syscall.SIGHUP:
+		// Deferred calls don't run when exiting with os.Exit, so run the
+		// pre-exit hooks explicitly. In interactive mode, this also hangs up
+		// child processes in other process groups.
+		ev.PreExit()
 		syscall.Kill(0, syscall.SIGHUP)
 		os.Exit(0)
 	case syscall.SIGUSR1:
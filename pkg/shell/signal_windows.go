@@ -4,13 +4,16 @@ import (
 	"io"
 	"os"
 	"syscall"
+
+	"src.elv.sh/pkg/eval"
 )
 
-func handleSignal(sig os.Signal, stderr io.Writer) {
+func handleSignal(sig os.Signal, stderr io.Writer, ev *eval.Evaler) {
 	switch sig {
 	// See https://pkg.go.dev/os/signal#hdr-Windows for the semantics of SIGTERM
 	// on Windows.
 	case syscall.SIGTERM:
+		ev.PreExit()
 		os.Exit(0)
 	}
 }
@@ -1,3 +1,6 @@
 package shell
 
-var SecureRunDir = secureRunDir
+var (
+	SecureRunDir     = secureRunDir
+	SystemConfigDirs = &systemConfigDirs
+)
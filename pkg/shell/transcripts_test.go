@@ -46,6 +46,9 @@ func TestTranscripts(t *testing.T) {
 			testutil.Umask(t, must.OK1(strconv.Atoi(arg)))
 		},
 		"in-temp-home", func(t *testing.T) { testutil.InTempHome(t) },
+		"system-config-dir", func(t *testing.T, dir string) {
+			testutil.Set(t, shell.SystemConfigDirs, []string{must.OK1(filepath.Abs(dir))})
+		},
 		"skip-if-root", func(t *testing.T) {
 			if os.Getuid() == 0 {
 				t.SkipNow()
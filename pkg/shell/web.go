@@ -0,0 +1,63 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+
+	"src.elv.sh/pkg/daemon/daemondefs"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/web"
+)
+
+const defaultWebPort = 3171
+
+// Configuration for a web session.
+type webCfg struct {
+	Port int
+
+	ActivateDaemon daemondefs.ActivateFunc
+	SpawnConfig    *daemondefs.SpawnConfig
+}
+
+// Runs a web session, serving the web UI on localhost until interrupted.
+func webSession(ev *eval.Evaler, fds [3]*os.File, cfg *webCfg) error {
+	token, err := web.NewToken()
+	if err != nil {
+		return err
+	}
+	webCfg := web.Config{Token: token}
+	if cl := activateDaemon(ev, fds[2], cfg.ActivateDaemon, cfg.SpawnConfig, false); cl != nil {
+		webCfg.Store = cl
+	}
+
+	// Only listen on localhost; the web UI gives full access to the shell. It
+	// also requires the token, which is only shown to the user, so that other
+	// users of the machine and other web pages can't use it.
+	l, err := net.Listen("tcp", net.JoinHostPort("localhost", strconv.Itoa(cfg.Port)))
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: web.Handler(ev, webCfg)}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	defer signal.Stop(sigCh)
+	go func() {
+		if _, ok := <-sigCh; ok {
+			server.Shutdown(context.Background())
+		}
+	}()
+
+	fmt.Fprintf(fds[2], "Serving web UI at http://%s/#token=%s, press Ctrl-C to stop\n", l.Addr(), token)
+	err = server.Serve(l)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,178 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	errBackupEncrypted    = errors.New("the backup is encrypted, but the database is not")
+	errBackupNotEncrypted = errors.New("the backup is not encrypted, but the database is")
+	errBackupOtherKey     = errors.New("the backup is not encrypted with the same key as the database")
+	errBackupToDB         = errors.New("cannot back up the database to itself")
+)
+
+// Backup writes a consistent copy of the database to path, returning the size
+// of the copy. It runs in a read-only transaction, so other operations can
+// continue while the copy is being written. The file at path, if any, is only
+// replaced after the copy is written successfully.
+func (s *dbStore) Backup(path string) (int64, error) {
+	var size int64
+	err := s.view(func(tx *bolt.Tx) error {
+		info, err := os.Stat(tx.DB().Path())
+		if err != nil {
+			return err
+		}
+		if old, err := os.Stat(path); err == nil && os.SameFile(info, old) {
+			return errBackupToDB
+		}
+		tmpPath := path + ".tmp"
+		f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		size, err = tx.WriteTo(f)
+		if err == nil {
+			err = f.Sync()
+		}
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(tmpPath, path)
+		}
+		if err != nil {
+			os.Remove(tmpPath)
+		}
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// CopyReadOnly writes a consistent copy of the database file src to dst. The
+// database is opened read-only, so it is not modified in any way, unlike with
+// [NewStore], which creates missing buckets. Like [NewStore], it returns an
+// error for which [IsLocked] is true if the database is being used by another
+// process.
+func CopyReadOnly(src, dst string) error {
+	db, err := bolt.Open(src, 0o644, &bolt.Options{Timeout: 1 * time.Second, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(func(tx *bolt.Tx) error {
+		f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return err
+		}
+		_, err = tx.WriteTo(f)
+		return errors.Join(err, f.Close())
+	})
+}
+
+// Restore replaces the database with a backup written by Backup, which is left
+// untouched. Other operations are blocked until it finishes.
+//
+// The integrity of the backup is checked before replacing the database. If the
+// database is encrypted, the backup must be encrypted with the same passphrase;
+// otherwise it must not be encrypted.
+func (s *dbStore) Restore(backup string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := s.db.Path()
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".restore"
+	// Left by an earlier restore that was interrupted.
+	os.Remove(tmpPath)
+	if err := copyFile(tmpPath, backup, info.Mode().Perm()); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := s.prepareRestore(tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	// Like in Compact, close the database before replacing it, and reopen it.
+	if err := s.db.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	renameErr := os.Rename(tmpPath, path)
+	s.db, err = dbWithDefaultOptions(path)
+	if err != nil {
+		return fmt.Errorf("reopen database: %w", err)
+	}
+	if renameErr != nil {
+		os.Remove(tmpPath)
+		return renameErr
+	}
+	return nil
+}
+
+// Checks a copy of a backup to restore, and brings it up to date with the
+// current version of Elvish.
+func (s *dbStore) prepareRestore(path string) error {
+	db, err := bolt.Open(path, 0, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return fmt.Errorf("open backup: %w", err)
+	}
+	defer db.Close()
+	if err := check(db); err != nil {
+		return err
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		for name, fn := range initDB {
+			if err := fn(tx); err != nil {
+				return fmt.Errorf("failed to %s: %v", name, err)
+			}
+		}
+		v := tx.Bucket([]byte(bucketSetting)).Get([]byte(settingEncryption))
+		switch {
+		case s.aead == nil && v != nil:
+			return errBackupEncrypted
+		case s.aead != nil && v == nil:
+			return errBackupNotEncrypted
+		case v != nil:
+			var e encryption
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			check, err := s.open(bucketSetting, []byte(settingEncryption), e.Check)
+			if err != nil || string(check) != encryptionCheck {
+				return errBackupOtherKey
+			}
+		}
+		return nil
+	})
+}
+
+func copyFile(dst, src string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,160 @@
+package store_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+	"src.elv.sh/pkg/must"
+	"src.elv.sh/pkg/store"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	st := store.MustTempStore(t)
+	st.AddCmd("echo foo")
+	st.AddDir("/foo", 1)
+
+	backup := filepath.Join(t.TempDir(), "backup")
+	size, err := st.Backup(backup)
+	if err != nil {
+		t.Fatalf("Backup -> error %v", err)
+	}
+	if info, err := os.Stat(backup); err != nil || info.Size() != size {
+		t.Errorf("Backup -> size %v, but got file info (%v, %v)", size, info, err)
+	}
+
+	st.AddCmd("echo bar")
+	st.DelDir("/foo")
+	if err := st.Restore(backup); err != nil {
+		t.Fatalf("Restore -> error %v", err)
+	}
+	if next, err := st.NextCmdSeq(); next != 2 || err != nil {
+		t.Errorf("NextCmdSeq -> (%v, %v), want (2, nil)", next, err)
+	}
+	if cmd, err := st.Cmd(1); cmd != "echo foo" || err != nil {
+		t.Errorf("Cmd(1) -> (%q, %v), want (%q, nil)", cmd, err, "echo foo")
+	}
+	if dirs, err := st.Dirs(nil); len(dirs) != 1 || err != nil {
+		t.Errorf("Dirs -> (%v, %v), want /foo", dirs, err)
+	}
+	// The backup is left untouched, and can be restored again.
+	if err := st.Restore(backup); err != nil {
+		t.Errorf("Restore again -> error %v", err)
+	}
+}
+
+func TestBackup_ToDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	st, err := store.NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	if _, err := st.Backup(path); err == nil {
+		t.Errorf("Backup to the database file -> no error")
+	}
+}
+
+func TestCopyReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	// A database with only some of the buckets, which NewStore would add.
+	db, err := bolt.Open(src, 0o644, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucket([]byte("cmd"))
+		return err
+	})
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := must.ReadFileString(src)
+
+	dst := filepath.Join(dir, "dst")
+	if err := store.CopyReadOnly(src, dst); err != nil {
+		t.Fatalf("CopyReadOnly -> error %v", err)
+	}
+	if after := must.ReadFileString(src); after != before {
+		t.Errorf("CopyReadOnly modified the source database")
+	}
+	st, err := store.NewStore(dst)
+	if err != nil {
+		t.Fatalf("NewStore on the copy -> error %v", err)
+	}
+	st.Close()
+
+	// The destination is never overwritten.
+	if err := store.CopyReadOnly(src, dst); err == nil {
+		t.Errorf("CopyReadOnly to an existing file -> no error")
+	}
+
+	st = must.OK1(store.NewStore(src))
+	defer st.Close()
+	if err := store.CopyReadOnly(src, filepath.Join(dir, "dst2")); !store.IsLocked(err) {
+		t.Errorf("CopyReadOnly of a database in use -> %v, want locked error", err)
+	}
+}
+
+func TestRestore_Errors(t *testing.T) {
+	testutil.Set(t, store.PBKDF2Iterations, 1)
+	dir := t.TempDir()
+	plain := store.MustTempStore(t)
+	plain.AddCmd("echo foo")
+	plainBackup := filepath.Join(dir, "plain")
+	mustBackup(t, plain, plainBackup)
+
+	encrypted := mustEncryptedStore(t, filepath.Join(dir, "encrypted"), "passphrase")
+	encryptedBackup := filepath.Join(dir, "encrypted-backup")
+	mustBackup(t, encrypted, encryptedBackup)
+
+	other := mustEncryptedStore(t, filepath.Join(dir, "other"), "passphrase")
+	invalid := filepath.Join(dir, "invalid")
+	os.WriteFile(invalid, []byte("not a bolt database"), 0600)
+
+	for _, tc := range []struct {
+		name   string
+		st     store.DBStore
+		backup string
+	}{
+		{"nonexistent backup", plain, filepath.Join(dir, "nonexistent")},
+		{"invalid backup", plain, invalid},
+		{"encrypted backup into unencrypted database", plain, encryptedBackup},
+		{"unencrypted backup into encrypted database", encrypted, plainBackup},
+		{"backup with another key", other, encryptedBackup},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.st.Restore(tc.backup); err == nil {
+				t.Errorf("Restore -> no error")
+			}
+		})
+	}
+	// The database is left untouched.
+	if cmd, err := plain.Cmd(1); cmd != "echo foo" || err != nil {
+		t.Errorf("Cmd(1) -> (%q, %v), want (%q, nil)", cmd, err, "echo foo")
+	}
+	if err := encrypted.Restore(encryptedBackup); err != nil {
+		t.Errorf("Restore of encrypted backup -> error %v", err)
+	}
+}
+
+func mustBackup(t *testing.T, st store.DBStore, path string) {
+	t.Helper()
+	if _, err := st.Backup(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustEncryptedStore(t *testing.T, path, passphrase string) store.DBStore {
+	t.Helper()
+	st, err := store.NewEncryptedStore(path, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	return st
+}
@@ -1,9 +1,15 @@
 package store
 
 const (
-	bucketCmd = "cmd"
-	bucketDir = "dir"
+	bucketCmd       = "cmd"
+	bucketCmdInfo   = "cmdInfo"
+	bucketDir       = "dir"
+	bucketPinnedDir = "pinnedDir"
+	bucketSetting   = "setting"
+	bucketWorkspace = "workspace"
 )
 
 // The following buckets were used before and are thus reserved:
 // "schema"
+// "shared_var" (shared variables, removed in 0.19.1)
+// "cmdTime" (migrated to "cmdInfo")
@@ -1,8 +1,9 @@
 package store
 
 import (
-	"bytes"
 	"encoding/binary"
+	"strings"
+	"time"
 
 	bolt "go.etcd.io/bbolt"
 	. "src.elv.sh/pkg/store/storedefs"
@@ -18,7 +19,7 @@ func init() {
 // NextCmdSeq returns the next sequence number of the command history.
 func (s *dbStore) NextCmdSeq() (int, error) {
 	var seq uint64
-	err := s.db.View(func(tx *bolt.Tx) error {
+	err := s.view(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucketCmd))
 		seq = b.Sequence() + 1
 		return nil
@@ -26,42 +27,173 @@ func (s *dbStore) NextCmdSeq() (int, error) {
 	return int(seq), err
 }
 
-// AddCmd adds a new command to the command history.
+// AddCmd adds a new command to the command history, removing duplicates as
+// specified by the dedup setting. If the command is not added because it is the
+// same as the last one, the sequence number of the last one is returned. If the
+// command matches one of the ignore patterns, it is not added and 0 is
+// returned.
 func (s *dbStore) AddCmd(cmd string) (int, error) {
 	var (
 		seq uint64
 		err error
 	)
-	err = s.db.Update(func(tx *bolt.Tx) error {
+	err = s.update(func(tx *bolt.Tx) error {
+		if ignoreMatcher(tx)(cmd) {
+			return nil
+		}
 		b := tx.Bucket([]byte(bucketCmd))
+		info := cmdInfo{Time: time.Now().UnixNano()}
+		switch getDedup(tx) {
+		case DedupConsecutive:
+			if k, v := b.Cursor().Last(); k != nil {
+				last, err := s.cmdText(k, v)
+				if err != nil {
+					return err
+				}
+				if last == cmd {
+					seq = unmarshalSeq(k)
+					infoBucket := tx.Bucket([]byte(bucketCmdInfo))
+					// Keep the workspace, which is indexed.
+					info.Workspace = s.getCmdInfo(infoBucket, k).Workspace
+					return s.putCmdInfo(infoBucket, k, info)
+				}
+			}
+		case DedupAll:
+			if err := s.delCmdsWithText(tx, cmd); err != nil {
+				return err
+			}
+		}
 		seq, err = b.NextSequence()
 		if err != nil {
 			return err
 		}
-		return b.Put(marshalSeq(seq), []byte(cmd))
+		return s.putCmd(tx, seq, cmd, info)
 	})
 	return int(seq), err
 }
 
+// Deletes all commands with the given text. This scans the entire command
+// history.
+func (s *dbStore) delCmdsWithText(tx *bolt.Tx, cmd string) error {
+	keys, err := s.selectCmds(tx, func(k []byte, text string) bool { return text == cmd })
+	if err != nil {
+		return err
+	}
+	return s.delCmds(tx, keys)
+}
+
+// Returns the keys of all commands for which f returns true, in the order of
+// their sequence numbers.
+func (s *dbStore) selectCmds(tx *bolt.Tx, f func(k []byte, text string) bool) ([][]byte, error) {
+	var keys [][]byte
+	err := tx.Bucket([]byte(bucketCmd)).ForEach(func(k, v []byte) error {
+		text, err := s.cmdText(k, v)
+		if err != nil {
+			return err
+		}
+		if f(k, text) {
+			// Copy the key, since the bucket is modified before it is used.
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// Deletes the commands with the given keys.
+func (s *dbStore) delCmds(tx *bolt.Tx, keys [][]byte) error {
+	b := tx.Bucket([]byte(bucketCmd))
+	info := tx.Bucket([]byte(bucketCmdInfo))
+	for _, k := range keys {
+		if err := unindexWorkspace(tx, k, s.getCmdInfo(info, k).Workspace); err != nil {
+			return err
+		}
+		if err := info.Delete(k); err != nil {
+			return err
+		}
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddCmdRecords adds commands to the command history in one transaction,
+// keeping their times, directories and results. Commands that match one of the
+// ignore patterns are skipped.
+func (s *dbStore) AddCmdRecords(records []CmdRecord) error {
+	return s.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketCmd))
+		ignore := ignoreMatcher(tx)
+		for _, r := range records {
+			if ignore(r.Text) {
+				continue
+			}
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			if err := s.putCmd(tx, seq, r.Text, newCmdInfo(r)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *dbStore) putCmd(tx *bolt.Tx, seq uint64, cmd string, info cmdInfo) error {
+	key := marshalSeq(seq)
+	if err := tx.Bucket([]byte(bucketCmd)).Put(key, s.seal(bucketCmd, key, []byte(cmd))); err != nil {
+		return err
+	}
+	if err := indexWorkspace(tx, key, info.Workspace); err != nil {
+		return err
+	}
+	return s.putCmdInfo(tx.Bucket([]byte(bucketCmdInfo)), key, info)
+}
+
+// Returns the text of a command from its value in the cmd bucket.
+func (s *dbStore) cmdText(k, v []byte) (string, error) {
+	text, err := s.open(bucketCmd, k, v)
+	return string(text), err
+}
+
+// SetCmdResult records the working directory and the result of running the
+// command with the given sequence number.
+func (s *dbStore) SetCmdResult(seq int, dir string, result CmdResult) error {
+	return s.update(func(tx *bolt.Tx) error {
+		key := marshalSeq(uint64(seq))
+		if tx.Bucket([]byte(bucketCmd)).Get(key) == nil {
+			return ErrNoMatchingCmd
+		}
+		b := tx.Bucket([]byte(bucketCmdInfo))
+		info := s.getCmdInfo(b, key)
+		info.Dir = dir
+		info.setResult(result)
+		return s.putCmdInfo(b, key, info)
+	})
+}
+
 // DelCmd deletes a command history item with the given sequence number.
 func (s *dbStore) DelCmd(seq int) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(bucketCmd))
-		return b.Delete(marshalSeq(uint64(seq)))
+	return s.update(func(tx *bolt.Tx) error {
+		return s.delCmds(tx, [][]byte{marshalSeq(uint64(seq))})
 	})
 }
 
 // Cmd queries the command history item with the specified sequence number.
 func (s *dbStore) Cmd(seq int) (string, error) {
 	var cmd string
-	err := s.db.View(func(tx *bolt.Tx) error {
+	err := s.view(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucketCmd))
-		v := b.Get(marshalSeq(uint64(seq)))
+		k := marshalSeq(uint64(seq))
+		v := b.Get(k)
 		if v == nil {
 			return ErrNoMatchingCmd
 		}
-		cmd = string(v)
-		return nil
+		var err error
+		cmd, err = s.cmdText(k, v)
+		return err
 	})
 	return cmd, err
 }
@@ -69,11 +201,15 @@ func (s *dbStore) Cmd(seq int) (string, error) {
 // IterateCmds iterates all the commands in the specified range, and calls the
 // callback with the content of each command sequentially.
 func (s *dbStore) IterateCmds(from, upto int, f func(Cmd)) error {
-	return s.db.View(func(tx *bolt.Tx) error {
+	return s.view(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucketCmd))
 		c := b.Cursor()
 		for k, v := c.Seek(marshalSeq(uint64(from))); k != nil && unmarshalSeq(k) < uint64(upto); k, v = c.Next() {
-			f(Cmd{Text: string(v), Seq: int(unmarshalSeq(k))})
+			text, err := s.cmdText(k, v)
+			if err != nil {
+				return err
+			}
+			f(Cmd{Text: text, Seq: int(unmarshalSeq(k))})
 		}
 		return nil
 	})
@@ -88,17 +224,41 @@ func (s *dbStore) CmdsWithSeq(from, upto int) ([]Cmd, error) {
 	return cmds, err
 }
 
+// CmdRecords returns all commands within the specified range, along with
+// information about how they were run.
+func (s *dbStore) CmdRecords(from, upto int) ([]CmdRecord, error) {
+	var records []CmdRecord
+	err := s.view(func(tx *bolt.Tx) error {
+		info := tx.Bucket([]byte(bucketCmdInfo))
+		c := tx.Bucket([]byte(bucketCmd)).Cursor()
+		for k, v := c.Seek(marshalSeq(uint64(from))); k != nil && unmarshalSeq(k) < uint64(upto); k, v = c.Next() {
+			text, err := s.cmdText(k, v)
+			if err != nil {
+				return err
+			}
+			r := CmdRecord{Text: text, Seq: int(unmarshalSeq(k))}
+			s.getCmdInfo(info, k).fill(&r)
+			records = append(records, r)
+		}
+		return nil
+	})
+	return records, err
+}
+
 // NextCmd finds the first command after the given sequence number (inclusive)
 // with the given prefix.
 func (s *dbStore) NextCmd(from int, prefix string) (Cmd, error) {
 	var cmd Cmd
-	err := s.db.View(func(tx *bolt.Tx) error {
+	err := s.view(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucketCmd))
 		c := b.Cursor()
-		p := []byte(prefix)
 		for k, v := c.Seek(marshalSeq(uint64(from))); k != nil; k, v = c.Next() {
-			if bytes.HasPrefix(v, p) {
-				cmd = Cmd{Text: string(v), Seq: int(unmarshalSeq(k))}
+			text, err := s.cmdText(k, v)
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(text, prefix) {
+				cmd = Cmd{Text: text, Seq: int(unmarshalSeq(k))}
 				return nil
 			}
 		}
@@ -111,10 +271,9 @@ func (s *dbStore) NextCmd(from int, prefix string) (Cmd, error) {
 // with the given prefix.
 func (s *dbStore) PrevCmd(upto int, prefix string) (Cmd, error) {
 	var cmd Cmd
-	err := s.db.View(func(tx *bolt.Tx) error {
+	err := s.view(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucketCmd))
 		c := b.Cursor()
-		p := []byte(prefix)
 
 		var v []byte
 		k, _ := c.Seek(marshalSeq(uint64(upto)))
@@ -128,8 +287,12 @@ func (s *dbStore) PrevCmd(upto int, prefix string) (Cmd, error) {
 		}
 
 		for ; k != nil; k, v = c.Prev() {
-			if bytes.HasPrefix(v, p) {
-				cmd = Cmd{Text: string(v), Seq: int(unmarshalSeq(k))}
+			text, err := s.cmdText(k, v)
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(text, prefix) {
+				cmd = Cmd{Text: text, Seq: int(unmarshalSeq(k))}
 				return nil
 			}
 		}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	. "src.elv.sh/pkg/store/storedefs"
+)
+
+// Bucket used before the cmdInfo bucket, which mapped sequence numbers to the
+// times of commands as Unix times in nanoseconds.
+const bucketCmdTimeLegacy = "cmdTime"
+
+func init() {
+	initDB["initialize command info table"] = func(tx *bolt.Tx) error {
+		info, err := tx.CreateBucketIfNotExists([]byte(bucketCmdInfo))
+		if err != nil {
+			return err
+		}
+		return migrateCmdTime(tx, info)
+	}
+}
+
+// Moves the times in the legacy cmdTime bucket to the cmdInfo bucket.
+func migrateCmdTime(tx *bolt.Tx, info *bolt.Bucket) error {
+	times := tx.Bucket([]byte(bucketCmdTimeLegacy))
+	if times == nil {
+		return nil
+	}
+	err := times.ForEach(func(k, v []byte) error {
+		if len(v) != 8 {
+			return nil
+		}
+		// The database is migrated before it is encrypted, so the information
+		// is stored as is.
+		v, err := json.Marshal(cmdInfo{Time: int64(binary.BigEndian.Uint64(v))})
+		if err != nil {
+			return err
+		}
+		return info.Put(k, v)
+	})
+	if err != nil {
+		return err
+	}
+	return tx.DeleteBucket([]byte(bucketCmdTimeLegacy))
+}
+
+// Information about a command other than its text, stored as JSON so that new
+// fields can be added without a migration. Commands added before Elvish
+// started to keep the information don't have an entry.
+type cmdInfo struct {
+	// Unix time in nanoseconds.
+	Time int64  `json:"time,omitempty"`
+	Dir  string `json:"dir,omitempty"`
+	// Whether the result of the command is known.
+	Done bool `json:"done,omitempty"`
+	// In nanoseconds.
+	Duration int64 `json:"duration,omitempty"`
+	Exit     int   `json:"exit,omitempty"`
+	// Root of the workspace the command was run in; also indexed in the
+	// workspace bucket.
+	Workspace string `json:"workspace,omitempty"`
+}
+
+func newCmdInfo(r CmdRecord) cmdInfo {
+	info := cmdInfo{Dir: r.Dir, Workspace: r.Workspace}
+	if !r.Time.IsZero() {
+		info.Time = r.Time.UnixNano()
+	}
+	if r.Result != nil {
+		info.setResult(*r.Result)
+	}
+	return info
+}
+
+func (info *cmdInfo) setResult(result CmdResult) {
+	info.Done = true
+	info.Duration = int64(result.Duration)
+	info.Exit = result.Exit
+}
+
+func (info cmdInfo) isEmpty() bool { return info == cmdInfo{} }
+
+// Sets the fields of r from the information.
+func (info cmdInfo) fill(r *CmdRecord) {
+	if info.Time != 0 {
+		r.Time = time.Unix(0, info.Time)
+	}
+	r.Dir = info.Dir
+	r.Workspace = info.Workspace
+	if info.Done {
+		r.Result = &CmdResult{Duration: time.Duration(info.Duration), Exit: info.Exit}
+	}
+}
+
+// Returns the information stored for the key, or the zero value if there is
+// none or it can't be parsed.
+func (s *dbStore) getCmdInfo(b *bolt.Bucket, key []byte) cmdInfo {
+	var info cmdInfo
+	if v := b.Get(key); v != nil {
+		if v, err := s.open(bucketCmdInfo, key, v); err == nil {
+			json.Unmarshal(v, &info)
+		}
+	}
+	return info
+}
+
+func (s *dbStore) putCmdInfo(b *bolt.Bucket, key []byte, info cmdInfo) error {
+	if info.isEmpty() {
+		return b.Delete(key)
+	}
+	v, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return b.Put(key, s.seal(bucketCmdInfo, key, v))
+}
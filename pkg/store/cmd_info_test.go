@@ -0,0 +1,53 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestMigrateCmdTime(t *testing.T) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "db"), 0644, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t1 := time.Unix(1700000000, 0)
+	err = db.Update(func(tx *bolt.Tx) error {
+		cmds, err := tx.CreateBucket([]byte(bucketCmd))
+		if err != nil {
+			return err
+		}
+		times, err := tx.CreateBucket([]byte(bucketCmdTimeLegacy))
+		if err != nil {
+			return err
+		}
+		cmds.Put(marshalSeq(1), []byte("echo old"))
+		cmds.Put(marshalSeq(2), []byte("echo foo"))
+		cmds.SetSequence(2)
+		return times.Put(marshalSeq(2), marshalSeq(uint64(t1.UnixNano())))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := NewStoreFromDB(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	records, err := st.CmdRecords(0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || !records[0].Time.IsZero() || !records[1].Time.Equal(t1) {
+		t.Errorf("got records %v, want the time of the second one to be %v", records, t1)
+	}
+	db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte(bucketCmdTimeLegacy)) != nil {
+			t.Errorf("legacy bucket still exists after migration")
+		}
+		return nil
+	})
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	. "src.elv.sh/pkg/store/storedefs"
+)
+
+// Maximum size of each transaction when copying the database in Compact.
+const compactTxMaxSize = 1 << 20
+
+// Compact checks the integrity of the database, and rewrites it to reclaim the
+// space of deleted data. Other operations are blocked until it finishes.
+//
+// If the integrity check fails, the database is not changed.
+func (s *dbStore) Compact() (CompactReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := s.db.Path()
+	info, err := os.Stat(path)
+	if err != nil {
+		return CompactReport{}, err
+	}
+	if err := check(s.db); err != nil {
+		return CompactReport{}, err
+	}
+
+	tmpPath := path + ".compact"
+	// Left by an earlier compaction that was interrupted.
+	os.Remove(tmpPath)
+	dst, err := bolt.Open(tmpPath, info.Mode().Perm(), &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return CompactReport{}, err
+	}
+	err = bolt.Compact(dst, s.db, compactTxMaxSize)
+	if err == nil {
+		err = check(dst)
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return CompactReport{}, err
+	}
+
+	// Files can't be renamed while they are open on Windows, so close the
+	// database before replacing it, and reopen it.
+	if err := s.db.Close(); err != nil {
+		os.Remove(tmpPath)
+		return CompactReport{}, err
+	}
+	renameErr := os.Rename(tmpPath, path)
+	s.db, err = dbWithDefaultOptions(path)
+	if err != nil {
+		return CompactReport{}, fmt.Errorf("reopen database: %w", err)
+	}
+	if renameErr != nil {
+		os.Remove(tmpPath)
+		return CompactReport{}, renameErr
+	}
+
+	report := CompactReport{SizeBefore: info.Size()}
+	if info, err := os.Stat(path); err == nil {
+		report.SizeAfter = info.Size()
+	}
+	report.Buckets, err = bucketStats(s.db)
+	return report, err
+}
+
+// Checks the integrity of a database.
+func check(db *bolt.DB) error {
+	return db.View(func(tx *bolt.Tx) error {
+		var errs []error
+		for err := range tx.Check() {
+			errs = append(errs, err)
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("integrity check failed: %w", errors.Join(errs...))
+		}
+		return nil
+	})
+}
+
+func bucketStats(db *bolt.DB) ([]BucketStats, error) {
+	var buckets []BucketStats
+	err := db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			st := b.Stats()
+			buckets = append(buckets, BucketStats{
+				Name: string(name), Keys: st.KeyN,
+				Size: int64(st.BranchInuse + st.LeafInuse + st.InlineBucketInuse)})
+			return nil
+		})
+	})
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Name < buckets[j].Name })
+	return buckets, err
+}
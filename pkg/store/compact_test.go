@@ -0,0 +1,55 @@
+package store_test
+
+import (
+	"strings"
+	"testing"
+
+	"src.elv.sh/pkg/store"
+	"src.elv.sh/pkg/store/storedefs"
+)
+
+func TestCompact(t *testing.T) {
+	st := store.MustTempStore(t)
+	long := strings.Repeat("x", 1000)
+	for i := 0; i < 1000; i++ {
+		st.AddCmd(long)
+	}
+	for i := 1; i < 1000; i++ {
+		st.DelCmd(i)
+	}
+	st.AddDir("/foo", 1)
+	st.SetDedup(storedefs.DedupAll)
+	st.PinDir("/bar")
+	st.SetCmdWorkspace(1000, "/ws")
+
+	report, err := st.Compact()
+	if err != nil {
+		t.Fatalf("Compact() -> error %v", err)
+	}
+	if report.SizeAfter >= report.SizeBefore {
+		t.Errorf("got size %v -> %v, want it to shrink", report.SizeBefore, report.SizeAfter)
+	}
+	var names []string
+	for _, b := range report.Buckets {
+		names = append(names, b.Name)
+		wantKeys := 1
+		if b.Name == "workspace" {
+			// The nested bucket for /ws and the command in it.
+			wantKeys = 2
+		}
+		if b.Keys != wantKeys {
+			t.Errorf("got %d keys in bucket %s, want %d", b.Keys, b.Name, wantKeys)
+		}
+	}
+	if strings.Join(names, " ") != "cmd cmdInfo dir pinnedDir setting workspace" {
+		t.Errorf("got buckets %v, want [cmd cmdInfo dir pinnedDir setting workspace]", names)
+	}
+
+	// The store still works after compacting.
+	if cmd, err := st.Cmd(1000); cmd != long || err != nil {
+		t.Errorf("Cmd(1000) -> (%.10q, %v), want the command added", cmd, err)
+	}
+	if seq, err := st.AddCmd("new"); seq != 1001 || err != nil {
+		t.Errorf("AddCmd -> (%v, %v), want (1001, nil)", seq, err)
+	}
+}
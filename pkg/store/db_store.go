@@ -1,6 +1,8 @@
 package store
 
 import (
+	"crypto/cipher"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -21,12 +23,23 @@ var initDB = map[string](func(*bolt.Tx) error){}
 // call wg.Done() in the spawned goroutine after the operation is finished.
 type DBStore interface {
 	Store
+	Compact() (CompactReport, error)
+	Backup(path string) (int64, error)
+	Restore(backup string) error
+	ApplyRetention() (int, error)
+	UpdateCheck(feed string) (UpdateCheck, error)
+	SetUpdateCheck(feed string, c UpdateCheck) error
 	Close() error
 }
 
 type dbStore struct {
+	// Protects db, which is replaced by Compact. All transactions should be
+	// run with view and update.
+	mu sync.RWMutex
 	db *bolt.DB
 	wg sync.WaitGroup // used for registering outstanding operations on the store
+	// Used to encrypt the command history; nil if the store is not encrypted.
+	aead cipher.AEAD
 }
 
 func dbWithDefaultOptions(dbname string) (*bolt.DB, error) {
@@ -37,24 +50,44 @@ func dbWithDefaultOptions(dbname string) (*bolt.DB, error) {
 	return db, err
 }
 
+// IsLocked returns whether err is returned by [NewStore] because the database
+// file is locked by another process, usually the daemon.
+func IsLocked(err error) bool {
+	return errors.Is(err, bolt.ErrTimeout)
+}
+
 // NewStore creates a new Store from the given file.
 func NewStore(dbname string) (DBStore, error) {
 	db, err := dbWithDefaultOptions(dbname)
 	if err != nil {
 		return nil, err
 	}
-	return NewStoreFromDB(db)
+	return closeOnError(NewStoreFromDB(db))
 }
 
-// NewStoreFromDB creates a new Store from a bolt DB.
+// Closes the store if there is an error, so that the database is not left
+// locked.
+func closeOnError(st DBStore, err error) (DBStore, error) {
+	if err != nil {
+		st.Close()
+		return nil, err
+	}
+	return st, nil
+}
+
+// NewStoreFromDB creates a new Store from a bolt DB. It returns
+// [ErrEncrypted] if the database is encrypted; use [NewEncryptedStoreFromDB]
+// instead.
 func NewStoreFromDB(db *bolt.DB) (DBStore, error) {
+	return newStoreFromDB(db, "")
+}
+
+func newStoreFromDB(db *bolt.DB, passphrase string) (DBStore, error) {
 	logger.Println("initializing store")
 	defer logger.Println("initialized store")
-	st := &dbStore{
-		db: db,
-		wg: sync.WaitGroup{},
-	}
+	st := &dbStore{db: db}
 
+	var encrypted bool
 	err := db.Update(func(tx *bolt.Tx) error {
 		for name, fn := range initDB {
 			err := fn(tx)
@@ -62,8 +95,20 @@ func NewStoreFromDB(db *bolt.DB) (DBStore, error) {
 				return fmt.Errorf("failed to %s: %v", name, err)
 			}
 		}
-		return nil
+		if passphrase == "" {
+			if isEncrypted(tx) {
+				return ErrEncrypted
+			}
+			return nil
+		}
+		var err error
+		encrypted, err = st.setupEncryption(tx, passphrase)
+		return err
 	})
+	if err == nil && encrypted {
+		logger.Println("encrypted command history, compacting")
+		_, err = st.Compact()
+	}
 	return st, err
 }
 
@@ -74,5 +119,19 @@ func (s *dbStore) Close() error {
 		return nil
 	}
 	s.wg.Wait()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return s.db.Close()
 }
+
+func (s *dbStore) view(fn func(*bolt.Tx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.View(fn)
+}
+
+func (s *dbStore) update(fn func(*bolt.Tx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.Update(fn)
+}
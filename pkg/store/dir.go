@@ -31,30 +31,34 @@ func unmarshalScore(data []byte) float64 {
 	return f
 }
 
-// AddDir adds a directory to the directory history.
+// AddDir adds a directory to the directory history, changing the scores as
+// specified by the dir scoring setting.
 func (s *dbStore) AddDir(d string, incFactor float64) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
+	return s.update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucketDir))
+		scoring := getDirScoring(tx)
 
-		c := b.Cursor()
-		for k, v := c.First(); k != nil; k, v = c.Next() {
-			score := unmarshalScore(v) * DirScoreDecay
-			b.Put(k, marshalScore(score))
+		if scoring.Mode != DirFrequency && scoring.Decay != 1 {
+			c := b.Cursor()
+			for k, v := c.First(); k != nil; k, v = c.Next() {
+				score := unmarshalScore(v) * scoring.Decay
+				b.Put(k, marshalScore(score))
+			}
 		}
 
 		k := []byte(d)
 		score := float64(0)
-		if v := b.Get(k); v != nil {
+		if v := b.Get(k); v != nil && scoring.Mode != DirRecency {
 			score = unmarshalScore(v)
 		}
-		score += DirScoreIncrement * incFactor
+		score += scoring.Increment * incFactor
 		return b.Put(k, marshalScore(score))
 	})
 }
 
 // AddDir adds a directory and its score to history.
 func (s *dbStore) AddDirRaw(d string, score float64) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
+	return s.update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucketDir))
 		return b.Put([]byte(d), marshalScore(score))
 	})
@@ -62,7 +66,7 @@ func (s *dbStore) AddDirRaw(d string, score float64) error {
 
 // DelDir deletes a directory record from history.
 func (s *dbStore) DelDir(d string) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
+	return s.update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucketDir))
 		return b.Delete([]byte(d))
 	})
@@ -73,7 +77,7 @@ func (s *dbStore) DelDir(d string) error {
 func (s *dbStore) Dirs(blacklist map[string]struct{}) ([]Dir, error) {
 	var dirs []Dir
 
-	err := s.db.View(func(tx *bolt.Tx) error {
+	err := s.view(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucketDir))
 		c := b.Cursor()
 		for k, v := c.First(); k != nil; k, v = c.Next() {
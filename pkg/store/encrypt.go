@@ -0,0 +1,195 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// When the store is encrypted, the values in the cmd and cmdInfo buckets are
+// encrypted with AES-256-GCM, using a key derived from a passphrase with
+// PBKDF2-HMAC-SHA256. Each encrypted value starts with a random nonce, and is
+// authenticated together with the name of the bucket and the key, so values
+// can't be moved around.
+//
+// Keys and the other buckets are not encrypted; in particular, the directory
+// history and the roots of workspaces are stored in plaintext.
+
+// Key in the setting bucket, storing the salt of the passphrase and a value
+// encrypted with the key, which is used to check the passphrase. Encrypted
+// stores always have it, and unencrypted stores never do.
+const settingEncryption = "encryption"
+
+type encryption struct {
+	Salt  []byte `json:"salt"`
+	Check []byte `json:"check"`
+}
+
+// Plaintext of the check value in the encryption setting.
+const encryptionCheck = "elvish"
+
+// Number of iterations of PBKDF2. Can be overridden in tests.
+var pbkdf2Iterations = 600000
+
+var (
+	// ErrEncrypted is returned by [NewStore] when the database is encrypted.
+	ErrEncrypted = errors.New("the database is encrypted and needs a passphrase")
+	// ErrWrongPassphrase is returned by [NewEncryptedStore] when the
+	// passphrase is wrong.
+	ErrWrongPassphrase = errors.New("wrong passphrase for the database")
+
+	errEmptyPassphrase = errors.New("passphrase must not be empty")
+	errDecrypt         = errors.New("cannot decrypt the command history")
+)
+
+// NewEncryptedStore is like [NewStore], but encrypts the command history with a
+// key derived from the passphrase. If the database is not encrypted yet, the
+// existing command history is encrypted, and the database is compacted so that
+// the plaintext doesn't remain in the file.
+func NewEncryptedStore(dbname, passphrase string) (DBStore, error) {
+	if passphrase == "" {
+		return nil, errEmptyPassphrase
+	}
+	db, err := dbWithDefaultOptions(dbname)
+	if err != nil {
+		return nil, err
+	}
+	return closeOnError(newStoreFromDB(db, passphrase))
+}
+
+// NewEncryptedStoreFromDB is like [NewStoreFromDB], but encrypts the command
+// history like [NewEncryptedStore]. If the database is compacted, db is closed
+// and replaced by a new one.
+func NewEncryptedStoreFromDB(db *bolt.DB, passphrase string) (DBStore, error) {
+	if passphrase == "" {
+		return nil, errEmptyPassphrase
+	}
+	return newStoreFromDB(db, passphrase)
+}
+
+// Sets up the encryption of the store, encrypting the command history if the
+// store is not encrypted yet. Returns whether the command history was
+// encrypted.
+func (s *dbStore) setupEncryption(tx *bolt.Tx, passphrase string) (bool, error) {
+	settings := tx.Bucket([]byte(bucketSetting))
+	if v := settings.Get([]byte(settingEncryption)); v != nil {
+		var e encryption
+		if err := json.Unmarshal(v, &e); err != nil {
+			return false, err
+		}
+		s.aead = newAEAD(passphrase, e.Salt)
+		check, err := s.open(bucketSetting, []byte(settingEncryption), e.Check)
+		if err != nil || string(check) != encryptionCheck {
+			return false, ErrWrongPassphrase
+		}
+		return false, nil
+	}
+
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	s.aead = newAEAD(passphrase, salt)
+	for _, name := range []string{bucketCmd, bucketCmdInfo} {
+		b := tx.Bucket([]byte(name))
+		// Collect the values first, since the bucket can't be modified while
+		// iterating it.
+		var keys, values [][]byte
+		b.ForEach(func(k, v []byte) error {
+			keys = append(keys, append([]byte(nil), k...))
+			values = append(values, s.seal(name, k, v))
+			return nil
+		})
+		for i, k := range keys {
+			if err := b.Put(k, values[i]); err != nil {
+				return false, err
+			}
+		}
+	}
+	v, err := json.Marshal(encryption{
+		Salt:  salt,
+		Check: s.seal(bucketSetting, []byte(settingEncryption), []byte(encryptionCheck))})
+	if err != nil {
+		return false, err
+	}
+	return true, settings.Put([]byte(settingEncryption), v)
+}
+
+func isEncrypted(tx *bolt.Tx) bool {
+	return tx.Bucket([]byte(bucketSetting)).Get([]byte(settingEncryption)) != nil
+}
+
+func newAEAD(passphrase string, salt []byte) cipher.AEAD {
+	key := pbkdf2Key([]byte(passphrase), salt, pbkdf2Iterations, 32)
+	// These only fail when the key size is invalid.
+	block, _ := aes.NewCipher(key)
+	aead, _ := cipher.NewGCM(block)
+	return aead
+}
+
+// Encrypts a value stored under the key in the bucket, if the store is
+// encrypted.
+func (s *dbStore) seal(bucket string, k, v []byte) []byte {
+	if s.aead == nil {
+		return v
+	}
+	n := s.aead.NonceSize()
+	nonce := make([]byte, n, n+len(v)+s.aead.Overhead())
+	rand.Read(nonce)
+	return s.aead.Seal(nonce, nonce, v, additionalData(bucket, k))
+}
+
+// Decrypts a value stored under the key in the bucket, if the store is
+// encrypted.
+func (s *dbStore) open(bucket string, k, v []byte) ([]byte, error) {
+	if s.aead == nil {
+		return v, nil
+	}
+	n := s.aead.NonceSize()
+	if len(v) < n {
+		return nil, errDecrypt
+	}
+	plain, err := s.aead.Open(nil, v[:n], v[n:], additionalData(bucket, k))
+	if err != nil {
+		return nil, errDecrypt
+	}
+	return plain, nil
+}
+
+func additionalData(bucket string, k []byte) []byte {
+	return append([]byte(bucket+"\x00"), k...)
+}
+
+// Derives a key with PBKDF2 from RFC 8018, using HMAC-SHA256 as the
+// pseudorandom function. This can be replaced with crypto/pbkdf2 when
+// requiring Go 1.24.
+func pbkdf2Key(password, salt []byte, iter, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	numBlocks := (keyLen + hashLen - 1) / hashLen
+
+	dk := make([]byte, 0, numBlocks*hashLen)
+	u := make([]byte, hashLen)
+	for block := 1; block <= numBlocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(binary.BigEndian.AppendUint32(nil, uint32(block)))
+		dk = prf.Sum(dk)
+		t := dk[len(dk)-hashLen:]
+		copy(u, t)
+		for n := 2; n <= iter; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for i := range u {
+				t[i] ^= u[i]
+			}
+		}
+	}
+	return dk[:keyLen]
+}
@@ -0,0 +1,105 @@
+package store_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"src.elv.sh/pkg/store"
+	"src.elv.sh/pkg/store/storedefs"
+	"src.elv.sh/pkg/store/storetest"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestPBKDF2Key(t *testing.T) {
+	// Test vectors from RFC 7914.
+	for _, tc := range []struct {
+		password, salt string
+		iter           int
+		want           string
+	}{
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+	} {
+		got := hex.EncodeToString(store.PBKDF2Key([]byte(tc.password), []byte(tc.salt), tc.iter, 64))
+		if got != tc.want {
+			t.Errorf("PBKDF2Key(%q, %q, %d, 64) -> %s, want %s",
+				tc.password, tc.salt, tc.iter, got, tc.want)
+		}
+	}
+}
+
+func TestEncryptedStore(t *testing.T) {
+	testutil.Set(t, store.PBKDF2Iterations, 1)
+	st, err := store.NewEncryptedStore(filepath.Join(t.TempDir(), "db"), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	storetest.TestCmd(t, st)
+	storetest.TestDir(t, st)
+}
+
+func TestEncryptedStore_EncryptsExistingHistory(t *testing.T) {
+	testutil.Set(t, store.PBKDF2Iterations, 1)
+	path := filepath.Join(t.TempDir(), "db")
+	mustClose := func(st store.DBStore) {
+		t.Helper()
+		if err := st.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	st, err := store.NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.AddCmd("echo secret")
+	st.SetCmdResult(1, "/secret-dir", storedefs.CmdResult{Exit: 1})
+	mustClose(st)
+
+	st, err = store.NewEncryptedStore(path, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd, err := st.Cmd(1); cmd != "echo secret" || err != nil {
+		t.Errorf("Cmd(1) -> (%q, %v), want (%q, nil)", cmd, err, "echo secret")
+	}
+	mustClose(st)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"echo secret", "/secret-dir"} {
+		if bytes.Contains(data, []byte(s)) {
+			t.Errorf("database file contains %q in plaintext", s)
+		}
+	}
+
+	if _, err := store.NewStore(path); err != store.ErrEncrypted {
+		t.Errorf("NewStore on encrypted database -> error %v, want %v", err, store.ErrEncrypted)
+	}
+	if _, err := store.NewEncryptedStore(path, "wrong"); err != store.ErrWrongPassphrase {
+		t.Errorf("NewEncryptedStore with wrong passphrase -> error %v, want %v",
+			err, store.ErrWrongPassphrase)
+	}
+
+	st, err = store.NewEncryptedStore(path, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	records, err := st.CmdRecords(0, -1)
+	if len(records) != 1 || records[0].Dir != "/secret-dir" || err != nil {
+		t.Errorf("CmdRecords(0, -1) -> (%v, %v), want the record added", records, err)
+	}
+}
+
+func TestNewEncryptedStore_EmptyPassphrase(t *testing.T) {
+	if _, err := store.NewEncryptedStore(filepath.Join(t.TempDir(), "db"), ""); err != store.ErrEmptyPassphrase {
+		t.Errorf("got error %v, want %v", err, store.ErrEmptyPassphrase)
+	}
+}
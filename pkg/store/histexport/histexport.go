@@ -0,0 +1,116 @@
+// Package histexport writes the command history in the store in formats that
+// can be processed outside Elvish.
+package histexport
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"src.elv.sh/pkg/store/storedefs"
+)
+
+// Formats lists the supported export formats.
+var Formats = []string{"text", "json"}
+
+// Number of entries read from the store in each call to CmdRecords.
+const exportBatchSize = 1000
+
+// An entry in the JSON format.
+type jsonEntry struct {
+	Seq  int    `json:"seq"`
+	Text string `json:"text"`
+	// In RFC 3339 format in UTC; omitted if the time is unknown.
+	Time      string `json:"time,omitempty"`
+	Dir       string `json:"dir,omitempty"`
+	Workspace string `json:"workspace,omitempty"`
+	// In seconds; omitted along with Exit if the result is unknown.
+	Duration *float64 `json:"duration,omitempty"`
+	Exit     *int     `json:"exit,omitempty"`
+}
+
+// Export writes the command history in the store to w in the given format,
+// one of [Formats], returning the number of entries written.
+//
+// In the text format, each entry is written as is, followed by a newline. In
+// the JSON format, each entry is written as a JSON object on its own line,
+// with the fields "seq", "text", "time", "dir", "workspace", "duration" and
+// "exit".
+func Export(w io.Writer, s storedefs.Store, format string) (int, error) {
+	var write func(storedefs.CmdRecord) error
+	switch format {
+	case "text":
+		write = func(r storedefs.CmdRecord) error {
+			_, err := fmt.Fprintln(w, r.Text)
+			return err
+		}
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		write = func(r storedefs.CmdRecord) error {
+			entry := jsonEntry{Seq: r.Seq, Text: r.Text, Dir: r.Dir, Workspace: r.Workspace}
+			if !r.Time.IsZero() {
+				entry.Time = r.Time.UTC().Format(time.RFC3339Nano)
+			}
+			if r.Result != nil {
+				duration := r.Result.Duration.Seconds()
+				entry.Duration, entry.Exit = &duration, &r.Result.Exit
+			}
+			return enc.Encode(entry)
+		}
+	default:
+		return 0, fmt.Errorf("unsupported export format: %s", format)
+	}
+
+	next, err := s.NextCmdSeq()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for from := 0; from < next; from += exportBatchSize {
+		records, err := s.CmdRecords(from, from+exportBatchSize)
+		if err != nil {
+			return n, err
+		}
+		for _, r := range records {
+			if err := write(r); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+// ParseJSON reads the entries written by [Export] in the JSON format. The Seq
+// fields of the returned records are the sequence numbers in the exported
+// store.
+func ParseJSON(r io.Reader) ([]storedefs.CmdRecord, error) {
+	var records []storedefs.CmdRecord
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for dec.More() {
+		var entry jsonEntry
+		if err := dec.Decode(&entry); err != nil {
+			return records, err
+		}
+		record := storedefs.CmdRecord{
+			Seq: entry.Seq, Text: entry.Text, Dir: entry.Dir, Workspace: entry.Workspace}
+		if entry.Time != "" {
+			t, err := time.Parse(time.RFC3339Nano, entry.Time)
+			if err != nil {
+				return records, fmt.Errorf("entry %d: %w", entry.Seq, err)
+			}
+			record.Time = t
+		}
+		if entry.Exit != nil {
+			record.Result = &storedefs.CmdResult{Exit: *entry.Exit}
+			if entry.Duration != nil {
+				record.Result.Duration = time.Duration(*entry.Duration * float64(time.Second))
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
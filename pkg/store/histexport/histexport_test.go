@@ -0,0 +1,86 @@
+package histexport
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/store"
+	"src.elv.sh/pkg/store/storedefs"
+	"src.elv.sh/pkg/tt"
+)
+
+var Args = tt.Args
+
+func TestExport(t *testing.T) {
+	s := store.MustTempStore(t)
+	s.AddCmdRecords([]storedefs.CmdRecord{
+		{Text: "echo foo", Time: time.Unix(1700000000, 0), Dir: "/tmp/ws",
+			Workspace: "/tmp/ws",
+			Result:    &storedefs.CmdResult{Duration: 1500 * time.Millisecond, Exit: 1}},
+		{Text: "deleted"},
+		{Text: "echo <a\nb>"},
+	})
+	s.DelCmd(2)
+	export := func(format string) (string, int, error) {
+		var sb strings.Builder
+		n, err := Export(&sb, s, format)
+		return sb.String(), n, err
+	}
+
+	tt.Test(t, tt.Fn(export).Named("export"),
+		Args("text").Rets("echo foo\necho <a\nb>\n", 2, error(nil)),
+		Args("json").Rets(
+			`{"seq":1,"text":"echo foo","time":"2023-11-14T22:13:20Z","dir":"/tmp/ws","workspace":"/tmp/ws","duration":1.5,"exit":1}`+"\n"+
+				`{"seq":3,"text":"echo <a\nb>"}`+"\n", 2, error(nil)),
+		Args("csv").Rets("", 0, errors.New("unsupported export format: csv")),
+	)
+}
+
+func TestExport_Batches(t *testing.T) {
+	s := store.MustTempStore(t)
+	records := make([]storedefs.CmdRecord, exportBatchSize+1)
+	for i := range records {
+		records[i] = storedefs.CmdRecord{Text: "echo foo"}
+	}
+	s.AddCmdRecords(records)
+	var sb strings.Builder
+	n, err := Export(&sb, s, "text")
+	if n != len(records) || err != nil {
+		t.Errorf("got (%v, %v), want (%v, nil)", n, err, len(records))
+	}
+	if got := strings.Count(sb.String(), "\n"); got != len(records) {
+		t.Errorf("got %v lines, want %v", got, len(records))
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	s := store.MustTempStore(t)
+	want := []storedefs.CmdRecord{
+		{Text: "echo foo", Seq: 1, Time: time.Unix(1700000000, 500).UTC(), Dir: "/tmp/ws",
+			Workspace: "/tmp/ws",
+			Result:    &storedefs.CmdResult{Duration: 1500 * time.Millisecond, Exit: 1}},
+		{Text: "echo <a\nb>", Seq: 2},
+	}
+	s.AddCmdRecords(want)
+	var sb strings.Builder
+	Export(&sb, s, "json")
+
+	got, err := ParseJSON(strings.NewReader(sb.String()))
+	if !reflect.DeepEqual(got, want) || err != nil {
+		t.Errorf("got (%v, %v), want (%v, nil)", got, err, want)
+	}
+}
+
+func TestParseJSON_Errors(t *testing.T) {
+	tt.Test(t, tt.Fn(func(s string) error {
+		_, err := ParseJSON(strings.NewReader(s))
+		return err
+	}).Named("parseJSONError"),
+		Args(`{"seq":1,"text":"foo"}{`).Rets(io.ErrUnexpectedEOF),
+		Args(`{"seq":1,"text":"foo","time":"yesterday"}`).Rets(tt.Any),
+	)
+}
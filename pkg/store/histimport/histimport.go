@@ -0,0 +1,220 @@
+// Package histimport parses the command history files of other shells, so that
+// they can be imported into Elvish's command history.
+package histimport
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"src.elv.sh/pkg/store/storedefs"
+)
+
+// Entry is an entry in the command history of a shell.
+type Entry struct {
+	Text string
+	// The time the command was run; zero if the history file doesn't record
+	// it.
+	Time time.Time
+}
+
+// Formats lists the supported history formats.
+var Formats = []string{"bash", "zsh", "fish"}
+
+// Parse parses a history file in the given format, one of [Formats]. Empty
+// commands are skipped.
+func Parse(format string, r io.Reader) ([]Entry, error) {
+	switch format {
+	case "bash":
+		return parseBash(r)
+	case "zsh":
+		return parseZsh(r)
+	case "fish":
+		return parseFish(r)
+	default:
+		return nil, fmt.Errorf("unsupported history format: %s", format)
+	}
+}
+
+func newScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16*1024*1024)
+	return scanner
+}
+
+func parseUnixTime(s string) (time.Time, bool) {
+	sec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(sec, 0), true
+}
+
+// Parses the format of ~/.bash_history. Each line is one command. If
+// $HISTTIMEFORMAT is set, bash also writes a line like "#1700000000" with the
+// timestamp before each command.
+func parseBash(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	var t time.Time
+	scanner := newScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if ts, ok := strings.CutPrefix(line, "#"); ok {
+			if parsed, ok := parseUnixTime(ts); ok {
+				t = parsed
+				continue
+			}
+		}
+		if strings.TrimSpace(line) != "" {
+			entries = append(entries, Entry{line, t})
+		}
+		t = time.Time{}
+	}
+	return entries, scanner.Err()
+}
+
+// Byte that zsh uses to escape bytes with special meanings in history files;
+// the byte following it is XOR'ed with 0x20.
+const zshMeta = 0x83
+
+// Parses the format of ~/.zsh_history. With the EXTENDED_HISTORY option, each
+// entry starts with ": <start time>:<duration>;". Newlines in commands are
+// written as a backslash followed by a newline.
+func parseZsh(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	var lines []string
+	scanner := newScanner(r)
+	for scanner.Scan() {
+		line := unmetafy(scanner.Text())
+		if strings.HasSuffix(line, `\`) {
+			lines = append(lines, line[:len(line)-1])
+			continue
+		}
+		lines = append(lines, line)
+		text := strings.Join(lines, "\n")
+		lines = nil
+
+		var t time.Time
+		if rest, ok := strings.CutPrefix(text, ": "); ok {
+			if header, cmd, ok := strings.Cut(rest, ";"); ok {
+				ts, _, _ := strings.Cut(header, ":")
+				if parsed, ok := parseUnixTime(strings.TrimSpace(ts)); ok {
+					t, text = parsed, cmd
+				}
+			}
+		}
+		if strings.TrimSpace(text) != "" {
+			entries = append(entries, Entry{text, t})
+		}
+	}
+	return entries, scanner.Err()
+}
+
+func unmetafy(s string) string {
+	if strings.IndexByte(s, zshMeta) == -1 {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == zshMeta && i+1 < len(s) {
+			i++
+			sb.WriteByte(s[i] ^ 0x20)
+		} else {
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
+
+// Parses the format of fish's history file (usually
+// ~/.local/share/fish/fish_history), which looks like YAML but isn't quite.
+// Each entry starts with a "- cmd: " line, optionally followed by an indented
+// "when: " line with the timestamp and a "paths:" list, which is ignored.
+// Newlines and backslashes in commands are escaped as \n and \\.
+func parseFish(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	scanner := newScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if cmd, ok := strings.CutPrefix(line, "- cmd: "); ok {
+			entries = append(entries, Entry{Text: unescapeFish(cmd)})
+		} else if ts, ok := strings.CutPrefix(line, "  when: "); ok && len(entries) > 0 {
+			if t, ok := parseUnixTime(strings.TrimSpace(ts)); ok {
+				entries[len(entries)-1].Time = t
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	nonEmpty := entries[:0]
+	for _, entry := range entries {
+		if strings.TrimSpace(entry.Text) != "" {
+			nonEmpty = append(nonEmpty, entry)
+		}
+	}
+	return nonEmpty, nil
+}
+
+func unescapeFish(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case '\\':
+				sb.WriteByte('\\')
+			default:
+				sb.WriteByte('\\')
+				sb.WriteByte(s[i])
+			}
+		} else {
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
+
+// GuessFormat guesses the format of a history file from its name, returning
+// "zsh" for names containing "zsh", "fish" for names containing "fish", and
+// "bash" otherwise.
+func GuessFormat(filename string) string {
+	base := filepath.Base(filename)
+	switch {
+	case strings.Contains(base, "zsh"):
+		return "zsh"
+	case strings.Contains(base, "fish"):
+		return "fish"
+	default:
+		return "bash"
+	}
+}
+
+// Number of entries added to the store in each call to AddCmdRecords. Each call
+// is one RPC when the store is the daemon.
+const importBatchSize = 1000
+
+// Import adds the entries to the command history in the store along with their
+// times, returning the number of entries added.
+func Import(s storedefs.Store, entries []Entry) (int, error) {
+	for i := 0; i < len(entries); i += importBatchSize {
+		batch := entries[i:min(i+importBatchSize, len(entries))]
+		records := make([]storedefs.CmdRecord, len(batch))
+		for j, entry := range batch {
+			records[j] = storedefs.CmdRecord{Text: entry.Text, Time: entry.Time}
+		}
+		if err := s.AddCmdRecords(records); err != nil {
+			return i, err
+		}
+	}
+	return len(entries), nil
+}
@@ -0,0 +1,94 @@
+package histimport
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/store"
+	"src.elv.sh/pkg/store/storedefs"
+	"src.elv.sh/pkg/tt"
+)
+
+var Args = tt.Args
+
+func parse(format, s string) ([]Entry, error) {
+	return Parse(format, strings.NewReader(s))
+}
+
+var (
+	t1 = time.Unix(1700000000, 0)
+	t2 = time.Unix(1700000100, 0)
+)
+
+func TestParse(t *testing.T) {
+	tt.Test(t, parse,
+		// bash
+		Args("bash", "echo foo\n\nls -l\n").
+			Rets([]Entry{{"echo foo", time.Time{}}, {"ls -l", time.Time{}}}, error(nil)),
+		Args("bash", "#1700000000\necho foo\n#1700000100\n# comment\n").
+			Rets([]Entry{{"echo foo", t1}, {"# comment", t2}}, error(nil)),
+
+		// zsh, with and without extended history
+		Args("zsh", ": 1700000000:0;echo foo\n: 1700000100:5;for x in a b; do\\\n  echo $x\\\ndone\n").
+			Rets([]Entry{{"echo foo", t1}, {"for x in a b; do\n  echo $x\ndone", t2}}, error(nil)),
+		Args("zsh", "echo foo\nls\n").
+			Rets([]Entry{{"echo foo", time.Time{}}, {"ls", time.Time{}}}, error(nil)),
+		// Metafied bytes; "\xe4\xbd\xa0" is "你" in UTF-8, and 0xa0 ^ 0x20 is
+		// 0x80.
+		Args("zsh", "echo \xe4\xbd\x83\x80\n").
+			Rets([]Entry{{"echo \xe4\xbd\xa0", time.Time{}}}, error(nil)),
+
+		// fish
+		Args("fish", "- cmd: echo foo\n  when: 1700000000\n- cmd: echo a\\nb\\\\c\n  when: 1700000100\n  paths:\n    - a\n").
+			Rets([]Entry{{"echo foo", t1}, {"echo a\nb\\c", t2}}, error(nil)),
+		Args("fish", "- cmd: \n  when: 1700000000\n").
+			Rets([]Entry{}, error(nil)),
+
+		Args("csh", "").Rets([]Entry(nil), errors.New("unsupported history format: csh")),
+	)
+}
+
+func TestGuessFormat(t *testing.T) {
+	tt.Test(t, GuessFormat,
+		Args("/home/u/.zsh_history").Rets("zsh"),
+		Args("/home/u/.local/share/fish/fish_history").Rets("fish"),
+		Args("/home/u/.bash_history").Rets("bash"),
+		Args("history.txt").Rets("bash"),
+	)
+}
+
+func TestImport(t *testing.T) {
+	s := store.MustTempStore(t)
+	s.AddCmd("echo old")
+	n, err := Import(s, []Entry{{"echo foo", t1}, {"echo bar", t2}})
+	if n != 2 || err != nil {
+		t.Errorf("got (%v, %v), want (2, nil)", n, err)
+	}
+	cmds, _ := s.CmdsWithSeq(0, 100)
+	want := []storedefs.Cmd{{Text: "echo old", Seq: 1}, {Text: "echo foo", Seq: 2}, {Text: "echo bar", Seq: 3}}
+	if !reflect.DeepEqual(cmds, want) {
+		t.Errorf("got commands %v, want %v", cmds, want)
+	}
+	records, _ := s.CmdRecords(2, 4)
+	if len(records) != 2 || !records[0].Time.Equal(t1) || !records[1].Time.Equal(t2) {
+		t.Errorf("got records %v, want times %v and %v", records, t1, t2)
+	}
+}
+
+func TestImport_Batches(t *testing.T) {
+	s := store.MustTempStore(t)
+	entries := make([]Entry, importBatchSize+1)
+	for i := range entries {
+		entries[i] = Entry{Text: "echo foo"}
+	}
+	n, err := Import(s, entries)
+	if n != len(entries) || err != nil {
+		t.Errorf("got (%v, %v), want (%v, nil)", n, err, len(entries))
+	}
+	if next, _ := s.NextCmdSeq(); next != len(entries)+1 {
+		t.Errorf("got next seq %v, want %v", next, len(entries)+1)
+	}
+}
@@ -0,0 +1,73 @@
+// Package histmerge merges the command history of another Elvish database,
+// for example one from another machine, into the command history in a store.
+package histmerge
+
+import (
+	"strconv"
+
+	"src.elv.sh/pkg/store/storedefs"
+)
+
+// Number of entries read from or added to a store in each call to CmdRecords
+// or AddCmdRecords. Each call is one RPC when the store is the daemon.
+const batchSize = 1000
+
+// Records returns all the entries in the command history in the store.
+func Records(s storedefs.Store) ([]storedefs.CmdRecord, error) {
+	next, err := s.NextCmdSeq()
+	if err != nil {
+		return nil, err
+	}
+	var records []storedefs.CmdRecord
+	for from := 0; from < next; from += batchSize {
+		batch, err := s.CmdRecords(from, from+batchSize)
+		if err != nil {
+			return records, err
+		}
+		records = append(records, batch...)
+	}
+	return records, nil
+}
+
+// Merge adds the records that are not in the command history in the store yet,
+// in their order, returning the number of records added.
+//
+// Two entries are considered the same if they have the same text and the
+// same time, so merging the same records again doesn't add anything. Entries
+// whose time is unknown are the same if they have the same text.
+//
+// The added entries get new sequence numbers after the existing ones, so the
+// order of the command history doesn't follow the times of the entries
+// afterwards.
+func Merge(s storedefs.Store, records []storedefs.CmdRecord) (int, error) {
+	existing, err := Records(s)
+	if err != nil {
+		return 0, err
+	}
+	seen := make(map[string]struct{}, len(existing))
+	for _, r := range existing {
+		seen[key(r)] = struct{}{}
+	}
+	var toAdd []storedefs.CmdRecord
+	for _, r := range records {
+		k := key(r)
+		if _, ok := seen[k]; !ok {
+			seen[k] = struct{}{}
+			toAdd = append(toAdd, r)
+		}
+	}
+
+	for i := 0; i < len(toAdd); i += batchSize {
+		if err := s.AddCmdRecords(toAdd[i:min(i+batchSize, len(toAdd))]); err != nil {
+			return i, err
+		}
+	}
+	return len(toAdd), nil
+}
+
+func key(r storedefs.CmdRecord) string {
+	if r.Time.IsZero() {
+		return "\x00" + r.Text
+	}
+	return strconv.FormatInt(r.Time.UnixNano(), 10) + "\x00" + r.Text
+}
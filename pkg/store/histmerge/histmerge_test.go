@@ -0,0 +1,79 @@
+package histmerge
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/store"
+	"src.elv.sh/pkg/store/storedefs"
+)
+
+var (
+	t1 = time.Unix(1700000000, 0)
+	t2 = time.Unix(1700000100, 0)
+)
+
+func TestMerge(t *testing.T) {
+	dst := store.MustTempStore(t)
+	dst.AddCmdRecords([]storedefs.CmdRecord{
+		{Text: "echo foo", Time: t1},
+		{Text: "echo old"},
+	})
+	src := store.MustTempStore(t)
+	src.AddCmdRecords([]storedefs.CmdRecord{
+		// Same entry as in dst.
+		{Text: "echo foo", Time: t1},
+		// Same text, but a different time.
+		{Text: "echo foo", Time: t2, Dir: "/tmp"},
+		// Same entry without a time as in dst.
+		{Text: "echo old"},
+		{Text: "echo new", Result: &storedefs.CmdResult{Exit: 1}},
+		// Duplicate of an earlier entry in src.
+		{Text: "echo new"},
+	})
+	records, err := Records(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := Merge(dst, records)
+	if n != 2 || err != nil {
+		t.Errorf("Merge -> (%v, %v), want (2, nil)", n, err)
+	}
+	got, _ := dst.CmdRecords(3, 5)
+	want := []storedefs.CmdRecord{
+		{Text: "echo foo", Seq: 3, Time: t2, Dir: "/tmp"},
+		{Text: "echo new", Seq: 4, Result: &storedefs.CmdResult{Exit: 1}},
+	}
+	for i := range got {
+		// Compare times with Equal instead.
+		if got[i].Time.Equal(want[i].Time) {
+			got[i].Time = want[i].Time
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got added records %v, want %v", got, want)
+	}
+
+	n, err = Merge(dst, records)
+	if n != 0 || err != nil {
+		t.Errorf("Merge again -> (%v, %v), want (0, nil)", n, err)
+	}
+}
+
+func TestMerge_Batches(t *testing.T) {
+	s := store.MustTempStore(t)
+	records := make([]storedefs.CmdRecord, batchSize+1)
+	for i := range records {
+		records[i] = storedefs.CmdRecord{Text: "echo", Time: t1.Add(time.Duration(i))}
+	}
+	n, err := Merge(s, records)
+	if n != len(records) || err != nil {
+		t.Errorf("got (%v, %v), want (%v, nil)", n, err, len(records))
+	}
+	got, err := Records(s)
+	if len(got) != len(records) || err != nil {
+		t.Errorf("Records -> %v records and error %v, want %v records", len(got), err, len(records))
+	}
+}
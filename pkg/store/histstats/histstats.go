@@ -0,0 +1,148 @@
+// Package histstats computes usage statistics from the command and directory
+// history in the store.
+package histstats
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/store/storedefs"
+	"src.elv.sh/pkg/wcwidth"
+)
+
+// Count is the number of times a command has been used.
+type Count struct {
+	Name  string
+	Count int
+}
+
+// Stats keeps usage statistics.
+type Stats struct {
+	// Number of entries in the command history.
+	Entries int
+	// Commands used in the command history, from the most used to the least
+	// used.
+	Commands []Count
+	// Entries in the directory history, in decreasing order of score.
+	Dirs []storedefs.Dir
+}
+
+// Compute computes the statistics from the store.
+func Compute(s storedefs.Store) (*Stats, error) {
+	cmds, err := s.CmdsWithSeq(0, -1)
+	if err != nil {
+		return nil, err
+	}
+	dirs, err := s.Dirs(storedefs.NoBlacklist)
+	if err != nil {
+		return nil, err
+	}
+	return &Stats{len(cmds), CountCommands(cmds), dirs}, nil
+}
+
+// CountCommands counts how many times each command is used in the command
+// history entries, from the most used to the least used. Commands used the
+// same number of times are sorted by name.
+//
+// All the commands in each entry are counted, so "ls | wc -l" counts once for
+// both ls and wc.
+func CountCommands(cmds []storedefs.Cmd) []Count {
+	m := make(map[string]int)
+	for _, cmd := range cmds {
+		for _, name := range CommandNames(cmd.Text) {
+			m[name]++
+		}
+	}
+	counts := make([]Count, 0, len(m))
+	for name, n := range m {
+		counts = append(counts, Count{name, n})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Name < counts[j].Name
+	})
+	return counts
+}
+
+// CommandNames returns the heads of all the forms in the code, in the order
+// they appear, including those in nested lambdas and output captures. If the
+// code can't be parsed, it returns the first field of the code.
+func CommandNames(code string) []string {
+	tree, err := parse.Parse(parse.Source{Name: "[history]", Code: code}, parse.Config{})
+	if err != nil {
+		if fields := strings.Fields(code); len(fields) > 0 {
+			return fields[:1]
+		}
+		return nil
+	}
+	var names []string
+	var walk func(parse.Node)
+	walk = func(n parse.Node) {
+		if form, ok := n.(*parse.Form); ok && form.Head != nil {
+			names = append(names, parse.SourceText(form.Head))
+		}
+		for _, ch := range parse.Children(n) {
+			walk(ch)
+		}
+	}
+	walk(tree.Root)
+	return names
+}
+
+// Width of the longest bar in the output of Write.
+const barWidth = 30
+
+// Write writes a human-readable report of the statistics to w, showing up to
+// top commands and directories.
+func (st *Stats) Write(w io.Writer, top int) {
+	fmt.Fprintf(w, "Command history: %d entries, %d distinct commands\n",
+		st.Entries, len(st.Commands))
+
+	commands := st.Commands[:min(top, len(st.Commands))]
+	if len(commands) > 0 {
+		fmt.Fprintln(w, "\nMost used commands:")
+		rows := make([][2]string, len(commands))
+		sizes := make([]float64, len(commands))
+		for i, c := range commands {
+			rows[i] = [2]string{c.Name, fmt.Sprint(c.Count)}
+			sizes[i] = float64(c.Count)
+		}
+		writeBarTable(w, rows, sizes)
+	}
+
+	dirs := st.Dirs[:min(top, len(st.Dirs))]
+	if len(dirs) > 0 {
+		fmt.Fprintln(w, "\nMost visited directories:")
+		rows := make([][2]string, len(dirs))
+		sizes := make([]float64, len(dirs))
+		for i, d := range dirs {
+			rows[i] = [2]string{d.Path, fmt.Sprintf("%.1f", d.Score)}
+			sizes[i] = d.Score
+		}
+		writeBarTable(w, rows, sizes)
+	}
+}
+
+// Writes a table where each row has a name, a value and a bar whose length is
+// proportional to the size. The sizes must be in decreasing order.
+func writeBarTable(w io.Writer, rows [][2]string, sizes []float64) {
+	var nameWidth, valueWidth int
+	for _, row := range rows {
+		nameWidth = max(nameWidth, wcwidth.Of(row[0]))
+		valueWidth = max(valueWidth, len(row[1]))
+	}
+	for i, row := range rows {
+		n := 0
+		if sizes[0] > 0 {
+			n = max(1, int(math.Round(sizes[i]/sizes[0]*barWidth)))
+		}
+		fmt.Fprintf(w, "  %s  %*s  %s\n",
+			wcwidth.Force(row[0], nameWidth), valueWidth, row[1], strings.Repeat("█", n))
+	}
+}
@@ -0,0 +1,60 @@
+package histstats
+
+import (
+	"strings"
+	"testing"
+
+	"src.elv.sh/pkg/store"
+	"src.elv.sh/pkg/store/storedefs"
+	"src.elv.sh/pkg/tt"
+)
+
+var Args = tt.Args
+
+func TestCommandNames(t *testing.T) {
+	tt.Test(t, CommandNames,
+		Args("ls -l").Rets([]string{"ls"}),
+		Args("ls | wc -l; git status").Rets([]string{"ls", "wc", "git"}),
+		Args("echo (pwd)").Rets([]string{"echo", "pwd"}),
+		Args("each {|x| echo $x } [a]").Rets([]string{"each", "echo"}),
+		// Parse error
+		Args("echo [").Rets([]string{"echo"}),
+		Args("").Rets([]string(nil)),
+	)
+}
+
+func TestCountCommands(t *testing.T) {
+	tt.Test(t, CountCommands,
+		Args([]storedefs.Cmd{{Text: "ls"}, {Text: "git status"}, {Text: "ls | wc"}, {Text: "git log"}}).
+			Rets([]Count{{"git", 2}, {"ls", 2}, {"wc", 1}}),
+	)
+}
+
+func TestComputeAndWrite(t *testing.T) {
+	s := store.MustTempStore(t)
+	for _, cmd := range []string{"git status", "ls", "git log", "git diff", "vim"} {
+		s.AddCmd(cmd)
+	}
+	s.AddDir("/foo", 1)
+	s.AddDir("/bar", 1)
+
+	st, err := Compute(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	st.Write(&sb, 2)
+	want := `Command history: 5 entries, 3 distinct commands
+
+Most used commands:
+  git  3  ` + strings.Repeat("█", 30) + `
+  ls   1  ` + strings.Repeat("█", 10) + `
+
+Most visited directories:
+  /bar  10.0  ` + strings.Repeat("█", 30) + `
+  /foo   9.9  ` + strings.Repeat("█", 30) + `
+`
+	if got := sb.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"sort"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func init() {
+	initDB["initialize pinned directory table"] = func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(bucketPinnedDir))
+		return err
+	}
+}
+
+// PinDir pins a directory. The pinned directories map paths to a sequence
+// number, which keeps the order they were pinned in. Pinning a directory that
+// is already pinned doesn't change its order.
+func (s *dbStore) PinDir(d string) error {
+	return s.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketPinnedDir))
+		if b.Get([]byte(d)) != nil {
+			return nil
+		}
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(d), marshalSeq(seq))
+	})
+}
+
+// UnpinDir unpins a directory. It does nothing if the directory is not pinned.
+func (s *dbStore) UnpinDir(d string) error {
+	return s.update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(bucketPinnedDir)).Delete([]byte(d))
+	})
+}
+
+// PinnedDirs returns all the pinned directories, in the order they were
+// pinned.
+func (s *dbStore) PinnedDirs() ([]string, error) {
+	type pinned struct {
+		path string
+		seq  uint64
+	}
+	var dirs []pinned
+	err := s.view(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(bucketPinnedDir)).ForEach(func(k, v []byte) error {
+			dirs = append(dirs, pinned{string(k), unmarshalSeq(v)})
+			return nil
+		})
+	})
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i].seq < dirs[j].seq })
+	paths := make([]string, len(dirs))
+	for i, d := range dirs {
+		paths[i] = d.path
+	}
+	return paths, err
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"errors"
+	"regexp"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	. "src.elv.sh/pkg/store/storedefs"
+)
+
+var errEmptyPruneFilter = errors.New("prune filter must have a time or a pattern")
+
+// Prune removes the commands selected by the filter, and returns the number of
+// commands removed.
+func (s *dbStore) Prune(f PruneFilter) (int, error) {
+	if f.Before.IsZero() && f.Pattern == "" {
+		return 0, errEmptyPruneFilter
+	}
+	var re *regexp.Regexp
+	if f.Pattern != "" {
+		var err error
+		re, err = regexp.Compile(f.Pattern)
+		if err != nil {
+			return 0, err
+		}
+	}
+	var n int
+	err := s.update(func(tx *bolt.Tx) error {
+		info := tx.Bucket([]byte(bucketCmdInfo))
+		keys, err := s.selectCmds(tx, func(k []byte, text string) bool {
+			if !f.Before.IsZero() {
+				t := s.getCmdInfo(info, k).Time
+				if t == 0 || t >= f.Before.UnixNano() {
+					return false
+				}
+			}
+			return re == nil || re.MatchString(text)
+		})
+		if err != nil {
+			return err
+		}
+		n = len(keys)
+		return s.delCmds(tx, keys)
+	})
+	return n, err
+}
+
+// ApplyRetention removes the commands that the retention policy doesn't keep,
+// and returns the number of commands removed.
+func (s *dbStore) ApplyRetention() (int, error) {
+	var n int
+	err := s.update(func(tx *bolt.Tx) error {
+		var err error
+		n, err = s.applyRetention(tx, getRetention(tx))
+		return err
+	})
+	return n, err
+}
+
+func (s *dbStore) applyRetention(tx *bolt.Tx, r Retention) (int, error) {
+	info := tx.Bucket([]byte(bucketCmdInfo))
+	var cutoff int64
+	if r.MaxAge > 0 {
+		cutoff = time.Now().Add(-r.MaxAge).UnixNano()
+	}
+	var kept int
+	keys, err := s.selectCmds(tx, func(k []byte, _ string) bool {
+		if t := s.getCmdInfo(info, k).Time; t != 0 && t < cutoff {
+			return true
+		}
+		kept++
+		return false
+	})
+	if err != nil {
+		return 0, err
+	}
+	if r.MaxSize > 0 && kept > r.MaxSize {
+		// Remove the oldest commands among those not already selected.
+		c := tx.Bucket([]byte(bucketCmd)).Cursor()
+		selected := make(map[string]bool, len(keys))
+		for _, k := range keys {
+			selected[string(k)] = true
+		}
+		for k, _ := c.First(); k != nil && kept > r.MaxSize; k, _ = c.Next() {
+			if !selected[string(k)] {
+				keys = append(keys, append([]byte(nil), k...))
+				kept--
+			}
+		}
+	}
+	return len(keys), s.delCmds(tx, keys)
+}
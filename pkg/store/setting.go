@@ -0,0 +1,222 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	. "src.elv.sh/pkg/store/storedefs"
+)
+
+// Keys in the setting bucket.
+const (
+	settingDedup      = "dedup"
+	settingRetention  = "retention"
+	settingDirScoring = "dirScoring"
+	settingIgnore     = "ignorePatterns"
+)
+
+func init() {
+	initDB["initialize setting table"] = func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(bucketSetting))
+		return err
+	}
+}
+
+// Dedup returns which duplicates are removed when adding commands.
+func (s *dbStore) Dedup() (Dedup, error) {
+	var d Dedup
+	err := s.view(func(tx *bolt.Tx) error {
+		d = getDedup(tx)
+		return nil
+	})
+	return d, err
+}
+
+// SetDedup sets which duplicates are removed when adding commands. It only
+// affects commands added afterwards.
+func (s *dbStore) SetDedup(d Dedup) error {
+	if !slices.Contains(DedupModes, d) {
+		return fmt.Errorf("invalid dedup mode: %s", d)
+	}
+	return s.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketSetting))
+		if d == DedupNone {
+			return b.Delete([]byte(settingDedup))
+		}
+		return b.Put([]byte(settingDedup), []byte(d))
+	})
+}
+
+func getDedup(tx *bolt.Tx) Dedup {
+	if v := tx.Bucket([]byte(bucketSetting)).Get([]byte(settingDedup)); v != nil {
+		return Dedup(v)
+	}
+	return DedupNone
+}
+
+// How the retention policy is stored in the setting bucket.
+type retention struct {
+	MaxSize int   `json:"maxSize,omitempty"`
+	MaxAge  int64 `json:"maxAge,omitempty"`
+}
+
+// Retention returns the policy for removing old commands.
+func (s *dbStore) Retention() (Retention, error) {
+	var r Retention
+	err := s.view(func(tx *bolt.Tx) error {
+		r = getRetention(tx)
+		return nil
+	})
+	return r, err
+}
+
+// SetRetention sets the policy for removing old commands, and removes the
+// commands that the policy doesn't keep.
+func (s *dbStore) SetRetention(r Retention) error {
+	if r.MaxSize < 0 || r.MaxAge < 0 {
+		return fmt.Errorf("retention limits must not be negative, got %d and %v", r.MaxSize, r.MaxAge)
+	}
+	return s.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketSetting))
+		if r == (Retention{}) {
+			return b.Delete([]byte(settingRetention))
+		}
+		data, err := json.Marshal(retention{r.MaxSize, int64(r.MaxAge)})
+		if err != nil {
+			return err
+		}
+		if err := b.Put([]byte(settingRetention), data); err != nil {
+			return err
+		}
+		_, err = s.applyRetention(tx, r)
+		return err
+	})
+}
+
+func getRetention(tx *bolt.Tx) Retention {
+	v := tx.Bucket([]byte(bucketSetting)).Get([]byte(settingRetention))
+	var r retention
+	if v == nil || json.Unmarshal(v, &r) != nil {
+		return Retention{}
+	}
+	return Retention{MaxSize: r.MaxSize, MaxAge: time.Duration(r.MaxAge)}
+}
+
+// DirScoring returns how the scores of directories are changed when adding a
+// directory, with zero values replaced by the defaults.
+func (s *dbStore) DirScoring() (DirScoring, error) {
+	var d DirScoring
+	err := s.view(func(tx *bolt.Tx) error {
+		d = getDirScoring(tx)
+		return nil
+	})
+	return d, err
+}
+
+// SetDirScoring sets how the scores of directories are changed when adding a
+// directory. It doesn't change existing scores.
+func (s *dbStore) SetDirScoring(d DirScoring) error {
+	switch {
+	case d.Mode != "" && !slices.Contains(DirScoringModes, d.Mode):
+		return fmt.Errorf("invalid dir scoring mode: %s", d.Mode)
+	case d.Decay < 0 || d.Decay > 1:
+		return fmt.Errorf("dir score decay must be between 0 and 1, got %v", d.Decay)
+	case d.Increment < 0:
+		return fmt.Errorf("dir score increment must be positive, got %v", d.Increment)
+	}
+	return s.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketSetting))
+		if withDefaultDirScoring(d) == withDefaultDirScoring(DirScoring{}) {
+			return b.Delete([]byte(settingDirScoring))
+		}
+		data, err := json.Marshal(withDefaultDirScoring(d))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(settingDirScoring), data)
+	})
+}
+
+func getDirScoring(tx *bolt.Tx) DirScoring {
+	var d DirScoring
+	if v := tx.Bucket([]byte(bucketSetting)).Get([]byte(settingDirScoring)); v != nil {
+		json.Unmarshal(v, &d)
+	}
+	return withDefaultDirScoring(d)
+}
+
+func withDefaultDirScoring(d DirScoring) DirScoring {
+	if d.Mode == "" {
+		d.Mode = DirFrecency
+	}
+	if d.Decay == 0 {
+		d.Decay = DirScoreDecay
+	}
+	if d.Increment == 0 {
+		d.Increment = DirScoreIncrement
+	}
+	return d
+}
+
+// IgnorePatterns returns the regular expressions of commands that are not
+// added to the command history.
+func (s *dbStore) IgnorePatterns() ([]string, error) {
+	var patterns []string
+	err := s.view(func(tx *bolt.Tx) error {
+		patterns = getIgnorePatterns(tx)
+		return nil
+	})
+	return patterns, err
+}
+
+// SetIgnorePatterns sets the regular expressions of commands that are not
+// added to the command history. It doesn't remove existing commands.
+func (s *dbStore) SetIgnorePatterns(patterns []string) error {
+	for _, p := range patterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("invalid ignore pattern: %w", err)
+		}
+	}
+	return s.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketSetting))
+		if len(patterns) == 0 {
+			return b.Delete([]byte(settingIgnore))
+		}
+		data, err := json.Marshal(patterns)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(settingIgnore), data)
+	})
+}
+
+func getIgnorePatterns(tx *bolt.Tx) []string {
+	var patterns []string
+	if v := tx.Bucket([]byte(bucketSetting)).Get([]byte(settingIgnore)); v != nil {
+		json.Unmarshal(v, &patterns)
+	}
+	return patterns
+}
+
+// Returns a function that reports whether a command matches any of the ignore
+// patterns. Patterns that no longer compile are skipped.
+func ignoreMatcher(tx *bolt.Tx) func(cmd string) bool {
+	var res []*regexp.Regexp
+	for _, p := range getIgnorePatterns(tx) {
+		if re, err := regexp.Compile(p); err == nil {
+			res = append(res, re)
+		}
+	}
+	return func(cmd string) bool {
+		for _, re := range res {
+			if re.MatchString(cmd) {
+				return true
+			}
+		}
+		return false
+	}
+}
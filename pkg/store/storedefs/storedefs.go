@@ -4,7 +4,10 @@
 // does not need to depend on the concrete implementation.
 package storedefs
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 // NoBlacklist is an empty blacklist, to be used in GetDirs.
 var NoBlacklist = map[string]struct{}{}
@@ -22,10 +25,27 @@ type Store interface {
 	CmdsWithSeq(from, upto int) ([]Cmd, error)
 	NextCmd(from int, prefix string) (Cmd, error)
 	PrevCmd(upto int, prefix string) (Cmd, error)
+	AddCmdRecords(records []CmdRecord) error
+	CmdRecords(from, upto int) ([]CmdRecord, error)
+	SetCmdResult(seq int, dir string, result CmdResult) error
+	SetCmdWorkspace(seq int, workspace string) error
+	WorkspaceCmds(workspace string, from, upto int) ([]Cmd, error)
+	Dedup() (Dedup, error)
+	SetDedup(d Dedup) error
+	Retention() (Retention, error)
+	SetRetention(r Retention) error
+	Prune(f PruneFilter) (int, error)
+	IgnorePatterns() ([]string, error)
+	SetIgnorePatterns(patterns []string) error
 
 	AddDir(dir string, incFactor float64) error
 	DelDir(dir string) error
 	Dirs(blacklist map[string]struct{}) ([]Dir, error)
+	DirScoring() (DirScoring, error)
+	SetDirScoring(s DirScoring) error
+	PinDir(dir string) error
+	UnpinDir(dir string) error
+	PinnedDirs() ([]string, error)
 }
 
 // Dir is an entry in the directory history.
@@ -34,8 +54,129 @@ type Dir struct {
 	Score float64
 }
 
+// DirScoring specifies how AddDir changes the scores of directories. Zero
+// values mean the defaults.
+type DirScoring struct {
+	// Defaults to DirFrecency.
+	Mode DirScoringMode
+	// Factor the scores of all directories are multiplied by when adding a
+	// directory, between 0 (exclusive) and 1 (inclusive). Not used in the
+	// DirFrequency mode.
+	Decay float64
+	// Score given to a directory each time it is added, multiplied by the
+	// incFactor argument of AddDir. Must be positive.
+	Increment float64
+}
+
+// DirScoringMode is how the score of a directory is computed.
+type DirScoringMode string
+
+const (
+	// Adds the increment to the score of the directory added, and decays the
+	// scores of all directories. This favors directories that are visited
+	// frequently and recently.
+	DirFrecency DirScoringMode = "frecency"
+	// Adds the increment to the score of the directory added, without
+	// decaying. The score is proportional to how many times a directory has
+	// been visited.
+	DirFrequency DirScoringMode = "frequency"
+	// Sets the score of the directory added to the increment, and decays the
+	// scores of all directories. Directories are thus ranked by when they were
+	// last visited.
+	DirRecency DirScoringMode = "recency"
+)
+
+// DirScoringModes lists all the valid values of DirScoringMode.
+var DirScoringModes = []DirScoringMode{DirFrecency, DirFrequency, DirRecency}
+
 // Cmd is an entry in the command history.
 type Cmd struct {
 	Text string
 	Seq  int
 }
+
+// CmdRecord is an entry in the command history, along with information about
+// how it was run.
+type CmdRecord struct {
+	Text string
+	// Ignored by AddCmdRecords, which always assigns new sequence numbers.
+	Seq int
+	// The time the command was added. Zero if the time is unknown, which is the
+	// case for commands added before Elvish started to keep the time, and those
+	// imported without it.
+	Time time.Time
+	// The working directory the command was run in; empty if unknown.
+	Dir string
+	// The root of the workspace the command was run in; empty if unknown or
+	// not run in a workspace.
+	Workspace string
+	// Result of running the command; nil if unknown, which is also the case
+	// when the command is still running.
+	Result *CmdResult
+}
+
+// CmdResult is the result of running a command in the command history.
+type CmdResult struct {
+	// Wall-clock time spent running the command.
+	Duration time.Duration
+	// 0 if the command ran without exceptions. If it was terminated because
+	// an external command exited with a non-zero status, or was killed by a
+	// signal, this is the exit status or 128 plus the signal number
+	// respectively, like in POSIX shells. Otherwise 1.
+	Exit int
+}
+
+// Dedup specifies which duplicates of a command are removed when adding it to
+// the command history with AddCmd.
+type Dedup string
+
+const (
+	// Keep all duplicates. This is the default.
+	DedupNone Dedup = "none"
+	// Don't add a command that is the same as the last one; update the time of
+	// the last one instead.
+	DedupConsecutive Dedup = "consecutive"
+	// Remove all earlier occurrences of a command when adding it.
+	DedupAll Dedup = "all"
+)
+
+// DedupModes lists all the valid values of Dedup.
+var DedupModes = []Dedup{DedupNone, DedupConsecutive, DedupAll}
+
+// Retention is a policy for removing old commands from the command history.
+// Zero values mean no limit.
+type Retention struct {
+	// Maximum number of commands to keep. The oldest ones are removed first.
+	MaxSize int
+	// Maximum age of commands to keep. Commands whose time is unknown are
+	// never removed because of their age.
+	MaxAge time.Duration
+}
+
+// PruneFilter selects the commands to remove with Prune. A command is removed
+// if it matches all the filters that are set; at least one must be set.
+type PruneFilter struct {
+	// If not zero, matches commands added before this time. Commands whose
+	// time is unknown are never matched.
+	Before time.Time
+	// If not empty, matches commands containing a match of this regular
+	// expression.
+	Pattern string
+}
+
+// CompactReport describes the result of compacting a database.
+type CompactReport struct {
+	// Size of the database file before and after compacting, in bytes.
+	SizeBefore, SizeAfter int64
+	// Buckets in the compacted database, sorted by name.
+	Buckets []BucketStats
+}
+
+// BucketStats contains statistics of a bucket in a database.
+type BucketStats struct {
+	Name string
+	// Number of keys.
+	Keys int
+	// Number of bytes used by the pages of the bucket.
+	Size int64
+}
@@ -2,7 +2,9 @@ package storetest
 
 import (
 	"reflect"
+	"slices"
 	"testing"
+	"time"
 
 	"src.elv.sh/pkg/store/storedefs"
 )
@@ -104,6 +106,288 @@ func TestCmd(t *testing.T, store storedefs.Store) {
 		t.Errorf("Cmd(1) => (%v, %v), want (%v, %v)",
 			seq, err, "", storedefs.ErrNoMatchingCmd)
 	}
+
+	// CmdRecords of commands added with AddCmd
+	records, err := store.CmdRecords(0, endSeq)
+	if len(records) != len(cmds)-1 || err != nil {
+		t.Fatalf("store.CmdRecords(0, %v) => (%v, %v), want %v records",
+			endSeq, records, err, len(cmds)-1)
+	}
+	for _, r := range records {
+		if r.Text != cmds[r.Seq-1] || r.Time.IsZero() {
+			t.Errorf("got record %v, want text %q and non-zero time", r, cmds[r.Seq-1])
+		}
+	}
+
+	// AddCmdRecords
+	t1 := time.Unix(1700000000, 0)
+	result := &storedefs.CmdResult{Duration: time.Second, Exit: 2}
+	err = store.AddCmdRecords([]storedefs.CmdRecord{
+		{Text: "echo old", Seq: 100, Time: t1, Dir: "/tmp", Result: result},
+		{Text: "echo unknown"}})
+	if err != nil {
+		t.Errorf("store.AddCmdRecords(...) => %v, want nil", err)
+	}
+	records, err = store.CmdRecords(endSeq, endSeq+2)
+	wantRecords := []storedefs.CmdRecord{
+		{Text: "echo old", Seq: endSeq, Time: t1, Dir: "/tmp", Result: result},
+		{Text: "echo unknown", Seq: endSeq + 1}}
+	if !equalRecords(records, wantRecords) || err != nil {
+		t.Errorf("store.CmdRecords(%v, %v) => (%v, %v), want (%v, nil)",
+			endSeq, endSeq+2, records, err, wantRecords)
+	}
+
+	// SetCmdResult
+	result = &storedefs.CmdResult{Duration: time.Millisecond}
+	if err := store.SetCmdResult(endSeq+1, "/home", *result); err != nil {
+		t.Errorf("store.SetCmdResult(...) => %v, want nil", err)
+	}
+	records, err = store.CmdRecords(endSeq+1, endSeq+2)
+	wantRecords = []storedefs.CmdRecord{
+		{Text: "echo unknown", Seq: endSeq + 1, Dir: "/home", Result: result}}
+	if !equalRecords(records, wantRecords) || err != nil {
+		t.Errorf("store.CmdRecords(%v, %v) => (%v, %v), want (%v, nil)",
+			endSeq+1, endSeq+2, records, err, wantRecords)
+	}
+	err = store.SetCmdResult(1, "/home", *result)
+	if !matchErr(err, storedefs.ErrNoMatchingCmd) {
+		t.Errorf("store.SetCmdResult(1, ...) => %v, want %v", err, storedefs.ErrNoMatchingCmd)
+	}
+
+	testDedup(t, store)
+	testPrune(t, store)
+	testRetention(t, store)
+	testWorkspace(t, store)
+	testIgnorePatterns(t, store)
+}
+
+func testDedup(t *testing.T, store storedefs.Store) {
+	if dedup, err := store.Dedup(); dedup != storedefs.DedupNone || err != nil {
+		t.Errorf("store.Dedup() => (%v, %v), want (%v, nil)", dedup, err, storedefs.DedupNone)
+	}
+	if err := store.SetDedup("bad"); err == nil {
+		t.Errorf("store.SetDedup(\"bad\") => nil, want error")
+	}
+
+	addCmds := func(cmds ...string) []storedefs.Cmd {
+		t.Helper()
+		from, _ := store.NextCmdSeq()
+		for _, cmd := range cmds {
+			if _, err := store.AddCmd(cmd); err != nil {
+				t.Errorf("store.AddCmd(%q) => %v", cmd, err)
+			}
+		}
+		added, _ := store.CmdsWithSeq(from, -1)
+		return added
+	}
+	setDedup := func(d storedefs.Dedup) {
+		t.Helper()
+		if err := store.SetDedup(d); err != nil {
+			t.Errorf("store.SetDedup(%v) => %v, want nil", d, err)
+		}
+		if dedup, err := store.Dedup(); dedup != d || err != nil {
+			t.Errorf("store.Dedup() => (%v, %v), want (%v, nil)", dedup, err, d)
+		}
+	}
+
+	setDedup(storedefs.DedupConsecutive)
+	added := addCmds("dedup a", "dedup a", "dedup b", "dedup a")
+	if texts := cmdTexts(added); !reflect.DeepEqual(texts, []string{"dedup a", "dedup b", "dedup a"}) {
+		t.Errorf("with consecutive dedup, got commands %q", texts)
+	}
+	// AddCmd returns the sequence number of the existing command.
+	if seq, err := store.AddCmd("dedup a"); seq != added[2].Seq || err != nil {
+		t.Errorf("store.AddCmd(%q) => (%v, %v), want (%v, nil)", "dedup a", seq, err, added[2].Seq)
+	}
+
+	setDedup(storedefs.DedupAll)
+	addCmds("dedup c", "dedup b", "dedup a")
+	all, _ := store.CmdsWithSeq(added[0].Seq, -1)
+	if texts := cmdTexts(all); !reflect.DeepEqual(texts, []string{"dedup c", "dedup b", "dedup a"}) {
+		t.Errorf("with all dedup, got commands %q", texts)
+	}
+
+	setDedup(storedefs.DedupNone)
+	added = addCmds("dedup b", "dedup b")
+	if texts := cmdTexts(added); !reflect.DeepEqual(texts, []string{"dedup b", "dedup b"}) {
+		t.Errorf("without dedup, got commands %q", texts)
+	}
+}
+
+func testPrune(t *testing.T, store storedefs.Store) {
+	t1 := time.Unix(1700000000, 0)
+	from, _ := store.NextCmdSeq()
+	store.AddCmdRecords([]storedefs.CmdRecord{
+		{Text: "prune old", Time: t1},
+		{Text: "prune unknown"},
+		{Text: "keep old", Time: t1},
+		{Text: "prune new", Time: t1.Add(time.Hour)}})
+
+	if _, err := store.Prune(storedefs.PruneFilter{}); err == nil {
+		t.Errorf("store.Prune with empty filter => nil error, want error")
+	}
+	if _, err := store.Prune(storedefs.PruneFilter{Pattern: "("}); err == nil {
+		t.Errorf("store.Prune with invalid pattern => nil error, want error")
+	}
+
+	prune := func(f storedefs.PruneFilter, wantN int, wantTexts ...string) {
+		t.Helper()
+		n, err := store.Prune(f)
+		if n != wantN || err != nil {
+			t.Errorf("store.Prune(%v) => (%v, %v), want (%v, nil)", f, n, err, wantN)
+		}
+		cmds, _ := store.CmdsWithSeq(from, -1)
+		if texts := cmdTexts(cmds); !reflect.DeepEqual(texts, wantTexts) {
+			t.Errorf("after store.Prune(%v), got commands %q, want %q", f, texts, wantTexts)
+		}
+	}
+	prune(storedefs.PruneFilter{Before: t1.Add(time.Minute), Pattern: "^prune"}, 1,
+		"prune unknown", "keep old", "prune new")
+	prune(storedefs.PruneFilter{Pattern: "^prune"}, 2, "keep old")
+}
+
+func testRetention(t *testing.T, store storedefs.Store) {
+	if r, err := store.Retention(); r != (storedefs.Retention{}) || err != nil {
+		t.Errorf("store.Retention() => (%v, %v), want zero value", r, err)
+	}
+	if err := store.SetRetention(storedefs.Retention{MaxSize: -1}); err == nil {
+		t.Errorf("store.SetRetention with negative size => nil, want error")
+	}
+
+	now := time.Now()
+	from, _ := store.NextCmdSeq()
+	store.AddCmdRecords([]storedefs.CmdRecord{
+		{Text: "retention old", Time: now.Add(-2 * time.Hour)},
+		{Text: "retention unknown"},
+		{Text: "retention new", Time: now}})
+
+	setRetention := func(r storedefs.Retention) {
+		t.Helper()
+		if err := store.SetRetention(r); err != nil {
+			t.Errorf("store.SetRetention(%v) => %v, want nil", r, err)
+		}
+		if got, err := store.Retention(); got != r || err != nil {
+			t.Errorf("store.Retention() => (%v, %v), want (%v, nil)", got, err, r)
+		}
+	}
+
+	// Removes commands older than an hour, including those added in earlier
+	// tests with old times, but keeps commands whose time is unknown.
+	setRetention(storedefs.Retention{MaxAge: time.Hour})
+	cmds, _ := store.CmdsWithSeq(from, -1)
+	if texts := cmdTexts(cmds); !reflect.DeepEqual(texts, []string{"retention unknown", "retention new"}) {
+		t.Errorf("with max age, got commands %q", texts)
+	}
+	if cmds, _ := store.CmdsWithSeq(0, -1); slices.ContainsFunc(cmds, func(c storedefs.Cmd) bool { return c.Text == "keep old" }) {
+		t.Errorf("with max age, got old command %q", "keep old")
+	}
+
+	// Keeps only the newest command.
+	setRetention(storedefs.Retention{MaxSize: 1})
+	cmds, _ = store.CmdsWithSeq(0, -1)
+	if want := []storedefs.Cmd{{Text: "retention new", Seq: from + 2}}; !reflect.DeepEqual(cmds, want) {
+		t.Errorf("with max size, got commands %v, want %v", cmds, want)
+	}
+
+	setRetention(storedefs.Retention{})
+}
+
+func testWorkspace(t *testing.T, store storedefs.Store) {
+	from, _ := store.NextCmdSeq()
+	store.AddCmdRecords([]storedefs.CmdRecord{{Text: "ws imported", Workspace: "/ws1"}})
+	var seqs []int
+	for _, cmd := range []string{"ws a", "ws b", "ws c"} {
+		seq, _ := store.AddCmd(cmd)
+		seqs = append(seqs, seq)
+	}
+	for i, ws := range []string{"/ws1", "/ws2", "/ws1"} {
+		if err := store.SetCmdWorkspace(seqs[i], ws); err != nil {
+			t.Errorf("store.SetCmdWorkspace(%v, %q) => %v, want nil", seqs[i], ws, err)
+		}
+	}
+	// Changes the workspace of an existing command.
+	store.SetCmdWorkspace(seqs[2], "/ws2")
+
+	testWorkspaceCmds := func(ws string, from int, want ...storedefs.Cmd) {
+		t.Helper()
+		cmds, err := store.WorkspaceCmds(ws, from, -1)
+		if !equalCmds(cmds, want) || err != nil {
+			t.Errorf("store.WorkspaceCmds(%q, %v, -1) => (%v, %v), want (%v, nil)",
+				ws, from, cmds, err, want)
+		}
+	}
+	testWorkspaceCmds("/ws1", 0,
+		storedefs.Cmd{Text: "ws imported", Seq: from}, storedefs.Cmd{Text: "ws a", Seq: seqs[0]})
+	testWorkspaceCmds("/ws2", seqs[1]+1, storedefs.Cmd{Text: "ws c", Seq: seqs[2]})
+	testWorkspaceCmds("/none", 0)
+	if records, _ := store.CmdRecords(seqs[1], seqs[1]+1); len(records) != 1 || records[0].Workspace != "/ws2" {
+		t.Errorf("store.CmdRecords(%v, %v) => %v, want workspace /ws2", seqs[1], seqs[1]+1, records)
+	}
+
+	// Deleted commands are removed from the workspace.
+	store.DelCmd(seqs[0])
+	testWorkspaceCmds("/ws1", 0, storedefs.Cmd{Text: "ws imported", Seq: from})
+
+	err := store.SetCmdWorkspace(1, "/ws1")
+	if !matchErr(err, storedefs.ErrNoMatchingCmd) {
+		t.Errorf("store.SetCmdWorkspace(1, ...) => %v, want %v", err, storedefs.ErrNoMatchingCmd)
+	}
+}
+
+func testIgnorePatterns(t *testing.T, store storedefs.Store) {
+	if patterns, err := store.IgnorePatterns(); len(patterns) != 0 || err != nil {
+		t.Errorf("store.IgnorePatterns() => (%v, %v), want (nil, nil)", patterns, err)
+	}
+	if err := store.SetIgnorePatterns([]string{"("}); err == nil {
+		t.Errorf("store.SetIgnorePatterns with invalid pattern => nil, want error")
+	}
+	patterns := []string{"AWS_SECRET", "password="}
+	if err := store.SetIgnorePatterns(patterns); err != nil {
+		t.Errorf("store.SetIgnorePatterns(%q) => %v, want nil", patterns, err)
+	}
+	if got, err := store.IgnorePatterns(); !reflect.DeepEqual(got, patterns) || err != nil {
+		t.Errorf("store.IgnorePatterns() => (%q, %v), want (%q, nil)", got, err, patterns)
+	}
+
+	from, _ := store.NextCmdSeq()
+	if seq, err := store.AddCmd("export AWS_SECRET=foo"); seq != 0 || err != nil {
+		t.Errorf("store.AddCmd with ignored command => (%v, %v), want (0, nil)", seq, err)
+	}
+	store.AddCmd("echo ignore")
+	store.AddCmdRecords([]storedefs.CmdRecord{
+		{Text: "curl -d password=foo"}, {Text: "echo imported"}})
+	cmds, _ := store.CmdsWithSeq(from, -1)
+	if texts := cmdTexts(cmds); !reflect.DeepEqual(texts, []string{"echo ignore", "echo imported"}) {
+		t.Errorf("with ignore patterns, got commands %q", texts)
+	}
+
+	if err := store.SetIgnorePatterns(nil); err != nil {
+		t.Errorf("store.SetIgnorePatterns(nil) => %v, want nil", err)
+	}
+	if seq, err := store.AddCmd("export AWS_SECRET=foo"); seq == 0 || err != nil {
+		t.Errorf("store.AddCmd without ignore patterns => (%v, %v), want command added", seq, err)
+	}
+}
+
+func cmdTexts(cmds []storedefs.Cmd) []string {
+	texts := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		texts[i] = cmd.Text
+	}
+	return texts
+}
+
+func equalRecords(a, b []storedefs.CmdRecord) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Text != b[i].Text || a[i].Seq != b[i].Seq || !a[i].Time.Equal(b[i].Time) ||
+			a[i].Dir != b[i].Dir || !reflect.DeepEqual(a[i].Result, b[i].Result) {
+			return false
+		}
+	}
+	return true
 }
 
 func equalCmds(a, b []storedefs.Cmd) bool {
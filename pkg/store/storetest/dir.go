@@ -51,4 +51,78 @@ func TestDir(t *testing.T, tStore storedefs.Store) {
 		t.Errorf(`After DelDir("/usr"), tStore.ListDirs() => (%v, %v), want (%v, <nil>)`,
 			dirs, err, wantedDirsAfterDel)
 	}
+
+	testDirScoring(t, tStore)
+	testPinnedDirs(t, tStore)
+}
+
+func testPinnedDirs(t *testing.T, tStore storedefs.Store) {
+	testPinned := func(want ...string) {
+		t.Helper()
+		dirs, err := tStore.PinnedDirs()
+		if err != nil || !equalStrings(dirs, want) {
+			t.Errorf("tStore.PinnedDirs() => (%q, %v), want (%q, <nil>)", dirs, err, want)
+		}
+	}
+	testPinned()
+	for _, dir := range []string{"/b", "/a", "/c", "/b"} {
+		if err := tStore.PinDir(dir); err != nil {
+			t.Errorf("tStore.PinDir(%q) => %v, want <nil>", dir, err)
+		}
+	}
+	testPinned("/b", "/a", "/c")
+	for _, dir := range []string{"/a", "/d"} {
+		if err := tStore.UnpinDir(dir); err != nil {
+			t.Errorf("tStore.UnpinDir(%q) => %v, want <nil>", dir, err)
+		}
+	}
+	testPinned("/b", "/c")
+}
+
+func equalStrings(a, b []string) bool {
+	return (len(a) == 0 && len(b) == 0) || reflect.DeepEqual(a, b)
+}
+
+func testDirScoring(t *testing.T, tStore storedefs.Store) {
+	defaultScoring := storedefs.DirScoring{Mode: storedefs.DirFrecency,
+		Decay: store.DirScoreDecay, Increment: store.DirScoreIncrement}
+	if s, err := tStore.DirScoring(); s != defaultScoring || err != nil {
+		t.Errorf("tStore.DirScoring() => (%v, %v), want (%v, <nil>)", s, err, defaultScoring)
+	}
+	for _, bad := range []storedefs.DirScoring{{Mode: "bad"}, {Decay: 2}, {Increment: -1}} {
+		if err := tStore.SetDirScoring(bad); err == nil {
+			t.Errorf("tStore.SetDirScoring(%v) => <nil>, want error", bad)
+		}
+	}
+
+	testScores := func(s storedefs.DirScoring, want []storedefs.Dir) {
+		t.Helper()
+		if err := tStore.SetDirScoring(s); err != nil {
+			t.Errorf("tStore.SetDirScoring(%v) => %v, want <nil>", s, err)
+		}
+		for _, d := range want {
+			tStore.DelDir(d.Path)
+		}
+		for _, path := range []string{"/a", "/b", "/a"} {
+			tStore.AddDir(path, 1)
+		}
+		dirs, err := tStore.Dirs(map[string]struct{}{"/usr/local": {}, "/usr/bin": {}})
+		if err != nil || !reflect.DeepEqual(dirs, want) {
+			t.Errorf("with scoring %v, tStore.Dirs() => (%v, %v), want (%v, <nil>)",
+				s, dirs, err, want)
+		}
+	}
+	testScores(storedefs.DirScoring{Mode: storedefs.DirFrequency, Increment: 1},
+		[]storedefs.Dir{{Path: "/a", Score: 2}, {Path: "/b", Score: 1}})
+	testScores(storedefs.DirScoring{Mode: storedefs.DirRecency, Decay: 0.5, Increment: 8},
+		[]storedefs.Dir{{Path: "/a", Score: 8}, {Path: "/b", Score: 4}})
+	testScores(storedefs.DirScoring{Decay: 0.5, Increment: 8},
+		[]storedefs.Dir{{Path: "/a", Score: 10}, {Path: "/b", Score: 4}})
+
+	if err := tStore.SetDirScoring(storedefs.DirScoring{}); err != nil {
+		t.Errorf("tStore.SetDirScoring({}) => %v, want <nil>", err)
+	}
+	if s, err := tStore.DirScoring(); s != defaultScoring || err != nil {
+		t.Errorf("tStore.DirScoring() => (%v, %v), want (%v, <nil>)", s, err, defaultScoring)
+	}
 }
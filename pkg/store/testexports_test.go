@@ -0,0 +1,7 @@
+package store
+
+var (
+	PBKDF2Key          = pbkdf2Key
+	PBKDF2Iterations   = &pbkdf2Iterations
+	ErrEmptyPassphrase = errEmptyPassphrase
+)
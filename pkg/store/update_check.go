@@ -0,0 +1,53 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Key in the setting bucket, storing the results of the last update checks as
+// a JSON object mapping release feeds to UpdateCheck values.
+const settingUpdateCheck = "updateCheck"
+
+// UpdateCheck is the result of checking a release feed for the latest version
+// of Elvish.
+type UpdateCheck struct {
+	Time    time.Time `json:"time"`
+	Version string    `json:"version,omitempty"`
+	// The error message if the check failed.
+	Error string `json:"error,omitempty"`
+}
+
+// UpdateCheck returns the result of the last check of the release feed, or
+// the zero value if it has not been checked.
+func (s *dbStore) UpdateCheck(feed string) (UpdateCheck, error) {
+	var c UpdateCheck
+	err := s.view(func(tx *bolt.Tx) error {
+		c = getUpdateChecks(tx)[feed]
+		return nil
+	})
+	return c, err
+}
+
+// SetUpdateCheck records the result of checking the release feed.
+func (s *dbStore) SetUpdateCheck(feed string, c UpdateCheck) error {
+	return s.update(func(tx *bolt.Tx) error {
+		checks := getUpdateChecks(tx)
+		checks[feed] = c
+		data, err := json.Marshal(checks)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket([]byte(bucketSetting)).Put([]byte(settingUpdateCheck), data)
+	})
+}
+
+func getUpdateChecks(tx *bolt.Tx) map[string]UpdateCheck {
+	checks := make(map[string]UpdateCheck)
+	if v := tx.Bucket([]byte(bucketSetting)).Get([]byte(settingUpdateCheck)); v != nil {
+		json.Unmarshal(v, &checks)
+	}
+	return checks
+}
@@ -0,0 +1,33 @@
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/store"
+)
+
+func TestUpdateCheck(t *testing.T) {
+	st := store.MustTempStore(t)
+
+	if c, err := st.UpdateCheck("feed1"); c != (store.UpdateCheck{}) || err != nil {
+		t.Errorf("UpdateCheck -> (%v, %v), want zero value and nil", c, err)
+	}
+
+	t1 := time.Unix(1700000000, 0).UTC()
+	checks := map[string]store.UpdateCheck{
+		"feed1": {Time: t1, Version: "0.99.0"},
+		"feed2": {Time: t1.Add(time.Hour), Error: "bad feed"},
+	}
+	for feed, c := range checks {
+		if err := st.SetUpdateCheck(feed, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for feed, want := range checks {
+		c, err := st.UpdateCheck(feed)
+		if !c.Time.Equal(want.Time) || c.Version != want.Version || c.Error != want.Error || err != nil {
+			t.Errorf("UpdateCheck(%q) -> (%v, %v), want (%v, nil)", feed, c, err, want)
+		}
+	}
+}
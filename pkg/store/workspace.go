@@ -0,0 +1,93 @@
+package store
+
+import (
+	bolt "go.etcd.io/bbolt"
+	. "src.elv.sh/pkg/store/storedefs"
+)
+
+// The workspace bucket indexes the commands by the workspaces they were run
+// in. It contains a nested bucket for each workspace, keyed by the root of the
+// workspace, which in turn contains the sequence numbers of the commands as
+// keys, with empty values.
+
+func init() {
+	initDB["initialize workspace table"] = func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(bucketWorkspace))
+		return err
+	}
+}
+
+// SetCmdWorkspace records the root of the workspace the command with the given
+// sequence number was run in. An empty workspace means none.
+func (s *dbStore) SetCmdWorkspace(seq int, workspace string) error {
+	return s.update(func(tx *bolt.Tx) error {
+		key := marshalSeq(uint64(seq))
+		if tx.Bucket([]byte(bucketCmd)).Get(key) == nil {
+			return ErrNoMatchingCmd
+		}
+		b := tx.Bucket([]byte(bucketCmdInfo))
+		info := s.getCmdInfo(b, key)
+		if err := unindexWorkspace(tx, key, info.Workspace); err != nil {
+			return err
+		}
+		info.Workspace = workspace
+		if err := indexWorkspace(tx, key, workspace); err != nil {
+			return err
+		}
+		return s.putCmdInfo(b, key, info)
+	})
+}
+
+// WorkspaceCmds returns all commands within the specified range that were run
+// in the given workspace.
+func (s *dbStore) WorkspaceCmds(workspace string, from, upto int) ([]Cmd, error) {
+	var cmds []Cmd
+	err := s.view(func(tx *bolt.Tx) error {
+		ws := tx.Bucket([]byte(bucketWorkspace)).Bucket([]byte(workspace))
+		if ws == nil {
+			return nil
+		}
+		b := tx.Bucket([]byte(bucketCmd))
+		c := ws.Cursor()
+		for k, _ := c.Seek(marshalSeq(uint64(from))); k != nil && unmarshalSeq(k) < uint64(upto); k, _ = c.Next() {
+			if v := b.Get(k); v != nil {
+				text, err := s.cmdText(k, v)
+				if err != nil {
+					return err
+				}
+				cmds = append(cmds, Cmd{Text: text, Seq: int(unmarshalSeq(k))})
+			}
+		}
+		return nil
+	})
+	return cmds, err
+}
+
+func indexWorkspace(tx *bolt.Tx, key []byte, workspace string) error {
+	if workspace == "" {
+		return nil
+	}
+	ws, err := tx.Bucket([]byte(bucketWorkspace)).CreateBucketIfNotExists([]byte(workspace))
+	if err != nil {
+		return err
+	}
+	return ws.Put(key, nil)
+}
+
+func unindexWorkspace(tx *bolt.Tx, key []byte, workspace string) error {
+	if workspace == "" {
+		return nil
+	}
+	b := tx.Bucket([]byte(bucketWorkspace))
+	ws := b.Bucket([]byte(workspace))
+	if ws == nil {
+		return nil
+	}
+	if err := ws.Delete(key); err != nil {
+		return err
+	}
+	if k, _ := ws.Cursor().First(); k == nil {
+		return b.DeleteBucket([]byte(workspace))
+	}
+	return nil
+}
@@ -58,6 +58,11 @@ func (term *Termios) SetEcho(v bool) {
 	setFlag(&term.Lflag, unix.ECHO, v)
 }
 
+// SetISig sets the isig flag.
+func (term *Termios) SetISig(v bool) {
+	setFlag(&term.Lflag, unix.ISIG, v)
+}
+
 // SetICRNL sets the CRNL iflag bit.
 func (term *Termios) SetICRNL(v bool) {
 	setFlag(&term.Iflag, unix.ICRNL, v)
@@ -5,21 +5,14 @@ package sys
 import (
 	"os"
 	"os/signal"
-	"syscall"
 )
 
 func notifySignals() chan os.Signal {
-	// This catches every signal regardless of whether it is ignored.
+	// This catches every signal regardless of whether it is ignored. This
+	// includes SIGTSTP, SIGTTIN and SIGTTOU, so Elvish itself is never stopped
+	// by them; since the signals are caught rather than ignored, external
+	// commands still get the default handling, which job control relies on.
 	sigCh := make(chan os.Signal, sigsChanBufferSize)
 	signal.Notify(sigCh)
-	// Calling signal.Notify will reset the signal ignore status, so we need to
-	// call signal.Ignore every time we call signal.Notify.
-	//
-	// TODO: Remove this if, and when, job control is implemented. This
-	// handles the case of running an external command from an interactive
-	// prompt.
-	//
-	// See https://b.elv.sh/988.
-	signal.Ignore(syscall.SIGTTIN, syscall.SIGTTOU, syscall.SIGTSTP)
 	return sigCh
 }
@@ -0,0 +1,183 @@
+package wcwidth
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// This file implements a simplified version of the extended grapheme cluster
+// segmentation algorithm in https://unicode.org/reports/tr29/. It omits the
+// rules involving the Prepend property and conjunct clusters of Indic scripts,
+// which don't affect how many columns a cluster takes up on most terminals.
+
+type clusterProp int
+
+const (
+	propOther clusterProp = iota
+	propCR
+	propLF
+	propControl
+	propExtend
+	propZWJ
+	propSpacingMark
+	propRegionalIndicator
+	propL
+	propV
+	propT
+	propLV
+	propLVT
+	propPictographic
+)
+
+// Ranges of characters with the Extended_Pictographic property, excluding
+// those in the ASCII range. The list is an approximation that includes whole
+// blocks of emoji and symbols.
+var pictographicRanges = [][2]rune{
+	{0x00A9, 0x00A9}, {0x00AE, 0x00AE}, {0x203C, 0x203C},
+	{0x2049, 0x2049}, {0x2122, 0x2122}, {0x2139, 0x2139},
+	{0x2194, 0x2199}, {0x21A9, 0x21AA}, {0x231A, 0x231B},
+	{0x2328, 0x2328}, {0x2388, 0x2388}, {0x23CF, 0x23CF},
+	{0x23E9, 0x23F3}, {0x23F8, 0x23FA}, {0x24C2, 0x24C2},
+	{0x25AA, 0x25AB}, {0x25B6, 0x25B6}, {0x25C0, 0x25C0},
+	{0x25FB, 0x25FE}, {0x2600, 0x27BF}, {0x2934, 0x2935},
+	{0x2B05, 0x2B07}, {0x2B1B, 0x2B1C}, {0x2B50, 0x2B50},
+	{0x2B55, 0x2B55}, {0x3030, 0x3030}, {0x303D, 0x303D},
+	{0x3297, 0x3297}, {0x3299, 0x3299}, {0x1F000, 0x1F1E5},
+	{0x1F200, 0x1FAFF},
+}
+
+func clusterPropOf(r rune) clusterProp {
+	switch {
+	case r == '\r':
+		return propCR
+	case r == '\n':
+		return propLF
+	case r == 0x200D:
+		return propZWJ
+	case r == 0x200C || (0x1F3FB <= r && r <= 0x1F3FF) ||
+		unicode.In(r, unicode.Mn, unicode.Me, unicode.Other_Grapheme_Extend):
+		// The emoji modifiers U+1F3FB to U+1F3FF are Extend.
+		return propExtend
+	case r < 0x20 || (0x7F <= r && r < 0xA0) || r == 0x200B ||
+		r == 0x2028 || r == 0x2029 || unicode.Is(unicode.Cf, r):
+		return propControl
+	case 0x1F1E6 <= r && r <= 0x1F1FF:
+		return propRegionalIndicator
+	case unicode.Is(unicode.Mc, r):
+		return propSpacingMark
+	case (0x1100 <= r && r <= 0x115F) || (0xA960 <= r && r <= 0xA97C):
+		return propL
+	case (0x1160 <= r && r <= 0x11A7) || (0xD7B0 <= r && r <= 0xD7C6):
+		return propV
+	case (0x11A8 <= r && r <= 0x11FF) || (0xD7CB <= r && r <= 0xD7FB):
+		return propT
+	case 0xAC00 <= r && r <= 0xD7A3:
+		if (r-0xAC00)%28 == 0 {
+			return propLV
+		}
+		return propLVT
+	case r >= 0xA9 && inRange(r, pictographicRanges):
+		return propPictographic
+	}
+	return propOther
+}
+
+// NextCluster returns the length in bytes of the first extended grapheme
+// cluster in s, which is 0 if and only if s is empty.
+func NextCluster(s string) int {
+	if s == "" {
+		return 0
+	}
+	r, n := utf8.DecodeRuneInString(s)
+	prev := clusterPropOf(r)
+	// Whether the cluster so far is an emoji followed by Extend characters,
+	// optionally followed by a ZWJ.
+	emoji := prev == propPictographic
+	// Number of regional indicators in the cluster so far.
+	numRI := 0
+	if prev == propRegionalIndicator {
+		numRI = 1
+	}
+	for n < len(s) {
+		r, size := utf8.DecodeRuneInString(s[n:])
+		next := clusterPropOf(r)
+		if breaksBetween(prev, next, emoji, numRI) {
+			break
+		}
+		emoji = (emoji && (next == propExtend || next == propZWJ)) ||
+			(prev == propZWJ && next == propPictographic)
+		if next == propRegionalIndicator {
+			numRI++
+		}
+		prev = next
+		n += size
+	}
+	return n
+}
+
+func breaksBetween(prev, next clusterProp, emoji bool, numRI int) bool {
+	switch {
+	case prev == propCR && next == propLF:
+		return false
+	case prev == propCR || prev == propLF || prev == propControl ||
+		next == propCR || next == propLF || next == propControl:
+		return true
+	case prev == propL &&
+		(next == propL || next == propV || next == propLV || next == propLVT):
+		return false
+	case (prev == propLV || prev == propV) && (next == propV || next == propT):
+		return false
+	case (prev == propLVT || prev == propT) && next == propT:
+		return false
+	case next == propExtend || next == propZWJ || next == propSpacingMark:
+		return false
+	case prev == propZWJ && next == propPictographic && emoji:
+		return false
+	case prev == propRegionalIndicator && next == propRegionalIndicator:
+		return numRI%2 == 0
+	}
+	return true
+}
+
+// LastCluster returns the length in bytes of the last extended grapheme
+// cluster in s, which is 0 if and only if s is empty.
+func LastCluster(s string) int {
+	n := 0
+	for i := 0; i < len(s); i += n {
+		n = NextCluster(s[i:])
+	}
+	return n
+}
+
+// Clusters splits s into extended grapheme clusters.
+func Clusters(s string) []string {
+	var clusters []string
+	for s != "" {
+		n := NextCluster(s)
+		clusters = append(clusters, s[:n])
+		s = s[n:]
+	}
+	return clusters
+}
+
+// OfCluster returns the column width of an extended grapheme cluster.
+//
+// The width is determined by the first rune, except that an emoji presentation
+// selector (U+FE0F) makes the cluster wide and a pair of regional indicators
+// (a flag) is always wide. Overrides of the width of the other runes are added
+// to the width.
+func OfCluster(c string) int {
+	r, n := utf8.DecodeRuneInString(c)
+	w := OfRune(r)
+	for _, r := range c[n:] {
+		switch {
+		case r == 0xFE0F || clusterPropOf(r) == propRegionalIndicator:
+			w = max(w, 2)
+		default:
+			if w0, ok := getOverride(r); ok {
+				w += w0
+			}
+		}
+	}
+	return w
+}
@@ -0,0 +1,58 @@
+package wcwidth
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/tt"
+)
+
+func TestClusters(t *testing.T) {
+	tt.Test(t, Clusters,
+		Args("").Rets([]string(nil)),
+		Args("abc").Rets([]string{"a", "b", "c"}),
+		// CR LF
+		Args("a\r\nb").Rets([]string{"a", "\r\n", "b"}),
+		// Control characters are never combined
+		Args("\x01\u0301").Rets([]string{"\x01", "\u0301"}),
+		// Combining marks
+		Args("e\u0301x").Rets([]string{"e\u0301", "x"}),
+		// Spacing marks (Devanagari "ki")
+		Args("\u0915\u093f").Rets([]string{"\u0915\u093f"}),
+		// Hangul syllables made up of jamo
+		Args("\u1100\u1161\u11a8\u1100").Rets([]string{"\u1100\u1161\u11a8", "\u1100"}),
+		Args("\uac00\u11a8").Rets([]string{"\uac00\u11a8"}),
+		// Emoji with a modifier
+		Args("👍🏽👍").Rets([]string{"👍🏽", "👍"}),
+		// Emoji ZWJ sequences
+		Args("👨\u200d👩\u200d👧x").Rets([]string{"👨\u200d👩\u200d👧", "x"}),
+		// ZWJ not following an emoji
+		Args("a\u200d👩").Rets([]string{"a\u200d", "👩"}),
+		// Flags
+		Args("🇨🇳🇺🇸🇬").Rets([]string{"🇨🇳", "🇺🇸", "🇬"}),
+		// Emoji presentation selector
+		Args("\u2764\ufe0fa").Rets([]string{"\u2764\ufe0f", "a"}),
+	)
+}
+
+func TestLastCluster(t *testing.T) {
+	tt.Test(t, LastCluster,
+		Args("").Rets(0),
+		Args("ab").Rets(1),
+		Args("ae\u0301").Rets(3),
+		Args("a👨\u200d👩").Rets(len("👨\u200d👩")),
+	)
+}
+
+func TestOfCluster(t *testing.T) {
+	tt.Test(t, OfCluster,
+		Args("a").Rets(1),
+		Args("e\u0301").Rets(1),
+		Args("好").Rets(2),
+		Args("👍🏽").Rets(2),
+		Args("👨\u200d👩\u200d👧").Rets(2),
+		Args("🇨🇳").Rets(2),
+		Args("\u2764").Rets(1),
+		Args("\u2764\ufe0f").Rets(2),
+		Args("\u1100\u1161\u11a8").Rets(2),
+	)
+}
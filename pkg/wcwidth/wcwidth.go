@@ -67,13 +67,34 @@ var combiningRanges = [][2]rune{
 	{0xE0100, 0xE01EF},
 }
 
+// Emoji with East_Asian_Width=W outside the Miscellaneous Symbols and
+// Pictographs ... Geometric Shapes Extended blocks, which are all wide.
+var wideEmojiRanges = [][2]rune{
+	{0x231A, 0x231B}, {0x23E9, 0x23EC}, {0x23F0, 0x23F0},
+	{0x23F3, 0x23F3}, {0x25FD, 0x25FE}, {0x2614, 0x2615},
+	{0x2648, 0x2653}, {0x267F, 0x267F}, {0x2693, 0x2693},
+	{0x26A1, 0x26A1}, {0x26AA, 0x26AB}, {0x26BD, 0x26BE},
+	{0x26C4, 0x26C5}, {0x26CE, 0x26CE}, {0x26D4, 0x26D4},
+	{0x26EA, 0x26EA}, {0x26F2, 0x26F3}, {0x26F5, 0x26F5},
+	{0x26FA, 0x26FA}, {0x26FD, 0x26FD}, {0x2705, 0x2705},
+	{0x270A, 0x270B}, {0x2728, 0x2728}, {0x274C, 0x274C},
+	{0x274E, 0x274E}, {0x2753, 0x2755}, {0x2757, 0x2757},
+	{0x2795, 0x2797}, {0x27B0, 0x27B0}, {0x27BF, 0x27BF},
+	{0x2B1B, 0x2B1C}, {0x2B50, 0x2B50}, {0x2B55, 0x2B55},
+	{0x1F004, 0x1F004}, {0x1F0CF, 0x1F0CF}, {0x1F18E, 0x1F18E},
+	{0x1F191, 0x1F19A}, {0x1F200, 0x1F2FF}, {0x1F7E0, 0x1F7EB},
+	{0x1F90C, 0x1F9FF}, {0x1FA70, 0x1FAFF},
+}
+
 func inRange(r rune, ranges [][2]rune) bool {
 	n := len(ranges)
 	i := sort.Search(n, func(i int) bool { return r <= ranges[i][1] })
 	return i < n && r >= ranges[i][0]
 }
 
-// OfRune returns the column width of a rune.
+// OfRune returns the column width of a rune on its own. Use [Of] to get the
+// column width of strings, which handles combining sequences like emoji with
+// modifiers correctly.
 func OfRune(r rune) int {
 	if w, ok := getOverride(r); ok {
 		return w
@@ -97,7 +118,9 @@ func OfRune(r rune) int {
 			(r >= 0xffe0 && r <= 0xffe6) || /* Fullwidth Forms */
 			(r >= 0x20000 && r <= 0x2fffd) || /* CJK Extensions */
 			(r >= 0x30000 && r <= 0x3fffd) || /* Reserved for historical Chinese scripts */
-			(r >= 0x1f300 && r <= 0x1f6ff)) { // Miscellaneous Symbols and Pictographs ... Geometric Shapes Extended
+			(r >= 0x1f300 && r <= 0x1f64f) || /* Miscellaneous Symbols and Pictographs, Emoticons */
+			(r >= 0x1f680 && r <= 0x1f6ff) || /* Transport and Map Symbols */
+			inRange(r, wideEmojiRanges)) {
 		return 2
 	}
 	return 1
@@ -129,39 +152,36 @@ func Unoverride(r rune) {
 	delete(override, r)
 }
 
-// Of returns the column width of a string, assuming no soft line breaks.
+// Of returns the column width of a string, assuming no soft line breaks. The
+// width is the sum of the widths of all the extended grapheme clusters.
 func Of(s string) (w int) {
-	for _, r := range s {
-		w += OfRune(r)
+	for i := 0; i < len(s); {
+		n := NextCluster(s[i:])
+		w += OfCluster(s[i : i+n])
+		i += n
 	}
 	return
 }
 
-// Trim trims the string s so that it has a column width of at most wmax.
+// Trim trims the string s so that it has a column width of at most wmax. It
+// never breaks an extended grapheme cluster.
 func Trim(s string, wmax int) string {
 	w := 0
-	for i, r := range s {
-		w += OfRune(r)
+	for i := 0; i < len(s); {
+		n := NextCluster(s[i:])
+		w += OfCluster(s[i : i+n])
 		if w > wmax {
 			return s[:i]
 		}
+		i += n
 	}
 	return s
 }
 
 // Force forces the string s to the given column width by trimming and padding.
 func Force(s string, width int) string {
-	w := 0
-	for i, r := range s {
-		w0 := OfRune(r)
-		w += w0
-		if w > width {
-			w -= w0
-			s = s[:i]
-			break
-		}
-	}
-	return s + strings.Repeat(" ", width-w)
+	s = Trim(s, width)
+	return s + strings.Repeat(" ", width-Of(s))
 }
 
 // TrimEachLine trims each line of s so that it is no wider than the specified
@@ -18,6 +18,12 @@ func TestOf(t *testing.T) {
 
 		Args("abc").Rets(3),
 		Args("你好").Rets(4),
+
+		// Emoji
+		Args("🧑").Rets(2),
+		Args("⚡").Rets(2),
+		Args("👨\u200d👩\u200d👧 family").Rets(9),
+		Args("🇨🇳").Rets(2),
 	)
 }
 
@@ -61,6 +67,10 @@ func TestTrim(t *testing.T) {
 		Args("你好", 3).Rets("你"),
 		Args("你好", 4).Rets("你好"),
 		Args("你好", 5).Rets("你好"),
+
+		// Extended grapheme clusters are never broken
+		Args("ae\u0301c", 2).Rets("ae\u0301"),
+		Args("a👍🏽", 2).Rets("a"),
 	)
 }
 
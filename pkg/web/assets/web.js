@@ -0,0 +1,239 @@
+// Frontend of the web UI. See the doc comment of the pkg/web package for the
+// API used here.
+'use strict';
+
+const scrollbackEl = document.getElementById('scrollback');
+const codeEl = document.getElementById('code');
+const highlightedEl = document.getElementById('highlighted');
+const tipsEl = document.getElementById('tips');
+const completionsEl = document.getElementById('completions');
+
+let history = [];
+// Index into history when walking it; history.length when not walking.
+let historyIndex = 0;
+// The code being edited when the user started walking history.
+let historyDraft = '';
+// The AbortController of the running evaluation, if any.
+let evalAbort = null;
+
+// The token of the session, which the server prints in the URL of the web UI.
+const token = new URLSearchParams(location.hash.slice(1)).get('token') || '';
+
+function fetchAPI(path, options = {}) {
+  const headers = {'X-Elvish-Token': token};
+  if (options.method === 'POST') {
+    headers['Content-Type'] = 'application/json';
+  }
+  return fetch(path, {...options, headers});
+}
+
+async function postJSON(path, data) {
+  const resp = await fetchAPI(path, {method: 'POST', body: JSON.stringify(data)});
+  if (!resp.ok) {
+    throw new Error(await resp.text());
+  }
+  return resp.json();
+}
+
+// The server works with byte offsets in UTF-8, while JavaScript strings are
+// indexed in UTF-16 code units.
+const encoder = new TextEncoder();
+const decoder = new TextDecoder();
+function byteOffset(s, i) {
+  return encoder.encode(s.slice(0, i)).length;
+}
+function charOffset(s, i) {
+  return decoder.decode(encoder.encode(s).slice(0, i)).length;
+}
+
+function escapeHTML(s) {
+  return s.replace(/&/g, '&amp;').replace(/</g, '&lt;').replace(/>/g, '&gt;');
+}
+
+// Converts text with SGR sequences to HTML, using the sgr-* classes.
+function sgrToHTML(text) {
+  let html = '';
+  let classes = [];
+  let open = false;
+  for (const part of text.split(/(\x1b\[[0-9;]*m)/)) {
+    const m = part.match(/^\x1b\[([0-9;]*)m$/);
+    if (m) {
+      const codes = m[1] === '' ? ['0'] : m[1].split(';');
+      for (const code of codes) {
+        if (code === '0' || code === '') {
+          classes = [];
+        } else {
+          classes.push('sgr-' + code);
+        }
+      }
+      continue;
+    }
+    if (part === '') {
+      continue;
+    }
+    if (classes.length) {
+      html += `<span class="${classes.join(' ')}">${escapeHTML(part)}</span>`;
+    } else {
+      html += escapeHTML(part);
+    }
+  }
+  return html;
+}
+
+function appendScrollback(className, html) {
+  const el = document.createElement('pre');
+  el.className = className;
+  el.innerHTML = html;
+  scrollbackEl.appendChild(el);
+  return el;
+}
+
+async function highlight() {
+  const code = codeEl.value;
+  // Keep the trailing newline visible, since pre elements swallow it.
+  highlightedEl.innerHTML = escapeHTML(code) + '\n';
+  try {
+    const resp = await postJSON('highlight', {code});
+    if (codeEl.value !== code) {
+      return;
+    }
+    highlightedEl.innerHTML = resp.html + '\n';
+    tipsEl.innerHTML = resp.tips.map(tip => `<pre>${tip}</pre>`).join('');
+  } catch (e) {
+    // Leave the code unhighlighted.
+  }
+  codeEl.rows = code.split('\n').length;
+}
+
+async function loadHistory() {
+  try {
+    const resp = await fetchAPI('history');
+    history = await resp.json();
+  } catch (e) {
+    history = [];
+  }
+  historyIndex = history.length;
+}
+
+function setCode(code) {
+  codeEl.value = code;
+  codeEl.selectionStart = codeEl.selectionEnd = code.length;
+  highlight();
+}
+
+async function evalCode() {
+  const code = codeEl.value;
+  appendScrollback('command', document.getElementById('prompt').innerHTML +
+                              highlightedEl.innerHTML.replace(/\n$/, ''));
+  setCode('');
+  completionsEl.innerHTML = '';
+  tipsEl.innerHTML = '';
+  const outputEl = appendScrollback('output', '');
+  let output = '';
+  evalAbort = new AbortController();
+  try {
+    const resp = await fetchAPI('eval', {method: 'POST',
+                                         body: JSON.stringify({code}),
+                                         signal: evalAbort.signal});
+    if (!resp.ok) {
+      throw new Error(await resp.text());
+    }
+    const reader = resp.body.getReader();
+    const streamDecoder = new TextDecoder();
+    for (;;) {
+      const {done, value} = await reader.read();
+      if (done) {
+        break;
+      }
+      output += streamDecoder.decode(value, {stream: true});
+      outputEl.innerHTML = sgrToHTML(output);
+      window.scrollTo(0, document.body.scrollHeight);
+    }
+  } catch (e) {
+    output += e.name === 'AbortError' ? '^C\n' : e.message + '\n';
+    outputEl.innerHTML = sgrToHTML(output);
+  }
+  evalAbort = null;
+  await loadHistory();
+  window.scrollTo(0, document.body.scrollHeight);
+}
+
+function longestCommonPrefix(strs) {
+  let prefix = strs[0];
+  for (const s of strs.slice(1)) {
+    let i = 0;
+    while (i < prefix.length && i < s.length && prefix[i] === s[i]) {
+      i++;
+    }
+    prefix = prefix.slice(0, i);
+  }
+  return prefix;
+}
+
+async function completeCode() {
+  const code = codeEl.value;
+  const dot = codeEl.selectionStart;
+  let resp;
+  try {
+    resp = await postJSON('complete', {code, dot: byteOffset(code, dot)});
+  } catch (e) {
+    return;
+  }
+  if (codeEl.value !== code || resp.items.length === 0) {
+    completionsEl.innerHTML = '';
+    return;
+  }
+  const from = charOffset(code, resp.from);
+  const to = charOffset(code, resp.to);
+  const insert = resp.items.length === 1 ?
+      resp.items[0].insert :
+      longestCommonPrefix(resp.items.map(item => item.insert));
+  if (insert.length >= to - from) {
+    codeEl.value = code.slice(0, from) + insert + code.slice(to);
+    codeEl.selectionStart = codeEl.selectionEnd = from + insert.length;
+  }
+  completionsEl.innerHTML = resp.items.length === 1 ? '' :
+      resp.items.map(item => `<span class="item">${item.show}</span>`)
+                .join('');
+  highlight();
+}
+
+codeEl.addEventListener('input', () => {
+  completionsEl.innerHTML = '';
+  highlight();
+});
+
+codeEl.addEventListener('keydown', e => {
+  if (e.key === 'Enter' && !e.shiftKey) {
+    e.preventDefault();
+    if (evalAbort === null) {
+      evalCode();
+    }
+  } else if (e.key === 'Tab') {
+    e.preventDefault();
+    completeCode();
+  } else if (e.key === 'c' && e.ctrlKey && evalAbort !== null) {
+    e.preventDefault();
+    evalAbort.abort();
+  } else if (e.key === 'ArrowUp' && !codeEl.value.slice(0, codeEl.selectionStart).includes('\n')) {
+    if (historyIndex > 0) {
+      e.preventDefault();
+      if (historyIndex === history.length) {
+        historyDraft = codeEl.value;
+      }
+      historyIndex--;
+      setCode(history[historyIndex]);
+    }
+  } else if (e.key === 'ArrowDown' && !codeEl.value.slice(codeEl.selectionEnd).includes('\n')) {
+    if (historyIndex < history.length) {
+      e.preventDefault();
+      historyIndex++;
+      setCode(historyIndex === history.length ? historyDraft : history[historyIndex]);
+    }
+  }
+});
+
+document.addEventListener('click', () => codeEl.focus());
+
+loadHistory();
+highlight();
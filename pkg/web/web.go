@@ -0,0 +1,361 @@
+// Package web implements a web-based interactive session, which serves a web
+// UI that works like a terminal running Elvish.
+//
+// The web UI talks to the server with a simple HTTP API:
+//
+//   - POST /eval takes a JSON object with the "code" field, evaluates the
+//     code, and streams the output back, with styles encoded as SGR sequences
+//     as in a terminal.
+//
+//   - POST /complete and POST /highlight take a JSON object with the
+//     "code" field (and the "dot" field for /complete, the byte offset of
+//     the cursor), and respond with a JSON object containing the completion
+//     candidates and the highlighted code respectively.
+//
+//   - GET /history responds with a JSON array of the command history.
+//
+// Since the API can run arbitrary code, it is protected against requests from
+// other web pages and other users of the machine. All requests must use
+// localhost or a loopback address with the port of the server as the Host.
+// API requests must also carry the token of the session in the X-Elvish-Token
+// header, must have an Origin header matching the Host if they have one, and
+// must use the application/json content type if they are POST requests. The
+// web UI gets the token from the fragment of its URL, like
+// http://localhost:3171/#token=xxx.
+package web
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/edit"
+	"src.elv.sh/pkg/edit/complete"
+	"src.elv.sh/pkg/edit/highlight"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/store/storedefs"
+	"src.elv.sh/pkg/ui"
+)
+
+//go:embed assets
+var assets embed.FS
+
+// Maximum number of history entries sent to the web UI.
+const maxHistory = 1000
+
+// Name of the header that carries the token in API requests.
+const tokenHeader = "X-Elvish-Token"
+
+// Config keeps configuration for the web UI.
+type Config struct {
+	// Used to store command history. If nil, the history is only kept in
+	// memory.
+	Store storedefs.Store
+	// The token that API requests must carry. If empty, all API requests are
+	// rejected.
+	Token string
+}
+
+// NewToken generates a random token for [Config].
+func NewToken() (string, error) {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf[:]), nil
+}
+
+type server struct {
+	ev    *eval.Evaler
+	store storedefs.Store
+	token string
+	hl    *highlight.Highlighter
+
+	// Serializes evaluations and protects the fields below.
+	mu      sync.Mutex
+	cmdNum  int
+	history []string
+}
+
+// Handler returns an [http.Handler] that serves the web UI, evaluating code
+// with ev.
+func Handler(ev *eval.Evaler, cfg Config) http.Handler {
+	s := &server{ev: ev, store: cfg.Store, token: cfg.Token}
+	s.hl = highlight.NewHighlighter(highlight.Config{
+		Check: func(t parse.Tree) (string, []*eval.CompilationError) {
+			_, err := ev.CheckTree(t, nil)
+			return "", eval.UnpackCompilationErrors(err)
+		},
+		HasCommand: func(cmd string) bool { return edit.HasCommand(ev, cmd) },
+	})
+	// The highlighter notifies late updates via a buffered channel; drain it
+	// to avoid blocking it. The web UI doesn't support late updates, and gets
+	// them the next time the code is highlighted.
+	go func() {
+		for range s.hl.LateUpdates() {
+		}
+	}()
+
+	mux := http.NewServeMux()
+	assetsFS, _ := fs.Sub(assets, "assets")
+	mux.Handle("GET /", http.FileServer(http.FS(assetsFS)))
+	mux.HandleFunc("POST /eval", s.api(s.eval))
+	mux.HandleFunc("POST /complete", s.api(s.complete))
+	mux.HandleFunc("POST /highlight", s.api(s.highlight))
+	mux.HandleFunc("GET /history", s.api(s.getHistory))
+	return checkHost(mux)
+}
+
+// Rejects requests whose Host is not localhost or a loopback address with the
+// port of the server, which protects against DNS rebinding.
+func checkHost(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isLocalHost(r) {
+			http.Error(w, "bad host", http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func isLocalHost(r *http.Request) bool {
+	host, port, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		return false
+	}
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		if _, localPort, err := net.SplitHostPort(addr.String()); err != nil || port != localPort {
+			return false
+		}
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Wraps a handler of the API with checks that protect against requests from
+// other web pages and other users of the machine.
+func (s *server) api(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.checkAPIRequest(r); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		h(w, r)
+	}
+}
+
+func (s *server) checkAPIRequest(r *http.Request) error {
+	token := r.Header.Get(tokenHeader)
+	if s.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+		return errors.New("bad token")
+	}
+	if origin := r.Header.Get("Origin"); origin != "" && origin != "http://"+r.Host {
+		return errors.New("bad origin")
+	}
+	if r.Method == http.MethodPost {
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mediaType != "application/json" {
+			return errors.New("content type must be application/json")
+		}
+	}
+	return nil
+}
+
+func (s *server) eval(w http.ResponseWriter, r *http.Request) {
+	var req codeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	code := req.Code
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cmdNum++
+	s.addHistory(code)
+
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	copied := make(chan struct{})
+	go func() {
+		copyAndFlush(w, pr)
+		pr.Close()
+		close(copied)
+	}()
+
+	ports, cleanup := eval.PortsFromFiles(
+		[3]*os.File{eval.DevNull, pw, pw}, s.ev.ValuePrefix())
+	src := parse.Source{Name: fmt.Sprintf("[web %d]", s.cmdNum), Code: code}
+	// The request context is canceled when the web UI aborts the request,
+	// which happens when the user presses Ctrl-C.
+	err = s.ev.Eval(src, eval.EvalCfg{
+		Ports: ports, Interrupts: r.Context()})
+	cleanup()
+	if err != nil {
+		diag.ShowError(pw, err)
+	}
+	pw.Close()
+	<-copied
+}
+
+// Copies from r to w, flushing w after each read so that output is streamed.
+func copyAndFlush(w http.ResponseWriter, r io.Reader) {
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, 4096)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			w.Write(buf[:n])
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Must be called with s.mu held.
+func (s *server) addHistory(code string) {
+	if strings.TrimSpace(code) == "" {
+		return
+	}
+	if s.store != nil {
+		s.store.AddCmd(code)
+	} else {
+		s.history = append(s.history, code)
+	}
+}
+
+func (s *server) getHistory(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	history := s.history
+	if s.store != nil {
+		next, err := s.store.NextCmdSeq()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		cmds, err := s.store.CmdsWithSeq(max(0, next-maxHistory), next)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		history = make([]string, len(cmds))
+		for i, cmd := range cmds {
+			history[i] = cmd.Text
+		}
+	} else if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+	writeJSON(w, history)
+}
+
+type codeRequest struct {
+	Code string `json:"code"`
+	Dot  int    `json:"dot"`
+}
+
+type completeResponse struct {
+	// Byte offsets of the range to replace.
+	From  int                    `json:"from"`
+	To    int                    `json:"to"`
+	Items []completeResponseItem `json:"items"`
+}
+
+type completeResponseItem struct {
+	Show   string `json:"show"`
+	Insert string `json:"insert"`
+}
+
+func (s *server) complete(w http.ResponseWriter, r *http.Request) {
+	var req codeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Dot < 0 || req.Dot > len(req.Code) {
+		http.Error(w, "dot out of range", http.StatusBadRequest)
+		return
+	}
+	result, err := complete.Complete(
+		complete.CodeBuffer{Content: req.Code, Dot: req.Dot}, s.ev, complete.Config{})
+	if err != nil {
+		// Not having any completion is not an error for the web UI.
+		writeJSON(w, completeResponse{From: req.Dot, To: req.Dot, Items: []completeResponseItem{}})
+		return
+	}
+	items := make([]completeResponseItem, len(result.Items))
+	for i, item := range result.Items {
+		items[i] = completeResponseItem{textToHTML(item.ToShow), item.ToInsert}
+	}
+	writeJSON(w, completeResponse{result.Replace.From, result.Replace.To, items})
+}
+
+type highlightResponse struct {
+	HTML string   `json:"html"`
+	Tips []string `json:"tips"`
+}
+
+func (s *server) highlight(w http.ResponseWriter, r *http.Request) {
+	var req codeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	styled, tips := s.hl.Get(req.Code)
+	tipsHTML := make([]string, len(tips))
+	for i, tip := range tips {
+		tipsHTML[i] = textToHTML(tip)
+	}
+	writeJSON(w, highlightResponse{textToHTML(styled), tipsHTML})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// Converts styled text to HTML, using the same classes as the output of the
+// web UI's SGR interpreter.
+func textToHTML(t ui.Text) string {
+	var sb strings.Builder
+	for _, seg := range t {
+		var classes []string
+		for _, sgrCode := range seg.Style.SGRValues() {
+			classes = append(classes, "sgr-"+sgrCode)
+		}
+		if len(classes) > 0 {
+			fmt.Fprintf(&sb, `<span class="%s">`, strings.Join(classes, " "))
+		}
+		sb.WriteString(html.EscapeString(seg.Text))
+		if len(classes) > 0 {
+			sb.WriteString("</span>")
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,265 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/store"
+)
+
+const testToken = "test-token"
+
+func setup(t *testing.T, cfg Config) *httptest.Server {
+	ev := eval.NewEvaler()
+	if cfg.Token == "" {
+		cfg.Token = testToken
+	}
+	server := httptest.NewServer(Handler(ev, cfg))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Sends a request with the test token, and returns the status code and the
+// body of the response. If body is not empty, the request is a POST request
+// with the application/json content type.
+func request(t *testing.T, server *httptest.Server, path, body string, modify func(*http.Request)) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+	if body != "" {
+		req, err = http.NewRequest(http.MethodPost, server.URL+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(tokenHeader, testToken)
+	if modify != nil {
+		modify(req)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(content)
+}
+
+func post(t *testing.T, server *httptest.Server, path, body string) string {
+	t.Helper()
+	_, content := request(t, server, path, body, nil)
+	return content
+}
+
+func get(t *testing.T, server *httptest.Server, path string) string {
+	t.Helper()
+	_, content := request(t, server, path, "", nil)
+	return content
+}
+
+// Returns the JSON request body for /eval.
+func evalBody(code string) string {
+	body, _ := json.Marshal(codeRequest{Code: code})
+	return string(body)
+}
+
+func TestAssets(t *testing.T) {
+	server := setup(t, Config{})
+	for _, path := range []string{"/", "/web.js", "/web.css"} {
+		if body := get(t, server, path); body == "" {
+			t.Errorf("GET %s returns empty body", path)
+		}
+	}
+}
+
+var rejectedRequestTests = []struct {
+	name   string
+	path   string
+	body   string
+	modify func(*http.Request)
+	want   string
+}{
+	{
+		name:   "no token",
+		path:   "/eval",
+		body:   evalBody("echo foo"),
+		modify: func(r *http.Request) { r.Header.Del(tokenHeader) },
+		want:   "bad token",
+	},
+	{
+		name:   "wrong token",
+		path:   "/history",
+		modify: func(r *http.Request) { r.Header.Set(tokenHeader, "wrong") },
+		want:   "bad token",
+	},
+	{
+		name:   "other origin",
+		path:   "/eval",
+		body:   evalBody("echo foo"),
+		modify: func(r *http.Request) { r.Header.Set("Origin", "http://example.com") },
+		want:   "bad origin",
+	},
+	{
+		name:   "text/plain content type",
+		path:   "/eval",
+		body:   "echo foo",
+		modify: func(r *http.Request) { r.Header.Set("Content-Type", "text/plain") },
+		want:   "content type must be application/json",
+	},
+	{
+		name:   "no content type",
+		path:   "/complete",
+		body:   `{"code": "echo", "dot": 4}`,
+		modify: func(r *http.Request) { r.Header.Del("Content-Type") },
+		want:   "content type must be application/json",
+	},
+	{
+		name:   "other host",
+		path:   "/history",
+		modify: func(r *http.Request) { r.Host = "attacker.example:" + r.URL.Port() },
+		want:   "bad host",
+	},
+	{
+		name:   "other port",
+		path:   "/history",
+		modify: func(r *http.Request) { r.Host = "localhost:1" },
+		want:   "bad host",
+	},
+	{
+		name:   "assets with other host",
+		path:   "/",
+		modify: func(r *http.Request) { r.Host = "attacker.example:" + r.URL.Port() },
+		want:   "bad host",
+	},
+}
+
+func TestRejectedRequests(t *testing.T) {
+	server := setup(t, Config{})
+	for _, test := range rejectedRequestTests {
+		t.Run(test.name, func(t *testing.T) {
+			status, body := request(t, server, test.path, test.body, test.modify)
+			if status != http.StatusForbidden || strings.TrimSpace(body) != test.want {
+				t.Errorf("got %d %q, want %d %q",
+					status, body, http.StatusForbidden, test.want)
+			}
+		})
+	}
+	// None of the rejected code was run.
+	testHistory(t, server, nil)
+}
+
+func TestAcceptedHosts(t *testing.T) {
+	server := setup(t, Config{})
+	for _, host := range []string{"localhost", "127.0.0.1"} {
+		status, _ := request(t, server, "/history", "", func(r *http.Request) {
+			r.Host = host + ":" + r.URL.Port()
+			r.Header.Set("Origin", "http://"+r.Host)
+		})
+		if status != http.StatusOK {
+			t.Errorf("host %s: got status %d", host, status)
+		}
+	}
+}
+
+func TestEmptyTokenRejectsAll(t *testing.T) {
+	server := httptest.NewServer(Handler(eval.NewEvaler(), Config{}))
+	t.Cleanup(server.Close)
+	status, _ := request(t, server, "/history", "", func(r *http.Request) {
+		r.Header.Set(tokenHeader, "")
+	})
+	if status != http.StatusForbidden {
+		t.Errorf("got status %d, want %d", status, http.StatusForbidden)
+	}
+}
+
+func TestNewToken(t *testing.T) {
+	a, errA := NewToken()
+	b, errB := NewToken()
+	if errA != nil || errB != nil || len(a) != 32 || a == b {
+		t.Errorf("got tokens %q, %q, errors %v, %v", a, b, errA, errB)
+	}
+}
+
+func TestEval(t *testing.T) {
+	server := setup(t, Config{})
+
+	if got := post(t, server, "/eval", evalBody("echo foo; put bar")); got != "foo\n▶ bar\n" {
+		t.Errorf("got output %q", got)
+	}
+	if got := post(t, server, "/eval", evalBody("var x = foo")); got != "" {
+		t.Errorf("got output %q", got)
+	}
+	// Variables persist across evaluations.
+	if got := post(t, server, "/eval", evalBody("echo $x")); got != "foo\n" {
+		t.Errorf("got output %q", got)
+	}
+	if got := post(t, server, "/eval", evalBody("fail bad")); !strings.Contains(got, "bad") {
+		t.Errorf("got output %q, want it to contain error", got)
+	}
+}
+
+func TestHistory_InMemory(t *testing.T) {
+	server := setup(t, Config{})
+	post(t, server, "/eval", evalBody("echo foo"))
+	post(t, server, "/eval", evalBody(" "))
+	post(t, server, "/eval", evalBody("echo bar"))
+
+	testHistory(t, server, []string{"echo foo", "echo bar"})
+}
+
+func TestHistory_Store(t *testing.T) {
+	st := store.MustTempStore(t)
+	st.AddCmd("echo old")
+	server := setup(t, Config{Store: st})
+	post(t, server, "/eval", evalBody("echo new"))
+
+	testHistory(t, server, []string{"echo old", "echo new"})
+}
+
+func testHistory(t *testing.T, server *httptest.Server, want []string) {
+	t.Helper()
+	var history []string
+	json.Unmarshal([]byte(get(t, server, "/history")), &history)
+	if !reflect.DeepEqual(history, want) {
+		t.Errorf("got history %q, want %q", history, want)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	server := setup(t, Config{})
+
+	var resp completeResponse
+	json.Unmarshal([]byte(post(t, server, "/complete",
+		`{"code": "echo $nil", "dot": 9}`)), &resp)
+	want := completeResponse{
+		From: 6, To: 9, Items: []completeResponseItem{{"nil", "nil"}}}
+	if !reflect.DeepEqual(resp, want) {
+		t.Errorf("got %v, want %v", resp, want)
+	}
+
+	if got := post(t, server, "/complete", `{"code": "echo", "dot": 10}`); !strings.Contains(got, "dot out of range") {
+		t.Errorf("got %q, want error", got)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	server := setup(t, Config{})
+
+	var resp highlightResponse
+	json.Unmarshal([]byte(post(t, server, "/highlight", `{"code": "echo [}"}`)), &resp)
+	if want := `<span class="sgr-32">echo</span> `; !strings.HasPrefix(resp.HTML, want) {
+		t.Errorf("got HTML %q, want prefix %q", resp.HTML, want)
+	}
+	if len(resp.Tips) == 0 {
+		t.Errorf("want tips for parse error, got none")
+	}
+}